@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// AddOptions selects the files Add stages.
+type AddOptions struct {
+	Paths  []string // Slash separated and relative to the top of the worktree; none for all of it.
+	Update bool     // Only stage tracked files, leaving untracked ones alone.
+	Force  bool     // Also stage ignored files.
+	DryRun bool     // Only find out what would be staged.
+
+	// Warnings receives the core.safecrlf warnings about the files staged;
+	// see StagePath.
+	Warnings io.Writer
+}
+
+// AddChange is a file whose worktree state Add staged.
+type AddChange struct {
+	Path    string
+	Removed bool // The file is gone from the worktree and was removed from the index.
+}
+
+// AddResult describes what a call to Add staged.
+type AddResult struct {
+	Changes []AddChange // Sorted by path.
+	Ignored []string    // The ignored files or directories given or lying under a given path, left alone, sorted.
+}
+
+// Add stages the files under the given paths as they are in the worktree,
+// as git add does: tracked files that changed, were deleted or are
+// unmerged, and untracked files unless Update is set. Untracked files that
+// are ignored are only staged with Force; an ignored path given on its
+// own is reported instead, while ignored files found below a given
+// directory are skipped silently. Each file is staged with StagePath, and
+// the index written once all of them are.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - opts: The paths and which of their files to stage.
+//
+// Returns:
+// - The files staged, or that would be with DryRun, and the ignored paths.
+// - An error if a path matches no file, a file given is marked
+// skip-worktree, or a file cannot be staged.
+func Add(repo *GitRepository, opts AddOptions) (*AddResult, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	defer trace.Start(trace.Perf, "add")()
+
+	lock, index, err := LockIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	staged := IndexFiles(index)
+	worktree, err := WorktreeFiles(repo, index, NewEOLConverter(repo))
+	if err != nil {
+		return nil, err
+	}
+
+	specs := opts.Paths
+	if len(specs) == 0 {
+		specs = []string{""}
+	}
+	matched := make([]bool, len(specs))
+	matches := func(name string) bool {
+		found := false
+		for i, spec := range specs {
+			if pathspecMatches(name, spec) {
+				matched[i], found = true, true
+			}
+		}
+		return found
+	}
+
+	result := &AddResult{}
+	var paths []string
+	tracked := make(map[string]bool)
+	for _, entry := range index.Entries {
+		if tracked[entry.Name] {
+			continue
+		}
+		tracked[entry.Name] = true
+		if !matches(entry.Name) {
+			continue
+		}
+		if entry.SkipWorktree() && slices.Contains(specs, entry.Name) {
+			return nil, skipWorktreeError(entry.Name)
+		}
+		current, ok := worktree[entry.Name]
+		if entry.Stage() != 0 || (indexModeString(entry.Mode) != ModeGitlink && (!ok || !sameTreeEntry(current, staged[entry.Name]))) {
+			paths = append(paths, entry.Name)
+		}
+	}
+
+	// Below the paths given, ignored files are skipped unless forced.
+	ignore, walkIgnore := NewIgnoreMatcher(repo), (*IgnoreMatcher)(nil)
+	if !opts.Force {
+		walkIgnore = ignore
+	}
+	for i, spec := range specs {
+		info, err := os.Lstat(worktreePath(repo, spec))
+		if err != nil {
+			if !matched[i] {
+				return nil, fmt.Errorf("pathspec '%s' did not match any files", spec)
+			}
+			continue
+		}
+		if opts.Update || tracked[spec] {
+			continue
+		}
+		if spec == "." {
+			spec = ""
+		}
+		if !opts.Force {
+			if ignored := ignoredPath(ignore, spec, info.IsDir()); ignored != "" {
+				result.Ignored = append(result.Ignored, ignored)
+				continue
+			}
+		}
+		if !info.IsDir() {
+			paths = append(paths, spec)
+			continue
+		}
+		dir := spec
+		if dir != "" {
+			dir += "/"
+		}
+		// A nested repository is not walked into; neither is it staged.
+		if dir != "" && pathExists(OSFileSystem{}, worktreePath(repo, dir+GitExtension)) {
+			continue
+		}
+		files, err := filesBelow(repo, walkIgnore, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !tracked[file] {
+				paths = append(paths, file)
+			}
+		}
+	}
+
+	sort.Strings(result.Ignored)
+	sort.Strings(paths)
+	paths = slices.Compact(paths)
+	for _, name := range paths {
+		_, err := os.Lstat(worktreePath(repo, name))
+		if !opts.DryRun {
+			if err := StagePath(repo, index, name, opts.Warnings); err != nil {
+				return nil, err
+			}
+		}
+		result.Changes = append(result.Changes, AddChange{Path: name, Removed: os.IsNotExist(err)})
+	}
+	if opts.DryRun || len(paths) == 0 {
+		return result, nil
+	}
+	return result, lock.Write(index)
+}
+
+// ignoredPath returns the ignored file or directory that name is or lies
+// in, or "" when it is not ignored.
+func ignoredPath(ignore *IgnoreMatcher, name string, isDir bool) string {
+	if name == "" {
+		return ""
+	}
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		last := i == len(parts)-1
+		if prefix := strings.Join(parts[:i+1], "/"); ignore.Ignored(prefix, isDir || !last) {
+			return prefix
+		}
+	}
+	return ""
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	GitAttributesFile = ".gitattributes"
+	// attributesFile holds repository-local attributes that are not shared.
+	attributesFile = "info/attributes"
+)
+
+// States of an attribute besides a value, as check-attr prints them.
+const (
+	AttrSet         = "set"
+	AttrUnset       = "unset"
+	AttrUnspecified = "unspecified"
+)
+
+// builtinMacros are the attribute macros git always knows.
+var builtinMacros = map[string][]attrAssignment{
+	"binary": {{"diff", AttrUnset}, {"merge", AttrUnset}, {"text", AttrUnset}},
+}
+
+// builtinAttributes are the attributes the built-in macros define, in the
+// order git registers them.
+var builtinAttributes = []string{"binary", "diff", "merge", "text"}
+
+// Attribute is an attribute of a path and its value: AttrSet, AttrUnset or
+// a value.
+type Attribute struct {
+	Name  string
+	Value string
+}
+
+// attrAssignment is one "name", "-name", "!name" or "name=value" of a line.
+type attrAssignment struct {
+	name  string
+	value string // AttrSet, AttrUnset, AttrUnspecified or a value.
+}
+
+// attrRule is one line of a .gitattributes file.
+type attrRule struct {
+	pattern     string
+	base        string // Directory of the file the rule came from, "" for the top.
+	anchored    bool   // The pattern contains a slash and matches relative to base.
+	assignments []attrAssignment
+}
+
+// AttributeMatcher looks up the attributes of paths from .gitattributes
+// files and $GIT_DIR/info/attributes. For each attribute the last matching
+// line wins, deeper .gitattributes files override shallower ones, and
+// info/attributes overrides them all.
+type AttributeMatcher struct {
+	repo   *GitRepository
+	index  *Index     // When set, .gitattributes files are read from it.
+	rules  []attrRule // From .gitattributes files, shallowest first.
+	local  []attrRule // From info/attributes.
+	macros map[string][]attrAssignment
+	loaded map[string]bool
+	order  map[string]int // Attribute names in the order they were first seen.
+}
+
+// NewAttributeMatcher creates a matcher loaded with the top-level
+// .gitattributes and info/attributes. Deeper files are loaded on demand.
+func NewAttributeMatcher(repo *GitRepository) *AttributeMatcher {
+	return newAttributeMatcher(repo, nil)
+}
+
+// NewIndexAttributeMatcher creates a matcher that reads .gitattributes
+// files from the index instead of the worktree, as check-attr --cached
+// does. info/attributes still applies.
+func NewIndexAttributeMatcher(repo *GitRepository, index *Index) *AttributeMatcher {
+	return newAttributeMatcher(repo, index)
+}
+
+func newAttributeMatcher(repo *GitRepository, index *Index) *AttributeMatcher {
+	matcher := &AttributeMatcher{
+		repo:   repo,
+		index:  index,
+		macros: make(map[string][]attrAssignment),
+		loaded: make(map[string]bool),
+		order:  make(map[string]int),
+	}
+	for name, assignments := range builtinMacros {
+		matcher.macros[name] = assignments
+	}
+	for _, name := range builtinAttributes {
+		matcher.see(name)
+	}
+	matcher.loadDir("")
+	data, _ := readFile(repo.fs, createRepoPath(repo, filepath.FromSlash(attributesFile)))
+	matcher.local = matcher.parseRules(data, "", true)
+	return matcher
+}
+
+// see records an attribute name the first time it shows up.
+func (m *AttributeMatcher) see(name string) {
+	if _, ok := m.order[name]; !ok {
+		m.order[name] = len(m.order)
+	}
+}
+
+// loadDir reads the .gitattributes of a directory once, from the index or
+// the worktree.
+func (m *AttributeMatcher) loadDir(dir string) {
+	if m.loaded[dir] {
+		return
+	}
+	m.loaded[dir] = true
+
+	var data []byte
+	switch {
+	case m.index != nil:
+		entry := m.index.Entry(path.Join(dir, GitAttributesFile))
+		if entry == nil {
+			return
+		}
+		objType, blob, err := NewObjectManager(m.repo).ReadObject(entry.SHA)
+		if err != nil || objType != BlobType {
+			return
+		}
+		data = blob
+	case m.repo.WorkTree != "":
+		data, _ = os.ReadFile(filepath.Join(m.repo.WorkTree, filepath.FromSlash(dir), GitAttributesFile))
+	}
+	m.rules = append(m.rules, m.parseRules(data, dir, dir == "")...)
+}
+
+// parseRules parses the content of an attributes file whose patterns are
+// relative to base. Macros may only be defined at the top level.
+func (m *AttributeMatcher) parseRules(data []byte, base string, macros bool) []attrRule {
+	var rules []attrRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		var assignments []attrAssignment
+		for _, field := range fields[1:] {
+			switch {
+			case strings.HasPrefix(field, "-"):
+				assignments = append(assignments, attrAssignment{field[1:], AttrUnset})
+			case strings.HasPrefix(field, "!"):
+				assignments = append(assignments, attrAssignment{field[1:], AttrUnspecified})
+			case strings.Contains(field, "="):
+				name, value, _ := strings.Cut(field, "=")
+				assignments = append(assignments, attrAssignment{name, value})
+			default:
+				assignments = append(assignments, attrAssignment{field, AttrSet})
+			}
+		}
+
+		pattern := fields[0]
+		if name, ok := strings.CutPrefix(pattern, "[attr]"); ok {
+			m.see(name)
+		}
+		for _, assignment := range assignments {
+			m.see(assignment.name)
+		}
+		if name, ok := strings.CutPrefix(pattern, "[attr]"); ok {
+			if macros {
+				m.macros[name] = assignments
+			}
+			continue
+		}
+		// Negative patterns are forbidden and directories have no attributes.
+		if strings.HasPrefix(pattern, "!") || strings.HasSuffix(pattern, "/") {
+			continue
+		}
+
+		rule := attrRule{base: base, assignments: assignments}
+		if strings.Contains(pattern, "/") {
+			rule.anchored, pattern = true, strings.TrimPrefix(pattern, "/")
+		}
+		rule.pattern = pattern
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Attributes returns the attributes specified for a worktree path, relative
+// to the top of the worktree and using forward slashes. Attributes that end
+// up unspecified are left out.
+func (m *AttributeMatcher) Attributes(name string) map[string]string {
+	// Parents are loaded before children so deeper rules take precedence.
+	var dirs []string
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		m.loadDir(dirs[i])
+	}
+
+	decided := make(map[string]string)
+	for _, rules := range [][]attrRule{m.local, m.rules} {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].matches(name) {
+				m.assign(decided, rules[i].assignments)
+			}
+		}
+	}
+
+	for attr, value := range decided {
+		if value == AttrUnspecified {
+			delete(decided, attr)
+		}
+	}
+	return decided
+}
+
+// AllAttributes returns the attributes specified for a path, as
+// check-attr --all lists them: in the order their names first appear in
+// the attribute files, which starts with the built-in binary macro.
+func (m *AttributeMatcher) AllAttributes(name string) []Attribute {
+	attrs := m.Attributes(name)
+	all := make([]Attribute, 0, len(attrs))
+	for attr, value := range attrs {
+		all = append(all, Attribute{Name: attr, Value: value})
+	}
+	slices.SortFunc(all, func(a, b Attribute) int {
+		return cmp.Compare(m.order[a.Name], m.order[b.Name])
+	})
+	return all
+}
+
+// Get returns the value of one attribute of a path: AttrSet, AttrUnset,
+// AttrUnspecified or its value.
+func (m *AttributeMatcher) Get(name, attr string) string {
+	if value, ok := m.Attributes(name)[attr]; ok {
+		return value
+	}
+	return AttrUnspecified
+}
+
+// assign records the assignments of a matching line, walking it backwards
+// because later assignments on a line win. Attributes decided by a line of
+// higher precedence are kept. A set macro also assigns its attributes.
+func (m *AttributeMatcher) assign(decided map[string]string, assignments []attrAssignment) {
+	for i := len(assignments) - 1; i >= 0; i-- {
+		assignment := assignments[i]
+		if _, ok := decided[assignment.name]; ok {
+			continue
+		}
+		decided[assignment.name] = assignment.value
+		if expansion, ok := m.macros[assignment.name]; ok && assignment.value == AttrSet {
+			m.assign(decided, expansion)
+		}
+	}
+}
+
+// matches reports whether the rule applies to a path.
+func (r attrRule) matches(name string) bool {
+	rel := name
+	if r.base != "" {
+		var ok bool
+		if rel, ok = strings.CutPrefix(name, r.base+"/"); !ok {
+			return false
+		}
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	return wildmatch(r.pattern, rel, true)
+}
@@ -0,0 +1,390 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// benchObjectLimit bounds how many objects the object benchmarks use, so
+// that a run on a large repository stays short.
+const benchObjectLimit = 10000
+
+// Benchmark times one operation against a repository, for profiling
+// justdoit on repositories of a known size. The hidden bench command runs
+// them against a real repository and the go test benchmarks against
+// generated ones. Prepare does the untimed setup, such as finding the
+// objects to read, and returns the timed part, which reports how many items
+// it handled.
+type Benchmark struct {
+	Name        string
+	Description string
+	Unit        string        // What an item is: object, file, entry or commit.
+	Budget      time.Duration // Most time one item may take on average.
+	Prepare     func(repo *GitRepository) (func() (int, error), error)
+}
+
+// BenchResult is the timing of the runs of one benchmark.
+type BenchResult struct {
+	Name   string
+	Unit   string
+	Budget time.Duration
+	Runs   int
+	Items  int           // Items handled by each run.
+	Total  time.Duration // Time of all runs together.
+}
+
+// PerRun returns the mean time of a run.
+func (r BenchResult) PerRun() time.Duration {
+	return r.Total / time.Duration(max(r.Runs, 1))
+}
+
+// PerItem returns the mean time spent on one item.
+func (r BenchResult) PerItem() time.Duration {
+	return r.PerRun() / time.Duration(max(r.Items, 1))
+}
+
+// OverBudget reports whether an item took longer than the budget of the
+// benchmark.
+func (r BenchResult) OverBudget() bool {
+	return r.PerItem() > r.Budget
+}
+
+// The budgets are about ten times the times measured on the go test
+// fixtures, which leaves room for slower machines and for the fixed costs
+// that weigh more on small repositories, while work that grows
+// quadratically with the repository still exceeds them.
+var benchmarks = []Benchmark{
+	{
+		Name:        "object-read",
+		Description: "Read objects reachable from HEAD",
+		Unit:        "object",
+		Budget:      250 * time.Microsecond,
+		Prepare:     prepareObjectRead,
+	},
+	{
+		Name:        "object-write",
+		Description: "Write blobs reachable from HEAD into a discarded quarantine",
+		Unit:        "object",
+		Budget:      5 * time.Millisecond,
+		Prepare:     prepareObjectWrite,
+	},
+	{
+		Name:        "tree-serialize",
+		Description: "Parse and serialize the trees of HEAD",
+		Unit:        "entry",
+		Budget:      5 * time.Microsecond,
+		Prepare:     prepareTreeSerialize,
+	},
+	{
+		Name:        "tree-flatten",
+		Description: "List the files of the tree of HEAD",
+		Unit:        "file",
+		Budget:      20 * time.Microsecond,
+		Prepare:     prepareTreeFlatten,
+	},
+	{
+		Name:        "tree-write",
+		Description: "Build the tree of HEAD again from its files",
+		Unit:        "file",
+		Budget:      20 * time.Microsecond,
+		Prepare:     prepareTreeWrite,
+	},
+	{
+		Name:        "index-load",
+		Description: "Read and parse the index",
+		Unit:        "entry",
+		Budget:      5 * time.Microsecond,
+		Prepare:     prepareIndexLoad,
+	},
+	{
+		Name:        "index-write",
+		Description: "Lock the index and write it back",
+		Unit:        "entry",
+		Budget:      20 * time.Microsecond,
+		Prepare:     prepareIndexWrite,
+	},
+	{
+		Name:        "status",
+		Description: "Compare HEAD, the index and the worktree",
+		Unit:        "file",
+		Budget:      200 * time.Microsecond,
+		Prepare:     prepareStatus,
+	},
+	{
+		Name:        "rev-list",
+		Description: "List the history of HEAD in date order",
+		Unit:        "commit",
+		Budget:      300 * time.Microsecond,
+		Prepare:     prepareRevList,
+	},
+	{
+		Name:        "log",
+		Description: "Walk the history of HEAD in date order and read each commit",
+		Unit:        "commit",
+		Budget:      600 * time.Microsecond,
+		Prepare:     prepareLog,
+	},
+}
+
+// Benchmarks returns every known benchmark.
+func Benchmarks() []Benchmark {
+	return benchmarks
+}
+
+// FindBenchmark looks up a benchmark by name.
+func FindBenchmark(name string) (Benchmark, error) {
+	for _, bench := range benchmarks {
+		if bench.Name == name {
+			return bench, nil
+		}
+	}
+	return Benchmark{}, fmt.Errorf("'%s' is not a valid benchmark", name)
+}
+
+// RunBenchmark prepares a benchmark and times runs of it.
+//
+// Parameters:
+// - repo: The repository to run against.
+// - bench: The benchmark.
+// - runs: How many times to run it; at least one.
+//
+// Returns:
+// - The timing of the runs.
+// - An error if the benchmark cannot be prepared or a run fails.
+func RunBenchmark(repo *GitRepository, bench Benchmark, runs int) (BenchResult, error) {
+	defer trace.Start(trace.Perf, "benchmark", "name", bench.Name, "runs", runs)()
+
+	run, err := bench.Prepare(repo)
+	if err != nil {
+		return BenchResult{}, fmt.Errorf("benchmark '%s': %w", bench.Name, err)
+	}
+
+	result := BenchResult{Name: bench.Name, Unit: bench.Unit, Budget: bench.Budget, Runs: max(runs, 1)}
+	for range result.Runs {
+		start := time.Now()
+		items, err := run()
+		result.Total += time.Since(start)
+		if err != nil {
+			return BenchResult{}, fmt.Errorf("benchmark '%s': %w", bench.Name, err)
+		}
+		result.Items = items
+	}
+	return result, nil
+}
+
+// benchObjects returns up to benchObjectLimit objects reachable from HEAD,
+// in a stable order, and when objType is not empty only those of that type.
+func benchObjects(repo *GitRepository, objects *ObjectManager, objType GitObjectType) ([]string, error) {
+	head, err := ResolveRevision(repo, HeadFile)
+	if err != nil {
+		return nil, err
+	}
+	reachable, err := collectObjects(objects, []string{head}, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	shas := make([]string, 0, len(reachable))
+	for sha := range reachable {
+		shas = append(shas, sha)
+	}
+	sort.Strings(shas)
+
+	var picked []string
+	for _, sha := range shas {
+		if len(picked) == benchObjectLimit {
+			break
+		}
+		if objType != "" {
+			if t, _, err := objects.StatObject(sha); err != nil || t != objType {
+				continue
+			}
+		}
+		picked = append(picked, sha)
+	}
+	return picked, nil
+}
+
+func prepareObjectRead(repo *GitRepository) (func() (int, error), error) {
+	shas, err := benchObjects(repo, NewObjectManager(repo), "")
+	if err != nil {
+		return nil, err
+	}
+	return func() (int, error) {
+		// A fresh manager each run, so pack indexes are loaded every time.
+		objects := NewObjectManager(repo)
+		for _, sha := range shas {
+			if _, _, err := objects.ReadObject(sha); err != nil {
+				return 0, err
+			}
+		}
+		return len(shas), nil
+	}, nil
+}
+
+func prepareObjectWrite(repo *GitRepository) (func() (int, error), error) {
+	objects := NewObjectManager(repo)
+	shas, err := benchObjects(repo, objects, BlobType)
+	if err != nil {
+		return nil, err
+	}
+	blobs := make([][]byte, len(shas))
+	for i, sha := range shas {
+		_, data, err := objects.ReadObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		// Existing objects are not written again, so write altered copies.
+		blobs[i] = append(data, "\nbench\n"...)
+	}
+
+	return func() (int, error) {
+		quarantine, err := NewQuarantine(repo)
+		if err != nil {
+			return 0, err
+		}
+		defer quarantine.Discard()
+
+		for _, blob := range blobs {
+			if _, err := quarantine.Objects.WriteObject(BlobType, blob, true); err != nil {
+				return 0, err
+			}
+		}
+		return len(blobs), nil
+	}, nil
+}
+
+func prepareTreeSerialize(repo *GitRepository) (func() (int, error), error) {
+	objects := NewObjectManager(repo)
+	shas, err := benchObjects(repo, objects, TreeType)
+	if err != nil {
+		return nil, err
+	}
+	trees := make([][]byte, len(shas))
+	for i, sha := range shas {
+		if _, trees[i], err = objects.ReadObject(sha); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() (int, error) {
+		// Trees vary in size, so count their entries.
+		count := 0
+		for _, data := range trees {
+			entries, err := parseTree(data)
+			if err != nil {
+				return 0, err
+			}
+			serializeTree(entries)
+			count += len(entries)
+		}
+		return count, nil
+	}, nil
+}
+
+func prepareTreeFlatten(repo *GitRepository) (func() (int, error), error) {
+	tree, err := ResolveTree(repo, HeadFile)
+	if err != nil {
+		return nil, err
+	}
+	return func() (int, error) {
+		files, err := NewObjectManager(repo).FlattenTree(tree)
+		return len(files), err
+	}, nil
+}
+
+func prepareTreeWrite(repo *GitRepository) (func() (int, error), error) {
+	tree, err := ResolveTree(repo, HeadFile)
+	if err != nil {
+		return nil, err
+	}
+	files, err := NewObjectManager(repo).FlattenTree(tree)
+	if err != nil {
+		return nil, err
+	}
+	return func() (int, error) {
+		sha, err := NewObjectManager(repo).WriteTreeFromFiles(files)
+		if err != nil {
+			return 0, err
+		}
+		if sha != tree {
+			return 0, fmt.Errorf("wrote tree %s instead of %s", sha, tree)
+		}
+		return len(files), nil
+	}, nil
+}
+
+func prepareIndexLoad(repo *GitRepository) (func() (int, error), error) {
+	return func() (int, error) {
+		index, err := ReadIndex(repo)
+		if err != nil {
+			return 0, err
+		}
+		return len(index.Entries), nil
+	}, nil
+}
+
+func prepareIndexWrite(repo *GitRepository) (func() (int, error), error) {
+	return func() (int, error) {
+		lock, index, err := LockIndex(repo)
+		if err != nil {
+			return 0, err
+		}
+		defer lock.Release()
+		if err := lock.Write(index); err != nil {
+			return 0, err
+		}
+		return len(index.Entries), nil
+	}, nil
+}
+
+func prepareStatus(repo *GitRepository) (func() (int, error), error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	// Status does work in proportion to the tracked files.
+	index, err := ReadIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	return func() (int, error) {
+		if _, err := Status(repo); err != nil {
+			return 0, err
+		}
+		return len(index.Entries), nil
+	}, nil
+}
+
+func prepareRevList(repo *GitRepository) (func() (int, error), error) {
+	head, err := ResolveRevision(repo, HeadFile)
+	if err != nil {
+		return nil, err
+	}
+	return func() (int, error) {
+		commits, err := NewObjectManager(repo).RevList([]string{head}, nil, RevListOptions{})
+		return len(commits), err
+	}, nil
+}
+
+func prepareLog(repo *GitRepository) (func() (int, error), error) {
+	head, err := ResolveRevision(repo, HeadFile)
+	if err != nil {
+		return nil, err
+	}
+	return func() (int, error) {
+		objects := NewObjectManager(repo)
+		commits, err := objects.RevList([]string{head}, nil, RevListOptions{})
+		if err != nil {
+			return 0, err
+		}
+		for _, sha := range commits {
+			if _, err := objects.ReadCommit(sha); err != nil {
+				return 0, err
+			}
+		}
+		return len(commits), nil
+	}, nil
+}
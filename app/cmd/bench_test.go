@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The benchmarks run the Benchmarks of bench.go, which the hidden bench
+// command runs against real repositories, on generated repositories of a
+// known size: a tree of benchFiles files, committed and checked out with its
+// index, and a linear history of benchCommits commits. Run them with
+//
+//	go test -run '^$' -bench . ./app/cmd
+//
+// and add -cpuprofile or -memprofile to profile one of them. A benchmark
+// fails when an item takes longer than its Budget. Each fixture is built
+// once per run, outside the timed part, and removed by TestMain.
+const (
+	benchFiles       = 100_000
+	benchFilesPerDir = 1000
+	benchCommits     = 100_000
+)
+
+var (
+	fixtureMu   sync.Mutex
+	fixtureRoot string
+	fixtures    = make(map[string]*GitRepository)
+)
+
+// benchFixture returns the repository build fills for name, building it the
+// first time it is asked for.
+func benchFixture(b *testing.B, name string, build func(testing.TB, *GitRepository)) *GitRepository {
+	b.Helper()
+	isolateConfig(b)
+
+	fixtureMu.Lock()
+	defer fixtureMu.Unlock()
+	if repo, ok := fixtures[name]; ok {
+		return repo
+	}
+
+	if fixtureRoot == "" {
+		dir, err := os.MkdirTemp("", "justdoit-bench-")
+		if err != nil {
+			b.Fatal(err)
+		}
+		fixtureRoot = dir
+	}
+	repo, _, err := CreateGitRepository(filepath.Join(fixtureRoot, name), InitOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	build(b, repo)
+	fixtures[name] = repo
+	return repo
+}
+
+// fixtureObject is an object of a fixture, before it is packed.
+type fixtureObject struct {
+	objType GitObjectType
+	data    []byte
+}
+
+// fixtureCommit builds the n-th commit of a fixture, one second after the
+// previous one.
+func fixtureCommit(tree, parent string, n int) fixtureObject {
+	kvlm := &Kvlm{Message: []byte(fmt.Sprintf("commit %d\n", n))}
+	kvlm.Add("tree", []byte(tree))
+	if parent != "" {
+		kvlm.Add("parent", []byte(parent))
+	}
+	signature := fmt.Sprintf("Bench <bench@example.com> %d +0000", 1700000000+n)
+	kvlm.Add("author", []byte(signature))
+	kvlm.Add("committer", []byte(signature))
+	return fixtureObject{objType: CommitType, data: kvlm.Serialize()}
+}
+
+// writeFixturePack stores objects in a single indexed pack, as a clone
+// leaves them, which is much faster than writing them loose.
+func writeFixturePack(tb testing.TB, repo *GitRepository, objects []fixtureObject) {
+	tb.Helper()
+	var pack bytes.Buffer
+	pack.WriteString(packSignature)
+	_ = binary.Write(&pack, binary.BigEndian, uint32(packVersion))
+	_ = binary.Write(&pack, binary.BigEndian, uint32(len(objects)))
+	for _, object := range objects {
+		pack.Write(encodePackEntryHeader(packTypeCodes[object.objType], len(object.data)))
+		writer, _ := zlib.NewWriterLevel(&pack, zlib.BestSpeed)
+		_, _ = writer.Write(object.data)
+		_ = writer.Close()
+	}
+	sum := sha1.Sum(pack.Bytes())
+	pack.Write(sum[:])
+
+	if _, _, err := IndexPack(repo, pack.Bytes(), UnpackOptions{}); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// setFixtureHead points the current branch at commit.
+func setFixtureHead(tb testing.TB, repo *GitRepository, commit string) {
+	tb.Helper()
+	branch, ok := ReadSymbolicRef(repo, HeadFile)
+	if !ok {
+		tb.Fatal("HEAD of a new repository is detached")
+	}
+	if err := UpdateRef(repo, branch, commit, ""); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// buildWideTree commits benchFiles small files, benchFilesPerDir to a
+// directory, and checks them out with their index.
+func buildWideTree(tb testing.TB, repo *GitRepository) {
+	var objects []fixtureObject
+	var root []TreeEntry
+	for dir := range benchFiles / benchFilesPerDir {
+		var entries []TreeEntry
+		for file := range benchFilesPerDir {
+			data := []byte(fmt.Sprintf("file %d of directory %d\n", file, dir))
+			sha, _ := encodeObject(BlobType, data)
+			objects = append(objects, fixtureObject{objType: BlobType, data: data})
+			entries = append(entries, TreeEntry{Mode: ModeBlob, Name: fmt.Sprintf("file%04d.txt", file), SHA: sha})
+		}
+		data := serializeTree(entries)
+		sha, _ := encodeObject(TreeType, data)
+		objects = append(objects, fixtureObject{objType: TreeType, data: data})
+		root = append(root, TreeEntry{Mode: ModeTree, Name: fmt.Sprintf("dir%03d", dir), SHA: sha})
+	}
+	data := serializeTree(root)
+	tree, _ := encodeObject(TreeType, data)
+	commit := fixtureCommit(tree, "", 0)
+	sha, _ := encodeObject(CommitType, commit.data)
+	objects = append(objects, fixtureObject{objType: TreeType, data: data}, commit)
+
+	writeFixturePack(tb, repo, objects)
+	setFixtureHead(tb, repo, sha)
+	if err := CheckoutTree(repo, tree); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// buildLongHistory commits benchCommits times on top of each other, every
+// commit with the same one-file tree.
+func buildLongHistory(tb testing.TB, repo *GitRepository) {
+	blob := []byte("history\n")
+	blobSHA, _ := encodeObject(BlobType, blob)
+	tree := serializeTree([]TreeEntry{{Mode: ModeBlob, Name: "file.txt", SHA: blobSHA}})
+	treeSHA, _ := encodeObject(TreeType, tree)
+
+	objects := []fixtureObject{{objType: BlobType, data: blob}, {objType: TreeType, data: tree}}
+	parent := ""
+	for n := range benchCommits {
+		commit := fixtureCommit(treeSHA, parent, n)
+		parent, _ = encodeObject(CommitType, commit.data)
+		objects = append(objects, commit)
+	}
+
+	writeFixturePack(tb, repo, objects)
+	setFixtureHead(tb, repo, parent)
+}
+
+// runBenchmark runs the benchmark called name on the fixture build fills,
+// reports the mean time of an item and fails when it is over the budget.
+func runBenchmark(b *testing.B, name, fixture string, build func(testing.TB, *GitRepository)) {
+	bench, err := FindBenchmark(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	repo := benchFixture(b, fixture, build)
+	run, err := bench.Prepare(repo)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	items := 0
+	for range b.N {
+		if items, err = run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if items == 0 {
+		b.Fatal("the benchmark handled no items")
+	}
+	reportPerItem(b, bench, items)
+}
+
+// reportPerItem reports the mean time spent on one of the items each run
+// handles, such as index entries or commits, and fails the benchmark when
+// it is over the budget.
+func reportPerItem(b *testing.B, bench Benchmark, items int) {
+	perItem := time.Duration(b.Elapsed().Nanoseconds() / int64(b.N) / int64(items))
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(items), "ns/"+bench.Unit)
+	if perItem > bench.Budget {
+		b.Errorf("%s took %s per %s, over its budget of %s", bench.Name, perItem, bench.Unit, bench.Budget)
+	}
+}
+
+func BenchmarkReadObject(b *testing.B) {
+	runBenchmark(b, "object-read", "tree", buildWideTree)
+}
+
+func BenchmarkWriteObject(b *testing.B) {
+	runBenchmark(b, "object-write", "tree", buildWideTree)
+}
+
+func BenchmarkSerializeTree(b *testing.B) {
+	runBenchmark(b, "tree-serialize", "tree", buildWideTree)
+}
+
+func BenchmarkFlattenTree(b *testing.B) {
+	runBenchmark(b, "tree-flatten", "tree", buildWideTree)
+}
+
+func BenchmarkWriteTreeFromFiles(b *testing.B) {
+	runBenchmark(b, "tree-write", "tree", buildWideTree)
+}
+
+func BenchmarkReadIndex(b *testing.B) {
+	runBenchmark(b, "index-load", "tree", buildWideTree)
+}
+
+func BenchmarkWriteIndex(b *testing.B) {
+	runBenchmark(b, "index-write", "tree", buildWideTree)
+}
+
+func BenchmarkStatus(b *testing.B) {
+	runBenchmark(b, "status", "tree", buildWideTree)
+}
+
+func BenchmarkRevList(b *testing.B) {
+	runBenchmark(b, "rev-list", "history", buildLongHistory)
+}
+
+func BenchmarkLog(b *testing.B) {
+	runBenchmark(b, "log", "history", buildLongHistory)
+}
@@ -0,0 +1,418 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// defaultBigFileThreshold is git's default for core.bigFileThreshold.
+const defaultBigFileThreshold = 512 << 20
+
+// BigFileThreshold returns core.bigFileThreshold in bytes. Blobs larger
+// than this are streamed instead of being loaded whole, are never delta
+// compressed and are shown as binary by diff.
+func BigFileThreshold(repo *GitRepository) int64 {
+	value := repo.Config().GetString("core.bigFileThreshold")
+	if value == "" {
+		return defaultBigFileThreshold
+	}
+	size, err := parseConfigSize(value)
+	if err != nil || size <= 0 {
+		return defaultBigFileThreshold
+	}
+	return size
+}
+
+// parseConfigSize parses a size with an optional k, m or g suffix, as git
+// config does.
+func parseConfigSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	multiplier := int64(1)
+	if value != "" {
+		switch strings.ToLower(value[len(value)-1:]) {
+		case "k":
+			multiplier = 1 << 10
+		case "m":
+			multiplier = 1 << 20
+		case "g":
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	return size * multiplier, nil
+}
+
+// StatObject returns the type and size of an object without loading its
+// content: only the header of a loose object, or of a pack entry and its
+// delta, is inflated. Replace refs are honored like in ReadObject.
+//
+// Parameters:
+// - sha: The hex encoded SHA-1 of the object.
+//
+// Returns:
+// - The type of the object.
+// - The size of its content in bytes.
+// - An error if the object does not exist or its header is corrupt.
+func (m *ObjectManager) StatObject(sha string) (GitObjectType, int64, error) {
+	return m.statObject(m.replaced(sha))
+}
+
+// statObject returns the type and size of the object stored under sha,
+// ignoring replace refs.
+func (m *ObjectManager) statObject(sha string) (GitObjectType, int64, error) {
+	if !isValidSHA(sha) {
+		return "", 0, fmt.Errorf("invalid object name '%s'", sha)
+	}
+
+	if path, ok := m.findLoose(sha); ok {
+		file, err := m.repo.fs.Open(path)
+		if err != nil {
+			return "", 0, err
+		}
+		defer file.Close()
+
+		objType, size, _, err := openLooseObject(file, m.unknownTypes)
+		if err != nil {
+			return "", 0, fmt.Errorf("object %s: %w", sha, err)
+		}
+		return objType, size, nil
+	}
+
+	packs, err := m.packFiles()
+	if err != nil {
+		return "", 0, err
+	}
+	for _, pack := range packs {
+		if offset, ok := pack.index.find(sha); ok {
+			objType, size, err := pack.statAt(m, offset)
+			if err != nil {
+				return "", 0, fmt.Errorf("object %s: %w", sha, err)
+			}
+			return objType, size, nil
+		}
+	}
+
+	if err := m.fetchPromised(sha); err != nil {
+		return "", 0, err
+	}
+	return m.statObject(sha)
+}
+
+// openLooseObject starts inflating a loose object and reads its header.
+//
+// Returns:
+// - The type and size from the header.
+// - A reader positioned at the start of the content.
+// - An error if the object is empty or its header is malformed.
+func openLooseObject(r io.Reader, allowUnknown bool) (GitObjectType, int64, *bufio.Reader, error) {
+	// An interrupted write can leave an empty file behind; zlib would only
+	// report an unexpected end.
+	compressed := bufio.NewReader(r)
+	if _, err := compressed.Peek(1); errors.Is(err, io.EOF) {
+		return "", 0, nil, fmt.Errorf("empty loose object")
+	}
+	inflater, err := zlib.NewReader(compressed)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("corrupt loose object: %w", err)
+	}
+	reader := bufio.NewReader(inflater)
+
+	header, err := reader.ReadString(0)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("malformed object header")
+	}
+	typeName, sizeText, ok := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	if !ok {
+		return "", 0, nil, fmt.Errorf("malformed object header")
+	}
+	objType, err := parseLooseObjectType(typeName, allowUnknown)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	size, err := strconv.ParseInt(sizeText, 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, nil, fmt.Errorf("malformed object size '%s'", sizeText)
+	}
+	return objType, size, reader, nil
+}
+
+// statAt returns the type and size of the pack entry at offset. The size of
+// a delta is read from the start of the delta itself; its type comes from
+// the base.
+func (p *packFile) statAt(m *ObjectManager, offset uint64) (GitObjectType, int64, error) {
+	file, err := p.fs.Open(p.path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	typeCode, size, headerLen, err := readPackEntryHeader(file, offset)
+	if err != nil {
+		return "", 0, err
+	}
+	pos := offset + uint64(headerLen)
+
+	var baseSHA string
+	switch typeCode {
+	case packCommit, packTree, packBlob, packTag:
+		return packTypeNames[typeCode], int64(size), nil
+	case packOfsDelta:
+		distance, n, err := readOffsetDelta(file, pos)
+		if err != nil {
+			return "", 0, err
+		}
+		if distance > offset {
+			return "", 0, fmt.Errorf("delta base offset out of range at %d", offset)
+		}
+		pos += uint64(n)
+		offset -= distance
+	case packRefDelta:
+		raw := make([]byte, 20)
+		if _, err := file.ReadAt(raw, int64(pos)); err != nil {
+			return "", 0, err
+		}
+		baseSHA = hex.EncodeToString(raw)
+		pos += 20
+	default:
+		return "", 0, fmt.Errorf("unknown pack entry type %d at offset %d", typeCode, offset)
+	}
+
+	// Both sizes fit in the first 20 bytes of the delta.
+	start, err := inflatePrefix(file, pos, 20)
+	if err != nil {
+		return "", 0, err
+	}
+	_, n := readDeltaSize(start)
+	targetSize, _ := readDeltaSize(start[n:])
+
+	var baseType GitObjectType
+	if baseSHA != "" {
+		baseType, _, err = m.statObject(baseSHA)
+	} else {
+		baseType, _, err = p.statAt(m, offset)
+	}
+	return baseType, int64(targetSize), err
+}
+
+// inflatePrefix decompresses at most n bytes of the zlib stream at pos.
+func inflatePrefix(r io.ReaderAt, pos uint64, n int) ([]byte, error) {
+	reader, err := zlib.NewReader(io.NewSectionReader(r, int64(pos), 1<<62))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt zlib stream at %d: %w", pos, err)
+	}
+	defer reader.Close()
+
+	buf := make([]byte, n)
+	read, err := io.ReadFull(reader, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("corrupt zlib stream at %d: %w", pos, err)
+	}
+	return buf[:read], nil
+}
+
+// StreamObject copies the content of an object to w. Loose objects and
+// undeltified pack entries are inflated as they are copied, so large blobs
+// are never held in memory; deltas are resolved in memory as usual.
+//
+// Parameters:
+// - sha: The hex encoded SHA-1 of the object.
+// - w: Where the content is written.
+//
+// Returns:
+// - The type of the object.
+// - An error if the object cannot be read or w fails.
+func (m *ObjectManager) StreamObject(sha string, w io.Writer) (GitObjectType, error) {
+	sha = m.replaced(sha)
+	if !isValidSHA(sha) {
+		return "", fmt.Errorf("invalid object name '%s'", sha)
+	}
+
+	if path, ok := m.findLoose(sha); ok {
+		file, err := m.repo.fs.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		objType, size, reader, err := openLooseObject(file, m.unknownTypes)
+		if err != nil {
+			return "", fmt.Errorf("object %s: %w", sha, err)
+		}
+		if _, err := io.CopyN(w, reader, size); err != nil {
+			return "", fmt.Errorf("object %s: %w", sha, err)
+		}
+		return objType, nil
+	}
+
+	packs, err := m.packFiles()
+	if err != nil {
+		return "", err
+	}
+	for _, pack := range packs {
+		offset, ok := pack.index.find(sha)
+		if !ok {
+			continue
+		}
+		file, err := pack.fs.Open(pack.path)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		typeCode, size, headerLen, err := readPackEntryHeader(file, offset)
+		if err != nil {
+			return "", fmt.Errorf("object %s: %w", sha, err)
+		}
+		if objType, whole := packTypeNames[typeCode]; whole {
+			reader, err := zlib.NewReader(io.NewSectionReader(file, int64(offset)+int64(headerLen), 1<<62))
+			if err != nil {
+				return "", fmt.Errorf("object %s: %w", sha, err)
+			}
+			defer reader.Close()
+			if _, err := io.CopyN(w, reader, int64(size)); err != nil {
+				return "", fmt.Errorf("object %s: %w", sha, err)
+			}
+			return objType, nil
+		}
+		break
+	}
+
+	objType, data, err := m.readObject(sha)
+	if err != nil {
+		return "", err
+	}
+	_, err = w.Write(data)
+	return objType, err
+}
+
+// WriteBlobStream hashes a blob read from r and, if changeRepo is set,
+// stores it as a loose object, compressing it as it is read so that its
+// content is never held in memory.
+//
+// Parameters:
+// - r: The blob content.
+// - size: The exact number of bytes r yields.
+// - changeRepo: Whether the object should actually be written to the repository.
+//
+// Returns:
+// - The hex encoded SHA-1 of the blob.
+// - An error if r fails, yields a different size or the object cannot be written.
+func (m *ObjectManager) WriteBlobStream(r io.Reader, size int64, changeRepo bool) (string, error) {
+	hasher := sha1.New()
+	header := fmt.Sprintf("%s %d\x00", BlobType, size)
+
+	if !changeRepo {
+		hasher.Write([]byte(header))
+		if n, err := io.Copy(hasher, r); err != nil || n != size {
+			return "", streamSizeError(n, size, err)
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+
+	fsys := m.repo.fs
+	policy := m.repo.fsyncPolicyOf(fsyncLooseObject)
+	tmp, err := fsys.CreateTemp(m.objectDir(), "tmp_obj_")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer fsys.Remove(tmpPath)
+
+	compressor := zlib.NewWriter(tmp)
+	writer := io.MultiWriter(hasher, compressor)
+	io.WriteString(writer, header)
+	n, err := io.Copy(writer, r)
+	if err == nil && n == size {
+		err = compressor.Close()
+	}
+	if err == nil && n == size && policy == fsyncNow {
+		err = syncFile(tmp)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || n != size {
+		return "", streamSizeError(n, size, err)
+	}
+
+	sha := hex.EncodeToString(hasher.Sum(nil))
+	if m.Has(sha) {
+		return sha, nil
+	}
+	path := filepath.Join(m.objectDir(), sha[:2], sha[2:])
+	if err := fsys.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := fsys.Chmod(tmpPath, 0444); err != nil {
+		return "", err
+	}
+	if err := fsys.Rename(tmpPath, path); err != nil {
+		return "", err
+	}
+	if err := m.repo.syncWritten(policy, path); err != nil {
+		return "", err
+	}
+	if err := adjustSharedPerm(m.repo, filepath.Dir(path), path); err != nil {
+		return "", err
+	}
+	m.repo.looseWritten.Add(1)
+
+	trace.Log(trace.Object, "write", "sha", sha, "type", BlobType, "size", size, "streamed", true)
+	return sha, nil
+}
+
+// streamSizeError reports a failed or short streamed read.
+func streamSizeError(read, size int64, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("expected %d bytes but read %d", size, read)
+}
+
+// isBigBlob reports whether the blob behind entry exceeds threshold, without
+// reading it. Content only present in the worktree is measured there.
+func (m *ObjectManager) isBigBlob(path string, entry TreeEntry, worktree string, threshold int64) bool {
+	if entry.SHA == "" || entry.IsGitlink() || entry.Mode == ModeSymlink {
+		return false
+	}
+	if worktree != "" && !m.Has(entry.SHA) {
+		info, err := os.Stat(filepath.Join(worktree, filepath.FromSlash(path)))
+		return err == nil && info.Size() > threshold
+	}
+	_, size, err := m.StatObject(entry.SHA)
+	return err == nil && size > threshold
+}
+
+// bigBlobSize returns the size of a side of a change for the binary stat of
+// a big file, or 0 when it cannot be determined.
+func (m *ObjectManager) bigBlobSize(path string, entry TreeEntry, worktree string) int {
+	if entry.SHA == "" {
+		return 0
+	}
+	if worktree != "" && !m.Has(entry.SHA) {
+		if info, err := os.Stat(filepath.Join(worktree, filepath.FromSlash(path))); err == nil {
+			return int(info.Size())
+		}
+		return 0
+	}
+	_, size, _ := m.StatObject(entry.SHA)
+	return int(size)
+}
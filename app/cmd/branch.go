@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// BranchesPrefix is the namespace of local branches.
+const BranchesPrefix = "refs/heads/"
+
+// TrackMode says whether a new branch gets upstream configuration.
+type TrackMode int
+
+const (
+	TrackAuto   TrackMode = iota // Follow branch.autoSetupMerge.
+	TrackAlways                  // --track: always record the start point as upstream.
+	TrackNever                   // --no-track: never record an upstream.
+)
+
+// Upstream is the branch a local branch tracks, from branch.<name>.remote
+// and branch.<name>.merge.
+type Upstream struct {
+	Remote string // The remote name, or "." for a local branch.
+	Merge  string // The branch on the remote, e.g. refs/heads/main.
+	Ref    string // The local ref following it, e.g. refs/remotes/origin/main.
+}
+
+// ShortName returns the upstream as git prints it, e.g. "origin/main".
+func (u *Upstream) ShortName() string {
+	return strings.TrimPrefix(strings.TrimPrefix(u.Ref, "refs/remotes/"), BranchesPrefix)
+}
+
+// RemoteNames lists the remotes configured in the repository, sorted.
+func RemoteNames(repo *GitRepository) []string {
+	seen := make(map[string]bool)
+	for _, key := range repo.Config().AllKeys() {
+		rest, ok := strings.CutPrefix(key, `remote "`)
+		if !ok {
+			continue
+		}
+		if name, _, ok := strings.Cut(rest, `"`); ok {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BranchUpstream returns the upstream configured for a branch. ok is false
+// when the branch does not track anything.
+func BranchUpstream(repo *GitRepository, branch string) (*Upstream, bool) {
+	remote := repo.Config().GetString(configKey("branch", branch, "remote"))
+	merge := repo.Config().GetString(configKey("branch", branch, "merge"))
+	if remote == "" || merge == "" {
+		return nil, false
+	}
+
+	upstream := &Upstream{Remote: remote, Merge: merge, Ref: merge}
+	if remote == "." {
+		return upstream, true
+	}
+
+	refspecs, err := RemoteFetchRefspecs(repo, remote)
+	if err != nil {
+		return nil, false
+	}
+	for _, refspec := range refspecs {
+		if refspec.Negative {
+			continue
+		}
+		if dst, ok := refspec.MatchSource(merge); ok && dst != "" {
+			upstream.Ref = dst
+			return upstream, true
+		}
+	}
+	return nil, false
+}
+
+// SetBranchUpstream makes branch track upstreamRef, which is either a
+// remote-tracking ref such as refs/remotes/origin/main or a local branch.
+//
+// Parameters:
+// - repo: The repository.
+// - branch: The short name of the local branch.
+// - upstreamRef: The full name of the ref to track.
+//
+// Returns:
+// - The upstream that was recorded.
+// - An error if no remote fetches into upstreamRef or the config cannot be written.
+func SetBranchUpstream(repo *GitRepository, branch, upstreamRef string) (*Upstream, error) {
+	upstream := &Upstream{Ref: upstreamRef}
+
+	if strings.HasPrefix(upstreamRef, BranchesPrefix) {
+		upstream.Remote, upstream.Merge = ".", upstreamRef
+	} else {
+		for _, remote := range RemoteNames(repo) {
+			refspecs, err := RemoteFetchRefspecs(repo, remote)
+			if err != nil {
+				return nil, err
+			}
+			for _, refspec := range refspecs {
+				if src, ok := refspec.MatchDestination(upstreamRef); ok && !refspec.Negative {
+					upstream.Remote, upstream.Merge = remote, src
+					break
+				}
+			}
+			if upstream.Remote != "" {
+				break
+			}
+		}
+	}
+
+	if upstream.Remote == "" {
+		return nil, fmt.Errorf("cannot set up tracking information; '%s' is not a branch or remote-tracking branch", upstreamRef)
+	}
+
+	if err := SetConfig(repo, "branch", branch, "remote", upstream.Remote); err != nil {
+		return nil, err
+	}
+	if err := SetConfig(repo, "branch", branch, "merge", upstream.Merge); err != nil {
+		return nil, err
+	}
+	trace.Log(trace.Ref, "set upstream", "branch", branch, "remote", upstream.Remote, "merge", upstream.Merge)
+	return upstream, nil
+}
+
+// UnsetBranchUpstream removes the tracking configuration of a branch.
+func UnsetBranchUpstream(repo *GitRepository, branch string) error {
+	if err := UnsetConfig(repo, "branch", branch, "remote"); err != nil {
+		return err
+	}
+	return UnsetConfig(repo, "branch", branch, "merge")
+}
+
+// CreateBranchOptions controls CreateBranch.
+type CreateBranchOptions struct {
+	Force bool      // Reset the branch if it already exists.
+	Track TrackMode // Whether to record the start point as upstream.
+}
+
+// CreateBranch creates a branch at a start point. When the start point is a
+// remote-tracking branch, or always with TrackAlways, the branch is set up
+// to track it, subject to branch.autoSetupMerge.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The short name of the new branch.
+// - startPoint: The revision the branch starts at.
+// - opts: Overwrite and tracking behaviour.
+//
+// Returns:
+// - The upstream recorded for the branch, or nil.
+// - An error if the name is invalid, the branch exists or the start point is unknown.
+func CreateBranch(repo *GitRepository, name, startPoint string, opts CreateBranchOptions) (*Upstream, error) {
+	ref := BranchesPrefix + name
+	if name == HeadFile || strings.HasPrefix(name, "-") || !validRefName(ref) {
+		return nil, fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	exists := refExists(repo, ref)
+	if exists && !opts.Force {
+		return nil, fmt.Errorf("a branch named '%s' already exists", name)
+	}
+
+	sha, err := ResolveRevision(repo, startPoint)
+	if err != nil {
+		return nil, err
+	}
+	commit, objType, err := NewObjectManager(repo).PeelObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != CommitType {
+		return nil, fmt.Errorf("not a valid branch point: '%s'", startPoint)
+	}
+
+	message := "branch: Created from " + startPoint
+	if exists {
+		message = "branch: Reset to " + startPoint
+	}
+	if err := UpdateRef(repo, ref, commit, message); err != nil {
+		return nil, err
+	}
+
+	startRef, err := ExpandRefName(repo, startPoint)
+	if err != nil || opts.Track == TrackNever || !refExists(repo, startRef) {
+		return nil, nil
+	}
+
+	autoSetup := strings.ToLower(repo.Config().GetString("branch.autosetupmerge"))
+	isRemote := strings.HasPrefix(startRef, "refs/remotes/")
+	isLocal := strings.HasPrefix(startRef, BranchesPrefix)
+	track := false
+	switch opts.Track {
+	case TrackAlways:
+		track = isRemote || isLocal
+	case TrackAuto:
+		track = (isRemote && autoSetup != "false") || (isLocal && autoSetup == "always")
+	}
+	if !track {
+		return nil, nil
+	}
+	return SetBranchUpstream(repo, name, startRef)
+}
+
+// RenameBranch renames a branch together with its reflog and its
+// branch.<name> config, and moves HEAD along if it is the current branch.
+// The current branch may be renamed before its first commit.
+//
+// Parameters:
+// - repo: The repository.
+// - oldName: The short name of the branch to rename.
+// - newName: Its new short name.
+// - force: Overwrite an existing branch named newName.
+//
+// Returns:
+// - An error if a name is invalid, the branch does not exist or newName is taken.
+func RenameBranch(repo *GitRepository, oldName, newName string, force bool) error {
+	return moveBranch(repo, oldName, newName, force, false)
+}
+
+// CopyBranch copies a branch together with its reflog and its
+// branch.<name> config. HEAD stays where it is.
+//
+// Parameters:
+// - repo: The repository.
+// - oldName: The short name of the branch to copy.
+// - newName: The short name of the copy.
+// - force: Overwrite an existing branch named newName.
+//
+// Returns:
+// - An error if a name is invalid, the branch does not exist or newName is taken.
+func CopyBranch(repo *GitRepository, oldName, newName string, force bool) error {
+	return moveBranch(repo, oldName, newName, force, true)
+}
+
+// moveBranch implements RenameBranch and, with keep, CopyBranch.
+func moveBranch(repo *GitRepository, oldName, newName string, force, keep bool) error {
+	oldRef, newRef := BranchesPrefix+oldName, BranchesPrefix+newName
+	if newName == HeadFile || strings.HasPrefix(newName, "-") || !validRefName(newRef) {
+		return fmt.Errorf("'%s' is not a valid branch name", newName)
+	}
+
+	message := fmt.Sprintf("Branch: renamed %s to %s", oldRef, newRef)
+	if keep {
+		message = fmt.Sprintf("Branch: copied %s to %s", oldRef, newRef)
+	}
+	current, _ := CurrentBranch(repo)
+	sha, err := ResolveRef(repo, oldRef)
+	unborn := err != nil && oldName == current
+	if err != nil && !unborn {
+		return fmt.Errorf("no branch named '%s'", oldName)
+	}
+	if oldName == newName && !unborn {
+		if !keep || force {
+			return nil
+		}
+		return fmt.Errorf("a branch named '%s' already exists", newName)
+	}
+	if refExists(repo, newRef) && !force {
+		return fmt.Errorf("a branch named '%s' already exists", newName)
+	}
+	if newName == current {
+		return fmt.Errorf("cannot force update the branch '%s' checked out at '%s'", newName, repo.WorkTree)
+	}
+
+	if !unborn {
+		reflog, err := readFile(repo.fs, createRepoPath(repo, LogsDir, filepath.FromSlash(oldRef)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// The old ref goes first, so that a branch can be renamed to a name
+		// below itself, such as topic to topic/v1.
+		if !keep {
+			if err := DeleteRef(repo, oldRef); err != nil {
+				return err
+			}
+		}
+		if err := DeleteRef(repo, newRef); err != nil {
+			return err
+		}
+		if err := UpdateRef(repo, newRef, sha, ""); err != nil {
+			if !keep {
+				UpdateRef(repo, oldRef, sha, "")
+			}
+			return err
+		}
+		if reflog != nil {
+			if err := writeFileAtomic(repo.fs, createRepoPath(repo, LogsDir, filepath.FromSlash(newRef)), reflog, 0644); err != nil {
+				return err
+			}
+		}
+		if err := logRefUpdate(repo, newRef, sha, sha, message); err != nil {
+			return err
+		}
+	}
+
+	if !keep && oldName == current {
+		if err := UpdateSymbolicRef(repo, HeadFile, newRef, ""); err != nil {
+			return err
+		}
+		// Like git, HEAD logs the rename as the branch going away and
+		// coming back.
+		if !unborn {
+			if err := logRefUpdate(repo, HeadFile, sha, zeroSHA, message); err != nil {
+				return err
+			}
+			if err := logRefUpdate(repo, HeadFile, zeroSHA, sha, message); err != nil {
+				return err
+			}
+		}
+	}
+	trace.Log(trace.Ref, "move branch", "from", oldName, "to", newName, "copy", keep)
+	return renameConfigSection(repo, "branch", oldName, newName, keep)
+}
+
+// TrackingStatus compares a branch with its upstream.
+//
+// Returns:
+// - The upstream, or nil if the branch has none.
+// - The commits the branch has that the upstream lacks, and the reverse.
+// - Whether the upstream is configured but its ref no longer exists.
+// - An error if the history cannot be walked.
+func TrackingStatus(repo *GitRepository, branch string) (upstream *Upstream, ahead, behind int, gone bool, err error) {
+	upstream, ok := BranchUpstream(repo, branch)
+	if !ok {
+		return nil, 0, 0, false, nil
+	}
+
+	upstreamSHA, err := ResolveRef(repo, upstream.Ref)
+	if err != nil {
+		return upstream, 0, 0, true, nil
+	}
+	localSHA, err := ResolveRef(repo, BranchesPrefix+branch)
+	if err != nil {
+		return upstream, 0, 0, false, nil
+	}
+
+	ahead, behind, err = NewObjectManager(repo).UseReplaceRefs().UseGrafts().AheadBehind(localSHA, upstreamSHA)
+	return upstream, ahead, behind, false, err
+}
@@ -0,0 +1,385 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// browseLogPageSize is the number of commits on one page of the log.
+const browseLogPageSize = 50
+
+// browser serves a read-only web view of one repository, like git instaweb.
+type browser struct {
+	repo   *GitRepository
+	logger *log.Logger
+}
+
+// NewBrowseHandler creates the handler of the repository browser:
+//
+//	GET /                      the log of HEAD
+//	GET /log?rev=<rev>&page=N  the log of any revision
+//	GET /commit/<rev>          a commit with its diff against its first parent
+//	GET /tree/<path>?rev=<rev> a directory listing
+//	GET /blob/<path>?rev=<rev> the content of a file
+//
+// Parameters:
+// - repo: The repository to browse.
+// - logger: Where failed requests are logged.
+//
+// Returns:
+// - The HTTP handler.
+func NewBrowseHandler(repo *GitRepository, logger *log.Logger) http.Handler {
+	b := &browser{repo: repo, logger: logger}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", b.serveLog)
+	mux.HandleFunc("GET /log", b.serveLog)
+	mux.HandleFunc("GET /commit/{rev...}", b.serveCommit)
+	mux.HandleFunc("GET /tree/{path...}", b.serveTree)
+	mux.HandleFunc("GET /blob/{path...}", b.serveBlob)
+	return mux
+}
+
+// RunBrowser serves the repository browser on addr until the listener fails.
+func RunBrowser(addr string, repo *GitRepository, logger *log.Logger) error {
+	logger.Printf("Browsing %s on http://%s", repo.GitDir, addr)
+	return http.ListenAndServe(addr, NewBrowseHandler(repo, logger))
+}
+
+// objects returns a fresh object manager, so packs written while the
+// browser runs are seen. History is shown as log shows it, with replace
+// refs and grafts.
+func (b *browser) objects() *ObjectManager {
+	return NewObjectManager(b.repo).UseReplaceRefs().UseGrafts()
+}
+
+// fail reports a request that cannot be answered.
+func (b *browser) fail(w http.ResponseWriter, req *http.Request, status int, err error) {
+	b.logger.Printf("%s %s: %v", req.Method, req.URL, err)
+	http.Error(w, err.Error(), status)
+}
+
+// render writes a page with the shared layout.
+func (b *browser) render(w http.ResponseWriter, req *http.Request, page string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := browseTemplates.ExecuteTemplate(w, page, data); err != nil {
+		b.logger.Printf("%s %s: %v", req.Method, req.URL, err)
+	}
+}
+
+// revision resolves the rev query parameter, defaulting to HEAD, to a commit.
+func (b *browser) revision(objects *ObjectManager, rev string) (string, *Commit, error) {
+	if rev == "" {
+		rev = HeadFile
+	}
+	sha, err := ResolveRevision(b.repo, rev)
+	if err != nil {
+		return "", nil, err
+	}
+	if sha, err = objects.PeelToType(sha, CommitType); err != nil {
+		return "", nil, err
+	}
+	commit, err := objects.ReadCommit(sha)
+	return sha, commit, err
+}
+
+// browseCommit is a commit as the log and commit pages show it.
+type browseCommit struct {
+	SHA     string
+	Short   string
+	Parents []string
+	Author  Signature
+	Subject string
+	Message string
+}
+
+func newBrowseCommit(sha string, commit *Commit) browseCommit {
+	message := string(commit.Message)
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return browseCommit{
+		SHA:     sha,
+		Short:   sha[:7],
+		Parents: commit.Parents,
+		Author:  ParseSignature(commit.Author),
+		Subject: subject,
+		Message: message,
+	}
+}
+
+func (b *browser) serveLog(w http.ResponseWriter, req *http.Request) {
+	objects := b.objects()
+	rev := req.URL.Query().Get("rev")
+	sha, _, err := b.revision(objects, rev)
+	if err != nil {
+		b.fail(w, req, http.StatusNotFound, err)
+		return
+	}
+	page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+	page = max(page, 0)
+
+	shas, err := objects.RevList([]string{sha}, nil, RevListOptions{})
+	if err != nil {
+		b.fail(w, req, http.StatusInternalServerError, err)
+		return
+	}
+	start := min(page*browseLogPageSize, len(shas))
+	end := min(start+browseLogPageSize, len(shas))
+
+	var commits []browseCommit
+	for _, sha := range shas[start:end] {
+		commit, err := objects.ReadCommit(sha)
+		if err != nil {
+			b.fail(w, req, http.StatusInternalServerError, err)
+			return
+		}
+		commits = append(commits, newBrowseCommit(sha, commit))
+	}
+
+	data := struct {
+		Title          string
+		Rev            string
+		Commits        []browseCommit
+		Previous, Next int
+	}{Title: "log", Rev: rev, Commits: commits, Previous: -1, Next: -1}
+	if page > 0 {
+		data.Previous = page - 1
+	}
+	if end < len(shas) {
+		data.Next = page + 1
+	}
+	b.render(w, req, "log", data)
+}
+
+// browseLine is one line of a rendered diff.
+type browseLine struct {
+	Class string
+	Text  string
+}
+
+// browsePatch is the diff of one file as the commit page shows it.
+type browsePatch struct {
+	Path   string
+	Status string
+	Binary bool
+	Lines  []browseLine
+}
+
+func (b *browser) serveCommit(w http.ResponseWriter, req *http.Request) {
+	objects := b.objects()
+	sha, commit, err := b.revision(objects, req.PathValue("rev"))
+	if err != nil {
+		b.fail(w, req, http.StatusNotFound, err)
+		return
+	}
+
+	parentTree := ""
+	if len(commit.Parents) > 0 {
+		parent, err := objects.ReadCommit(commit.Parents[0])
+		if err != nil {
+			b.fail(w, req, http.StatusInternalServerError, err)
+			return
+		}
+		parentTree = parent.Tree
+	}
+	changes, err := objects.DiffTrees(parentTree, commit.Tree)
+	if err != nil {
+		b.fail(w, req, http.StatusInternalServerError, err)
+		return
+	}
+	patches, err := objects.Patches(changes, "", 3)
+	if err != nil {
+		b.fail(w, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	b.render(w, req, "commit", struct {
+		Title   string
+		Rev     string
+		Commit  browseCommit
+		Patches []browsePatch
+	}{Title: sha[:7], Rev: sha, Commit: newBrowseCommit(sha, commit), Patches: browsePatches(patches)})
+}
+
+// browsePatches turns patches into lines classed for highlighting.
+func browsePatches(patches []*FilePatch) []browsePatch {
+	result := make([]browsePatch, len(patches))
+	for i, patch := range patches {
+		result[i] = browsePatch{Path: patch.Path, Status: string(patch.Status), Binary: patch.Binary}
+		for _, hunk := range patch.Hunks {
+			result[i].Lines = append(result[i].Lines, browseLine{Class: "hunk", Text: hunk.Header()})
+			for _, line := range hunk.Lines {
+				class := map[byte]string{'+': "add", '-': "del"}[line.Kind]
+				result[i].Lines = append(result[i].Lines, browseLine{Class: class, Text: string(line.Kind) + strings.TrimSuffix(line.Text, "\n")})
+			}
+		}
+	}
+	return result
+}
+
+// entryAt resolves the path of a tree or blob request at the requested
+// revision.
+func (b *browser) entryAt(objects *ObjectManager, req *http.Request) (string, TreeEntry, error) {
+	sha, commit, err := b.revision(objects, req.URL.Query().Get("rev"))
+	if err != nil {
+		return "", TreeEntry{}, err
+	}
+	entry, err := objects.TreeEntryAt(commit.Tree, req.PathValue("path"))
+	return sha, entry, err
+}
+
+func (b *browser) serveTree(w http.ResponseWriter, req *http.Request) {
+	objects := b.objects()
+	sha, entry, err := b.entryAt(objects, req)
+	if err == nil && !entry.IsTree() {
+		err = fmt.Errorf("'%s' is not a directory", req.PathValue("path"))
+	}
+	if err != nil {
+		b.fail(w, req, http.StatusNotFound, err)
+		return
+	}
+	entries, err := objects.ReadTree(entry.SHA)
+	if err != nil {
+		b.fail(w, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	dir := strings.Trim(req.PathValue("path"), "/")
+	type listed struct {
+		Name, Path, Kind string
+	}
+	var listing []listed
+	for _, entry := range entries {
+		kind := "blob"
+		switch {
+		case entry.IsTree():
+			kind = "tree"
+		case entry.IsGitlink():
+			kind = "commit"
+		}
+		listing = append(listing, listed{Name: entry.Name, Path: path.Join(dir, entry.Name), Kind: kind})
+	}
+	b.render(w, req, "tree", struct {
+		Title   string
+		Rev     string
+		Path    string
+		Parent  string
+		Entries []listed
+	}{Title: "/" + dir, Rev: sha, Path: dir, Parent: path.Dir(dir), Entries: listing})
+}
+
+func (b *browser) serveBlob(w http.ResponseWriter, req *http.Request) {
+	objects := b.objects()
+	sha, entry, err := b.entryAt(objects, req)
+	if err == nil && (entry.IsTree() || entry.IsGitlink()) {
+		err = fmt.Errorf("'%s' is not a file", req.PathValue("path"))
+	}
+	if err != nil {
+		b.fail(w, req, http.StatusNotFound, err)
+		return
+	}
+	_, data, err := objects.ReadObject(entry.SHA)
+	if err != nil {
+		b.fail(w, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	file := req.PathValue("path")
+	binary := IsBinary(data)
+	var lines []string
+	if !binary {
+		lines = splitLines(data)
+		for i := range lines {
+			lines[i] = strings.TrimSuffix(lines[i], "\n")
+		}
+	}
+	b.render(w, req, "blob", struct {
+		Title    string
+		Rev      string
+		Path     string
+		Dir      string
+		Language string
+		Binary   bool
+		Size     int
+		Lines    []string
+	}{Title: file, Rev: sha, Path: file, Dir: path.Dir(file), Language: languageOf(file), Binary: binary, Size: len(data), Lines: lines})
+}
+
+// languages maps file extensions, and a few well-known names, to the
+// language shown above a file.
+var languages = map[string]string{
+	".go": "Go", ".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++", ".hpp": "C++",
+	".rs": "Rust", ".py": "Python", ".rb": "Ruby", ".js": "JavaScript", ".ts": "TypeScript",
+	".java": "Java", ".kt": "Kotlin", ".swift": "Swift", ".sh": "Shell", ".bash": "Shell",
+	".md": "Markdown", ".html": "HTML", ".css": "CSS", ".json": "JSON", ".yaml": "YAML",
+	".yml": "YAML", ".toml": "TOML", ".xml": "XML", ".sql": "SQL", ".txt": "Text",
+	"Makefile": "Makefile", "Dockerfile": "Dockerfile", "go.mod": "Go module",
+}
+
+// languageOf guesses the language of a file from its name.
+func languageOf(file string) string {
+	base := path.Base(file)
+	if language, ok := languages[base]; ok {
+		return language
+	}
+	if language, ok := languages[strings.ToLower(path.Ext(base))]; ok {
+		return language
+	}
+	return "Plain text"
+}
+
+var browseTemplates = template.Must(template.New("browse").Funcs(template.FuncMap{
+	"date": func(sig Signature) string { return sig.When.Format("2006-01-02 15:04:05 -0700") },
+	"inc":  func(i int) int { return i + 1 },
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}} - justdoit browse</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre, code, .sha { font-family: monospace; }
+table { border-collapse: collapse; }
+td { padding: 0.2em 0.8em; vertical-align: top; }
+.add { background: #e6ffec; } .del { background: #ffebe9; } .hunk { color: #6a737d; }
+.lineno { color: #6a737d; text-align: right; user-select: none; }
+nav a { margin-right: 1em; }
+</style></head><body>
+<nav><a href="/">log</a><a href="/tree/?rev={{.Rev}}">tree</a></nav>
+{{end}}
+{{define "footer"}}</body></html>{{end}}
+
+{{define "log"}}{{template "header" .}}
+<h1>Log{{if .Rev}} of {{.Rev}}{{end}}</h1>
+<table>{{range .Commits}}
+<tr><td class="sha"><a href="/commit/{{.SHA}}">{{.Short}}</a></td><td>{{.Subject}}</td><td>{{.Author.Name}}</td><td>{{date .Author}}</td></tr>{{end}}
+</table>
+<p>{{if ge .Previous 0}}<a href="/log?rev={{.Rev}}&page={{.Previous}}">newer</a> {{end}}{{if ge .Next 0}}<a href="/log?rev={{.Rev}}&page={{.Next}}">older</a>{{end}}</p>
+{{template "footer"}}{{end}}
+
+{{define "commit"}}{{template "header" .}}
+<h1>Commit <span class="sha">{{.Commit.SHA}}</span></h1>
+<p>{{.Commit.Author.Name}} &lt;{{.Commit.Author.Email}}&gt; on {{date .Commit.Author}}</p>
+<p>{{range .Commit.Parents}}parent <a class="sha" href="/commit/{{.}}">{{.}}</a><br>{{end}}
+<a href="/tree/?rev={{.Commit.SHA}}">browse files</a></p>
+<pre>{{.Commit.Message}}</pre>
+{{range .Patches}}<h3>{{.Status}} <a href="/blob/{{.Path}}?rev={{$.Rev}}">{{.Path}}</a></h3>
+{{if .Binary}}<p>Binary file differs</p>{{else}}<pre>{{range .Lines}}<div class="{{.Class}}">{{.Text}}</div>{{end}}</pre>{{end}}
+{{end}}{{template "footer"}}{{end}}
+
+{{define "tree"}}{{template "header" .}}
+<h1>{{.Title}} at <a class="sha" href="/commit/{{.Rev}}">{{slice .Rev 0 7}}</a></h1>
+<table>{{if .Path}}<tr><td></td><td><a href="/tree/{{if ne .Parent "."}}{{.Parent}}{{end}}?rev={{.Rev}}">..</a></td></tr>{{end}}
+{{range .Entries}}<tr><td>{{.Kind}}</td><td>{{if eq .Kind "tree"}}<a href="/tree/{{.Path}}?rev={{$.Rev}}">{{.Name}}/</a>{{else if eq .Kind "blob"}}<a href="/blob/{{.Path}}?rev={{$.Rev}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+
+{{define "blob"}}{{template "header" .}}
+<h1>{{.Path}} at <a class="sha" href="/commit/{{.Rev}}">{{slice .Rev 0 7}}</a></h1>
+<p><a href="/tree/{{if ne .Dir "."}}{{.Dir}}{{end}}?rev={{.Rev}}">up</a> &middot; {{.Language}} &middot; {{.Size}} bytes</p>
+{{if .Binary}}<p>Binary file not shown.</p>{{else}}<table><tr><td class="lineno"><pre>{{range $i, $line := .Lines}}{{inc $i}}
+{{end}}</pre></td><td><pre>{{range .Lines}}{{.}}
+{{end}}</pre></td></tr></table>{{end}}
+{{template "footer"}}{{end}}
+`))
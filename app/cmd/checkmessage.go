@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultSubjectMaxLength is the longest subject check-message accepts
+// unless checkMessage.subjectMaxLength says otherwise.
+const defaultSubjectMaxLength = 72
+
+// defaultDenyWords start subjects that describe a change instead of giving
+// the order to make it, e.g. "Added the parser" for "Add the parser".
+var defaultDenyWords = []string{
+	"added", "adds", "adding",
+	"changed", "changes", "changing",
+	"fixed", "fixes", "fixing",
+	"removed", "removes", "removing",
+	"updated", "updates", "updating",
+}
+
+// MessageRules are what CheckMessage holds a commit message to.
+type MessageRules struct {
+	SubjectMaxLength int      // In characters; 0 allows subjects of any length.
+	DenyWords        []string // First words of the subject that are refused, compared without case.
+	RequireTrailers  []string // Tokens of the trailers every message must carry, e.g. "Signed-off-by".
+}
+
+// LoadMessageRules reads the rules of check-message from the config:
+// checkMessage.subjectMaxLength, and checkMessage.denyWords and
+// checkMessage.requireTrailers, which hold whitespace separated lists.
+// denyWords replaces the built-in list of past tense and present
+// participle verbs; set it to "none" to allow any first word.
+//
+// Parameters:
+// - repo: The repository, or nil to read only the global config.
+//
+// Returns:
+// - The rules.
+// - An error if checkMessage.subjectMaxLength is not a number.
+func LoadMessageRules(repo *GitRepository) (MessageRules, error) {
+	rules := MessageRules{SubjectMaxLength: defaultSubjectMaxLength, DenyWords: defaultDenyWords}
+	if value := lookupConfig(repo, "checkmessage.subjectmaxlength"); value != "" {
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 0 {
+			return rules, fmt.Errorf("bad numeric config value '%s' for 'checkMessage.subjectMaxLength'", value)
+		}
+		rules.SubjectMaxLength = length
+	}
+	if value := lookupConfig(repo, "checkmessage.denywords"); value != "" {
+		rules.DenyWords = strings.Fields(value)
+		if strings.EqualFold(value, "none") {
+			rules.DenyWords = nil
+		}
+	}
+	rules.RequireTrailers = strings.Fields(lookupConfig(repo, "checkmessage.requiretrailers"))
+	return rules, nil
+}
+
+// CheckMessage checks a commit message against rules, as a commit-msg hook
+// would. Comments and anything below the scissors line are ignored, and
+// the "fixup! " and "squash! " prefixes of autosquash commits do not count
+// towards the subject. A "<area>: " prefix is skipped when looking for the
+// first word of the subject.
+//
+// Parameters:
+// - repo: The repository, or nil.
+// - message: The message, as the editor left it in COMMIT_EDITMSG.
+// - rules: The rules to check.
+//
+// Returns:
+// - The rules the message breaks, one sentence each; empty if it passes.
+// - An error if core.commentChar is invalid.
+func CheckMessage(repo *GitRepository, message []byte, rules MessageRules) ([]string, error) {
+	commentChar, err := CommentChar(repo)
+	if err != nil {
+		return nil, err
+	}
+	text := string(message)
+	scissors := commentChar + " " + scissorsLine
+	if strings.HasPrefix(text, scissors) {
+		text = ""
+	} else if i := strings.Index(text, "\n"+scissors); i >= 0 {
+		text = text[:i+1]
+	}
+	cleaned := Stripspace([]byte(text), commentChar)
+	if len(cleaned) == 0 {
+		return []string{"the message is empty"}, nil
+	}
+
+	var problems []string
+	lines := strings.Split(strings.TrimSuffix(string(cleaned), "\n"), "\n")
+	subject, squashed := skipAutosquashPrefix(lines[0])
+	for squashed {
+		subject, squashed = skipAutosquashPrefix(strings.TrimLeft(subject, " \t"))
+	}
+	if length := utf8.RuneCountInString(subject); rules.SubjectMaxLength > 0 && length > rules.SubjectMaxLength {
+		problems = append(problems, fmt.Sprintf("the subject is %d characters long, more than %d", length, rules.SubjectMaxLength))
+	}
+	if len(lines) > 1 && lines[1] != "" {
+		problems = append(problems, "the subject is not followed by a blank line")
+	}
+	if word := subjectVerb(subject); word != "" {
+		for _, denied := range rules.DenyWords {
+			if strings.EqualFold(word, denied) {
+				problems = append(problems, fmt.Sprintf("the subject should use the imperative mood, not '%s'", word))
+				break
+			}
+		}
+	}
+
+	trailers := ParseTrailers(repo, cleaned)
+	for _, token := range rules.RequireTrailers {
+		found := false
+		for _, trailer := range trailers {
+			found = found || strings.EqualFold(trailer.Token, token)
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("the '%s' trailer is missing", token))
+		}
+	}
+	return problems, nil
+}
+
+// subjectVerb returns the first word of a subject, after an "<area>: "
+// prefix, without the punctuation around it.
+func subjectVerb(subject string) string {
+	words := strings.Fields(subject)
+	if len(words) > 1 && strings.HasSuffix(words[0], ":") {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.Trim(words[0], `.,:;!?"'()[]`)
+}
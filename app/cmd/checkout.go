@@ -0,0 +1,566 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// CheckoutTree fills the worktree of a new repository with the files of a
+// tree and writes an index describing them, as the checkout at the end of
+// git clone does. Files already in the worktree are overwritten. Like git,
+// line endings follow the .gitattributes files of the tree itself.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - tree: The tree to check out.
+//
+// Returns:
+// - An error if an object is missing or a file cannot be written.
+func CheckoutTree(repo *GitRepository, tree string) error {
+	defer trace.Start(trace.Perf, "checkout tree", "tree", tree)()
+
+	objects := NewObjectManager(repo)
+	files, err := objects.FlattenTree(tree)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	shas := make([]string, 0, len(files))
+	index := &Index{Version: 2}
+	for _, name := range names {
+		entry := files[name]
+		if entry.Mode != ModeGitlink {
+			shas = append(shas, entry.SHA)
+		}
+		placeholder := &IndexEntry{Name: name, SHA: entry.SHA}
+		fmt.Sscanf(entry.Mode, "%o", &placeholder.Mode)
+		index.Add(placeholder)
+	}
+	if err := objects.Prefetch(shas); err != nil {
+		return err
+	}
+
+	eol := NewEOLConverter(repo)
+	eol.attrs = NewIndexAttributeMatcher(repo, index)
+	for _, name := range names {
+		entry := files[name]
+		info, err := checkoutFile(repo, objects, eol, name, entry)
+		if err != nil {
+			return err
+		}
+		index.Add(NewIndexEntry(name, entry.Mode, entry.SHA, info))
+	}
+	return WriteIndex(repo, index)
+}
+
+// checkoutFile writes one tree entry to the worktree, replacing whatever
+// is at its path. A gitlink becomes an empty directory, or keeps the
+// directory already there, and a symlink a plain file holding its target
+// when core.symlinks is off.
+//
+// Returns:
+// - The stat data of the written file, for its index entry.
+// - An error if the blob cannot be read or the file cannot be written.
+func checkoutFile(repo *GitRepository, objects *ObjectManager, eol *EOLConverter, name string, entry TreeEntry) (os.FileInfo, error) {
+	file := worktreePath(repo, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(file); err == nil && info.IsDir() && entry.Mode == ModeGitlink {
+		return info, nil
+	}
+	if err := os.RemoveAll(file); err != nil {
+		return nil, err
+	}
+
+	if entry.Mode == ModeGitlink {
+		if err := os.Mkdir(file, 0755); err != nil {
+			return nil, err
+		}
+		return os.Lstat(file)
+	}
+
+	objType, data, err := objects.ReadObject(entry.SHA)
+	if err != nil {
+		return nil, err
+	}
+	if objType != BlobType {
+		return nil, fmt.Errorf("'%s' is a %s, not a blob", name, objType)
+	}
+
+	switch {
+	case entry.Mode == ModeSymlink && SymlinksEnabled(repo):
+		err = os.Symlink(string(data), file)
+	case entry.Mode == ModeSymlink:
+		err = os.WriteFile(file, data, 0644)
+	case entry.Mode == ModeExecutable:
+		err = os.WriteFile(file, eol.ToWorktree(name, data), 0755)
+	default:
+		err = os.WriteFile(file, eol.ToWorktree(name, data), 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(file)
+}
+
+// CheckoutOptions controls Checkout.
+type CheckoutOptions struct {
+	Force    bool      // Throw away local changes and untracked files in the way instead of refusing.
+	Progress io.Writer // Receives a progress meter while files are updated; nil for none.
+	KeepHead bool      // Only update the index and the worktree, for callers that move HEAD themselves.
+}
+
+// CheckoutResult summarizes a call to Checkout. When Modified or Untracked
+// lists any path, the checkout was refused and nothing was changed.
+type CheckoutResult struct {
+	Branch    string   // The branch switched to, empty when HEAD was detached.
+	Commit    string   // The commit checked out.
+	Updated   int      // Number of files written or removed.
+	Modified  []string // Paths whose local changes the checkout would overwrite.
+	Untracked []string // Untracked files the checkout would overwrite.
+}
+
+// Refused reports whether local changes stopped the checkout.
+func (r *CheckoutResult) Refused() bool {
+	return len(r.Modified) > 0 || len(r.Untracked) > 0
+}
+
+// Checkout switches the worktree, the index and HEAD to a branch, or
+// detaches HEAD at a commit. Only the files that differ between HEAD and
+// the target are touched, so local changes to other files are kept, as in
+// git. A file that differs is only replaced when neither its index entry
+// nor its worktree copy has changes of its own, judged by the stat data in
+// the index and by hashing the file when that does not match, and an
+// untracked file is never overwritten; otherwise the checkout is refused
+// and the paths in the way are listed. With Force, the index and worktree
+// are reset to the target instead, whatever their changes. The move is
+// recorded in the reflog of HEAD as "checkout: moving from <old> to
+// <new>", which is where "@{-<n>}" and "-", for "@{-1}", find the branch
+// checked out before.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - rev: A branch name, "@{-<n>}" or "-", or any revision naming a commit.
+// - opts: Whether to force the checkout and where to show progress.
+//
+// Returns:
+// - The new HEAD, or the paths that stopped the checkout.
+// - An error if rev names no commit, the index has unmerged entries and
+// Force is not set, or a file cannot be written.
+func Checkout(repo *GitRepository, rev string, opts CheckoutOptions) (*CheckoutResult, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	defer trace.Start(trace.Perf, "checkout", "rev", rev)()
+
+	if rev == "-" {
+		rev = "@{-1}"
+	}
+	if spec, ok := strings.CutPrefix(rev, "@{-"); ok && strings.HasSuffix(spec, "}") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(spec, "}")); err == nil && n > 0 {
+			previous, err := previousCheckout(repo, n)
+			if err != nil {
+				return nil, err
+			}
+			rev = previous
+		}
+	}
+
+	result := &CheckoutResult{}
+	var err error
+	if refExists(repo, BranchesPrefix+rev) {
+		result.Branch = rev
+		result.Commit, err = ResolveRef(repo, BranchesPrefix+rev)
+	} else {
+		result.Commit, err = ResolveRevision(repo, rev+"^{commit}")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", rev)
+	}
+
+	objects := NewObjectManager(repo)
+	commit, err := objects.ReadCommit(result.Commit)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := objects.FlattenTree(commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return nil, err
+	}
+	oldFiles := make(map[string]TreeEntry)
+	if head.SHA != "" {
+		headCommit, err := objects.ReadCommit(head.SHA)
+		if err != nil {
+			return nil, err
+		}
+		if oldFiles, err = objects.FlattenTree(headCommit.Tree); err != nil {
+			return nil, err
+		}
+	}
+
+	lock, index, err := LockIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	unmerged := make(map[string]bool)
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 {
+			unmerged[entry.Name] = true
+		}
+	}
+	if len(unmerged) > 0 && !opts.Force {
+		return nil, fmt.Errorf("you need to resolve your current index first")
+	}
+	eol := NewEOLConverter(repo)
+	staged := IndexFiles(index)
+	worktree, err := WorktreeFiles(repo, index, eol)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]TreeEntry{oldFiles, newFiles, staged} {
+		for name := range files {
+			paths[name] = true
+		}
+	}
+	for name := range unmerged {
+		paths[name] = true
+	}
+	plan := checkoutPlan{
+		repo:     repo,
+		ignore:   NewIgnoreMatcher(repo),
+		oldFiles: oldFiles,
+		newFiles: newFiles,
+		staged:   staged,
+		worktree: worktree,
+		unmerged: unmerged,
+		force:    opts.Force,
+	}
+	var updates []string
+	for name := range paths {
+		update, err := plan.update(name, result)
+		if err != nil {
+			return nil, err
+		}
+		if update {
+			updates = append(updates, name)
+		}
+	}
+	sort.Strings(result.Modified)
+	sort.Strings(result.Untracked)
+	result.Untracked = slices.Compact(result.Untracked)
+	if result.Refused() {
+		return result, nil
+	}
+
+	// Removals go first, so that a file can take the place of a directory
+	// that is going away.
+	sort.Slice(updates, func(i, j int) bool {
+		_, iNew := newFiles[updates[i]]
+		_, jNew := newFiles[updates[j]]
+		if iNew != jNew {
+			return !iNew
+		}
+		return updates[i] < updates[j]
+	})
+	var shas []string
+	for _, name := range updates {
+		if entry, ok := newFiles[name]; ok && entry.Mode != ModeGitlink {
+			shas = append(shas, entry.SHA)
+		}
+	}
+	if err := objects.Prefetch(shas); err != nil {
+		return nil, err
+	}
+
+	meter := newProgress(opts.Progress, "Updating files", len(updates))
+	for _, name := range updates {
+		entry, ok := newFiles[name]
+		if !ok {
+			index.Remove(name)
+			if err := removeWorktreeFile(repo, name); err != nil {
+				return nil, err
+			}
+		} else {
+			info, err := checkoutFile(repo, objects, eol, name, entry)
+			if err != nil {
+				return nil, err
+			}
+			index.Add(NewIndexEntry(name, entry.Mode, entry.SHA, info))
+		}
+		meter.add(1)
+	}
+	meter.finish()
+	result.Updated = len(updates)
+
+	if err := lock.Write(index); err != nil {
+		return nil, err
+	}
+	if opts.KeepHead {
+		return result, nil
+	}
+	from := head.Branch()
+	if head.Detached() {
+		from = head.SHA
+	}
+	message := fmt.Sprintf("checkout: moving from %s to %s", from, rev)
+	if result.Branch != "" {
+		err = UpdateSymbolicRef(repo, HeadFile, BranchesPrefix+result.Branch, message)
+	} else {
+		err = UpdateRef(repo, HeadFile, result.Commit, message)
+	}
+	return result, err
+}
+
+// CheckoutPathsResult summarizes a call to CheckoutPaths.
+type CheckoutPathsResult struct {
+	Tree    string // The tree the files came from, empty for the index.
+	Updated int    // Number of files written.
+}
+
+// CheckoutPaths restores the files under paths without moving HEAD, as git
+// checkout [<tree-ish>] -- <paths> does. From a commit or tree, the files
+// are written to both the index and the worktree, resolving any conflict
+// on them; the files under paths that it lacks are kept, as in git's
+// default overlay mode. Without one, the worktree files are restored from
+// the index. Files that already match are left alone.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - rev: The commit or tree to take the files from, or "" for the index.
+// - paths: Slash separated paths relative to the top of the worktree. A
+// directory stands for the files below it, and "." for every file.
+//
+// Returns:
+// - Where the files came from and how many were written.
+// - An error if rev names no commit or tree, a path matches no file of the source,
+// a file to restore from the index is unmerged or a file cannot be
+// written.
+func CheckoutPaths(repo *GitRepository, rev string, paths []string) (*CheckoutPathsResult, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	defer trace.Start(trace.Perf, "checkout paths", "rev", rev)()
+
+	lock, index, err := LockIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	objects := NewObjectManager(repo)
+	result := &CheckoutPathsResult{}
+	source := IndexFiles(index)
+	unmerged := make(map[string]bool)
+	if rev == "" {
+		for _, entry := range index.Entries {
+			if entry.Stage() != 0 {
+				unmerged[entry.Name] = true
+			}
+		}
+	} else {
+		sha, err := ResolveRevision(repo, rev)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference: %s", rev)
+		}
+		if result.Tree, err = objects.PeelToType(sha, TreeType); err != nil {
+			return nil, fmt.Errorf("reference is not a tree: %s", rev)
+		}
+		if source, err = objects.FlattenTree(result.Tree); err != nil {
+			return nil, err
+		}
+	}
+
+	var matched []string
+	for _, spec := range paths {
+		found := false
+		for name := range source {
+			if pathspecMatches(name, spec) {
+				matched = append(matched, name)
+				found = true
+			}
+		}
+		for name := range unmerged {
+			if pathspecMatches(name, spec) {
+				return nil, fmt.Errorf("path '%s' is unmerged", name)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", spec)
+		}
+	}
+	sort.Strings(matched)
+	matched = slices.Compact(matched)
+
+	eol := NewEOLConverter(repo)
+	worktree, err := WorktreeFiles(repo, index, eol)
+	if err != nil {
+		return nil, err
+	}
+	var shas []string
+	for _, name := range matched {
+		if entry := source[name]; entry.Mode != ModeGitlink {
+			shas = append(shas, entry.SHA)
+		}
+	}
+	if err := objects.Prefetch(shas); err != nil {
+		return nil, err
+	}
+
+	for _, name := range matched {
+		entry := source[name]
+		current := index.Entry(name)
+		file, exists := worktree[name]
+		if current != nil && current.Stage() == 0 && sameTreeEntry(current.TreeEntry(), entry) && exists && sameTreeEntry(file, entry) {
+			continue
+		}
+		info, err := checkoutFile(repo, objects, eol, name, entry)
+		if err != nil {
+			return nil, err
+		}
+		restored := NewIndexEntry(name, entry.Mode, entry.SHA, info)
+		if rev == "" {
+			// The entry itself does not change, so neither do its flags.
+			restored.Flags, restored.ExtendedFlags = current.Flags, current.ExtendedFlags
+		}
+		index.Add(restored)
+		result.Updated++
+	}
+	if result.Updated == 0 {
+		return result, nil
+	}
+	return result, lock.Write(index)
+}
+
+// pathspecMatches reports whether the file name falls under spec: the file
+// itself, a directory holding it, or "." or "" for the top of the worktree.
+func pathspecMatches(name, spec string) bool {
+	return spec == "." || spec == "" || name == spec || strings.HasPrefix(name, spec+"/")
+}
+
+// checkoutPlan holds the states of the files Checkout compares.
+type checkoutPlan struct {
+	repo     *GitRepository
+	ignore   *IgnoreMatcher
+	oldFiles map[string]TreeEntry // The files of HEAD.
+	newFiles map[string]TreeEntry // The files of the target commit.
+	staged   map[string]TreeEntry // The stage 0 entries of the index.
+	worktree map[string]TreeEntry // The tracked files as they are in the worktree.
+	unmerged map[string]bool
+	force    bool
+}
+
+// update decides whether Checkout has to write or remove a path, and
+// records the path in result when local changes are in the way.
+func (p *checkoutPlan) update(name string, result *CheckoutResult) (bool, error) {
+	oldEntry, inOld := p.oldFiles[name]
+	newEntry, inNew := p.newFiles[name]
+	indexEntry, inIndex := p.staged[name]
+	worktreeEntry, inWorktree := p.worktree[name]
+
+	if p.force {
+		clean := inIndex && sameTreeEntry(indexEntry, newEntry) && inWorktree && sameTreeEntry(worktreeEntry, newEntry)
+		return p.unmerged[name] || (inNew && !clean) || (!inNew && (inIndex || inOld)), nil
+	}
+
+	switch {
+	case inOld == inNew && (!inOld || sameTreeEntry(oldEntry, newEntry)):
+		// The path is the same in both commits; local changes carry over.
+		return false, nil
+	case inIndex == inNew && (!inIndex || sameTreeEntry(indexEntry, newEntry)):
+		// The index already has what the target has.
+		return false, nil
+	case inIndex != inOld || (inIndex && !sameTreeEntry(indexEntry, oldEntry)):
+		result.Modified = append(result.Modified, name)
+		return false, nil
+	case inIndex && inWorktree && !sameTreeEntry(worktreeEntry, indexEntry):
+		result.Modified = append(result.Modified, name)
+		return false, nil
+	case !inIndex:
+		untracked, err := p.untrackedInTheWay(name)
+		if err != nil {
+			return false, err
+		}
+		result.Untracked = append(result.Untracked, untracked...)
+	}
+	return true, nil
+}
+
+// sameTreeEntry reports whether two entries hold the same object and mode.
+func sameTreeEntry(a, b TreeEntry) bool {
+	return a.SHA == b.SHA && a.Mode == b.Mode
+}
+
+// untrackedInTheWay lists the untracked, non-ignored files that writing a
+// new file at name would overwrite: the file itself, or the files of a
+// directory in its place.
+func (p *checkoutPlan) untrackedInTheWay(name string) ([]string, error) {
+	info, err := os.Lstat(worktreePath(p.repo, name))
+	if err != nil {
+		// A file may stand where a directory of the path has to go.
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if info, err := os.Lstat(worktreePath(p.repo, dir)); err == nil && !info.IsDir() {
+				if _, tracked := p.staged[dir]; !tracked && !p.ignore.Ignored(dir, false) {
+					return []string{dir}, nil
+				}
+				break
+			}
+		}
+		return nil, nil
+	}
+	if !info.IsDir() {
+		if p.ignore.Ignored(name, false) {
+			return nil, nil
+		}
+		return []string{name}, nil
+	}
+
+	files, err := filesBelow(p.repo, p.ignore, name+"/")
+	if err != nil {
+		return nil, err
+	}
+	var untracked []string
+	for _, file := range files {
+		if _, tracked := p.staged[file]; !tracked {
+			untracked = append(untracked, file)
+		}
+	}
+	return untracked, nil
+}
+
+// removeWorktreeFile deletes a file from the worktree along with the
+// directories it leaves empty. The directory of a submodule is only
+// removed when it is empty.
+func removeWorktreeFile(repo *GitRepository, name string) error {
+	file := worktreePath(repo, name)
+	if info, err := os.Lstat(file); err == nil && info.IsDir() {
+		_ = os.Remove(file)
+	} else if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if os.Remove(worktreePath(repo, dir)) != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// MirrorRefspec maps every ref of a remote onto the same ref, as a mirror
+// fetches and pushes them.
+const MirrorRefspec = "+refs/*:refs/*"
+
+// CloneOptions controls Clone.
+type CloneOptions struct {
+	Bare       bool   // Make a bare repository whose branches are those of the remote.
+	Mirror     bool   // Make a bare repository mirroring every ref of the remote; implies Bare.
+	Origin     string // The name of the remote; "origin" when empty.
+	UploadPack string // Program to run on the remote instead of git-upload-pack.
+	Branch     string // The branch, or tag to detach HEAD at, to start on instead of the remote HEAD.
+	// Fetch only the history of Branch, or of the remote HEAD, now and in
+	// later fetches. A mirror clone takes every ref regardless.
+	SingleBranch bool
+	// Filter makes a partial clone, leaving out the objects the filter
+	// spec selects, e.g. "blob:none". origin becomes the promisor remote
+	// that they are fetched from when needed.
+	Filter string
+}
+
+// CloneResult summarizes a call to Clone.
+type CloneResult struct {
+	Repo  *GitRepository
+	Fetch *FetchResult
+	Head  string // The branch or tag HEAD was set to, e.g. refs/heads/main; empty for an empty remote.
+}
+
+// DefaultCloneDir derives the directory a clone of url goes to from the
+// last component of its path, e.g. "repo" for "https://host/repo.git", or
+// "repo.git" for a bare clone.
+func DefaultCloneDir(url string, bare bool) string {
+	name := url
+	if endpoint, err := transport.ParseEndpoint(url); err == nil {
+		name = endpoint.Path
+	}
+	name = strings.TrimSuffix(strings.TrimRight(filepath.ToSlash(name), "/"), "/"+GitExtension)
+	name = strings.TrimSuffix(path.Base(name), GitExtension)
+	if bare {
+		name += GitExtension
+	}
+	return name
+}
+
+// Clone creates a repository in dir holding a copy of the remote at url.
+// The remote is recorded as origin, its branches are fetched as
+// remote-tracking refs with its tags, and the branch its HEAD points at is
+// checked out and set to track it. A bare clone instead takes the remote
+// branches as its own branches, and a mirror clone takes every ref and
+// keeps fetching them all, with remote.origin.mirror set so that pushes
+// mirror too. A single-branch clone asks only for the branch it starts on
+// and the tags into its history, and keeps its fetch refspec to that
+// branch. With a filter, origin is recorded as the promisor remote with
+// the filter kept for later fetches, as Fetch does. When the clone fails, the directory is removed again if Clone
+// created it.
+//
+// Parameters:
+// - url: The URL or path of the remote repository.
+// - dir: Where to create the repository; see DefaultCloneDir when empty.
+// - opts: The kind of clone.
+//
+// Returns:
+// - The new repository and what was fetched.
+// - An error if dir is not empty or the remote cannot be cloned.
+func Clone(url, dir string, opts CloneOptions) (result *CloneResult, err error) {
+	defer trace.Start(trace.Perf, "clone", "url", url)()
+
+	bare := opts.Bare || opts.Mirror
+	if dir == "" {
+		dir = DefaultCloneDir(url, bare)
+	}
+	origin := opts.Origin
+	if origin == "" {
+		origin = "origin"
+	}
+	if opts.Filter != "" {
+		if _, err := ParseObjectFilter(opts.Filter); err != nil {
+			return nil, err
+		}
+	}
+	if endpoint, err := transport.ParseEndpoint(url); err == nil && endpoint.Scheme == "file" && !strings.Contains(url, "://") {
+		if url, err = filepath.Abs(url); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, readErr := os.ReadDir(dir)
+	if readErr == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("destination path '%s' already exists and is not an empty directory", dir)
+	}
+	if os.IsNotExist(readErr) {
+		defer func() {
+			if err != nil {
+				os.RemoveAll(dir)
+			}
+		}()
+	}
+
+	repo, _, err := CreateGitRepository(dir, InitOptions{Bare: bare})
+	if err != nil {
+		return nil, err
+	}
+	if err := SetConfig(repo, "remote", origin, "url", url); err != nil {
+		return nil, err
+	}
+	if opts.UploadPack != "" {
+		if err := SetConfig(repo, "remote", origin, "uploadpack", opts.UploadPack); err != nil {
+			return nil, err
+		}
+	}
+
+	// A single-branch clone settles on its branch before fetching, so that
+	// it asks for nothing else.
+	head, refspec := "", ""
+	if opts.SingleBranch && !opts.Mirror {
+		advertisement, err := queryRemote(repo, origin)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(advertisement.Refs))
+		for _, ref := range advertisement.Refs {
+			names = append(names, ref.Name)
+		}
+		if head, err = cloneHead(opts.Branch, advertisedHead(advertisement), names); err != nil {
+			return nil, err
+		}
+		if slices.Contains(names, head) {
+			refspec = singleBranchRefspec(head, origin, bare)
+		}
+	}
+
+	reflog := "clone: from " + url
+	fetchOpts := FetchOptions{Filter: opts.Filter, NoReflog: true}
+	switch {
+	case opts.Mirror:
+		err = setRemoteConfig(repo, origin, [][2]string{{"fetch", MirrorRefspec}, {"mirror", "true"}})
+	case bare && refspec != "":
+		fetchOpts.Refspecs = []string{refspec}
+	case bare:
+		fetchOpts.Tags = true
+		fetchOpts.Refspecs = []string{"+" + BranchesPrefix + "*:" + BranchesPrefix + "*"}
+	case refspec != "":
+		err = setRemoteConfig(repo, origin, [][2]string{{"fetch", refspec}})
+	default:
+		fetchOpts.Tags = true
+		err = setRemoteConfig(repo, origin, [][2]string{{"fetch", DefaultFetchRefspec(origin)}})
+	}
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := Fetch(repo, origin, fetchOpts)
+	if err != nil {
+		return nil, err
+	}
+	result = &CloneResult{Repo: repo, Fetch: fetched}
+
+	if head == "" {
+		names := make([]string, 0, len(fetched.Refs))
+		for _, ref := range fetched.Refs {
+			names = append(names, ref.Remote)
+		}
+		if head, err = cloneHead(opts.Branch, fetched.Head, names); err != nil {
+			return nil, err
+		}
+	}
+	if head == "" {
+		return result, nil
+	}
+	if remoteHead := trackingRef(repo, origin, fetched.Head); !bare && remoteHead != "" && refExists(repo, remoteHead) {
+		if err := UpdateSymbolicRef(repo, "refs/remotes/"+origin+"/"+HeadFile, remoteHead, reflog); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(head, "refs/tags/") {
+		result.Head = head
+		return result, checkoutClonedTag(repo, head, bare, reflog)
+	}
+
+	branch := strings.TrimPrefix(head, BranchesPrefix)
+	tracking := head
+	if !bare {
+		tracking = trackingRef(repo, origin, head)
+	}
+	if tracking == "" || !refExists(repo, tracking) {
+		// An empty remote only names its unborn branch.
+		return result, UpdateSymbolicRef(repo, HeadFile, head, "")
+	}
+	result.Head = head
+	if bare {
+		return result, UpdateSymbolicRef(repo, HeadFile, head, reflog)
+	}
+
+	sha, err := ResolveRef(repo, tracking)
+	if err != nil {
+		return nil, err
+	}
+	// HEAD goes first, so that the branch is logged in its reflog too.
+	if err := UpdateSymbolicRef(repo, HeadFile, head, ""); err != nil {
+		return nil, err
+	}
+	if err := UpdateRef(repo, head, sha, reflog); err != nil {
+		return nil, err
+	}
+	if _, err := SetBranchUpstream(repo, branch, tracking); err != nil {
+		return nil, err
+	}
+
+	commit, err := NewObjectManager(repo).ReadCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckoutTree(repo, commit.Tree); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// setRemoteConfig sets several remote.<name>.* variables.
+func setRemoteConfig(repo *GitRepository, name string, settings [][2]string) error {
+	for _, setting := range settings {
+		if err := SetConfig(repo, "remote", name, setting[0], setting[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneHead picks the ref a clone starts on among the remote refs: the
+// branch or else the tag named by branch, or the ref the remote HEAD
+// points at or, when the remote does not say, the default branch or else
+// the first branch.
+//
+// Returns:
+// - The full name of the ref, empty when the remote has no branches.
+// - An error if branch names neither a branch nor a tag of the remote.
+func cloneHead(branch, remoteHead string, names []string) (string, error) {
+	if branch != "" {
+		for _, candidate := range []string{BranchesPrefix + branch, "refs/tags/" + branch} {
+			if slices.Contains(names, candidate) {
+				return candidate, nil
+			}
+		}
+		return "", fmt.Errorf("remote branch '%s' not found in upstream", branch)
+	}
+	if remoteHead != "" {
+		return remoteHead, nil
+	}
+	first := ""
+	for _, name := range names {
+		if !strings.HasPrefix(name, BranchesPrefix) {
+			continue
+		}
+		if name == BranchesPrefix+DefaultBranch {
+			return name, nil
+		}
+		if first == "" {
+			first = name
+		}
+	}
+	return first, nil
+}
+
+// singleBranchRefspec returns the refspec that fetches only the given
+// branch or tag of a remote.
+func singleBranchRefspec(ref, remote string, bare bool) string {
+	if bare || strings.HasPrefix(ref, "refs/tags/") {
+		return "+" + ref + ":" + ref
+	}
+	return "+" + ref + ":refs/remotes/" + remote + "/" + strings.TrimPrefix(ref, BranchesPrefix)
+}
+
+// checkoutClonedTag detaches HEAD at the commit a fetched tag points to
+// and, unless the clone is bare, checks it out. reflog is the message
+// HEAD's reflog records.
+func checkoutClonedTag(repo *GitRepository, tag string, bare bool, reflog string) error {
+	sha, err := ResolveRef(repo, tag)
+	if err != nil {
+		return err
+	}
+	objects := NewObjectManager(repo)
+	sha, objType, err := objects.PeelObject(sha)
+	if err != nil {
+		return err
+	}
+	if objType != CommitType {
+		return fmt.Errorf("'%s' does not point to a commit", tag)
+	}
+	if err := UpdateRef(repo, HeadFile, sha, reflog); err != nil {
+		return err
+	}
+	if bare {
+		return nil
+	}
+	commit, err := objects.ReadCommit(sha)
+	if err != nil {
+		return err
+	}
+	return CheckoutTree(repo, commit.Tree)
+}
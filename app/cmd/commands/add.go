@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// AddCommand creates the `add` command.
+func AddCommand() *cobra.Command {
+	var opts cmd.AddOptions
+	var all bool
+	var run *runFlags
+
+	addCmd := &cobra.Command{
+		Use:   "add [-n] [-v] [-f] [-u | -A] [--] [<path>...]",
+		Short: "Stage the files under the given paths as they are in the worktree",
+		Long: `Stage the files under the given paths as they are in the worktree.
+
+Changed and untracked files are staged, and deleted ones removed from the
+index. With --update only tracked files are, and with --update or --all and
+no paths, those of the whole worktree. Ignored files are left out unless
+--force is given.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 && !all && !opts.Update {
+				fmt.Fprintln(os.Stderr, "Nothing specified, nothing added.")
+				return nil
+			}
+			for _, arg := range args {
+				path := worktreeRelative(repo, arg)
+				if path == "" {
+					return fmt.Errorf("'%s' is outside repository", arg)
+				}
+				opts.Paths = append(opts.Paths, path)
+			}
+			opts.DryRun = run.DryRun
+			opts.Warnings = os.Stderr
+
+			result, err := cmd.Add(repo, opts)
+			if err != nil {
+				return err
+			}
+			if run.DryRun || run.Verbose {
+				quotePath := cmd.QuotePathEnabled(repo)
+				for _, change := range result.Changes {
+					verb := "add"
+					if change.Removed {
+						verb = "remove"
+					}
+					fmt.Printf("%s '%s'\n", verb, cmd.QuotePath(change.Path, quotePath))
+				}
+			}
+			if len(result.Ignored) > 0 {
+				fmt.Fprintln(os.Stderr, "The following paths are ignored by one of your .gitignore files:")
+				for _, path := range result.Ignored {
+					fmt.Fprintln(os.Stderr, path)
+				}
+				fmt.Fprintln(os.Stderr, "hint: Use -f if you really want to add them.")
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+
+	flags := addCmd.Flags()
+	flags.BoolVarP(&opts.Force, "force", "f", false, "Also stage ignored files")
+	flags.BoolVarP(&opts.Update, "update", "u", false, "Only stage tracked files, leaving untracked ones alone")
+	flags.BoolVarP(&all, "all", "A", false, "Stage every file, also without paths")
+	addCmd.MarkFlagsMutuallyExclusive("update", "all")
+	run = addRunFlags(addCmd, runFlagUsage{
+		DryRun:  "Only show what would be staged",
+		Verbose: "Show each file staged",
+	})
+	return addCmd
+}
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// BenchCommand creates the hidden `bench` command.
+func BenchCommand() *cobra.Command {
+	var runs int
+	var repoPath, cpuProfile, memProfile string
+	var list bool
+
+	benchCmd := &cobra.Command{
+		Use:    "bench [<benchmark>...]",
+		Short:  "Time core operations against a repository",
+		Hidden: true,
+		Long: `Time core operations against a repository, the current one unless --repo
+names another.
+
+Each benchmark is prepared once and then run --runs times; the mean time of
+a run and of each item it handled (object, file, tree or index entry, or
+commit) are printed. Without arguments every benchmark runs. The command
+fails when an item takes longer on average than the budget of its
+benchmark, the same budgets the go test benchmarks hold the generated
+fixtures to. --cpuprofile and --memprofile write pprof profiles of the
+runs.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			if list {
+				for _, bench := range cmd.Benchmarks() {
+					fmt.Printf("%-16s %8s/%-6s %s\n", bench.Name, bench.Budget, bench.Unit, bench.Description)
+				}
+				return nil
+			}
+			if runs < 1 {
+				return fmt.Errorf("--runs must be at least 1")
+			}
+
+			benchmarks := cmd.Benchmarks()
+			if len(args) > 0 {
+				benchmarks = nil
+				for _, name := range args {
+					bench, err := cmd.FindBenchmark(name)
+					if err != nil {
+						return err
+					}
+					benchmarks = append(benchmarks, bench)
+				}
+			}
+
+			repo, err := cmd.FindRepository(repoPath)
+			if err != nil {
+				return err
+			}
+
+			if cpuProfile != "" {
+				file, err := os.Create(cpuProfile)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				if err := pprof.StartCPUProfile(file); err != nil {
+					return err
+				}
+				defer pprof.StopCPUProfile()
+			}
+
+			var over []string
+			for _, bench := range benchmarks {
+				result, err := cmd.RunBenchmark(repo, bench, runs)
+				if err != nil {
+					return err
+				}
+				mark := ""
+				if result.OverBudget() {
+					mark = "  over budget of " + result.Budget.String()
+					over = append(over, result.Name)
+				}
+				fmt.Printf("%-16s %4d runs %8d items %12s/run %10s/%s%s\n",
+					result.Name, result.Runs, result.Items, result.PerRun(), result.PerItem(), result.Unit, mark)
+			}
+
+			if memProfile != "" {
+				if err := writeMemProfile(memProfile); err != nil {
+					return err
+				}
+			}
+			if len(over) > 0 {
+				return fmt.Errorf("over budget: %s", strings.Join(over, ", "))
+			}
+			return nil
+		},
+	}
+
+	benchCmd.Flags().IntVarP(&runs, "runs", "n", 3, "Run each benchmark this many times")
+	benchCmd.Flags().StringVar(&repoPath, "repo", ".", "Run against the repository at this path")
+	benchCmd.Flags().StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile of the runs to this file")
+	benchCmd.Flags().StringVar(&memProfile, "memprofile", "", "Write a memory profile of the runs to this file")
+	benchCmd.Flags().BoolVarP(&list, "list", "l", false, "List the benchmarks and their budgets")
+	return benchCmd
+}
+
+// writeMemProfile writes a pprof profile of the memory allocated so far to
+// path, as go test -memprofile does.
+func writeMemProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// Collect first so the profile covers every allocation up to now.
+	runtime.GC()
+	return pprof.Lookup("allocs").WriteTo(file, 0)
+}
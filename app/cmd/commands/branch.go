@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// BranchCommand creates the `branch` command.
+func BranchCommand() *cobra.Command {
+	var all, remotes, force, track, noTrack, unsetUpstream bool
+	var move, moveForce, copyBranch, copyForce bool
+	var verbose int
+	var setUpstream string
+	var contains, merged, noMerged []string
+
+	branchCmd := &cobra.Command{
+		Use:   "branch [<branchname> [<start-point>]] | (-m | -M | -c | -C) [<oldbranch>] <newbranch> | [--contains <commit>] [--merged <commit>] [--no-merged <commit>]",
+		Short: "List, create, rename, copy or set up tracking for branches",
+		Args:  cobra.MaximumNArgs(2),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			filter, err := cmd.NewCommitFilter(repo, contains, merged, noMerged)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case !filter.Empty():
+				if len(args) > 0 {
+					return fmt.Errorf("options '--contains', '--merged' and '--no-merged' are only allowed when listing branches")
+				}
+			case move || moveForce || copyBranch || copyForce:
+				if len(args) == 0 {
+					return fmt.Errorf("branch name required")
+				}
+				oldName, err := targetBranch(repo, args[:len(args)-1])
+				if err != nil {
+					return err
+				}
+				newName := args[len(args)-1]
+				if copyBranch || copyForce {
+					return cmd.CopyBranch(repo, oldName, newName, copyForce || force)
+				}
+				return cmd.RenameBranch(repo, oldName, newName, moveForce || force)
+
+			case command.Flags().Changed("set-upstream-to"):
+				branch, err := targetBranch(repo, args)
+				if err != nil {
+					return err
+				}
+				upstreamRef, err := cmd.ExpandRefName(repo, setUpstream)
+				if err != nil {
+					return fmt.Errorf("the requested upstream branch '%s' does not exist", setUpstream)
+				}
+				upstream, err := cmd.SetBranchUpstream(repo, branch, upstreamRef)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("branch '%s' set up to track '%s'.\n", branch, upstream.ShortName())
+				return nil
+
+			case unsetUpstream:
+				branch, err := targetBranch(repo, args)
+				if err != nil {
+					return err
+				}
+				if _, ok := cmd.BranchUpstream(repo, branch); !ok {
+					return fmt.Errorf("branch '%s' has no upstream information", branch)
+				}
+				return cmd.UnsetBranchUpstream(repo, branch)
+
+			case len(args) > 0:
+				// Like git, start from the current branch by name, which
+				// is how the reflog of the new branch names it.
+				start := cmd.HeadFile
+				if current, ok := cmd.CurrentBranch(repo); ok {
+					start = current
+				}
+				if len(args) > 1 {
+					start = args[1]
+				}
+				mode := cmd.TrackAuto
+				if track {
+					mode = cmd.TrackAlways
+				} else if noTrack {
+					mode = cmd.TrackNever
+				}
+
+				upstream, err := cmd.CreateBranch(repo, args[0], start, cmd.CreateBranchOptions{Force: force, Track: mode})
+				if err != nil {
+					return err
+				}
+				if upstream != nil {
+					fmt.Printf("branch '%s' set up to track '%s'.\n", args[0], upstream.ShortName())
+				}
+				return nil
+			}
+
+			return listBranches(repo, all, remotes, verbose, filter)
+		},
+	}
+
+	branchCmd.Flags().BoolVarP(&all, "all", "a", false, "List both local and remote-tracking branches")
+	branchCmd.Flags().BoolVarP(&remotes, "remotes", "r", false, "List remote-tracking branches")
+	branchCmd.Flags().CountVarP(&verbose, "verbose", "v",
+		"Show the commit of each branch; twice to also name the upstream")
+	branchCmd.Flags().BoolVarP(&force, "force", "f", false, "Reset the branch if it already exists")
+	branchCmd.Flags().BoolVarP(&move, "move", "m", false, "Rename a branch with its reflog and config")
+	branchCmd.Flags().BoolVarP(&moveForce, "move-force", "M", false, "Rename a branch even if the new name exists")
+	branchCmd.Flags().BoolVarP(&copyBranch, "copy", "c", false, "Copy a branch with its reflog and config")
+	branchCmd.Flags().BoolVarP(&copyForce, "copy-force", "C", false, "Copy a branch even if the new name exists")
+	branchCmd.Flags().BoolVarP(&track, "track", "t", false, "Set up the start point as the upstream of the new branch")
+	branchCmd.Flags().BoolVar(&noTrack, "no-track", false, "Do not set up an upstream, even if branch.autoSetupMerge says so")
+	branchCmd.Flags().StringVarP(&setUpstream, "set-upstream-to", "u", "", "Make the branch track the given upstream")
+	branchCmd.Flags().BoolVar(&unsetUpstream, "unset-upstream", false, "Remove the upstream information of the branch")
+	branchCmd.Flags().StringArrayVar(&contains, "contains", nil, "List only the branches that contain the given commit")
+	branchCmd.Flags().StringArrayVar(&merged, "merged", nil, "List only the branches merged into the given commit, e.g. HEAD")
+	branchCmd.Flags().StringArrayVar(&noMerged, "no-merged", nil, "List only the branches not merged into the given commit")
+	branchCmd.MarkFlagsMutuallyExclusive("track", "no-track")
+	branchCmd.MarkFlagsMutuallyExclusive("move", "move-force", "copy", "copy-force")
+	return branchCmd
+}
+
+// targetBranch returns the branch named in args, or the current branch.
+func targetBranch(repo *cmd.GitRepository, args []string) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("too many arguments")
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	branch, ok := cmd.CurrentBranch(repo)
+	if !ok {
+		return "", fmt.Errorf("HEAD does not point to a branch")
+	}
+	return branch, nil
+}
+
+// listedBranch is one line of branch listing output.
+type listedBranch struct {
+	name    string
+	sha     string
+	current bool
+	local   string // Short name of a local branch, for the tracking info.
+}
+
+// listBranches prints branches like git branch, with the commit subject and
+// tracking information when verbose is set. Only the branches whose commit
+// passes filter are listed.
+func listBranches(repo *cmd.GitRepository, all, remotes bool, verbose int, filter *cmd.CommitFilter) error {
+	refs, err := cmd.ListRefs(repo)
+	if err != nil {
+		return err
+	}
+
+	head, err := cmd.ResolveHEAD(repo)
+	if err != nil {
+		return err
+	}
+	objects := cmd.NewObjectManager(repo)
+	var branches []listedBranch
+	if head.Detached() && !remotes {
+		branches = append(branches, listedBranch{name: fmt.Sprintf("(HEAD detached at %s)", objects.ShortSHA(head.SHA, 0)), sha: head.SHA, current: true})
+	}
+
+	for _, ref := range refs {
+		switch {
+		case strings.HasPrefix(ref.Name, cmd.BranchesPrefix) && !remotes:
+			name := strings.TrimPrefix(ref.Name, cmd.BranchesPrefix)
+			branches = append(branches, listedBranch{name: name, sha: ref.SHA, current: ref.Name == head.Ref, local: name})
+		case strings.HasPrefix(ref.Name, "refs/remotes/") && (all || remotes):
+			name := strings.TrimPrefix(ref.Name, "refs/remotes/")
+			if all {
+				name = "remotes/" + name
+			}
+			if ref.Symbolic {
+				target, _ := cmd.ReadSymbolicRef(repo, ref.Name)
+				name += " -> " + strings.TrimPrefix(target, "refs/remotes/")
+			}
+			branches = append(branches, listedBranch{name: name, sha: ref.SHA})
+		}
+	}
+
+	if !filter.Empty() {
+		var kept []listedBranch
+		for _, branch := range branches {
+			commit, objType, err := objects.PeelObject(branch.sha)
+			if err != nil {
+				return err
+			}
+			if objType != cmd.CommitType {
+				continue
+			}
+			ok, err := filter.Match(commit)
+			if err != nil {
+				return err
+			}
+			if ok {
+				kept = append(kept, branch)
+			}
+		}
+		branches = kept
+	}
+
+	width := 0
+	for _, branch := range branches {
+		width = max(width, len(branch.name))
+	}
+
+	for _, branch := range branches {
+		marker := " "
+		if branch.current {
+			marker = "*"
+		}
+		if verbose == 0 || strings.Contains(branch.name, " -> ") {
+			fmt.Printf("%s %s\n", marker, branch.name)
+			continue
+		}
+
+		tracking := ""
+		if branch.local != "" {
+			tracking, err = trackingSummary(repo, branch.local, verbose > 1)
+			if err != nil {
+				return err
+			}
+		}
+
+		subject := ""
+		if commit, err := objects.ReadCommit(branch.sha); err == nil {
+			subject = commitSubject(commit)
+		}
+		fmt.Printf("%s %-*s %s %s%s\n", marker, width, branch.name, objects.ShortSHA(branch.sha, 0), tracking, subject)
+	}
+	return nil
+}
+
+// trackingSummary renders the "[origin/main: ahead 1, behind 2] " part of a
+// verbose branch listing. The upstream is only named when withName is set.
+func trackingSummary(repo *cmd.GitRepository, branch string, withName bool) (string, error) {
+	upstream, ahead, behind, gone, err := cmd.TrackingStatus(repo, branch)
+	if err != nil || upstream == nil {
+		return "", err
+	}
+
+	var counts []string
+	if gone {
+		counts = append(counts, "gone")
+	}
+	if ahead > 0 {
+		counts = append(counts, fmt.Sprintf("ahead %d", ahead))
+	}
+	if behind > 0 {
+		counts = append(counts, fmt.Sprintf("behind %d", behind))
+	}
+
+	parts := strings.Join(counts, ", ")
+	if withName {
+		if parts == "" {
+			parts = upstream.ShortName()
+		} else {
+			parts = upstream.ShortName() + ": " + parts
+		}
+	}
+	if parts == "" {
+		return "", nil
+	}
+	return "[" + parts + "] ", nil
+}
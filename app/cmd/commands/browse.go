@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// BrowseCommand creates the `browse` command.
+func BrowseCommand() *cobra.Command {
+	var addr string
+
+	browseCmd := &cobra.Command{
+		Use:   "browse [--listen <addr>]",
+		Short: "Browse the log, trees, files and diffs of the repository in a web browser",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			return cmd.RunBrowser(addr, repo, log.New(os.Stderr, "justdoit-browse: ", log.LstdFlags))
+		},
+	}
+
+	browseCmd.Flags().StringVar(&addr, "listen", "127.0.0.1:1234", "Address to serve the browser on; keep it on localhost")
+	return browseCmd
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+	"golang.org/x/term"
+)
+
+// CatFileCommand creates the `cat-file` command.
+func CatFileCommand() *cobra.Command {
+	var showType, showSize, exists, pretty, allowUnknownType bool
+	var batch, batchCheck, followSymlinks bool
+
+	catFileCmd := &cobra.Command{
+		Use:   "cat-file (-t | -s | -e | -p | <type>) <object> | (--batch | --batch-check) [--follow-symlinks]",
+		Short: "Provide the content, type or size of repository objects",
+		Long: `Provide the content, type or size of repository objects.
+
+With --batch or --batch-check, object names are read from standard input,
+one per line, and each is answered with "<sha> <type> <size>", followed for
+--batch by the content of the object and a newline, or "<object> missing".
+
+With --follow-symlinks, names of the form <tree-ish>:<path> follow the
+symlinks of the tree on the way to path, as a checkout would. Where that
+fails, the answer is "dangling", "loop" or "notdir" with the length of the
+name, followed by the name on a line of its own, or "symlink" with the
+length of the path a symlink leads to outside the tree, followed by that
+path.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(command *cobra.Command, args []string) error {
+			modes := 0
+			for _, set := range []bool{showType, showSize, exists, pretty, batch, batchCheck} {
+				if set {
+					modes++
+				}
+			}
+			if followSymlinks && !batch && !batchCheck {
+				return fmt.Errorf("'--follow-symlinks' requires a batch mode")
+			}
+			if batch || batchCheck {
+				if modes > 1 || len(args) > 0 {
+					return fmt.Errorf("--batch and --batch-check take no other mode and no arguments")
+				}
+			} else if (modes == 0) != (len(args) == 2) || modes > 1 || len(args) == 0 {
+				return fmt.Errorf("give exactly one of -t, -s, -e, -p or an object type")
+			}
+			if allowUnknownType && !showType && !showSize {
+				return fmt.Errorf("--allow-unknown-type: use with -s or -t")
+			}
+
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			objects := cmd.NewObjectManager(repo).UseReplaceRefs()
+			if allowUnknownType {
+				objects.AllowUnknownType()
+			}
+			if batch || batchCheck {
+				return catFileBatch(repo, objects, batch, followSymlinks)
+			}
+			rev := args[len(args)-1]
+
+			sha, err := cmd.ResolveRevision(repo, rev)
+			if exists {
+				// Like git, -e only answers through the exit status.
+				if err != nil || !objects.Has(sha) {
+					os.Exit(1)
+				}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			objType, size, err := objects.StatObject(sha)
+			if err != nil {
+				return err
+			}
+			switch {
+			case showType:
+				fmt.Println(objType)
+				return nil
+			case showSize:
+				fmt.Println(size)
+				return nil
+			case pretty:
+				return prettyPrintObject(objects, sha, objType)
+			}
+
+			sha, err = objects.PeelToType(sha, cmd.GitObjectType(args[0]))
+			if err != nil {
+				return fmt.Errorf("%s: bad file", rev)
+			}
+			return writeObject(objects, sha)
+		},
+	}
+
+	catFileCmd.Flags().BoolVarP(&showType, "type", "t", false, "Show the object type")
+	catFileCmd.Flags().BoolVarP(&showSize, "size", "s", false, "Show the object size")
+	catFileCmd.Flags().BoolVarP(&exists, "exists", "e", false, "Exit with zero status if the object exists")
+	catFileCmd.Flags().BoolVarP(&pretty, "pretty", "p", false, "Pretty-print the object content based on its type")
+	catFileCmd.Flags().BoolVar(&allowUnknownType, "allow-unknown-type", false, "Allow -t and -s on objects of unknown type")
+	catFileCmd.Flags().BoolVar(&batch, "batch", false, "Print the type, size and content of each object named on standard input")
+	catFileCmd.Flags().BoolVar(&batchCheck, "batch-check", false, "Print the type and size of each object named on standard input")
+	catFileCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symlinks inside the tree when resolving <tree-ish>:<path>")
+	return catFileCmd
+}
+
+// catFileBatch answers the object names read from stdin, one per line,
+// with their type and size and, with contents set, their content.
+func catFileBatch(repo *cmd.GitRepository, objects *cmd.ObjectManager, contents, followSymlinks bool) error {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		name := scanner.Text()
+		var found cmd.FollowedPath
+		var err error
+		if followSymlinks {
+			found, err = cmd.ResolveRevisionFollowingSymlinks(repo, name)
+		} else {
+			found.Entry.SHA, err = cmd.ResolveRevision(repo, name)
+		}
+
+		switch {
+		case err != nil || found.Status == cmd.PathMissing:
+			fmt.Fprintf(out, "%s missing\n", name)
+		case found.Status == cmd.PathOutside:
+			fmt.Fprintf(out, "%s %d\n%s\n", found.Status, len(found.Target), found.Target)
+		case found.Status != cmd.PathFound:
+			fmt.Fprintf(out, "%s %d\n%s\n", found.Status, len(name), name)
+		default:
+			sha := found.Entry.SHA
+			objType, size, err := objects.StatObject(sha)
+			if err != nil {
+				fmt.Fprintf(out, "%s missing\n", name)
+				break
+			}
+			fmt.Fprintf(out, "%s %s %d\n", sha, objType, size)
+			if contents {
+				if _, err := objects.StreamObject(sha, out); err != nil {
+					return err
+				}
+				out.WriteString("\n")
+			}
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// prettyPrintObject prints an object for a human with the pretty-printer
+// of its kind, e.g. trees as a listing like ls-tree. Kinds without one are
+// printed as their raw content.
+func prettyPrintObject(objects *cmd.ObjectManager, sha string, objType cmd.GitObjectType) error {
+	kind, ok := cmd.LookupObjectType(objType)
+	if !ok || kind.Pretty == nil {
+		return writeObject(objects, sha)
+	}
+
+	_, data, err := objects.ReadObject(sha)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	return kind.Pretty(out, objects, data)
+}
+
+// writeObject copies the content of an object to stdout byte for byte.
+// Content going to a pipe or file is streamed. Binary content headed for a
+// terminal, where it would garble the display, is only written once the
+// user agrees.
+func writeObject(objects *cmd.ObjectManager, sha string) error {
+	if !isTerminal(os.Stdout) {
+		_, err := objects.StreamObject(sha, os.Stdout)
+		return err
+	}
+
+	_, data, err := objects.ReadObject(sha)
+	if err != nil {
+		return err
+	}
+	if cmd.IsBinary(data) && isTerminal(os.Stdin) {
+		fmt.Fprintf(os.Stderr, "Object %s is binary and may garble the terminal. Print it anyway? [y/N] ", sha)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			return nil
+		}
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// isTerminal reports whether file is a terminal rather than a pipe, a file
+// or another character device such as /dev/null.
+func isTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
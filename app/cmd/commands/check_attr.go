@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// CheckAttrCommand creates the `check-attr` command.
+func CheckAttrCommand() *cobra.Command {
+	var all, cached, stdin, nullTerminated bool
+
+	checkAttrCmd := &cobra.Command{
+		Use:   "check-attr [-a | --all | <attr>...] [--] <pathname>...",
+		Short: "Show gitattributes information",
+		Long: `Show gitattributes information.
+
+For each path, prints "<path>: <attribute>: <info>", where info is "set",
+"unset", "unspecified" or the value of the attribute. Without "--" the first
+argument is the attribute and the others are paths; with --all every
+attribute specified for the path is listed.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			attrs, paths, err := splitCheckAttrArgs(args, command.ArgsLenAtDash(), all, stdin)
+			if err != nil {
+				return err
+			}
+
+			matcher := cmd.NewAttributeMatcher(repo)
+			if cached {
+				index, err := cmd.ReadIndex(repo)
+				if err != nil {
+					return err
+				}
+				matcher = cmd.NewIndexAttributeMatcher(repo, index)
+			}
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+			check := func(file string) error {
+				return checkAttr(out, repo, matcher, file, attrs, all, nullTerminated)
+			}
+
+			for _, file := range paths {
+				if err := check(file); err != nil {
+					return err
+				}
+			}
+			if !stdin {
+				return nil
+			}
+			return readStdinPaths(nullTerminated, func(file string) error {
+				if err := check(file); err != nil {
+					return err
+				}
+				// Callers feeding paths one by one wait for each answer.
+				return out.Flush()
+			})
+		},
+	}
+
+	checkAttrCmd.Flags().BoolVarP(&all, "all", "a", false, "List all attributes that are associated with the paths")
+	checkAttrCmd.Flags().BoolVar(&cached, "cached", false, "Consider .gitattributes in the index only, ignoring the worktree")
+	checkAttrCmd.Flags().BoolVar(&stdin, "stdin", false, "Read paths from stdin, one per line, instead of the command line")
+	checkAttrCmd.Flags().BoolVarP(&nullTerminated, "null", "z", false, "Separate input paths and output fields with NUL")
+	return checkAttrCmd
+}
+
+// splitCheckAttrArgs splits the arguments of check-attr into attributes
+// and paths like git: before "--" are attributes, and without it only the
+// first argument is, or all of them with --stdin.
+func splitCheckAttrArgs(args []string, dash int, all, stdin bool) ([]string, []string, error) {
+	var attrs, paths []string
+	switch {
+	case all:
+		if dash >= 1 {
+			return nil, nil, fmt.Errorf("attributes and --all both specified")
+		}
+		paths = args
+	case dash == 0 || len(args) == 0:
+		return nil, nil, fmt.Errorf("no attribute specified")
+	case dash > 0:
+		attrs, paths = args[:dash], args[dash:]
+	case stdin:
+		attrs = args
+	default:
+		attrs, paths = args[:1], args[1:]
+	}
+
+	if stdin && len(paths) > 0 {
+		return nil, nil, fmt.Errorf("can't specify files with --stdin")
+	}
+	if !stdin && len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no file specified")
+	}
+	return attrs, paths, nil
+}
+
+// checkAttr prints the requested attributes of one path, or all of its
+// attributes.
+func checkAttr(out io.Writer, repo *cmd.GitRepository, matcher *cmd.AttributeMatcher, file string, attrs []string, all, nullTerminated bool) error {
+	name := path.Clean(file)
+	if repo.WorkTree != "" {
+		if name = worktreeRelative(repo, file); name == "" {
+			return fmt.Errorf("'%s' is outside repository", file)
+		}
+	}
+
+	var results []cmd.Attribute
+	if all {
+		results = matcher.AllAttributes(name)
+	} else {
+		for _, attr := range attrs {
+			results = append(results, cmd.Attribute{Name: attr, Value: matcher.Get(name, attr)})
+		}
+	}
+
+	for _, result := range results {
+		if nullTerminated {
+			fmt.Fprintf(out, "%s\x00%s\x00%s\x00", file, result.Name, result.Value)
+		} else {
+			fmt.Fprintf(out, "%s: %s: %s\n", cmd.QuotePath(file, cmd.QuotePathEnabled(repo)), result.Name, result.Value)
+		}
+	}
+	return nil
+}
+
+// readStdinPaths calls fn for each path read from stdin, one per line or,
+// with nullTerminated, separated by NUL.
+func readStdinPaths(nullTerminated bool, fn func(string) error) error {
+	separator := byte('\n')
+	if nullTerminated {
+		separator = 0
+	}
+	input := bufio.NewScanner(os.Stdin)
+	input.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, separator); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	for input.Scan() {
+		if err := fn(input.Text()); err != nil {
+			return err
+		}
+	}
+	return input.Err()
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// CheckMailmapCommand creates the `check-mailmap` command.
+func CheckMailmapCommand() *cobra.Command {
+	var stdin bool
+
+	checkMailmapCmd := &cobra.Command{
+		Use:   "check-mailmap [--stdin] <contact>...",
+		Short: "Show canonical names and email addresses of contacts",
+		Long: `Show canonical names and email addresses of contacts.
+
+Each contact, "Name <user@host>" or "<user@host>", is mapped through .mailmap,
+mailmap.blob and mailmap.file and printed; contacts without a mapping are
+printed as they are.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			if !stdin && len(args) == 0 {
+				return fmt.Errorf("no contacts specified")
+			}
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			mailmap, err := cmd.ReadMailmap(repo)
+			if err != nil {
+				return err
+			}
+
+			for _, contact := range args {
+				mapped, err := mailmap.MapContact(contact)
+				if err != nil {
+					return err
+				}
+				fmt.Println(mapped)
+			}
+			if !stdin {
+				return nil
+			}
+			lines := bufio.NewScanner(os.Stdin)
+			for lines.Scan() {
+				mapped, err := mailmap.MapContact(lines.Text())
+				if err != nil {
+					return err
+				}
+				fmt.Println(mapped)
+			}
+			return lines.Err()
+		},
+	}
+
+	checkMailmapCmd.Flags().BoolVar(&stdin, "stdin", false, "Also read contacts, one per line, from stdin")
+	return checkMailmapCmd
+}
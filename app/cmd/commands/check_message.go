@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// CheckMessageCommand creates the `check-message` command.
+func CheckMessageCommand() *cobra.Command {
+	var maxLength int
+	var denyWords, requireTrailers []string
+
+	checkMessageCmd := &cobra.Command{
+		Use:   "check-message [--subject-max-length <n>] [--deny-word <word>...] [--require-trailer <token>...] [<file>]",
+		Short: "Check a commit message against the rules of the repository",
+		Long: `Check a commit message against the rules of the repository.
+
+The message is read from the given file, or from stdin without one or with -.
+It fails, listing every broken rule, when the subject is longer than
+checkMessage.subjectMaxLength characters (72 by default), is not followed by a
+blank line, or starts with one of the words in checkMessage.denyWords (past
+tenses such as "Added" and "Fixed" by default), or when a trailer named in
+checkMessage.requireTrailers is missing.
+
+To check every commit, call it from the commit-msg hook:
+
+    #!/bin/sh
+    exec justdoit check-message "$1"`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			// Like stripspace, this works outside of a repository too.
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				repo = nil
+			}
+			rules, err := cmd.LoadMessageRules(repo)
+			if err != nil {
+				return err
+			}
+			flags := command.Flags()
+			if flags.Changed("subject-max-length") {
+				rules.SubjectMaxLength = max(maxLength, 0)
+			}
+			if flags.Changed("deny-word") {
+				rules.DenyWords = denyWords
+			}
+			if flags.Changed("require-trailer") {
+				rules.RequireTrailers = requireTrailers
+			}
+
+			var message []byte
+			if len(args) == 0 || args[0] == "-" {
+				message, err = io.ReadAll(os.Stdin)
+			} else {
+				message, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			problems, err := cmd.CheckMessage(repo, message, rules)
+			if err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				for _, problem := range problems {
+					fmt.Fprintf(os.Stderr, "error: %s\n", problem)
+				}
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+
+	flags := checkMessageCmd.Flags()
+	flags.IntVar(&maxLength, "subject-max-length", 0, "Refuse subjects longer than this many characters; 0 for no limit")
+	flags.StringArrayVar(&denyWords, "deny-word", nil, "Refuse subjects starting with this word, instead of checkMessage.denyWords")
+	flags.StringArrayVar(&requireTrailers, "require-trailer", nil, "Require a trailer with this token, instead of checkMessage.requireTrailers")
+	return checkMessageCmd
+}
@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// CheckoutCommand creates the `checkout` command.
+func CheckoutCommand() *cobra.Command {
+	var opts cmd.CheckoutOptions
+	var quiet, progress, noProgress bool
+
+	checkoutCmd := &cobra.Command{
+		Use:   "checkout [-f] <branch>|<commit> | [<tree-ish>] [--] <path>...",
+		Short: "Switch branches, or detach HEAD at a commit",
+		Long: `Switch branches, or detach HEAD at a commit.
+
+Given paths, HEAD stays where it is and the files under the paths are
+restored instead: from the tree-ish into the index and the worktree, or
+without one from the index into the worktree.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			dash := command.ArgsLenAtDash()
+			if len(args) > 1 || dash == 0 {
+				return checkoutPaths(repo, args, dash, quiet)
+			}
+
+			before, err := cmd.ResolveHEAD(repo)
+			if err != nil {
+				return err
+			}
+			if progress || (!quiet && !noProgress && isTerminal(os.Stderr)) {
+				opts.Progress = os.Stderr
+			}
+			undo, err := cmd.SnapshotForUndo(repo, "checkout", true)
+			if err != nil {
+				return err
+			}
+			result, err := cmd.Checkout(repo, args[0], opts)
+			if err != nil {
+				return err
+			}
+			if result.Refused() {
+				printCheckoutRefusal(result)
+				return fmt.Errorf("checkout aborted")
+			}
+			if err := undo.Record(); err != nil {
+				return err
+			}
+			if quiet {
+				return nil
+			}
+
+			objects := cmd.NewObjectManager(repo)
+			if result.Branch == "" {
+				if before.Detached() && before.SHA != result.Commit {
+					if commit, err := objects.ReadCommit(before.SHA); err == nil {
+						fmt.Fprintf(os.Stderr, "Previous HEAD position was %s %s\n", objects.ShortSHA(before.SHA, 0), commitSubject(commit))
+					}
+				}
+				commit, err := objects.ReadCommit(result.Commit)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "HEAD is now at %s %s\n", objects.ShortSHA(result.Commit, 0), commitSubject(commit))
+				return nil
+			}
+
+			if before.Branch() == result.Branch {
+				fmt.Fprintf(os.Stderr, "Already on '%s'\n", result.Branch)
+			} else {
+				fmt.Fprintf(os.Stderr, "Switched to branch '%s'\n", result.Branch)
+			}
+			report := &cmd.StatusReport{Branch: result.Branch}
+			report.Upstream, report.Ahead, report.Behind, report.UpstreamGone, err = cmd.TrackingStatus(repo, result.Branch)
+			if err != nil {
+				return err
+			}
+			if line := trackingLine(report); line != "" {
+				fmt.Println(line)
+			}
+			return nil
+		},
+	}
+
+	flags := checkoutCmd.Flags()
+	flags.BoolVarP(&opts.Force, "force", "f", false, "Throw away local changes and untracked files in the way")
+	flags.BoolVarP(&quiet, "quiet", "q", false, "Only report errors")
+	flags.BoolVar(&progress, "progress", false, "Show progress while files are updated, even when stderr is not a terminal")
+	flags.BoolVar(&noProgress, "no-progress", false, "Never show progress")
+	return checkoutCmd
+}
+
+// checkoutPaths runs checkout [<tree-ish>] [--] <path>.... Like git, it
+// reports how many files it updated only without "--", where the first
+// argument might have been meant as a path.
+func checkoutPaths(repo *cmd.GitRepository, args []string, dash int, quiet bool) error {
+	rev, specs := args[0], args[1:]
+	switch {
+	case dash == 0:
+		rev, specs = "", args
+	case dash > 1:
+		return fmt.Errorf("only one reference expected, %d given", dash)
+	}
+	var paths []string
+	for _, spec := range specs {
+		path := worktreeRelative(repo, spec)
+		if path == "" {
+			return fmt.Errorf("'%s' is outside repository", spec)
+		}
+		paths = append(paths, path)
+	}
+
+	undo, err := cmd.SnapshotForUndo(repo, "checkout", true)
+	if err != nil {
+		return err
+	}
+	result, err := cmd.CheckoutPaths(repo, rev, paths)
+	if err != nil {
+		return err
+	}
+	if err := undo.Record(); err != nil {
+		return err
+	}
+	if quiet || dash >= 0 {
+		return nil
+	}
+	noun := "paths"
+	if result.Updated == 1 {
+		noun = "path"
+	}
+	from := "the index"
+	if result.Tree != "" {
+		from = cmd.NewObjectManager(repo).ShortSHA(result.Tree, 0)
+	}
+	fmt.Fprintf(os.Stderr, "Updated %d %s from %s\n", result.Updated, noun, from)
+	return nil
+}
+
+// printCheckoutRefusal lists the paths that stopped a checkout, as git
+// does.
+func printCheckoutRefusal(result *cmd.CheckoutResult) {
+	if len(result.Modified) > 0 {
+		fmt.Fprintln(os.Stderr, "error: Your local changes to the following files would be overwritten by checkout:")
+		for _, path := range result.Modified {
+			fmt.Fprintf(os.Stderr, "\t%s\n", path)
+		}
+		fmt.Fprintln(os.Stderr, "Please commit your changes or stash them before you switch branches.")
+	}
+	if len(result.Untracked) > 0 {
+		fmt.Fprintln(os.Stderr, "error: The following untracked working tree files would be overwritten by checkout:")
+		for _, path := range result.Untracked {
+			fmt.Fprintf(os.Stderr, "\t%s\n", path)
+		}
+		fmt.Fprintln(os.Stderr, "Please move or remove them before you switch branches.")
+	}
+	fmt.Fprintln(os.Stderr, "Use -f to discard them and check out anyway.")
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// CloneCommand creates the `clone` command.
+func CloneCommand() *cobra.Command {
+	var opts cmd.CloneOptions
+
+	cloneCmd := &cobra.Command{
+		Use:   "clone [--filter=<spec>] <repository> [<directory>]",
+		Short: "Copy a repository into a new directory",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(command *cobra.Command, args []string) error {
+			url := args[0]
+			dir := cmd.DefaultCloneDir(url, opts.Bare || opts.Mirror)
+			if len(args) > 1 {
+				dir = args[1]
+			}
+
+			if opts.Bare || opts.Mirror {
+				fmt.Fprintf(os.Stderr, "Cloning into bare repository '%s'...\n", dir)
+			} else {
+				fmt.Fprintf(os.Stderr, "Cloning into '%s'...\n", dir)
+			}
+			result, err := cmd.Clone(url, dir, opts)
+			if err != nil {
+				return err
+			}
+			if len(result.Fetch.Refs) == 0 {
+				fmt.Fprintln(os.Stderr, "warning: You appear to have cloned an empty repository.")
+			}
+			return nil
+		},
+	}
+
+	flags := cloneCmd.Flags()
+	flags.BoolVar(&opts.Bare, "bare", false, "Make a bare repository holding the branches of the remote")
+	flags.BoolVar(&opts.Mirror, "mirror", false, "Make a bare repository mirroring every ref of the remote, and keep it that way on fetch and push")
+	flags.StringVarP(&opts.Origin, "origin", "o", "", "Name the remote <name> instead of origin")
+	flags.StringVarP(&opts.UploadPack, "upload-pack", "u", "", "Path of the upload-pack program on the remote host")
+	flags.StringVarP(&opts.Branch, "branch", "b", "", "Start on this branch, or detached at this tag, instead of the remote HEAD")
+	flags.BoolVar(&opts.SingleBranch, "single-branch", false, "Fetch only the history of the branch being checked out, now and later")
+	flags.StringVar(&opts.Filter, "filter", "", "Make a partial clone, leaving out the objects the filter spec selects, e.g. blob:none")
+	return cloneCmd
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// CommitCommand creates the `commit` command.
+func CommitCommand() *cobra.Command {
+	var opts cmd.CommitOptions
+	var messages []string
+	var file string
+	var edit, noEdit, quiet, only bool
+
+	commitCmd := &cobra.Command{
+		Use:   "commit [-a | -i | -o] [<path>...]",
+		Short: "Record the staged changes as a new commit",
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			if opts.Include && only {
+				return fmt.Errorf("options '--include' and '--only' cannot be used together")
+			}
+			for _, arg := range args {
+				path := worktreeRelative(repo, arg)
+				if path == "" {
+					return fmt.Errorf("'%s' is outside repository", arg)
+				}
+				opts.Paths = append(opts.Paths, path)
+			}
+
+			switch {
+			case len(messages) > 0 && file != "":
+				return fmt.Errorf("options '-m' and '-F' cannot be used together")
+			case len(messages) > 0:
+				opts.Message = []byte(strings.Join(messages, "\n\n"))
+			case file == "-":
+				if opts.Message, err = io.ReadAll(os.Stdin); err != nil {
+					return err
+				}
+			case file != "":
+				if opts.Message, err = os.ReadFile(file); err != nil {
+					return fmt.Errorf("could not read log file '%s': %w", file, err)
+				}
+			}
+			// Like git, the editor opens when no message is given, except
+			// for a fixup, whose message is complete without one.
+			opts.Edit = edit || (opts.Message == nil && opts.Fixup == "" && !noEdit)
+			opts.Warnings, opts.HookOutput = os.Stderr, os.Stderr
+
+			undo, err := cmd.SnapshotForUndo(repo, "commit", false)
+			if err != nil {
+				return err
+			}
+			result, err := cmd.CreateCommit(repo, opts)
+			if err != nil {
+				return err
+			}
+			objects := cmd.NewObjectManager(repo)
+			if opts.DryRun || result.Empty {
+				// Like git, show what would be committed, and fail when
+				// that is nothing.
+				if result.Empty && opts.Amend && !opts.DryRun {
+					fmt.Fprint(os.Stderr, amendEmptyHelp)
+				} else {
+					report, err := cmd.Status(repo)
+					if err != nil {
+						return err
+					}
+					printStatus(objects, report, cmd.QuotePathEnabled(repo))
+				}
+				if result.Empty {
+					os.Exit(1)
+				}
+				return nil
+			}
+			if err := undo.Record(); err != nil {
+				return err
+			}
+			// Like git, record how the conflicts that led here were resolved.
+			if cmd.RerereEnabled(repo) {
+				rerere, err := cmd.Rerere(repo)
+				if err != nil {
+					return err
+				}
+				printRerere(rerere)
+			}
+			if quiet {
+				return cmd.AutoMaintenance(repo, io.Discard)
+			}
+			if err := cmd.AutoMaintenance(repo, os.Stderr); err != nil {
+				return err
+			}
+
+			return printCommitSummary(repo, objects, result, opts.Amend && !opts.ResetAuthor)
+		},
+	}
+
+	flags := commitCmd.Flags()
+	flags.StringArrayVarP(&messages, "message", "m", nil, "Use the given message; several are joined as paragraphs")
+	flags.StringVarP(&file, "file", "F", "", "Take the message from the given file, or - for stdin")
+	flags.BoolVarP(&edit, "edit", "e", false, "Edit the message even when it was given")
+	flags.BoolVar(&noEdit, "no-edit", false, "Use the message as it is, e.g. that of the amended commit")
+	flags.BoolVar(&opts.Amend, "amend", false, "Replace the tip of the current branch with a new commit")
+	flags.BoolVar(&opts.ResetAuthor, "reset-author", false, "With --amend, become the author of the commit, with a new date")
+	flags.StringVar(&opts.Fixup, "fixup", "", "Make a fixup! commit for the given commit, for rebase --autosquash")
+	flags.StringVar(&opts.Squash, "squash", "", "Make a squash! commit for the given commit, for rebase --autosquash")
+	flags.BoolVarP(&opts.Signoff, "signoff", "s", false, "Add a Signed-off-by trailer for the committer at the end of the message")
+	flags.BoolVarP(&opts.All, "all", "a", false, "Stage every tracked file that changed or was deleted first")
+	flags.BoolVarP(&opts.Include, "include", "i", false, "Stage the given paths and commit them along with the index")
+	flags.BoolVarP(&only, "only", "o", false, "Commit only the given paths, leaving other staged changes out (the default with paths)")
+	flags.BoolVar(&opts.AllowEmpty, "allow-empty", false, "Commit even when the tree is the same as that of the parent")
+	flags.BoolVar(&opts.AllowEmptyMessage, "allow-empty-message", false, "Commit even when the message is empty")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "Only show what would be committed")
+	flags.BoolVarP(&opts.NoVerify, "no-verify", "n", false, "Do not run the commit-msg hook")
+	flags.BoolVarP(&quiet, "quiet", "q", false, "Do not print the summary of the commit")
+	return commitCmd
+}
+
+// amendEmptyHelp explains why an amend that changes nothing was refused.
+const amendEmptyHelp = `You asked to amend the most recent commit, but doing so would make
+it empty. You can repeat your command with --allow-empty.
+`
+
+// printCommitSummary describes a new commit as git does: its branch, name
+// and subject, its author when that is not the committer, its author date
+// when it was kept from an amended commit, and, unless it is a merge, what
+// it changed compared to its parent.
+func printCommitSummary(repo *cmd.GitRepository, objects *cmd.ObjectManager, result *cmd.CommitResult, showDate bool) error {
+	where := result.Branch
+	if where == "" {
+		where = "detached HEAD"
+	}
+	if result.Root {
+		where += " (root-commit)"
+	}
+	fmt.Printf("[%s %s] %s\n", where, objects.ShortSHA(result.SHA, 0), result.Subject)
+
+	commit, err := objects.ReadCommit(result.SHA)
+	if err != nil {
+		return err
+	}
+	author, committer := cmd.ParseSignature(commit.Author), cmd.ParseSignature(commit.Committer)
+	if author.Name != committer.Name || author.Email != committer.Email {
+		fmt.Printf(" Author: %s <%s>\n", author.Name, author.Email)
+	}
+	if showDate {
+		fmt.Printf(" Date: %s\n", author.When.Format(gitDateLayout))
+	}
+	if len(commit.Parents) > 1 {
+		return nil
+	}
+
+	oldFiles := map[string]cmd.TreeEntry{}
+	if len(commit.Parents) > 0 {
+		parent, err := objects.ReadCommit(commit.Parents[0])
+		if err != nil {
+			return err
+		}
+		if oldFiles, err = objects.FlattenTree(parent.Tree); err != nil {
+			return err
+		}
+	}
+	newFiles, err := objects.FlattenTree(commit.Tree)
+	if err != nil {
+		return err
+	}
+	changes := cmd.DiffFileSets(oldFiles, newFiles)
+	if len(changes) == 0 {
+		return nil
+	}
+	patches, err := objects.Patches(changes, "", 0)
+	if err != nil {
+		return err
+	}
+	fmt.Println(cmd.FormatStatSummary(cmd.DiffStats(patches)))
+
+	printModeChanges(os.Stdout, changes, cmd.QuotePathEnabled(repo))
+	return nil
+}
+
+// printModeChanges lists the files created and deleted and the mode
+// changes below a diffstat, as git does after a commit or merge.
+func printModeChanges(out io.Writer, changes []cmd.TreeChange, quotePath bool) {
+	for _, change := range changes {
+		path := cmd.QuotePath(change.Path, quotePath)
+		switch {
+		case change.Status == cmd.StatusAdded:
+			fmt.Fprintf(out, " create mode %s %s\n", change.New.Mode, path)
+		case change.Status == cmd.StatusDeleted:
+			fmt.Fprintf(out, " delete mode %s %s\n", change.Old.Mode, path)
+		case change.Old.Mode != change.New.Mode:
+			fmt.Fprintf(out, " mode change %s => %s %s\n", change.Old.Mode, change.New.Mode, path)
+		}
+	}
+}
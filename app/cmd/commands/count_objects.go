@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// CountObjectsCommand creates the `count-objects` command.
+func CountObjectsCommand() *cobra.Command {
+	var verbose, human bool
+
+	countObjectsCmd := &cobra.Command{
+		Use:   "count-objects [--verbose] [-H]",
+		Short: "Count the loose objects and their disk consumption",
+		Long: `Count the loose objects and their disk consumption.
+
+With --verbose, the packs are counted too, along with the loose objects that
+are also packed (prune-packable) and the files of the object database that
+are neither objects nor packs (garbage). Sizes are in KiB, or in readable
+units with -H. Automatic maintenance packs the loose objects once there are
+more than gc.auto of them, 6700 by default.`,
+		Args: cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			counts, err := cmd.CountObjects(repo)
+			if err != nil {
+				return err
+			}
+
+			size := func(bytes int64) string {
+				if human {
+					return formatHumanSize(bytes)
+				}
+				return fmt.Sprint(bytes / 1024)
+			}
+			if !verbose {
+				if human {
+					fmt.Printf("%d objects, %s\n", counts.Count, size(counts.Size))
+				} else {
+					fmt.Printf("%d objects, %s kilobytes\n", counts.Count, size(counts.Size))
+				}
+				return nil
+			}
+			for _, path := range counts.Garbage {
+				fmt.Fprintf(os.Stderr, "warning: garbage found: %s\n", path)
+			}
+			fmt.Printf("count: %d\n", counts.Count)
+			fmt.Printf("size: %s\n", size(counts.Size))
+			fmt.Printf("in-pack: %d\n", counts.InPack)
+			fmt.Printf("packs: %d\n", counts.Packs)
+			fmt.Printf("size-pack: %s\n", size(counts.SizePack))
+			fmt.Printf("prune-packable: %d\n", counts.PrunePackable)
+			fmt.Printf("garbage: %d\n", len(counts.Garbage))
+			fmt.Printf("size-garbage: %s\n", size(counts.SizeGarbage))
+			return nil
+		},
+	}
+
+	countObjectsCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Also count the packs and the garbage")
+	countObjectsCmd.Flags().BoolVarP(&human, "human-readable", "H", false, "Print sizes in human readable units")
+	return countObjectsCmd
+}
+
+// formatHumanSize formats a size in bytes the way git does for -H, with
+// two decimals in the largest unit under it.
+func formatHumanSize(bytes int64) string {
+	switch {
+	case bytes > 1<<30:
+		return fmt.Sprintf("%d.%02d GiB", bytes>>30, (bytes&(1<<30-1))/10737419)
+	case bytes > 1<<20:
+		x := bytes + 5243
+		return fmt.Sprintf("%d.%02d MiB", x>>20, (x&(1<<20-1))*100>>20)
+	case bytes > 1<<10:
+		x := bytes + 5
+		return fmt.Sprintf("%d.%02d KiB", x>>10, (x&(1<<10-1))*100>>10)
+	case bytes == 1:
+		return "1 byte"
+	}
+	return fmt.Sprintf("%d bytes", bytes)
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// DaemonCommand creates the `daemon` command.
+func DaemonCommand() *cobra.Command {
+	var opts cmd.DaemonOptions
+	var listen string
+	var port int
+	var timeout int
+
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Serve repositories read-only over the git:// protocol",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			opts.Listen = fmt.Sprintf("%s:%d", listen, port)
+			opts.Timeout = time.Duration(timeout) * time.Second
+			return cmd.RunDaemon(opts, log.New(os.Stderr, "justdoit-daemon: ", log.LstdFlags))
+		},
+	}
+
+	daemonCmd.Flags().StringVar(&opts.BasePath, "base-path", "", "Resolve request paths relative to this directory")
+	daemonCmd.Flags().BoolVar(&opts.ExportAll, "export-all", false, "Serve repositories without a "+cmd.DaemonExportFile+" file")
+	daemonCmd.Flags().IntVar(&opts.MaxConnections, "max-connections", 32, "Maximum simultaneous clients, 0 for unlimited")
+	daemonCmd.Flags().StringVar(&listen, "listen", "", "Address to listen on")
+	daemonCmd.Flags().IntVar(&port, "port", cmd.DefaultDaemonPort, "Port to listen on")
+	daemonCmd.Flags().IntVar(&timeout, "timeout", 0, "Disconnect clients idle for this many seconds")
+	return daemonCmd
+}
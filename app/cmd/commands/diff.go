@@ -0,0 +1,305 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// diffOutput holds the output format options shared by diff, show and log.
+type diffOutput struct {
+	patch      bool
+	noPatch    bool
+	stat       bool
+	numstat    bool
+	shortstat  bool
+	nameOnly   bool
+	nameStatus bool
+	raw        bool
+	nulTerm    bool // -z: NUL terminated, unquoted paths.
+	quotePath  bool // core.quotePath, see setup.
+	context    int
+}
+
+// addFlags registers the diff output flags.
+func (o *diffOutput) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVarP(&o.patch, "patch", "p", false, "Show the patch of each change")
+	flags.BoolVarP(&o.noPatch, "no-patch", "s", false, "Suppress diff output")
+	flags.BoolVar(&o.stat, "stat", false, "Show a diffstat with a histogram of changed lines")
+	flags.BoolVar(&o.numstat, "numstat", false, "Show added and deleted line counts in a machine-readable form")
+	flags.BoolVar(&o.shortstat, "shortstat", false, "Show only the summary line of --stat")
+	flags.BoolVar(&o.nameOnly, "name-only", false, "Show only the names of changed files")
+	flags.BoolVar(&o.nameStatus, "name-status", false, "Show the names and status letters of changed files")
+	flags.BoolVar(&o.raw, "raw", false, "Show modes, object names and status of changed files")
+	flags.IntVarP(&o.context, "unified", "U", cmd.DefaultDiffContext, "Number of context lines around each change")
+	flags.BoolVarP(&o.nulTerm, "null", "z", false,
+		"Separate paths in --raw, --numstat, --name-only and --name-status output with NULs and do not quote them")
+}
+
+// setup reads the settings of repo that affect the output.
+func (o *diffOutput) setup(repo *cmd.GitRepository) {
+	o.quotePath = cmd.QuotePathEnabled(repo)
+}
+
+// path formats a path for the per-file formats: quoted as core.quotePath
+// says, or raw with -z.
+func (o *diffOutput) path(name string) string {
+	if o.nulTerm {
+		return name
+	}
+	return cmd.QuotePath(name, o.quotePath)
+}
+
+// printRecord prints one per-file record, terminated by a newline or, with
+// -z, by a NUL.
+func (o *diffOutput) printRecord(prefix, name string) {
+	if o.nulTerm {
+		fmt.Printf("%s%s\x00", prefix, name)
+		return
+	}
+	fmt.Printf("%s%s\n", prefix, o.path(name))
+}
+
+// statusSeparator separates the status of --name-status and --raw from the
+// path: a tab, or a NUL with -z.
+func (o *diffOutput) statusSeparator() string {
+	if o.nulTerm {
+		return "\x00"
+	}
+	return "\t"
+}
+
+// enabled reports whether any diff output was requested.
+func (o *diffOutput) enabled() bool {
+	return !o.noPatch && (o.patch || o.stat || o.numstat || o.shortstat || o.nameOnly || o.nameStatus || o.raw)
+}
+
+// summaryOnly reports whether only a per-file format was chosen, so there
+// is no patch or stat to compute and no file contents to read.
+func (o *diffOutput) summaryOnly() bool {
+	return o.nameOnly || o.nameStatus || (o.raw && !o.patch && !o.stat && !o.numstat && !o.shortstat)
+}
+
+// print writes the requested diff output for a set of changes.
+//
+// Parameters:
+// - objects: The object manager used to load file contents.
+// - changes: The changed paths.
+// - worktree: Directory to read content missing from the object database, or "".
+func (o *diffOutput) print(objects *cmd.ObjectManager, changes []cmd.TreeChange, worktree string) error {
+	// --name-only and --name-status replace every other format, as in git.
+	for _, change := range changes {
+		status := string(change.Status)
+		if change.Status == cmd.StatusRenamed {
+			status = fmt.Sprintf("%c%03d%s%s", change.Status, change.Score, o.statusSeparator(), o.path(change.OldPath))
+		}
+		switch {
+		case o.nameOnly:
+			o.printRecord("", change.Path)
+		case o.nameStatus:
+			o.printRecord(status+o.statusSeparator(), change.Path)
+		case o.raw:
+			raw := change
+			if worktree != "" && raw.New.SHA != "" && !objects.Has(raw.New.SHA) {
+				// Modified worktree content is not an object yet, so git
+				// shows no name for it.
+				raw.New.SHA = ""
+			}
+			o.printRecord(rawChange(raw)+status+o.statusSeparator(), change.Path)
+		}
+	}
+	if o.summaryOnly() {
+		return nil
+	}
+
+	patches, err := objects.Patches(changes, worktree, o.context)
+	if err != nil {
+		return err
+	}
+
+	stats := cmd.DiffStats(patches)
+	summary := o.raw && len(changes) > 0
+	if o.numstat {
+		for _, stat := range stats {
+			o.printRecord(cmd.NumstatPrefix(stat), stat.Path)
+		}
+		summary = true
+	}
+	if o.stat {
+		for _, line := range cmd.FormatStat(stats, cmd.DefaultStatWidth, o.quotePath) {
+			fmt.Println(line)
+		}
+		summary = true
+	} else if o.shortstat && len(stats) > 0 {
+		fmt.Println(cmd.FormatStatSummary(stats))
+		summary = true
+	}
+
+	if !o.patch {
+		return nil
+	}
+	if summary && len(patches) > 0 {
+		fmt.Println()
+	}
+	for _, patch := range patches {
+		if err := cmd.WritePatch(os.Stdout, patch, o.quotePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawChange renders a change in git's --raw format up to the status, e.g.
+// ":100644 100644 4cb29ea ea14db2 ".
+func rawChange(change cmd.TreeChange) string {
+	mode := func(entry cmd.TreeEntry) string {
+		if entry.Mode == "" {
+			return "000000"
+		}
+		return fmt.Sprintf("%06s", entry.Mode)
+	}
+	sha := func(entry cmd.TreeEntry) string {
+		if entry.SHA == "" {
+			return "0000000"
+		}
+		return entry.SHA[:7]
+	}
+	return fmt.Sprintf(":%s %s %s %s ", mode(change.Old), mode(change.New), sha(change.Old), sha(change.New))
+}
+
+// printForCommit writes the diff output of a commit after its message,
+// separated the way git log does.
+func (o *diffOutput) printForCommit(objects *cmd.ObjectManager, changes []cmd.TreeChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if o.patch && (o.stat || o.numstat || o.shortstat) {
+		fmt.Println("---")
+	} else {
+		fmt.Println()
+	}
+	return o.print(objects, changes, "")
+}
+
+// commitChanges diffs a commit against its first parent, or against the
+// empty tree for a root commit. Merge commits have no diff, as in git log,
+// unless firstParent is set, as it is for log --first-parent.
+func commitChanges(objects *cmd.ObjectManager, commit *cmd.Commit, firstParent bool) ([]cmd.TreeChange, error) {
+	if len(commit.Parents) > 1 && !firstParent {
+		return nil, nil
+	}
+
+	parentTree := ""
+	if len(commit.Parents) > 0 {
+		parent, err := objects.ReadCommit(commit.Parents[0])
+		if err != nil {
+			return nil, err
+		}
+		parentTree = parent.Tree
+	}
+	return objects.DiffTrees(parentTree, commit.Tree)
+}
+
+// DiffCommand creates the `diff` command.
+func DiffCommand() *cobra.Command {
+	var output diffOutput
+	var cached bool
+
+	diffCmd := &cobra.Command{
+		Use:   "diff [--cached] [<commit> [<commit>]]",
+		Short: "Show changes between the worktree, the index and commits",
+		Args:  cobra.MaximumNArgs(2),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			output.setup(repo)
+			changes, worktree, err := diffChanges(repo, args, cached, os.Stderr)
+			if err != nil {
+				return err
+			}
+
+			output.patch = !output.stat && !output.numstat && !output.shortstat && !output.raw
+			if command.Flags().Changed("patch") || command.Flags().Changed("unified") {
+				output.patch = true
+			}
+			if output.noPatch {
+				return nil
+			}
+			return output.print(cmd.NewObjectManager(repo).UseReplaceRefs().UseGrafts(), changes, worktree)
+		},
+	}
+
+	output.addFlags(diffCmd.Flags())
+	diffCmd.Flags().BoolVar(&cached, "cached", false, "Compare the index with HEAD or the given commit")
+	diffCmd.Flags().BoolVar(&cached, "staged", false, "Synonym for --cached")
+	return diffCmd
+}
+
+// diffChanges works out what diff compares from its arguments:
+// index and worktree, a commit and the index (--cached), a commit and the
+// worktree, or two commits. Line ending warnings go to warnings, which
+// may be nil.
+//
+// Returns:
+// - The changes.
+// - The worktree to read new content from, or "" when both sides are objects.
+// - An error if a revision or the index cannot be read.
+func diffChanges(repo *cmd.GitRepository, args []string, cached bool, warnings io.Writer) ([]cmd.TreeChange, string, error) {
+	objects := cmd.NewObjectManager(repo).UseReplaceRefs().UseGrafts()
+
+	if len(args) == 2 {
+		oldTree, err := cmd.ResolveTree(repo, args[0])
+		if err != nil {
+			return nil, "", err
+		}
+		newTree, err := cmd.ResolveTree(repo, args[1])
+		if err != nil {
+			return nil, "", err
+		}
+		changes, err := objects.DiffTrees(oldTree, newTree)
+		return changes, "", err
+	}
+
+	index, err := cmd.ReadIndex(repo)
+	if err != nil {
+		return nil, "", err
+	}
+	staged := cmd.IndexFiles(index)
+
+	oldFiles := staged
+	if cached || len(args) == 1 {
+		rev := cmd.HeadFile
+		if len(args) == 1 {
+			rev = args[0]
+		}
+		oldFiles = make(map[string]cmd.TreeEntry)
+		if tree, err := cmd.ResolveTree(repo, rev); err == nil {
+			if oldFiles, err = objects.FlattenTree(tree); err != nil {
+				return nil, "", err
+			}
+		} else if len(args) == 1 {
+			return nil, "", err
+		}
+	}
+
+	if cached {
+		return cmd.DiffFileSets(oldFiles, staged), "", nil
+	}
+	if repo.IsBare() {
+		return nil, "", fmt.Errorf("this operation must be run in a work tree")
+	}
+	eol := cmd.NewEOLConverter(repo)
+	eol.Warnings = warnings
+	worktree, err := cmd.WorktreeFiles(repo, index, eol)
+	if err != nil {
+		return nil, "", err
+	}
+	return cmd.DiffFileSets(oldFiles, worktree), repo.WorkTree, nil
+}
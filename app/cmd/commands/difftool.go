@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// DiffToolCommand creates the `difftool` command.
+func DiffToolCommand() *cobra.Command {
+	var toolName string
+	var cached, dirDiff, noPrompt, prompt, trustExitCode bool
+
+	difftoolCmd := &cobra.Command{
+		Use:   "difftool [--cached] [-d] [-t <tool>] [-y] [<commit> [<commit>]]",
+		Short: "Show changes with an external diff tool",
+		Long: `Show changes with an external diff tool.
+
+The changes are those diff would show. The tool is diff.tool, or merge.tool,
+and runs the shell command difftool.<tool>.cmd once per file with $LOCAL and
+$REMOTE naming the old and new content and $MERGED the path. With --dir-diff
+the tool runs once with $LOCAL and $REMOTE naming two directories; edits to
+worktree files in the right one are copied back.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			if repo.IsBare() {
+				return fmt.Errorf("this operation must be run in a work tree")
+			}
+			tool, err := cmd.DiffTool(repo, toolName)
+			if err != nil {
+				return err
+			}
+			if command.Flags().Changed("trust-exit-code") {
+				tool.TrustExitCode = trustExitCode
+			}
+
+			changes, worktree, err := diffChanges(repo, args, cached, os.Stderr)
+			if err != nil || len(changes) == 0 {
+				return err
+			}
+			dir, err := os.MkdirTemp("", "justdoit-difftool-")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dir)
+
+			objects := cmd.NewObjectManager(repo).UseReplaceRefs().UseGrafts()
+			if dirDiff {
+				return runDirDiff(repo, objects, tool, changes, worktree, dir)
+			}
+
+			if command.Flags().Changed("prompt") || command.Flags().Changed("no-prompt") {
+				tool.Prompt = prompt && !noPrompt
+			}
+			answers := bufio.NewReader(os.Stdin)
+			for i, change := range changes {
+				if tool.Prompt {
+					fmt.Printf("\nViewing (%d/%d): '%s'\n", i+1, len(changes), change.Path)
+					fmt.Printf("Launch '%s' [Y/n]? ", tool.Name)
+					answer, err := answers.ReadString('\n')
+					if err != nil {
+						return err
+					}
+					if strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "n") {
+						continue
+					}
+				}
+				if err := runFileDiff(repo, objects, tool, change, worktree, dir); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	difftoolCmd.Flags().BoolVar(&cached, "cached", false, "Compare the index with HEAD or the given commit")
+	difftoolCmd.Flags().BoolVar(&cached, "staged", false, "Synonym for --cached")
+	difftoolCmd.Flags().BoolVarP(&dirDiff, "dir-diff", "d", false, "Compare two directory trees in one run of the tool")
+	difftoolCmd.Flags().StringVarP(&toolName, "tool", "t", "", "Use this tool instead of diff.tool")
+	difftoolCmd.Flags().BoolVarP(&noPrompt, "no-prompt", "y", false, "Do not ask before launching the tool")
+	difftoolCmd.Flags().BoolVar(&prompt, "prompt", false, "Ask before launching the tool for each file")
+	difftoolCmd.Flags().BoolVar(&trustExitCode, "trust-exit-code", false, "Stop when the tool exits with a non-zero status")
+	return difftoolCmd
+}
+
+// runFileDiff runs a diff tool on one change. Object content goes to
+// temporary files below dir; worktree content is given as the worktree
+// file itself, so edits made in the tool are kept.
+func runFileDiff(repo *cmd.GitRepository, objects *cmd.ObjectManager, tool *cmd.ExternalTool, change cmd.TreeChange, worktree, dir string) error {
+	name := filepath.FromSlash(change.Path)
+	local, remote := os.DevNull, os.DevNull
+	if change.Old.SHA != "" {
+		local = filepath.Join(dir, "left", name)
+		if err := objects.ExtractToolFile(change.Old, local); err != nil {
+			return err
+		}
+	}
+	switch {
+	case change.New.SHA == "":
+	case worktree != "" && !change.New.IsGitlink():
+		remote = filepath.Join(worktree, name)
+	default:
+		remote = filepath.Join(dir, "right", name)
+		if err := objects.ExtractToolFile(change.New, remote); err != nil {
+			return err
+		}
+	}
+
+	return tool.Run(repo, cmd.ToolRun{
+		Local:  local,
+		Remote: remote,
+		Merged: change.Path,
+		Base:   change.Path,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	})
+}
+
+// runDirDiff runs a diff tool once on two trees holding both sides of
+// every change, then copies edits to worktree files back.
+func runDirDiff(repo *cmd.GitRepository, objects *cmd.ObjectManager, tool *cmd.ExternalTool, changes []cmd.TreeChange, worktree, dir string) error {
+	trees, err := objects.NewDirDiff(changes, worktree, dir)
+	if err != nil {
+		return err
+	}
+	err = tool.Run(repo, cmd.ToolRun{
+		Local:  trees.Left,
+		Remote: trees.Right,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = trees.CopyBack()
+	return err
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// FastExportCommand creates the `fast-export` command.
+func FastExportCommand() *cobra.Command {
+	var all bool
+
+	exportCmd := &cobra.Command{
+		Use:   "fast-export [--all | <ref>...]",
+		Short: "Export history as a git fast-import stream",
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			var refNames []string
+			if all {
+				refs, err := cmd.ListRefs(repo)
+				if err != nil {
+					return err
+				}
+				for _, ref := range refs {
+					refNames = append(refNames, ref.Name)
+				}
+			}
+
+			for _, arg := range args {
+				name, err := cmd.ExpandRefName(repo, arg)
+				if err != nil {
+					return err
+				}
+				refNames = append(refNames, name)
+			}
+
+			return cmd.FastExport(repo, refNames, os.Stdout)
+		},
+	}
+
+	exportCmd.Flags().BoolVar(&all, "all", false, "Export every ref")
+	return exportCmd
+}
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// FastImportCommand creates the `fast-import` command.
+func FastImportCommand() *cobra.Command {
+	var opts cmd.FastImportOptions
+	var quiet bool
+
+	importCmd := &cobra.Command{
+		Use:   "fast-import",
+		Short: "Create objects and refs from a fast-import stream read on stdin",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			stats, err := cmd.FastImport(repo, os.Stdin, os.Stdout, opts)
+			if stats != nil && !quiet {
+				fmt.Fprintf(os.Stderr, "Imported %d blobs, %d commits, %d tags; updated %d refs\n",
+					stats.Blobs, stats.Commits, stats.Tags, stats.Refs)
+			}
+			return err
+		},
+	}
+
+	importCmd.Flags().BoolVar(&opts.Force, "force", false, "Allow refs to be rewound or rewritten")
+	importCmd.Flags().StringVar(&opts.ExportMarks, "export-marks", "", "Write the mark table to this file")
+	importCmd.Flags().BoolVar(&quiet, "quiet", false, "Do not print import statistics")
+	return importCmd
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// FetchCommand creates the `fetch` command.
+func FetchCommand() *cobra.Command {
+	var opts cmd.FetchOptions
+	var run *runFlags
+
+	fetchCmd := &cobra.Command{
+		Use:   "fetch [<repository> [<refspec>...]]",
+		Short: "Download objects and refs from another repository",
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			remote := "origin"
+			if len(args) > 0 {
+				remote, opts.Refspecs = args[0], args[1:]
+			}
+
+			opts.DryRun = run.DryRun
+			result, err := cmd.Fetch(repo, remote, opts)
+			if err != nil {
+				return err
+			}
+
+			rejected := false
+			printed := false
+			for _, ref := range result.Refs {
+				rejected = rejected || ref.Rejected()
+				shown := !run.Quiet
+				if ref.Status == cmd.FetchUpToDate {
+					shown = run.Verbose
+				}
+				if !shown {
+					continue
+				}
+				if !printed {
+					fmt.Fprintf(os.Stderr, "From %s\n", result.URL)
+					printed = true
+				}
+				fmt.Fprintln(os.Stderr, formatFetchedRef(ref))
+			}
+
+			if rejected {
+				return fmt.Errorf("some local refs could not be updated")
+			}
+			if run.DryRun {
+				return nil
+			}
+			return cmd.AutoMaintenance(repo, run.Out(os.Stderr))
+		},
+	}
+
+	fetchCmd.Flags().BoolVarP(&opts.Tags, "tags", "t", false, "Fetch all tags from the remote")
+	fetchCmd.Flags().BoolVarP(&opts.NoTags, "no-tags", "n", false, "Do not follow tags pointing into the fetched history")
+	fetchCmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Allow refs to be updated even when they do not fast-forward")
+	fetchCmd.Flags().StringVar(&opts.UploadPack, "upload-pack", "", "Path of the upload-pack program on the remote host")
+	fetchCmd.Flags().StringVar(&opts.Filter, "filter", "", "Leave out objects matching the filter, e.g. blob:none, making a partial clone")
+	run = addRunFlags(fetchCmd, runFlagUsage{
+		DryRun:  "Show what would be updated, without updating any ref",
+		Verbose: "Also report refs that are up to date",
+		Quiet:   "Do not report updated refs",
+	})
+	return fetchCmd
+}
+
+// formatFetchedRef renders one line of fetch output in git's layout, e.g.
+// " * [new branch]      main       -> origin/main".
+func formatFetchedRef(ref cmd.FetchedRef) string {
+	flag, summary, note := " ", "", ""
+	switch ref.Status {
+	case cmd.FetchUpToDate:
+		flag, summary = "=", "[up to date]"
+	case cmd.FetchNewBranch, cmd.FetchNewTag, cmd.FetchNewRef:
+		flag, summary = "*", "["+ref.Status+"]"
+	case cmd.FetchHeadOnly:
+		flag, summary = "*", "branch"
+		if strings.HasPrefix(ref.Remote, "refs/tags/") {
+			summary = "tag"
+		}
+	case cmd.FetchFastForward:
+		summary = ref.Old[:7] + ".." + ref.New[:7]
+	case cmd.FetchForced:
+		flag, summary, note = "+", ref.Old[:7]+"..."+ref.New[:7], "  (forced update)"
+	default:
+		flag, summary, note = "!", "[rejected]", "  ("+ref.Status+")"
+	}
+
+	local := "FETCH_HEAD"
+	if ref.Local != "" {
+		local = shortRefName(ref.Local)
+	}
+	return fmt.Sprintf(" %s %-17s %-10s -> %s%s", flag, summary, shortRefName(ref.Remote), local, note)
+}
+
+// shortRefName strips the refs/heads/, refs/tags/ or refs/remotes/ prefix.
+func shortRefName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if short, ok := strings.CutPrefix(name, prefix); ok {
+			return short
+		}
+	}
+	return name
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// FsckCommand creates the `fsck` command.
+func FsckCommand() *cobra.Command {
+	var verifyObjects, jsonOutput bool
+
+	fsckCmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Verify the integrity of the objects in the database",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			report, err := cmd.VerifyObjects(repo, cmd.VerifyOptions{Blobs: verifyObjects})
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				for _, corruption := range report.Corrupt {
+					fmt.Printf("error: %s\n", corruption)
+				}
+			}
+
+			if len(report.Corrupt) > 0 {
+				return fmt.Errorf("%d of %d objects are corrupt", len(report.Corrupt), report.Checked)
+			}
+			return nil
+		},
+	}
+
+	fsckCmd.Flags().BoolVar(&verifyObjects, "verify-objects", false,
+		"Also re-inflate and re-hash every blob instead of only reading its header")
+	fsckCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the corruption manifest as JSON")
+	return fsckCmd
+}
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// HashObjectCommand creates the `hash-object` command.
+func HashObjectCommand() *cobra.Command {
+	var objType, path string
+	var write, stdin, stdinPaths, literally, noFilters bool
+
+	hashObjectCmd := &cobra.Command{
+		Use:   "hash-object [-t <type>] [-w] [--path=<file> | --no-filters] [--stdin [--literally]] [--] <file>... | --stdin-paths",
+		Short: "Compute the object name of files and optionally store them",
+		RunE: func(command *cobra.Command, args []string) error {
+			if stdinPaths && (stdin || len(args) > 0) {
+				return fmt.Errorf("--stdin-paths cannot be combined with --stdin or file arguments")
+			}
+			if path != "" && noFilters {
+				return fmt.Errorf("--path and --no-filters cannot be used together")
+			}
+
+			// Hashing without writing works outside of a repository.
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				if write {
+					return err
+				}
+				repo = nil
+			}
+
+			opts := cmd.HashObjectOptions{Type: cmd.GitObjectType(objType), Write: write, Literally: literally}
+			hashed := func(sha string, err error) error {
+				if err != nil {
+					return err
+				}
+				fmt.Println(sha)
+				return nil
+			}
+
+			if stdin {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				stdinOpts := opts
+				if !noFilters {
+					stdinOpts.Path = path
+				}
+				if err := hashed(cmd.HashObject(repo, data, stdinOpts)); err != nil {
+					return err
+				}
+			}
+
+			files := args
+			if stdinPaths {
+				scanner := bufio.NewScanner(os.Stdin)
+				for scanner.Scan() {
+					files = append(files, scanner.Text())
+				}
+				if err := scanner.Err(); err != nil {
+					return err
+				}
+			}
+
+			for _, file := range files {
+				fileOpts := opts
+				switch {
+				case noFilters:
+				case path != "":
+					fileOpts.Path = path
+				default:
+					fileOpts.Path = worktreeRelative(repo, file)
+				}
+				if err := hashed(cmd.HashFile(repo, file, fileOpts)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	hashObjectCmd.Flags().StringVarP(&objType, "type", "t", string(cmd.BlobType), "The type of object to create")
+	hashObjectCmd.Flags().BoolVarP(&write, "write", "w", false, "Write the object into the object database")
+	hashObjectCmd.Flags().BoolVar(&stdin, "stdin", false, "Read the object from standard input")
+	hashObjectCmd.Flags().BoolVar(&stdinPaths, "stdin-paths", false, "Read file names from standard input, one per line")
+	hashObjectCmd.Flags().BoolVar(&literally, "literally", false, "Hash any garbage as an object of any type, without checking it")
+	hashObjectCmd.Flags().StringVar(&path, "path", "", "Convert line endings as for this worktree path")
+	hashObjectCmd.Flags().BoolVar(&noFilters, "no-filters", false, "Hash the content as is, without line ending conversion")
+	return hashObjectCmd
+}
+
+// worktreeRelative returns the path of file relative to the top of the
+// worktree with forward slashes, or "" if it is outside of the worktree.
+func worktreeRelative(repo *cmd.GitRepository, file string) string {
+	if repo == nil || repo.WorkTree == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return ""
+	}
+	top, err := filepath.Abs(repo.WorkTree)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
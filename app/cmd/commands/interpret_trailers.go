@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// InterpretTrailersCommand creates the `interpret-trailers` command.
+func InterpretTrailersCommand() *cobra.Command {
+	var trailers trailerArgs
+	var inPlace, parse bool
+	var opts cmd.TrailerOptions
+
+	interpretTrailersCmd := &cobra.Command{
+		Use:   "interpret-trailers [--in-place] [--trailer <token>[(=|:)<value>]...] [<file>...]",
+		Short: "Add or parse structured information in commit messages",
+		Long: `Add or parse structured information in commit messages.
+
+Trailers are "Token: value" lines, such as Signed-off-by, in the last
+paragraph of a message. Each --trailer is added to the messages read from the
+files, or from stdin, and the result is printed or, with --in-place, written
+back. Like in git, --where, --if-exists and --if-missing apply to the
+--trailer options after them; trailer.* config covers the others.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			if inPlace && len(args) == 0 {
+				return fmt.Errorf("no input file given for in-place editing")
+			}
+			if parse {
+				opts.OnlyTrailers, opts.OnlyInput, opts.Unfold = true, true, true
+			}
+			if opts.OnlyInput && len(trailers.added) > 0 {
+				return fmt.Errorf("--trailer with --only-input does not make sense")
+			}
+			opts.Warnings = os.Stderr
+
+			// Like git interpret-trailers, this works outside of a
+			// repository too, for hook scripts.
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				repo = nil
+			}
+
+			if len(args) == 0 {
+				message, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(cmd.InterpretTrailers(repo, message, trailers.added, opts))
+				return err
+			}
+
+			for _, file := range args {
+				message, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("could not read input file '%s': %w", file, err)
+				}
+				message = cmd.InterpretTrailers(repo, message, trailers.added, opts)
+				if inPlace {
+					if err := os.WriteFile(file, message, 0644); err != nil {
+						return err
+					}
+					continue
+				}
+				if _, err := os.Stdout.Write(message); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	trailers.addFlags(interpretTrailersCmd.Flags())
+	interpretTrailersCmd.Flags().BoolVar(&inPlace, "in-place", false, "Edit the files in place")
+	interpretTrailersCmd.Flags().BoolVar(&opts.TrimEmpty, "trim-empty", false, "Leave out trailers with an empty value")
+	interpretTrailersCmd.Flags().BoolVar(&opts.OnlyTrailers, "only-trailers", false, "Output only the trailers")
+	interpretTrailersCmd.Flags().BoolVar(&opts.OnlyInput, "only-input", false, "Do not add the trailers from --trailer or the config")
+	interpretTrailersCmd.Flags().BoolVar(&opts.Unfold, "unfold", false, "Join multi-line values into one line")
+	interpretTrailersCmd.Flags().BoolVar(&parse, "parse", false, "Same as --only-trailers --only-input --unfold")
+	interpretTrailersCmd.Flags().BoolVar(&opts.NoDivider, "no-divider", false, "Do not treat --- as the end of the message")
+	return interpretTrailersCmd
+}
+
+// trailerArgs collects --trailer options with the rule that the --where,
+// --if-exists and --if-missing options before each of them set.
+type trailerArgs struct {
+	rule  cmd.TrailerRule
+	added []cmd.NewTrailer
+}
+
+// addFlags registers --trailer and the rule flags. pflag sets values in
+// command line order, which is what makes the rules positional.
+func (a *trailerArgs) addFlags(flags *pflag.FlagSet) {
+	flags.Var(&trailerFlag{func(text string) error {
+		a.added = append(a.added, cmd.NewTrailer{Text: text, Rule: a.rule})
+		return nil
+	}}, "trailer", "Trailer to add, as <token>: <value> or <token>=<value>")
+	flags.Var(&trailerFlag{func(value string) (err error) {
+		a.rule.Where, err = cmd.ParseTrailerWhere(value)
+		return err
+	}}, "where", "Where to add the next trailers: end, start, after or before")
+	flags.Var(&trailerFlag{func(value string) (err error) {
+		a.rule.IfExists, err = cmd.ParseTrailerIfExists(value)
+		return err
+	}}, "if-exists", "What to do if the next trailers exist: addIfDifferentNeighbor, addIfDifferent, add, replace or doNothing")
+	flags.Var(&trailerFlag{func(value string) (err error) {
+		a.rule.IfMissing, err = cmd.ParseTrailerIfMissing(value)
+		return err
+	}}, "if-missing", "What to do if the next trailers are missing: add or doNothing")
+}
+
+// trailerFlag is a pflag.Value that hands each value to a function as soon
+// as it is parsed.
+type trailerFlag struct {
+	set func(string) error
+}
+
+func (f *trailerFlag) String() string     { return "" }
+func (f *trailerFlag) Set(v string) error { return f.set(v) }
+func (f *trailerFlag) Type() string       { return "string" }
@@ -0,0 +1,409 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// gitDateLayout is the default date format of git log.
+const gitDateLayout = cmd.GitDateLayout
+
+// logOptions holds the flags shared by log and whatchanged.
+type logOptions struct {
+	maxCount   int
+	oneline    bool
+	noDecorate bool
+	decorate   string
+	output     diffOutput
+	selectors  refSelectors
+	walk       walkFlags
+	skipEmpty  bool // Leave out commits without changes, like whatchanged.
+	signatures bool
+	pickaxe    cmd.PickaxeOptions
+	pickaxeAll bool // Show every change of the commits the pickaxe finds.
+	follow     bool
+}
+
+// addFlags registers the log flags.
+func (o *logOptions) addFlags(flags *pflag.FlagSet) {
+	flags.IntVarP(&o.maxCount, "max-count", "n", -1, "Limit the number of commits to output")
+	flags.BoolVar(&o.oneline, "oneline", false, "Show each commit on a single line")
+	flags.StringVar(&o.decorate, "decorate", "", "Print the ref names of commits: short, full, auto or no")
+	flags.Lookup("decorate").NoOptDefVal = string(cmd.DecorateShort)
+	flags.BoolVar(&o.noDecorate, "no-decorate", false, "Do not print ref names")
+	flags.BoolVar(&o.signatures, "show-signature", false, "Verify signed commits and show the result")
+	flags.StringVarP(&o.pickaxe.Search, "pickaxe-search", "S", "", "Show only commits that change the number of occurrences of the string")
+	flags.StringVarP(&o.pickaxe.Grep, "pickaxe-grep", "G", "", "Show only commits whose diff adds or removes a line matching the regex")
+	flags.BoolVar(&o.pickaxe.Regex, "pickaxe-regex", false, "Treat the string of -S as a regex")
+	flags.BoolVar(&o.pickaxeAll, "pickaxe-all", false, "Show all the changes of the commits -S or -G finds, not only the matching ones")
+	flags.BoolVar(&o.follow, "follow", false, "Continue the history of a single file across renames")
+	o.output.addFlags(flags)
+	o.selectors.addFlags(flags)
+	o.walk.addFlags(flags)
+}
+
+// LogCommand creates the `log` command.
+func LogCommand() *cobra.Command {
+	var opts logOptions
+
+	logCmd := &cobra.Command{
+		Use:   "log [<revision>...] [[--] <path>...]",
+		Short: "Show commit logs",
+		Long: `Show commit logs.
+
+Paths limit the log to the commits that change them, and the diff output to
+the changes under them. Paths follow the revisions, after "--" if a path
+could be taken for a revision. With --follow, the history of a single file
+continues across the commits that renamed it.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			return runLog(command, args, &opts)
+		},
+	}
+
+	opts.addFlags(logCmd.Flags())
+	return logCmd
+}
+
+// WhatchangedCommand creates the `whatchanged` command, a log that lists
+// the files each commit touched in raw format and skips commits that
+// changed nothing.
+func WhatchangedCommand() *cobra.Command {
+	opts := logOptions{skipEmpty: true}
+
+	whatchangedCmd := &cobra.Command{
+		Use:   "whatchanged [<revision>...] [[--] <path>...]",
+		Short: "Show logs with the files each commit changed",
+		RunE: func(command *cobra.Command, args []string) error {
+			opts.output.raw = !opts.output.patch && !opts.output.nameOnly && !opts.output.nameStatus &&
+				!opts.output.stat && !opts.output.numstat && !opts.output.shortstat
+			return runLog(command, args, &opts)
+		},
+	}
+
+	opts.addFlags(whatchangedCmd.Flags())
+	return whatchangedCmd
+}
+
+// runLog walks the history from the given revisions and prints each commit
+// with the requested diff output.
+func runLog(command *cobra.Command, args []string, opts *logOptions) error {
+	repo, err := cmd.FindRepository(".")
+	if err != nil {
+		return err
+	}
+
+	style, err := decorationStyle(repo, command, opts.decorate, opts.noDecorate)
+	if err != nil {
+		return err
+	}
+	decorations := &cmd.Decorations{}
+	if style != cmd.DecorateNo {
+		if decorations, err = cmd.LoadDecorations(repo, style); err != nil {
+			return err
+		}
+	}
+
+	opts.output.setup(repo)
+	if !command.Flags().Changed("show-signature") {
+		opts.signatures = repo.Config().GetBool("log.showsignature")
+	}
+	args, paths, err := splitLogArgs(repo, args, command.ArgsLenAtDash())
+	if err != nil {
+		return err
+	}
+	var filter *pathFilter
+	if opts.follow && len(paths) != 1 {
+		return fmt.Errorf("--follow requires exactly one pathspec")
+	}
+	if len(paths) > 0 {
+		filter = &pathFilter{paths: paths, follow: opts.follow}
+	}
+
+	revs, err := opts.selectors.revisionRange(repo, args)
+	if err != nil {
+		return err
+	}
+	if revs.Empty() && len(args) == 0 && !opts.selectors.given() {
+		head, err := cmd.HeadCommit(repo)
+		if err != nil {
+			return err
+		}
+		revs.Include = []string{head}
+	}
+
+	pickaxe, err := cmd.NewPickaxe(opts.pickaxe)
+	if err != nil {
+		return err
+	}
+
+	objects := cmd.NewObjectManager(repo).UseReplaceRefs().UseGrafts()
+	commits, err := objects.RevList(revs.Include, revs.Exclude, opts.walk.options())
+	if err != nil {
+		return err
+	}
+	// Paths are applied newest first, as --follow renames the file it
+	// follows on the way back, and before --reverse counts the commits.
+	var touched map[string][]cmd.TreeChange
+	if filter != nil {
+		if commits, touched, err = filter.apply(objects, commits, opts.walk.firstParent); err != nil {
+			return err
+		}
+	}
+	if opts.walk.reverse {
+		commits = opts.walk.limit(commits, opts.maxCount)
+	}
+
+	output := &opts.output
+	shown := 0
+	for _, sha := range commits {
+		if opts.maxCount >= 0 && shown >= opts.maxCount {
+			break
+		}
+		commit, err := objects.ReadCommit(sha)
+		if err != nil {
+			return err
+		}
+
+		var changes []cmd.TreeChange
+		if filter != nil {
+			changes = touched[sha]
+		} else if output.enabled() || opts.skipEmpty || pickaxe != nil {
+			if changes, err = commitChanges(objects, commit, opts.walk.firstParent); err != nil {
+				return err
+			}
+			if opts.skipEmpty && len(changes) == 0 {
+				continue
+			}
+		}
+		if pickaxe != nil {
+			found, err := pickaxe.Filter(objects, changes)
+			if err != nil {
+				return err
+			}
+			if len(found) == 0 {
+				continue
+			}
+			if !opts.pickaxeAll {
+				changes = found
+			}
+		}
+
+		signature := ""
+		if opts.signatures {
+			signature = signatureReport(repo, commit)
+		}
+		if opts.oneline {
+			fmt.Printf("%s%s %s%s\n", objects.ShortSHA(sha, 0), decorations.Format(sha), signature, commitSubject(commit))
+		} else {
+			if shown > 0 {
+				fmt.Println()
+			}
+			printCommitMedium(objects, commit, decorations, signature)
+		}
+		shown++
+
+		if !output.enabled() {
+			continue
+		}
+		if opts.oneline {
+			err = output.print(objects, changes, "")
+		} else {
+			err = output.printForCommit(objects, changes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decorationStyle picks the decoration style from the flags, then
+// log.decorate, then auto. Auto decorates only when stdout is a terminal.
+func decorationStyle(repo *cmd.GitRepository, command *cobra.Command, flag string, noDecorate bool) (cmd.DecorationStyle, error) {
+	value := string(cmd.DecorateAuto)
+	switch {
+	case noDecorate:
+		value = string(cmd.DecorateNo)
+	case command.Flags().Changed("decorate"):
+		value = flag
+	case repo.Config().IsSet("log.decorate"):
+		value = repo.Config().GetString("log.decorate")
+	}
+
+	style, err := cmd.ParseDecorationStyle(value)
+	if err != nil || style != cmd.DecorateAuto {
+		return style, err
+	}
+	if isTerminal(os.Stdout) {
+		return cmd.DecorateShort, nil
+	}
+	return cmd.DecorateNo, nil
+}
+
+// commitSubject returns the first line of a commit message.
+func commitSubject(commit *cmd.Commit) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(string(commit.Message)), "\n")
+	return subject
+}
+
+// signatureReport verifies a signed commit for --show-signature and
+// returns what the verifier reported, or "" for an unsigned commit. Like
+// git, a signature that cannot be checked is reported as no signature,
+// with the reason on stderr.
+func signatureReport(repo *cmd.GitRepository, commit *cmd.Commit) string {
+	payload, signature := commit.Signature()
+	if signature == nil {
+		return ""
+	}
+	status, err := cmd.VerifySignature(repo, payload, signature)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return "No signature\n"
+	}
+	return status.Output
+}
+
+// printCommitMedium prints a commit in git's default "medium" format, with
+// the signature report of --show-signature below the commit line.
+func printCommitMedium(objects *cmd.ObjectManager, commit *cmd.Commit, decorations *cmd.Decorations, signature string) {
+	fmt.Printf("commit %s%s\n", commit.SHA, decorations.Format(commit.SHA))
+	fmt.Print(signature)
+	if len(commit.Parents) > 1 {
+		short := make([]string, len(commit.Parents))
+		for i, parent := range commit.Parents {
+			short[i] = objects.ShortSHA(parent, 0)
+		}
+		fmt.Printf("Merge: %s\n", strings.Join(short, " "))
+	}
+
+	author := cmd.ParseSignature(commit.Author)
+	fmt.Printf("Author: %s <%s>\n", author.Name, author.Email)
+	fmt.Printf("Date:   %s\n\n", author.When.Format(gitDateLayout))
+
+	message := strings.TrimRight(string(commit.Message), "\n")
+	for _, line := range strings.Split(message, "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// splitLogArgs separates the revisions of a log command line from its
+// paths. Everything after "--" is a path; without "--", the arguments from
+// the first one that is not a revision but names an existing file on are.
+//
+// Parameters:
+// - repo: The repository.
+// - args: The arguments.
+// - dash: The number of arguments before "--", or -1.
+//
+// Returns:
+// - The revisions.
+// - The paths, relative to the top of the worktree.
+// - An error if a path is outside the repository.
+func splitLogArgs(repo *cmd.GitRepository, args []string, dash int) ([]string, []string, error) {
+	if dash < 0 {
+		dash = len(args)
+		for i, arg := range args {
+			if _, err := cmd.ParseRevisionRange(repo, []string{arg}); err == nil {
+				continue
+			}
+			if _, err := os.Stat(arg); err == nil {
+				dash = i
+			}
+			break
+		}
+	}
+
+	var paths []string
+	for _, arg := range args[dash:] {
+		path := worktreeRelative(repo, arg)
+		if path == "" {
+			return nil, nil, fmt.Errorf("'%s' is outside repository", arg)
+		}
+		paths = append(paths, path)
+	}
+	return args[:dash], paths, nil
+}
+
+// pathFilter limits a log to the commits that change some paths.
+type pathFilter struct {
+	paths  []string
+	follow bool // Follow the single path across renames.
+}
+
+// apply keeps the commits that change the paths, with those changes.
+// Commits must come newest first: with follow, the filter looks for the
+// file under its old name once it reaches the commit that renamed it.
+//
+// Parameters:
+// - objects: The object store.
+// - commits: The commits of the walk, newest first.
+// - firstParent: Diff merges against their first parent.
+//
+// Returns:
+// - The commits that change the paths, in order.
+// - The changes of each commit that fall under the paths.
+// - An error if a commit or tree cannot be read.
+func (f *pathFilter) apply(objects *cmd.ObjectManager, commits []string, firstParent bool) ([]string, map[string][]cmd.TreeChange, error) {
+	var kept []string
+	touched := make(map[string][]cmd.TreeChange)
+	for _, sha := range commits {
+		commit, err := objects.ReadCommit(sha)
+		if err != nil {
+			return nil, nil, err
+		}
+		changes, err := commitChanges(objects, commit, firstParent)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var matched []cmd.TreeChange
+		for _, change := range changes {
+			if f.matches(change.Path) {
+				matched = append(matched, change)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		if f.follow && len(matched) == 1 && matched[0].Status == cmd.StatusAdded {
+			if matched, err = f.followRename(objects, changes, matched); err != nil {
+				return nil, nil, err
+			}
+		}
+		kept = append(kept, sha)
+		touched[sha] = matched
+	}
+	return kept, touched, nil
+}
+
+// followRename looks for the file that the followed path was renamed from
+// in a commit that added it. If there is one, the rename replaces the
+// addition and the filter follows the old path from then on.
+func (f *pathFilter) followRename(objects *cmd.ObjectManager, changes, matched []cmd.TreeChange) ([]cmd.TreeChange, error) {
+	renames, err := objects.DetectRenames(changes, cmd.DefaultRenameScore)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range renames {
+		if change.Status == cmd.StatusRenamed && change.Path == f.paths[0] {
+			f.paths[0] = change.OldPath
+			return []cmd.TreeChange{change}, nil
+		}
+	}
+	return matched, nil
+}
+
+// matches reports whether a file is one of the paths or lies under one.
+func (f *pathFilter) matches(file string) bool {
+	for _, path := range f.paths {
+		if path == "." || file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
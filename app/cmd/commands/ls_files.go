@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// LsFilesCommand creates the `ls-files` command.
+func LsFilesCommand() *cobra.Command {
+	var opts cmd.LsFilesOptions
+	var stage, tags, validBit, nullTerminated bool
+
+	lsFilesCmd := &cobra.Command{
+		Use:   "ls-files [-c] [-d] [-m] [-s] [-t] [-v] [-z]",
+		Short: "Show information about files in the index and the working tree",
+		Long: `Show information about files in the index and the working tree.
+
+With -t, every line starts with a tag saying why the file is listed: H for
+cached, S for skip-worktree, M for unmerged, R for deleted and C for
+modified. -v tags files the same way, but with lowercase letters for files
+marked assume-unchanged.`,
+		Args: cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			if stage {
+				opts.Cached = true
+			}
+			listed, err := cmd.LsFiles(repo, opts)
+			if err != nil {
+				return err
+			}
+
+			quotePath := cmd.QuotePathEnabled(repo)
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+			for _, item := range listed {
+				entry := item.Entry
+				if tags || validBit {
+					tag := rune(item.Tag)
+					if validBit && entry.AssumeUnchanged() {
+						tag = unicode.ToLower(tag)
+					}
+					fmt.Fprintf(out, "%c ", tag)
+				}
+				if stage {
+					fmt.Fprintf(out, "%06o %s %d\t", entry.Mode, entry.SHA, entry.Stage())
+				}
+				if nullTerminated {
+					fmt.Fprintf(out, "%s\x00", entry.Name)
+				} else {
+					fmt.Fprintf(out, "%s\n", cmd.QuotePath(entry.Name, quotePath))
+				}
+			}
+			return nil
+		},
+	}
+
+	flags := lsFilesCmd.Flags()
+	flags.BoolVarP(&opts.Cached, "cached", "c", false, "Show the files of the index (the default)")
+	flags.BoolVarP(&opts.Deleted, "deleted", "d", false, "Show the files deleted from the worktree")
+	flags.BoolVarP(&opts.Modified, "modified", "m", false, "Show the files modified in the worktree")
+	flags.BoolVarP(&stage, "stage", "s", false, "Show the mode, object name and stage of each file")
+	flags.BoolVarP(&tags, "tags", "t", false, "Tag every file with its status")
+	flags.BoolVar(&validBit, "valid-bit", false, "Like -t, with lowercase tags for files marked assume-unchanged")
+	flags.BoolVarP(&nullTerminated, "null", "z", false, "Terminate lines with NUL and do not quote paths")
+	return lsFilesCmd
+}
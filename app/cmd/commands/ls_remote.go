@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// LsRemoteCommand creates the `ls-remote` command.
+func LsRemoteCommand() *cobra.Command {
+	var opts cmd.LsRemoteOptions
+
+	lsRemoteCmd := &cobra.Command{
+		Use:   "ls-remote [<repository> [<patterns>...]]",
+		Short: "List references in a remote repository",
+		RunE: func(command *cobra.Command, args []string) error {
+			// Outside a repository only URLs and paths can be listed.
+			repo, _ := cmd.FindRepository(".")
+
+			location := "origin"
+			if len(args) > 0 {
+				location, opts.Patterns = args[0], args[1:]
+			} else if repo == nil {
+				return fmt.Errorf("no remote specified")
+			}
+
+			refs, err := cmd.LsRemote(repo, cmd.ResolveRemoteURL(repo, location), opts)
+			if err != nil {
+				return err
+			}
+			for _, ref := range refs {
+				fmt.Printf("%s\t%s\n", ref.SHA, ref.Name)
+			}
+			return nil
+		},
+	}
+
+	lsRemoteCmd.Flags().BoolVar(&opts.Heads, "heads", false, "Limit to refs/heads")
+	lsRemoteCmd.Flags().BoolVarP(&opts.Tags, "tags", "t", false, "Limit to refs/tags")
+	lsRemoteCmd.Flags().BoolVar(&opts.RefsOnly, "refs", false, "Do not show peeled tags or pseudorefs like HEAD")
+	lsRemoteCmd.Flags().StringVar(&opts.UploadPack, "upload-pack", "", "Path of the upload-pack program on the remote host")
+	return lsRemoteCmd
+}
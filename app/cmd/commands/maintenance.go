@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// MaintenanceCommand creates the `maintenance` command and its subcommands.
+func MaintenanceCommand() *cobra.Command {
+	maintenanceCmd := &cobra.Command{
+		Use:   "maintenance",
+		Short: "Run tasks to optimize the repository data",
+	}
+
+	maintenanceCmd.AddCommand(maintenanceRunCommand())
+	maintenanceCmd.AddCommand(maintenanceUnlockCommand())
+	return maintenanceCmd
+}
+
+func maintenanceRunCommand() *cobra.Command {
+	var taskNames []string
+	var run *runFlags
+
+	runCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run one or more maintenance tasks",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			tasks := cmd.DefaultMaintenanceTasks(repo)
+			if len(taskNames) > 0 {
+				tasks = tasks[:0]
+				for _, name := range taskNames {
+					task, err := cmd.FindMaintenanceTask(name)
+					if err != nil {
+						return err
+					}
+					tasks = append(tasks, task)
+				}
+			}
+
+			for _, task := range tasks {
+				if run.DryRun {
+					fmt.Fprintf(run.Out(os.Stdout), "would run %s\n", task.Name)
+					continue
+				}
+				summary, err := cmd.RunMaintenanceTask(repo, task)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(run.Out(os.Stdout), "%s: %s\n", task.Name, summary)
+			}
+			return nil
+		},
+	}
+
+	runCmd.Flags().StringSliceVar(&taskNames, "task", nil,
+		"Run only the given task (gc, commit-graph, loose-objects, pack-refs); may be repeated")
+	run = addRunFlags(runCmd, runFlagUsage{
+		DryRun: "List the tasks that would run, without running them",
+		Quiet:  "Do not report task results",
+	})
+	return runCmd
+}
+
+func maintenanceUnlockCommand() *cobra.Command {
+	var expire string
+	var dryRun, force bool
+
+	unlockCmd := &cobra.Command{
+		Use:   "unlock",
+		Short: "Remove lock files left behind by crashed processes",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			cutoff, err := cmd.ParseExpiry(expire, time.Now())
+			if err != nil {
+				return err
+			}
+			locks, err := cmd.FindLocks(repo)
+			if err != nil {
+				return err
+			}
+
+			for _, lock := range locks {
+				if !force && !lock.Stale(cutoff) {
+					if lock.Alive {
+						fmt.Printf("skipping %s: held by running process %d\n", lock.Path, lock.PID)
+					} else {
+						fmt.Printf("skipping %s: too recent, use --expire or --force\n", lock.Path)
+					}
+					continue
+				}
+				if dryRun {
+					fmt.Printf("would remove %s\n", lock.Path)
+					continue
+				}
+				if err := cmd.RemoveLock(repo, lock); err != nil {
+					return err
+				}
+				fmt.Printf("removed %s\n", lock.Path)
+			}
+			return nil
+		},
+	}
+
+	unlockCmd.Flags().StringVar(&expire, "expire", cmd.DefaultStaleLockExpiry,
+		"Only remove locks last written before this time, e.g. 10.minutes.ago")
+	unlockCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "List the locks that would be removed")
+	unlockCmd.Flags().BoolVarP(&force, "force", "f", false, "Remove all locks, even recent ones or those of running processes")
+	return unlockCmd
+}
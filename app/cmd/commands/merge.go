@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// MergeCommand creates the `merge` command.
+func MergeCommand() *cobra.Command {
+	var opts cmd.MergeOptions
+	var messages []string
+	var verify, noVerify bool
+	var run *runFlags
+
+	mergeCmd := &cobra.Command{
+		Use:   "merge [--no-commit] [--no-ff | --ff-only] [--squash] [--[no-]verify-signatures] [-m <msg>] <commit>",
+		Short: "Merge a commit into the current branch",
+		Long: `Merge a commit into the current branch.
+
+The branch fast-forwards when it is behind the commit, unless --no-ff is
+given. Otherwise the changes since the merge base are merged and committed
+with both commits as parents. When they conflict, the merge stops with the
+conflicts in the index and the worktree; resolve them, add the files and run
+commit to conclude it. There must be no local changes.
+
+With --verify-signatures, or merge.verifySignatures, the commit must have a
+good signature to be merged.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			opts.Rev = args[0]
+			if len(messages) > 0 {
+				opts.Message = []byte(strings.Join(messages, "\n\n") + "\n")
+			}
+			opts.VerifySignatures = verify || (cmd.MergeVerifySignatures(repo) && !noVerify)
+			opts.HookOutput = os.Stderr
+
+			undo, err := cmd.SnapshotForUndo(repo, "merge", true)
+			if err != nil {
+				return err
+			}
+			result, err := cmd.Merge(repo, opts)
+			if err != nil {
+				return err
+			}
+			out := run.Out(os.Stdout)
+			objects := cmd.NewObjectManager(repo)
+			if result.Signature != nil {
+				fmt.Fprintf(out, "Commit %s has a good GPG signature by %s\n", objects.ShortSHA(result.Merged, 0), result.Signature.Signer)
+			}
+
+			switch {
+			case result.UpToDate:
+				fmt.Fprintln(out, "Already up to date.")
+				return nil
+			case result.FastForward:
+				if result.Head != "" {
+					fmt.Fprintf(out, "Updating %s..%s\nFast-forward\n", objects.ShortSHA(result.Head, 0), objects.ShortSHA(result.Merged, 0))
+					if err := printMergeStat(out, repo, objects, result.Head, result.Merged); err != nil {
+						return err
+					}
+				}
+			case result.Commit != nil:
+				fmt.Fprintln(out, "Merge made by the 'resolve' strategy.")
+				if err := printMergeStat(out, repo, objects, result.Head, result.Commit.SHA); err != nil {
+					return err
+				}
+			}
+			if result.FastForward || result.Commit != nil {
+				if err := undo.Record(); err != nil {
+					return err
+				}
+				return cmd.AutoMaintenance(repo, os.Stderr)
+			}
+
+			if len(result.Conflicts) == 0 {
+				fmt.Fprintln(out, "Automatic merge went well; stopped before committing as requested")
+			}
+			for _, conflict := range result.Conflicts {
+				fmt.Fprintln(out, describeMergeConflict(conflict, opts.Rev))
+			}
+			if opts.Squash {
+				fmt.Fprintln(out, "Squash commit -- not updating HEAD")
+			}
+			if len(result.Conflicts) == 0 {
+				return nil
+			}
+			// Like git, record the conflicts, or resolve them as before.
+			if cmd.RerereEnabled(repo) {
+				rerere, err := cmd.Rerere(repo)
+				if err != nil {
+					return err
+				}
+				printRerere(rerere)
+			}
+			fmt.Fprintln(out, "Automatic merge failed; fix conflicts and then commit the result.")
+			os.Exit(1)
+			return nil
+		},
+	}
+
+	flags := mergeCmd.Flags()
+	flags.StringArrayVarP(&messages, "message", "m", nil, "Use the given message for the merge commit; several are joined as paragraphs")
+	flags.BoolVarP(&opts.Edit, "edit", "e", false, "Edit the message of the merge commit first")
+	flags.BoolVar(&opts.NoCommit, "no-commit", false, "Stop before making the merge commit")
+	flags.BoolVar(&opts.NoFF, "no-ff", false, "Make a merge commit even when the branch could fast-forward")
+	flags.BoolVar(&opts.FFOnly, "ff-only", false, "Only fast-forward, refusing to merge otherwise")
+	flags.BoolVar(&opts.Squash, "squash", false, "Stage the merged changes for a commit of their own, without merging")
+	flags.BoolVar(&verify, "verify-signatures", false, "Refuse to merge a commit without a good signature")
+	flags.BoolVar(&noVerify, "no-verify-signatures", false, "Do not check the signature, whatever merge.verifySignatures says")
+	mergeCmd.MarkFlagsMutuallyExclusive("verify-signatures", "no-verify-signatures")
+	run = addRunFlags(mergeCmd, runFlagUsage{Quiet: "Do not report what was merged"})
+	return mergeCmd
+}
+
+// describeMergeConflict reports a conflicted path the way git does.
+func describeMergeConflict(conflict cmd.MergeConflict, theirs string) string {
+	switch {
+	case conflict.Ours == nil:
+		return fmt.Sprintf("CONFLICT (modify/delete): %s deleted in HEAD and modified in %s.  Version %s of %s left in tree.", conflict.Path, theirs, theirs, conflict.Path)
+	case conflict.Theirs == nil:
+		return fmt.Sprintf("CONFLICT (modify/delete): %s deleted in %s and modified in HEAD.  Version HEAD of %s left in tree.", conflict.Path, theirs, conflict.Path)
+	case conflict.Base == nil:
+		return fmt.Sprintf("CONFLICT (add/add): Merge conflict in %s", conflict.Path)
+	}
+	return fmt.Sprintf("CONFLICT (content): Merge conflict in %s", conflict.Path)
+}
+
+// printMergeStat prints the diffstat of what a merge brought in, between
+// the commits HEAD was at before and after it.
+func printMergeStat(out io.Writer, repo *cmd.GitRepository, objects *cmd.ObjectManager, from, to string) error {
+	var files [2]map[string]cmd.TreeEntry
+	for i, sha := range []string{from, to} {
+		commit, err := objects.ReadCommit(sha)
+		if err != nil {
+			return err
+		}
+		if files[i], err = objects.FlattenTree(commit.Tree); err != nil {
+			return err
+		}
+	}
+	changes := cmd.DiffFileSets(files[0], files[1])
+	patches, err := objects.Patches(changes, "", 0)
+	if err != nil {
+		return err
+	}
+	quotePath := cmd.QuotePathEnabled(repo)
+	for _, line := range cmd.FormatStat(cmd.DiffStats(patches), cmd.DefaultStatWidth, quotePath) {
+		fmt.Fprintln(out, line)
+	}
+	printModeChanges(out, changes, quotePath)
+	return nil
+}
@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// MergeToolCommand creates the `mergetool` command.
+func MergeToolCommand() *cobra.Command {
+	var toolName string
+	var noPrompt, prompt bool
+
+	mergetoolCmd := &cobra.Command{
+		Use:   "mergetool [-t <tool>] [-y] [<file>...]",
+		Short: "Resolve merge conflicts with an external merge tool",
+		Long: `Resolve merge conflicts with an external merge tool.
+
+For each conflicted file the tool, merge.tool, runs the shell command
+mergetool.<tool>.cmd with $BASE, $LOCAL and $REMOTE naming temporary copies
+of the common ancestor, our and their version, and $MERGED the worktree file
+to write the result to. A file the tool resolves is staged.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			if repo.IsBare() {
+				return fmt.Errorf("this operation must be run in a work tree")
+			}
+			tool, err := cmd.MergeTool(repo, toolName)
+			if err != nil {
+				return err
+			}
+			if command.Flags().Changed("prompt") || command.Flags().Changed("no-prompt") {
+				tool.Prompt = prompt && !noPrompt
+			}
+
+			index, err := cmd.ReadIndex(repo)
+			if err != nil {
+				return err
+			}
+			paths, err := unmergedPaths(repo, index, args)
+			if err != nil {
+				return err
+			}
+			if len(paths) == 0 {
+				fmt.Println("No files need merging")
+				return nil
+			}
+			fmt.Printf("Merging:\n%s\n", strings.Join(paths, "\n"))
+
+			dir, err := os.MkdirTemp("", "justdoit-mergetool-")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dir)
+
+			answers := bufio.NewReader(os.Stdin)
+			var failed []string
+			for _, file := range paths {
+				fmt.Println()
+				resolved, err := mergeFile(repo, index, tool, file, dir, answers)
+				if err != nil {
+					return err
+				}
+				if !resolved {
+					failed = append(failed, file)
+					continue
+				}
+				// Staged one at a time so that work survives a later failure,
+				// and the index is only locked while staging, not while the
+				// tool runs.
+				lock, staged, err := cmd.LockIndex(repo)
+				if err != nil {
+					return err
+				}
+				if err := cmd.StagePath(repo, staged, file, os.Stderr); err != nil {
+					lock.Release()
+					return err
+				}
+				if err := lock.Write(staged); err != nil {
+					return err
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("merge of %s failed", strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+
+	mergetoolCmd.Flags().StringVarP(&toolName, "tool", "t", "", "Use this tool instead of merge.tool")
+	mergetoolCmd.Flags().BoolVarP(&noPrompt, "no-prompt", "y", false, "Do not ask before launching the tool")
+	mergetoolCmd.Flags().BoolVar(&prompt, "prompt", false, "Ask before launching the tool for each file")
+	return mergetoolCmd
+}
+
+// unmergedPaths lists the conflicted paths of the index, limited to the
+// given files or directories when there are any.
+func unmergedPaths(repo *cmd.GitRepository, index *cmd.Index, args []string) ([]string, error) {
+	var filters []string
+	for _, arg := range args {
+		rel := worktreeRelative(repo, arg)
+		if rel == "" {
+			return nil, fmt.Errorf("'%s' is outside repository", arg)
+		}
+		filters = append(filters, rel)
+	}
+
+	var paths []string
+	for _, entry := range index.Entries {
+		if entry.Stage() == 0 || (len(paths) > 0 && paths[len(paths)-1] == entry.Name) {
+			continue
+		}
+		if len(filters) == 0 || matchesPathFilter(entry.Name, filters) {
+			paths = append(paths, entry.Name)
+		}
+	}
+	return paths, nil
+}
+
+// matchesPathFilter reports whether name is one of filters or lies below
+// one of them. The filter "." matches everything.
+func matchesPathFilter(name string, filters []string) bool {
+	for _, filter := range filters {
+		if filter == "." || name == filter || strings.HasPrefix(name, filter+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeFile runs the merge tool on one conflicted file.
+//
+// Returns:
+// - Whether the conflict was resolved.
+// - An error if the versions cannot be extracted or an answer not read.
+func mergeFile(repo *cmd.GitRepository, index *cmd.Index, tool *cmd.ExternalTool, file, dir string, answers *bufio.Reader) (bool, error) {
+	stages := index.Stages(file)
+	if stages[2] == nil || stages[3] == nil {
+		fmt.Printf("Deleted merge conflict for '%s'; resolve it by staging or removing the file\n", file)
+		return false, nil
+	}
+	change := "modified file"
+	if stages[1] == nil {
+		change = "created file"
+	}
+	fmt.Printf("Normal merge conflict for '%s':\n", file)
+	fmt.Printf("  {local}: %s\n", change)
+	fmt.Printf("  {remote}: %s\n", change)
+	if tool.Prompt {
+		fmt.Printf("Hit return to start merge resolution tool (%s): ", tool.Name)
+		if _, err := answers.ReadString('\n'); err != nil {
+			return false, err
+		}
+	}
+
+	// The versions are named like git's, file_BASE.ext and so on, so that
+	// tools showing file names or highlighting by extension still can.
+	ext := path.Ext(file)
+	stem := strings.TrimSuffix(path.Base(file), ext)
+	run := cmd.ToolRun{
+		Merged: file,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	versions := []struct {
+		stage int
+		name  string
+		file  *string
+	}{{1, "BASE", &run.Base}, {2, "LOCAL", &run.Local}, {3, "REMOTE", &run.Remote}}
+	for _, version := range versions {
+		*version.file = filepath.Join(dir, version.name, stem+"_"+version.name+ext)
+		if stages[version.stage] == nil {
+			// A conflict without a common ancestor, such as add/add.
+			if err := os.MkdirAll(filepath.Dir(*version.file), 0700); err != nil {
+				return false, err
+			}
+			if err := os.WriteFile(*version.file, nil, 0600); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if err := cmd.NewObjectManager(repo).ExtractToolFile(stages[version.stage].TreeEntry(), *version.file); err != nil {
+			return false, err
+		}
+	}
+
+	merged := filepath.Join(repo.WorkTree, filepath.FromSlash(file))
+	before, err := os.ReadFile(merged)
+	if err != nil {
+		return false, err
+	}
+	if tool.KeepBackup {
+		if err := os.WriteFile(merged+".orig", before, 0644); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tool.Run(repo, run); err != nil {
+		fmt.Println(err)
+		return false, nil
+	}
+	if tool.TrustExitCode {
+		return true, nil
+	}
+
+	after, err := os.ReadFile(merged)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(before, after) {
+		return true, nil
+	}
+	fmt.Printf("%s seems unchanged.\nWas the merge successful [y/n]? ", file)
+	answer, err := answers.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y"), nil
+}
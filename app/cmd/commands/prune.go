@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// PruneCommand creates the `prune` command.
+func PruneCommand() *cobra.Command {
+	var expire string
+	var run *runFlags
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove unreachable loose objects from the object database",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			cutoff, err := cmd.ParseExpiry(expire, time.Now())
+			if err != nil {
+				return err
+			}
+
+			pruned, err := cmd.Prune(repo, cmd.PruneOptions{Expire: cutoff, DryRun: run.DryRun})
+			if err != nil {
+				return err
+			}
+
+			if run.DryRun || run.Verbose {
+				for _, object := range pruned {
+					fmt.Printf("%s %s\n", object.SHA, object.Type)
+				}
+			}
+			return nil
+		},
+	}
+
+	pruneCmd.Flags().StringVar(&expire, "expire", "now",
+		"Only remove unreachable objects older than this time, e.g. 2.weeks.ago")
+	run = addRunFlags(pruneCmd, runFlagUsage{
+		DryRun:  "List the objects that would be removed",
+		Verbose: "Report all removed objects",
+	})
+	return pruneCmd
+}
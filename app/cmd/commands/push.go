@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// leaseEveryRef stands for a bare --force-with-lease, which protects every
+// ref pushed.
+const leaseEveryRef = "*"
+
+// PushCommand creates the `push` command.
+func PushCommand() *cobra.Command {
+	var opts cmd.PushOptions
+	var run *runFlags
+
+	pushCmd := &cobra.Command{
+		Use:   "push [<repository> [<refspec>...]]",
+		Short: "Update remote refs along with the objects they need",
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			remote := "origin"
+			if branch, ok := cmd.CurrentBranch(repo); ok {
+				if upstream, ok := cmd.BranchUpstream(repo, branch); ok && upstream.Remote != "." {
+					remote = upstream.Remote
+				}
+			}
+			if len(args) > 0 {
+				remote, opts.Refspecs = args[0], args[1:]
+			}
+			for i, lease := range opts.Leases {
+				if lease == leaseEveryRef {
+					opts.Leases[i] = ""
+				}
+			}
+			opts.Messages = os.Stderr
+			opts.DryRun = run.DryRun
+			fmt.Fprintf(run.Detail(os.Stderr), "Pushing to %s\n", cmd.AnonymizeURL(cmd.ResolvePushURL(repo, remote)))
+
+			result, err := cmd.Push(repo, remote, opts)
+			if err != nil {
+				return err
+			}
+
+			rejected := false
+			printed := false
+			changed := false
+			for _, ref := range result.Refs {
+				rejected = rejected || ref.Rejected()
+				changed = changed || ref.Status != cmd.PushUpToDate
+				shown := !run.Quiet || ref.Rejected()
+				if ref.Status == cmd.PushUpToDate {
+					shown = run.Verbose
+				}
+				if !shown {
+					continue
+				}
+				if !printed {
+					fmt.Fprintf(os.Stderr, "To %s\n", result.URL)
+					printed = true
+				}
+				fmt.Fprintln(os.Stderr, formatPushedRef(ref))
+			}
+			if !changed {
+				fmt.Fprintln(run.Out(os.Stderr), "Everything up-to-date")
+			}
+			for _, ref := range result.Refs {
+				if ref.Upstream != nil {
+					fmt.Fprintf(run.Out(os.Stdout), "branch '%s' set up to track '%s'.\n", shortRefName(ref.Local), ref.Upstream.ShortName())
+				}
+			}
+
+			if rejected {
+				return fmt.Errorf("failed to push some refs to '%s'", result.URL)
+			}
+			return nil
+		},
+	}
+
+	flags := pushCmd.Flags()
+	flags.BoolVarP(&opts.Force, "force", "f", false, "Allow remote refs to be updated even when they do not fast-forward")
+	flags.StringArrayVar(&opts.Leases, "force-with-lease", nil, "Force updates only while the remote ref still has the expected value, <ref>[:<expect>]")
+	flags.Lookup("force-with-lease").NoOptDefVal = leaseEveryRef
+	flags.BoolVarP(&opts.Delete, "delete", "d", false, "Delete the named refs from the remote")
+	flags.BoolVar(&opts.Tags, "tags", false, "Push every tag")
+	flags.BoolVar(&opts.Mirror, "mirror", false, "Make every ref of the remote match the local refs, deleting remote refs that do not exist locally")
+	flags.BoolVarP(&opts.SetUpstream, "set-upstream", "u", false, "Make each pushed branch track the remote branch")
+	flags.BoolVar(&opts.Atomic, "atomic", false, "Update either every remote ref or none of them")
+	flags.StringArrayVarP(&opts.Options, "push-option", "o", nil, "Send an option to the hooks of the remote")
+	flags.StringVar(&opts.ReceivePack, "receive-pack", "", "Path of the receive-pack program on the remote host")
+	run = addRunFlags(pushCmd, runFlagUsage{
+		DryRun:  "Show what would be pushed, without sending anything",
+		Verbose: "Also report refs that are up to date",
+		Quiet:   "Only report refs that could not be pushed",
+	})
+	return pushCmd
+}
+
+// formatPushedRef renders one line of push output in git's layout, e.g.
+// "   1a2b3c4..5d6e7f8  main -> main".
+func formatPushedRef(ref cmd.PushedRef) string {
+	flag, summary, note := " ", "", ""
+	switch ref.Status {
+	case cmd.PushUpToDate:
+		flag, summary = "=", "[up to date]"
+	case cmd.PushNewBranch, cmd.PushNewTag, cmd.PushNewRef:
+		flag, summary = "*", "["+ref.Status+"]"
+	case cmd.PushDeleted:
+		flag, summary = "-", "[deleted]"
+	case cmd.PushFastForward:
+		summary = ref.Old[:7] + ".." + ref.New[:7]
+	case cmd.PushForced:
+		flag, summary, note = "+", ref.Old[:7]+"..."+ref.New[:7], " (forced update)"
+	case cmd.PushRemoteRejected:
+		flag, summary, note = "!", "[remote rejected]", " ("+ref.Reason+")"
+	case cmd.PushRemoteFailure:
+		flag, summary, note = "!", "[remote failure]", " (remote failed to report status)"
+	default:
+		flag, summary, note = "!", "[rejected]", " ("+ref.Status+")"
+	}
+
+	if ref.Local == "" {
+		return fmt.Sprintf(" %s %-17s %s%s", flag, summary, shortRefName(ref.Remote), note)
+	}
+	return fmt.Sprintf(" %s %-17s %s -> %s%s", flag, summary, shortRefName(ref.Local), shortRefName(ref.Remote), note)
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// RebaseCommand creates the `rebase` command.
+func RebaseCommand() *cobra.Command {
+	var opts cmd.RebaseOptions
+	var autosquash, noAutosquash bool
+
+	rebaseCmd := &cobra.Command{
+		Use:   "rebase [-i] [--autosquash] [--onto <newbase>] [<upstream>]",
+		Short: "Replay the commits of the current branch on top of another commit",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			head, err := cmd.ResolveHEAD(repo)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				opts.Upstream = args[0]
+			} else {
+				upstream, ok := cmd.BranchUpstream(repo, head.Branch())
+				if head.Detached() || !ok {
+					return fmt.Errorf("there is no tracking information for the current branch; please specify which branch you want to rebase against")
+				}
+				opts.Upstream = upstream.Ref
+			}
+			// Like git, rebase.autoSquash only applies to interactive rebases.
+			opts.Autosquash = autosquash || (cmd.AutosquashEnabled(repo) && !noAutosquash)
+
+			undo, err := cmd.SnapshotForUndo(repo, "rebase", true)
+			if err != nil {
+				return err
+			}
+			result, err := cmd.Rebase(repo, opts)
+			if err != nil {
+				return err
+			}
+			if err := undo.Record(); err != nil {
+				return err
+			}
+			printRerere(&cmd.RerereResult{Resolved: result.Resolved})
+			switch {
+			case result.UpToDate && result.Branch != "":
+				fmt.Printf("Current branch %s is up to date.\n", result.Branch)
+			case result.UpToDate:
+				fmt.Println("HEAD is up to date.")
+			case result.Branch != "":
+				fmt.Fprintf(os.Stderr, "Successfully rebased and updated %s%s.\n", cmd.BranchesPrefix, result.Branch)
+			default:
+				fmt.Fprintln(os.Stderr, "Successfully rebased and updated detached HEAD.")
+			}
+			return cmd.AutoMaintenance(repo, os.Stderr)
+		},
+	}
+
+	flags := rebaseCmd.Flags()
+	flags.BoolVarP(&opts.Interactive, "interactive", "i", false, "Edit the list of commits to replay first")
+	flags.BoolVar(&autosquash, "autosquash", false, "With -i, move fixup! and squash! commits after their targets and mark them")
+	flags.BoolVar(&noAutosquash, "no-autosquash", false, "Do not rearrange fixup! and squash! commits, whatever rebase.autoSquash says")
+	flags.StringVar(&opts.Onto, "onto", "", "Replay the commits on top of this commit instead of the upstream")
+	return rebaseCmd
+}
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// ReceivePackCommand creates the `receive-pack` command.
+func ReceivePackCommand() *cobra.Command {
+	var opts cmd.ReceivePackOptions
+
+	receivePackCmd := &cobra.Command{
+		Use:   "receive-pack <directory>",
+		Short: "Receive what is pushed into the repository over stdin/stdout",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.EnterRepository(args[0])
+			if err != nil {
+				return err
+			}
+			opts.HookOutput = os.Stderr
+			return cmd.ReceivePack(repo, os.Stdin, os.Stdout, opts)
+		},
+	}
+
+	receivePackCmd.Flags().BoolVar(&opts.StatelessRPC, "stateless-rpc", false, "Serve a single request/response exchange without advertising refs")
+	receivePackCmd.Flags().BoolVar(&opts.AdvertiseRefsOnly, "advertise-refs", false, "Only advertise the refs and exit")
+	return receivePackCmd
+}
@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// RemoteCommand creates the `remote` command and its subcommands. Without
+// a subcommand it lists the configured remotes.
+func RemoteCommand() *cobra.Command {
+	var verbose bool
+
+	remoteCmd := &cobra.Command{
+		Use:   "remote",
+		Short: "List and inspect the remotes of the repository",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			for _, name := range cmd.RemoteNames(repo) {
+				if !verbose {
+					fmt.Println(name)
+					continue
+				}
+				fmt.Printf("%s\t%s (fetch)\n", name, cmd.ResolveRemoteURL(repo, name))
+				fmt.Printf("%s\t%s (push)\n", name, cmd.ResolvePushURL(repo, name))
+			}
+			return nil
+		},
+	}
+
+	remoteCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show the URL of each remote")
+	remoteCmd.AddCommand(remoteShowCommand())
+	remoteCmd.AddCommand(remotePruneCommand())
+	return remoteCmd
+}
+
+func remoteShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <name>...",
+		Short: "Show the branches of a remote and how local branches relate to them",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			for _, name := range args {
+				info, err := cmd.ShowRemote(repo, name)
+				if err != nil {
+					return err
+				}
+				printRemoteInfo(info)
+			}
+			return nil
+		},
+	}
+}
+
+// printRemoteInfo prints a remote in the layout of git remote show.
+func printRemoteInfo(info *cmd.RemoteInfo) {
+	fmt.Printf("* remote %s\n", info.Name)
+	fmt.Printf("  Fetch URL: %s\n", info.FetchURL)
+	fmt.Printf("  Push  URL: %s\n", info.PushURL)
+	head := info.HeadBranch
+	if head == "" {
+		head = "(unknown)"
+	}
+	fmt.Printf("  HEAD branch: %s\n", head)
+
+	if len(info.Branches) > 0 {
+		fmt.Printf("  Remote %s:\n", plural(len(info.Branches), "branch", "branches"))
+		// Like git, a stale ref is shown by its own name, since the branch
+		// it followed is gone.
+		names := make([]string, len(info.Branches))
+		width := 0
+		for i, branch := range info.Branches {
+			names[i] = shortRefName(branch.Name)
+			if branch.State == cmd.RemoteBranchStale {
+				names[i] = branch.Tracking
+			}
+			width = max(width, len(names[i]))
+		}
+		for i, branch := range info.Branches {
+			state := branch.State
+			switch branch.State {
+			case cmd.RemoteBranchNew:
+				if branch.Tracking == "" {
+					break
+				}
+				state = fmt.Sprintf("new (next fetch will store in %s)", path.Dir(strings.TrimPrefix(branch.Tracking, "refs/")))
+			case cmd.RemoteBranchStale:
+				state = "stale (use 'justdoit remote prune' to remove)"
+			}
+			fmt.Printf("    %-*s %s\n", width, names[i], state)
+		}
+	}
+
+	if len(info.Merges) > 0 {
+		fmt.Printf("  Local %s configured for 'git pull':\n", plural(len(info.Merges), "branch", "branches"))
+		width := 0
+		for _, upstream := range info.Merges {
+			width = max(width, len(shortRefName(upstream.Ref)))
+		}
+		for _, upstream := range info.Merges {
+			fmt.Printf("    %-*s merges with remote %s\n", width, shortRefName(upstream.Ref), shortRefName(upstream.Merge))
+		}
+	}
+
+	if len(info.Pushes) > 0 {
+		fmt.Printf("  Local %s configured for 'git push':\n", plural(len(info.Pushes), "ref", "refs"))
+		width, remoteWidth := 0, 0
+		for _, target := range info.Pushes {
+			width = max(width, len(shortRefName(target.Local)))
+			remoteWidth = max(remoteWidth, len(shortRefName(target.Remote)))
+		}
+		for _, target := range info.Pushes {
+			fmt.Printf("    %-*s pushes to %-*s (%s)\n", width, shortRefName(target.Local), remoteWidth, shortRefName(target.Remote), target.State)
+		}
+	}
+}
+
+// plural picks the singular or plural form of a word for count.
+func plural(count int, singular, pluralForm string) string {
+	if count == 1 {
+		return singular
+	}
+	return pluralForm
+}
+
+func remotePruneCommand() *cobra.Command {
+	var dryRun bool
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune <name>...",
+		Short: "Delete remote-tracking refs of branches the remote no longer has",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			for _, name := range args {
+				pruned, err := cmd.PruneRemote(repo, name, dryRun)
+				if err != nil {
+					return err
+				}
+				if len(pruned) == 0 {
+					continue
+				}
+
+				fmt.Printf("Pruning %s\n", name)
+				fmt.Printf("URL: %s\n", cmd.ResolveRemoteURL(repo, name))
+				verb := "[pruned]"
+				if dryRun {
+					verb = "[would prune]"
+				}
+				for _, ref := range pruned {
+					fmt.Printf(" * %s %s\n", verb, shortRefName(ref))
+				}
+			}
+			return nil
+		},
+	}
+
+	pruneCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only report what would be pruned")
+	return pruneCmd
+}
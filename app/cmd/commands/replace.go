@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// ReplaceCommand creates the `replace` command.
+func ReplaceCommand() *cobra.Command {
+	var force, del, list bool
+	var format string
+
+	replaceCmd := &cobra.Command{
+		Use:   "replace [-f] <object> <replacement> | -d <object>... | [-l [<pattern>]]",
+		Short: "Create, list or delete refs to replace objects",
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case del:
+				if len(args) == 0 {
+					return fmt.Errorf("-d needs at least one argument")
+				}
+				for _, arg := range args {
+					object, err := cmd.ResolveRevision(repo, arg)
+					if err != nil {
+						return err
+					}
+					if err := cmd.DeleteReplacement(repo, object); err != nil {
+						return err
+					}
+					fmt.Printf("Deleted replace ref '%s'\n", object)
+				}
+				return nil
+
+			case !list && len(args) > 0:
+				if len(args) != 2 {
+					return fmt.Errorf("bad number of arguments")
+				}
+				object, err := cmd.ResolveRevision(repo, args[0])
+				if err != nil {
+					return err
+				}
+				replacement, err := cmd.ResolveRevision(repo, args[1])
+				if err != nil {
+					return err
+				}
+				return cmd.ReplaceObject(repo, object, replacement, force)
+			}
+
+			if len(args) > 1 {
+				return fmt.Errorf("only one pattern can be given with -l")
+			}
+			pattern := ""
+			if len(args) == 1 {
+				pattern = args[0]
+			}
+			return listReplacements(repo, pattern, format)
+		},
+	}
+
+	replaceCmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite an existing replacement")
+	replaceCmd.Flags().BoolVarP(&del, "delete", "d", false, "Delete the replace refs of the given objects")
+	replaceCmd.Flags().BoolVarP(&list, "list", "l", false, "List replace refs matching the pattern")
+	replaceCmd.Flags().StringVar(&format, "format", "short", "Listing format: short, medium or long")
+	replaceCmd.MarkFlagsMutuallyExclusive("delete", "list")
+	return replaceCmd
+}
+
+// listReplacements prints the replaced objects matching pattern. The medium
+// format adds the replacement, the long format also the object types.
+func listReplacements(repo *cmd.GitRepository, pattern, format string) error {
+	switch format {
+	case "short", "medium", "long":
+	default:
+		return fmt.Errorf("invalid replace format '%s': valid formats are 'short', 'medium' and 'long'", format)
+	}
+
+	replacements, err := cmd.ListReplacements(repo, pattern)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(replacements))
+	for object := range replacements {
+		names = append(names, object)
+	}
+	sort.Strings(names)
+
+	objects := cmd.NewObjectManager(repo)
+	for _, object := range names {
+		replacement := replacements[object]
+		switch format {
+		case "short":
+			fmt.Println(object)
+		case "medium":
+			fmt.Printf("%s -> %s\n", object, replacement)
+		case "long":
+			objType, _, _ := objects.StatObject(object)
+			replacementType, _, _ := objects.StatObject(replacement)
+			fmt.Printf("%s (%s) -> %s (%s)\n", object, orUnknown(objType), replacement, orUnknown(replacementType))
+		}
+	}
+	return nil
+}
+
+// orUnknown names a type that could not be determined.
+func orUnknown(objType cmd.GitObjectType) string {
+	if objType == "" {
+		return "unknown"
+	}
+	return string(objType)
+}
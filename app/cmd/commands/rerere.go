@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// RerereCommand creates the `rerere` command and its subcommands. Without
+// a subcommand it records the current conflicts and their resolutions, and
+// reuses recorded resolutions.
+func RerereCommand() *cobra.Command {
+	rerereCmd := &cobra.Command{
+		Use:   "rerere",
+		Short: "Reuse recorded resolutions of conflicted merges",
+		Long: `Reuse recorded resolutions of conflicted merges.
+
+With rerere.enabled, the conflicts of every unmerged file are recorded under
+rr-cache, and the file as the user resolves it is recorded as their
+resolution. When the same conflict shows up again, the resolution is applied
+to the file, and also to the merges of rebase. commit records resolutions by
+itself; this command can be run to record or reuse them at any time.`,
+		Args: cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			if !cmd.RerereEnabled(repo) {
+				return nil
+			}
+
+			result, err := cmd.Rerere(repo)
+			if err != nil {
+				return err
+			}
+			printRerere(result)
+			return nil
+		},
+	}
+
+	rerereCmd.AddCommand(rerereStatusCommand())
+	rerereCmd.AddCommand(rerereForgetCommand())
+	rerereCmd.AddCommand(rerereClearCommand())
+	rerereCmd.AddCommand(rerereGCCommand())
+	return rerereCmd
+}
+
+func rerereStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "List the conflicted paths whose resolution will be recorded",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			paths, err := cmd.RerereStatus(repo)
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				fmt.Println(path)
+			}
+			return nil
+		},
+	}
+}
+
+func rerereForgetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "forget <path>...",
+		Short: "Drop the recorded resolutions of the conflicts in the given files",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			for _, path := range args {
+				if err := cmd.RerereForget(repo, path); err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", err)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "Updated preimage for '%s'\n", path)
+				fmt.Fprintf(os.Stderr, "Forgot resolution for '%s'\n", path)
+			}
+			return nil
+		},
+	}
+}
+
+func rerereClearCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Forget the conflicts that are waiting for a resolution",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			return cmd.RerereClear(repo)
+		},
+	}
+}
+
+func rerereGCCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "gc",
+		Short: "Remove old recorded conflicts and resolutions",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			_, err = cmd.RerereGC(repo, time.Now())
+			return err
+		},
+	}
+}
+
+// printRerere reports what rerere did, in git's words.
+func printRerere(result *cmd.RerereResult) {
+	for _, path := range result.Resolutions {
+		fmt.Fprintf(os.Stderr, "Recorded resolution for '%s'.\n", path)
+	}
+	for _, path := range result.Recorded {
+		fmt.Fprintf(os.Stderr, "Recorded preimage for '%s'\n", path)
+	}
+	for _, path := range result.Resolved {
+		if slices.Contains(result.Staged, path) {
+			fmt.Fprintf(os.Stderr, "Staged '%s' using previous resolution.\n", path)
+		} else {
+			fmt.Fprintf(os.Stderr, "Resolved '%s' using previous resolution.\n", path)
+		}
+	}
+}
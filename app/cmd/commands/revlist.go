@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// refSelectors holds the --all, --branches, --tags and --remotes flags that
+// add refs to a revision range.
+type refSelectors struct {
+	all      bool
+	branches string
+	tags     string
+	remotes  string
+}
+
+// addFlags registers the ref selector flags. The pattern is optional; a
+// bare --branches selects every branch.
+func (s *refSelectors) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&s.all, "all", false, "Pretend all refs and HEAD are listed on the command line")
+	flags.StringVar(&s.branches, "branches", "", "Pretend all branches matching the pattern are listed")
+	flags.StringVar(&s.tags, "tags", "", "Pretend all tags matching the pattern are listed")
+	flags.StringVar(&s.remotes, "remotes", "", "Pretend all remote-tracking branches matching the pattern are listed")
+	for _, name := range []string{"branches", "tags", "remotes"} {
+		flags.Lookup(name).NoOptDefVal = "*"
+	}
+}
+
+// given reports whether any selector was used, even one that matched no
+// refs; commands then do not fall back to HEAD.
+func (s *refSelectors) given() bool {
+	return s.all || s.branches != "" || s.tags != "" || s.remotes != ""
+}
+
+// revisionRange parses the revision arguments and adds the selected refs.
+//
+// Returns:
+// - The range, which is empty if nothing was given.
+// - An error if a revision cannot be resolved.
+func (s *refSelectors) revisionRange(repo *cmd.GitRepository, args []string) (*cmd.RevisionRange, error) {
+	revs, err := cmd.ParseRevisionRange(repo, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.all {
+		if err := revs.AddRefs(repo, cmd.RefsDir+"/", ""); err != nil {
+			return nil, err
+		}
+	}
+	selectors := []struct{ prefix, pattern string }{
+		{cmd.BranchesPrefix, s.branches},
+		{"refs/tags/", s.tags},
+		{"refs/remotes/", s.remotes},
+	}
+	for _, selector := range selectors {
+		if selector.pattern == "" {
+			continue
+		}
+		if err := revs.AddRefs(repo, selector.prefix, selector.pattern); err != nil {
+			return nil, err
+		}
+	}
+	return revs, nil
+}
+
+// walkFlags holds the flags that choose and order the commits of a walk.
+type walkFlags struct {
+	topoOrder   bool
+	dateOrder   bool
+	reverse     bool
+	firstParent bool
+	merges      bool
+	noMerges    bool
+}
+
+// addFlags registers the ordering flags.
+func (w *walkFlags) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&w.topoOrder, "topo-order", false, "Show no parents before all of their children, without interleaving lines of history")
+	flags.BoolVar(&w.dateOrder, "date-order", false, "Show no parents before all of their children, otherwise in commit date order")
+	flags.BoolVar(&w.reverse, "reverse", false, "Output the selected commits in reverse order")
+	flags.BoolVar(&w.firstParent, "first-parent", false, "Follow only the first parent of merge commits")
+	flags.BoolVar(&w.merges, "merges", false, "Print only merge commits")
+	flags.BoolVar(&w.noMerges, "no-merges", false, "Do not print commits with more than one parent")
+}
+
+// options converts the flags to walk options. Reverse is left to the caller,
+// since git limits the number of commits before reversing them.
+func (w *walkFlags) options() cmd.RevListOptions {
+	opts := cmd.RevListOptions{FirstParent: w.firstParent, Merges: w.merges, NoMerges: w.noMerges}
+	switch {
+	case w.topoOrder:
+		opts.Order = cmd.OrderTopo
+	case w.dateOrder:
+		opts.Order = cmd.OrderDate
+	}
+	return opts
+}
+
+// limit keeps at most maxCount commits, unless it is negative, and then
+// applies --reverse.
+func (w *walkFlags) limit(commits []string, maxCount int) []string {
+	if maxCount >= 0 && len(commits) > maxCount {
+		commits = commits[:maxCount]
+	}
+	if w.reverse {
+		slices.Reverse(commits)
+	}
+	return commits
+}
+
+// RevListCommand creates the `rev-list` command.
+func RevListCommand() *cobra.Command {
+	var selectors refSelectors
+	var walk walkFlags
+	var maxCount int
+	var count bool
+
+	revListCmd := &cobra.Command{
+		Use:   "rev-list <commit>...",
+		Short: "List commits in reverse chronological order",
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			revs, err := selectors.revisionRange(repo, args)
+			if err != nil {
+				return err
+			}
+			if revs.Empty() && !selectors.given() {
+				return errors.New("rev-list needs at least one revision")
+			}
+
+			commits, err := cmd.NewObjectManager(repo).UseReplaceRefs().UseGrafts().RevList(revs.Include, revs.Exclude, walk.options())
+			if err != nil {
+				return err
+			}
+			commits = walk.limit(commits, maxCount)
+
+			if count {
+				fmt.Println(len(commits))
+				return nil
+			}
+			for _, sha := range commits {
+				fmt.Println(sha)
+			}
+			return nil
+		},
+	}
+
+	revListCmd.Flags().IntVarP(&maxCount, "max-count", "n", -1, "Limit the number of commits to output")
+	revListCmd.Flags().BoolVar(&count, "count", false, "Print only the number of commits")
+	selectors.addFlags(revListCmd.Flags())
+	walk.addFlags(revListCmd.Flags())
+	return revListCmd
+}
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// runFlags are the --dry-run, --verbose and --quiet flags shared by the
+// commands that change the repository. A command registers the ones it
+// supports with addRunFlags and prints through Out and Detail, so that
+// they behave the same everywhere: --quiet silences the usual report,
+// --verbose adds what is usually left out, such as refs that are already
+// up to date, and --dry-run reports what would be done without doing it.
+// Tracing is the global --trace, so --verbose only changes the report.
+type runFlags struct {
+	DryRun  bool
+	Verbose bool
+	Quiet   bool
+}
+
+// runFlagUsage is the help text of each run flag for a command. Flags
+// without one are not registered.
+type runFlagUsage struct {
+	DryRun  string
+	Verbose string
+	Quiet   string
+}
+
+// addRunFlags registers the run flags of command. Each flag gets its usual
+// shorthand, -n, -v or -q, unless the command already uses it, as fetch
+// does -n for --no-tags; the other flags of the command must therefore be
+// registered first.
+//
+// Parameters:
+// - command: The command the flags are added to.
+// - usage: The help of the flags to add.
+//
+// Returns:
+// - The values of the flags, set once the command line is parsed.
+func addRunFlags(command *cobra.Command, usage runFlagUsage) *runFlags {
+	run := &runFlags{}
+	flags := command.Flags()
+	add := func(value *bool, name, shorthand, usage string) {
+		if usage == "" {
+			return
+		}
+		if flags.ShorthandLookup(shorthand) != nil {
+			shorthand = ""
+		}
+		flags.BoolVarP(value, name, shorthand, false, usage)
+	}
+	add(&run.DryRun, "dry-run", "n", usage.DryRun)
+	add(&run.Verbose, "verbose", "v", usage.Verbose)
+	add(&run.Quiet, "quiet", "q", usage.Quiet)
+	if usage.Verbose != "" && usage.Quiet != "" {
+		command.MarkFlagsMutuallyExclusive("verbose", "quiet")
+	}
+	return run
+}
+
+// Out returns w for the usual report of the command, or a writer that
+// discards it with --quiet.
+func (r *runFlags) Out(w io.Writer) io.Writer {
+	if r.Quiet {
+		return io.Discard
+	}
+	return w
+}
+
+// Detail returns w for what is only reported with --verbose, or a writer
+// that discards it otherwise.
+func (r *runFlags) Detail(w io.Writer) io.Writer {
+	if !r.Verbose {
+		return io.Discard
+	}
+	return w
+}
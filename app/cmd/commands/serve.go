@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// serveAuthEnv holds "user:password" credentials so they need not appear on
+// the command line.
+const serveAuthEnv = "JUSTDOIT_SERVE_AUTH"
+
+// ServeCommand creates the `serve` command.
+func ServeCommand() *cobra.Command {
+	var opts cmd.HTTPServerOptions
+	var addr string
+	var auth string
+
+	serveCmd := &cobra.Command{
+		Use:   "serve --http <addr>",
+		Short: "Serve repositories over the smart HTTP protocol",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			if addr == "" {
+				return fmt.Errorf("an address is required, e.g. --http :8080")
+			}
+
+			if auth == "" {
+				auth = os.Getenv(serveAuthEnv)
+			}
+			if auth != "" {
+				var ok bool
+				opts.Username, opts.Password, ok = strings.Cut(auth, ":")
+				if !ok || opts.Username == "" {
+					return fmt.Errorf("invalid credentials, expected user:password")
+				}
+			}
+
+			return cmd.RunHTTPServer(addr, opts, log.New(os.Stderr, "justdoit-serve: ", log.LstdFlags))
+		},
+	}
+
+	serveCmd.Flags().StringVar(&addr, "http", "", "Address to serve smart HTTP on, e.g. :8080")
+	serveCmd.Flags().StringVar(&opts.Root, "root", ".", "Directory containing the repositories to serve")
+	serveCmd.Flags().BoolVar(&opts.ExportAll, "export-all", false, "Serve repositories without a "+cmd.DaemonExportFile+" file")
+	serveCmd.Flags().StringVar(&auth, "auth", "", "Require basic auth as user:password (or set "+serveAuthEnv+")")
+	return serveCmd
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// ShowCommand creates the `show` command.
+func ShowCommand() *cobra.Command {
+	var output diffOutput
+	var noDecorate, signatures bool
+	var decorate string
+
+	showCmd := &cobra.Command{
+		Use:   "show [<object>...]",
+		Short: "Show commits, tags, trees and blobs",
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			style, err := decorationStyle(repo, command, decorate, noDecorate)
+			if err != nil {
+				return err
+			}
+			decorations := &cmd.Decorations{}
+			if style != cmd.DecorateNo {
+				if decorations, err = cmd.LoadDecorations(repo, style); err != nil {
+					return err
+				}
+			}
+
+			// Like git show, the patch is shown unless another format was chosen.
+			if !output.stat && !output.numstat && !output.shortstat && !output.raw {
+				output.patch = true
+			}
+
+			output.setup(repo)
+			if !command.Flags().Changed("show-signature") {
+				signatures = repo.Config().GetBool("log.showsignature")
+			}
+			if len(args) == 0 {
+				if _, err := cmd.HeadCommit(repo); err != nil {
+					return err
+				}
+				args = []string{cmd.HeadFile}
+			}
+			objects := cmd.NewObjectManager(repo).UseReplaceRefs().UseGrafts()
+			for i, rev := range args {
+				sha, err := cmd.ResolveRevision(repo, rev)
+				if err != nil {
+					return err
+				}
+				if i > 0 {
+					fmt.Println()
+				}
+				if err := showObject(repo, objects, rev, sha, &output, decorations, signatures); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	output.addFlags(showCmd.Flags())
+	showCmd.Flags().StringVar(&decorate, "decorate", "", "Print the ref names of commits: short, full, auto or no")
+	showCmd.Flags().Lookup("decorate").NoOptDefVal = string(cmd.DecorateShort)
+	showCmd.Flags().BoolVar(&noDecorate, "no-decorate", false, "Do not print ref names")
+	showCmd.Flags().BoolVar(&signatures, "show-signature", false, "Verify signed commits and show the result")
+	return showCmd
+}
+
+// showObject prints one object the way git show does: commits with their
+// patch, tags followed by the object they point at, tree listings and raw
+// blob content. With signatures, signed commits are verified.
+func showObject(repo *cmd.GitRepository, objects *cmd.ObjectManager, rev, sha string, output *diffOutput, decorations *cmd.Decorations, signatures bool) error {
+	objType, _, err := objects.StatObject(sha)
+	if err != nil {
+		return err
+	}
+	// Blobs may be large, so they are streamed rather than loaded.
+	if objType == cmd.BlobType {
+		_, err := objects.StreamObject(sha, os.Stdout)
+		return err
+	}
+
+	_, data, err := objects.ReadObject(sha)
+	if err != nil {
+		return err
+	}
+
+	switch objType {
+	case cmd.CommitType:
+		commit, err := objects.ReadCommit(sha)
+		if err != nil {
+			return err
+		}
+		signature := ""
+		if signatures {
+			signature = signatureReport(repo, commit)
+		}
+		printCommitMedium(objects, commit, decorations, signature)
+		if !output.enabled() {
+			return nil
+		}
+		changes, err := commitChanges(objects, commit, false)
+		if err != nil {
+			return err
+		}
+		return output.printForCommit(objects, changes)
+
+	case cmd.TagType:
+		tag, err := cmd.ParseKvlm(data)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("tag %s\n", tag.Get("tag"))
+		if tagger := tag.Get("tagger"); tagger != nil {
+			signature := cmd.ParseSignature(string(tagger))
+			fmt.Printf("Tagger: %s <%s>\n", signature.Name, signature.Email)
+			fmt.Printf("Date:   %s\n", signature.When.Format(gitDateLayout))
+		}
+		fmt.Printf("\n%s\n", strings.TrimRight(string(tag.Message), "\n"))
+		target := string(tag.Get("object"))
+		fmt.Println()
+		return showObject(repo, objects, target, target, output, decorations, signatures)
+
+	case cmd.TreeType:
+		entries, err := objects.ReadTree(sha)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("tree %s\n\n", rev)
+		for _, entry := range entries {
+			if entry.IsTree() {
+				fmt.Printf("%s/\n", entry.Name)
+			} else {
+				fmt.Println(entry.Name)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("object %s has unknown type '%s'", sha, objType)
+}
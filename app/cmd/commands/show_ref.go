@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// ShowRefCommand creates the `show-ref` command.
+func ShowRefCommand() *cobra.Command {
+	var opts cmd.ShowRefOptions
+	var verify, quiet, hashOnly bool
+
+	showRefCmd := &cobra.Command{
+		Use:   "show-ref [--head] [-d] [-s] [-q] [--heads] [--tags] [<pattern>...] | --verify [-d] [-s] [-q] <ref>...",
+		Short: "List the refs of the local repository",
+		Long: `List the refs of the local repository.
+
+Patterns match the end of ref names in whole path components, so "main"
+matches refs/heads/main and refs/remotes/origin/main. The exit status is 1
+when no ref matches. With --verify, every argument must be the full name of
+an existing ref, such as refs/heads/main or HEAD.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			var refs []cmd.Ref
+			if verify {
+				if len(args) == 0 {
+					return fmt.Errorf("--verify requires a reference")
+				}
+				for _, name := range args {
+					verified, err := cmd.VerifyRef(repo, name, opts.Dereference)
+					if err != nil {
+						// Like git, --quiet only answers through the exit status.
+						if quiet {
+							os.Exit(1)
+						}
+						return err
+					}
+					refs = append(refs, verified...)
+				}
+			} else {
+				opts.Patterns = args
+				if refs, err = cmd.ShowRefs(repo, opts); err != nil {
+					return err
+				}
+			}
+
+			if !quiet {
+				for _, ref := range refs {
+					if hashOnly {
+						fmt.Println(ref.SHA)
+					} else {
+						fmt.Printf("%s %s\n", ref.SHA, ref.Name)
+					}
+				}
+			}
+			if len(refs) == 0 {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+
+	flags := showRefCmd.Flags()
+	flags.BoolVar(&opts.Heads, "heads", false, "Only show branches")
+	flags.BoolVar(&opts.Tags, "tags", false, "Only show tags, or tags and branches with --heads")
+	flags.BoolVar(&opts.Head, "head", false, "Show HEAD too, even if it would be filtered out")
+	flags.BoolVarP(&opts.Dereference, "dereference", "d", false, "Show what annotated tags peel to as <tag>^{}")
+	flags.BoolVar(&verify, "verify", false, "Require the arguments to be exact, existing ref names")
+	flags.BoolVarP(&quiet, "quiet", "q", false, "Print nothing; only set the exit status")
+	flags.BoolVarP(&hashOnly, "hash", "s", false, "Only show the object names")
+	return showRefCmd
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// StashCommand creates the `stash` command and its subcommands. Without a
+// subcommand it saves the local changes, as `stash push` does.
+func StashCommand() *cobra.Command {
+	var opts cmd.StashOptions
+
+	stashCmd := &cobra.Command{
+		Use:   "stash [-u] [-m <message>]",
+		Short: "Stash the changes in a dirty working directory away",
+		Long: `Stash the changes in a dirty working directory away.
+
+Without a subcommand, the changes to the index and the tracked files are
+saved as a new stash entry and the worktree is reset to HEAD, as with
+"stash push". Entries are named stash@{0}, the newest, stash@{1} and so on;
+a bare number n stands for stash@{n}.`,
+		Args: cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			return runStashPush(opts)
+		},
+	}
+	addStashPushFlags(stashCmd.Flags(), &opts)
+
+	stashCmd.AddCommand(stashPushCommand())
+	stashCmd.AddCommand(stashListCommand())
+	stashCmd.AddCommand(stashShowCommand())
+	stashCmd.AddCommand(stashApplyCommand("apply", false))
+	stashCmd.AddCommand(stashApplyCommand("pop", true))
+	stashCmd.AddCommand(stashDropCommand())
+	return stashCmd
+}
+
+// addStashPushFlags registers the flags of stash push.
+func addStashPushFlags(flags *pflag.FlagSet, opts *cmd.StashOptions) {
+	flags.StringVarP(&opts.Message, "message", "m", "", "Describe the entry with this message")
+	flags.BoolVarP(&opts.IncludeUntracked, "include-untracked", "u", false, "Also stash the untracked files, and remove them")
+}
+
+// runStashPush saves the local changes and reports the new entry.
+func runStashPush(opts cmd.StashOptions) error {
+	repo, err := cmd.FindRepository(".")
+	if err != nil {
+		return err
+	}
+	stash, err := cmd.SaveStash(repo, opts)
+	if err != nil {
+		return err
+	}
+	if stash == nil {
+		fmt.Println("No local changes to save")
+		return nil
+	}
+	fmt.Printf("Saved working directory and index state %s\n", stash.Message)
+	return nil
+}
+
+func stashPushCommand() *cobra.Command {
+	var opts cmd.StashOptions
+
+	pushCmd := &cobra.Command{
+		Use:   "push [-u] [-m <message>]",
+		Short: "Save the local changes as a new stash entry and reset the worktree to HEAD",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			return runStashPush(opts)
+		},
+	}
+	addStashPushFlags(pushCmd.Flags(), &opts)
+	return pushCmd
+}
+
+func stashListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the stash entries, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			stashes, err := cmd.ListStashes(repo)
+			if err != nil {
+				return err
+			}
+			for _, stash := range stashes {
+				fmt.Printf("%s: %s\n", stash.Name, stash.Message)
+			}
+			return nil
+		},
+	}
+}
+
+func stashShowCommand() *cobra.Command {
+	var output diffOutput
+	var untracked bool
+
+	showCmd := &cobra.Command{
+		Use:   "show [-p] [-u] [<stash>]",
+		Short: "Show the changes of a stash entry against the commit it was made on",
+		Long: `Show the changes of a stash entry against the commit it was made on.
+
+The output is a diffstat unless another diff format is chosen. With -u, the
+untracked files the entry saved are shown as added files.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			stash, err := cmd.ReadStash(repo, name)
+			if err != nil {
+				return err
+			}
+
+			objects := cmd.NewObjectManager(repo)
+			changes, err := stash.Changes(objects, untracked)
+			if err != nil {
+				return err
+			}
+			output.setup(repo)
+			if !output.enabled() && !output.noPatch {
+				output.stat = true
+			}
+			return output.print(objects, changes, "")
+		},
+	}
+
+	output.addFlags(showCmd.Flags())
+	showCmd.Flags().BoolVarP(&untracked, "include-untracked", "u", false, "Show the untracked files of the entry too")
+	return showCmd
+}
+
+// stashApplyCommand creates `stash apply`, or `stash pop` when drop is set,
+// which also drops the entry once it applied cleanly.
+func stashApplyCommand(name string, drop bool) *cobra.Command {
+	var opts cmd.ApplyStashOptions
+
+	short := "Apply the changes of a stash entry to the worktree"
+	if drop {
+		short = "Apply a stash entry to the worktree and drop it from the stash"
+	}
+	applyCmd := &cobra.Command{
+		Use:   name + " [--index] [<stash>]",
+		Short: short,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			stashName := ""
+			if len(args) == 1 {
+				stashName = args[0]
+			}
+			stash, err := cmd.ApplyStash(repo, stashName, opts)
+			if err != nil {
+				return err
+			}
+
+			report, err := cmd.Status(repo)
+			if err != nil {
+				return err
+			}
+			printStatus(cmd.NewObjectManager(repo), report, cmd.QuotePathEnabled(repo))
+			if drop {
+				dropped, err := cmd.DropStash(repo, stash.Name)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Dropped %s (%s)\n", dropped.Name, dropped.SHA)
+			}
+			return nil
+		},
+	}
+	applyCmd.Flags().BoolVar(&opts.Index, "index", false, "Restore the staged changes of the entry in the index too")
+	return applyCmd
+}
+
+func stashDropCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "drop [<stash>]",
+		Short: "Remove an entry from the stash",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			dropped, err := cmd.DropStash(repo, name)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Dropped %s (%s)\n", dropped.Name, dropped.SHA)
+			return nil
+		},
+	}
+}
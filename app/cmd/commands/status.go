@@ -0,0 +1,254 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// StatusCommand creates the `status` command.
+func StatusCommand() *cobra.Command {
+	var short, branch bool
+	var untracked string
+
+	statusCmd := &cobra.Command{
+		Use:   "status [-s] [-b] [-u[=<mode>]]",
+		Short: "Show the working tree status",
+		Long: `Show the working tree status.
+
+An untracked directory that holds no tracked files is shown as a whole, as
+"dir/". -u chooses the untracked files to show: "no" shows none, "normal"
+collapses directories that way and "all", the mode of a bare -u, lists
+every file. The default is status.showUntrackedFiles, or "normal".`,
+		Args: cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			var opts cmd.StatusOptions
+			if command.Flags().Changed("untracked-files") {
+				if opts.Untracked, err = cmd.ParseUntrackedMode(untracked); err != nil {
+					return err
+				}
+			}
+			report, err := cmd.StatusWithOptions(repo, opts)
+			if err != nil {
+				return err
+			}
+			if short {
+				printShortStatus(report, branch, cmd.QuotePathEnabled(repo))
+			} else {
+				printStatus(cmd.NewObjectManager(repo), report, cmd.QuotePathEnabled(repo))
+			}
+			for _, lock := range report.StaleLocks {
+				fmt.Fprintf(os.Stderr, "warning: stale lock '%s' from %s; run 'justdoit maintenance unlock' to remove it\n",
+					lock.Path, lock.ModTime.Format(time.RFC1123Z))
+			}
+			return nil
+		},
+	}
+
+	flags := statusCmd.Flags()
+	flags.BoolVarP(&short, "short", "s", false, "Give the output in the short format")
+	flags.BoolVarP(&branch, "branch", "b", false, "Show the branch and its tracking state in the short format")
+	flags.StringVarP(&untracked, "untracked-files", "u", "", "Show untracked files: no, normal or all")
+	flags.Lookup("untracked-files").NoOptDefVal = string(cmd.UntrackedAll)
+	return statusCmd
+}
+
+// conflictLabels describe the unmerged paths in the long format, by their
+// short status.
+var conflictLabels = map[string]string{
+	"DD": "both deleted",
+	"AU": "added by us",
+	"UD": "deleted by them",
+	"UA": "added by them",
+	"DU": "deleted by us",
+	"AA": "both added",
+	"UU": "both modified",
+}
+
+// printStatus prints a status report in git's long format, without hints.
+// Paths are quoted as QuotePath does.
+func printStatus(objects *cmd.ObjectManager, report *cmd.StatusReport, quotePath bool) {
+	if report.Branch != "" {
+		fmt.Printf("On branch %s\n", report.Branch)
+	} else if report.Head != "" {
+		fmt.Printf("HEAD detached at %s\n", objects.ShortSHA(report.Head, 0))
+	}
+	if line := trackingLine(report); line != "" {
+		fmt.Printf("%s\n\n", line)
+	}
+	if report.Merging {
+		if len(report.Unmerged) > 0 {
+			fmt.Print("You have unmerged paths.\n\n")
+		} else {
+			fmt.Print("All conflicts fixed but you are still merging.\n\n")
+		}
+	}
+	if report.Head == "" {
+		fmt.Print("\nNo commits yet\n\n")
+	}
+
+	printSection := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Println(title)
+		for _, line := range lines {
+			fmt.Printf("\t%s\n", line)
+		}
+		fmt.Println()
+	}
+	changeLines := func(changes []cmd.FileChange) []string {
+		lines := make([]string, len(changes))
+		for i, change := range changes {
+			lines[i] = fmt.Sprintf("%-12s%s", string(change.Kind)+":", cmd.QuotePath(change.Path, quotePath))
+		}
+		return lines
+	}
+	unmerged := make([]string, len(report.Unmerged))
+	for i, path := range report.Unmerged {
+		unmerged[i] = fmt.Sprintf("%-17s%s", conflictLabels[report.Conflicts[path]]+":", cmd.QuotePath(path, quotePath))
+	}
+
+	printSection("Changes to be committed:", changeLines(report.Staged))
+	printSection("Unmerged paths:", unmerged)
+	printSection("Changes not staged for commit:", changeLines(report.Unstaged))
+	untracked := make([]string, len(report.Untracked))
+	for i, path := range report.Untracked {
+		untracked[i] = cmd.QuotePath(path, quotePath)
+	}
+	printSection("Untracked files:", untracked)
+
+	switch {
+	case len(report.Staged) > 0:
+		if report.NoUntracked {
+			fmt.Println("Untracked files not listed")
+		}
+	case len(report.Unstaged) > 0 || len(report.Unmerged) > 0:
+		fmt.Println("no changes added to commit")
+	case len(report.Untracked) > 0:
+		fmt.Println("nothing added to commit but untracked files present")
+	case report.Head == "" || report.NoUntracked:
+		fmt.Println("nothing to commit")
+	default:
+		fmt.Println("nothing to commit, working tree clean")
+	}
+}
+
+// shortStatusLetters maps the kinds of changes to the letters of the short
+// format.
+var shortStatusLetters = map[cmd.ChangeKind]byte{
+	cmd.ChangeAdded:      'A',
+	cmd.ChangeModified:   'M',
+	cmd.ChangeDeleted:    'D',
+	cmd.ChangeTypeChange: 'T',
+}
+
+// printShortStatus prints a status report in git's short format: one line
+// per path, with the staged and the unstaged change as two letters, and
+// "??" for untracked paths.
+//
+// Parameters:
+// - report: The status report.
+// - branch: Start with a "## " line naming the branch and its tracking state.
+// - quotePath: Quote paths as QuotePath does.
+func printShortStatus(report *cmd.StatusReport, branch bool, quotePath bool) {
+	if branch {
+		fmt.Printf("## %s\n", shortBranchLine(report))
+	}
+
+	codes := make(map[string][]byte)
+	code := func(path string) []byte {
+		if codes[path] == nil {
+			codes[path] = []byte("  ")
+		}
+		return codes[path]
+	}
+	for _, change := range report.Staged {
+		code(change.Path)[0] = shortStatusLetters[change.Kind]
+	}
+	for _, change := range report.Unstaged {
+		code(change.Path)[1] = shortStatusLetters[change.Kind]
+	}
+	for _, path := range report.Unmerged {
+		copy(code(path), report.Conflicts[path])
+	}
+
+	paths := make([]string, 0, len(codes))
+	for path := range codes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Printf("%s %s\n", codes[path], cmd.QuotePath(path, quotePath))
+	}
+	for _, path := range report.Untracked {
+		fmt.Printf("?? %s\n", cmd.QuotePath(path, quotePath))
+	}
+}
+
+// shortBranchLine describes the branch for the "## " line of the short
+// format, e.g. "main...origin/main [ahead 1, behind 2]".
+func shortBranchLine(report *cmd.StatusReport) string {
+	switch {
+	case report.Head == "":
+		return "No commits yet on " + report.Branch
+	case report.Branch == "":
+		return "HEAD (no branch)"
+	case report.Upstream == nil:
+		return report.Branch
+	}
+
+	line := report.Branch + "..." + report.Upstream.ShortName()
+	switch {
+	case report.UpstreamGone:
+		line += " [gone]"
+	case report.Ahead > 0 && report.Behind > 0:
+		line += fmt.Sprintf(" [ahead %d, behind %d]", report.Ahead, report.Behind)
+	case report.Ahead > 0:
+		line += fmt.Sprintf(" [ahead %d]", report.Ahead)
+	case report.Behind > 0:
+		line += fmt.Sprintf(" [behind %d]", report.Behind)
+	}
+	return line
+}
+
+// trackingLine describes how the current branch relates to its upstream,
+// e.g. "Your branch is ahead of 'origin/main' by 2 commits.".
+func trackingLine(report *cmd.StatusReport) string {
+	if report.Upstream == nil {
+		return ""
+	}
+
+	name := report.Upstream.ShortName()
+	commits := func(n int) string {
+		if n == 1 {
+			return "1 commit"
+		}
+		return fmt.Sprintf("%d commits", n)
+	}
+
+	switch {
+	case report.UpstreamGone:
+		return fmt.Sprintf("Your branch is based on '%s', but the upstream is gone.", name)
+	case report.Ahead > 0 && report.Behind > 0:
+		return strings.Join([]string{
+			fmt.Sprintf("Your branch and '%s' have diverged,", name),
+			fmt.Sprintf("and have %d and %d different commits each, respectively.", report.Ahead, report.Behind),
+		}, "\n")
+	case report.Ahead > 0:
+		return fmt.Sprintf("Your branch is ahead of '%s' by %s.", name, commits(report.Ahead))
+	case report.Behind > 0:
+		return fmt.Sprintf("Your branch is behind '%s' by %s, and can be fast-forwarded.", name, commits(report.Behind))
+	}
+	return fmt.Sprintf("Your branch is up to date with '%s'.", name)
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// StripspaceCommand creates the `stripspace` command.
+func StripspaceCommand() *cobra.Command {
+	var stripComments, commentLines bool
+	var wrap int
+
+	stripspaceCmd := &cobra.Command{
+		Use:   "stripspace [-s | --strip-comments | -c | --comment-lines] [--wrap <width>]",
+		Short: "Clean up a message read from stdin the way commit messages are cleaned up",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			if stripComments && commentLines {
+				return fmt.Errorf("options '--strip-comments' and '--comment-lines' cannot be used together")
+			}
+
+			// Like git stripspace, this works outside of a repository too,
+			// for hook scripts.
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				repo = nil
+			}
+			commentChar, err := cmd.CommentChar(repo)
+			if err != nil {
+				return err
+			}
+
+			message, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			if commentLines {
+				message = cmd.CommentLines(message, commentChar)
+			} else {
+				if !stripComments {
+					commentChar = ""
+				}
+				message = cmd.WrapMessage(cmd.Stripspace(message, commentChar), wrap)
+			}
+			_, err = os.Stdout.Write(message)
+			return err
+		},
+	}
+
+	stripspaceCmd.Flags().BoolVarP(&stripComments, "strip-comments", "s", false, "Also remove lines starting with the comment character")
+	stripspaceCmd.Flags().BoolVarP(&commentLines, "comment-lines", "c", false, "Prefix every line with the comment character instead")
+	stripspaceCmd.Flags().IntVar(&wrap, "wrap", 0, "Wrap lines longer than this many characters")
+	return stripspaceCmd
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// TagCommand creates the `tag` command.
+func TagCommand() *cobra.Command {
+	var opts cmd.CreateTagOptions
+	var listOpts cmd.TagListOptions
+	var messages []string
+	var file string
+	var list, deleteTags, verify bool
+
+	tagCmd := &cobra.Command{
+		Use:   "tag [-a] [-f] [-m <msg> | -F <file>] <tagname> [<commit>] | -d <tagname>... | -v <tagname>... | [-l] [--sort=<key>] [--contains <commit>] [--points-at <object>] [<pattern>...]",
+		Short: "Create, list, delete or verify tags",
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case deleteTags:
+				objects := cmd.NewObjectManager(repo)
+				failed := false
+				for _, name := range args {
+					sha, err := cmd.DeleteTag(repo, name)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "error: %v\n", err)
+						failed = true
+						continue
+					}
+					fmt.Printf("Deleted tag '%s' (was %s)\n", name, objects.ShortSHA(sha, 0))
+				}
+				if failed {
+					os.Exit(1)
+				}
+				return nil
+
+			case verify:
+				return verifyTags(repo, args, true)
+
+			case list || len(args) == 0 || len(listOpts.Contains) > 0 || len(listOpts.PointsAt) > 0:
+				listOpts.Patterns = args
+				tags, err := cmd.ListTags(repo, listOpts)
+				if err != nil {
+					return err
+				}
+				for _, tag := range tags {
+					fmt.Println(strings.TrimPrefix(tag.Name, cmd.TagsPrefix))
+				}
+				return nil
+			}
+
+			if len(args) > 2 {
+				return fmt.Errorf("too many arguments")
+			}
+			name, target := args[0], cmd.HeadFile
+			if len(args) == 2 {
+				target = args[1]
+			}
+			switch {
+			case len(messages) > 0 && file != "":
+				return fmt.Errorf("options '-m' and '-F' cannot be used together")
+			case len(messages) > 0:
+				opts.Message = []byte(strings.Join(messages, "\n\n"))
+			case file == "-":
+				if opts.Message, err = io.ReadAll(os.Stdin); err != nil {
+					return err
+				}
+			case file != "":
+				if opts.Message, err = os.ReadFile(file); err != nil {
+					return fmt.Errorf("could not open or read '%s': %w", file, err)
+				}
+			}
+			// Like git, a message makes the tag annotated, and an annotated
+			// tag without one has it written in the editor.
+			opts.Annotate = opts.Annotate || opts.Message != nil
+			if opts.Annotate && opts.Message == nil {
+				help := fmt.Sprintf(tagHelp, strings.ReplaceAll(name, "%", "%%"))
+				if opts.Message, err = cmd.EditMessage(repo, nil, help); err != nil {
+					return err
+				}
+				if len(opts.Message) == 0 {
+					return fmt.Errorf("no tag message?")
+				}
+			} else if opts.Annotate {
+				opts.Message = cmd.Stripspace(opts.Message, "")
+			}
+
+			old, _ := cmd.ResolveRef(repo, cmd.TagsPrefix+name)
+			sha, err := cmd.CreateTag(repo, name, target, opts)
+			if err != nil {
+				return err
+			}
+			if old != "" && old != sha {
+				fmt.Printf("Updated tag '%s' (was %s)\n", name, cmd.NewObjectManager(repo).ShortSHA(old, 0))
+			}
+			return nil
+		},
+	}
+
+	flags := tagCmd.Flags()
+	flags.BoolVarP(&opts.Annotate, "annotate", "a", false, "Make an annotated tag object, with a message")
+	flags.StringArrayVarP(&messages, "message", "m", nil, "Use the given tag message; several are joined as paragraphs")
+	flags.StringVarP(&file, "file", "F", "", "Take the tag message from the given file, or - for stdin")
+	flags.BoolVarP(&opts.Force, "force", "f", false, "Replace an existing tag")
+	flags.BoolVarP(&list, "list", "l", false, "List the tags, or those matching the patterns")
+	flags.StringArrayVar(&listOpts.Sort, "sort", nil, "Sort by refname or v:refname (version), reversed with a leading -; the last key decides first")
+	flags.StringArrayVar(&listOpts.Contains, "contains", nil, "List only the tags of commits that contain the given commit")
+	flags.StringArrayVar(&listOpts.PointsAt, "points-at", nil, "List only the tags that point at the given object")
+	flags.BoolVarP(&deleteTags, "delete", "d", false, "Delete the given tags")
+	flags.BoolVarP(&verify, "verify", "v", false, "Verify the signatures of the given tags")
+	tagCmd.MarkFlagsMutuallyExclusive("list", "delete", "verify")
+	return tagCmd
+}
+
+// tagHelp is shown below a tag message in the editor; the first "%s" is
+// the tag name.
+const tagHelp = `
+Write a message for tag:
+  %s
+Lines starting with '%%s' will be ignored.
+`
+
+// verifyTags checks the signatures of tags, printing the verifier's report
+// on stderr, and with verbose the tags themselves first. Like git, it goes
+// on after a tag that fails and exits with 1 at the end.
+func verifyTags(repo *cmd.GitRepository, names []string, verbose bool) error {
+	if len(names) == 0 {
+		return fmt.Errorf("tag name required")
+	}
+	failed := false
+	for _, name := range names {
+		result, err := cmd.VerifyTag(repo, name)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			failed = true
+			continue
+		case verbose:
+			os.Stdout.Write(result.Payload)
+		}
+		if result.Status == nil {
+			fmt.Fprintln(os.Stderr, "error: no signature found")
+			failed = true
+			continue
+		}
+		fmt.Fprint(os.Stderr, result.Status.Output)
+		failed = failed || !result.Status.Good
+	}
+	if failed {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,616 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// Keys as the terminal sends them in raw mode.
+const (
+	keyUp       = "\x1b[A"
+	keyDown     = "\x1b[B"
+	keyPageUp   = "\x1b[5~"
+	keyPageDown = "\x1b[6~"
+	keyCtrlC    = "\x03"
+	keyCtrlD    = "\x04"
+	keyCtrlU    = "\x15"
+)
+
+// ANSI escape sequences used to draw the screen.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiReverse = "\x1b[7m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiCyan    = "\x1b[36m"
+)
+
+const uiHelp = "j/k move  J/K scroll diff  tab switch view  space stage/unstage  r refresh  q quit"
+
+// uiView is a screen of the terminal UI.
+type uiView int
+
+const (
+	uiLog uiView = iota
+	uiStatus
+)
+
+// uiFile is a row of the status view. A path with both staged and unstaged
+// changes has a row for each.
+type uiFile struct {
+	Path   string
+	Label  string // What changed, as in status, or "untracked" or "unmerged".
+	Staged bool
+}
+
+// ui is the state of the terminal UI.
+type ui struct {
+	repo     *cmd.GitRepository
+	objects  *cmd.ObjectManager
+	term     *terminal
+	view     uiView
+	branch   string
+	commits  []string
+	subjects map[string]string
+	files    []uiFile
+	staged   map[string]cmd.TreeChange // HEAD against the index, by path.
+	unstaged map[string]cmd.TreeChange // The index against the worktree, by path.
+	selected [2]int                    // The selected row of each view.
+	top      [2]int                    // The first visible row of each view.
+	diff     []string
+	diffTop  int
+	message  string
+}
+
+// UICommand creates the `ui` command.
+func UICommand() *cobra.Command {
+	uiCmd := &cobra.Command{
+		Use:   "ui",
+		Short: "Browse the log and stage changes in an interactive terminal UI",
+		Long: `Browse the log and stage changes in an interactive terminal UI.
+
+The log view lists the commits of HEAD with the diff of the selected commit
+below. The status view lists staged, unstaged and untracked files with their
+diff; space stages or unstages the selected file.
+
+Keys: ` + uiHelp,
+		Args: cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+				return fmt.Errorf("ui needs a terminal")
+			}
+
+			u := &ui{
+				repo:     repo,
+				objects:  cmd.NewObjectManager(repo).UseReplaceRefs().UseGrafts(),
+				subjects: make(map[string]string),
+			}
+			if err := u.loadLog(); err != nil {
+				return err
+			}
+			if !repo.IsBare() {
+				if err := u.loadStatus(); err != nil {
+					return err
+				}
+			}
+
+			term, err := openTerminal()
+			if err != nil {
+				return err
+			}
+			defer term.close()
+			u.term = term
+			return u.run()
+		},
+	}
+	return uiCmd
+}
+
+// run draws the screen and handles keys until the user quits.
+func (u *ui) run() error {
+	u.loadDiff()
+	for {
+		rows, _ := u.term.size()
+		if err := u.draw(); err != nil {
+			return err
+		}
+		key, err := u.term.readKey()
+		if err != nil {
+			return err
+		}
+
+		u.message = ""
+		half := max(u.diffHeight(rows)/2, 1)
+		switch key {
+		case "q", keyCtrlC:
+			return nil
+		case "j", keyDown:
+			u.move(1)
+		case "k", keyUp:
+			u.move(-1)
+		case "J", keyPageDown, keyCtrlD:
+			u.diffTop = max(min(u.diffTop+half, len(u.diff)-1), 0)
+		case "K", keyPageUp, keyCtrlU:
+			u.diffTop = max(u.diffTop-half, 0)
+		case "\t":
+			if u.repo.IsBare() {
+				u.message = "no status in a bare repository"
+				break
+			}
+			u.view = 1 - u.view
+			u.loadDiff()
+		case " ", "\r":
+			if u.view == uiStatus {
+				u.toggle()
+			}
+		case "r":
+			u.refresh()
+		}
+	}
+}
+
+// loadLog lists the commits reachable from HEAD.
+func (u *ui) loadLog() error {
+	head, err := cmd.ResolveHEAD(u.repo)
+	if err != nil {
+		return err
+	}
+	u.branch = head.Branch()
+	u.commits = nil
+	if head.Unborn() {
+		return nil
+	}
+	u.commits, err = u.objects.RevList([]string{head.SHA}, nil, cmd.RevListOptions{})
+	return err
+}
+
+// loadStatus lists the changed files and the changes to diff them with.
+func (u *ui) loadStatus() error {
+	report, err := cmd.Status(u.repo)
+	if err != nil {
+		return err
+	}
+
+	u.files = nil
+	for _, change := range report.Staged {
+		u.files = append(u.files, uiFile{Path: change.Path, Label: string(change.Kind), Staged: true})
+	}
+	for _, path := range report.Unmerged {
+		u.files = append(u.files, uiFile{Path: path, Label: "unmerged"})
+	}
+	for _, change := range report.Unstaged {
+		u.files = append(u.files, uiFile{Path: change.Path, Label: string(change.Kind)})
+	}
+	for _, path := range report.Untracked {
+		u.files = append(u.files, uiFile{Path: path, Label: "untracked"})
+	}
+	u.selected[uiStatus] = max(min(u.selected[uiStatus], len(u.files)-1), 0)
+
+	staged, _, err := diffChanges(u.repo, nil, true, nil)
+	if err != nil {
+		return err
+	}
+	unstaged, _, err := diffChanges(u.repo, nil, false, nil)
+	if err != nil {
+		return err
+	}
+	u.staged = changesByPath(staged)
+	u.unstaged = changesByPath(unstaged)
+	return nil
+}
+
+// changesByPath indexes changes by their path.
+func changesByPath(changes []cmd.TreeChange) map[string]cmd.TreeChange {
+	byPath := make(map[string]cmd.TreeChange, len(changes))
+	for _, change := range changes {
+		byPath[change.Path] = change
+	}
+	return byPath
+}
+
+// refresh reloads the log and the status, as after changes made outside
+// the UI.
+func (u *ui) refresh() {
+	err := u.loadLog()
+	if err == nil && !u.repo.IsBare() {
+		err = u.loadStatus()
+	}
+	u.selected[uiLog] = max(min(u.selected[uiLog], len(u.commits)-1), 0)
+	if err != nil {
+		u.message = err.Error()
+	}
+	u.loadDiff()
+}
+
+// move changes the selected row of the current view by delta.
+func (u *ui) move(delta int) {
+	last := len(u.commits) - 1
+	if u.view == uiStatus {
+		last = len(u.files) - 1
+	}
+	u.selected[u.view] = max(min(u.selected[u.view]+delta, last), 0)
+	u.loadDiff()
+}
+
+// toggle stages the selected file of the status view, or unstages it when
+// the row is a staged change, and writes the index.
+func (u *ui) toggle() {
+	if len(u.files) == 0 {
+		return
+	}
+	file := u.files[u.selected[uiStatus]]
+	if err := u.stage(file); err != nil {
+		u.message = err.Error()
+		return
+	}
+
+	if file.Staged {
+		u.message = fmt.Sprintf("unstaged '%s'", file.Path)
+	} else {
+		u.message = fmt.Sprintf("staged '%s'", file.Path)
+	}
+	if err := u.loadStatus(); err != nil {
+		u.message = err.Error()
+	}
+	u.loadDiff()
+}
+
+// stage stages or unstages one row of the status view. An untracked
+// directory is staged file by file.
+func (u *ui) stage(file uiFile) error {
+	lock, index, err := cmd.LockIndex(u.repo)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	paths := []string{file.Path}
+	if strings.HasSuffix(file.Path, "/") {
+		if paths, err = cmd.UntrackedFilesIn(u.repo, file.Path); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths {
+		if file.Staged {
+			err = cmd.UnstagePath(u.repo, index, path)
+		} else {
+			err = cmd.StagePath(u.repo, index, path, io.Discard)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return lock.Write(index)
+}
+
+// loadDiff fills the diff pane for the selected row of the current view.
+func (u *ui) loadDiff() {
+	u.diffTop = 0
+	lines := []string{"nothing to show"}
+	var err error
+	switch {
+	case u.view == uiLog && len(u.commits) > 0:
+		lines, err = u.commitDiff(u.commits[u.selected[uiLog]])
+	case u.view == uiStatus && len(u.files) > 0:
+		lines, err = u.fileDiff(u.files[u.selected[uiStatus]])
+	}
+	if err != nil {
+		lines = []string{"error: " + err.Error()}
+	}
+	u.diff = lines
+}
+
+// commitDiff formats a commit like git show: its header, message and patch.
+func (u *ui) commitDiff(sha string) ([]string, error) {
+	commit, err := u.objects.ReadCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "commit %s\n", sha)
+	if len(commit.Parents) > 1 {
+		short := make([]string, len(commit.Parents))
+		for i, parent := range commit.Parents {
+			short[i] = parent[:7]
+		}
+		fmt.Fprintf(&buf, "Merge: %s\n", strings.Join(short, " "))
+	}
+	author := cmd.ParseSignature(commit.Author)
+	fmt.Fprintf(&buf, "Author: %s <%s>\n", author.Name, author.Email)
+	fmt.Fprintf(&buf, "Date:   %s\n\n", author.When.Format(gitDateLayout))
+	for _, line := range strings.Split(strings.TrimRight(string(commit.Message), "\n"), "\n") {
+		fmt.Fprintf(&buf, "    %s\n", line)
+	}
+
+	changes, err := commitChanges(u.objects, commit, false)
+	if err != nil {
+		return nil, err
+	}
+	patches, err := u.objects.Patches(changes, "", 3)
+	if err != nil {
+		return nil, err
+	}
+	if len(patches) > 0 {
+		buf.WriteString("\n")
+	}
+	for _, patch := range patches {
+		if err := cmd.WritePatch(&buf, patch, true); err != nil {
+			return nil, err
+		}
+	}
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"), nil
+}
+
+// fileDiff returns the staged or unstaged patch of a file, or for an
+// untracked file its content.
+func (u *ui) fileDiff(file uiFile) ([]string, error) {
+	if file.Label == "untracked" {
+		return u.untrackedDiff(file.Path)
+	}
+
+	changes, worktree := u.unstaged, u.repo.WorkTree
+	if file.Staged {
+		changes, worktree = u.staged, ""
+	}
+	change, ok := changes[file.Path]
+	if !ok {
+		return []string{fmt.Sprintf("%s: %s", file.Label, file.Path)}, nil
+	}
+	patches, err := u.objects.Patches([]cmd.TreeChange{change}, worktree, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, patch := range patches {
+		if err := cmd.WritePatch(&buf, patch, true); err != nil {
+			return nil, err
+		}
+	}
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"), nil
+}
+
+// untrackedDiff shows an untracked file as added lines, or lists the files
+// of an untracked directory.
+func (u *ui) untrackedDiff(path string) ([]string, error) {
+	if strings.HasSuffix(path, "/") {
+		files, err := cmd.UntrackedFilesIn(u.repo, path)
+		if err != nil {
+			return nil, err
+		}
+		return append([]string{"untracked directory " + path, ""}, files...), nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(u.repo.WorkTree, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{"new file " + path}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return append(lines, "Binary file"), nil
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		lines = append(lines, "+"+line)
+	}
+	return lines, nil
+}
+
+// listHeight is the number of rows of the list pane, a third of the screen
+// below the title.
+func (u *ui) listHeight(rows int) int {
+	return max((rows-3)/3, 1)
+}
+
+// diffHeight is the number of rows of the diff pane.
+func (u *ui) diffHeight(rows int) int {
+	return max(rows-3-u.listHeight(rows), 1)
+}
+
+// listRows returns the rows of the current view's list.
+func (u *ui) listRows() []string {
+	if u.view == uiStatus {
+		rows := make([]string, len(u.files))
+		for i, file := range u.files {
+			mark := "[ ]"
+			if file.Staged {
+				mark = "[x]"
+			}
+			rows[i] = fmt.Sprintf("%s %-10s %s", mark, file.Label, file.Path)
+		}
+		return rows
+	}
+
+	rows := make([]string, len(u.commits))
+	for i, sha := range u.commits {
+		subject, ok := u.subjects[sha]
+		if !ok {
+			if commit, err := u.objects.ReadCommit(sha); err == nil {
+				subject = commitSubject(commit)
+			}
+			u.subjects[sha] = subject
+		}
+		rows[i] = sha[:7] + " " + subject
+	}
+	return rows
+}
+
+// draw redraws the whole screen: a title, the list, the diff and a line
+// for messages and help.
+func (u *ui) draw() error {
+	rows, cols := u.term.size()
+	out := u.term.out
+	out.WriteString("\x1b[H")
+
+	title := " justdoit ui   [log]  status "
+	if u.view == uiStatus {
+		title = " justdoit ui    log  [status]"
+	}
+	if u.branch != "" {
+		title += "   on " + u.branch
+	}
+	writeScreenLine(out, ansiReverse, title, cols, true)
+
+	list := u.listRows()
+	height := u.listHeight(rows)
+	selected, top := u.selected[u.view], u.top[u.view]
+	if selected < top {
+		top = selected
+	}
+	if selected >= top+height {
+		top = selected - height + 1
+	}
+	u.top[u.view] = top
+	for i := top; i < top+height; i++ {
+		switch {
+		case i >= len(list):
+			writeScreenLine(out, "", "", cols, true)
+		case i == selected:
+			writeScreenLine(out, ansiReverse, list[i], cols, true)
+		default:
+			writeScreenLine(out, "", list[i], cols, true)
+		}
+	}
+
+	writeScreenLine(out, ansiDim, strings.Repeat("-", cols), cols, true)
+	for i := u.diffTop; i < u.diffTop+u.diffHeight(rows); i++ {
+		if i < len(u.diff) {
+			writeScreenLine(out, diffLineStyle(u.diff[i]), u.diff[i], cols, true)
+		} else {
+			writeScreenLine(out, "", "", cols, true)
+		}
+	}
+
+	footer := uiHelp
+	if u.message != "" {
+		footer = u.message
+	}
+	writeScreenLine(out, ansiBold, footer, cols, false)
+	return out.Flush()
+}
+
+// diffLineStyle returns the color of a line of the diff pane.
+func diffLineStyle(line string) string {
+	switch {
+	case strings.HasPrefix(line, "commit "):
+		return ansiYellow
+	case strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return ansiBold
+	case strings.HasPrefix(line, "@@"):
+		return ansiCyan
+	case strings.HasPrefix(line, "+"):
+		return ansiGreen
+	case strings.HasPrefix(line, "-"):
+		return ansiRed
+	}
+	return ""
+}
+
+// writeScreenLine writes one line of the screen in the given style, with
+// tabs expanded and cut to the width of the terminal, clearing whatever
+// was left of the line before. Control characters are shown as '?' so
+// content cannot move the cursor.
+func writeScreenLine(out *bufio.Writer, style, text string, cols int, newline bool) {
+	out.WriteString(style)
+	column := 0
+	for _, r := range text {
+		if column >= cols {
+			break
+		}
+		switch {
+		case r == '\t':
+			spaces := min(8-column%8, cols-column)
+			out.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+			continue
+		case r < ' ' || r == 0x7f:
+			r = '?'
+		}
+		out.WriteRune(r)
+		column++
+	}
+	out.WriteString("\x1b[K" + ansiReset)
+	if newline {
+		out.WriteString("\r\n")
+	}
+}
+
+// terminal is the controlling terminal in raw mode, switched to the
+// alternate screen so the shell's screen comes back on exit.
+type terminal struct {
+	in    *os.File
+	out   *bufio.Writer
+	saved string // The stty settings to restore.
+}
+
+// openTerminal puts the terminal on stdin in raw mode and switches to the
+// alternate screen with the cursor hidden.
+func openTerminal() (*terminal, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+
+	term := &terminal{in: os.Stdin, out: bufio.NewWriter(os.Stdout), saved: saved}
+	term.out.WriteString("\x1b[?1049h\x1b[?25l")
+	return term, term.out.Flush()
+}
+
+// close restores the screen, the cursor and the terminal settings.
+func (t *terminal) close() {
+	t.out.WriteString("\x1b[?25h\x1b[?1049l")
+	t.out.Flush()
+	stty(t.saved)
+}
+
+// size returns the rows and columns of the terminal, or 24x80 when stty
+// cannot tell.
+func (t *terminal) size() (int, int) {
+	rows, cols := 24, 80
+	if out, err := stty("size"); err == nil {
+		fmt.Sscan(out, &rows, &cols)
+	}
+	return max(rows, 4), max(cols, 10)
+}
+
+// readKey waits for a key press and returns the bytes it sent, a single
+// character or an escape sequence.
+func (t *terminal) readKey() (string, error) {
+	buf := make([]byte, 16)
+	n, err := t.in.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+// stty runs stty on the terminal on stdin. There is no portable way to
+// change terminal modes from the standard library alone.
+func stty(args ...string) (string, error) {
+	command := exec.Command("stty", args...)
+	command.Stdin = os.Stdin
+	out, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
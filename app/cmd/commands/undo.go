@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// UndoCommand creates the `undo` command.
+func UndoCommand() *cobra.Command {
+	var opts cmd.UndoOptions
+	var list bool
+
+	undoCmd := &cobra.Command{
+		Use:   "undo [-f] | --list",
+		Short: "Take back the last commit, rebase or checkout",
+		Long: `Take back the last commit, rebase or checkout.
+
+These commands record where HEAD was and a copy of the index before they
+run in a journal kept in the git directory. undo puts HEAD back on the
+branch and commit it was on and restores the index. After a checkout or a
+rebase, the files they checked out are checked out as they were, unless
+they have local changes since. The commits HEAD leaves stay in the reflog.
+
+Operations are undone newest first; the last undo.limit of them, 20 by
+default, are kept. undo refuses when HEAD moved since the operation, unless
+forced.`,
+		Args: cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			objects := cmd.NewObjectManager(repo)
+
+			if list {
+				entries, err := cmd.ReadUndoJournal(repo)
+				if err != nil {
+					return err
+				}
+				for i := len(entries) - 1; i >= 0; i-- {
+					entry := entries[i]
+					fmt.Printf("%s %-8s %s\n", entry.Time.Format("2006-01-02 15:04:05"), entry.Command, undoMove(objects, entry))
+				}
+				return nil
+			}
+
+			result, err := cmd.Undo(repo, opts)
+			if err != nil {
+				return err
+			}
+			if len(result.Modified) > 0 || len(result.Untracked) > 0 {
+				printCheckoutRefusal(&cmd.CheckoutResult{Modified: result.Modified, Untracked: result.Untracked})
+				return fmt.Errorf("undo aborted")
+			}
+
+			entry := result.Entry
+			fmt.Fprintf(os.Stderr, "Undid %s: %s\n", entry.Command, undoMove(objects, entry))
+			if entry.OldHead == "" {
+				return nil
+			}
+			commit, err := objects.ReadCommit(entry.OldHead)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "HEAD is now at %s %s\n", objects.ShortSHA(entry.OldHead, 0), commitSubject(commit))
+			return nil
+		},
+	}
+
+	undoCmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Undo even if HEAD moved since, throwing away local changes in the way")
+	undoCmd.Flags().BoolVar(&list, "list", false, "List the operations that can be undone, newest first")
+	return undoCmd
+}
+
+// undoMove describes where an operation moved HEAD, e.g.
+// "master 1a2b3c4 -> 5d6e7f8".
+func undoMove(objects *cmd.ObjectManager, entry cmd.UndoEntry) string {
+	side := func(ref, sha string) string {
+		name := "(unborn)"
+		if sha != "" {
+			name = objects.ShortSHA(sha, 0)
+		}
+		if ref == "" {
+			return "detached " + name
+		}
+		return shortRefName(ref) + " " + name
+	}
+	to := side(entry.NewRef, entry.NewHead)
+	if entry.NewRef == entry.OldRef && entry.NewHead != "" {
+		to = objects.ShortSHA(entry.NewHead, 0)
+	}
+	return side(entry.OldRef, entry.OldHead) + " -> " + to
+}
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// UnpackObjectsCommand creates the `unpack-objects` command.
+func UnpackObjectsCommand() *cobra.Command {
+	var dryRun, quiet, recover bool
+
+	unpackCmd := &cobra.Command{
+		Use:   "unpack-objects",
+		Short: "Unpack objects from a packed archive read on stdin",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+
+			result, err := cmd.UnpackObjects(repo, os.Stdin, cmd.UnpackOptions{DryRun: dryRun, Recover: recover})
+			if err != nil {
+				return err
+			}
+
+			for _, problem := range result.Errors {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", problem)
+			}
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "Unpacking objects: %d/%d, done.\n", result.Unpacked, result.Total)
+			}
+			return nil
+		},
+	}
+
+	unpackCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Check the pack without writing any object")
+	unpackCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Do not report progress")
+	unpackCmd.Flags().BoolVarP(&recover, "recover", "r", false, "Salvage as many objects as possible from a corrupt pack")
+	return unpackCmd
+}
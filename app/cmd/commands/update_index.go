@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// UpdateIndexCommand creates the `update-index` command, which for now
+// only marks index entries assume-unchanged or skip-worktree.
+func UpdateIndexCommand() *cobra.Command {
+	var assume, noAssume, skip, noSkip bool
+
+	updateIndexCmd := &cobra.Command{
+		Use:   "update-index [--[no-]assume-unchanged] [--[no-]skip-worktree] [--] <file>...",
+		Short: "Mark files in the index assume-unchanged or skip-worktree",
+		Long: `Mark files in the index assume-unchanged or skip-worktree.
+
+The worktree copy of a file marked either way is not looked at: status does
+not report changes to it, and commit -a and stash leave it alone. Files
+marked skip-worktree are also refused by commands that would update them in
+the index from the worktree.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			if !assume && !noAssume && !skip && !noSkip {
+				return fmt.Errorf("nothing to do; use --assume-unchanged or --skip-worktree, or their --no- forms")
+			}
+
+			paths := make([]string, len(args))
+			for i, arg := range args {
+				if paths[i] = worktreeRelative(repo, arg); paths[i] == "" {
+					return fmt.Errorf("'%s' is outside repository", arg)
+				}
+			}
+			changes := []struct {
+				set   bool
+				flag  cmd.IndexEntryFlag
+				value bool
+			}{
+				{assume, cmd.FlagAssumeUnchanged, true},
+				{noAssume, cmd.FlagAssumeUnchanged, false},
+				{skip, cmd.FlagSkipWorktree, true},
+				{noSkip, cmd.FlagSkipWorktree, false},
+			}
+			for _, change := range changes {
+				if !change.set {
+					continue
+				}
+				if err := cmd.SetIndexEntryFlag(repo, paths, change.flag, change.value); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	flags := updateIndexCmd.Flags()
+	flags.BoolVar(&assume, "assume-unchanged", false, "Take the files to be unchanged in the worktree")
+	flags.BoolVar(&noAssume, "no-assume-unchanged", false, "Look at the worktree copies of the files again")
+	flags.BoolVar(&skip, "skip-worktree", false, "Ignore the worktree copies of the files")
+	flags.BoolVar(&noSkip, "no-skip-worktree", false, "Stop ignoring the worktree copies of the files")
+	return updateIndexCmd
+}
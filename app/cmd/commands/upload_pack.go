@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// UploadPackCommand creates the `upload-pack` command.
+func UploadPackCommand() *cobra.Command {
+	var opts cmd.UploadPackOptions
+
+	uploadPackCmd := &cobra.Command{
+		Use:   "upload-pack <directory>",
+		Short: "Send objects packed back to a fetching client over stdin/stdout",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.EnterRepository(args[0])
+			if err != nil {
+				return err
+			}
+			return cmd.UploadPack(repo, os.Stdin, os.Stdout, opts)
+		},
+	}
+
+	uploadPackCmd.Flags().BoolVar(&opts.StatelessRPC, "stateless-rpc", false, "Serve a single request/response exchange without advertising refs")
+	uploadPackCmd.Flags().BoolVar(&opts.AdvertiseRefsOnly, "advertise-refs", false, "Only advertise the refs and exit")
+	return uploadPackCmd
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// logicalVariable is one of the values `var` can print.
+type logicalVariable struct {
+	name  string
+	value func(repo *cmd.GitRepository) (string, error)
+}
+
+// logicalVariables lists the variables of `var` in the order -l prints them.
+var logicalVariables = []logicalVariable{
+	{"GIT_COMMITTER_IDENT", identVariable(cmd.CommitterRole)},
+	{"GIT_AUTHOR_IDENT", identVariable(cmd.AuthorRole)},
+	{"GIT_EDITOR", func(repo *cmd.GitRepository) (string, error) { return cmd.Editor(repo), nil }},
+	{"GIT_PAGER", func(repo *cmd.GitRepository) (string, error) { return cmd.Pager(repo), nil }},
+}
+
+// identVariable returns the value function of an identity variable.
+func identVariable(role string) func(repo *cmd.GitRepository) (string, error) {
+	return func(repo *cmd.GitRepository) (string, error) {
+		sig, err := cmd.Ident(repo, role)
+		if err != nil {
+			return "", err
+		}
+		return sig.String(), nil
+	}
+}
+
+// VarCommand creates the `var` command.
+func VarCommand() *cobra.Command {
+	var list bool
+
+	varCmd := &cobra.Command{
+		Use:   "var (-l | <variable>)",
+		Short: "Show a logical variable: GIT_AUTHOR_IDENT, GIT_COMMITTER_IDENT, GIT_EDITOR or GIT_PAGER",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			if list == (len(args) == 1) {
+				return fmt.Errorf("give either -l or a variable name")
+			}
+
+			// Like git var, this works outside of a repository too.
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				repo = nil
+			}
+
+			for _, variable := range logicalVariables {
+				switch {
+				case list:
+					// Identities that cannot be resolved are left out of the list.
+					if value, err := variable.value(repo); err == nil {
+						fmt.Printf("%s=%s\n", variable.name, value)
+					}
+				case variable.name == args[0]:
+					value, err := variable.value(repo)
+					if err != nil {
+						return err
+					}
+					fmt.Println(value)
+					return nil
+				}
+			}
+			if !list {
+				return fmt.Errorf("unknown variable '%s'", args[0])
+			}
+			return nil
+		},
+	}
+
+	varCmd.Flags().BoolVarP(&list, "list", "l", false, "List all logical variables")
+	return varCmd
+}
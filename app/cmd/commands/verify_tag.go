@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/utkarsh5026/justdoit/app/cmd"
+)
+
+// VerifyTagCommand creates the `verify-tag` command.
+func VerifyTagCommand() *cobra.Command {
+	var verbose bool
+
+	verifyTagCmd := &cobra.Command{
+		Use:   "verify-tag [-v] <tag>...",
+		Short: "Check the signatures of annotated tags",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			repo, err := cmd.FindRepository(".")
+			if err != nil {
+				return err
+			}
+			return verifyTags(repo, args, verbose)
+		},
+	}
+
+	verifyTagCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Print the contents of each tag before checking it")
+	return verifyTagCmd
+}
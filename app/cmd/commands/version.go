@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// VersionCommand creates the `version` command.
+func VersionCommand() *cobra.Command {
+	var buildOptions bool
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show the version and build information",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			version, revision, modified := "(devel)", "", false
+			if info, ok := debug.ReadBuildInfo(); ok {
+				if info.Main.Version != "" {
+					version = info.Main.Version
+				}
+				for _, setting := range info.Settings {
+					switch setting.Key {
+					case "vcs.revision":
+						revision = setting.Value
+					case "vcs.modified":
+						modified = setting.Value == "true"
+					}
+				}
+			}
+
+			fmt.Printf("justdoit version %s\n", version)
+			if !buildOptions {
+				return nil
+			}
+			if revision != "" {
+				if modified {
+					revision += "-dirty"
+				}
+				fmt.Printf("commit: %s\n", revision)
+			}
+			fmt.Printf("go version: %s\n", runtime.Version())
+			fmt.Printf("platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+			return nil
+		},
+	}
+
+	versionCmd.Flags().BoolVar(&buildOptions, "build-options", false, "Also show the commit, Go version and platform of the build")
+	return versionCmd
+}
@@ -0,0 +1,591 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// Commit is a parsed commit object.
+type Commit struct {
+	SHA       string
+	Tree      string
+	Parents   []string
+	Author    string
+	Committer string
+	Message   []byte
+	Kvlm      *Kvlm // The raw headers, for fields not exposed above.
+}
+
+// ReadCommit reads and parses the commit with the given SHA-1.
+//
+// Parameters:
+// - sha: The hex encoded SHA-1 of the commit.
+//
+// Returns:
+// - A pointer to the parsed Commit, with grafted parents if m uses grafts.
+// - An error if the object does not exist, is not a commit or is malformed.
+func (m *ObjectManager) ReadCommit(sha string) (*Commit, error) {
+	objType, data, err := m.ReadObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != CommitType {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", sha, objType)
+	}
+	commit, err := parseCommit(sha, data)
+	if err != nil {
+		return nil, err
+	}
+	m.applyGrafts(commit)
+	return commit, nil
+}
+
+// parseCommit builds a Commit from the content of a commit object.
+func parseCommit(sha string, data []byte) (*Commit, error) {
+	kvlm, err := ParseKvlm(data)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %w", sha, err)
+	}
+
+	commit := &Commit{
+		SHA:       sha,
+		Tree:      string(kvlm.Get("tree")),
+		Author:    string(kvlm.Get("author")),
+		Committer: string(kvlm.Get("committer")),
+		Message:   kvlm.Message,
+		Kvlm:      kvlm,
+	}
+	for _, parent := range kvlm.GetAll("parent") {
+		commit.Parents = append(commit.Parents, string(parent))
+	}
+
+	if !isValidSHA(commit.Tree) {
+		return nil, fmt.Errorf("commit %s has an invalid tree '%s'", sha, commit.Tree)
+	}
+	return commit, nil
+}
+
+// CommitTime returns the committer timestamp of the commit in seconds since the epoch.
+func (c *Commit) CommitTime() int64 {
+	timestamp, _ := parseSignatureTime(c.Committer)
+	return timestamp
+}
+
+// GitDateLayout is the default date format of git, as in git log.
+const GitDateLayout = "Mon Jan 2 15:04:05 2006 -0700"
+
+// Signature is the identity and time recorded in an author or committer line.
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time // In the timezone recorded with the signature.
+}
+
+// ParseSignature parses an author or committer line of the form
+// "Name <email> 1700000000 +0100". Missing parts are left empty.
+func ParseSignature(line string) Signature {
+	var sig Signature
+	start, end := strings.IndexByte(line, '<'), strings.LastIndexByte(line, '>')
+	if start < 0 || end < start {
+		sig.Name = strings.TrimSpace(line)
+		return sig
+	}
+	sig.Name = strings.TrimSpace(line[:start])
+	sig.Email = line[start+1 : end]
+
+	timestamp, zone := parseSignatureTime(line)
+	offset := 0
+	if len(zone) == 5 {
+		hours, _ := strconv.Atoi(zone[1:3])
+		minutes, _ := strconv.Atoi(zone[3:5])
+		offset = hours*3600 + minutes*60
+		if zone[0] == '-' {
+			offset = -offset
+		}
+	}
+	sig.When = time.Unix(timestamp, 0).In(time.FixedZone("", offset))
+	return sig
+}
+
+// parseSignatureTime extracts the timestamp and timezone from an author or
+// committer line of the form "Name <email> 1700000000 +0100".
+func parseSignatureTime(signature string) (int64, string) {
+	end := strings.LastIndexByte(signature, '>')
+	if end < 0 {
+		return 0, ""
+	}
+
+	fields := strings.Fields(signature[end+1:])
+	if len(fields) == 0 {
+		return 0, ""
+	}
+
+	timestamp, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, ""
+	}
+
+	if len(fields) > 1 {
+		return timestamp, fields[1]
+	}
+	return timestamp, ""
+}
+
+// IsAncestor reports whether ancestor is reachable from descendant by
+// following parent links. A commit is its own ancestor.
+func (m *ObjectManager) IsAncestor(ancestor, descendant string) (bool, error) {
+	seen := make(map[string]bool)
+	pending := []string{descendant}
+
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if sha == ancestor {
+			return true, nil
+		}
+		if seen[sha] {
+			continue
+		}
+		seen[sha] = true
+
+		commit, err := m.ReadCommit(sha)
+		if err != nil {
+			return false, err
+		}
+		pending = append(pending, commit.Parents...)
+	}
+	return false, nil
+}
+
+// CommitOptions controls CreateCommit.
+type CommitOptions struct {
+	Message     []byte // The message; on an amend without one, that of the amended commit.
+	Edit        bool   // Open the editor on the message before committing.
+	Amend       bool   // Replace the tip commit instead of adding to it.
+	ResetAuthor bool   // On an amend, take the current author and date instead of the original ones.
+	Fixup       string // Make a "fixup!" commit for this revision, for rebase --autosquash.
+	Squash      string // Make a "squash!" commit for this revision, for rebase --autosquash.
+	Signoff     bool   // Add a Signed-off-by trailer for the committer to the message.
+
+	// What to commit besides the index: with All, every tracked file that
+	// changed in the worktree; with Paths, only the given files or
+	// directories as they are in the worktree, on top of HEAD, or with
+	// Include, on top of the index. The index is updated to match.
+	All     bool
+	Paths   []string // Slash separated and relative to the top of the worktree.
+	Include bool
+
+	AllowEmpty        bool // Commit even when the tree is the same as that of the parent.
+	AllowEmptyMessage bool // Commit even when the message is empty.
+	DryRun            bool // Only find out whether there is anything to commit.
+	NoVerify          bool // Do not run the commit-msg hook.
+
+	// Reflog is the reflog message of the branch, instead of git's, e.g.
+	// "commit (amend): <subject>".
+	Reflog string
+
+	// Warnings receives the core.safecrlf warnings about the files staged
+	// with All or Paths; see StagePath.
+	Warnings   io.Writer
+	HookOutput io.Writer // Receives the output of the commit-msg hook; discarded when nil.
+}
+
+// CommitResult describes a commit made by CreateCommit. When Empty is set,
+// or for a dry run, no commit was made and SHA is empty.
+type CommitResult struct {
+	SHA     string
+	Branch  string // The branch moved to the commit, empty when HEAD is detached.
+	Root    bool   // The commit has no parents.
+	Subject string // The first line of the message.
+	Empty   bool   // The commit would have the same tree as its parent.
+}
+
+// Subject prefixes of the commits that rebase --autosquash melds into an
+// earlier commit.
+const (
+	FixupPrefix  = "fixup! "
+	SquashPrefix = "squash! "
+)
+
+// commitHelp is shown below a commit message in the editor.
+const commitHelp = `
+Please enter the commit message for your changes. Lines starting
+with '%s' will be ignored, and an empty message aborts the commit.
+`
+
+// CreateCommit records the index as a new commit on the current branch,
+// or on the detached HEAD. An amend replaces the tip commit instead: the
+// new commit gets its parents and, unless ResetAuthor is set, its author
+// and author date, and its message unless another is given. A fixup or
+// squash commit gets the subject of its target, prefixed with "fixup! " or
+// "squash! ", so that rebase --autosquash can later meld it into the
+// target; the given message, if any, follows after a blank line.
+//
+// While a merge is in progress, the commit concludes it: the commits of
+// MERGE_HEAD become its further parents and, unless another message is
+// given, MERGE_MSG its message. Such a commit cannot be an amend nor leave
+// out some of the staged changes. After a squash merge, SQUASH_MSG is the
+// default message instead. The merge state is removed once the commit is
+// made.
+//
+// Like git, CreateCommit makes no commit whose tree is the same as that of
+// its parent, unless AllowEmpty is set or the commit is a merge; it then
+// returns a result with Empty set instead, before any editor runs. A dry
+// run stops at the same point, after working out whether the commit would
+// be empty.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - opts: The message and the kind of commit.
+//
+// Returns:
+// - The new commit and the branch it was made on.
+// - An error if the index has unmerged entries, there is nothing to amend
+// or a merge is in progress with an amend or paths, the message is empty without AllowEmptyMessage or the identity is
+// unknown.
+func CreateCommit(repo *GitRepository, opts CommitOptions) (*CommitResult, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	switch {
+	case opts.Fixup != "" && opts.Squash != "":
+		return nil, fmt.Errorf("options '--squash' and '--fixup' cannot be used together")
+	case opts.Amend && (opts.Fixup != "" || opts.Squash != ""):
+		return nil, fmt.Errorf("options '--amend' and '--fixup' or '--squash' cannot be used together")
+	case opts.ResetAuthor && !opts.Amend:
+		return nil, fmt.Errorf("--reset-author can be used only with --amend")
+	case opts.All && len(opts.Paths) > 0:
+		return nil, fmt.Errorf("paths '%s ...' with -a does not make sense", opts.Paths[0])
+	case opts.Include && len(opts.Paths) == 0:
+		return nil, fmt.Errorf("no paths with --include does not make sense")
+	}
+	defer trace.Start(trace.Perf, "commit")()
+
+	mergeHeads, err := ReadMergeHeads(repo)
+	if err != nil {
+		return nil, err
+	}
+	if mergeHeads != nil {
+		switch {
+		case opts.Amend:
+			return nil, fmt.Errorf("you are in the middle of a merge -- cannot amend")
+		case len(opts.Paths) > 0 && !opts.Include:
+			return nil, fmt.Errorf("cannot do a partial commit during a merge")
+		}
+	}
+
+	objects := NewObjectManager(repo)
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return nil, err
+	}
+	// With -a or paths the index is updated too, so it stays locked until
+	// the commit is made, across the editor and hooks as in git.
+	var lock *IndexLock
+	var index *Index
+	if opts.All || len(opts.Paths) > 0 {
+		if lock, index, err = LockIndex(repo); err != nil {
+			return nil, err
+		}
+		defer lock.Release()
+	} else if index, err = ReadIndex(repo); err != nil {
+		return nil, err
+	}
+	snapshot, err := commitIndex(repo, objects, index, head, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range snapshot.Entries {
+		if entry.Stage() != 0 {
+			return nil, fmt.Errorf("committing is not possible because you have unmerged files")
+		}
+	}
+	files := IndexFiles(snapshot)
+	tree, err := objects.WriteTreeFromFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	var parents []string
+	var author string
+	message := opts.Message
+	if opts.Amend {
+		if head.SHA == "" {
+			return nil, fmt.Errorf("you have nothing to amend")
+		}
+		amended, err := objects.ReadCommit(head.SHA)
+		if err != nil {
+			return nil, err
+		}
+		parents = amended.Parents
+		if !opts.ResetAuthor {
+			author = amended.Author
+		}
+		if message == nil {
+			message = amended.Message
+		}
+	} else if head.SHA != "" {
+		parents = []string{head.SHA}
+	}
+	parents = append(parents, mergeHeads...)
+	if message == nil && !opts.Amend && opts.Fixup == "" && opts.Squash == "" {
+		if message, err = ReadMergeMessage(repo); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &CommitResult{Branch: head.Branch(), Root: len(parents) == 0}
+	if len(parents) <= 1 {
+		parentTree := ""
+		if len(parents) == 1 {
+			parent, err := objects.ReadCommit(parents[0])
+			if err != nil {
+				return nil, err
+			}
+			parentTree = parent.Tree
+		}
+		result.Empty = tree == parentTree || (len(parents) == 0 && len(files) == 0)
+	}
+	if opts.DryRun || (result.Empty && !opts.AllowEmpty) {
+		return result, nil
+	}
+	result.Empty = false
+
+	switch {
+	case opts.Fixup != "":
+		message, err = autosquashMessage(repo, objects, FixupPrefix, opts.Fixup, message)
+	case opts.Squash != "":
+		message, err = autosquashMessage(repo, objects, SquashPrefix, opts.Squash, message)
+	}
+	if err != nil {
+		return nil, err
+	}
+	committer, err := Ident(repo, CommitterRole)
+	if err != nil {
+		return nil, err
+	}
+	// Like git, sign off before the editor opens, so that it shows.
+	if opts.Signoff {
+		if len(message) > 0 && message[len(message)-1] != '\n' {
+			message = append(message, '\n')
+		}
+		message = AppendSignoff(repo, message, fmt.Sprintf("%s <%s>", committer.Name, committer.Email))
+	}
+	if opts.Edit {
+		if message, err = EditMessage(repo, message, commitHelp); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.NoVerify {
+		if message, err = runCommitMsgHook(repo, message, opts.Edit, opts.HookOutput); err != nil {
+			return nil, err
+		}
+	}
+	message = Stripspace(message, "")
+	if len(message) == 0 && !opts.AllowEmptyMessage {
+		return nil, fmt.Errorf("aborting commit due to empty commit message")
+	}
+
+	if author == "" {
+		sig, err := Ident(repo, AuthorRole)
+		if err != nil {
+			return nil, err
+		}
+		author = sig.String()
+	}
+
+	kvlm := &Kvlm{Message: message}
+	kvlm.Add("tree", []byte(tree))
+	for _, parent := range parents {
+		kvlm.Add("parent", []byte(parent))
+	}
+	kvlm.Add("author", []byte(author))
+	kvlm.Add("committer", []byte(committer.String()))
+	sha, err := objects.WriteObject(CommitType, kvlm.Serialize(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := head.Ref
+	if ref == "" {
+		ref = HeadFile
+	}
+	result.Subject, _, _ = strings.Cut(string(message), "\n")
+	reflog := opts.Reflog
+	if reflog == "" {
+		action := "commit"
+		switch {
+		case opts.Amend:
+			action = "commit (amend)"
+		case len(parents) == 0:
+			action = "commit (initial)"
+		case len(mergeHeads) > 0:
+			action = "commit (merge)"
+		}
+		reflog = action + ": " + result.Subject
+	}
+	if err := UpdateRef(repo, ref, sha, reflog); err != nil {
+		return nil, err
+	}
+	if opts.All || len(opts.Paths) > 0 {
+		if err := lock.Write(index); err != nil {
+			return nil, err
+		}
+	}
+	if err := clearMergeState(repo); err != nil {
+		return nil, err
+	}
+	result.SHA = sha
+	trace.Log(trace.Ref, "commit", "ref", ref, "sha", sha, "amend", opts.Amend)
+	return result, nil
+}
+
+// commitIndex prepares the index a commit records. With All or Paths, the
+// files concerned are staged in index, and with Paths but not Include, the
+// commit records a separate snapshot instead: the tree of HEAD with just
+// those files taken from the index, so that other staged changes stay out
+// of the commit, as in git.
+func commitIndex(repo *GitRepository, objects *ObjectManager, index *Index, head Head, opts CommitOptions) (*Index, error) {
+	if opts.All {
+		staged := IndexFiles(index)
+		worktree, err := WorktreeFiles(repo, index, NewEOLConverter(repo))
+		if err != nil {
+			return nil, err
+		}
+		var changed []string
+		for _, entry := range index.Entries {
+			if len(changed) > 0 && changed[len(changed)-1] == entry.Name {
+				continue
+			}
+			current, ok := worktree[entry.Name]
+			if entry.Stage() != 0 || (indexModeString(entry.Mode) != ModeGitlink && (!ok || !sameTreeEntry(current, staged[entry.Name]))) {
+				changed = append(changed, entry.Name)
+			}
+		}
+		for _, name := range changed {
+			if err := StagePath(repo, index, name, opts.Warnings); err != nil {
+				return nil, err
+			}
+		}
+		return index, nil
+	}
+	if len(opts.Paths) == 0 {
+		return index, nil
+	}
+
+	headFiles := make(map[string]TreeEntry)
+	if head.SHA != "" {
+		commit, err := objects.ReadCommit(head.SHA)
+		if err != nil {
+			return nil, err
+		}
+		if headFiles, err = objects.FlattenTree(commit.Tree); err != nil {
+			return nil, err
+		}
+	}
+	known := make(map[string]bool, len(headFiles))
+	for name := range headFiles {
+		known[name] = true
+	}
+	for _, entry := range index.Entries {
+		known[entry.Name] = true
+	}
+	var matched []string
+	for _, spec := range opts.Paths {
+		found := false
+		for name := range known {
+			if pathspecMatches(name, spec) {
+				matched = append(matched, name)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", spec)
+		}
+	}
+	sort.Strings(matched)
+	matched = slices.Compact(matched)
+	for _, name := range matched {
+		if err := StagePath(repo, index, name, opts.Warnings); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Include {
+		return index, nil
+	}
+
+	snapshot := &Index{Version: index.Version}
+	for name, file := range headFiles {
+		entry := &IndexEntry{Name: name, SHA: file.SHA}
+		fmt.Sscanf(file.Mode, "%o", &entry.Mode)
+		snapshot.Entries = append(snapshot.Entries, entry)
+	}
+	slices.SortFunc(snapshot.Entries, compareIndexEntries)
+	for _, name := range matched {
+		snapshot.Remove(name)
+		if entry := index.Entry(name); entry != nil {
+			snapshot.Add(entry)
+		}
+	}
+	return snapshot, nil
+}
+
+// autosquashMessage builds the message of a fixup or squash commit: the
+// prefix and the subject of the target commit, then the given message.
+func autosquashMessage(repo *GitRepository, objects *ObjectManager, prefix, target string, message []byte) ([]byte, error) {
+	sha, err := ResolveRevision(repo, target+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup commit '%s'", target)
+	}
+	commit, err := objects.ReadCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+	subject, _, _ := strings.Cut(strings.TrimSpace(string(commit.Message)), "\n")
+	out := []byte(prefix + subject + "\n")
+	if len(message) > 0 {
+		out = append(append(out, '\n'), message...)
+	}
+	return out, nil
+}
+
+// runCommitMsgHook lets the commit-msg hook check or rewrite a message: the
+// message is written to COMMIT_EDITMSG, whose path is the hook's only
+// argument, and read back when the hook succeeds. Comment lines are
+// stripped again when the message was edited, as the editor's were. The
+// hook's output goes to output.
+func runCommitMsgHook(repo *GitRepository, message []byte, edited bool, output io.Writer) ([]byte, error) {
+	if _, ok := HookPath(repo, "commit-msg"); !ok {
+		return message, nil
+	}
+	file, err := filepath.Abs(createRepoPath(repo, CommitEditMsgFile))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, message, 0644); err != nil {
+		return nil, err
+	}
+	if _, err := RunHook(repo, "commit-msg", HookRun{Args: []string{file}, Output: output}); err != nil {
+		return nil, fmt.Errorf("the commit-msg hook refused the message: %v", err)
+	}
+
+	hooked, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	commentChar := ""
+	if edited {
+		if commentChar, err = CommentChar(repo); err != nil {
+			return nil, err
+		}
+	}
+	return Stripspace(hooked, commentChar), nil
+}
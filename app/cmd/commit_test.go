@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCreateCommitWritesWarningsToCaller(t *testing.T) {
+	repo := newTestRepo(t)
+	t.Setenv("GIT_AUTHOR_NAME", "A U Thor")
+	t.Setenv("GIT_AUTHOR_EMAIL", "author@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "C O Mitter")
+	t.Setenv("GIT_COMMITTER_EMAIL", "committer@example.com")
+	appendRepoConfig(t, repo, "[core]\n\tautocrlf = true\n\tsafecrlf = warn\n")
+
+	writeWorktreeFile(t, repo, "file.txt", "one\n")
+	if _, err := Add(repo, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	writeWorktreeFile(t, repo, "file.txt", "one\ntwo\n")
+	var warnings bytes.Buffer
+	if _, err := CreateCommit(repo, CommitOptions{Message: []byte("change\n"), All: true, Warnings: &warnings}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "warning: in the working copy of 'file.txt', LF will be replaced by CRLF"; !strings.Contains(warnings.String(), want) {
+		t.Errorf("warnings = %q, want %q", warnings.String(), want)
+	}
+}
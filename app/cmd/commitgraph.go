@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+const CommitGraphFile = "commit-graph"
+
+const (
+	commitGraphSignature   = "CGPH"
+	commitGraphVersion     = 1
+	commitGraphHashVersion = 1 // SHA-1
+
+	graphParentNone    = 0x70000000
+	graphExtraEdges    = 0x80000000
+	graphLastEdge      = 0x80000000
+	graphMaxGeneration = 0x3fffffff
+)
+
+// reachableCommits walks the history of every ref and HEAD and returns all
+// commits found, keyed by SHA-1.
+func reachableCommits(repo *GitRepository, objects *ObjectManager) (map[string]*Commit, error) {
+	pending, err := refRoots(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make(map[string]*Commit)
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if _, seen := commits[sha]; seen {
+			continue
+		}
+
+		objType, data, err := objects.ReadObject(sha)
+		if err != nil {
+			return nil, err
+		}
+
+		// Refs may point at tags or other objects; only commits are graphed.
+		if objType == TagType {
+			if kvlm, err := ParseKvlm(data); err == nil {
+				pending = append(pending, string(kvlm.Get("object")))
+			}
+			continue
+		}
+		if objType != CommitType {
+			continue
+		}
+
+		commit, err := parseCommit(sha, data)
+		if err != nil {
+			return nil, err
+		}
+		commits[sha] = commit
+		pending = append(pending, commit.Parents...)
+	}
+
+	return commits, nil
+}
+
+// commitGenerations computes the topological level of every commit: roots
+// have generation 1 and every other commit is one more than its highest parent.
+func commitGenerations(commits map[string]*Commit) map[string]uint32 {
+	generations := make(map[string]uint32, len(commits))
+
+	for sha := range commits {
+		stack := []string{sha}
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			if _, done := generations[current]; done {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			var generation uint32 = 1
+			ready := true
+			for _, parent := range commits[current].Parents {
+				parentGeneration, done := generations[parent]
+				if !done {
+					stack = append(stack, parent)
+					ready = false
+					continue
+				}
+				if parentGeneration+1 > generation {
+					generation = parentGeneration + 1
+				}
+			}
+
+			if ready {
+				generations[current] = min(generation, graphMaxGeneration)
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	return generations
+}
+
+// WriteCommitGraph writes objects/info/commit-graph covering every commit
+// reachable from the refs, in the format understood by git.
+//
+// Returns:
+// - The number of commits in the graph.
+// - An error if history cannot be walked or the file cannot be written.
+func WriteCommitGraph(repo *GitRepository) (int, error) {
+	defer trace.Start(trace.Perf, "write commit-graph")()
+
+	commits, err := reachableCommits(repo, NewObjectManager(repo))
+	if err != nil {
+		return 0, err
+	}
+
+	shas := make([]string, 0, len(commits))
+	for sha := range commits {
+		shas = append(shas, sha)
+	}
+	sort.Strings(shas)
+
+	positions := make(map[string]uint32, len(shas))
+	for i, sha := range shas {
+		positions[sha] = uint32(i)
+	}
+	generations := commitGenerations(commits)
+
+	var fanout, oids, data, edges bytes.Buffer
+	var counts [256]uint32
+	for _, sha := range shas {
+		raw, _ := hex.DecodeString(sha)
+		counts[raw[0]]++
+		oids.Write(raw)
+	}
+	var total uint32
+	for _, count := range counts {
+		total += count
+		_ = binary.Write(&fanout, binary.BigEndian, total)
+	}
+
+	for _, sha := range shas {
+		commit := commits[sha]
+		tree, _ := hex.DecodeString(commit.Tree)
+		data.Write(tree)
+
+		parent1, parent2 := uint32(graphParentNone), uint32(graphParentNone)
+		if len(commit.Parents) > 0 {
+			parent1 = positions[commit.Parents[0]]
+		}
+		if len(commit.Parents) == 2 {
+			parent2 = positions[commit.Parents[1]]
+		} else if len(commit.Parents) > 2 {
+			parent2 = graphExtraEdges | uint32(edges.Len()/4)
+			for i, parent := range commit.Parents[1:] {
+				edge := positions[parent]
+				if i == len(commit.Parents)-2 {
+					edge |= graphLastEdge
+				}
+				_ = binary.Write(&edges, binary.BigEndian, edge)
+			}
+		}
+		_ = binary.Write(&data, binary.BigEndian, parent1)
+		_ = binary.Write(&data, binary.BigEndian, parent2)
+
+		commitTime := uint64(commit.CommitTime())
+		_ = binary.Write(&data, binary.BigEndian, generations[sha]<<2|uint32(commitTime>>32)&3)
+		_ = binary.Write(&data, binary.BigEndian, uint32(commitTime))
+	}
+
+	type chunk struct {
+		id   string
+		data []byte
+	}
+	chunks := []chunk{{"OIDF", fanout.Bytes()}, {"OIDL", oids.Bytes()}, {"CDAT", data.Bytes()}}
+	if edges.Len() > 0 {
+		chunks = append(chunks, chunk{"EDGE", edges.Bytes()})
+	}
+
+	var file bytes.Buffer
+	file.WriteString(commitGraphSignature)
+	file.Write([]byte{commitGraphVersion, commitGraphHashVersion, byte(len(chunks)), 0})
+
+	offset := uint64(file.Len() + (len(chunks)+1)*12)
+	for _, c := range chunks {
+		file.WriteString(c.id)
+		_ = binary.Write(&file, binary.BigEndian, offset)
+		offset += uint64(len(c.data))
+	}
+	file.Write([]byte{0, 0, 0, 0})
+	_ = binary.Write(&file, binary.BigEndian, offset)
+
+	for _, c := range chunks {
+		file.Write(c.data)
+	}
+	sum := sha1.Sum(file.Bytes())
+	file.Write(sum[:])
+
+	path := createRepoPath(repo, ObjectsDir, "info", CommitGraphFile)
+	if err := writeRepoFile(repo, fsyncCommitGraph, path, file.Bytes(), 0444); err != nil {
+		return 0, err
+	}
+	return len(shas), nil
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// GlobalConfigEnv names a file to use instead of the user's global config,
+// like git's GIT_CONFIG_GLOBAL.
+const GlobalConfigEnv = "GIT_CONFIG_GLOBAL"
+
+// GlobalConfig reads the user's global configuration: the file named by
+// GIT_CONFIG_GLOBAL, or $XDG_CONFIG_HOME/git/config followed by
+// ~/.gitconfig, which wins where both set a variable. Missing or unreadable
+// files are skipped, so the result may be empty but is never nil.
+func GlobalConfig() *viper.Viper {
+	config := viper.New()
+	config.SetConfigType("ini")
+	for _, path := range globalConfigFiles() {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		if err := config.MergeConfig(file); err != nil {
+			trace.Log(trace.Config, "global config not read", "path", path, "error", err)
+		}
+		file.Close()
+	}
+	return config
+}
+
+// globalConfigFiles returns the paths of the global config files, in the
+// order they are read.
+func globalConfigFiles() []string {
+	if file, ok := os.LookupEnv(GlobalConfigEnv); ok {
+		return []string{file}
+	}
+	var files []string
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	home, _ := os.UserHomeDir()
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	if xdg != "" {
+		files = append(files, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		files = append(files, filepath.Join(home, ".gitconfig"))
+	}
+	return files
+}
+
+// lookupConfig returns a config variable from the repository config, or
+// from the global config when the repository does not set it, with git's
+// quoting undone. repo may be nil outside of a repository.
+func lookupConfig(repo *GitRepository, key string) string {
+	if repo != nil && repo.Config().IsSet(key) {
+		return unquoteConfigValue(repo.Config().GetString(key))
+	}
+	return unquoteConfigValue(GlobalConfig().GetString(key))
+}
+
+// configSectionHeader formats the header line of a config section.
+func configSectionHeader(section, subsection string) string {
+	if subsection == "" {
+		return fmt.Sprintf("[%s]", section)
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(subsection)
+	return fmt.Sprintf("[%s \"%s\"]", section, escaped)
+}
+
+// parseConfigSectionHeader parses "[section]", "[section "sub"]" or the
+// legacy "[section.sub]". ok is false for lines that are not headers.
+func parseConfigSectionHeader(line string) (section, subsection string, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") {
+		return "", "", false
+	}
+	end := strings.LastIndex(trimmed, "]")
+	if end < 0 {
+		return "", "", false
+	}
+	inner := trimmed[1:end]
+
+	if name, quoted, found := strings.Cut(inner, " "); found {
+		quoted = strings.TrimSpace(quoted)
+		quoted = strings.TrimSuffix(strings.TrimPrefix(quoted, `"`), `"`)
+		unescaped := strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(quoted)
+		return strings.ToLower(name), unescaped, true
+	}
+	if name, sub, found := strings.Cut(inner, "."); found {
+		return strings.ToLower(name), strings.ToLower(sub), true
+	}
+	return strings.ToLower(inner), "", true
+}
+
+// configLineKey returns the lowercased variable name of a "name = value"
+// line, or "" for blank lines, comments and headers.
+func configLineKey(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' || trimmed[0] == '[' {
+		return ""
+	}
+	name, _, _ := strings.Cut(trimmed, "=")
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// quoteConfigValue quotes a value when git would need quotes to read it back.
+func quoteConfigValue(value string) string {
+	needsQuotes := value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;\"\\\n\t")
+	if !needsQuotes {
+		return value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(value)
+	return `"` + escaped + `"`
+}
+
+// unquoteConfigValue undoes the quoting of a value as git reads it: double
+// quotes are dropped and \", \\, \n, \t and \b are unescaped. The ini
+// parser leaves these alone, which matters for values such as tool
+// commands that quote their arguments.
+func unquoteConfigValue(value string) string {
+	if !strings.ContainsAny(value, `"\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '"':
+		case c == '\\' && i+1 < len(value):
+			i++
+			switch value[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			default:
+				b.WriteByte(value[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// SetConfig writes a variable to the repository's config file the way
+// git config does: an existing value in the matching section is replaced,
+// otherwise the variable is added to the section, which is created at the
+// end of the file if needed. The in-memory config is reloaded afterwards.
+//
+// Parameters:
+// - repo: The repository whose config is changed.
+// - section: The section name, e.g. "branch".
+// - subsection: The subsection, e.g. a branch name. May be empty.
+// - name: The variable name, e.g. "remote".
+// - value: The new value.
+//
+// Returns:
+// - An error if the config file cannot be read or written.
+func SetConfig(repo *GitRepository, section, subsection, name, value string) error {
+	trace.Log(trace.Config, "set", "section", section, "subsection", subsection, "name", name)
+	return editConfigLines(repo, func(lines []string) ([]string, bool) {
+		entry := fmt.Sprintf("\t%s = %s", name, quoteConfigValue(value))
+		lastInSection := -1
+		inSection := false
+		for i, line := range lines {
+			if s, sub, ok := parseConfigSectionHeader(line); ok {
+				inSection = s == strings.ToLower(section) && sub == subsection
+				if inSection {
+					lastInSection = i
+				}
+				continue
+			}
+			if !inSection {
+				continue
+			}
+			if configLineKey(line) == strings.ToLower(name) {
+				lines[i] = entry
+				return lines, true
+			}
+			if strings.TrimSpace(line) != "" {
+				lastInSection = i
+			}
+		}
+
+		if lastInSection >= 0 {
+			return append(lines[:lastInSection+1], append([]string{entry}, lines[lastInSection+1:]...)...), true
+		}
+		return append(lines, configSectionHeader(section, subsection), entry), true
+	})
+}
+
+// UnsetConfig removes a variable from the repository's config file, and the
+// section too when nothing else is left in it. Removing a variable that is
+// not set is not an error.
+func UnsetConfig(repo *GitRepository, section, subsection, name string) error {
+	trace.Log(trace.Config, "unset", "section", section, "subsection", subsection, "name", name)
+	return editConfigLines(repo, func(lines []string) ([]string, bool) {
+		var kept []string
+		header := -1
+		inSection := false
+		for _, line := range lines {
+			if s, sub, ok := parseConfigSectionHeader(line); ok {
+				inSection = s == strings.ToLower(section) && sub == subsection
+				if inSection {
+					header = len(kept)
+				}
+				kept = append(kept, line)
+				continue
+			}
+			if inSection && configLineKey(line) == strings.ToLower(name) {
+				continue
+			}
+			kept = append(kept, line)
+		}
+
+		// Drop the section header if the section became empty.
+		if header >= 0 {
+			empty := true
+			for _, line := range kept[header+1:] {
+				if _, _, ok := parseConfigSectionHeader(line); ok {
+					break
+				}
+				if strings.TrimSpace(line) != "" {
+					empty = false
+					break
+				}
+			}
+			if empty {
+				kept = append(kept[:header], kept[header+1:]...)
+			}
+		}
+		return kept, true
+	})
+}
+
+// renameConfigSection moves the variables of every [section "from"] of the
+// config file into one [section "to"], or with keep copies them there. The
+// copy is placed at the end of the file. Any existing [section "to"] is
+// replaced. Nothing is written when there is no [section "from"].
+func renameConfigSection(repo *GitRepository, section, from, to string, keep bool) error {
+	trace.Log(trace.Config, "rename section", "section", section, "from", from, "to", to, "copy", keep)
+	return editConfigLines(repo, func(lines []string) ([]string, bool) {
+		var kept, moved []string
+		found, inFrom, inTo := false, false, false
+		for _, line := range lines {
+			if s, sub, ok := parseConfigSectionHeader(line); ok {
+				inFrom = s == strings.ToLower(section) && sub == from
+				inTo = s == strings.ToLower(section) && sub == to
+				found = found || inFrom
+				if inTo || (inFrom && !keep) {
+					continue
+				}
+			} else if inFrom && strings.TrimSpace(line) != "" {
+				moved = append(moved, line)
+			}
+			if !inTo && (keep || !inFrom) {
+				kept = append(kept, line)
+			}
+		}
+		if !found {
+			return nil, false
+		}
+
+		kept = append(kept, configSectionHeader(section, to))
+		return append(kept, moved...), true
+	})
+}
+
+// readConfigLines reads the config file as lines, without the final newline.
+func readConfigLines(repo *GitRepository) ([]string, error) {
+	data, err := readFile(repo.fs, createRepoPath(repo, ConfigFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content := strings.TrimSuffix(string(data), "\n")
+	if content == "" {
+		return nil, nil
+	}
+	return strings.Split(content, "\n"), nil
+}
+
+// editConfigLines changes the config file under config.lock, as git config
+// does: the lock is taken before the file is read, so that two processes
+// editing the config cannot both start from the same content and one lose
+// the other's change. edit is given the lines of the file and returns
+// them changed, or false when there is nothing to write. A new snapshot of
+// the config is loaded afterwards, leaving snapshots already handed out by
+// Config untouched.
+//
+// Returns:
+// - An error if the config is locked or cannot be read or written.
+func editConfigLines(repo *GitRepository, edit func(lines []string) ([]string, bool)) error {
+	path := createRepoPath(repo, ConfigFile)
+	lock, err := repo.fs.OpenFile(path+lockSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("could not lock config file %s: another process seems to be running; remove the file if it crashed", path+lockSuffix)
+		}
+		return err
+	}
+
+	lines, err := readConfigLines(repo)
+	changed := false
+	if err == nil {
+		lines, changed = edit(lines)
+	}
+	if err == nil && changed {
+		_, err = lock.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	}
+	if closeErr := lock.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && changed {
+		err = repo.fs.Rename(path+lockSuffix, path)
+	}
+	if err != nil || !changed {
+		repo.fs.Remove(path + lockSuffix)
+		return err
+	}
+	return loadRepoConfig(repo, true)
+}
+
+// readConfigFile reads the file config is set to through fsys.
+func readConfigFile(fsys FileSystem, config *viper.Viper) error {
+	file, err := fsys.Open(config.ConfigFileUsed())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return config.ReadConfig(file)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+// TestSetConfigParallelWriters sets a different variable from each of many
+// goroutines. Without config.lock taken before the file is read, writers
+// starting from the same content would overwrite each other's variable.
+func TestSetConfigParallelWriters(t *testing.T) {
+	repo := newTestRepo(t)
+	const writers = 16
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- retryLocked(func() error {
+				return SetConfig(repo, "test", "", fmt.Sprintf("key%02d", i), fmt.Sprint(i))
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := OpenRepository(repo.WorkTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range writers {
+		key := fmt.Sprintf("test.key%02d", i)
+		if got := reopened.Config().GetString(key); got != fmt.Sprint(i) {
+			t.Errorf("%s = %q, want %q", key, got, fmt.Sprint(i))
+		}
+	}
+	if _, err := os.Stat(createRepoPath(repo, ConfigFile+lockSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("config.lock left behind: %v", err)
+	}
+}
+
+func TestSetConfigFailsWhileLocked(t *testing.T) {
+	repo := newTestRepo(t)
+	lock := createRepoPath(repo, ConfigFile+lockSuffix)
+	if err := os.WriteFile(lock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfig(repo, "test", "", "key", "value"); err == nil {
+		t.Fatal("SetConfig succeeded while config.lock was held")
+	}
+	if _, err := os.Stat(lock); err != nil {
+		t.Fatalf("the lock of another writer was removed: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ObjectCounts describes the object database as git count-objects does.
+// Sizes are in bytes.
+type ObjectCounts struct {
+	Count         int      // Loose objects.
+	Size          int64    // Disk space of the loose objects.
+	InPack        int      // Objects in packs.
+	Packs         int      // Pack files.
+	SizePack      int64    // Size of the packs and their indexes.
+	PrunePackable int      // Loose objects that are also packed.
+	Garbage       []string // Files in the object database that are neither objects nor packs.
+	SizeGarbage   int64    // Size of the garbage.
+}
+
+// packFileSuffixes are the files that may sit next to a pack.
+var packFileSuffixes = []string{".pack", ".idx", ".keep", ".promisor", ".bitmap", ".rev", ".mtimes"}
+
+// CountObjects counts the loose objects of every fan-out directory and the
+// objects of every pack, and the files that do not belong there.
+//
+// Parameters:
+// - repo: The repository.
+//
+// Returns:
+// - The counts.
+// - An error if a directory cannot be read or a pack index is corrupt.
+func CountObjects(repo *GitRepository) (ObjectCounts, error) {
+	var counts ObjectCounts
+	objects := NewObjectManager(repo)
+	objectsPath := createRepoPath(repo, ObjectsDir)
+
+	for shard := 0; shard < 256; shard++ {
+		prefix := fmt.Sprintf("%02x", shard)
+		dir := filepath.Join(objectsPath, prefix)
+		files, err := listDir(repo.fs, dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return counts, err
+		}
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil {
+				return counts, err
+			}
+			sha := prefix + file.Name()
+			if !isValidSHA(sha) || file.IsDir() {
+				counts.Garbage = append(counts.Garbage, filepath.Join(dir, file.Name()))
+				counts.SizeGarbage += info.Size()
+				continue
+			}
+			counts.Count++
+			counts.Size += diskUsage(info)
+			if objects.inPack(sha) {
+				counts.PrunePackable++
+			}
+		}
+	}
+
+	packPath := filepath.Join(objectsPath, PackDir)
+	files, err := listDir(repo.fs, packPath)
+	if err != nil && !os.IsNotExist(err) {
+		return counts, err
+	}
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			return counts, err
+		}
+		name := file.Name()
+		suffix := filepath.Ext(name)
+		base := strings.TrimSuffix(name, suffix)
+		known := strings.HasPrefix(name, "pack-") && !file.IsDir()
+		switch {
+		case known && suffix == ".pack" && pathExists(repo.fs, filepath.Join(packPath, base+".idx")):
+			pack, err := openPackFile(repo.fs, filepath.Join(packPath, name))
+			if err != nil {
+				return counts, err
+			}
+			counts.Packs++
+			counts.InPack += pack.index.count()
+			counts.SizePack += info.Size()
+		case known && suffix == ".idx" && pathExists(repo.fs, filepath.Join(packPath, base+".pack")):
+			counts.SizePack += info.Size()
+		case known && suffix != ".pack" && suffix != ".idx" && slices.Contains(packFileSuffixes, suffix):
+		default:
+			counts.Garbage = append(counts.Garbage, filepath.Join(packPath, name))
+			counts.SizeGarbage += info.Size()
+		}
+	}
+	return counts, nil
+}
+
+// tooManyLooseObjects reports whether the repository holds more than
+// limit loose objects. Like git gc --auto it only counts the fan-out
+// directory objects/17: names are evenly spread over the 256 directories,
+// so one of them is enough for an estimate.
+func tooManyLooseObjects(repo *GitRepository, limit int) (bool, error) {
+	dir := createRepoPath(repo, ObjectsDir, "17")
+	files, err := listDir(repo.fs, dir)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	count := 0
+	for _, file := range files {
+		if isValidSHA("17" + file.Name()) {
+			count++
+		}
+	}
+	return count > (limit+255)/256, nil
+}
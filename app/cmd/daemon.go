@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+const (
+	// DefaultDaemonPort is the registered port of the git protocol.
+	DefaultDaemonPort = 9418
+	// DaemonExportFile marks a repository as exported by the daemon.
+	DaemonExportFile = "git-daemon-export-ok"
+)
+
+// DaemonOptions configures RunDaemon.
+type DaemonOptions struct {
+	Listen         string        // Address to listen on, e.g. ":9418".
+	BasePath       string        // Directory that request paths are resolved against.
+	ExportAll      bool          // Serve repositories without git-daemon-export-ok.
+	MaxConnections int           // Maximum simultaneous clients; 0 means unlimited.
+	Timeout        time.Duration // Idle timeout per connection; 0 means none.
+}
+
+// RunDaemon serves read-only fetches over the git:// protocol. Only
+// git-upload-pack is offered.
+//
+// Parameters:
+// - opts: Listening address, repository location and limits.
+// - logger: Where connection events are logged.
+//
+// Returns:
+// - An error if the address cannot be listened on.
+func RunDaemon(opts DaemonOptions, logger *log.Logger) error {
+	listener, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	logger.Printf("Ready to rumble on %s", listener.Addr())
+	return serveDaemon(listener, opts, logger)
+}
+
+// Bounds of the wait before accepting again after Accept fails.
+const (
+	daemonAcceptMinDelay = 5 * time.Millisecond
+	daemonAcceptMaxDelay = time.Second
+)
+
+// serveDaemon accepts and serves connections until listener is closed.
+// Accept errors such as running out of file descriptors (EMFILE) or a
+// client aborting before it is accepted (ECONNABORTED) do not stop the
+// daemon: it logs them and tries again after a delay that doubles with each
+// failure in a row, as net/http does.
+func serveDaemon(listener net.Listener, opts DaemonOptions, logger *log.Logger) error {
+	var slots chan struct{}
+	if opts.MaxConnections > 0 {
+		slots = make(chan struct{}, opts.MaxConnections)
+	}
+
+	var delay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			delay = min(max(2*delay, daemonAcceptMinDelay), daemonAcceptMaxDelay)
+			logger.Printf("Accept error: %s; retrying in %s", err, delay)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			default:
+				logger.Printf("[%s] Too many connections, rejecting", conn.RemoteAddr())
+				_ = transport.NewEncoder(conn).Encodef("ERR too many connections")
+				conn.Close()
+				continue
+			}
+		}
+
+		go func() {
+			defer func() {
+				if slots != nil {
+					<-slots
+				}
+			}()
+			defer conn.Close()
+
+			if err := serveDaemonConnection(conn, opts); err != nil {
+				logger.Printf("[%s] %s", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// serveDaemonConnection handles a single git:// request of the form
+// "git-upload-pack /path\0host=example.com\0".
+func serveDaemonConnection(conn net.Conn, opts DaemonOptions) error {
+	var stream io.ReadWriter = conn
+	if opts.Timeout > 0 {
+		stream = &deadlineConn{conn: conn, timeout: opts.Timeout}
+	}
+
+	enc := transport.NewEncoder(stream)
+	_, payload, err := transport.NewDecoder(stream).Read()
+	if err != nil {
+		return err
+	}
+
+	request, _, _ := bytes.Cut(payload, []byte{0})
+	service, path, ok := strings.Cut(strings.TrimSuffix(string(request), "\n"), " ")
+	if !ok {
+		return enc.Encodef("ERR malformed request")
+	}
+
+	if service != "git-upload-pack" {
+		_ = enc.Encodef("ERR service not enabled: %s", service)
+		return fmt.Errorf("refused service %s", service)
+	}
+
+	repo, err := resolveServedRepository(path, opts.BasePath, opts.ExportAll)
+	if err != nil {
+		_ = enc.Encodef("ERR access denied or repository not exported: %s", path)
+		return err
+	}
+
+	return UploadPack(repo, stream, stream, UploadPackOptions{})
+}
+
+// resolveServedRepository maps a request path onto an exported repository
+// below the base path, trying <path>, <path>.git and <path>/.git. Unless
+// exportAll is set, the repository must contain git-daemon-export-ok.
+func resolveServedRepository(path, basePath string, exportAll bool) (*GitRepository, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("'%s': request path must be absolute", path)
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if basePath != "" {
+		clean = filepath.Join(basePath, clean)
+	}
+
+	repo, err := EnterRepository(clean)
+	if err != nil {
+		return nil, err
+	}
+	if !exportAll && !pathExists(repo.fs, filepath.Join(repo.GitDir, DaemonExportFile)) {
+		return nil, fmt.Errorf("'%s': repository not exported", clean)
+	}
+	return repo, nil
+}
+
+// deadlineConn extends the connection deadline before every read and write so
+// only idle clients are timed out.
+type deadlineConn struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (d *deadlineConn) Read(p []byte) (int, error) {
+	_ = d.conn.SetDeadline(time.Now().Add(d.timeout))
+	return d.conn.Read(p)
+}
+
+func (d *deadlineConn) Write(p []byte) (int, error) {
+	_ = d.conn.SetDeadline(time.Now().Add(d.timeout))
+	return d.conn.Write(p)
+}
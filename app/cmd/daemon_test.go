@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// flakyListener fails to accept with each of errs in turn, then hands out
+// conn once and reports itself closed.
+type flakyListener struct {
+	errs []error
+	conn net.Conn
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		return nil, err
+	}
+	if conn := l.conn; conn != nil {
+		l.conn = nil
+		return conn, nil
+	}
+	return nil, net.ErrClosed
+}
+
+func (l *flakyListener) Close() error   { return nil }
+func (l *flakyListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+// TestServeDaemonRetriesAccept checks that failing Accepts, as when the
+// process runs out of file descriptors, do not stop the daemon, which
+// still serves the next client and only returns once the listener is
+// closed.
+func TestServeDaemonRetriesAccept(t *testing.T) {
+	server, client := net.Pipe()
+	listener := &flakyListener{
+		errs: []error{syscall.EMFILE, syscall.ECONNABORTED, syscall.EMFILE},
+		conn: server,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer client.Close()
+		// A request for a repository that does not exist gets an ERR line.
+		_ = transport.NewEncoder(client).Encodef("git-upload-pack /missing\x00")
+		var reply bytes.Buffer
+		_, _ = reply.ReadFrom(client)
+		if !strings.Contains(reply.String(), "ERR") {
+			t.Errorf("daemon replied %q, want an ERR line", reply.String())
+		}
+	}()
+
+	var logged bytes.Buffer
+	if err := serveDaemon(listener, DaemonOptions{BasePath: t.TempDir()}, log.New(&logged, "", 0)); err != nil {
+		t.Fatalf("serveDaemon returned %v after the listener was closed, want nil", err)
+	}
+	wg.Wait()
+	if got := strings.Count(logged.String(), "Accept error"); got != 3 {
+		t.Errorf("logged %d accept errors, want 3:\n%s", got, logged.String())
+	}
+}
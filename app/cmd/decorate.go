@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DecorationStyle selects how ref names are printed next to commits.
+type DecorationStyle string
+
+const (
+	DecorateNo    DecorationStyle = "no"
+	DecorateShort DecorationStyle = "short" // master, tag: v1.0, origin/master
+	DecorateFull  DecorationStyle = "full"  // refs/heads/master, tag: refs/tags/v1.0
+	DecorateAuto  DecorationStyle = "auto"  // short when writing to a terminal, otherwise no
+)
+
+// ParseDecorationStyle parses the value of --decorate or log.decorate.
+// Boolean config values are accepted too: true means short, false means no.
+func ParseDecorationStyle(value string) (DecorationStyle, error) {
+	switch strings.ToLower(value) {
+	case "", "short", "true", "yes", "on", "1":
+		return DecorateShort, nil
+	case "full":
+		return DecorateFull, nil
+	case "auto":
+		return DecorateAuto, nil
+	case "no", "false", "off", "0":
+		return DecorateNo, nil
+	}
+	return "", fmt.Errorf("invalid --decorate option: %s", value)
+}
+
+// Decorations maps commits to the refs that point at them.
+type Decorations struct {
+	byCommit map[string][]string
+	head     string // The branch HEAD points at, rendered as "HEAD -> branch".
+}
+
+// LoadDecorations reads every ref once and indexes the names by the commit
+// they point at. Annotated tags decorate the commit they peel to.
+//
+// Parameters:
+// - repo: The repository whose refs are loaded.
+// - style: DecorateShort or DecorateFull.
+//
+// Returns:
+// - The decorations of all commits.
+// - An error if the refs cannot be listed.
+func LoadDecorations(repo *GitRepository, style DecorationStyle) (*Decorations, error) {
+	d := &Decorations{byCommit: make(map[string][]string)}
+	objects := NewObjectManager(repo)
+
+	// Like git, names are prepended as the sorted refs are walked, so later
+	// namespaces (tags, then remotes, then branches) come first.
+	add := func(sha, name string) {
+		d.byCommit[sha] = append([]string{name}, d.byCommit[sha]...)
+	}
+	err := ForEachRef(repo, RefsDir+"/", func(ref Ref) error {
+		if strings.HasPrefix(ref.Name, "refs/notes/") {
+			return nil
+		}
+		name := decorationName(ref.Name, style)
+		if strings.HasPrefix(ref.Name, "refs/tags/") {
+			name = "tag: " + name
+			if peeled, _, err := objects.PeelObject(ref.SHA); err == nil && peeled != ref.SHA {
+				add(peeled, name)
+			}
+		}
+		add(ref.SHA, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if head, err := ResolveHEAD(repo); err == nil && !head.Unborn() {
+		if !head.Detached() {
+			d.head = decorationName(head.Ref, style)
+		}
+		add(head.SHA, HeadFile)
+	}
+	return d, nil
+}
+
+// decorationName shortens a ref name unless full names were requested.
+func decorationName(name string, style DecorationStyle) string {
+	if style == DecorateFull {
+		return name
+	}
+	for _, prefix := range []string{BranchesPrefix, "refs/remotes/", "refs/tags/"} {
+		if short, ok := strings.CutPrefix(name, prefix); ok {
+			return short
+		}
+	}
+	return name
+}
+
+// Names returns the decorations of a commit in display order, with HEAD
+// joined to the branch it points at, e.g. ["HEAD -> master", "tag: v1.0"].
+func (d *Decorations) Names(sha string) []string {
+	names := d.byCommit[sha]
+	if len(names) == 0 {
+		return nil
+	}
+
+	headJoined := d.head != "" && slices.Contains(names, HeadFile) && slices.Contains(names, d.head)
+	result := make([]string, 0, len(names))
+	if headJoined {
+		result = append(result, HeadFile+" -> "+d.head)
+	}
+	for _, name := range names {
+		if headJoined && (name == HeadFile || name == d.head) {
+			continue
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// Format renders the decorations of a commit as " (HEAD -> master, tag: v1.0)",
+// or "" when nothing points at it.
+func (d *Decorations) Format(sha string) string {
+	names := d.Names(sha)
+	if len(names) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(names, ", ") + ")"
+}
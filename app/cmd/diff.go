@@ -0,0 +1,529 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	// DefaultDiffContext is the number of unchanged lines shown around changes.
+	DefaultDiffContext = 3
+	// binaryCheckSize is how much of a file is searched for NUL bytes when
+	// deciding whether it is binary, as git does.
+	binaryCheckSize = 8000
+	// maxFuncnameLength caps the function context printed after "@@".
+	maxFuncnameLength = 80
+	// minDiffCost is the smallest number of edits searched for before the
+	// diff settles for a result that may not be minimal.
+	minDiffCost = 256
+)
+
+// DiffLine is one line of a hunk. Text keeps its trailing newline, so a
+// line without one is the last line of a file that does not end in "\n".
+type DiffLine struct {
+	Kind byte // ' ' for context, '-' for removed, '+' for added.
+	Text string
+}
+
+// Hunk is a block of changes with its surrounding context.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Funcname           string // The nearest preceding "function" line, as git shows it.
+	Lines              []DiffLine
+}
+
+// Header renders the "@@ -1,3 +1,4 @@" line of the hunk.
+func (h *Hunk) Header() string {
+	rangeOf := func(start, count int) string {
+		if count == 1 {
+			return fmt.Sprint(start)
+		}
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+	header := fmt.Sprintf("@@ -%s +%s @@", rangeOf(h.OldStart, h.OldLines), rangeOf(h.NewStart, h.NewLines))
+	if h.Funcname != "" {
+		header += " " + h.Funcname
+	}
+	return header
+}
+
+// FilePatch is the difference of a single file.
+type FilePatch struct {
+	TreeChange
+	Binary  bool
+	OldSize int // Size of the old content in bytes.
+	NewSize int // Size of the new content in bytes.
+	Hunks   []Hunk
+}
+
+// Stat returns the number of added and removed lines. For binary files it
+// returns the sizes of the new and old content instead, as git's diffstat does.
+func (p *FilePatch) Stat() (added, deleted int) {
+	if p.Binary {
+		return p.NewSize, p.OldSize
+	}
+	for _, hunk := range p.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case '+':
+				added++
+			case '-':
+				deleted++
+			}
+		}
+	}
+	return added, deleted
+}
+
+// IsBinary reports whether content looks binary to git: it has a NUL byte
+// near the start.
+func IsBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binaryCheckSize)], 0) >= 0
+}
+
+// splitLines splits content into lines that keep their newline.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is one step of an edit script: keep, delete or insert a line.
+type diffOp struct {
+	kind     byte // ' ', '-' or '+'
+	old, new int  // Line indexes in the old and new content.
+}
+
+// diffLines computes a shortest edit script from a to b with Myers'
+// algorithm, then slides the changes into the positions git would show.
+// Deletions are placed before insertions within a change.
+func diffLines(a, b []string) []diffOp {
+	// Changed lines are tracked per side with a sentinel on both ends, so
+	// the groups can be slid around like xdiff does before the script is built.
+	removed := make([]bool, len(a)+2)
+	added := make([]bool, len(b)+2)
+	myers(a, b, 0, len(a), 0, len(b), removed, added)
+	compactChanges(a, removed, added)
+	compactChanges(b, added, removed)
+
+	var ops []diffOp
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		if !removed[i+1] && !added[j+1] {
+			ops = append(ops, diffOp{' ', i, j})
+			i, j = i+1, j+1
+			continue
+		}
+		for ; removed[i+1]; i++ {
+			ops = append(ops, diffOp{'-', i, j})
+		}
+		for ; added[j+1]; j++ {
+			ops = append(ops, diffOp{'+', i, j})
+		}
+	}
+	return ops
+}
+
+// myers marks the lines of a[aLo:aHi] that are removed and of b[bLo:bHi]
+// that are added by a shortest edit script. It uses the linear space form
+// of the algorithm: find the middle snake of an optimal path, then recurse
+// on the parts before and after it.
+func myers(a, b []string, aLo, aHi, bLo, bHi int, removed, added []bool) {
+	for aLo < aHi && bLo < bHi && a[aLo] == b[bLo] {
+		aLo, bLo = aLo+1, bLo+1
+	}
+	for aLo < aHi && bLo < bHi && a[aHi-1] == b[bHi-1] {
+		aHi, bHi = aHi-1, bHi-1
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			added[j+1] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			removed[i+1] = true
+		}
+	default:
+		x, y, ok := middleSnake(a[aLo:aHi], b[bLo:bHi])
+		if !ok {
+			for i := aLo; i < aHi; i++ {
+				removed[i+1] = true
+			}
+			for j := bLo; j < bHi; j++ {
+				added[j+1] = true
+			}
+			return
+		}
+		myers(a, b, aLo, aLo+x, bLo, bLo+y, removed, added)
+		myers(a, b, aLo+x, aHi, bLo+y, bHi, removed, added)
+	}
+}
+
+// middleSnake searches forward from the start and backward from the end
+// until the two paths overlap, and returns a point on a shortest path
+// that splits it into two smaller problems.
+func middleSnake(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	odd := delta%2 != 0
+	costLimit := max(minDiffCost, int(math.Sqrt(float64(n+m))))
+	// Diagonals that ran off the grid are trimmed from later rounds.
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+
+	for d := 0; d <= maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -d || (k != d && forward[i-1] < forward[i+1]) {
+				fx = forward[i+1]
+			} else {
+				fx = forward[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && a[fx] == b[fy] {
+				fx, fy = fx+1, fy+1
+			}
+			forward[i] = fx
+
+			switch {
+			case fx > n:
+				fEnd += 2
+			case fy > m:
+				fStart += 2
+			case odd:
+				j := offset + delta - k
+				if j >= 0 && j < len(backward) && backward[j] != -1 && fx >= n-backward[j] {
+					return fx, fy, true
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -d || (k != d && backward[i-1] < backward[i+1]) {
+				bx = backward[i+1]
+			} else {
+				bx = backward[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && a[n-1-bx] == b[m-1-by] {
+				bx, by = bx+1, by+1
+			}
+			backward[i] = bx
+
+			switch {
+			case bx > n:
+				bEnd += 2
+			case by > m:
+				bStart += 2
+			case !odd:
+				j := offset + delta - k
+				if j >= 0 && j < len(forward) && forward[j] != -1 {
+					fx := forward[j]
+					if fx >= n-bx {
+						return fx, fx - (delta - k), true
+					}
+				}
+			}
+		}
+
+		// Past the cost limit the search gives up on a minimal result and
+		// splits at the forward path that got furthest, as xdiff does.
+		if d >= costLimit {
+			best, bestX, bestY := 0, 0, 0
+			for k := -d + fStart; k <= d-fEnd; k += 2 {
+				fx := forward[offset+k]
+				if fy := fx - k; fx <= n && fy >= 0 && fy <= m && fx+fy > best && fx+fy < n+m {
+					best, bestX, bestY = fx+fy, fx, fy
+				}
+			}
+			if best > 0 {
+				return bestX, bestY, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// changeGroup is a run [start, end) of changed lines in one file; an empty
+// group sits between two unchanged lines.
+type changeGroup struct {
+	start, end int
+}
+
+// compactChanges slides each group of changed lines of one file as far
+// down as it can go, unless it can line up with a change in the other
+// file, which is the placement git's xdiff settles on. changed and other
+// are indexed from 1, with false sentinels at both ends.
+func compactChanges(lines []string, changed, other []bool) {
+	n := len(lines)
+	first := func(flags []bool) changeGroup {
+		g := changeGroup{}
+		for flags[g.end+1] {
+			g.end++
+		}
+		return g
+	}
+	next := func(flags []bool, g *changeGroup) bool {
+		if g.end >= len(flags)-2 {
+			return false
+		}
+		g.start = g.end + 1
+		for g.end = g.start; flags[g.end+1]; g.end++ {
+		}
+		return true
+	}
+	previous := func(flags []bool, g *changeGroup) bool {
+		if g.start == 0 {
+			return false
+		}
+		g.end = g.start - 1
+		for g.start = g.end; flags[g.start]; g.start-- {
+		}
+		return true
+	}
+	slideUp := func(g *changeGroup) bool {
+		if g.start == 0 || lines[g.start-1] != lines[g.end-1] {
+			return false
+		}
+		g.start--
+		g.end--
+		changed[g.start+1], changed[g.end+1] = true, false
+		for changed[g.start] {
+			g.start--
+		}
+		return true
+	}
+	slideDown := func(g *changeGroup) bool {
+		if g.end >= n || lines[g.start] != lines[g.end] {
+			return false
+		}
+		changed[g.start+1], changed[g.end+1] = false, true
+		g.start++
+		g.end++
+		for changed[g.end+1] {
+			g.end++
+		}
+		return true
+	}
+
+	g, og := first(changed), first(other)
+	for {
+		if g.end != g.start {
+			var size, earliestEnd int
+			matchingOther := -1
+			for {
+				size = g.end - g.start
+				for slideUp(&g) {
+					previous(other, &og)
+				}
+				earliestEnd = g.end
+				if og.end > og.start {
+					matchingOther = g.end
+				}
+				for slideDown(&g) {
+					next(other, &og)
+					if og.end > og.start {
+						matchingOther = g.end
+					}
+				}
+				if size == g.end-g.start {
+					break
+				}
+			}
+
+			if g.end != earliestEnd && matchingOther != -1 {
+				for og.end == og.start {
+					slideUp(&g)
+					previous(other, &og)
+				}
+			}
+		}
+
+		if !next(changed, &g) {
+			return
+		}
+		next(other, &og)
+	}
+}
+
+// DiffContent compares two versions of a file line by line.
+//
+// Parameters:
+// - oldContent: The old version, nil for a file that did not exist.
+// - newContent: The new version, nil for a file that no longer exists.
+// - context: The number of unchanged lines kept around each change.
+//
+// Returns:
+// - The hunks of the difference, empty if the contents are equal.
+func DiffContent(oldContent, newContent []byte, context int) []Hunk {
+	a, b := splitLines(oldContent), splitLines(newContent)
+	ops := diffLines(a, b)
+
+	var hunks []Hunk
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the next change is close enough that the
+		// context of both would touch.
+		start := max(0, i-context)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = run
+		}
+
+		hunk := Hunk{OldStart: ops[start].old, NewStart: ops[start].new}
+		for _, op := range ops[start:end] {
+			switch op.kind {
+			case ' ':
+				hunk.Lines = append(hunk.Lines, DiffLine{' ', a[op.old]})
+				hunk.OldLines++
+				hunk.NewLines++
+			case '-':
+				hunk.Lines = append(hunk.Lines, DiffLine{'-', a[op.old]})
+				hunk.OldLines++
+			case '+':
+				hunk.Lines = append(hunk.Lines, DiffLine{'+', b[op.new]})
+				hunk.NewLines++
+			}
+		}
+		hunk.Funcname = funcname(a, hunk.OldStart)
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+	return hunks
+}
+
+// funcname finds the function context of a hunk starting at line index
+// start: the closest earlier line that begins with a letter, "_" or "$",
+// which is git's default rule.
+func funcname(lines []string, start int) string {
+	for i := start - 1; i >= 0; i-- {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		c := line[0]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == '$' {
+			line = strings.TrimRight(line, " \t\r\n")
+			if len(line) > maxFuncnameLength {
+				line = line[:maxFuncnameLength]
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+// NewFilePatch builds the patch of a change from the old and new contents.
+func NewFilePatch(change TreeChange, oldContent, newContent []byte, context int) *FilePatch {
+	patch := &FilePatch{TreeChange: change, OldSize: len(oldContent), NewSize: len(newContent)}
+	if IsBinary(oldContent) || IsBinary(newContent) {
+		patch.Binary = !bytes.Equal(oldContent, newContent)
+		return patch
+	}
+	patch.Hunks = DiffContent(oldContent, newContent, context)
+	return patch
+}
+
+// WritePatch writes a patch in git's unified format, starting with the
+// "diff --git" header. File names are quoted as QuotePath does.
+func WritePatch(w io.Writer, patch *FilePatch, quotePath bool) error {
+	var buf bytes.Buffer
+	oldName, newName := QuotePath("a/"+patch.SourcePath(), quotePath), QuotePath("b/"+patch.Path, quotePath)
+	fmt.Fprintf(&buf, "diff --git %s %s\n", oldName, newName)
+	if patch.Status == StatusRenamed {
+		fmt.Fprintf(&buf, "similarity index %d%%\n", patch.Score)
+		fmt.Fprintf(&buf, "rename from %s\nrename to %s\n", QuotePath(patch.OldPath, quotePath), QuotePath(patch.Path, quotePath))
+	}
+
+	oldSHA, newSHA := abbrevBlob(patch.Old.SHA), abbrevBlob(patch.New.SHA)
+	switch patch.Status {
+	case StatusAdded:
+		fmt.Fprintf(&buf, "new file mode %s\n", patch.New.Mode)
+		fmt.Fprintf(&buf, "index %s..%s\n", oldSHA, newSHA)
+		oldName = "/dev/null"
+	case StatusDeleted:
+		fmt.Fprintf(&buf, "deleted file mode %s\n", patch.Old.Mode)
+		fmt.Fprintf(&buf, "index %s..%s\n", oldSHA, newSHA)
+		newName = "/dev/null"
+	default:
+		if patch.Old.Mode != patch.New.Mode {
+			fmt.Fprintf(&buf, "old mode %s\nnew mode %s\n", patch.Old.Mode, patch.New.Mode)
+			if patch.Old.SHA != patch.New.SHA {
+				fmt.Fprintf(&buf, "index %s..%s\n", oldSHA, newSHA)
+			}
+		} else if patch.Old.SHA != patch.New.SHA || patch.Status != StatusRenamed {
+			fmt.Fprintf(&buf, "index %s..%s %s\n", oldSHA, newSHA, patch.New.Mode)
+		}
+	}
+
+	if patch.Binary {
+		fmt.Fprintf(&buf, "Binary files %s and %s differ\n", oldName, newName)
+	} else if len(patch.Hunks) > 0 {
+		fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+		for _, hunk := range patch.Hunks {
+			buf.WriteString(hunk.Header() + "\n")
+			for _, line := range hunk.Lines {
+				buf.WriteByte(line.Kind)
+				buf.WriteString(line.Text)
+				if !strings.HasSuffix(line.Text, "\n") {
+					buf.WriteString("\n\\ No newline at end of file\n")
+				}
+			}
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// abbrevBlob shortens an object name for an "index" line; a missing side
+// is shown as zeros.
+func abbrevBlob(sha string) string {
+	if sha == "" {
+		return strings.Repeat("0", 7)
+	}
+	return sha[:7]
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultStatWidth is the total width of --stat output, as git uses when
+// not writing to a terminal.
+const DefaultStatWidth = 80
+
+// FileStat is the per-file summary of a patch.
+type FileStat struct {
+	Path    string
+	Added   int // Added lines, or the new size in bytes for binary files.
+	Deleted int // Removed lines, or the old size in bytes for binary files.
+	Binary  bool
+}
+
+// DiffStats summarizes patches for --stat, --numstat and --shortstat. The
+// two halves of a type change are counted as a single file.
+func DiffStats(patches []*FilePatch) []FileStat {
+	stats := make([]FileStat, 0, len(patches))
+	for i, patch := range patches {
+		added, deleted := patch.Stat()
+		if i > 0 && patch.Status == StatusAdded && patches[i-1].Status == StatusDeleted && patches[i-1].Path == patch.Path {
+			last := &stats[len(stats)-1]
+			last.Added += added
+			last.Deleted += deleted
+			last.Binary = last.Binary || patch.Binary
+			continue
+		}
+		path := patch.Path
+		if patch.Status == StatusRenamed {
+			path = RenameDisplayName(patch.OldPath, patch.Path)
+		}
+		stats = append(stats, FileStat{Path: path, Added: added, Deleted: deleted, Binary: patch.Binary})
+	}
+	return stats
+}
+
+// NumstatPrefix renders the "added<TAB>deleted<TAB>" that precedes the path
+// of a --numstat line; binary files show "-" for both counts.
+func NumstatPrefix(stat FileStat) string {
+	if stat.Binary {
+		return "-\t-\t"
+	}
+	return fmt.Sprintf("%d\t%d\t", stat.Added, stat.Deleted)
+}
+
+// FormatStatSummary renders the " 2 files changed, 3 insertions(+), 1
+// deletion(-)" line that ends --stat and makes up --shortstat.
+func FormatStatSummary(stats []FileStat) string {
+	if len(stats) == 0 {
+		return " 0 files changed"
+	}
+
+	insertions, deletions := 0, 0
+	for _, stat := range stats {
+		if !stat.Binary {
+			insertions += stat.Added
+			deletions += stat.Deleted
+		}
+	}
+
+	plural := func(n int, word string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, word)
+		}
+		return fmt.Sprintf("%d %ss", n, word)
+	}
+	summary := " " + plural(len(stats), "file") + " changed"
+	if insertions > 0 || deletions == 0 {
+		summary += ", " + plural(insertions, "insertion") + "(+)"
+	}
+	if deletions > 0 || insertions == 0 {
+		summary += ", " + plural(deletions, "deletion") + "(-)"
+	}
+	return summary
+}
+
+// FormatStat renders git's --stat output: one line per file with the
+// number of changed lines and a histogram scaled to fit width, followed by
+// the summary line. Long names are shortened from the left.
+//
+// Parameters:
+// - stats: The per-file statistics.
+// - width: The total width available, e.g. DefaultStatWidth.
+// - quotePath: Whether non-ASCII names are quoted, see QuotePath.
+//
+// Returns:
+// - The lines of output, without newlines.
+func FormatStat(stats []FileStat, width int, quotePath bool) []string {
+	names := make([]string, len(stats))
+	maxLen, maxChange, numberWidth, binWidth := 0, 0, 0, 0
+	for i, stat := range stats {
+		names[i] = QuotePath(stat.Path, quotePath)
+		maxLen = max(maxLen, utf8.RuneCountInString(names[i]))
+		if stat.Binary {
+			// "Bin XXX -> YYY bytes"
+			binWidth = max(binWidth, 14+len(strconv.Itoa(stat.Added))+len(strconv.Itoa(stat.Deleted)))
+			numberWidth = 3
+			continue
+		}
+		maxChange = max(maxChange, stat.Added+stat.Deleted)
+	}
+	numberWidth = max(numberWidth, len(strconv.Itoa(maxChange)))
+
+	// The graph gets at least 3/8 and the name 5/8 of 16 columns.
+	width = max(width, 16+6+numberWidth)
+
+	graphWidth := maxChange
+	if maxChange+4 <= binWidth {
+		graphWidth = binWidth - 4
+	}
+	nameWidth := maxLen
+
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if graphWidth > width*3/8-numberWidth-6 {
+			graphWidth = max(width*3/8-numberWidth-6, 6)
+		}
+		if nameWidth > width-numberWidth-6-graphWidth {
+			nameWidth = width - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	lines := make([]string, 0, len(stats)+1)
+	for i, stat := range stats {
+		name, prefix := names[i], ""
+		if runes := []rune(name); len(runes) > nameWidth {
+			prefix = "..."
+			keep := max(nameWidth-3, 0)
+			name = string(runes[len(runes)-keep:])
+			if slash := strings.IndexByte(name, '/'); slash >= 0 {
+				name = name[slash:]
+			}
+		}
+		padding := strings.Repeat(" ", max(nameWidth-len(prefix)-utf8.RuneCountInString(name), 0))
+		line := fmt.Sprintf(" %s%s%s |", prefix, name, padding)
+
+		if stat.Binary {
+			line += fmt.Sprintf(" %*s", numberWidth, "Bin")
+			if stat.Added != 0 || stat.Deleted != 0 {
+				line += fmt.Sprintf(" %d -> %d bytes", stat.Deleted, stat.Added)
+			}
+			lines = append(lines, line)
+			continue
+		}
+
+		added, deleted := stat.Added, stat.Deleted
+		if graphWidth <= maxChange {
+			total := scaleLinear(added+deleted, graphWidth, maxChange)
+			if total < 2 && added > 0 && deleted > 0 {
+				total = 2
+			}
+			if added < deleted {
+				added = scaleLinear(added, graphWidth, maxChange)
+				deleted = total - added
+			} else {
+				deleted = scaleLinear(deleted, graphWidth, maxChange)
+				added = total - deleted
+			}
+		}
+
+		line += fmt.Sprintf(" %*d", numberWidth, stat.Added+stat.Deleted)
+		if stat.Added+stat.Deleted > 0 {
+			line += " " + strings.Repeat("+", added) + strings.Repeat("-", deleted)
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, FormatStatSummary(stats))
+}
+
+// scaleLinear scales a change count to the graph width; any change gets
+// at least one column.
+func scaleLinear(n, width, maxChange int) int {
+	if n == 0 {
+		return 0
+	}
+	return 1 + n*(width-1)/maxChange
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Programs used when nothing configures another.
+const (
+	DefaultEditor = "vi"
+	DefaultPager  = "less"
+)
+
+// Editor returns the command used to edit messages: GIT_EDITOR, then
+// core.editor, then VISUAL on a capable terminal, then EDITOR.
+func Editor(repo *GitRepository) string {
+	if editor := os.Getenv("GIT_EDITOR"); editor != "" {
+		return editor
+	}
+	if editor := lookupConfig(repo, "core.editor"); editor != "" {
+		return editor
+	}
+	if visual := os.Getenv("VISUAL"); visual != "" && os.Getenv("TERM") != "dumb" {
+		return visual
+	}
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
+	}
+	return DefaultEditor
+}
+
+// Pager returns the command long output is piped through: GIT_PAGER, then
+// core.pager, then PAGER. "cat" means no pager.
+func Pager(repo *GitRepository) string {
+	if pager, ok := os.LookupEnv("GIT_PAGER"); ok {
+		return pager
+	}
+	if pager := lookupConfig(repo, "core.pager"); pager != "" {
+		return pager
+	}
+	if pager, ok := os.LookupEnv("PAGER"); ok {
+		return pager
+	}
+	return DefaultPager
+}
+
+// CommitEditMsgFile holds a commit message while it is being edited.
+const CommitEditMsgFile = "COMMIT_EDITMSG"
+
+// EditMessage lets the user edit a message in their editor, as git commit
+// does. The message is written to COMMIT_EDITMSG followed by the help text
+// as comments, and the editor runs on that file attached to the terminal.
+//
+// Parameters:
+// - repo: The repository.
+// - message: The message to start from.
+// - help: Explains what to do, e.g. that an empty message aborts; "%s"
+// in it stands for the comment character.
+//
+// Returns:
+// - The edited message, without comment lines and cleaned up with Stripspace.
+// - An error if the editor fails or core.commentChar is invalid.
+func EditMessage(repo *GitRepository, message []byte, help string) ([]byte, error) {
+	commentChar, err := CommentChar(repo)
+	if err != nil {
+		return nil, err
+	}
+	file := createRepoPath(repo, CommitEditMsgFile)
+	content := append(append([]byte{}, message...), '\n')
+	content = append(content, CommentLines([]byte(fmt.Sprintf(help, commentChar)), commentChar)...)
+	if err := os.WriteFile(file, content, 0644); err != nil {
+		return nil, err
+	}
+
+	if err := runEditor(repo, file); err != nil {
+		return nil, err
+	}
+
+	edited, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Stripspace(edited, commentChar), nil
+}
+
+// runEditor runs the editor on a file, attached to the terminal, and waits
+// for it to exit.
+func runEditor(repo *GitRepository, file string) error {
+	editor := Editor(repo)
+	run := exec.Command("sh", "-c", editor+` "$@"`, editor, file)
+	run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := run.Run(); err != nil {
+		return fmt.Errorf("there was a problem with the editor '%s'", editor)
+	}
+	return nil
+}
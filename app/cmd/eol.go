@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+)
+
+// crlfAction is what happens to the line endings of a file, decided from its
+// text and eol attributes and core.autocrlf, as in git's convert.c.
+type crlfAction int
+
+const (
+	crlfBinary    crlfAction = iota // Never converted.
+	crlfText                        // Text; worktree endings from core.eol or core.autocrlf.
+	crlfTextInput                   // Text; LF in the worktree.
+	crlfTextCRLF                    // Text; CRLF in the worktree.
+	crlfAuto                        // Converted if it looks like text; endings as crlfText.
+	crlfAutoInput                   // Converted if it looks like text; LF in the worktree.
+	crlfAutoCRLF                    // Converted if it looks like text; CRLF in the worktree.
+)
+
+// EOLConverter converts line endings between the worktree and the object
+// database following core.autocrlf, core.eol and the text and eol
+// attributes. Files stored in the object database always use LF.
+type EOLConverter struct {
+	repo     *GitRepository
+	attrs    *AttributeMatcher
+	autocrlf string // "true", "input" or "false".
+	eol      string // core.eol: "lf", "crlf" or "native".
+	safecrlf string // core.safecrlf: "true", "warn" or "false".
+	index    map[string]string
+
+	// Warnings receives the core.safecrlf warnings about conversions that do
+	// not round-trip. When nil, no such checks are made, as for status.
+	Warnings io.Writer
+	// Strict makes core.safecrlf=true fail such conversions instead of
+	// warning. git is only strict when adding files, not when diffing them.
+	Strict bool
+}
+
+// NewEOLConverter creates a converter for the worktree of repo.
+func NewEOLConverter(repo *GitRepository) *EOLConverter {
+	config := func(key, fallback string) string {
+		if !repo.Config().IsSet(key) {
+			return fallback
+		}
+		value := strings.ToLower(repo.Config().GetString(key))
+		switch value {
+		case "yes", "on", "1":
+			return "true"
+		case "no", "off", "0", "":
+			return "false"
+		}
+		return value
+	}
+	return &EOLConverter{
+		repo:     repo,
+		attrs:    NewAttributeMatcher(repo),
+		autocrlf: config("core.autocrlf", "false"),
+		eol:      config("core.eol", "native"),
+		safecrlf: config("core.safecrlf", "warn"),
+	}
+}
+
+// action decides the conversion of a path.
+func (c *EOLConverter) action(name string) crlfAction {
+	attrs := c.attrs.Attributes(name)
+	text, ok := attrs["text"]
+	if !ok {
+		// The crlf attribute is the old name of text.
+		text, ok = attrs["crlf"]
+	}
+
+	action, undefined := crlfBinary, false
+	switch {
+	case !ok:
+		undefined = true
+	case text == AttrSet:
+		action = crlfText
+	case text == AttrUnset:
+		return crlfBinary
+	case text == "auto":
+		action = crlfAuto
+	case text == "input":
+		action = crlfTextInput
+	default:
+		undefined = true
+	}
+
+	switch eol := attrs["eol"]; {
+	case eol == "lf" && action == crlfAuto:
+		action = crlfAutoInput
+	case eol == "crlf" && action == crlfAuto:
+		action = crlfAutoCRLF
+	case eol == "lf":
+		return crlfTextInput
+	case eol == "crlf":
+		return crlfTextCRLF
+	}
+
+	if undefined {
+		switch c.autocrlf {
+		case "true":
+			return crlfAutoCRLF
+		case "input":
+			return crlfAutoInput
+		}
+		return crlfBinary
+	}
+	return action
+}
+
+// worktreeCRLF reports whether text files without an eol attribute get CRLF
+// endings in the worktree.
+func (c *EOLConverter) worktreeCRLF() bool {
+	switch c.autocrlf {
+	case "true":
+		return true
+	case "input":
+		return false
+	}
+	return c.eol == "crlf" || (c.eol == "native" && runtime.GOOS == "windows")
+}
+
+// outputCRLF reports whether an action writes CRLF endings to the worktree.
+func (c *EOLConverter) outputCRLF(action crlfAction) bool {
+	switch action {
+	case crlfTextCRLF, crlfAutoCRLF:
+		return true
+	case crlfText, crlfAuto:
+		return c.worktreeCRLF()
+	}
+	return false
+}
+
+// isAuto reports whether an action only applies to content that looks like text.
+func (action crlfAction) isAuto() bool {
+	return action == crlfAuto || action == crlfAutoInput || action == crlfAutoCRLF
+}
+
+// eolStats counts the line endings and kinds of bytes in content.
+type eolStats struct {
+	lonecr, lonelf, crlf         int
+	nul, printable, nonprintable int
+}
+
+// gatherEOLStats counts the line endings of data as git does.
+func gatherEOLStats(data []byte) eolStats {
+	var stats eolStats
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '\r':
+			if i+1 < len(data) && data[i+1] == '\n' {
+				stats.crlf++
+				i++
+			} else {
+				stats.lonecr++
+			}
+		case c == '\n':
+			stats.lonelf++
+		case c == 0x7f:
+			stats.nonprintable++
+		case c == 0:
+			stats.nul++
+			stats.nonprintable++
+		case c < 0x20:
+			if c == '\b' || c == '\t' || c == 0x1b || c == '\f' {
+				stats.printable++
+			} else {
+				stats.nonprintable++
+			}
+		default:
+			stats.printable++
+		}
+	}
+	// A trailing ^Z, the DOS end of file marker, does not make a file binary.
+	if len(data) > 0 && data[len(data)-1] == 0x1a {
+		stats.nonprintable--
+	}
+	return stats
+}
+
+// binary reports whether content with these statistics is treated as binary.
+func (s eolStats) binary() bool {
+	return s.lonecr > 0 || s.nul > 0 || (s.printable>>7) < s.nonprintable
+}
+
+// addsCRLF reports whether checking out content with these statistics would
+// turn its LF endings into CRLF.
+func (c *EOLConverter) addsCRLF(stats eolStats, action crlfAction) bool {
+	if !c.outputCRLF(action) || stats.lonelf == 0 {
+		return false
+	}
+	if action.isAuto() && (stats.lonecr > 0 || stats.crlf > 0 || stats.binary()) {
+		return false
+	}
+	return true
+}
+
+// ToGit converts worktree content of a path to what is stored in the object
+// database: CRLF becomes LF for text files. Files that only look like text
+// under text=auto or core.autocrlf are left alone when they are binary or
+// when the version in the index already has CRLF endings.
+//
+// Parameters:
+// - name: The path, relative to the top of the worktree.
+// - data: The worktree content.
+//
+// Returns:
+// - The content to hash or store.
+// - An error if Strict and core.safecrlf is true and the conversion would not round-trip.
+func (c *EOLConverter) ToGit(name string, data []byte) ([]byte, error) {
+	action := c.action(name)
+	if action == crlfBinary || len(data) == 0 {
+		return data, nil
+	}
+
+	stats := gatherEOLStats(data)
+	convert := stats.crlf > 0
+	if action.isAuto() {
+		if stats.binary() {
+			return data, nil
+		}
+		if convert && c.crlfInIndex(name) {
+			convert = false
+		}
+	}
+
+	if c.Warnings != nil && c.safecrlf != "false" {
+		// Simulate adding and then checking out the file.
+		after := stats
+		if convert {
+			after.lonelf += after.crlf
+			after.crlf = 0
+		}
+		if c.addsCRLF(after, action) {
+			after.crlf += after.lonelf
+			after.lonelf = 0
+		}
+		if err := c.checkRoundTrip(name, stats, after); err != nil {
+			return nil, err
+		}
+	}
+
+	if !convert {
+		return data, nil
+	}
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), nil
+}
+
+// checkRoundTrip warns about, or with core.safecrlf=true rejects, line
+// endings that would differ after the file is added and checked out again.
+func (c *EOLConverter) checkRoundTrip(name string, before, after eolStats) error {
+	var lost, gained string
+	switch {
+	case before.crlf > 0 && after.crlf == 0:
+		lost, gained = "CRLF", "LF"
+	case before.lonelf > 0 && after.lonelf == 0:
+		lost, gained = "LF", "CRLF"
+	default:
+		return nil
+	}
+
+	if c.safecrlf == "true" && c.Strict {
+		return fmt.Errorf("%s would be replaced by %s in %s", lost, gained, name)
+	}
+	fmt.Fprintf(c.Warnings, "warning: in the working copy of '%s', %s will be replaced by %s the next time Git touches it\n",
+		name, lost, gained)
+	return nil
+}
+
+// crlfInIndex reports whether the index version of a path is text with CRLF
+// endings, which automatic conversion must then leave alone.
+func (c *EOLConverter) crlfInIndex(name string) bool {
+	if c.index == nil {
+		c.index = make(map[string]string)
+		if index, err := ReadIndex(c.repo); err == nil {
+			for _, entry := range index.Entries {
+				if entry.Stage() == 0 {
+					c.index[entry.Name] = entry.SHA
+				}
+			}
+		}
+	}
+
+	sha, ok := c.index[name]
+	if !ok {
+		return false
+	}
+	objType, data, err := NewObjectManager(c.repo).ReadObject(sha)
+	if err != nil || objType != BlobType || !bytes.Contains(data, []byte("\r")) {
+		return false
+	}
+	stats := gatherEOLStats(data)
+	return !stats.binary() && stats.crlf > 0
+}
+
+// ToWorktree converts content from the object database for writing a path
+// to the worktree: LF becomes CRLF for text files that are checked out
+// with CRLF endings.
+//
+// Parameters:
+// - name: The path, relative to the top of the worktree.
+// - data: The blob content.
+//
+// Returns:
+// - The content to write.
+func (c *EOLConverter) ToWorktree(name string, data []byte) []byte {
+	action := c.action(name)
+	if action == crlfBinary || len(data) == 0 {
+		return data
+	}
+	if !c.addsCRLF(gatherEOLStats(data), action) {
+		return data
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(data) + bytes.Count(data, []byte("\n")))
+	for i, b := range data {
+		if b == '\n' && (i == 0 || data[i-1] != '\r') {
+			out.WriteByte('\r')
+		}
+		out.WriteByte(b)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var expiryUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// ParseExpiry converts an expiry such as "now", "never", "2.weeks.ago",
+// "3 days ago", an ISO date or "@<unix seconds>" into a cut-off time.
+// Anything older than the cut-off has expired. "never" returns the zero time,
+// before which nothing exists.
+//
+// Parameters:
+// - value: The expiry to parse.
+// - now: The reference time for relative expiries.
+//
+// Returns:
+// - The cut-off time.
+// - An error if the value is not understood.
+func ParseExpiry(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	switch value {
+	case "now", "all":
+		return now, nil
+	case "never", "false":
+		return time.Time{}, nil
+	}
+
+	if seconds, ok := strings.CutPrefix(value, "@"); ok {
+		timestamp, err := strconv.ParseInt(seconds, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("malformed expiration date '%s'", value)
+		}
+		return time.Unix(timestamp, 0), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == '.' || r == ' ' })
+	if len(fields) == 3 && fields[2] == "ago" {
+		count, err := strconv.Atoi(fields[0])
+		unit, ok := expiryUnits[strings.TrimSuffix(fields[1], "s")]
+		if err == nil && ok {
+			return now.Add(-time.Duration(count) * unit), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("malformed expiration date '%s'", value)
+}
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Config sections that configure external tools, as in
+// [difftool "meld"] cmd = meld "$LOCAL" "$REMOTE".
+const (
+	DiffToolSection  = "difftool"
+	MergeToolSection = "mergetool"
+)
+
+// ExternalTool is an external diff or merge program configured with
+// difftool.<name>.cmd or mergetool.<name>.cmd.
+type ExternalTool struct {
+	Name          string
+	Cmd           string // A shell command using $LOCAL, $REMOTE, $MERGED and $BASE.
+	TrustExitCode bool   // Whether a non-zero exit status means the tool failed.
+	Prompt        bool   // Whether to ask before launching the tool for each file.
+	KeepBackup    bool   // For a merge, whether to keep the conflicted file as <path>.orig.
+}
+
+// DiffTool looks up the tool difftool runs: name, or diff.tool, or like git
+// merge.tool. Its command is difftool.<name>.cmd, falling back to
+// mergetool.<name>.cmd so that one tool can serve both.
+//
+// Parameters:
+// - repo: The repository, or nil to read only the global config.
+// - name: The tool asked for with --tool, or "" for the configured one.
+//
+// Returns:
+// - The tool.
+// - An error if no tool is configured or it has no command.
+func DiffTool(repo *GitRepository, name string) (*ExternalTool, error) {
+	if name == "" {
+		name = lookupConfig(repo, "diff.tool")
+	}
+	if name == "" {
+		name = lookupConfig(repo, "merge.tool")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no diff tool configured; set diff.tool or use --tool")
+	}
+
+	for _, section := range []string{DiffToolSection, MergeToolSection} {
+		if command := lookupConfig(repo, configKey(section, name, "cmd")); command != "" {
+			return &ExternalTool{
+				Name:          name,
+				Cmd:           command,
+				TrustExitCode: toolConfigBool(repo, "difftool.trustexitcode", false),
+				Prompt:        toolConfigBool(repo, "difftool.prompt", true),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown diff tool '%s': set difftool.%s.cmd", name, name)
+}
+
+// MergeTool looks up the tool mergetool runs: name, or merge.tool, with the
+// command mergetool.<name>.cmd. Unless mergetool.<name>.trustExitCode is
+// set, whether a merge succeeded is judged by whether the tool changed the
+// merged file. mergetool.keepBackup, on by default, keeps the conflicted
+// file as <path>.orig.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The tool asked for with --tool, or "" for the configured one.
+//
+// Returns:
+// - The tool.
+// - An error if no tool is configured or it has no command.
+func MergeTool(repo *GitRepository, name string) (*ExternalTool, error) {
+	if name == "" {
+		name = lookupConfig(repo, "merge.tool")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no merge tool configured; set merge.tool or use --tool")
+	}
+
+	command := lookupConfig(repo, configKey(MergeToolSection, name, "cmd"))
+	if command == "" {
+		return nil, fmt.Errorf("unknown merge tool '%s': set mergetool.%s.cmd", name, name)
+	}
+	return &ExternalTool{
+		Name:          name,
+		Cmd:           command,
+		TrustExitCode: toolConfigBool(repo, configKey(MergeToolSection, name, "trustexitcode"), false),
+		Prompt:        toolConfigBool(repo, "mergetool.prompt", true),
+		KeepBackup:    toolConfigBool(repo, "mergetool.keepbackup", true),
+	}, nil
+}
+
+// toolConfigBool reads a boolean tool setting, or returns fallback when it
+// is unset or not a boolean.
+func toolConfigBool(repo *GitRepository, key string, fallback bool) bool {
+	value, err := strconv.ParseBool(lookupConfig(repo, key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// ToolRun describes one invocation of an external tool.
+type ToolRun struct {
+	Local  string // The old side, or ours in a merge.
+	Remote string // The new side, or theirs in a merge.
+	Merged string // The worktree path the files stand for; the merge result.
+	Base   string // The common ancestor in a merge; Merged for a diff.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Run runs the tool's command with sh from the top of the worktree, with
+// the files of run in $LOCAL, $REMOTE, $MERGED and $BASE.
+//
+// Returns:
+// - An error if the tool could not be started, or exited with a non-zero
+// status while TrustExitCode is set.
+func (t *ExternalTool) Run(repo *GitRepository, run ToolRun) error {
+	tool := exec.Command("sh", "-c", t.Cmd)
+	tool.Dir = repo.WorkTree
+	tool.Env = append(os.Environ(),
+		"LOCAL="+run.Local,
+		"REMOTE="+run.Remote,
+		"MERGED="+run.Merged,
+		"BASE="+run.Base,
+	)
+	tool.Stdin, tool.Stdout, tool.Stderr = run.Stdin, run.Stdout, run.Stderr
+
+	err := tool.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && !t.TrustExitCode {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("external tool '%s' failed: %w", t.Name, err)
+	}
+	return nil
+}
+
+// ExtractToolFile writes the content of a blob, or the "Subproject commit"
+// line of a gitlink, to file for an external tool, creating its directory.
+func (m *ObjectManager) ExtractToolFile(entry TreeEntry, file string) error {
+	data, err := m.diffContent("", entry, "", nil)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	perm := os.FileMode(0600)
+	if entry.Mode == ModeExecutable {
+		perm = 0700
+	}
+	return os.WriteFile(file, data, perm)
+}
+
+// DirDiff is the pair of trees a tool compares in difftool --dir-diff.
+type DirDiff struct {
+	Left     string // Holds the old side of every change.
+	Right    string // Holds the new side of every change.
+	worktree string
+	copies   map[string][]byte // Worktree files copied to Right, with their content.
+}
+
+// NewDirDiff writes both sides of changes into two trees below dir, left
+// and right, leaving out the missing side of added and deleted files.
+// Content on the worktree side is copied from the worktree as is, so that
+// edits made to it in the tool can be copied back.
+//
+// Parameters:
+// - changes: The changes, e.g. from DiffTrees.
+// - worktree: When not empty, the new side of changes is this worktree.
+// - dir: An empty directory for the two trees.
+//
+// Returns:
+// - The trees.
+// - An error if some content cannot be read or written.
+func (m *ObjectManager) NewDirDiff(changes []TreeChange, worktree, dir string) (*DirDiff, error) {
+	d := &DirDiff{
+		Left:     filepath.Join(dir, "left"),
+		Right:    filepath.Join(dir, "right"),
+		worktree: worktree,
+		copies:   make(map[string][]byte),
+	}
+	for _, tree := range []string{d.Left, d.Right} {
+		if err := os.MkdirAll(tree, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, change := range changes {
+		name := filepath.FromSlash(change.Path)
+		if change.Old.SHA != "" {
+			if err := m.ExtractToolFile(change.Old, filepath.Join(d.Left, name)); err != nil {
+				return nil, err
+			}
+		}
+		if change.New.SHA == "" {
+			continue
+		}
+		if worktree == "" || change.New.IsGitlink() {
+			if err := m.ExtractToolFile(change.New, filepath.Join(d.Right, name)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Symlinks are compared by their target and not copied back.
+		data, err := readWorktreeFile(filepath.Join(worktree, name), change.New.Mode)
+		if err != nil {
+			return nil, err
+		}
+		file := filepath.Join(d.Right, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			return nil, err
+		}
+		if change.New.Mode != ModeSymlink {
+			d.copies[change.Path] = data
+		}
+	}
+	return d, nil
+}
+
+// CopyBack copies the files of the right tree that were copied from the
+// worktree and have since been edited back to the worktree.
+//
+// Returns:
+// - The paths copied back.
+// - An error if a file cannot be read or written.
+func (d *DirDiff) CopyBack() ([]string, error) {
+	var copied []string
+	for path, original := range d.copies {
+		name := filepath.FromSlash(path)
+		data, err := os.ReadFile(filepath.Join(d.Right, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return copied, err
+		}
+		if bytes.Equal(data, original) {
+			continue
+		}
+		file := filepath.Join(d.worktree, name)
+		info, err := os.Stat(file)
+		if err != nil {
+			return copied, err
+		}
+		if err := os.WriteFile(file, data, info.Mode().Perm()); err != nil {
+			return copied, err
+		}
+		copied = append(copied, path)
+	}
+	sort.Strings(copied)
+	return copied, nil
+}
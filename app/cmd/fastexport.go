@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fastExporter writes history in the git fast-import stream format.
+type fastExporter struct {
+	objects *ObjectManager
+	out     *bufio.Writer
+	marks   map[string]int // Object SHA-1 to mark number.
+	next    int
+}
+
+// FastExport writes the history reachable from the given refs to w as a
+// fast-import stream: blobs and commits in parent-first order, followed by
+// the annotated tags and resets needed to recreate every ref.
+//
+// Parameters:
+// - repo: The repository to export from.
+// - refNames: The full names of the refs to export.
+// - w: Where the stream is written.
+//
+// Returns:
+// - An error if an object cannot be read or the stream cannot be written.
+func FastExport(repo *GitRepository, refNames []string, w io.Writer) error {
+	exporter := &fastExporter{
+		objects: NewObjectManager(repo),
+		out:     bufio.NewWriter(w),
+		marks:   make(map[string]int),
+		next:    1,
+	}
+
+	type exportRef struct {
+		name   string
+		commit string
+		tag    *Kvlm // Set for annotated tags.
+	}
+
+	var refs []exportRef
+	for _, name := range refNames {
+		sha, err := ResolveRef(repo, name)
+		if err != nil {
+			return err
+		}
+
+		ref := exportRef{name: name, commit: sha}
+		objType, data, err := exporter.objects.ReadObject(sha)
+		if err != nil {
+			return err
+		}
+		if objType == TagType {
+			if ref.tag, err = ParseKvlm(data); err != nil {
+				return err
+			}
+			ref.commit = string(ref.tag.Get("object"))
+			if string(ref.tag.Get("type")) != string(CommitType) {
+				continue // Tags of trees and blobs cannot be expressed in the stream.
+			}
+		} else if objType != CommitType {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+
+	// Every commit is written under the first ref that reaches it.
+	labels := make(map[string]string)
+	commits := make(map[string]*Commit)
+	for _, ref := range refs {
+		label := ref.name
+		if ref.tag != nil {
+			label = "refs/tags/" + string(ref.tag.Get("tag"))
+		}
+
+		pending := []string{ref.commit}
+		for len(pending) > 0 {
+			sha := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			if _, seen := commits[sha]; seen {
+				continue
+			}
+
+			commit, err := exporter.objects.ReadCommit(sha)
+			if err != nil {
+				return err
+			}
+			commits[sha] = commit
+			labels[sha] = label
+			pending = append(pending, commit.Parents...)
+		}
+	}
+
+	for _, commit := range topoSortCommits(commits) {
+		if err := exporter.writeCommit(commit, labels[commit.SHA]); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range refs {
+		if ref.tag != nil {
+			exporter.writeTag(ref.tag)
+			continue
+		}
+		if labels[ref.commit] != ref.name {
+			fmt.Fprintf(exporter.out, "reset %s\nfrom :%d\n\n", ref.name, exporter.marks[ref.commit])
+		}
+	}
+
+	return exporter.out.Flush()
+}
+
+// topoSortCommits orders commits so that parents always come before their
+// children, breaking ties by commit time.
+func topoSortCommits(commits map[string]*Commit) []*Commit {
+	children := make(map[string][]string)
+	waiting := make(map[string]int)
+	for sha, commit := range commits {
+		for _, parent := range commit.Parents {
+			if _, ok := commits[parent]; ok {
+				children[parent] = append(children[parent], sha)
+				waiting[sha]++
+			}
+		}
+	}
+
+	var ready []*Commit
+	for sha, commit := range commits {
+		if waiting[sha] == 0 {
+			ready = append(ready, commit)
+		}
+	}
+
+	var sorted []*Commit
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			if ready[i].CommitTime() != ready[j].CommitTime() {
+				return ready[i].CommitTime() < ready[j].CommitTime()
+			}
+			return ready[i].SHA < ready[j].SHA
+		})
+
+		commit := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, commit)
+
+		for _, child := range children[commit.SHA] {
+			waiting[child]--
+			if waiting[child] == 0 {
+				ready = append(ready, commits[child])
+			}
+		}
+	}
+	return sorted
+}
+
+func (e *fastExporter) mark(sha string) int {
+	mark := e.next
+	e.marks[sha] = mark
+	e.next++
+	return mark
+}
+
+func (e *fastExporter) writeData(data []byte) {
+	fmt.Fprintf(e.out, "data %d\n", len(data))
+	e.out.Write(data)
+	e.out.WriteByte('\n')
+}
+
+// writeCommit writes the blobs introduced by a commit, then the commit itself
+// with its changes relative to the first parent.
+func (e *fastExporter) writeCommit(commit *Commit, ref string) error {
+	files, err := e.objects.FlattenTree(commit.Tree)
+	if err != nil {
+		return err
+	}
+
+	parentFiles := map[string]TreeEntry{}
+	if len(commit.Parents) > 0 {
+		parent, err := e.objects.ReadCommit(commit.Parents[0])
+		if err != nil {
+			return err
+		}
+		if parentFiles, err = e.objects.FlattenTree(parent.Tree); err != nil {
+			return err
+		}
+	}
+
+	var changed, deleted []string
+	for path, entry := range files {
+		if old, ok := parentFiles[path]; !ok || old != entry {
+			changed = append(changed, path)
+		}
+	}
+	for path := range parentFiles {
+		if _, ok := files[path]; !ok {
+			deleted = append(deleted, path)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(deleted)
+
+	for _, path := range changed {
+		entry := files[path]
+		if entry.IsGitlink() || e.marks[entry.SHA] != 0 {
+			continue
+		}
+		_, data, err := e.objects.ReadObject(entry.SHA)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "blob\nmark :%d\n", e.mark(entry.SHA))
+		e.writeData(data)
+	}
+
+	fmt.Fprintf(e.out, "commit %s\nmark :%d\n", ref, e.mark(commit.SHA))
+	if commit.Author != "" {
+		fmt.Fprintf(e.out, "author %s\n", commit.Author)
+	}
+	fmt.Fprintf(e.out, "committer %s\n", commit.Committer)
+	if encoding := commit.Kvlm.Get("encoding"); encoding != nil {
+		fmt.Fprintf(e.out, "encoding %s\n", encoding)
+	}
+	e.writeData(commit.Message)
+
+	for i, parent := range commit.Parents {
+		command := "merge"
+		if i == 0 {
+			command = "from"
+		}
+		fmt.Fprintf(e.out, "%s :%d\n", command, e.marks[parent])
+	}
+
+	for _, path := range deleted {
+		fmt.Fprintf(e.out, "D %s\n", quoteFastImportPath(path))
+	}
+	for _, path := range changed {
+		entry := files[path]
+		dataRef := entry.SHA
+		if !entry.IsGitlink() {
+			dataRef = ":" + strconv.Itoa(e.marks[entry.SHA])
+		}
+		fmt.Fprintf(e.out, "M %s %s %s\n", entry.Mode, dataRef, quoteFastImportPath(path))
+	}
+	e.out.WriteByte('\n')
+	return nil
+}
+
+// writeTag writes an annotated tag pointing at an already exported commit.
+func (e *fastExporter) writeTag(tag *Kvlm) {
+	fmt.Fprintf(e.out, "tag %s\nfrom :%d\n", tag.Get("tag"), e.marks[string(tag.Get("object"))])
+	if tagger := tag.Get("tagger"); tagger != nil {
+		fmt.Fprintf(e.out, "tagger %s\n", tagger)
+	}
+	e.writeData(tag.Message)
+}
+
+// quoteFastImportPath quotes a path in C style when it contains characters
+// that would be ambiguous in a fast-import stream.
+func quoteFastImportPath(path string) string {
+	if !strings.ContainsAny(path, "\"\\\n") && !strings.HasPrefix(path, " ") {
+		return path
+	}
+	return strconv.Quote(path)
+}
@@ -0,0 +1,669 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FastImportOptions controls how FastImport updates refs.
+type FastImportOptions struct {
+	Force       bool   // Allow refs to be updated to commits that do not contain their old value.
+	ExportMarks string // Write the mark table to this file when done.
+}
+
+// FastImportStats counts what a fast-import stream created.
+type FastImportStats struct {
+	Blobs   int
+	Commits int
+	Tags    int
+	Refs    int
+}
+
+// fastImporter holds the state of a fast-import stream being consumed.
+type fastImporter struct {
+	repo     *GitRepository
+	objects  *ObjectManager
+	in       *bufio.Reader
+	progress io.Writer
+	marks    map[int]string    // Mark number to object SHA-1.
+	branches map[string]string // Ref name to the tip created by this stream.
+	stats    FastImportStats
+	line     string // The current command line, already read.
+	eof      bool
+}
+
+// FastImport reads a fast-import stream from r, writing the objects it
+// describes and updating the refs it names. Progress commands are echoed to
+// progress.
+//
+// Parameters:
+// - repo: The repository to import into.
+// - r: The fast-import stream.
+// - progress: Where progress messages are written.
+// - opts: Ref update and marks settings.
+//
+// Returns:
+// - Counts of the objects and refs created.
+// - An error if the stream is malformed or a ref update is refused.
+func FastImport(repo *GitRepository, r io.Reader, progress io.Writer, opts FastImportOptions) (*FastImportStats, error) {
+	importer := &fastImporter{
+		repo:     repo,
+		objects:  NewObjectManager(repo),
+		in:       bufio.NewReader(r),
+		progress: progress,
+		marks:    make(map[int]string),
+		branches: make(map[string]string),
+	}
+
+	if err := importer.run(); err != nil {
+		return nil, err
+	}
+
+	if opts.ExportMarks != "" {
+		if err := importer.exportMarks(opts.ExportMarks); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := importer.updateRefs(opts.Force); err != nil {
+		return &importer.stats, err
+	}
+	return &importer.stats, nil
+}
+
+// readLine reads the next line without its newline into f.line.
+func (f *fastImporter) readLine() error {
+	line, err := f.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		f.eof = true
+		f.line = ""
+		return nil
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	f.line = strings.TrimSuffix(line, "\n")
+	return nil
+}
+
+// run dispatches every command of the stream.
+func (f *fastImporter) run() error {
+	if err := f.readLine(); err != nil {
+		return err
+	}
+
+	for !f.eof {
+		line := f.line
+		var err error
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			err = f.readLine()
+		case line == "blob":
+			err = f.parseBlob()
+		case strings.HasPrefix(line, "commit "):
+			err = f.parseCommit(strings.TrimPrefix(line, "commit "))
+		case strings.HasPrefix(line, "tag "):
+			err = f.parseTag(strings.TrimPrefix(line, "tag "))
+		case strings.HasPrefix(line, "reset "):
+			err = f.parseReset(strings.TrimPrefix(line, "reset "))
+		case strings.HasPrefix(line, "progress "):
+			fmt.Fprintln(f.progress, line)
+			err = f.readLine()
+		case line == "checkpoint", strings.HasPrefix(line, "feature "), strings.HasPrefix(line, "option "):
+			err = f.readLine()
+		case line == "done":
+			return nil
+		default:
+			return fmt.Errorf("unsupported command: %s", line)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseMark consumes an optional "mark :<n>" line.
+func (f *fastImporter) parseMark() (int, error) {
+	value, ok := strings.CutPrefix(f.line, "mark :")
+	if !ok {
+		return 0, nil
+	}
+
+	mark, err := strconv.Atoi(value)
+	if err != nil || mark <= 0 {
+		return 0, fmt.Errorf("invalid mark: %s", f.line)
+	}
+	return mark, f.readLine()
+}
+
+// skipOriginalOID consumes an optional "original-oid" line.
+func (f *fastImporter) skipOriginalOID() error {
+	if strings.HasPrefix(f.line, "original-oid ") {
+		return f.readLine()
+	}
+	return nil
+}
+
+// parseData reads a "data" command in either its counted or delimited form.
+func (f *fastImporter) parseData() ([]byte, error) {
+	value, ok := strings.CutPrefix(f.line, "data ")
+	if !ok {
+		return nil, fmt.Errorf("expected data command, got: %s", f.line)
+	}
+
+	var data []byte
+	if delimiter, delimited := strings.CutPrefix(value, "<<"); delimited {
+		var buf bytes.Buffer
+		for {
+			line, err := f.in.ReadString('\n')
+			if err != nil {
+				return nil, fmt.Errorf("unterminated delimited data")
+			}
+			if strings.TrimSuffix(line, "\n") == delimiter {
+				break
+			}
+			buf.WriteString(line)
+		}
+		data = buf.Bytes()
+	} else {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid data length: %s", f.line)
+		}
+		data = make([]byte, size)
+		if _, err := io.ReadFull(f.in, data); err != nil {
+			return nil, fmt.Errorf("truncated data: %w", err)
+		}
+	}
+
+	if err := f.readLine(); err != nil {
+		return nil, err
+	}
+	// An optional empty line may follow the data.
+	if f.line == "" && !f.eof {
+		if err := f.readLine(); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (f *fastImporter) setMark(mark int, sha string) {
+	if mark > 0 {
+		f.marks[mark] = sha
+	}
+}
+
+func (f *fastImporter) parseBlob() error {
+	if err := f.readLine(); err != nil {
+		return err
+	}
+	mark, err := f.parseMark()
+	if err != nil {
+		return err
+	}
+	if err := f.skipOriginalOID(); err != nil {
+		return err
+	}
+
+	data, err := f.parseData()
+	if err != nil {
+		return err
+	}
+
+	sha, err := f.objects.WriteObject(BlobType, data, true)
+	if err != nil {
+		return err
+	}
+	f.setMark(mark, sha)
+	f.stats.Blobs++
+	return nil
+}
+
+// resolveCommitish resolves a mark, SHA-1 or ref name used by from/merge.
+func (f *fastImporter) resolveCommitish(value string) (string, error) {
+	if markValue, ok := strings.CutPrefix(value, ":"); ok {
+		mark, err := strconv.Atoi(markValue)
+		sha, found := f.marks[mark]
+		if err != nil || !found {
+			return "", fmt.Errorf("unknown mark: %s", value)
+		}
+		return sha, nil
+	}
+
+	if isValidSHA(value) {
+		return value, nil
+	}
+	if sha, ok := f.branches[value]; ok {
+		return sha, nil
+	}
+	return ResolveRef(f.repo, value)
+}
+
+func (f *fastImporter) parseCommit(ref string) error {
+	if err := f.readLine(); err != nil {
+		return err
+	}
+	mark, err := f.parseMark()
+	if err != nil {
+		return err
+	}
+	if err := f.skipOriginalOID(); err != nil {
+		return err
+	}
+
+	kvlm := &Kvlm{}
+	var author, committer, encoding string
+	if value, ok := strings.CutPrefix(f.line, "author "); ok {
+		author = value
+		if err := f.readLine(); err != nil {
+			return err
+		}
+	}
+	committer, ok := strings.CutPrefix(f.line, "committer ")
+	if !ok {
+		return fmt.Errorf("expected committer in commit %s, got: %s", ref, f.line)
+	}
+	if author == "" {
+		author = committer
+	}
+	if err := f.readLine(); err != nil {
+		return err
+	}
+	if value, ok := strings.CutPrefix(f.line, "encoding "); ok {
+		encoding = value
+		if err := f.readLine(); err != nil {
+			return err
+		}
+	}
+
+	message, err := f.parseData()
+	if err != nil {
+		return err
+	}
+
+	var parents []string
+	if value, ok := strings.CutPrefix(f.line, "from "); ok {
+		parent, err := f.resolveCommitish(value)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, parent)
+		if err := f.readLine(); err != nil {
+			return err
+		}
+	} else if tip, err := f.resolveCommitish(ref); err == nil {
+		parents = append(parents, tip)
+	}
+
+	for strings.HasPrefix(f.line, "merge ") {
+		parent, err := f.resolveCommitish(strings.TrimPrefix(f.line, "merge "))
+		if err != nil {
+			return err
+		}
+		parents = append(parents, parent)
+		if err := f.readLine(); err != nil {
+			return err
+		}
+	}
+
+	files := map[string]TreeEntry{}
+	if len(parents) > 0 {
+		parent, err := f.objects.ReadCommit(parents[0])
+		if err != nil {
+			return err
+		}
+		if files, err = f.objects.FlattenTree(parent.Tree); err != nil {
+			return err
+		}
+	}
+
+	if err := f.applyFileCommands(files); err != nil {
+		return fmt.Errorf("commit %s: %w", ref, err)
+	}
+
+	tree, err := f.objects.WriteTreeFromFiles(files)
+	if err != nil {
+		return err
+	}
+
+	kvlm.Add("tree", []byte(tree))
+	for _, parent := range parents {
+		kvlm.Add("parent", []byte(parent))
+	}
+	kvlm.Add("author", []byte(author))
+	kvlm.Add("committer", []byte(committer))
+	if encoding != "" {
+		kvlm.Add("encoding", []byte(encoding))
+	}
+	kvlm.Message = message
+
+	sha, err := f.objects.WriteObject(CommitType, kvlm.Serialize(), true)
+	if err != nil {
+		return err
+	}
+	f.setMark(mark, sha)
+	f.branches[ref] = sha
+	f.stats.Commits++
+	return nil
+}
+
+// applyFileCommands applies M, D, C, R and deleteall commands to files until
+// the end of the commit.
+func (f *fastImporter) applyFileCommands(files map[string]TreeEntry) error {
+	for !f.eof {
+		line := f.line
+		switch {
+		case line == "":
+			return f.readLine()
+
+		case line == "deleteall":
+			for path := range files {
+				delete(files, path)
+			}
+
+		case strings.HasPrefix(line, "M "):
+			if err := f.fileModify(files, strings.TrimPrefix(line, "M ")); err != nil {
+				return err
+			}
+			// fileModify may have consumed inline data and the following line.
+			continue
+
+		case strings.HasPrefix(line, "D "):
+			path, _, err := parseFastImportPath(strings.TrimPrefix(line, "D "), false)
+			if err != nil {
+				return err
+			}
+			removePathPrefix(files, path)
+
+		case strings.HasPrefix(line, "C "), strings.HasPrefix(line, "R "):
+			source, rest, err := parseFastImportPath(line[2:], true)
+			if err != nil {
+				return err
+			}
+			target, _, err := parseFastImportPath(rest, false)
+			if err != nil {
+				return err
+			}
+			copyPathPrefix(files, source, target, line[0] == 'R')
+
+		default:
+			// Not a file command: the commit ends here.
+			return nil
+		}
+
+		if err := f.readLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileModify handles "M <mode> <dataref> <path>".
+func (f *fastImporter) fileModify(files map[string]TreeEntry, args string) error {
+	fields := strings.SplitN(args, " ", 3)
+	if len(fields) != 3 {
+		return fmt.Errorf("malformed filemodify: M %s", args)
+	}
+
+	mode, err := normalizeFastImportMode(fields[0])
+	if err != nil {
+		return err
+	}
+	path, _, err := parseFastImportPath(fields[2], false)
+	if err != nil {
+		return err
+	}
+
+	var sha string
+	switch dataRef := fields[1]; {
+	case dataRef == "inline":
+		if err := f.readLine(); err != nil {
+			return err
+		}
+		data, err := f.parseData()
+		if err != nil {
+			return err
+		}
+		if sha, err = f.objects.WriteObject(BlobType, data, true); err != nil {
+			return err
+		}
+		f.stats.Blobs++
+		f.insertFile(files, path, mode, sha)
+		return nil
+	default:
+		if sha, err = f.resolveCommitish(dataRef); err != nil {
+			return err
+		}
+	}
+
+	if mode == ModeTree {
+		sub, err := f.objects.FlattenTree(sha)
+		if err != nil {
+			return err
+		}
+		removePathPrefix(files, path)
+		for subPath, entry := range sub {
+			files[path+"/"+subPath] = entry
+		}
+	} else {
+		f.insertFile(files, path, mode, sha)
+	}
+	return f.readLine()
+}
+
+func (f *fastImporter) insertFile(files map[string]TreeEntry, path, mode, sha string) {
+	removePathPrefix(files, path)
+	files[path] = TreeEntry{Mode: mode, SHA: sha}
+}
+
+func (f *fastImporter) parseTag(name string) error {
+	if err := f.readLine(); err != nil {
+		return err
+	}
+	mark, err := f.parseMark()
+	if err != nil {
+		return err
+	}
+
+	value, ok := strings.CutPrefix(f.line, "from ")
+	if !ok {
+		return fmt.Errorf("expected from in tag %s, got: %s", name, f.line)
+	}
+	target, err := f.resolveCommitish(value)
+	if err != nil {
+		return err
+	}
+	targetType, _, err := f.objects.StatObject(target)
+	if err != nil {
+		return err
+	}
+	if err := f.readLine(); err != nil {
+		return err
+	}
+	if err := f.skipOriginalOID(); err != nil {
+		return err
+	}
+
+	kvlm := &Kvlm{}
+	kvlm.Add("object", []byte(target))
+	kvlm.Add("type", []byte(targetType))
+	kvlm.Add("tag", []byte(name))
+	if tagger, ok := strings.CutPrefix(f.line, "tagger "); ok {
+		kvlm.Add("tagger", []byte(tagger))
+		if err := f.readLine(); err != nil {
+			return err
+		}
+	}
+
+	if kvlm.Message, err = f.parseData(); err != nil {
+		return err
+	}
+
+	sha, err := f.objects.WriteObject(TagType, kvlm.Serialize(), true)
+	if err != nil {
+		return err
+	}
+	f.setMark(mark, sha)
+	f.branches["refs/tags/"+name] = sha
+	f.stats.Tags++
+	return nil
+}
+
+func (f *fastImporter) parseReset(ref string) error {
+	if err := f.readLine(); err != nil {
+		return err
+	}
+
+	value, ok := strings.CutPrefix(f.line, "from ")
+	if !ok {
+		delete(f.branches, ref)
+		return nil
+	}
+
+	sha, err := f.resolveCommitish(value)
+	if err != nil {
+		return err
+	}
+	f.branches[ref] = sha
+	return f.readLine()
+}
+
+// updateRefs points every ref touched by the stream at its new tip. Unless
+// force is set, a ref that already exists is only moved forward.
+func (f *fastImporter) updateRefs(force bool) error {
+	names := make([]string, 0, len(f.branches))
+	for name := range f.branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var refused []string
+	for _, name := range names {
+		sha := f.branches[name]
+		if old, err := ResolveRef(f.repo, name); err == nil && old != sha && !force {
+			contained, err := f.objects.IsAncestor(old, sha)
+			if err != nil || !contained {
+				refused = append(refused, fmt.Sprintf("%s (new tip %s does not contain %s)", name, sha, old))
+				continue
+			}
+		}
+
+		if err := UpdateRef(f.repo, name, sha, "fast-import"); err != nil {
+			return err
+		}
+		f.stats.Refs++
+	}
+
+	if len(refused) > 0 {
+		return fmt.Errorf("not updating refs:\n  %s", strings.Join(refused, "\n  "))
+	}
+	return nil
+}
+
+// exportMarks writes the mark table as ":<mark> <sha>" lines.
+func (f *fastImporter) exportMarks(path string) error {
+	marks := make([]int, 0, len(f.marks))
+	for mark := range f.marks {
+		marks = append(marks, mark)
+	}
+	sort.Ints(marks)
+
+	var buf bytes.Buffer
+	for _, mark := range marks {
+		fmt.Fprintf(&buf, ":%d %s\n", mark, f.marks[mark])
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// normalizeFastImportMode converts the modes accepted by fast-import into
+// the modes stored in trees.
+func normalizeFastImportMode(mode string) (string, error) {
+	switch mode {
+	case "644", "100644":
+		return ModeBlob, nil
+	case "755", "100755":
+		return ModeExecutable, nil
+	case "120000":
+		return ModeSymlink, nil
+	case "160000":
+		return ModeGitlink, nil
+	case "040000", "40000":
+		return ModeTree, nil
+	}
+	return "", fmt.Errorf("invalid file mode '%s'", mode)
+}
+
+// parseFastImportPath reads a path that is either C-quoted or, when
+// spaceTerminated is set, ends at the first space. Otherwise the path runs to
+// the end of the line.
+//
+// Returns:
+// - The unquoted path.
+// - The text following the path and its separator.
+// - An error if a quoted path is malformed.
+func parseFastImportPath(value string, spaceTerminated bool) (string, string, error) {
+	if strings.HasPrefix(value, "\"") {
+		end := 1
+		for end < len(value) && value[end] != '"' {
+			if value[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(value) {
+			return "", "", fmt.Errorf("unterminated quoted path: %s", value)
+		}
+
+		path, err := strconv.Unquote(value[:end+1])
+		if err != nil {
+			return "", "", fmt.Errorf("malformed quoted path: %s", value)
+		}
+		return path, strings.TrimPrefix(value[end+1:], " "), nil
+	}
+
+	if spaceTerminated {
+		path, rest, _ := strings.Cut(value, " ")
+		return path, rest, nil
+	}
+	return value, "", nil
+}
+
+// removePathPrefix deletes path and everything below it.
+func removePathPrefix(files map[string]TreeEntry, path string) {
+	delete(files, path)
+	for existing := range files {
+		if strings.HasPrefix(existing, path+"/") {
+			delete(files, existing)
+		}
+	}
+}
+
+// copyPathPrefix copies (or renames) source and everything below it to target.
+func copyPathPrefix(files map[string]TreeEntry, source, target string, rename bool) {
+	copied := make(map[string]TreeEntry)
+	for path, entry := range files {
+		if path == source {
+			copied[target] = entry
+		} else if rest, ok := strings.CutPrefix(path, source+"/"); ok {
+			copied[target+"/"+rest] = entry
+		}
+	}
+
+	if rename {
+		removePathPrefix(files, source)
+	}
+	removePathPrefix(files, target)
+	for path, entry := range copied {
+		files[path] = entry
+	}
+}
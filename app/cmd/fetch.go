@@ -0,0 +1,666 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	neturl "net/url"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+const (
+	// FetchHeadFile records what the last fetch brought in, for merge and pull.
+	FetchHeadFile = "FETCH_HEAD"
+	// haveBatchSize is how many "have" lines are sent per negotiation round.
+	haveBatchSize = 32
+	// maxHaves bounds the negotiation so a fetch into an unrelated
+	// repository does not walk its entire history.
+	maxHaves = 256
+)
+
+// Ref update outcomes reported by Fetch, matching git's fetch output.
+const (
+	FetchUpToDate     = "up to date"
+	FetchNewBranch    = "new branch"
+	FetchNewTag       = "new tag"
+	FetchNewRef       = "new ref"
+	FetchFastForward  = "fast-forward"
+	FetchForced       = "forced update"
+	FetchNonFF        = "non-fast-forward"
+	FetchTagClobbered = "would clobber existing tag"
+	FetchHeadOnly     = "fetch head"
+)
+
+// FetchOptions controls Fetch.
+type FetchOptions struct {
+	Refspecs   []string // Refspecs to fetch instead of the remote's configured ones.
+	Tags       bool     // Fetch every tag in addition to the refspecs.
+	NoTags     bool     // Do not follow tags pointing into the fetched history.
+	Force      bool     // Allow non fast-forward updates for every refspec.
+	UploadPack string   // Program to run on the remote instead of git-upload-pack.
+	Filter     string   // Partial clone filter spec, e.g. "blob:none"; see ObjectFilter.
+	DryRun     bool     // Report how refs would be updated, but update neither them nor FETCH_HEAD.
+	// NoReflog updates refs without logging them, as clone does: git packs
+	// the refs a clone fetches, and writes no reflog for them.
+	NoReflog bool
+}
+
+// FetchedRef describes what happened to one ref during a fetch.
+type FetchedRef struct {
+	Remote string // The ref on the remote.
+	Local  string // The local ref updated, empty if only FETCH_HEAD records it.
+	Old    string // The previous value of the local ref, zeroSHA if it was new.
+	New    string // The fetched object.
+	Status string // One of the Fetch* outcomes.
+}
+
+// Rejected reports whether the local ref was left untouched because the
+// update was not allowed.
+func (f FetchedRef) Rejected() bool {
+	return f.Status == FetchNonFF || f.Status == FetchTagClobbered
+}
+
+// FetchResult summarizes a call to Fetch.
+type FetchResult struct {
+	URL     string       // Where the objects came from.
+	Head    string       // The ref the remote HEAD points at, if the remote said.
+	Refs    []FetchedRef // Every ref considered, in refspec order.
+	Objects int          // Number of objects received.
+}
+
+// fetchTarget is a remote ref selected by the refspecs.
+type fetchTarget struct {
+	mapping  RefMapping
+	sha      string
+	forMerge bool
+}
+
+// Fetch downloads objects and refs from a remote repository. Refs are mapped
+// through the refspecs given in opts or, for a configured remote, through
+// remote.<name>.fetch, and every fetched ref is recorded in FETCH_HEAD. A
+// dry run still downloads the objects, as git's does, but leaves the refs
+// and FETCH_HEAD alone.
+//
+// Parameters:
+// - repo: The repository to fetch into.
+// - remote: A configured remote name, or a URL or path.
+// - opts: Refspecs and update policy.
+//
+// Returns:
+// - What was fetched and how each ref was updated.
+// - An error if the remote cannot be reached or a refspec matches nothing.
+func Fetch(repo *GitRepository, remote string, opts FetchOptions) (*FetchResult, error) {
+	defer trace.Start(trace.Perf, "fetch", "remote", remote)()
+
+	url := ResolveRemoteURL(repo, remote)
+	configured := RemoteConfigured(repo, remote)
+
+	refspecs, err := fetchRefspecs(repo, remote, configured, opts)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := fetchFilter(repo, remote, configured, opts)
+	if err != nil {
+		return nil, err
+	}
+	promisor := configured && remote == PromisorRemote(repo)
+	if filter != nil && !promisor {
+		if err := recordPromisorRemote(repo, remote, filter); err != nil {
+			return nil, err
+		}
+		promisor = true
+	}
+
+	uploadPack := opts.UploadPack
+	if uploadPack == "" && configured {
+		uploadPack = remoteUploadPack(repo, remote)
+	}
+
+	session, err := dialRemote(repo, url, "git-upload-pack", uploadPack)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	objects := NewObjectManager(repo)
+	targets, err := selectFetchTargets(repo, remote, configured, refspecs, session.Advertisement, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var wants []string
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if !seen[target.sha] && !objects.Has(target.sha) {
+			wants = append(wants, target.sha)
+		}
+		seen[target.sha] = true
+	}
+
+	if filter != nil && !session.Advertisement.Capabilities.Has(transport.CapFilter) {
+		trace.Log(trace.Pack, "filtering not recognized by server, ignoring", "filter", filter.String())
+		filter = nil
+	}
+
+	result := &FetchResult{URL: fetchDisplayURL(url), Head: advertisedHead(session.Advertisement)}
+	if len(wants) > 0 {
+		haves, err := negotiationHaves(repo, objects)
+		if err != nil {
+			return nil, err
+		}
+		pack, err := fetchPack(session, wants, haves, filter)
+		if err != nil {
+			return nil, err
+		}
+		if promisor {
+			result.Objects, err = storePromisorPack(repo, pack)
+		} else {
+			var unpacked *UnpackResult
+			if unpacked, err = StorePack(repo, pack, UnpackOptions{Strict: FsckObjects(repo, "fetch")}); err == nil {
+				result.Objects = unpacked.Unpacked
+			}
+		}
+		objects.reloadPacks()
+		if err != nil {
+			return nil, fmt.Errorf("received a corrupt pack: %w", err)
+		}
+	} else if !session.Stateless {
+		_ = transport.NewEncoder(session).Flush()
+	}
+
+	if !opts.NoTags && !opts.Tags {
+		targets = append(targets, followedTags(repo, objects, targets, session.Advertisement)...)
+	}
+
+	var fetchHead bytes.Buffer
+	for _, target := range targets {
+		fetched := FetchedRef{Remote: target.mapping.Src, Local: target.mapping.Dst, Old: zeroSHA, New: target.sha}
+		if fetched.Local == "" {
+			fetched.Status = FetchHeadOnly
+		} else {
+			fetched.Status, err = updateFetchedRef(repo, objects, &fetched, target.mapping.Force || opts.Force, opts.DryRun, fetchReflog(remote, opts.NoReflog))
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.Refs = append(result.Refs, fetched)
+		writeFetchHeadLine(&fetchHead, target, result.URL)
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if err := writeFileAtomic(repo.fs, createRepoPath(repo, FetchHeadFile), fetchHead.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// fetchRefspecs returns the refspecs a fetch applies: those given on the
+// command line, else the configured ones of a named remote, else HEAD.
+func fetchRefspecs(repo *GitRepository, remote string, configured bool, opts FetchOptions) ([]*Refspec, error) {
+	var refspecs []*Refspec
+	var err error
+
+	switch {
+	case len(opts.Refspecs) > 0:
+		refspecs, err = ParseRefspecs(opts.Refspecs)
+	case configured:
+		refspecs, err = RemoteFetchRefspecs(repo, remote)
+	default:
+		refspecs, err = ParseRefspecs([]string{HeadFile})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Tags {
+		refspecs = append(refspecs, &Refspec{Src: "refs/tags/*", Dst: "refs/tags/*"})
+	}
+	return refspecs, nil
+}
+
+// fetchFilter returns the partial clone filter of a fetch: the one given in
+// opts, else for the promisor remote its remote.<name>.partialCloneFilter.
+// A repository has a single promisor remote, so a filter can only be given
+// for that remote once there is one.
+func fetchFilter(repo *GitRepository, remote string, configured bool, opts FetchOptions) (*ObjectFilter, error) {
+	promisor := PromisorRemote(repo)
+	if opts.Filter == "" {
+		if configured && remote == promisor {
+			return promisorFilter(repo, remote)
+		}
+		return nil, nil
+	}
+
+	if !configured {
+		return nil, fmt.Errorf("--filter can only be used with a configured remote")
+	}
+	if promisor != "" && promisor != remote {
+		return nil, fmt.Errorf("--filter can only be used with the remote configured in extensions.partialclone")
+	}
+	return ParseObjectFilter(opts.Filter)
+}
+
+// selectFetchTargets maps the advertised refs through the refspecs.
+// Non-pattern sources may be abbreviated, e.g. "main" for refs/heads/main,
+// and must exist on the remote.
+func selectFetchTargets(repo *GitRepository, remote string, configured bool, refspecs []*Refspec, advertisement *transport.Advertisement, opts FetchOptions) ([]fetchTarget, error) {
+	advertised := make(map[string]string)
+	var names []string
+	for _, ref := range advertisement.Refs {
+		if strings.HasSuffix(ref.Name, "^{}") {
+			continue
+		}
+		advertised[ref.Name] = ref.SHA
+		names = append(names, ref.Name)
+	}
+
+	expanded := make([]*Refspec, 0, len(refspecs))
+	for _, refspec := range refspecs {
+		if refspec.IsPattern() || refspec.Negative {
+			expanded = append(expanded, refspec)
+			continue
+		}
+
+		name, ok := expandAdvertisedRef(refspec.Src, advertised)
+		if !ok {
+			return nil, fmt.Errorf("couldn't find remote ref %s", refspec.Src)
+		}
+		copied := *refspec
+		copied.Src = name
+		expanded = append(expanded, &copied)
+	}
+
+	merge := ""
+	if branch, ok := CurrentBranch(repo); ok && configured {
+		if repo.Config().GetString(configKey("branch", branch, "remote")) == remote {
+			merge = repo.Config().GetString(configKey("branch", branch, "merge"))
+		}
+	}
+
+	var targets []fetchTarget
+	for _, mapping := range MapRefs(expanded, names) {
+		forMerge := mapping.Src == merge
+		if len(opts.Refspecs) > 0 {
+			forMerge = !isPatternMapping(expanded, mapping)
+		}
+		targets = append(targets, fetchTarget{mapping: mapping, sha: advertised[mapping.Src], forMerge: forMerge})
+	}
+	return targets, nil
+}
+
+// advertisedHead returns the ref the remote HEAD points at, as its symref
+// capability says, or "" when it does not say.
+func advertisedHead(advertisement *transport.Advertisement) string {
+	for _, symref := range advertisement.Capabilities.Values(transport.CapSymref) {
+		if target, ok := strings.CutPrefix(symref, HeadFile+":"); ok {
+			return target
+		}
+	}
+	return ""
+}
+
+// isPatternMapping reports whether a mapping was produced by a pattern refspec.
+func isPatternMapping(refspecs []*Refspec, mapping RefMapping) bool {
+	for _, refspec := range refspecs {
+		if refspec.Negative || !refspec.IsPattern() {
+			continue
+		}
+		if dst, ok := refspec.MatchSource(mapping.Src); ok && dst == mapping.Dst {
+			return true
+		}
+	}
+	return false
+}
+
+// expandAdvertisedRef finds the advertised ref an abbreviated name refers to,
+// trying the same prefixes as ExpandRefName.
+func expandAdvertisedRef(name string, advertised map[string]string) (string, bool) {
+	candidates := []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name, "refs/remotes/" + name + "/HEAD"}
+	for _, candidate := range candidates {
+		if _, ok := advertised[candidate]; ok {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// followedTags returns the advertised tags missing locally whose tag object
+// and target are both present once the pack has been received, which is how
+// git follows tags into fetched history by default. Annotated tags arrive
+// thanks to the include-tag capability.
+func followedTags(repo *GitRepository, objects *ObjectManager, targets []fetchTarget, advertisement *transport.Advertisement) []fetchTarget {
+	taken := make(map[string]bool)
+	for _, target := range targets {
+		taken[target.mapping.Dst] = true
+	}
+
+	var tags []fetchTarget
+	for _, ref := range advertisement.Refs {
+		if !strings.HasPrefix(ref.Name, "refs/tags/") || strings.HasSuffix(ref.Name, "^{}") || taken[ref.Name] || refExists(repo, ref.Name) {
+			continue
+		}
+		if _, _, err := objects.PeelObject(ref.SHA); err != nil {
+			continue
+		}
+		tags = append(tags, fetchTarget{mapping: RefMapping{Src: ref.Name, Dst: ref.Name}, sha: ref.SHA})
+	}
+	return tags
+}
+
+// fetchPack negotiates with upload-pack and returns the pack it sends. Haves
+// are offered in batches, until the server acknowledges a common commit or
+// they run out. Over stateless HTTP every round repeats the wants.
+// multi_ack_detailed is used when the server offers it, as git's does,
+// since its replies end every round with a NAK.
+//
+// Parameters:
+// - session: The open upload-pack session.
+// - wants: The objects to ask for.
+// - haves: Local commits to offer, newest first; see negotiationHaves.
+// - filter: Objects the server may leave out, or nil. The caller checks
+// that the server supports filtering.
+//
+// Returns:
+// - The raw pack.
+// - An error if the server refuses the request or the pack cannot be read.
+func fetchPack(session *transport.Session, wants, haves []string, filter *ObjectFilter) ([]byte, error) {
+	offered := session.Advertisement.Capabilities
+	request := transport.NewCapabilities(transport.CapMultiAckDetailed, transport.CapIncludeTag, transport.CapNoProgress).
+		With(offered.Preferred(transport.CapSideBand64k, transport.CapSideBand)).
+		WithValue(transport.CapAgent, Agent)
+	if filter != nil {
+		request = request.With(transport.CapFilter)
+	}
+	capabilities := offered.Negotiate(request)
+	multiAck := capabilities.Has(transport.CapMultiAckDetailed)
+
+	enc := transport.NewEncoder(session)
+	dec := transport.NewDecoder(session)
+	sendWants := func() error {
+		for i, want := range wants {
+			line := "want " + want
+			if i == 0 && capabilities.Len() > 0 {
+				line += " " + capabilities.String()
+			}
+			if err := enc.Encode([]byte(line + "\n")); err != nil {
+				return err
+			}
+		}
+		if filter != nil {
+			if err := enc.Encodef("filter %s\n", filter); err != nil {
+				return err
+			}
+		}
+		return enc.Flush()
+	}
+
+	if err := sendWants(); err != nil {
+		return nil, err
+	}
+
+	common := ""
+	for start := 0; start < len(haves) && common == ""; start += haveBatchSize {
+		if start > 0 && session.Stateless {
+			if err := sendWants(); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, have := range haves[start:min(start+haveBatchSize, len(haves))] {
+			if err := enc.Encodef("have %s\n", have); err != nil {
+				return nil, err
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			return nil, err
+		}
+
+		acked, err := readAcks(dec, multiAck)
+		if err != nil {
+			return nil, err
+		}
+		common = acked
+	}
+	trace.Log(trace.Pack, "negotiation finished", "haves", len(haves), "common", common)
+
+	if session.Stateless && len(haves) > 0 {
+		if err := sendWants(); err != nil {
+			return nil, err
+		}
+		if common != "" {
+			if err := enc.Encodef("have %s\n", common); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := enc.Encode([]byte("done\n")); err != nil {
+		return nil, err
+	}
+
+	// A stateful basic server has already acknowledged the common commit;
+	// otherwise the final ACK or NAK comes now.
+	if multiAck || session.Stateless || common == "" {
+		if err := readFinalAck(dec); err != nil {
+			return nil, err
+		}
+	}
+
+	var packStream io.Reader = session
+	if capabilities.SidebandPacketSize() > 0 {
+		// Progress is turned off with no-progress where the server allows.
+		packStream = transport.NewSidebandReader(dec, io.Discard)
+	}
+	pack, err := ReadPackStream(packStream)
+	if err != nil {
+		return nil, fmt.Errorf("received a corrupt pack: %w", err)
+	}
+	return pack, nil
+}
+
+// readAcks reads the replies to a round of haves and returns the first
+// common commit the server acknowledged, or "". A basic server sends a
+// single ACK or NAK; with multi_ack_detailed every common have is
+// acknowledged and the round ends with NAK.
+func readAcks(dec *transport.Decoder, multiAck bool) (string, error) {
+	common := ""
+	for {
+		line, err := readAckLine(dec)
+		if err != nil || line == "NAK" {
+			return common, err
+		}
+		if common == "" {
+			common = strings.Fields(line)[1]
+		}
+		if !multiAck {
+			return common, nil
+		}
+	}
+}
+
+// readFinalAck reads the reply to "done": a NAK, or an ACK without status.
+// With multi_ack_detailed it can be preceded by "ACK <sha> common" lines for
+// the haves sent in the same request.
+func readFinalAck(dec *transport.Decoder) error {
+	for {
+		line, err := readAckLine(dec)
+		if err != nil || line == "NAK" || len(strings.Fields(line)) == 2 {
+			return err
+		}
+	}
+}
+
+// readAckLine reads one negotiation reply and turns ERR packets into errors.
+func readAckLine(dec *transport.Decoder) (string, error) {
+	_, payload, err := dec.Read()
+	if err != nil {
+		return "", fmt.Errorf("reading negotiation reply: %w", err)
+	}
+
+	line := strings.TrimSuffix(string(payload), "\n")
+	if message, isError := strings.CutPrefix(line, "ERR "); isError {
+		return "", fmt.Errorf("remote error: %s", message)
+	}
+	if line != "NAK" && !strings.HasPrefix(line, "ACK ") {
+		return "", fmt.Errorf("protocol error: expected ACK/NAK, got '%s'", line)
+	}
+	return line, nil
+}
+
+// negotiationHaves lists local commits to offer the server, newest first,
+// starting from HEAD and every ref.
+func negotiationHaves(repo *GitRepository, objects *ObjectManager) ([]string, error) {
+	roots, err := refRoots(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var queue []*Commit
+	queued := make(map[string]bool)
+	push := func(sha string) {
+		if queued[sha] {
+			return
+		}
+		queued[sha] = true
+		if commit, err := objects.ReadCommit(sha); err == nil {
+			queue = append(queue, commit)
+		}
+	}
+	for _, root := range roots {
+		if peeled, objType, err := objects.PeelObject(root); err == nil && objType == CommitType {
+			push(peeled)
+		}
+	}
+
+	var haves []string
+	for len(queue) > 0 && len(haves) < maxHaves {
+		sort.Slice(queue, func(i, j int) bool { return queue[i].CommitTime() > queue[j].CommitTime() })
+		commit := queue[0]
+		queue = queue[1:]
+
+		haves = append(haves, commit.SHA)
+		for _, parent := range commit.Parents {
+			push(parent)
+		}
+	}
+	return haves, nil
+}
+
+// fetchReflog returns the reflog message of a ref a fetch updates, given
+// its status, e.g. "fetch origin: fast-forward"; with noReflog, the
+// message is empty and nothing is logged.
+func fetchReflog(remote string, noReflog bool) func(status string) string {
+	return func(status string) string {
+		if noReflog {
+			return ""
+		}
+		action := map[string]string{
+			FetchNewBranch:   "storing head",
+			FetchNewRef:      "storing head",
+			FetchNewTag:      "storing tag",
+			FetchFastForward: "fast-forward",
+			FetchForced:      "forced-update",
+		}[status]
+		return "fetch " + remote + ": " + action
+	}
+}
+
+// updateFetchedRef moves a local ref to its fetched value unless the update
+// is not a fast-forward, or would move an existing tag, and force is off.
+// With dryRun, the outcome is worked out but the ref is left alone.
+//
+// Returns:
+// - The outcome, one of the Fetch* statuses.
+// - An error if the ref cannot be written.
+func updateFetchedRef(repo *GitRepository, objects *ObjectManager, fetched *FetchedRef, force, dryRun bool, reflog func(status string) string) (string, error) {
+	if refExists(repo, fetched.Local) {
+		old, err := ResolveRef(repo, fetched.Local)
+		if err != nil {
+			return "", err
+		}
+		fetched.Old = old
+	}
+
+	status := ""
+	switch {
+	case fetched.Old == fetched.New:
+		return FetchUpToDate, nil
+	case fetched.Old == zeroSHA && strings.HasPrefix(fetched.Local, "refs/tags/"):
+		status = FetchNewTag
+	case fetched.Old == zeroSHA && strings.HasPrefix(fetched.Remote, "refs/heads/"):
+		status = FetchNewBranch
+	case fetched.Old == zeroSHA:
+		status = FetchNewRef
+	case strings.HasPrefix(fetched.Local, "refs/tags/"):
+		if !force {
+			return FetchTagClobbered, nil
+		}
+		status = FetchForced
+	default:
+		if fastForward, err := objects.IsAncestor(fetched.Old, fetched.New); err == nil && fastForward {
+			status = FetchFastForward
+		} else if force {
+			status = FetchForced
+		} else {
+			return FetchNonFF, nil
+		}
+	}
+
+	if dryRun {
+		return status, nil
+	}
+	return status, UpdateRef(repo, fetched.Local, fetched.New, reflog(status))
+}
+
+// fetchDisplayURL shortens a remote URL the way git does in FETCH_HEAD and
+// fetch output, dropping credentials, trailing slashes and a ".git" suffix.
+func fetchDisplayURL(url string) string {
+	return strings.TrimSuffix(strings.TrimRight(AnonymizeURL(url), "/"), GitExtension)
+}
+
+// AnonymizeURL removes the credentials embedded in a URL, so that a token
+// is not printed or written to FETCH_HEAD.
+func AnonymizeURL(raw string) string {
+	if !strings.Contains(raw, "://") {
+		return raw
+	}
+	parsed, err := neturl.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// writeFetchHeadLine appends a FETCH_HEAD record in git's format:
+// "<sha>\t[not-for-merge]\t<kind> '<name>' of <url>".
+func writeFetchHeadLine(buf *bytes.Buffer, target fetchTarget, url string) {
+	marker := "not-for-merge"
+	if target.forMerge {
+		marker = ""
+	}
+
+	name := target.mapping.Src
+	description := fmt.Sprintf("'%s'", name)
+	switch {
+	case strings.HasPrefix(name, "refs/heads/"):
+		description = fmt.Sprintf("branch '%s'", strings.TrimPrefix(name, "refs/heads/"))
+	case strings.HasPrefix(name, "refs/tags/"):
+		description = fmt.Sprintf("tag '%s'", strings.TrimPrefix(name, "refs/tags/"))
+	case strings.HasPrefix(name, "refs/remotes/"):
+		description = fmt.Sprintf("remote-tracking branch '%s'", strings.TrimPrefix(name, "refs/remotes/"))
+	case name == HeadFile:
+		description = ""
+	}
+
+	if description == "" {
+		fmt.Fprintf(buf, "%s\t%s\t%s\n", target.sha, marker, url)
+		return
+	}
+	fmt.Fprintf(buf, "%s\t%s\t%s of %s\n", target.sha, marker, description, url)
+}
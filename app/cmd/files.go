@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 )
@@ -8,21 +9,22 @@ import (
 // isDir checks if the given path is a directory.
 //
 // Parameters:
+// - fsys: The file system holding the path.
 // - path: The path to check as a string.
 //
 // Returns:
 // - A boolean indicating whether the path is a directory.
 // - An error if there is an issue retrieving the file information.
-func isDir(path string) (bool, error) {
-	fileInfo, err := os.Stat(path)
+func isDir(fsys FileSystem, path string) (bool, error) {
+	fileInfo, err := fsys.Stat(path)
 	if err != nil {
 		return false, err
 	}
 	return fileInfo.IsDir(), nil
 }
 
-func pathExists(path string) bool {
-	_, err := os.Stat(path)
+func pathExists(fsys FileSystem, path string) bool {
+	_, err := fsys.Stat(path)
 	return !os.IsNotExist(err)
 }
 
@@ -53,12 +55,12 @@ func repoDir(repo *GitRepository, mkdir bool, paths ...string) (string, error) {
 	path := createRepoPath(repo, paths...)
 	pathExists := true
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
+	if _, err := repo.fs.Stat(path); os.IsNotExist(err) {
 		pathExists = false
 	}
 
 	if pathExists {
-		isDir, err := isDir(path)
+		isDir, err := isDir(repo.fs, path)
 		if err != nil || !isDir {
 			return "", err
 		} else {
@@ -67,7 +69,7 @@ func repoDir(repo *GitRepository, mkdir bool, paths ...string) (string, error) {
 	}
 
 	if mkdir {
-		if err := os.MkdirAll(path, 0755); err != nil {
+		if err := repo.fs.MkdirAll(path, 0755); err != nil {
 			return "", err
 		}
 		return path, nil
@@ -97,33 +99,69 @@ func repoFile(repo *GitRepository, mkdir bool, paths ...string) string {
 // listDir lists the contents of a directory.
 //
 // Parameters:
+// - fsys: The file system holding the directory.
 // - path: The path to the directory to be listed.
 //
 // Returns:
-// - A slice of os.DirEntry representing the contents of the directory.
+// - A slice of fs.DirEntry representing the contents of the directory.
 // - An error if there is an issue opening or reading the directory.
-func listDir(path string) ([]os.DirEntry, error) {
-	dir, err := os.Open(path)
-	if err != nil {
-		return nil, err
+func listDir(fsys FileSystem, path string) ([]fs.DirEntry, error) {
+	return fsys.ReadDir(path)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file.
+//
+// Parameters:
+// - fsys: The file system to write to.
+// - path: The final location of the file.
+// - data: The content to write.
+// - perm: The permissions of the resulting file.
+//
+// Returns:
+// - An error if the directory, the temporary file or the rename fails.
+func writeFileAtomic(fsys FileSystem, path string, data []byte, perm os.FileMode) error {
+	return writeFileSynced(fsys, path, data, perm, false)
+}
+
+// writeFileSynced is writeFileAtomic that, if sync is set, flushes the
+// temporary file to disk before renaming it into place. Syncing the
+// directory is left to the caller.
+func writeFileSynced(fsys FileSystem, path string, data []byte, perm os.FileMode, sync bool) error {
+	dir := filepath.Dir(path)
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return err
 	}
 
-	defer func(dir *os.File) {
-		err := dir.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(dir)
+	tmp, err := fsys.CreateTemp(dir, "tmp_"+filepath.Base(path)+"_")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
 
-	files, err := dir.ReadDir(-1) // Read all files
+	_, err = tmp.Write(data)
+	if err == nil && sync {
+		err = syncFile(tmp)
+	}
 	if err != nil {
-		return nil, err
+		tmp.Close()
+		fsys.Remove(tmpPath)
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		fsys.Remove(tmpPath)
+		return err
 	}
 
-	var dirContents []os.DirEntry
-	for _, file := range files {
-		dirContents = append(dirContents, file)
+	if err := fsys.Chmod(tmpPath, perm); err != nil {
+		fsys.Remove(tmpPath)
+		return err
 	}
 
-	return dirContents, nil
+	if err := fsys.Rename(tmpPath, path); err != nil {
+		fsys.Remove(tmpPath)
+		return err
+	}
+	return nil
 }
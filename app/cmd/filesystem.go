@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File is an open file of a FileSystem. *os.File implements it.
+type File interface {
+	fs.File
+	io.Writer
+	io.Seeker
+	io.ReaderAt
+	Name() string
+}
+
+// FileSystem is what a repository reads and writes its git directory
+// through: objects, packs, refs, reflogs, the index and the config. Names
+// are operating system paths, as built by createRepoPath, and errors are
+// *fs.PathError values that os.IsNotExist and friends understand.
+//
+// The worktree and files outside the repository, such as the global
+// config, templates and hooks, are still accessed through the os package.
+type FileSystem interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	MkdirAll(name string, perm fs.FileMode) error
+	MkdirTemp(dir, pattern string) (string, error)
+	CreateTemp(dir, pattern string) (File, error)
+	Rename(oldName, newName string) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// OSFileSystem is the FileSystem of the operating system, used by
+// repositories unless OpenRepositoryFS is given another.
+type OSFileSystem struct{}
+
+func (OSFileSystem) Open(name string) (File, error) {
+	return openOSFile(os.Open(name))
+}
+
+func (OSFileSystem) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return openOSFile(os.OpenFile(name, flag, perm))
+}
+
+func (OSFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OSFileSystem) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (OSFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	return os.MkdirTemp(dir, pattern)
+}
+
+func (OSFileSystem) CreateTemp(dir, pattern string) (File, error) {
+	return openOSFile(os.CreateTemp(dir, pattern))
+}
+
+func (OSFileSystem) Rename(oldName, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+func (OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFileSystem) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (OSFileSystem) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// openOSFile converts the result of an os call that opens a file, keeping
+// a failed open a nil File rather than a File holding a nil *os.File.
+func openOSFile(file *os.File, err error) (File, error) {
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// readOnlyFileSystem rejects every change to the file system it wraps.
+type readOnlyFileSystem struct {
+	FileSystem
+}
+
+// NewReadOnlyFileSystem wraps base so that reads go through and anything
+// that would change it fails with fs.ErrPermission, for serving
+// repositories that must not be modified.
+//
+// Parameters:
+// - base: The file system to read.
+//
+// Returns:
+// - The read-only file system.
+func NewReadOnlyFileSystem(base FileSystem) FileSystem {
+	return readOnlyFileSystem{base}
+}
+
+func (f readOnlyFileSystem) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0 {
+		return nil, readOnlyError("open", name)
+	}
+	return f.FileSystem.OpenFile(name, flag, perm)
+}
+
+func (readOnlyFileSystem) MkdirAll(name string, _ fs.FileMode) error {
+	return readOnlyError("mkdir", name)
+}
+
+func (readOnlyFileSystem) MkdirTemp(dir, _ string) (string, error) {
+	return "", readOnlyError("mkdirtemp", dir)
+}
+
+func (readOnlyFileSystem) CreateTemp(dir, _ string) (File, error) {
+	return nil, readOnlyError("createtemp", dir)
+}
+
+func (readOnlyFileSystem) Rename(oldName, _ string) error {
+	return readOnlyError("rename", oldName)
+}
+
+func (readOnlyFileSystem) Remove(name string) error {
+	return readOnlyError("remove", name)
+}
+
+func (readOnlyFileSystem) RemoveAll(name string) error {
+	return readOnlyError("removeall", name)
+}
+
+func (readOnlyFileSystem) Chmod(name string, _ fs.FileMode) error {
+	return readOnlyError("chmod", name)
+}
+
+func readOnlyError(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+// jailFileSystem confines the file system it wraps to a directory.
+type jailFileSystem struct {
+	base FileSystem
+	root string
+}
+
+// NewJailFileSystem wraps base so that only names inside root can be
+// accessed; any other name fails with fs.ErrPermission, like a path outside
+// a chroot. Names are checked after cleaning, so ".." cannot climb out, but
+// symlinks inside root are followed by base.
+//
+// Parameters:
+// - base: The file system to confine.
+// - root: The directory everything must stay inside.
+//
+// Returns:
+// - The confined file system.
+// - An error if root cannot be made absolute.
+func NewJailFileSystem(base FileSystem, root string) (FileSystem, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &jailFileSystem{base: base, root: root}, nil
+}
+
+// check fails unless name is root or inside it.
+func (f *jailFileSystem) check(op, name string) error {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if abs != f.root && !strings.HasPrefix(abs, f.root+string(filepath.Separator)) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (f *jailFileSystem) Open(name string) (File, error) {
+	if err := f.check("open", name); err != nil {
+		return nil, err
+	}
+	return f.base.Open(name)
+}
+
+func (f *jailFileSystem) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if err := f.check("open", name); err != nil {
+		return nil, err
+	}
+	return f.base.OpenFile(name, flag, perm)
+}
+
+func (f *jailFileSystem) Stat(name string) (fs.FileInfo, error) {
+	if err := f.check("stat", name); err != nil {
+		return nil, err
+	}
+	return f.base.Stat(name)
+}
+
+func (f *jailFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := f.check("readdir", name); err != nil {
+		return nil, err
+	}
+	return f.base.ReadDir(name)
+}
+
+func (f *jailFileSystem) MkdirAll(name string, perm fs.FileMode) error {
+	if err := f.check("mkdir", name); err != nil {
+		return err
+	}
+	return f.base.MkdirAll(name, perm)
+}
+
+func (f *jailFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	if err := f.check("mkdirtemp", dir); err != nil {
+		return "", err
+	}
+	return f.base.MkdirTemp(dir, pattern)
+}
+
+func (f *jailFileSystem) CreateTemp(dir, pattern string) (File, error) {
+	if err := f.check("createtemp", dir); err != nil {
+		return nil, err
+	}
+	return f.base.CreateTemp(dir, pattern)
+}
+
+func (f *jailFileSystem) Rename(oldName, newName string) error {
+	if err := f.check("rename", oldName); err != nil {
+		return err
+	}
+	if err := f.check("rename", newName); err != nil {
+		return err
+	}
+	return f.base.Rename(oldName, newName)
+}
+
+func (f *jailFileSystem) Remove(name string) error {
+	if err := f.check("remove", name); err != nil {
+		return err
+	}
+	return f.base.Remove(name)
+}
+
+func (f *jailFileSystem) RemoveAll(name string) error {
+	if err := f.check("removeall", name); err != nil {
+		return err
+	}
+	return f.base.RemoveAll(name)
+}
+
+func (f *jailFileSystem) Chmod(name string, mode fs.FileMode) error {
+	if err := f.check("chmod", name); err != nil {
+		return err
+	}
+	return f.base.Chmod(name, mode)
+}
+
+// walkDir is filepath.WalkDir on fsys: it calls fn for root and everything
+// below it in lexical order, honoring filepath.SkipDir and SkipAll.
+func walkDir(fsys FileSystem, root string, fn fs.WalkDirFunc) error {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDirEntry(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkDirEntry(fsys FileSystem, path string, entry fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, entry, nil); err != nil || !entry.IsDir() {
+		if err == filepath.SkipDir && entry.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		// Like filepath.WalkDir, fn hears about the directory again.
+		if err = fn(path, entry, err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, child := range entries {
+		if err := walkDirEntry(fsys, filepath.Join(path, child.Name()), child, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile reads the whole of a file, like os.ReadFile.
+func readFile(fsys FileSystem, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ObjectFilter is a partial clone filter, naming the objects a fetch may
+// leave out. Only blob filters are supported: blob:none omits every blob
+// and blob:limit=<n> omits blobs of at least n bytes. Objects that are
+// wanted by name are always sent, whatever the filter.
+type ObjectFilter struct {
+	spec      string
+	blobLimit int64 // Blobs of this size or larger are omitted.
+}
+
+// ParseObjectFilter parses a filter spec as given to --filter.
+//
+// Parameters:
+// - spec: The filter, "blob:none" or "blob:limit=<n>[kmg]".
+//
+// Returns:
+// - The filter.
+// - An error if the spec is malformed or not supported.
+func ParseObjectFilter(spec string) (*ObjectFilter, error) {
+	if spec == "blob:none" {
+		return &ObjectFilter{spec: spec}, nil
+	}
+	if value, ok := strings.CutPrefix(spec, "blob:limit="); ok {
+		limit, err := parseConfigSize(value)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid filter-spec '%s'", spec)
+		}
+		return &ObjectFilter{spec: spec, blobLimit: limit}, nil
+	}
+	return nil, fmt.Errorf("invalid filter-spec '%s'", spec)
+}
+
+// String returns the filter spec as sent to the server.
+func (f *ObjectFilter) String() string {
+	return f.spec
+}
+
+// Includes reports whether an object of the given type and size passes
+// the filter.
+func (f *ObjectFilter) Includes(objType GitObjectType, size int64) bool {
+	return objType != BlobType || size < f.blobLimit
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// WorktreeConfigFile holds per-worktree settings, read on top of the
+// repository config when extensions.worktreeConfig is set.
+const WorktreeConfigFile = "config.worktree"
+
+// maxFormatVersion is the highest core.repositoryformatversion understood.
+// Version 1 repositories may declare extensions that change how they must
+// be read, and refuse tools that do not know them.
+const maxFormatVersion = 1
+
+// checkRepositoryFormat validates core.repositoryformatversion and, for
+// version 1, the extensions.* the repository relies on, so a repository
+// using features this implementation lacks is refused rather than
+// misread. Like git, version 0 ignores extensions except objectFormat,
+// which only version 1 may use.
+func checkRepositoryFormat(config *viper.Viper) error {
+	version := config.GetInt("core.repositoryformatversion")
+	if version < 0 || version > maxFormatVersion {
+		return fmt.Errorf("expected git repo version <= %d, found %d", maxFormatVersion, version)
+	}
+
+	extensions := config.GetStringMapString("extensions")
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var unknown []string
+	for _, name := range names {
+		value := extensions[name]
+		switch name {
+		case "noop":
+		case "objectformat":
+			if version == 0 {
+				return fmt.Errorf("repo version is 0, but v1-only extension found: %s", name)
+			}
+			if !strings.EqualFold(value, "sha1") {
+				return fmt.Errorf("unsupported object format '%s'", value)
+			}
+		case "worktreeconfig", "preciousobjects", "partialclone":
+		default:
+			if version == 1 {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown repository extension found: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// readWorktreeConfig merges config.worktree into config, the repository
+// config being loaded, when extensions.worktreeConfig asks for it. A
+// missing file is not an error.
+func readWorktreeConfig(repo *GitRepository, config *viper.Viper) error {
+	if config.GetInt("core.repositoryformatversion") != 1 || !config.GetBool("extensions.worktreeconfig") {
+		return nil
+	}
+
+	file, err := repo.fs.Open(createRepoPath(repo, WorktreeConfigFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	trace.Log(trace.Config, "config read", "path", file.Name())
+	return config.MergeConfig(file)
+}
+
+// PreciousObjects reports whether extensions.preciousObjects forbids
+// deleting objects, as for a repository whose objects others borrow.
+func PreciousObjects(repo *GitRepository) bool {
+	return repo.Config().GetInt("core.repositoryformatversion") == 1 && repo.Config().GetBool("extensions.preciousobjects")
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// signaturePattern matches the "Name <email> <timestamp> <zone>" of an
+// author, committer or tagger header.
+var signaturePattern = regexp.MustCompile(`^[^<>\n]*<[^<>\n]*> [0-9]+ [+-][0-9]{4}$`)
+
+// FsckObjects reports whether objects received by the given transfer
+// direction ("fetch" or "receive") are checked before they are stored:
+// <direction>.fsckObjects, falling back to transfer.fsckObjects. Checking is
+// on unless configured off.
+func FsckObjects(repo *GitRepository, direction string) bool {
+	if key := direction + ".fsckObjects"; repo.Config().IsSet(key) {
+		return repo.Config().GetBool(key)
+	}
+	if repo.Config().IsSet("transfer.fsckObjects") {
+		return repo.Config().GetBool("transfer.fsckObjects")
+	}
+	return true
+}
+
+// CheckObject validates the content of an object of the given type the way
+// git fsck does for received objects: trees must have sane, sorted entries,
+// commits and tags must have well-formed headers. Blobs are always valid.
+//
+// Parameters:
+// - objType: The type of the object.
+// - data: The object content.
+//
+// Returns:
+// - An error describing the first problem found, or nil.
+func CheckObject(objType GitObjectType, data []byte) error {
+	kind, ok := LookupObjectType(objType)
+	if !ok {
+		return fmt.Errorf("unknown object type '%s'", objType)
+	}
+	if kind.Check == nil {
+		return nil
+	}
+	return kind.Check(data)
+}
+
+// checkTree checks entry modes and names and that entries are sorted
+// without duplicates.
+func checkTree(data []byte) error {
+	entries, err := parseTree(data)
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		switch entry.Mode {
+		case ModeTree, ModeBlob, ModeExecutable, ModeSymlink, ModeGitlink, "100664":
+		default:
+			return fmt.Errorf("tree entry '%s' has bad mode %s", entry.Name, entry.Mode)
+		}
+
+		switch {
+		case entry.Name == "":
+			return fmt.Errorf("tree has an empty entry name")
+		case entry.Name == "." || entry.Name == "..":
+			return fmt.Errorf("tree has an entry named '%s'", entry.Name)
+		case strings.Contains(entry.Name, "/"):
+			return fmt.Errorf("tree entry '%s' contains a slash", entry.Name)
+		case strings.EqualFold(entry.Name, GitExtension):
+			return fmt.Errorf("tree has an entry named '%s'", entry.Name)
+		}
+
+		if i > 0 && CompareTreeEntries(entries[i-1], entry) >= 0 {
+			if entries[i-1].Name == entry.Name {
+				return fmt.Errorf("tree has duplicate entries for '%s'", entry.Name)
+			}
+			return fmt.Errorf("tree entries are not sorted at '%s'", entry.Name)
+		}
+	}
+	return nil
+}
+
+// checkCommit checks that a commit starts with a valid tree, followed by
+// valid parents, an author and a committer.
+func checkCommit(data []byte) error {
+	kvlm, err := ParseKvlm(data)
+	if err != nil {
+		return err
+	}
+
+	fields := kvlm.Fields
+	if len(fields) == 0 || fields[0].Key != "tree" || !isValidSHA(string(fields[0].Value)) {
+		return fmt.Errorf("commit does not start with a valid tree")
+	}
+	fields = fields[1:]
+	for len(fields) > 0 && fields[0].Key == "parent" {
+		if !isValidSHA(string(fields[0].Value)) {
+			return fmt.Errorf("commit has an invalid parent '%s'", fields[0].Value)
+		}
+		fields = fields[1:]
+	}
+
+	for _, key := range []string{"author", "committer"} {
+		if len(fields) == 0 || fields[0].Key != key {
+			return fmt.Errorf("commit is missing its %s", key)
+		}
+		if !signaturePattern.Match(fields[0].Value) {
+			return fmt.Errorf("commit has a malformed %s '%s'", key, fields[0].Value)
+		}
+		fields = fields[1:]
+	}
+	return nil
+}
+
+// checkTag checks the object, type and tag headers of an annotated tag and
+// its tagger, if present.
+func checkTag(data []byte) error {
+	kvlm, err := ParseKvlm(data)
+	if err != nil {
+		return err
+	}
+
+	if object := string(kvlm.Get("object")); !isValidSHA(object) {
+		return fmt.Errorf("tag has an invalid object '%s'", object)
+	}
+	if _, err := parseObjectType(string(kvlm.Get("type"))); err != nil {
+		return fmt.Errorf("tag has an invalid type: %w", err)
+	}
+	if len(kvlm.Get("tag")) == 0 {
+		return fmt.Errorf("tag has no name")
+	}
+	if tagger := kvlm.Get("tagger"); tagger != nil && !signaturePattern.Match(tagger) {
+		return fmt.Errorf("tag has a malformed tagger '%s'", tagger)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// fsyncComponent is a set of the kinds of files core.fsync names.
+type fsyncComponent uint
+
+const (
+	fsyncLooseObject fsyncComponent = 1 << iota
+	fsyncPack
+	fsyncPackMetadata
+	fsyncCommitGraph
+	fsyncIndex
+	fsyncReference
+
+	fsyncObjects         = fsyncLooseObject | fsyncPack
+	fsyncDerivedMetadata = fsyncPackMetadata | fsyncCommitGraph
+	fsyncCommitted       = fsyncObjects | fsyncReference
+	fsyncAdded           = fsyncCommitted | fsyncIndex
+	fsyncAll             = fsyncAdded | fsyncDerivedMetadata
+
+	// fsyncDefault is what git syncs when core.fsync is unset: everything
+	// but loose objects, the index and refs.
+	fsyncDefault = (fsyncObjects | fsyncDerivedMetadata) &^ fsyncLooseObject
+)
+
+// fsyncComponents are the names core.fsync accepts, aggregates included.
+var fsyncComponents = map[string]fsyncComponent{
+	"loose-object":     fsyncLooseObject,
+	"pack":             fsyncPack,
+	"pack-metadata":    fsyncPackMetadata,
+	"commit-graph":     fsyncCommitGraph,
+	"index":            fsyncIndex,
+	"reference":        fsyncReference,
+	"objects":          fsyncObjects,
+	"derived-metadata": fsyncDerivedMetadata,
+	"committed":        fsyncCommitted,
+	"added":            fsyncAdded,
+	"all":              fsyncAll,
+}
+
+// parseFsyncComponents parses a core.fsync value the way git does: a comma
+// separated list of components added to the default, "-component" to take
+// one away and "none" to start from nothing. Unknown names are ignored.
+func parseFsyncComponents(value string) fsyncComponent {
+	current := fsyncComponent(fsyncDefault)
+	var positive, negative fsyncComponent
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "none" {
+			current = 0
+			continue
+		}
+		negated := strings.HasPrefix(name, "-")
+		component, ok := fsyncComponents[strings.TrimPrefix(name, "-")]
+		if !ok {
+			trace.Log(trace.Config, "ignoring unknown core.fsync component", "component", name)
+			continue
+		}
+		if negated {
+			negative |= component
+		} else {
+			positive |= component
+		}
+	}
+	return current&^negative | positive
+}
+
+// fsyncPolicy says how the write of a file is made durable.
+type fsyncPolicy int
+
+const (
+	fsyncNone     fsyncPolicy = iota // Left to the operating system.
+	fsyncNow                         // The file and its directory are synced before the write returns.
+	fsyncDeferred                    // Synced with the other deferred files, see syncPending.
+)
+
+// fsyncPolicyOf returns how repo syncs writes of component, following
+// core.fsync, the older core.fsyncObjectFiles and core.fsyncMethod.
+//
+// With core.fsyncMethod=batch, loose objects are not synced one by one:
+// they are synced all at once before the next index or ref update, which
+// is the end of an add or a commit. The objects of a command that updates
+// neither, such as hash-object -w, are left to the operating system.
+// Windows cannot sync the read-only object files later, so there batch
+// is the same as fsync.
+func (repo *GitRepository) fsyncPolicyOf(component fsyncComponent) fsyncPolicy {
+	components := parseFsyncComponents(lookupConfig(repo, "core.fsync"))
+	if legacy, err := strconv.ParseBool(lookupConfig(repo, "core.fsyncobjectfiles")); err == nil && legacy {
+		components |= fsyncLooseObject
+	}
+	if components&component == 0 {
+		return fsyncNone
+	}
+
+	switch method := lookupConfig(repo, "core.fsyncmethod"); method {
+	case "", "fsync", "writeout-only":
+	case "batch":
+		if component == fsyncLooseObject && runtime.GOOS != "windows" {
+			return fsyncDeferred
+		}
+	default:
+		trace.Log(trace.Config, "ignoring unknown core.fsyncMethod value", "value", method)
+	}
+	return fsyncNow
+}
+
+// writeRepoFile writes a file of component to the git directory of repo
+// like writeFileAtomic, syncing it as core.fsync says. Index and ref
+// updates first sync the deferred objects, so that nothing durable ever
+// points at an object that is not.
+//
+// Parameters:
+// - repo: The repository to write to.
+// - component: What kind of file is written.
+// - path: The final location of the file.
+// - data: The content to write.
+// - perm: The permissions of the resulting file.
+//
+// Returns:
+// - An error if the write or a sync fails.
+func writeRepoFile(repo *GitRepository, component fsyncComponent, path string, data []byte, perm os.FileMode) error {
+	if component&(fsyncIndex|fsyncReference) != 0 {
+		if err := repo.syncPending(); err != nil {
+			return err
+		}
+	}
+
+	policy := repo.fsyncPolicyOf(component)
+	if err := writeFileSynced(repo.fs, path, data, perm, policy == fsyncNow); err != nil {
+		return err
+	}
+	return repo.syncWritten(policy, path)
+}
+
+// syncWritten finishes the sync of a file just renamed into place, whose
+// content was already synced if policy is fsyncNow: it syncs the directory
+// or, for fsyncDeferred, remembers the file for syncPending.
+func (repo *GitRepository) syncWritten(policy fsyncPolicy, path string) error {
+	switch policy {
+	case fsyncNow:
+		return syncDir(repo.fs, filepath.Dir(path))
+	case fsyncDeferred:
+		repo.syncMu.Lock()
+		repo.pendingSyncs = append(repo.pendingSyncs, path)
+		repo.syncMu.Unlock()
+	}
+	return nil
+}
+
+// syncPending syncs the files whose sync was deferred by
+// core.fsyncMethod=batch, then each of their directories once. Files
+// removed since, as by a discarded quarantine, are skipped.
+func (repo *GitRepository) syncPending() error {
+	repo.syncMu.Lock()
+	pending := repo.pendingSyncs
+	repo.pendingSyncs = nil
+	repo.syncMu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	dirs := make(map[string]bool)
+	for _, path := range pending {
+		file, err := repo.fs.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		err = syncFile(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range dirs {
+		if err := syncDir(repo.fs, dir); err != nil {
+			return err
+		}
+	}
+	trace.Log(trace.Object, "fsync batch", "files", len(pending), "dirs", len(dirs))
+	return nil
+}
+
+// syncFile flushes an open file to disk. Files of a FileSystem that cannot
+// sync them are left as they are.
+func syncFile(file File) error {
+	if syncer, ok := file.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// syncDir flushes a directory to disk, making the files just renamed into
+// it durable. Windows has no way to sync a directory and needs none.
+func syncDir(fsys FileSystem, dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	file, err := fsys.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return syncFile(file)
+}
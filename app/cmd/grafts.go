@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+const (
+	GraftsFile  = "info/grafts" // Lines of "<commit> [<parent>...]" overriding parents.
+	ShallowFile = "shallow"     // Commits whose parents are not in the repository.
+)
+
+// ReadGrafts parses the info/grafts file. Each line names a commit followed
+// by the parents history should see for it; empty lines and lines starting
+// with '#' are ignored.
+//
+// Returns:
+// - A map from commit to its grafted parents, empty if there is no file.
+// - An error if the file cannot be read or a line is malformed.
+func ReadGrafts(repo *GitRepository) (map[string][]string, error) {
+	grafts := make(map[string][]string)
+	data, err := readFile(repo.fs, createRepoPath(repo, GraftsFile))
+	if os.IsNotExist(err) {
+		return grafts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		for _, sha := range fields {
+			if !isValidSHA(sha) {
+				return nil, fmt.Errorf("bad graft data: %s", line)
+			}
+		}
+		grafts[fields[0]] = fields[1:]
+	}
+	return grafts, scanner.Err()
+}
+
+// ReadShallow returns the commits listed in the shallow file: the boundary
+// of a shallow clone, beyond which no history is present.
+func ReadShallow(repo *GitRepository) ([]string, error) {
+	data, err := readFile(repo.fs, createRepoPath(repo, ShallowFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if !isValidSHA(line) {
+			return nil, fmt.Errorf("bad shallow line: %s", line)
+		}
+		commits = append(commits, line)
+	}
+	return commits, nil
+}
+
+// IsShallow reports whether the repository is a shallow clone.
+func IsShallow(repo *GitRepository) bool {
+	commits, err := ReadShallow(repo)
+	return err == nil && len(commits) > 0
+}
+
+// LoadGrafts combines info/grafts and the shallow file into one map from
+// commit to effective parents. Shallow commits have no parents, whatever
+// info/grafts says about them.
+func LoadGrafts(repo *GitRepository) (map[string][]string, error) {
+	grafts, err := ReadGrafts(repo)
+	if err != nil {
+		return nil, err
+	}
+	shallow, err := ReadShallow(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, sha := range shallow {
+		grafts[sha] = nil
+	}
+	return grafts, nil
+}
+
+// UseGrafts makes ReadCommit report the parents from info/grafts and the
+// shallow file instead of those recorded in the commit, so that everything
+// walking history through m sees the rewritten history. Like UseReplaceRefs,
+// this is for commands that show or walk history, not for those that copy
+// objects.
+//
+// Returns:
+// - m, for chaining onto NewObjectManager.
+func (m *ObjectManager) UseGrafts() *ObjectManager {
+	grafts, err := LoadGrafts(m.repo)
+	if err != nil {
+		trace.Log(trace.Object, "grafts unreadable", "error", err)
+		return m
+	}
+	if len(grafts) > 0 {
+		m.grafts = grafts
+	}
+	return m
+}
+
+// EffectiveParents returns the parents history sees for a commit: its
+// grafted parents if m uses grafts and the commit is grafted or shallow,
+// otherwise the parents recorded in the commit.
+//
+// Parameters:
+// - sha: The commit.
+//
+// Returns:
+// - The parents, in order.
+// - An error if the commit cannot be read.
+func (m *ObjectManager) EffectiveParents(sha string) ([]string, error) {
+	commit, err := m.ReadCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Parents, nil
+}
+
+// applyGrafts replaces the parents of commit with its grafted ones, if any.
+func (m *ObjectManager) applyGrafts(commit *Commit) {
+	if parents, ok := m.grafts[commit.SHA]; ok {
+		trace.Log(trace.Object, "graft", "sha", commit.SHA, "parents", len(parents))
+		commit.Parents = append([]string(nil), parents...)
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// HashObjectOptions control how HashObject and HashFile treat their input.
+type HashObjectOptions struct {
+	Type  GitObjectType
+	Write bool // Store the object instead of only computing its name.
+	// Literally skips the validation of the content and allows any type
+	// name, for creating deliberately broken objects.
+	Literally bool
+	// Path is the worktree path whose attributes decide the line ending
+	// conversion of blobs, relative to the top of the worktree. No
+	// conversion is done when it is empty.
+	Path string
+}
+
+// HashObject computes the name of an object and optionally stores it, as
+// git hash-object does.
+//
+// Parameters:
+// - repo: The repository, which may be nil when nothing is written.
+// - data: The object content, as found in the worktree for blobs.
+// - opts: The type of the object and how to treat it.
+//
+// Returns:
+// - The hex encoded SHA-1 of the object.
+// - An error if the type is unknown, the content is not a valid object of
+// its type or it cannot be written.
+func HashObject(repo *GitRepository, data []byte, opts HashObjectOptions) (string, error) {
+	if !opts.Literally {
+		if _, err := parseObjectType(string(opts.Type)); err != nil {
+			return "", fmt.Errorf("invalid object type '%s'", opts.Type)
+		}
+		if err := CheckObject(opts.Type, data); err != nil {
+			return "", fmt.Errorf("corrupt %s: %w", opts.Type, err)
+		}
+	}
+	if opts.Write && repo == nil {
+		return "", fmt.Errorf("not a git repository")
+	}
+
+	if opts.Type == BlobType && opts.Path != "" && repo != nil && repo.WorkTree != "" {
+		converted, err := NewEOLConverter(repo).ToGit(opts.Path, data)
+		if err != nil {
+			return "", err
+		}
+		data = converted
+	}
+	return NewObjectManager(repo).WriteObject(opts.Type, data, opts.Write)
+}
+
+// HashFile hashes the content of a file with HashObject. Blobs above
+// core.bigFileThreshold are streamed instead of being read into memory,
+// without line ending conversion.
+//
+// Parameters:
+// - repo: The repository, which may be nil when nothing is written.
+// - file: The file to hash.
+// - opts: As for HashObject.
+//
+// Returns:
+// - The hex encoded SHA-1 of the object.
+// - An error if the file cannot be read or from HashObject.
+func HashFile(repo *GitRepository, file string, opts HashObjectOptions) (string, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("could not open '%s' for reading: %w", file, errors.Unwrap(err))
+	}
+	defer reader.Close()
+
+	if opts.Type == BlobType && repo != nil {
+		if info, err := reader.Stat(); err == nil && info.Mode().IsRegular() && info.Size() > BigFileThreshold(repo) {
+			return NewObjectManager(repo).WriteBlobStream(reader, info.Size(), opts.Write)
+		}
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("could not read '%s': %w", file, err)
+	}
+	return HashObject(repo, data, opts)
+}
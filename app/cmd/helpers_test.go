@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMain removes the benchmark fixtures, which outlive a single
+// benchmark, once every test and benchmark has run.
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if fixtureRoot != "" {
+		_ = os.RemoveAll(fixtureRoot)
+	}
+	os.Exit(code)
+}
+
+// newTestRepo creates an empty repository with a worktree in a temporary
+// directory, out of reach of the user's global config.
+func newTestRepo(tb testing.TB) *GitRepository {
+	tb.Helper()
+	isolateConfig(tb)
+	repo, _, err := CreateGitRepository(tb.TempDir(), InitOptions{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return repo
+}
+
+// isolateConfig points the global config and the templates at an empty
+// home directory for the rest of the test.
+func isolateConfig(tb testing.TB) {
+	tb.Helper()
+	home := tb.TempDir()
+	tb.Setenv("HOME", home)
+	tb.Setenv(GlobalConfigEnv, filepath.Join(home, ".gitconfig"))
+	tb.Setenv("XDG_CONFIG_HOME", "")
+	tb.Setenv("GIT_TEMPLATE_DIR", "")
+}
+
+// writeWorktreeFile writes a file of the worktree, creating its directories.
+func writeWorktreeFile(tb testing.TB, repo *GitRepository, name, content string) {
+	tb.Helper()
+	path := filepath.Join(repo.WorkTree, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// appendRepoConfig adds text to the config file of repo as it is, e.g. to
+// set a variable more than once, and reloads the config.
+func appendRepoConfig(tb testing.TB, repo *GitRepository, text string) {
+	tb.Helper()
+	file, err := os.OpenFile(createRepoPath(repo, ConfigFile), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	_, err = file.WriteString(text)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = loadRepoConfig(repo, true)
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// retryLocked runs update until it no longer fails, as a writer that finds
+// a lock taken would try again later. It gives up after a few seconds and
+// returns the last error.
+func retryLocked(update func() error) error {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := update()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
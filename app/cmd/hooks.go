@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// HooksDir holds the hook scripts of a repository, unless core.hooksPath
+// names another directory.
+const HooksDir = "hooks"
+
+// HookPath returns the path of an executable hook, such as "pre-receive".
+// ok is false when the hook does not exist or is not executable.
+func HookPath(repo *GitRepository, name string) (string, bool) {
+	dir := createRepoPath(repo, HooksDir)
+	if custom := repo.Config().GetString("core.hookspath"); custom != "" {
+		dir = custom
+		if !filepath.IsAbs(dir) && repo.WorkTree != "" {
+			dir = filepath.Join(repo.WorkTree, dir)
+		}
+	}
+
+	path := filepath.Join(dir, name)
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return "", false
+	}
+	return path, true
+}
+
+// HookRun describes one invocation of a hook.
+type HookRun struct {
+	Args   []string
+	Stdin  io.Reader
+	Output io.Writer // Receives the hook's stdout and stderr; discarded when nil.
+	Env    []string  // Added to the environment, as "NAME=value".
+}
+
+// RunHook runs a hook the way git does: from the top of the worktree, or
+// the git directory of a bare repository, with GIT_DIR set.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The hook name, e.g. "post-receive".
+// - run: The arguments, input and output of the hook.
+//
+// Returns:
+// - Whether the hook exists and was run.
+// - An error if the hook could not be started or exited with a non-zero status.
+func RunHook(repo *GitRepository, name string, run HookRun) (bool, error) {
+	path, ok := HookPath(repo, name)
+	if !ok {
+		return false, nil
+	}
+
+	gitDir, err := filepath.Abs(repo.GitDir)
+	if err != nil {
+		return true, err
+	}
+	hook := exec.Command(path, run.Args...)
+	hook.Dir = gitDir
+	if !repo.IsBare() {
+		hook.Dir = repo.WorkTree
+	}
+	hook.Env = append(append(os.Environ(), "GIT_DIR="+gitDir), run.Env...)
+	hook.Stdin = run.Stdin
+	output := run.Output
+	if output == nil {
+		output = io.Discard
+	}
+	hook.Stdout, hook.Stderr = output, output
+	return true, hook.Run()
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// defaultHTTPRetries is how often an idempotent HTTP request is retried
+// when http.maxRetries is not set.
+const defaultHTTPRetries = 3
+
+// TokenEnv names the variable holding an access token for HTTP remotes,
+// for hosts such as GitHub and GitLab that accept tokens instead of
+// passwords. A plain token is sent as "Authorization: Bearer <token>"; a
+// "<user>:<token>" pair is sent with basic authentication.
+const TokenEnv = "JUSTDOIT_TOKEN"
+
+// HTTPOptions reads the settings of the smart HTTP client for url from the
+// config of repo, which may be nil outside a repository, and the
+// environment:
+//
+//   - http.proxy: the proxy to use; unset, HTTPS_PROXY, HTTP_PROXY and
+//     NO_PROXY are honored.
+//   - http.sslCAInfo or GIT_SSL_CAINFO: a file of CA certificates to trust.
+//   - http.sslVerify=false or GIT_SSL_NO_VERIFY: skip certificate checks.
+//   - http.timeout: seconds to wait for a connection or a response.
+//   - http.maxRetries: retries of fetch requests after transient failures.
+//   - http.extraHeader: a "Name: value" header sent with every request.
+//
+// Like git, each can also be set for the URLs under a prefix, as
+// http.<prefix>.<name>, and the longest matching prefix wins. Credentials
+// come from http.extraHeader, else from the URL, else from JUSTDOIT_TOKEN.
+// Unparsable numbers are ignored, leaving the default.
+func HTTPOptions(repo *GitRepository, url string) transport.HTTPOptions {
+	get := httpConfigLookup(repo, url)
+	opts := transport.HTTPOptions{
+		Proxy:      get("proxy"),
+		CAInfo:     get("sslcainfo"),
+		MaxRetries: defaultHTTPRetries,
+		Header:     make(http.Header),
+	}
+	if caInfo := os.Getenv("GIT_SSL_CAINFO"); caInfo != "" {
+		opts.CAInfo = caInfo
+	}
+	if verify, err := strconv.ParseBool(get("sslverify")); err == nil {
+		opts.NoVerify = !verify
+	}
+	if os.Getenv("GIT_SSL_NO_VERIFY") != "" {
+		opts.NoVerify = true
+	}
+
+	if value := get("timeout"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			opts.Timeout = time.Duration(seconds) * time.Second
+		} else {
+			trace.Log(trace.Config, "http.timeout ignored", "value", value)
+		}
+	}
+	if value := get("maxretries"); value != "" {
+		if retries, err := strconv.Atoi(value); err == nil && retries >= 0 {
+			opts.MaxRetries = retries
+		} else {
+			trace.Log(trace.Config, "http.maxRetries ignored", "value", value)
+		}
+	}
+
+	if header := get("extraheader"); header != "" {
+		if name, value, ok := strings.Cut(header, ":"); ok && strings.TrimSpace(name) != "" {
+			opts.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+		} else {
+			trace.Log(trace.Config, "http.extraHeader ignored, expected 'Name: value'")
+		}
+	}
+	if opts.Header.Get("Authorization") == "" {
+		if auth := tokenAuthorization(url); auth != "" {
+			opts.Header.Set("Authorization", auth)
+		}
+	}
+	return opts
+}
+
+// tokenAuthorization returns the Authorization header for the token in
+// JUSTDOIT_TOKEN, or an empty string when there is none or url carries
+// its own credentials.
+func tokenAuthorization(url string) string {
+	token := os.Getenv(TokenEnv)
+	if token == "" {
+		return ""
+	}
+	if endpoint, err := transport.ParseEndpoint(url); err != nil || endpoint.User != nil {
+		return ""
+	}
+	if user, secret, ok := strings.Cut(token, ":"); ok {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+secret))
+	}
+	return "Bearer " + token
+}
+
+// httpConfigLookup returns a function looking up http.<name> for url. A
+// value set under the longest http.<prefix>.<name> whose prefix matches
+// url wins, then http.<name>; the repository config is searched before
+// the global one at each step.
+func httpConfigLookup(repo *GitRepository, url string) func(name string) string {
+	configs := []*viper.Viper{GlobalConfig()}
+	if repo != nil {
+		configs = append([]*viper.Viper{repo.Config()}, configs...)
+	}
+	// Config keys are lower case, URLs included.
+	target := strings.ToLower(url)
+
+	return func(name string) string {
+		best, value := -1, ""
+		for _, config := range configs {
+			for _, key := range config.AllKeys() {
+				rest, ok := strings.CutPrefix(key, `http "`)
+				if !ok {
+					continue
+				}
+				prefix, keyName, ok := strings.Cut(rest, `".`)
+				if ok && keyName == name && len(prefix) > best && urlMatchesPrefix(target, prefix) {
+					best, value = len(prefix), config.GetString(key)
+				}
+			}
+		}
+		if best >= 0 {
+			return unquoteConfigValue(value)
+		}
+		for _, config := range configs {
+			if config.IsSet("http." + name) {
+				return unquoteConfigValue(config.GetString("http." + name))
+			}
+		}
+		return ""
+	}
+}
+
+// urlMatchesPrefix reports whether url is prefix or lies below it, only
+// matching whole path components, so "https://host/org" covers
+// "https://host/org/repo" but not "https://host/organization".
+func urlMatchesPrefix(url, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	rest, ok := strings.CutPrefix(url, prefix)
+	return ok && (rest == "" || strings.HasPrefix(rest, "/"))
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// HTTPServerOptions configures the smart HTTP server.
+type HTTPServerOptions struct {
+	Root      string // Directory that request paths are resolved against.
+	ExportAll bool   // Serve repositories without git-daemon-export-ok.
+	Username  string // Basic auth user. Authentication is disabled when empty.
+	Password  string // Basic auth password.
+}
+
+// httpServices maps the services reachable over smart HTTP onto their handlers.
+var httpServices = map[string]func(repo *GitRepository, r io.Reader, w io.Writer, advertiseOnly bool) error{
+	"git-upload-pack": func(repo *GitRepository, r io.Reader, w io.Writer, advertiseOnly bool) error {
+		return UploadPack(repo, r, w, UploadPackOptions{AdvertiseRefsOnly: advertiseOnly, StatelessRPC: !advertiseOnly})
+	},
+	"git-receive-pack": func(repo *GitRepository, r io.Reader, w io.Writer, advertiseOnly bool) error {
+		return ReceivePack(repo, r, w, ReceivePackOptions{AdvertiseRefsOnly: advertiseOnly, StatelessRPC: !advertiseOnly})
+	},
+}
+
+// httpServer serves the smart HTTP protocol for the repositories below a root.
+type httpServer struct {
+	opts   HTTPServerOptions
+	logger *log.Logger
+}
+
+// NewHTTPHandler creates a handler for the smart HTTP protocol, the same
+// endpoints git http-backend provides:
+//
+//	GET  /<repo>/info/refs?service=git-upload-pack|git-receive-pack
+//	POST /<repo>/git-upload-pack
+//	POST /<repo>/git-receive-pack
+//
+// Pushing is only allowed to authenticated users, or when the repository sets
+// http.receivepack to true.
+//
+// Parameters:
+// - opts: Repository location and credentials.
+// - logger: Where failed requests are logged.
+//
+// Returns:
+// - The HTTP handler.
+func NewHTTPHandler(opts HTTPServerOptions, logger *log.Logger) http.Handler {
+	return &httpServer{opts: opts, logger: logger}
+}
+
+// RunHTTPServer serves the smart HTTP protocol on addr until the listener fails.
+func RunHTTPServer(addr string, opts HTTPServerOptions, logger *log.Logger) error {
+	logger.Printf("Serving %s on http://%s", opts.Root, addr)
+	return http.ListenAndServe(addr, NewHTTPHandler(opts, logger))
+}
+
+func (s *httpServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if s.opts.Username != "" && !s.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="justdoit"`)
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var repoPath, service string
+	advertiseOnly := false
+	switch {
+	case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/info/refs"):
+		repoPath = strings.TrimSuffix(req.URL.Path, "/info/refs")
+		service = req.URL.Query().Get("service")
+		advertiseOnly = true
+		if service == "" {
+			http.Error(w, "dumb http protocol is not supported", http.StatusForbidden)
+			return
+		}
+	case req.Method == http.MethodPost:
+		slash := strings.LastIndex(req.URL.Path, "/")
+		repoPath, service = req.URL.Path[:slash], req.URL.Path[slash+1:]
+		if req.Header.Get("Content-Type") != fmt.Sprintf("application/x-%s-request", service) {
+			http.Error(w, "unexpected content type", http.StatusUnsupportedMediaType)
+			return
+		}
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	handler, ok := httpServices[service]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported service '%s'", service), http.StatusForbidden)
+		return
+	}
+
+	repo, err := resolveServedRepository(repoPath, s.opts.Root, s.opts.ExportAll)
+	if err != nil {
+		s.logger.Printf("[%s] %s", req.RemoteAddr, err)
+		http.NotFound(w, req)
+		return
+	}
+
+	if service == "git-receive-pack" && s.opts.Username == "" && !repo.Config().GetBool("http.receivepack") {
+		http.Error(w, "pushing requires authentication or http.receivepack", http.StatusForbidden)
+		return
+	}
+
+	body := io.Reader(req.Body)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, "malformed gzip request body", http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	header := w.Header()
+	header.Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
+	header.Set("Pragma", "no-cache")
+	header.Set("Expires", "Fri, 01 Jan 1980 00:00:00 GMT")
+
+	if advertiseOnly {
+		header.Set("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
+		enc := transport.NewEncoder(w)
+		if err := enc.Encodef("# service=%s\n", service); err != nil {
+			return
+		}
+		if err := enc.Flush(); err != nil {
+			return
+		}
+	} else {
+		header.Set("Content-Type", fmt.Sprintf("application/x-%s-result", service))
+	}
+
+	if err := handler(repo, body, w, advertiseOnly); err != nil {
+		s.logger.Printf("[%s] %s %s: %s", req.RemoteAddr, service, repoPath, err)
+	}
+}
+
+// authorized checks the request's basic auth credentials in constant time.
+func (s *httpServer) authorized(req *http.Request) bool {
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return false
+	}
+	userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(s.opts.Username))
+	passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(s.opts.Password))
+	return userMatch&passwordMatch == 1
+}
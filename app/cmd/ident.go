@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Roles of the identities recorded in commits and tags.
+const (
+	AuthorRole    = "author"
+	CommitterRole = "committer"
+)
+
+// String formats the signature as it is stored in commits and tags, e.g.
+// "A U Thor <author@example.com> 1700000000 +0100".
+func (s Signature) String() string {
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.When.Unix(), s.When.Format("-0700"))
+}
+
+// Ident returns the author or committer identity for new objects, resolved
+// like git's: GIT_AUTHOR_NAME and friends, then author.name or
+// committer.name, then user.name, from the repository config and then the
+// global config. The email falls back to $EMAIL and then user@hostname, the
+// date to now.
+//
+// Parameters:
+// - repo: The repository whose config is consulted. May be nil.
+// - role: AuthorRole or CommitterRole.
+//
+// Returns:
+// - The identity with its timestamp.
+// - An error if no name is configured or the date in the environment is invalid.
+func Ident(repo *GitRepository, role string) (Signature, error) {
+	env := "GIT_" + strings.ToUpper(role) + "_"
+	lookup := func(variable, key string) string {
+		if value := os.Getenv(env + variable); value != "" {
+			return value
+		}
+		if value := lookupConfig(repo, role+"."+key); value != "" {
+			return value
+		}
+		return lookupConfig(repo, "user."+key)
+	}
+
+	sig := Signature{Name: lookup("NAME", "name"), Email: lookup("EMAIL", "email"), When: time.Now()}
+	if sig.Name == "" {
+		return Signature{}, fmt.Errorf("%s identity unknown: set user.name and user.email", role)
+	}
+	if sig.Email == "" {
+		sig.Email = defaultEmail()
+	}
+	if date := os.Getenv(env + "DATE"); date != "" {
+		when, err := parseIdentDate(date)
+		if err != nil {
+			return Signature{}, err
+		}
+		sig.When = when
+	}
+	return sig, nil
+}
+
+// loginName is the name of the user running the program, standing in for a
+// name that is not configured where one is not strictly needed.
+func loginName() string {
+	if current, err := user.Current(); err == nil {
+		if current.Name != "" {
+			return current.Name
+		}
+		return current.Username
+	}
+	return "unknown"
+}
+
+// defaultEmail guesses an email address when none is configured: $EMAIL,
+// or the login name at the host name.
+func defaultEmail() string {
+	if email := os.Getenv("EMAIL"); email != "" {
+		return email
+	}
+	login := "unknown"
+	if current, err := user.Current(); err == nil {
+		login = current.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "(none)"
+	}
+	return login + "@" + host
+}
+
+// identDateLayouts are the readable date formats accepted in
+// GIT_AUTHOR_DATE and GIT_COMMITTER_DATE, besides git's own
+// "<seconds> <zone>".
+var identDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05 -0700",
+}
+
+// parseIdentDate parses a date given for a new signature.
+func parseIdentDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if fields := strings.Fields(strings.TrimPrefix(value, "@")); len(fields) == 2 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			sig := ParseSignature(fmt.Sprintf("<> %d %s", seconds, fields[1]))
+			return sig.When, nil
+		}
+	}
+	for _, layout := range identDateLayouts {
+		if when, err := time.Parse(layout, value); err == nil {
+			return when, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date format: %s", value)
+}
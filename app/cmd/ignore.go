@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	GitIgnoreFile = ".gitignore"
+	// excludeFile holds repository-local ignore patterns that are not shared.
+	excludeFile = "info/exclude"
+)
+
+// ignoreRule is one pattern of a .gitignore or exclude file.
+type ignoreRule struct {
+	pattern  string
+	base     string // Directory of the file the rule came from, "" for the top.
+	negated  bool   // "!pattern" re-includes a path.
+	dirOnly  bool   // "pattern/" only matches directories.
+	anchored bool   // The pattern contains a slash and matches relative to base.
+}
+
+// IgnoreMatcher decides which untracked paths are ignored, following the
+// rules of .gitignore files: the last matching pattern wins, and patterns
+// from deeper directories are consulted after shallower ones.
+type IgnoreMatcher struct {
+	repo   *GitRepository
+	rules  []ignoreRule
+	loaded map[string]bool
+}
+
+// NewIgnoreMatcher creates a matcher loaded with $GIT_DIR/info/exclude and
+// the top-level .gitignore. Deeper .gitignore files are loaded on demand.
+func NewIgnoreMatcher(repo *GitRepository) *IgnoreMatcher {
+	matcher := &IgnoreMatcher{repo: repo, loaded: make(map[string]bool)}
+	matcher.addFile(createRepoPath(repo, filepath.FromSlash(excludeFile)), "")
+	matcher.loadDir("")
+	return matcher
+}
+
+// loadDir reads the .gitignore of a worktree directory once.
+func (m *IgnoreMatcher) loadDir(dir string) {
+	if m.loaded[dir] {
+		return
+	}
+	m.loaded[dir] = true
+	m.addFile(filepath.Join(m.repo.WorkTree, filepath.FromSlash(dir), GitIgnoreFile), dir)
+}
+
+// addFile parses an ignore file whose patterns are relative to base.
+func (m *IgnoreMatcher) addFile(file, base string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if line[0] == '!' {
+			rule.negated, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored, line = true, strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// Ignored reports whether a worktree path, relative to the top of the
+// worktree and using forward slashes, is ignored. The .gitignore files of
+// its parent directories are loaded as needed. A nil matcher ignores
+// nothing.
+func (m *IgnoreMatcher) Ignored(name string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	// Parents are loaded before children so deeper rules take precedence.
+	var dirs []string
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		m.loadDir(dirs[i])
+	}
+
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		rule := m.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		rel := name
+		if rule.base != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(name, rule.base+"/"); !ok {
+				continue
+			}
+		}
+
+		target := rel
+		if !rule.anchored {
+			target = path.Base(rel)
+		}
+		if wildmatch(rule.pattern, target, true) {
+			return !rule.negated
+		}
+	}
+	return false
+}
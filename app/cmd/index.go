@@ -0,0 +1,553 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const IndexFile = "index"
+
+const indexFileSignature = "DIRC"
+
+// Flags stored in the 16 bit flags field of an index entry.
+const (
+	IndexFlagAssumeValid = 0x8000
+	IndexFlagExtended    = 0x4000
+	IndexFlagStageMask   = 0x3000
+	IndexFlagNameMask    = 0x0fff
+)
+
+// Flags stored in the extended flags field of version 3+ index entries.
+const (
+	IndexFlagSkipWorktree = 0x4000
+	IndexFlagIntentToAdd  = 0x2000
+)
+
+// IndexEntry is a single file tracked by the index, with the stat data used
+// to detect changes in the working tree.
+type IndexEntry struct {
+	CTime         time.Time
+	MTime         time.Time
+	Dev           uint32
+	Ino           uint32
+	Mode          uint32
+	UID           uint32
+	GID           uint32
+	Size          uint32
+	SHA           string
+	Flags         uint16
+	ExtendedFlags uint16
+	Name          string
+}
+
+// Stage returns the merge stage of the entry; 0 for normal entries.
+func (e *IndexEntry) Stage() int {
+	return int(e.Flags&IndexFlagStageMask) >> 12
+}
+
+// AssumeUnchanged reports whether the entry is marked assume-unchanged:
+// its worktree file is taken to match the index without being looked at.
+func (e *IndexEntry) AssumeUnchanged() bool {
+	return e.Flags&IndexFlagAssumeValid != 0
+}
+
+// SkipWorktree reports whether the entry is marked skip-worktree: the
+// index is the only copy that counts, whatever is or is not in the
+// worktree at its path.
+func (e *IndexEntry) SkipWorktree() bool {
+	return e.ExtendedFlags&IndexFlagSkipWorktree != 0
+}
+
+// TreeEntry returns the mode and SHA-1 of the entry as a tree entry would
+// record them.
+func (e *IndexEntry) TreeEntry() TreeEntry {
+	return TreeEntry{Mode: indexModeString(e.Mode), SHA: e.SHA}
+}
+
+// IndexExtension is an optional extension block of the index, kept verbatim.
+type IndexExtension struct {
+	Signature string
+	Data      []byte
+}
+
+// Index is the parsed content of .git/index.
+type Index struct {
+	Version    uint32
+	Entries    []*IndexEntry // Sorted by path, then by stage, as git writes them.
+	Extensions []IndexExtension
+	MTime      time.Time // When the index file was written; zero if there is none.
+}
+
+// IsRacy reports whether an entry is racily clean: its file was modified no
+// earlier than the index was written, so it may have changed again within
+// the timestamp granularity without its stat data showing it. The content
+// of such entries must be compared even when the stat data matches.
+func (index *Index) IsRacy(entry *IndexEntry) bool {
+	return isRacyTimestamp(entry, index.MTime)
+}
+
+// isRacyTimestamp reports whether an entry was modified at or after the
+// time an index was written.
+func isRacyTimestamp(entry *IndexEntry, written time.Time) bool {
+	return !written.IsZero() && !entry.MTime.Before(written)
+}
+
+// ReadIndex reads the index of the repository. A repository without an index
+// file has an empty index.
+//
+// Parameters:
+// - repo: The repository whose index should be read.
+//
+// Returns:
+// - A pointer to the parsed Index.
+// - An error if the file is corrupt or uses an unsupported version.
+func ReadIndex(repo *GitRepository) (*Index, error) {
+	file := createRepoPath(repo, IndexFile)
+	data, err := readFile(repo.fs, file)
+	if os.IsNotExist(err) {
+		return &Index{Version: 2}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := parseIndex(data)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := repo.fs.Stat(file); err == nil {
+		index.MTime = info.ModTime()
+	}
+	return index, nil
+}
+
+// parseIndex decodes an index file of version 2, 3 or 4.
+func parseIndex(data []byte) (*Index, error) {
+	if len(data) < 12+20 || string(data[:4]) != indexFileSignature {
+		return nil, fmt.Errorf("index file is corrupt: bad signature")
+	}
+
+	body, checksum := data[:len(data)-20], data[len(data)-20:]
+	if sum := sha1.Sum(body); !bytes.Equal(sum[:], checksum) {
+		return nil, fmt.Errorf("index file is corrupt: bad checksum")
+	}
+
+	index := &Index{Version: binary.BigEndian.Uint32(data[4:8])}
+	if index.Version < 2 || index.Version > 4 {
+		return nil, fmt.Errorf("unsupported index version %d", index.Version)
+	}
+
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+	pos := 12
+	previousName := ""
+
+	for i := 0; i < count; i++ {
+		start := pos
+		if pos+62 > len(body) {
+			return nil, fmt.Errorf("index file is corrupt: truncated entry %d", i)
+		}
+
+		field := func(offset int) uint32 {
+			return binary.BigEndian.Uint32(body[start+offset:])
+		}
+		entry := &IndexEntry{
+			CTime: time.Unix(int64(field(0)), int64(field(4))),
+			MTime: time.Unix(int64(field(8)), int64(field(12))),
+			Dev:   field(16),
+			Ino:   field(20),
+			Mode:  field(24),
+			UID:   field(28),
+			GID:   field(32),
+			Size:  field(36),
+			SHA:   hex.EncodeToString(body[start+40 : start+60]),
+			Flags: binary.BigEndian.Uint16(body[start+60:]),
+		}
+		pos += 62
+
+		if entry.Flags&IndexFlagExtended != 0 {
+			if index.Version < 3 || pos+2 > len(body) {
+				return nil, fmt.Errorf("index file is corrupt: unexpected extended flags")
+			}
+			entry.ExtendedFlags = binary.BigEndian.Uint16(body[pos:])
+			pos += 2
+		}
+
+		if index.Version == 4 {
+			strip, n := readIndexVarint(body[pos:])
+			if n == 0 || strip > len(previousName) {
+				return nil, fmt.Errorf("index file is corrupt: bad path prefix in entry %d", i)
+			}
+			pos += n
+
+			nul := bytes.IndexByte(body[pos:], 0)
+			if nul < 0 {
+				return nil, fmt.Errorf("index file is corrupt: unterminated path in entry %d", i)
+			}
+			entry.Name = previousName[:len(previousName)-strip] + string(body[pos:pos+nul])
+			pos += nul + 1
+		} else {
+			nul := bytes.IndexByte(body[pos:], 0)
+			if nul < 0 {
+				return nil, fmt.Errorf("index file is corrupt: unterminated path in entry %d", i)
+			}
+			entry.Name = string(body[pos : pos+nul])
+
+			// Entries are padded with NULs to a multiple of eight bytes.
+			entryLen := pos + nul - start
+			pos = start + (entryLen+8)&^7
+		}
+
+		previousName = entry.Name
+		index.Entries = append(index.Entries, entry)
+	}
+
+	for pos+8 <= len(body) {
+		signature := string(body[pos : pos+4])
+		size := int(binary.BigEndian.Uint32(body[pos+4:]))
+		pos += 8
+		if pos+size > len(body) {
+			return nil, fmt.Errorf("index file is corrupt: truncated extension '%s'", signature)
+		}
+
+		index.Extensions = append(index.Extensions, IndexExtension{
+			Signature: signature,
+			Data:      body[pos : pos+size],
+		})
+		pos += size
+	}
+
+	return index, nil
+}
+
+// staleIndexExtensions are the caches of the index that describe its
+// content and would be wrong once entries change. They are dropped on
+// write; git rebuilds them when it needs them.
+var staleIndexExtensions = []string{"TREE", "UNTR", "FSMN"}
+
+// Entry returns the stage 0 entry for path, or nil if it is not tracked.
+func (index *Index) Entry(path string) *IndexEntry {
+	if i, j := index.find(path); i < j && index.Entries[i].Stage() == 0 {
+		return index.Entries[i]
+	}
+	return nil
+}
+
+// Stages returns the entries for path indexed by merge stage: 1 for the
+// common ancestor, 2 for ours and 3 for theirs. Missing stages are nil, and
+// so is stage 0 for a path with a conflict.
+func (index *Index) Stages(path string) [4]*IndexEntry {
+	var stages [4]*IndexEntry
+	i, j := index.find(path)
+	for _, entry := range index.Entries[i:j] {
+		stages[entry.Stage()] = entry
+	}
+	return stages
+}
+
+// Remove drops every entry for path, at all stages.
+//
+// Returns:
+// - Whether an entry was removed.
+func (index *Index) Remove(path string) bool {
+	i, j := index.find(path)
+	index.Entries = slices.Delete(index.Entries, i, j)
+	return i < j
+}
+
+// Add puts a stage 0 entry into the index, replacing every entry for the
+// same path, including unmerged ones.
+func (index *Index) Add(entry *IndexEntry) {
+	i, j := index.find(entry.Name)
+	index.Entries = slices.Replace(index.Entries, i, j, entry)
+}
+
+// insert puts an entry into the index at its place, next to the entries for
+// the same path at other stages.
+func (index *Index) insert(entry *IndexEntry) {
+	i, _ := slices.BinarySearchFunc(index.Entries, entry, compareIndexEntries)
+	index.Entries = slices.Insert(index.Entries, i, entry)
+}
+
+// find returns the range of entries for path, at all stages. The entries
+// are sorted, so they are next to each other and found by binary search.
+func (index *Index) find(path string) (int, int) {
+	i, _ := slices.BinarySearchFunc(index.Entries, path, func(entry *IndexEntry, path string) int {
+		return strings.Compare(entry.Name, path)
+	})
+	j := i
+	for j < len(index.Entries) && index.Entries[j].Name == path {
+		j++
+	}
+	return i, j
+}
+
+// compareIndexEntries orders entries like git: by path, then by stage.
+func compareIndexEntries(a, b *IndexEntry) int {
+	if c := strings.Compare(a.Name, b.Name); c != 0 {
+		return c
+	}
+	return a.Stage() - b.Stage()
+}
+
+// NewIndexEntry creates a stage 0 entry for a file, with the stat data
+// used to notice later changes to it.
+//
+// Parameters:
+// - name: The slash separated path of the file in the worktree.
+// - mode: The tree mode of the file, e.g. ModeBlob.
+// - sha: The blob, or commit for a submodule, that is staged.
+// - info: The stat data of the worktree file.
+//
+// Returns:
+// - The entry.
+func NewIndexEntry(name, mode, sha string, info os.FileInfo) *IndexEntry {
+	entry := &IndexEntry{
+		MTime: info.ModTime(),
+		CTime: info.ModTime(),
+		Size:  uint32(info.Size()),
+		SHA:   sha,
+		Flags: uint16(min(len(name), IndexFlagNameMask)),
+		Name:  name,
+	}
+	fmt.Sscanf(mode, "%o", &entry.Mode)
+	fillStatData(entry, info)
+	return entry
+}
+
+// IndexLock is index.lock, held from before the index is read until it is
+// written back, as git does, so that two processes updating the index
+// cannot both start from the same content and one lose the other's
+// changes: the second one fails to take the lock instead.
+type IndexLock struct {
+	repo *GitRepository
+	path string // The path of the index; the lock is path+lockSuffix.
+	file File   // Nil once the lock is written or released.
+}
+
+// LockIndex takes index.lock, then reads the index under it.
+//
+// Parameters:
+// - repo: The repository whose index is to be updated.
+//
+// Returns:
+// - The held lock, which must be written or released.
+// - The index.
+// - An error if the index is locked or cannot be read.
+func LockIndex(repo *GitRepository) (*IndexLock, *Index, error) {
+	lock, err := lockIndex(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	index, err := ReadIndex(repo)
+	if err != nil {
+		lock.Release()
+		return nil, nil, err
+	}
+	return lock, index, nil
+}
+
+// lockIndex creates index.lock exclusively.
+func lockIndex(repo *GitRepository) (*IndexLock, error) {
+	path := createRepoPath(repo, IndexFile)
+	file, err := repo.fs.OpenFile(path+lockSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("unable to create '%s': another process seems to be running; remove the file if it crashed", path+lockSuffix)
+		}
+		return nil, err
+	}
+	return &IndexLock{repo: repo, path: path, file: file}, nil
+}
+
+// WriteIndex writes an index built from scratch, which nothing was read
+// for, through index.lock; an index that was read and changed is written
+// through the IndexLock taken before reading it.
+//
+// Returns:
+// - An error if the index is locked or cannot be written.
+func WriteIndex(repo *GitRepository, index *Index) error {
+	lock, err := lockIndex(repo)
+	if err != nil {
+		return err
+	}
+	return lock.Write(index)
+}
+
+// Write writes the index into the lock and renames it over the index,
+// releasing the lock. Entries are sorted and racily clean entries are
+// smudged first; see SmudgeRacyEntries. The index is synced to disk as
+// core.fsync says, after the objects whose sync core.fsyncMethod=batch
+// deferred.
+//
+// Parameters:
+// - index: The index to write. Its MTime is updated.
+//
+// Returns:
+// - An error if the index cannot be written; the lock is released.
+func (l *IndexLock) Write(index *Index) error {
+	repo := l.repo
+	if l.file == nil {
+		return fmt.Errorf("index.lock is no longer held")
+	}
+	slices.SortStableFunc(index.Entries, compareIndexEntries)
+	var err error
+	if !repo.IsBare() {
+		err = SmudgeRacyEntries(repo, index, time.Now())
+	}
+	var data []byte
+	if err == nil {
+		data, err = encodeIndex(index)
+	}
+	if err == nil {
+		err = repo.syncPending()
+	}
+	policy := repo.fsyncPolicyOf(fsyncIndex)
+	if err == nil {
+		_, err = l.file.Write(data)
+	}
+	if err == nil && policy == fsyncNow {
+		err = syncFile(l.file)
+	}
+	if err == nil {
+		err = l.file.Close()
+		l.file = nil
+	}
+	if err == nil {
+		err = repo.fs.Rename(l.path+lockSuffix, l.path)
+	}
+	if err == nil && policy == fsyncNow {
+		err = syncDir(repo.fs, filepath.Dir(l.path))
+	}
+	if err != nil {
+		l.Release()
+		repo.fs.Remove(l.path + lockSuffix)
+		return err
+	}
+
+	if info, err := repo.fs.Stat(l.path); err == nil {
+		index.MTime = info.ModTime()
+	}
+	return adjustSharedPerm(repo, l.path)
+}
+
+// Release gives up the lock without writing the index. Releasing a lock
+// that was already written or released does nothing, so it can be
+// deferred right after LockIndex.
+func (l *IndexLock) Release() {
+	if l.file == nil {
+		return
+	}
+	l.file.Close()
+	l.file = nil
+	l.repo.fs.Remove(l.path + lockSuffix)
+}
+
+// encodeIndex serializes an index in its version, or version 3 when a
+// version 2 index gained extended flags.
+func encodeIndex(index *Index) ([]byte, error) {
+	version := max(index.Version, 2)
+	for _, entry := range index.Entries {
+		if entry.ExtendedFlags != 0 && version < 3 {
+			version = 3
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(indexFileSignature)
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(index.Entries)))
+
+	previousName := ""
+	for _, entry := range index.Entries {
+		sha, err := hex.DecodeString(entry.SHA)
+		if err != nil || len(sha) != 20 {
+			return nil, fmt.Errorf("invalid object name '%s' for '%s' in the index", entry.SHA, entry.Name)
+		}
+
+		flags := entry.Flags&^(IndexFlagNameMask|IndexFlagExtended) | uint16(min(len(entry.Name), IndexFlagNameMask))
+		if entry.ExtendedFlags != 0 {
+			flags |= IndexFlagExtended
+		}
+		start := buf.Len()
+		for _, field := range []uint32{
+			uint32(entry.CTime.Unix()), uint32(entry.CTime.Nanosecond()),
+			uint32(entry.MTime.Unix()), uint32(entry.MTime.Nanosecond()),
+			entry.Dev, entry.Ino, entry.Mode, entry.UID, entry.GID, entry.Size,
+		} {
+			binary.Write(&buf, binary.BigEndian, field)
+		}
+		buf.Write(sha)
+		binary.Write(&buf, binary.BigEndian, flags)
+		if entry.ExtendedFlags != 0 {
+			binary.Write(&buf, binary.BigEndian, entry.ExtendedFlags)
+		}
+
+		if version == 4 {
+			common := 0
+			for common < len(previousName) && common < len(entry.Name) && previousName[common] == entry.Name[common] {
+				common++
+			}
+			buf.Write(indexVarint(len(previousName) - common))
+			buf.WriteString(entry.Name[common:])
+			buf.WriteByte(0)
+		} else {
+			buf.WriteString(entry.Name)
+			// Pad with NULs, at least one, to a multiple of eight bytes.
+			buf.Write(make([]byte, 8-(buf.Len()-start)%8))
+		}
+		previousName = entry.Name
+	}
+
+	for _, extension := range index.Extensions {
+		if extension.Signature == "link" {
+			return nil, fmt.Errorf("writing a split index is not supported")
+		}
+		if slices.Contains(staleIndexExtensions, extension.Signature) {
+			continue
+		}
+		buf.WriteString(extension.Signature)
+		binary.Write(&buf, binary.BigEndian, uint32(len(extension.Data)))
+		buf.Write(extension.Data)
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes(), nil
+}
+
+// indexVarint encodes a number in the offset encoding read by
+// readIndexVarint.
+func indexVarint(value int) []byte {
+	encoded := []byte{byte(value & 0x7f)}
+	for value >>= 7; value > 0; value >>= 7 {
+		value--
+		encoded = append([]byte{byte(0x80 | value&0x7f)}, encoded...)
+	}
+	return encoded
+}
+
+// readIndexVarint decodes the offset encoding used for version 4 path prefixes.
+func readIndexVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+
+	value := int(data[0] & 0x7f)
+	i := 0
+	for data[i]&0x80 != 0 {
+		i++
+		if i >= len(data) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | int(data[i]&0x7f)
+	}
+	return value, i + 1
+}
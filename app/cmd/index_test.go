@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestLockIndexExcludesOtherWriters(t *testing.T) {
+	repo := newTestRepo(t)
+
+	lock, _, err := LockIndex(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LockIndex(repo); err == nil {
+		t.Fatal("LockIndex succeeded while index.lock was held")
+	}
+	if err := WriteIndex(repo, &Index{Version: 2}); err == nil {
+		t.Fatal("WriteIndex succeeded while index.lock was held")
+	}
+
+	lock.Release()
+	lock, _, err = LockIndex(repo)
+	if err != nil {
+		t.Fatalf("LockIndex after Release: %v", err)
+	}
+	if err := lock.Write(&Index{Version: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(createRepoPath(repo, IndexFile+lockSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("index.lock left behind after Write: %v", err)
+	}
+}
+
+// TestLockIndexParallelWriters stages a file from each of many goroutines.
+// Reading the index before taking the lock would let two of them start from
+// the same index, and the second write would drop the first one's file.
+func TestLockIndexParallelWriters(t *testing.T) {
+	repo := newTestRepo(t)
+	const writers = 16
+	for i := range writers {
+		writeWorktreeFile(t, repo, fmt.Sprintf("file%02d", i), fmt.Sprintf("content %d\n", i))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- retryLocked(func() error {
+				lock, index, err := LockIndex(repo)
+				if err != nil {
+					return err
+				}
+				if err := StagePath(repo, index, fmt.Sprintf("file%02d", i), io.Discard); err != nil {
+					lock.Release()
+					return err
+				}
+				return lock.Write(index)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	index, err := ReadIndex(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Entries) != writers {
+		t.Fatalf("index has %d entries, want %d", len(index.Entries), writers)
+	}
+	for i := range writers {
+		if index.Entry(fmt.Sprintf("file%02d", i)) == nil {
+			t.Errorf("file%02d is missing from the index", i)
+		}
+	}
+}
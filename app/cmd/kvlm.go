@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// KvlmField is a single header of a commit or tag object. Multi-line values
+// (such as gpgsig) are stored with their continuation lines joined by "\n".
+type KvlmField struct {
+	Key   string
+	Value []byte
+}
+
+// Kvlm ("key-value list with message") is the format shared by commit and tag
+// objects: a list of headers, a blank line and a free-form message. Headers
+// keep their original order so objects can be serialized byte for byte.
+type Kvlm struct {
+	Fields  []KvlmField
+	Message []byte
+}
+
+// ParseKvlm parses the content of a commit or tag object.
+//
+// Parameters:
+// - data: The raw object content.
+//
+// Returns:
+// - A pointer to the parsed Kvlm.
+// - An error if a header line is malformed.
+func ParseKvlm(data []byte) (*Kvlm, error) {
+	kvlm := &Kvlm{}
+	rest := data
+
+	for len(rest) > 0 {
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated header line '%s'", rest)
+		}
+		line := rest[:end]
+		rest = rest[end+1:]
+
+		if len(line) == 0 {
+			kvlm.Message = rest
+			return kvlm, nil
+		}
+
+		if line[0] == ' ' {
+			if len(kvlm.Fields) == 0 {
+				return nil, fmt.Errorf("continuation line without header")
+			}
+			last := &kvlm.Fields[len(kvlm.Fields)-1]
+			last.Value = append(append(last.Value, '\n'), line[1:]...)
+			continue
+		}
+
+		key, value, ok := bytes.Cut(line, []byte{' '})
+		if !ok {
+			return nil, fmt.Errorf("malformed header line '%s'", line)
+		}
+		kvlm.Fields = append(kvlm.Fields, KvlmField{Key: string(key), Value: append([]byte(nil), value...)})
+	}
+
+	return kvlm, nil
+}
+
+// Get returns the value of the first header with the given key, or nil.
+func (k *Kvlm) Get(key string) []byte {
+	for _, field := range k.Fields {
+		if field.Key == key {
+			return field.Value
+		}
+	}
+	return nil
+}
+
+// GetAll returns the values of every header with the given key, in order.
+func (k *Kvlm) GetAll(key string) [][]byte {
+	var values [][]byte
+	for _, field := range k.Fields {
+		if field.Key == key {
+			values = append(values, field.Value)
+		}
+	}
+	return values
+}
+
+// Add appends a header, after any with the same key, so repeated keys such
+// as parent keep the order they were added in.
+func (k *Kvlm) Add(key string, value []byte) {
+	k.Fields = append(k.Fields, KvlmField{Key: key, Value: value})
+}
+
+// Set replaces the value of the first header with the given key, keeping
+// its position, and removes any later ones. Without such a header it is
+// added at the end.
+func (k *Kvlm) Set(key string, value []byte) {
+	for i, field := range k.Fields {
+		if field.Key == key {
+			k.Fields[i].Value = value
+			k.Fields = append(k.Fields[:i+1], deleteKvlmFields(k.Fields[i+1:], key)...)
+			return
+		}
+	}
+	k.Add(key, value)
+}
+
+// Delete removes every header with the given key.
+func (k *Kvlm) Delete(key string) {
+	k.Fields = deleteKvlmFields(k.Fields, key)
+}
+
+// deleteKvlmFields filters out the fields with the given key in place.
+func deleteKvlmFields(fields []KvlmField, key string) []KvlmField {
+	kept := fields[:0]
+	for _, field := range fields {
+		if field.Key != key {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// Clone returns a deep copy that can be edited without affecting k.
+func (k *Kvlm) Clone() *Kvlm {
+	clone := &Kvlm{Fields: make([]KvlmField, len(k.Fields)), Message: bytes.Clone(k.Message)}
+	for i, field := range k.Fields {
+		clone.Fields[i] = KvlmField{Key: field.Key, Value: bytes.Clone(field.Value)}
+	}
+	return clone
+}
+
+// Equal reports whether k and other have the same headers in the same
+// order and the same message, i.e. whether they serialize identically.
+func (k *Kvlm) Equal(other *Kvlm) bool {
+	if len(k.Fields) != len(other.Fields) || !bytes.Equal(k.Message, other.Message) {
+		return false
+	}
+	for i, field := range k.Fields {
+		if field.Key != other.Fields[i].Key || !bytes.Equal(field.Value, other.Fields[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Serialize encodes the headers and message back into object content.
+func (k *Kvlm) Serialize() []byte {
+	var buf bytes.Buffer
+	for _, field := range k.Fields {
+		buf.WriteString(field.Key)
+		buf.WriteByte(' ')
+		buf.Write(bytes.ReplaceAll(field.Value, []byte("\n"), []byte("\n ")))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	buf.Write(k.Message)
+	return buf.Bytes()
+}
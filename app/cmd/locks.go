@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lockSuffix = ".lock"
+	GcPidFile  = "gc.pid" // Held by a running gc, as "<pid> <hostname>".
+)
+
+// DefaultStaleLockExpiry is how old a lock must be before it is considered
+// abandoned, in the format of ParseExpiry.
+const DefaultStaleLockExpiry = "1.hour.ago"
+
+// LockFile is a lock found in the repository, such as index.lock or the
+// lock of a ref, left by a process that is writing the locked file.
+type LockFile struct {
+	Path    string    // Relative to the git directory, with forward slashes.
+	ModTime time.Time // When the lock was last written.
+	PID     int       // The process holding the lock, 0 if it is not recorded.
+	Alive   bool      // The holding process is known to still be running.
+}
+
+// Stale reports whether the lock looks abandoned: it was last written
+// before cutoff and no running process is known to hold it. Lock files do
+// not record who created them, so for most locks only the age counts.
+func (l LockFile) Stale(cutoff time.Time) bool {
+	return !l.Alive && l.ModTime.Before(cutoff)
+}
+
+// FindLocks lists the lock files in the git directory: every "*.lock" file
+// outside the object database, and gc.pid. Crashed processes leave these
+// behind, after which git and justdoit refuse to touch the locked files.
+//
+// Returns:
+// - The locks, sorted by path.
+// - An error if the git directory cannot be walked.
+func FindLocks(repo *GitRepository) ([]LockFile, error) {
+	objectsDir := createRepoPath(repo, ObjectsDir)
+	var locks []LockFile
+
+	err := walkDir(repo.fs, repo.GitDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if path == objectsDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(entry.Name(), lockSuffix) && entry.Name() != GcPidFile {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(repo.GitDir, path)
+		if err != nil {
+			return err
+		}
+
+		lock := LockFile{Path: filepath.ToSlash(rel), ModTime: info.ModTime()}
+		if entry.Name() == GcPidFile {
+			lock.PID, lock.Alive = gcPidOwner(repo.fs, path)
+		}
+		locks = append(locks, lock)
+		return nil
+	})
+	return locks, err
+}
+
+// StaleLocks returns the locks that are stale according to cutoff.
+func StaleLocks(repo *GitRepository, cutoff time.Time) ([]LockFile, error) {
+	locks, err := FindLocks(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []LockFile
+	for _, lock := range locks {
+		if lock.Stale(cutoff) {
+			stale = append(stale, lock)
+		}
+	}
+	return stale, nil
+}
+
+// RemoveLock deletes a lock file found by FindLocks.
+func RemoveLock(repo *GitRepository, lock LockFile) error {
+	return repo.fs.Remove(createRepoPath(repo, filepath.FromSlash(lock.Path)))
+}
+
+// gcPidOwner reads the "<pid> <hostname>" of a gc.pid file. A process on
+// another host cannot be checked, so it is assumed to be alive.
+func gcPidOwner(fsys FileSystem, path string) (int, bool) {
+	data, err := readFile(fsys, path)
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+
+	if len(fields) > 1 {
+		if host, err := os.Hostname(); err == nil && host != fields[1] {
+			return pid, true
+		}
+	}
+	return pid, processAlive(pid)
+}
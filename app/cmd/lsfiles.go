@@ -0,0 +1,137 @@
+package cmd
+
+import "fmt"
+
+// Tags of git ls-files -t, which say why a file is listed.
+const (
+	LsFilesCached       = 'H'
+	LsFilesSkipWorktree = 'S'
+	LsFilesUnmerged     = 'M'
+	LsFilesDeleted      = 'R'
+	LsFilesModified     = 'C'
+)
+
+// LsFilesOptions selects the files LsFiles lists. With none of Cached,
+// Deleted and Modified set, the cached files are listed.
+type LsFilesOptions struct {
+	Cached   bool // Every file of the index, at every stage.
+	Deleted  bool // Files missing from the worktree.
+	Modified bool // Files whose worktree copy differs from the index, deleted ones included.
+}
+
+// LsFilesEntry is one line of LsFiles: an index entry and why it is
+// listed. An entry may be listed more than once, e.g. as cached and as
+// modified.
+type LsFilesEntry struct {
+	Tag   byte
+	Entry *IndexEntry
+}
+
+// LsFiles lists the files of the index, as git ls-files does. Entries
+// marked skip-worktree are tagged "S" instead of "H" and are never listed
+// as deleted or modified, nor are entries marked assume-unchanged listed
+// as modified.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - opts: Which files to list.
+//
+// Returns:
+// - The entries in index order, each cached line followed by the
+// deleted and modified lines of the same entry.
+// - An error if the index or a worktree file cannot be read.
+func LsFiles(repo *GitRepository, opts LsFilesOptions) ([]LsFilesEntry, error) {
+	if !opts.Deleted && !opts.Modified {
+		opts.Cached = true
+	}
+	index, err := ReadIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	var worktree map[string]TreeEntry
+	if opts.Deleted || opts.Modified {
+		if repo.IsBare() {
+			return nil, fmt.Errorf("this operation must be run in a work tree")
+		}
+		if worktree, err = WorktreeFiles(repo, index, NewEOLConverter(repo)); err != nil {
+			return nil, err
+		}
+	}
+
+	var listed []LsFilesEntry
+	for _, entry := range index.Entries {
+		if opts.Cached {
+			tag := byte(LsFilesCached)
+			switch {
+			case entry.Stage() != 0:
+				tag = LsFilesUnmerged
+			case entry.SkipWorktree():
+				tag = LsFilesSkipWorktree
+			}
+			listed = append(listed, LsFilesEntry{Tag: tag, Entry: entry})
+		}
+		if worktree == nil || entry.SkipWorktree() {
+			continue
+		}
+
+		// Unmerged entries are compared with the worktree file like the
+		// others, whose state WorktreeFiles leaves out.
+		current, ok := worktree[entry.Name]
+		if entry.Stage() != 0 {
+			ok = pathExists(OSFileSystem{}, worktreePath(repo, entry.Name))
+			current = entry.TreeEntry()
+		}
+		if !ok && opts.Deleted {
+			listed = append(listed, LsFilesEntry{Tag: LsFilesDeleted, Entry: entry})
+		}
+		if opts.Modified && (!ok || !sameTreeEntry(current, entry.TreeEntry())) {
+			listed = append(listed, LsFilesEntry{Tag: LsFilesModified, Entry: entry})
+		}
+	}
+	return listed, nil
+}
+
+// IndexEntryFlag is a flag of index entries that SetIndexEntryFlag sets.
+type IndexEntryFlag int
+
+const (
+	FlagAssumeUnchanged IndexEntryFlag = iota
+	FlagSkipWorktree
+)
+
+// SetIndexEntryFlag sets or clears a flag on the index entries of paths,
+// as the --[no-]assume-unchanged and --[no-]skip-worktree options of git
+// update-index do.
+//
+// Parameters:
+// - repo: The repository.
+// - paths: The slash separated paths of tracked files.
+// - flag: The flag to change.
+// - value: Whether to set or clear it.
+//
+// Returns:
+// - An error if a path is not in the index or the index cannot be written.
+func SetIndexEntryFlag(repo *GitRepository, paths []string, flag IndexEntryFlag, value bool) error {
+	lock, index, err := LockIndex(repo)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	for _, path := range paths {
+		entry := index.Entry(path)
+		if entry == nil {
+			return fmt.Errorf("unable to mark file %s", path)
+		}
+		switch {
+		case flag == FlagAssumeUnchanged && value:
+			entry.Flags |= IndexFlagAssumeValid
+		case flag == FlagAssumeUnchanged:
+			entry.Flags &^= IndexFlagAssumeValid
+		case value:
+			entry.ExtendedFlags |= IndexFlagSkipWorktree
+		default:
+			entry.ExtendedFlags &^= IndexFlagSkipWorktree
+		}
+	}
+	return lock.Write(index)
+}
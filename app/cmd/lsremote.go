@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// LsRemoteOptions selects which advertised refs LsRemote returns.
+type LsRemoteOptions struct {
+	Heads      bool     // Only refs/heads/*.
+	Tags       bool     // Only refs/tags/*.
+	RefsOnly   bool     // Leave out peeled tags and refs outside refs/, such as HEAD.
+	Patterns   []string // Keep refs whose name ends with one of these globs.
+	UploadPack string   // Program to run on the remote instead of git-upload-pack.
+}
+
+// ResolveRemoteURL returns the URL of a configured remote when name is one,
+// and name itself otherwise, rewritten by url.<base>.insteadOf.
+func ResolveRemoteURL(repo *GitRepository, name string) string {
+	if RemoteConfigured(repo, name) {
+		return rewriteURL(repo, repo.Config().GetString(configKey("remote", name, "url")))
+	}
+	return rewriteURL(repo, name)
+}
+
+// RemoteConfigured reports whether name is a remote with a URL in the
+// config of repo, which may be nil, rather than a URL or a path.
+func RemoteConfigured(repo *GitRepository, name string) bool {
+	return repo != nil && repo.Config().GetString(configKey("remote", name, "url")) != ""
+}
+
+// dialRemote connects to a service of the repository at url, with the
+// transport settings of the config of repo, which may be nil.
+//
+// Parameters:
+// - repo: The local repository, or nil outside a repository.
+// - url: The URL or path of the remote repository.
+// - service: "git-upload-pack" or "git-receive-pack".
+// - command: The program to run on the remote instead of service, if any.
+//
+// Returns:
+// - The open session.
+// - An error if the URL is invalid or the remote cannot be reached.
+func dialRemote(repo *GitRepository, url, service, command string) (*transport.Session, error) {
+	endpoint, err := transport.ParseEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	return transport.Dial(endpoint, service, transport.DialOptions{Command: command, HTTP: HTTPOptions(repo, url)})
+}
+
+// LsRemote lists the refs advertised by the repository at location without
+// fetching anything, so it works outside a repository.
+//
+// Parameters:
+// - repo: The current repository, whose config sets up the transport, or
+// nil outside a repository.
+// - location: The URL or path of the remote repository.
+// - opts: Filters for the refs returned.
+//
+// Returns:
+// - The matching refs in advertised order, peeled tags as "<tag>^{}".
+// - An error if the remote cannot be reached.
+func LsRemote(repo *GitRepository, location string, opts LsRemoteOptions) ([]transport.AdvertisedRef, error) {
+	session, err := dialRemote(repo, location, "git-upload-pack", opts.UploadPack)
+	if err != nil {
+		return nil, err
+	}
+	if !session.Stateless {
+		// An empty request tells upload-pack the client wants nothing.
+		_ = transport.NewEncoder(session).Flush()
+	}
+	if err := session.Close(); err != nil {
+		return nil, err
+	}
+
+	var refs []transport.AdvertisedRef
+	for _, ref := range session.Advertisement.Refs {
+		if lsRemoteMatches(ref.Name, opts) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// lsRemoteMatches applies the ls-remote filters to a ref name. Patterns are
+// matched against the tail of the name, so "main" matches refs/heads/main.
+func lsRemoteMatches(name string, opts LsRemoteOptions) bool {
+	if opts.RefsOnly && (strings.HasSuffix(name, "^{}") || !strings.HasPrefix(name, "refs/")) {
+		return false
+	}
+	if opts.Heads || opts.Tags {
+		isHead := opts.Heads && strings.HasPrefix(name, "refs/heads/")
+		isTag := opts.Tags && strings.HasPrefix(name, "refs/tags/")
+		if !isHead && !isTag {
+			return false
+		}
+	}
+
+	if len(opts.Patterns) == 0 {
+		return true
+	}
+	for _, pattern := range opts.Patterns {
+		if refTailMatches(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
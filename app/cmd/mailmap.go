@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// MailmapFile maps the names and emails recorded in commits to canonical
+// ones.
+const MailmapFile = ".mailmap"
+
+// mailmapIdent is a canonical name and email; either may be empty to keep
+// the recorded one.
+type mailmapIdent struct {
+	name  string
+	email string
+}
+
+// mailmapEntry holds the mappings of one recorded email: a replacement for
+// any name, and replacements for particular names.
+type mailmapEntry struct {
+	mailmapIdent
+	names map[string]mailmapIdent // By lowercased recorded name.
+}
+
+// Mailmap canonicalizes identities as git's mailmap does. Each line of a
+// mailmap file is one of
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+//
+// Recorded emails and names are matched ignoring case, and later lines
+// override earlier ones.
+type Mailmap struct {
+	entries map[string]*mailmapEntry // By lowercased recorded email.
+}
+
+// ReadMailmap loads the mailmap of a repository: .mailmap at the top of the
+// worktree, then the blob named by mailmap.blob ("HEAD:.mailmap" by default
+// in bare repositories), then the file named by mailmap.file. Sources that
+// do not exist are skipped.
+//
+// Returns:
+// - The mailmap, which may be empty.
+// - An error if a source exists but cannot be read.
+func ReadMailmap(repo *GitRepository) (*Mailmap, error) {
+	mailmap := &Mailmap{entries: make(map[string]*mailmapEntry)}
+
+	if repo.WorkTree != "" {
+		if err := mailmap.readFile(filepath.Join(repo.WorkTree, MailmapFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	blob := lookupConfig(repo, "mailmap.blob")
+	if blob == "" && repo.IsBare() {
+		blob = "HEAD:" + MailmapFile
+	}
+	if blob != "" {
+		data, err := readMailmapBlob(repo, blob)
+		if err != nil {
+			// Like git, a missing blob is no mailmap rather than an error.
+			trace.Log(trace.Object, "mailmap blob skipped", "blob", blob, "error", err)
+		} else {
+			mailmap.Parse(data)
+		}
+	}
+
+	if file := lookupConfig(repo, "mailmap.file"); file != "" {
+		if err := mailmap.readFile(expandHome(file)); err != nil {
+			return nil, err
+		}
+	}
+	return mailmap, nil
+}
+
+// readFile parses a mailmap file if it exists.
+func (m *Mailmap) readFile(file string) error {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read mailmap '%s': %w", file, err)
+	}
+	m.Parse(data)
+	return nil
+}
+
+// readMailmapBlob reads a blob named as "<rev>:<path>" or by its SHA-1.
+func readMailmapBlob(repo *GitRepository, spec string) ([]byte, error) {
+	objects := NewObjectManager(repo)
+	sha := spec
+	if rev, file, ok := strings.Cut(spec, ":"); ok {
+		tree, err := ResolveTree(repo, rev)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := objects.TreeEntryAt(tree, file)
+		if err != nil {
+			return nil, err
+		}
+		sha = entry.SHA
+	} else if resolved, err := ResolveRevision(repo, spec); err == nil {
+		sha = resolved
+	}
+
+	objType, data, err := objects.ReadObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != BlobType {
+		return nil, fmt.Errorf("mailmap is not a blob: %s", spec)
+	}
+	return data, nil
+}
+
+// Parse adds the lines of a mailmap file. Lines starting with '#' and lines
+// without an email are ignored.
+func (m *Mailmap) Parse(data []byte) {
+	if m.entries == nil {
+		m.entries = make(map[string]*mailmapEntry)
+	}
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	for lines.Scan() {
+		line := lines.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		properName, properEmail, rest, ok := parseMailmapIdent(line, false)
+		if !ok {
+			continue
+		}
+		commitName, commitEmail, _, ok := parseMailmapIdent(rest, true)
+		if !ok {
+			// A single ident maps the email to its name only.
+			commitName, commitEmail, properEmail = "", properEmail, ""
+		}
+		m.add(mailmapIdent{properName, properEmail}, commitName, commitEmail)
+	}
+}
+
+// parseMailmapIdent parses "Name <email>" at the start of s, where the name
+// may be empty.
+//
+// Returns:
+// - The trimmed name and the email.
+// - What follows the closing '>'.
+// - Whether an ident was found and, unless allowEmpty, its email is not empty.
+func parseMailmapIdent(s string, allowEmpty bool) (string, string, string, bool) {
+	left := strings.IndexByte(s, '<')
+	if left < 0 {
+		return "", "", "", false
+	}
+	right := strings.IndexByte(s[left+1:], '>')
+	if right < 0 || (right == 0 && !allowEmpty) {
+		return "", "", "", false
+	}
+	return strings.TrimSpace(s[:left]), s[left+1 : left+1+right], s[left+1+right+1:], true
+}
+
+// add records that commitName <commitEmail>, or any name with commitEmail
+// when commitName is empty, maps to proper.
+func (m *Mailmap) add(proper mailmapIdent, commitName, commitEmail string) {
+	key := strings.ToLower(commitEmail)
+	entry := m.entries[key]
+	if entry == nil {
+		entry = &mailmapEntry{names: make(map[string]mailmapIdent)}
+		m.entries[key] = entry
+	}
+	if commitName != "" {
+		entry.names[strings.ToLower(commitName)] = proper
+		return
+	}
+	if proper.name != "" {
+		entry.name = proper.name
+	}
+	if proper.email != "" {
+		entry.email = proper.email
+	}
+}
+
+// Map returns the canonical name and email of an identity. A mapping for
+// the name and email together wins over one for the email alone.
+//
+// Returns:
+// - The canonical name and email, or the given ones if nothing maps them.
+// - Whether a mapping applied.
+func (m *Mailmap) Map(name, email string) (string, string, bool) {
+	entry, ok := m.entries[strings.ToLower(email)]
+	if !ok {
+		return name, email, false
+	}
+	proper := entry.mailmapIdent
+	if byName, ok := entry.names[strings.ToLower(name)]; ok {
+		proper = byName
+	}
+	if proper.name == "" && proper.email == "" {
+		return name, email, false
+	}
+	if proper.name != "" {
+		name = proper.name
+	}
+	if proper.email != "" {
+		email = proper.email
+	}
+	return name, email, true
+}
+
+// MapContact maps a "Name <email>" or "<email>" contact, as check-mailmap
+// does.
+//
+// Returns:
+// - The canonical contact, without a name if it has none.
+// - An error if the contact has no "<email>".
+func (m *Mailmap) MapContact(contact string) (string, error) {
+	name, email, _, ok := parseMailmapIdent(contact, true)
+	if !ok {
+		return "", fmt.Errorf("unable to parse contact: %s", contact)
+	}
+	name, email, _ = m.Map(name, email)
+	if name == "" {
+		return "<" + email + ">", nil
+	}
+	return name + " <" + email + ">", nil
+}
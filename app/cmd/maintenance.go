@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// defaultLooseObjectsBatchSize bounds how many loose objects a single run of
+// the loose-objects task packs, matching git's default.
+const defaultLooseObjectsBatchSize = 50000
+
+// defaultPruneExpire is used by gc when gc.pruneExpire is not configured.
+const defaultPruneExpire = "2.weeks.ago"
+
+// defaultGCAuto is the number of loose objects above which automatic
+// maintenance runs gc when gc.auto is not configured, matching git's.
+const defaultGCAuto = 6700
+
+// MaintenanceTask is a unit of repository upkeep that can be run by
+// `maintenance run`. Tasks do a bounded amount of work so they can be
+// scheduled frequently by an external scheduler.
+type MaintenanceTask struct {
+	Name        string
+	Description string
+	Run         func(repo *GitRepository) (string, error)
+}
+
+var maintenanceTasks = []MaintenanceTask{
+	{
+		Name:        "gc",
+		Description: "Pack refs, prune old unreachable objects and pack the rest",
+		Run:         gcTask,
+	},
+	{
+		Name:        "commit-graph",
+		Description: "Write the commit-graph file for reachable commits",
+		Run:         commitGraphTask,
+	},
+	{
+		Name:        "loose-objects",
+		Description: "Remove packed loose objects and pack a batch of the rest",
+		Run:         looseObjectsTask,
+	},
+	{
+		Name:        "pack-refs",
+		Description: "Collect loose refs into the packed-refs file",
+		Run:         packRefsTask,
+	},
+}
+
+// MaintenanceTasks returns every known maintenance task.
+func MaintenanceTasks() []MaintenanceTask {
+	return maintenanceTasks
+}
+
+// FindMaintenanceTask looks up a maintenance task by name.
+func FindMaintenanceTask(name string) (MaintenanceTask, error) {
+	for _, task := range maintenanceTasks {
+		if task.Name == name {
+			return task, nil
+		}
+	}
+	return MaintenanceTask{}, fmt.Errorf("'%s' is not a valid task", name)
+}
+
+// DefaultMaintenanceTasks returns the tasks enabled through
+// maintenance.<task>.enabled. When none are configured, only gc runs.
+func DefaultMaintenanceTasks(repo *GitRepository) []MaintenanceTask {
+	var tasks []MaintenanceTask
+	for _, task := range maintenanceTasks {
+		if repo.Config().GetBool(configKey("maintenance", task.Name, "enabled")) {
+			tasks = append(tasks, task)
+		}
+	}
+
+	if len(tasks) == 0 {
+		gc, _ := FindMaintenanceTask("gc")
+		tasks = append(tasks, gc)
+	}
+	return tasks
+}
+
+// RunMaintenanceTask runs a single task and returns its summary.
+func RunMaintenanceTask(repo *GitRepository, task MaintenanceTask) (string, error) {
+	defer trace.Start(trace.Perf, "maintenance task", "task", task.Name)()
+
+	summary, err := task.Run(repo)
+	if err != nil {
+		return "", fmt.Errorf("task '%s' failed: %w", task.Name, err)
+	}
+	return summary, nil
+}
+
+// AutoMaintenance runs the gc task when the repository holds more loose
+// objects than gc.auto, as git does after commands that create objects.
+// Nothing is checked unless this process wrote loose objects, and a gc.auto
+// of 0 turns the check off. With maintenance.auto set to false the gc is
+// not run, only advised. If gc leaves too many loose objects, because they
+// are unreachable but too recent to prune, a warning says so.
+//
+// Parameters:
+// - repo: The repository, as used by the command that wrote the objects.
+// - out: Where notices are written, usually stderr.
+//
+// Returns:
+// - An error if the object database cannot be read or gc fails.
+func AutoMaintenance(repo *GitRepository, out io.Writer) error {
+	if repo.looseWritten.Load() == 0 {
+		return nil
+	}
+	limit := defaultGCAuto
+	if value, err := strconv.Atoi(lookupConfig(repo, "gc.auto")); err == nil {
+		limit = value
+	}
+	if limit <= 0 {
+		return nil
+	}
+	if tooMany, err := tooManyLooseObjects(repo, limit); err != nil || !tooMany {
+		return err
+	}
+
+	if enabled, err := strconv.ParseBool(lookupConfig(repo, "maintenance.auto")); err == nil && !enabled {
+		fmt.Fprintf(out, "hint: the repository has more than %d loose objects; run 'justdoit maintenance run --task=gc' to pack them\n", limit)
+		return nil
+	}
+
+	fmt.Fprintln(out, "Auto packing the repository for optimum performance.")
+	fmt.Fprintln(out, "See \"justdoit maintenance run --help\" for manual housekeeping.")
+	gc, _ := FindMaintenanceTask("gc")
+	if _, err := RunMaintenanceTask(repo, gc); err != nil {
+		return err
+	}
+	if tooMany, err := tooManyLooseObjects(repo, limit); err != nil || !tooMany {
+		return err
+	}
+	fmt.Fprintln(out, "warning: There are too many unreachable loose objects; run 'justdoit prune' to remove them.")
+	return nil
+}
+
+func packRefsTask(repo *GitRepository) (string, error) {
+	count, err := PackRefs(repo)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("packed %d refs", count), nil
+}
+
+func commitGraphTask(repo *GitRepository) (string, error) {
+	count, err := WriteCommitGraph(repo)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("wrote commit-graph with %d commits", count), nil
+}
+
+// looseObjectsTask first deletes loose objects that already live in a pack and
+// then packs at most maintenance.loose-objects.batchSize of the remaining
+// ones. The freshly packed objects are removed by the next run.
+func looseObjectsTask(repo *GitRepository) (string, error) {
+	batchSize := repo.Config().GetInt(configKey("maintenance", "loose-objects", "batchSize"))
+	if batchSize <= 0 {
+		batchSize = defaultLooseObjectsBatchSize
+	}
+
+	objects := NewObjectManager(repo)
+	removed, remaining, err := prunePacked(objects)
+	if err != nil {
+		return "", err
+	}
+
+	if len(remaining) > batchSize {
+		remaining = remaining[:batchSize]
+	}
+	if len(remaining) == 0 {
+		return fmt.Sprintf("removed %d packed loose objects", removed), nil
+	}
+
+	_, stats, err := objects.WritePack(remaining)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("removed %d packed loose objects, packed %d loose objects (%s)", removed, len(remaining), stats), nil
+}
+
+// gcTask packs refs, prunes unreachable loose objects older than
+// gc.pruneExpire (two weeks by default) and packs the reachable loose objects.
+// Recent unreachable objects stay loose so a later prune can still remove them.
+// Old entries of the rr-cache are removed as by rerere gc, and the temporary
+// refs of operations whose process died, which would keep their commits
+// forever.
+func gcTask(repo *GitRepository) (string, error) {
+	cutoff, err := ParseExpiry(DefaultStaleLockExpiry, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if _, err := RemoveStaleTempRefs(repo, cutoff); err != nil {
+		return "", err
+	}
+
+	refs, err := PackRefs(repo)
+	if err != nil {
+		return "", err
+	}
+
+	// Objects of a precious-objects repository are packed but never deleted.
+	precious := PreciousObjects(repo)
+	var pruned []PrunedObject
+	if !precious {
+		expireValue := repo.Config().GetString("gc.pruneExpire")
+		if expireValue == "" {
+			expireValue = defaultPruneExpire
+		}
+		expire, err := ParseExpiry(expireValue, time.Now())
+		if err != nil {
+			return "", err
+		}
+
+		if pruned, err = Prune(repo, PruneOptions{Expire: expire}); err != nil {
+			return "", err
+		}
+	}
+
+	objects := NewObjectManager(repo)
+	reachable, err := ReachableObjects(repo, objects)
+	if err != nil {
+		return "", err
+	}
+
+	loose, err := objects.LooseObjects()
+	if err != nil {
+		return "", err
+	}
+
+	var toPack []string
+	for _, sha := range loose {
+		if reachable[sha] {
+			toPack = append(toPack, sha)
+		}
+	}
+
+	packed := ""
+	if len(toPack) > 0 {
+		_, stats, err := objects.WritePack(toPack)
+		if err != nil {
+			return "", err
+		}
+		if !precious {
+			if _, _, err := prunePacked(objects); err != nil {
+				return "", err
+			}
+		}
+		packed = fmt.Sprintf(" (%s)", stats)
+	}
+	rerere, err := RerereGC(repo, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("packed %d refs and %d loose objects%s, pruned %d objects and %d recorded conflicts", refs, len(toPack), packed, len(pruned), rerere), nil
+}
+
+// prunePacked removes loose objects that are also stored in a pack.
+//
+// Returns:
+// - The number of loose objects removed.
+// - The loose objects that are not packed yet.
+// - An error if the object directory cannot be read or a file cannot be removed.
+func prunePacked(objects *ObjectManager) (int, []string, error) {
+	loose, err := objects.LooseObjects()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	removed := 0
+	var remaining []string
+	for _, sha := range loose {
+		if !objects.inPack(sha) {
+			remaining = append(remaining, sha)
+			continue
+		}
+
+		if err := objects.removeLooseObject(sha); err != nil {
+			return removed, nil, err
+		}
+		removed++
+	}
+	return removed, remaining, nil
+}
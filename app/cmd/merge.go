@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"bytes"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// Lines that surround the two sides of a conflict in a merged file.
+const (
+	conflictOursMarker   = "<<<<<<<"
+	conflictSepMarker    = "======="
+	conflictTheirsMarker = ">>>>>>>"
+)
+
+// MergeLabels name the sides of a merge in conflict markers, e.g. "HEAD"
+// and "1a2b3c4 (Add the parser)".
+type MergeLabels struct {
+	Ours   string
+	Theirs string
+}
+
+// mergeHunk replaces the base lines [start, end) with lines.
+type mergeHunk struct {
+	start, end int
+	lines      []string
+}
+
+// changeHunks lists how other changes base, in base order.
+func changeHunks(base, other []string) []mergeHunk {
+	ops := diffLines(base, other)
+	var hunks []mergeHunk
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		hunk := mergeHunk{start: ops[i].old, end: ops[i].old}
+		for ; i < len(ops) && ops[i].kind != ' '; i++ {
+			if ops[i].kind == '-' {
+				hunk.end = ops[i].old + 1
+			} else {
+				hunk.lines = append(hunk.lines, other[ops[i].new])
+			}
+		}
+		hunks = append(hunks, hunk)
+	}
+	return hunks
+}
+
+// applyHunks returns base[start:end] with the hunks, which lie within it,
+// applied.
+func applyHunks(base []string, hunks []mergeHunk, start, end int) []string {
+	var out []string
+	pos := start
+	for _, hunk := range hunks {
+		out = append(out, base[pos:hunk.start]...)
+		out = append(out, hunk.lines...)
+		pos = hunk.end
+	}
+	return append(out, base[pos:end]...)
+}
+
+// MergeContent merges the changes ours and theirs made to base line by
+// line, as git merge-file does. Changes to different parts of the file are
+// combined; where both sides changed the same or adjacent lines
+// differently, both versions are kept between conflict markers, leaving
+// out the lines they share at either end.
+//
+// Parameters:
+// - base: The common ancestor, empty when there is none.
+// - ours: Our version.
+// - theirs: Their version.
+// - labels: The names shown on the conflict markers.
+//
+// Returns:
+// - The merged content.
+// - Whether any conflict was left.
+func MergeContent(base, ours, theirs []byte, labels MergeLabels) ([]byte, bool) {
+	baseLines := splitLines(base)
+	oursHunks := changeHunks(baseLines, splitLines(ours))
+	theirsHunks := changeHunks(baseLines, splitLines(theirs))
+
+	var out bytes.Buffer
+	conflicted := false
+	pos, i, j := 0, 0, 0
+	for i < len(oursHunks) || j < len(theirsHunks) {
+		// Grow a region from the first hunk until no hunk of either side
+		// overlaps or touches it.
+		var start, end int
+		if j >= len(theirsHunks) || (i < len(oursHunks) && oursHunks[i].start <= theirsHunks[j].start) {
+			start, end = oursHunks[i].start, oursHunks[i].end
+		} else {
+			start, end = theirsHunks[j].start, theirsHunks[j].end
+		}
+		firstOurs, firstTheirs := i, j
+		for grown := true; grown; {
+			grown = false
+			for ; i < len(oursHunks) && oursHunks[i].start <= end; i++ {
+				end, grown = max(end, oursHunks[i].end), true
+			}
+			for ; j < len(theirsHunks) && theirsHunks[j].start <= end; j++ {
+				end, grown = max(end, theirsHunks[j].end), true
+			}
+		}
+
+		writeLines(&out, baseLines[pos:start])
+		pos = end
+		oursSide := applyHunks(baseLines, oursHunks[firstOurs:i], start, end)
+		theirsSide := applyHunks(baseLines, theirsHunks[firstTheirs:j], start, end)
+		switch {
+		case firstTheirs == j:
+			writeLines(&out, oursSide)
+		case firstOurs == i || slices.Equal(oursSide, theirsSide):
+			writeLines(&out, theirsSide)
+		default:
+			conflicted = true
+			writeConflict(&out, oursSide, theirsSide, labels)
+		}
+	}
+	writeLines(&out, baseLines[pos:])
+	return out.Bytes(), conflicted
+}
+
+// writeConflict writes both sides of a conflicting region between markers,
+// keeping the lines they start and end with in common outside of them.
+func writeConflict(out *bytes.Buffer, ours, theirs []string, labels MergeLabels) {
+	prefix := 0
+	for prefix < len(ours) && prefix < len(theirs) && ours[prefix] == theirs[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ours)-prefix && suffix < len(theirs)-prefix && ours[len(ours)-1-suffix] == theirs[len(theirs)-1-suffix] {
+		suffix++
+	}
+
+	writeLines(out, ours[:prefix])
+	out.WriteString(conflictOursMarker + " " + labels.Ours + "\n")
+	writeTerminatedLines(out, ours[prefix:len(ours)-suffix])
+	out.WriteString(conflictSepMarker + "\n")
+	writeTerminatedLines(out, theirs[prefix:len(theirs)-suffix])
+	out.WriteString(conflictTheirsMarker + " " + labels.Theirs + "\n")
+	writeLines(out, ours[len(ours)-suffix:])
+}
+
+// writeLines writes lines as they are.
+func writeLines(out *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// writeTerminatedLines writes lines, ending the last one with a newline
+// if it lacks one, so that a marker can follow.
+func writeTerminatedLines(out *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteByte('\n')
+		}
+	}
+}
+
+// MergeConflict is a path that a tree merge could not resolve. A side
+// that does not have the path is nil.
+type MergeConflict struct {
+	Path   string
+	Base   *TreeEntry
+	Ours   *TreeEntry
+	Theirs *TreeEntry
+}
+
+// TreeMergeResult is the outcome of MergeTrees.
+type TreeMergeResult struct {
+	// Files is the merged tree as a flat file set. A file whose contents
+	// conflict holds both versions between conflict markers; for other
+	// conflicts our side is kept when we have the path.
+	Files     map[string]TreeEntry
+	Conflicts []MergeConflict // Sorted by path.
+}
+
+// MergeTrees merges the changes two trees made to a common base tree, path
+// by path. A path changed on one side only takes that side; a path both
+// sides changed differently has its contents merged with MergeContent
+// when it is a text file on both sides, and is a conflict otherwise, as
+// is a file on one side where the other has a directory. Merged blobs are
+// written to the object store.
+//
+// Parameters:
+// - base: The tree of the common ancestor, or "" for none.
+// - ours: Our tree.
+// - theirs: Their tree.
+// - labels: The names shown on conflict markers.
+//
+// Returns:
+// - The merged files and the conflicts.
+// - An error if an object cannot be read or written.
+func (m *ObjectManager) MergeTrees(base, ours, theirs string, labels MergeLabels) (*TreeMergeResult, error) {
+	defer trace.Start(trace.Perf, "merge trees", "base", base, "ours", ours, "theirs", theirs)()
+
+	sides := make([]map[string]TreeEntry, 3)
+	for i, tree := range []string{base, ours, theirs} {
+		sides[i] = make(map[string]TreeEntry)
+		if tree == "" {
+			continue
+		}
+		files, err := m.FlattenTree(tree)
+		if err != nil {
+			return nil, err
+		}
+		sides[i] = files
+	}
+	baseFiles, oursFiles, theirsFiles := sides[0], sides[1], sides[2]
+
+	paths := make(map[string]bool)
+	for _, files := range sides {
+		for name := range files {
+			paths[name] = true
+		}
+	}
+
+	result := &TreeMergeResult{Files: make(map[string]TreeEntry)}
+	for name := range paths {
+		b, inBase := baseFiles[name]
+		o, inOurs := oursFiles[name]
+		t, inTheirs := theirsFiles[name]
+		same := func(a TreeEntry, inA bool, b TreeEntry, inB bool) bool {
+			return inA == inB && (!inA || sameTreeEntry(a, b))
+		}
+
+		switch {
+		case same(o, inOurs, t, inTheirs), same(b, inBase, t, inTheirs):
+			if inOurs {
+				result.Files[name] = o
+			}
+			continue
+		case same(b, inBase, o, inOurs):
+			if inTheirs {
+				result.Files[name] = t
+			}
+			continue
+		}
+
+		conflict := MergeConflict{Path: name}
+		if inBase {
+			conflict.Base = &b
+		}
+		if inOurs {
+			conflict.Ours = &o
+		}
+		if inTheirs {
+			conflict.Theirs = &t
+		}
+		merged, clean, err := m.mergeFile(conflict, labels)
+		if err != nil {
+			return nil, err
+		}
+		if merged != nil {
+			result.Files[name] = *merged
+		}
+		if !clean {
+			result.Conflicts = append(result.Conflicts, conflict)
+		}
+	}
+
+	// A file cannot stay where the other side put a directory.
+	for name := range result.Files {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			file, ok := result.Files[dir]
+			if !ok {
+				continue
+			}
+			conflict := MergeConflict{Path: dir, Ours: &file}
+			if _, ours := oursFiles[dir]; !ours {
+				conflict = MergeConflict{Path: dir, Theirs: &file}
+			}
+			if b, ok := baseFiles[dir]; ok {
+				conflict.Base = &b
+			}
+			if !slices.ContainsFunc(result.Conflicts, func(c MergeConflict) bool { return c.Path == dir }) {
+				result.Conflicts = append(result.Conflicts, conflict)
+			}
+		}
+	}
+	sort.Slice(result.Conflicts, func(i, j int) bool { return result.Conflicts[i].Path < result.Conflicts[j].Path })
+	return result, nil
+}
+
+// mergeFile merges a path that both sides changed differently.
+//
+// Returns:
+// - The merged entry, or nil when the path is gone from both sides.
+// - Whether the merge is clean.
+// - An error if a blob cannot be read or written.
+func (m *ObjectManager) mergeFile(conflict MergeConflict, labels MergeLabels) (*TreeEntry, bool, error) {
+	ours, theirs := conflict.Ours, conflict.Theirs
+	if ours == nil || theirs == nil {
+		// Modified on one side and deleted on the other.
+		if ours != nil {
+			return ours, false, nil
+		}
+		return theirs, false, nil
+	}
+	if modeKind(ours.Mode) != "file" || modeKind(theirs.Mode) != "file" || ours.Mode == ModeSymlink || theirs.Mode == ModeSymlink {
+		return ours, false, nil
+	}
+
+	mode, modeClean := ours.Mode, true
+	switch {
+	case ours.Mode == theirs.Mode:
+	case conflict.Base != nil && conflict.Base.Mode == ours.Mode:
+		mode = theirs.Mode
+	case conflict.Base == nil || conflict.Base.Mode != theirs.Mode:
+		modeClean = false
+	}
+
+	var contents [3][]byte
+	for i, entry := range []*TreeEntry{conflict.Base, ours, theirs} {
+		if entry == nil || modeKind(entry.Mode) != "file" || entry.Mode == ModeSymlink {
+			continue
+		}
+		_, data, err := m.ReadObject(entry.SHA)
+		if err != nil {
+			return nil, false, err
+		}
+		contents[i] = data
+	}
+	if ours.SHA == theirs.SHA {
+		return &TreeEntry{Mode: mode, SHA: ours.SHA}, modeClean, nil
+	}
+	if IsBinary(contents[0]) || IsBinary(contents[1]) || IsBinary(contents[2]) {
+		return ours, false, nil
+	}
+
+	merged, conflicted := MergeContent(contents[0], contents[1], contents[2], labels)
+	sha, err := m.WriteObject(BlobType, merged, true)
+	if err != nil {
+		return nil, false, err
+	}
+	return &TreeEntry{Mode: mode, SHA: sha}, modeClean && !conflicted, nil
+}
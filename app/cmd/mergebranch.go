@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// MergeOptions describes a merge of a commit into HEAD.
+type MergeOptions struct {
+	Rev      string // The commit to merge, as given; it names it in the message.
+	Message  []byte // The message of the merge commit; nil for MergeMessage.
+	Edit     bool   // Open the editor on the message before committing.
+	NoCommit bool   // Stop before committing, as if the merge had conflicts.
+	NoFF     bool   // Make a merge commit even when HEAD could fast-forward.
+	FFOnly   bool   // Refuse to merge unless HEAD can fast-forward.
+	Squash   bool   // Stage the changes for a commit of their own instead of merging.
+
+	// VerifySignatures refuses to merge a commit without a good
+	// signature; see MergeVerifySignatures for the default.
+	VerifySignatures bool
+
+	HookOutput io.Writer // Receives the output of the commit-msg hook; discarded when nil.
+}
+
+// MergeResult describes what Merge did. Unless UpToDate or FastForward is
+// set, the merge stopped before its commit when Commit is nil, because of
+// Conflicts or as asked.
+type MergeResult struct {
+	Head        string           // HEAD before the merge.
+	Merged      string           // The commit merged.
+	Signature   *SignatureStatus // The good signature of Merged, with VerifySignatures.
+	UpToDate    bool             // Merged was already reachable from HEAD.
+	FastForward bool             // HEAD was moved to Merged.
+	Conflicts   []MergeConflict  // Sorted by path.
+	Commit      *CommitResult    // The merge commit, when one was made.
+}
+
+// MergeVerifySignatures reports whether merges verify the signature of
+// the commit they merge by default, as merge.verifySignatures says.
+func MergeVerifySignatures(repo *GitRepository) bool {
+	return repo.Config().GetBool("merge.verifysignatures")
+}
+
+// VerifyMergeSignature checks that a commit about to be merged has a good
+// signature, as git merge --verify-signatures does.
+//
+// Returns:
+// - The status of the signature.
+// - An error if the commit is not signed, the signature is not good or it
+// cannot be checked.
+func VerifyMergeSignature(repo *GitRepository, objects *ObjectManager, commit *Commit) (*SignatureStatus, error) {
+	short := objects.ShortSHA(commit.SHA, 0)
+	payload, signature := commit.Signature()
+	if signature == nil {
+		return nil, fmt.Errorf("commit %s does not have a GPG signature", short)
+	}
+	status, err := VerifySignature(repo, payload, signature)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !status.Good && status.Signer == "":
+		return nil, fmt.Errorf("commit %s has a bad GPG signature", short)
+	case !status.Good:
+		return nil, fmt.Errorf("commit %s has a bad GPG signature allegedly by %s", short, status.Signer)
+	}
+	return status, nil
+}
+
+// Merge merges a commit into HEAD, as git merge does for a single commit.
+// When HEAD is an ancestor of the commit, the branch fast-forwards to it
+// unless NoFF is set. Otherwise the trees are merged with MergeTrees from
+// the first merge base, and the result is written to the index and the
+// worktree with MERGE_HEAD and MERGE_MSG recorded, so that the merge is
+// concluded by CreateCommit: right away when the trees merged cleanly, or
+// once the conflicts, staged as unmerged entries, are resolved. A squash
+// merge records no MERGE_HEAD and never commits; its message is written to
+// SQUASH_MSG instead.
+//
+// Like rebase, Merge refuses to run with local changes, so that they
+// cannot be mixed up with the result of the merge.
+//
+// Parameters:
+// - repo: A repository with a worktree and no local changes.
+// - opts: The commit to merge and how.
+//
+// Returns:
+// - What was done.
+// - An error if there are local changes or a merge in progress, the
+// revision is not a commit, the histories are unrelated, a fast-forward
+// was required but is not possible, the signature is not good, untracked
+// files are in the way or the commit cannot be made.
+func Merge(repo *GitRepository, opts MergeOptions) (*MergeResult, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	switch {
+	case opts.Squash && opts.NoFF:
+		return nil, fmt.Errorf("you cannot combine --squash with --no-ff")
+	case opts.NoFF && opts.FFOnly:
+		return nil, fmt.Errorf("options '--ff-only' and '--no-ff' cannot be used together")
+	}
+	defer trace.Start(trace.Perf, "merge", "rev", opts.Rev)()
+
+	if MergeInProgress(repo) {
+		return nil, fmt.Errorf("you have not concluded your merge (MERGE_HEAD exists)")
+	}
+	status, err := Status(repo)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(status.Unmerged) > 0:
+		return nil, fmt.Errorf("merging is not possible because you have unmerged files")
+	case len(status.Unstaged) > 0:
+		return nil, fmt.Errorf("cannot merge: you have unstaged changes")
+	case len(status.Staged) > 0:
+		return nil, fmt.Errorf("cannot merge: your index contains uncommitted changes")
+	}
+
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := ResolveRevision(repo, opts.Rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("%s - not something we can merge", opts.Rev)
+	}
+	objects := NewObjectManager(repo)
+	theirs, err := objects.ReadCommit(merged)
+	if err != nil {
+		return nil, err
+	}
+	result := &MergeResult{Head: head.SHA, Merged: merged}
+	if opts.VerifySignatures {
+		if result.Signature, err = VerifyMergeSignature(repo, objects, theirs); err != nil {
+			return nil, err
+		}
+	}
+
+	var bases []string
+	if head.SHA != "" {
+		if bases, err = objects.MergeBases(head.SHA, merged); err != nil {
+			return nil, err
+		}
+		if len(bases) == 0 {
+			return nil, fmt.Errorf("refusing to merge unrelated histories")
+		}
+	}
+	switch {
+	case head.SHA != "" && bases[0] == merged:
+		result.UpToDate = true
+		return result, nil
+	case head.SHA == "" || (bases[0] == head.SHA && !opts.NoFF && !opts.Squash):
+		return result, fastForwardMerge(repo, head, opts.Rev, result)
+	case opts.FFOnly:
+		return nil, fmt.Errorf("not possible to fast-forward, aborting")
+	}
+
+	base, err := objects.ReadCommit(bases[0])
+	if err != nil {
+		return nil, err
+	}
+	ours, err := objects.ReadCommit(head.SHA)
+	if err != nil {
+		return nil, err
+	}
+	labels := MergeLabels{Ours: HeadFile, Theirs: opts.Rev}
+	tree, err := objects.MergeTrees(base.Tree, ours.Tree, theirs.Tree, labels)
+	if err != nil {
+		return nil, err
+	}
+	result.Conflicts = tree.Conflicts
+	if err := applyMerge(repo, objects, ours.Tree, tree); err != nil {
+		return nil, err
+	}
+	if err := UpdateRef(repo, OrigHeadFile, head.SHA, ""); err != nil {
+		return nil, err
+	}
+
+	if opts.Squash {
+		if err := writeSquashMerge(repo, objects, opts.Message, merged, head.SHA); err != nil {
+			return nil, err
+		}
+	}
+	var message []byte
+	if !opts.Squash {
+		if message = opts.Message; message == nil {
+			message = MergeMessage([]MergeSource{DescribeMergeSource(repo, opts.Rev)}, head.Branch())
+		}
+	}
+	if len(result.Conflicts) > 0 {
+		commentChar, err := CommentChar(repo)
+		if err != nil {
+			return nil, err
+		}
+		paths := make([]string, len(result.Conflicts))
+		for i, conflict := range result.Conflicts {
+			paths[i] = conflict.Path
+		}
+		message = AppendConflictComments(message, paths, commentChar)
+	}
+	if opts.Squash {
+		if len(result.Conflicts) == 0 {
+			return result, nil
+		}
+		// MERGE_MSG only lists the conflicts, to follow SQUASH_MSG; see
+		// ReadMergeMessage.
+		return result, writeFileAtomic(repo.fs, createRepoPath(repo, MergeMsgFile), message, 0644)
+	}
+	if err := WriteMergeState(repo, []string{merged}, message, opts.NoFF); err != nil {
+		return nil, err
+	}
+	if len(result.Conflicts) > 0 || opts.NoCommit {
+		return result, nil
+	}
+
+	reflog := "merge " + opts.Rev + ": Merge made by the 'resolve' strategy."
+	result.Commit, err = CreateCommit(repo, CommitOptions{Message: message, Edit: opts.Edit, Reflog: reflog, HookOutput: opts.HookOutput})
+	return result, err
+}
+
+// writeSquashMerge writes SQUASH_MSG for a squash merge: the message
+// given, or by default the log of the commits squashed, those reachable
+// from merged but not from head.
+func writeSquashMerge(repo *GitRepository, objects *ObjectManager, message []byte, merged, head string) error {
+	if message == nil {
+		commits, err := objects.RevList([]string{merged}, []string{head}, RevListOptions{})
+		if err != nil {
+			return err
+		}
+		squashed := make([]*Commit, len(commits))
+		for i, sha := range commits {
+			if squashed[i], err = objects.ReadCommit(sha); err != nil {
+				return err
+			}
+		}
+		message = SquashMessage(squashed)
+	}
+	return WriteSquashMessage(repo, message)
+}
+
+// fastForwardMerge moves HEAD, and the branch it is on, to the merged
+// commit, checking out its files.
+func fastForwardMerge(repo *GitRepository, head Head, rev string, result *MergeResult) error {
+	checkout, err := Checkout(repo, result.Merged, CheckoutOptions{KeepHead: true})
+	if err != nil {
+		return err
+	}
+	if checkout.Refused() {
+		return fmt.Errorf("untracked working tree files would be overwritten by merge: %s", strings.Join(checkout.Untracked, ", "))
+	}
+	result.FastForward = true
+	if head.SHA != "" {
+		if err := UpdateRef(repo, OrigHeadFile, head.SHA, ""); err != nil {
+			return err
+		}
+	}
+	ref := head.Ref
+	if ref == "" {
+		ref = HeadFile
+	}
+	trace.Log(trace.Ref, "merge", "ref", ref, "from", head.SHA, "to", result.Merged)
+	return UpdateRef(repo, ref, result.Merged, "merge "+rev+": Fast-forward")
+}
+
+// applyMerge writes the result of a tree merge to the worktree and the
+// index, which match the tree of HEAD. Files merged cleanly are staged;
+// a conflicted path gets an unmerged entry for each side that has it,
+// and in the worktree the merged file with its conflict markers, or the
+// side that kept the file. Nothing is changed if an untracked file is in
+// the way.
+func applyMerge(repo *GitRepository, objects *ObjectManager, headTree string, merged *TreeMergeResult) error {
+	headFiles, err := objects.FlattenTree(headTree)
+	if err != nil {
+		return err
+	}
+	lock, index, err := LockIndex(repo)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	worktree := make(map[string]TreeEntry, len(merged.Files))
+	for name, entry := range merged.Files {
+		worktree[name] = entry
+	}
+	conflicted := make(map[string]bool, len(merged.Conflicts))
+	for _, conflict := range merged.Conflicts {
+		conflicted[conflict.Path] = true
+		if _, ok := worktree[conflict.Path]; !ok && conflict.Theirs != nil {
+			worktree[conflict.Path] = *conflict.Theirs
+		}
+	}
+
+	var updates, inTheWay []string
+	for name, entry := range worktree {
+		current, ok := headFiles[name]
+		if ok && sameTreeEntry(current, entry) {
+			continue
+		}
+		updates = append(updates, name)
+		if !ok && pathExists(OSFileSystem{}, worktreePath(repo, name)) {
+			inTheWay = append(inTheWay, name)
+		}
+	}
+	if len(inTheWay) > 0 {
+		sort.Strings(inTheWay)
+		return fmt.Errorf("untracked working tree files would be overwritten by merge: %s", strings.Join(inTheWay, ", "))
+	}
+
+	eol := NewEOLConverter(repo)
+	for name := range headFiles {
+		if _, ok := worktree[name]; !ok {
+			if err := removeWorktreeFile(repo, name); err != nil {
+				return err
+			}
+			index.Remove(name)
+		}
+	}
+	sort.Strings(updates)
+	for _, name := range updates {
+		entry := worktree[name]
+		info, err := checkoutFile(repo, objects, eol, name, entry)
+		if err != nil {
+			return err
+		}
+		if !conflicted[name] {
+			index.Add(NewIndexEntry(name, entry.Mode, entry.SHA, info))
+		}
+	}
+	for _, conflict := range merged.Conflicts {
+		index.Remove(conflict.Path)
+		for stage, side := range []*TreeEntry{conflict.Base, conflict.Ours, conflict.Theirs} {
+			if side == nil {
+				continue
+			}
+			entry := &IndexEntry{Name: conflict.Path, SHA: side.SHA}
+			entry.Flags = uint16(min(len(conflict.Path), IndexFlagNameMask)) | uint16(stage+1)<<12
+			fmt.Sscanf(side.Mode, "%o", &entry.Mode)
+			index.insert(entry)
+		}
+	}
+	return lock.Write(index)
+}
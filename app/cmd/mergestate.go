@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Files describing a merge in progress, relative to the git directory.
+// MERGE_HEAD lists the commits being merged into HEAD, one per line, and
+// MERGE_MSG the message the merge commit gets by default. They stay until
+// the merge is committed, after the conflicts are resolved, or aborted.
+// SQUASH_MSG is the default message of the commit that concludes a squash
+// merge, which records no MERGE_HEAD.
+const (
+	MergeHeadFile = "MERGE_HEAD"
+	MergeMsgFile  = "MERGE_MSG"
+	MergeModeFile = "MERGE_MODE"
+	SquashMsgFile = "SQUASH_MSG"
+	autoMergeFile = "AUTO_MERGE" // Written by git for the tree of the conflicted merge.
+)
+
+// Kinds of MergeSource, in the order the message of a merge lists them.
+const (
+	MergeSourceBranch       = "branch"
+	MergeSourceRemoteBranch = "remote-tracking branch"
+	MergeSourceTag          = "tag"
+	MergeSourceCommit       = "commit"
+)
+
+// mergeSourcePlurals are the kinds of MergeSource naming several of them.
+var mergeSourcePlurals = map[string]string{
+	MergeSourceBranch:       "branches",
+	MergeSourceRemoteBranch: "remote-tracking branches",
+	MergeSourceTag:          "tags",
+	MergeSourceCommit:       "commits",
+}
+
+// MergeSource is a commit being merged, as the message of the merge names
+// it.
+type MergeSource struct {
+	Kind      string // One of the MergeSource kinds, such as MergeSourceBranch.
+	Name      string // The branch or tag without its refs/ prefix, or the revision as given.
+	EarlyPart bool   // An ancestor of the branch was given, as "<branch>~<n>" or "<branch>^".
+}
+
+// DescribeMergeSource works out how the message of a merge names a
+// revision given to merge, as git does: a branch, remote-tracking branch
+// or tag by its short name, an ancestor of a branch as the early part of
+// the branch, and anything else as a commit named as given.
+func DescribeMergeSource(repo *GitRepository, rev string) MergeSource {
+	if ref, err := ExpandRefName(repo, rev); err == nil {
+		switch {
+		case strings.HasPrefix(ref, BranchesPrefix):
+			return MergeSource{Kind: MergeSourceBranch, Name: strings.TrimPrefix(ref, BranchesPrefix)}
+		case strings.HasPrefix(ref, "refs/remotes/"):
+			return MergeSource{Kind: MergeSourceRemoteBranch, Name: strings.TrimPrefix(ref, "refs/remotes/")}
+		case strings.HasPrefix(ref, "refs/tags/"):
+			return MergeSource{Kind: MergeSourceTag, Name: strings.TrimPrefix(ref, "refs/tags/")}
+		}
+	}
+	if base, early, ok := cutAncestrySuffix(rev); ok {
+		if ref, err := ExpandRefName(repo, base); err == nil && strings.HasPrefix(ref, BranchesPrefix) {
+			return MergeSource{Kind: MergeSourceBranch, Name: strings.TrimPrefix(ref, BranchesPrefix), EarlyPart: early}
+		}
+	}
+	return MergeSource{Kind: MergeSourceCommit, Name: rev}
+}
+
+// cutAncestrySuffix splits a revision of the form "<name>^..." or
+// "<name>~<n>" into the name and whether it names an ancestor, which
+// "<name>~0" does not.
+func cutAncestrySuffix(rev string) (string, bool, bool) {
+	if base := strings.TrimRight(rev, "^"); base != rev {
+		return base, true, base != ""
+	}
+	i := strings.LastIndexByte(rev, '~')
+	if i <= 0 || !isDecimal(rev[i+1:]) {
+		return "", false, false
+	}
+	return rev[:i], strings.Trim(rev[i+1:], "0") != "" || i == len(rev)-1, true
+}
+
+// isDecimal reports whether s consists only of decimal digits.
+func isDecimal(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+// MergeMessage builds the default message of a merge commit, as git
+// fmt-merge-msg does: e.g. "Merge branch 'topic' into next", or "Merge
+// branches 'a' and 'b', tag 'v1'" for an octopus. Like git by default, the
+// branch merged into is left out when it is main or master, or when HEAD
+// is detached.
+//
+// Parameters:
+// - sources: The commits merged, as DescribeMergeSource names them.
+// - into: The branch merged into, or "" for a detached HEAD.
+//
+// Returns:
+// - The message, a single line.
+func MergeMessage(sources []MergeSource, into string) []byte {
+	names := make(map[string][]string)
+	for _, source := range sources {
+		name := "'" + source.Name + "'"
+		if source.EarlyPart {
+			name += " (early part)"
+		}
+		names[source.Kind] = append(names[source.Kind], name)
+	}
+
+	var groups []string
+	for _, kind := range []string{MergeSourceBranch, MergeSourceRemoteBranch, MergeSourceTag} {
+		if len(names[kind]) > 0 {
+			groups = append(groups, joinMergeSources(kind, names[kind]))
+		}
+	}
+	title := "Merge " + strings.Join(groups, ", ")
+	if commits := names[MergeSourceCommit]; len(commits) > 0 {
+		if len(groups) > 0 {
+			title += "; "
+		}
+		title += joinMergeSources(MergeSourceCommit, commits)
+	}
+	if into != "" && into != "main" && into != "master" {
+		title += " into " + into
+	}
+	return []byte(title + "\n")
+}
+
+// joinMergeSources names the sources of one kind, e.g. "branches 'a', 'b'
+// and 'c'".
+func joinMergeSources(kind string, names []string) string {
+	if len(names) == 1 {
+		return kind + " " + names[0]
+	}
+	last := len(names) - 1
+	return mergeSourcePlurals[kind] + " " + strings.Join(names[:last], ", ") + " and " + names[last]
+}
+
+// AppendConflictComments lists the conflicted paths below a merge or
+// squash message, commented out, so that whoever edits the message can
+// describe how they were resolved. With --no-edit the list is kept, as in
+// git.
+func AppendConflictComments(message []byte, paths []string, commentChar string) []byte {
+	if len(paths) == 0 {
+		return message
+	}
+	var out bytes.Buffer
+	out.Write(message)
+	fmt.Fprintf(&out, "\n%s Conflicts:\n", commentChar)
+	for _, path := range paths {
+		fmt.Fprintf(&out, "%s\t%s\n", commentChar, path)
+	}
+	return out.Bytes()
+}
+
+// SquashMessage builds the default message of the commit that concludes a
+// squash merge: the log of the commits squashed, in git's medium format.
+//
+// Parameters:
+// - commits: The commits squashed, newest first.
+//
+// Returns:
+// - The message.
+func SquashMessage(commits []*Commit) []byte {
+	var out bytes.Buffer
+	out.WriteString("Squashed commit of the following:\n")
+	for _, commit := range commits {
+		author := ParseSignature(commit.Author)
+		fmt.Fprintf(&out, "\ncommit %s\n", commit.SHA)
+		fmt.Fprintf(&out, "Author: %s <%s>\n", author.Name, author.Email)
+		fmt.Fprintf(&out, "Date:   %s\n\n", author.When.Format(GitDateLayout))
+		for _, line := range strings.Split(strings.TrimRight(string(commit.Message), "\n"), "\n") {
+			fmt.Fprintf(&out, "    %s\n", line)
+		}
+	}
+	return out.Bytes()
+}
+
+// WriteMergeState records a merge that stopped before its commit, for
+// the conflicts to be resolved or the message edited: the commits merged
+// in MERGE_HEAD and the default message in MERGE_MSG. MERGE_MODE is
+// written too, holding "no-ff" when the merge was not to fast-forward.
+//
+// Parameters:
+// - repo: The repository.
+// - heads: The commits merged into HEAD.
+// - message: The message of the merge commit, see MergeMessage.
+// - noFF: The merge was asked not to fast-forward.
+//
+// Returns:
+// - An error if a file cannot be written.
+func WriteMergeState(repo *GitRepository, heads []string, message []byte, noFF bool) error {
+	mode := ""
+	if noFF {
+		mode = "no-ff"
+	}
+	files := []struct {
+		name string
+		data string
+	}{
+		{MergeHeadFile, strings.Join(heads, "\n") + "\n"},
+		{MergeMsgFile, string(message)},
+		{MergeModeFile, mode},
+	}
+	for _, file := range files {
+		if err := writeFileAtomic(repo.fs, createRepoPath(repo, file.name), []byte(file.data), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSquashMessage records the default message of the commit that
+// concludes a squash merge, see SquashMessage.
+func WriteSquashMessage(repo *GitRepository, message []byte) error {
+	return writeFileAtomic(repo.fs, createRepoPath(repo, SquashMsgFile), message, 0644)
+}
+
+// MergeInProgress reports whether a merge is waiting to be committed.
+func MergeInProgress(repo *GitRepository) bool {
+	return pathExists(repo.fs, createRepoPath(repo, MergeHeadFile))
+}
+
+// ReadMergeHeads reads the commits being merged into HEAD.
+//
+// Returns:
+// - The commits, in the order they are recorded as parents after HEAD, or
+// nil when no merge is in progress.
+// - An error if MERGE_HEAD cannot be read or does not hold object names.
+func ReadMergeHeads(repo *GitRepository) ([]string, error) {
+	data, err := readFile(repo.fs, createRepoPath(repo, MergeHeadFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var heads []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) != 40 || !isHex(line) {
+			return nil, fmt.Errorf("could not parse %s", MergeHeadFile)
+		}
+		heads = append(heads, line)
+	}
+	return heads, nil
+}
+
+// ReadMergeMessage reads the message prepared for the commit that
+// concludes a merge: SQUASH_MSG after a squash merge followed by MERGE_MSG,
+// which a squash merge only writes to list its conflicts. It returns nil
+// when there is neither.
+func ReadMergeMessage(repo *GitRepository) ([]byte, error) {
+	var message []byte
+	for _, name := range []string{SquashMsgFile, MergeMsgFile} {
+		data, err := readFile(repo.fs, createRepoPath(repo, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		message = append(message, data...)
+	}
+	return message, nil
+}
+
+// clearMergeState removes the files of a merge, or squash merge, in
+// progress once a commit has been made. MERGE_RR is left to rerere, which
+// still has to record the resolutions.
+func clearMergeState(repo *GitRepository) error {
+	for _, name := range []string{MergeHeadFile, MergeMsgFile, MergeModeFile, SquashMsgFile, autoMergeFile} {
+		if err := repo.fs.Remove(createRepoPath(repo, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultCommentChar starts the lines of a message that are comments, such
+// as the help text of an edited commit message.
+const DefaultCommentChar = "#"
+
+// CommentChar returns the comment character set with core.commentChar, or
+// "#". "auto" is treated as "#" here; only message templates pick a
+// character that the message does not already use.
+//
+// Parameters:
+// - repo: The repository, or nil to read only the global config.
+//
+// Returns:
+// - The comment character.
+// - An error if core.commentChar is longer than one character.
+func CommentChar(repo *GitRepository) (string, error) {
+	value := lookupConfig(repo, "core.commentchar")
+	switch {
+	case value == "" || strings.EqualFold(value, "auto"):
+		return DefaultCommentChar, nil
+	case utf8.RuneCountInString(value) != 1:
+		return "", fmt.Errorf("core.commentChar should only be one character")
+	}
+	return value, nil
+}
+
+// Stripspace cleans up a message the way git does before storing it:
+// trailing whitespace is removed from every line, runs of blank lines are
+// collapsed into one, blank lines at the start and end are dropped and the
+// last line is terminated with a newline.
+//
+// Parameters:
+// - message: The message.
+// - commentChar: When not empty, lines starting with it are removed too.
+//
+// Returns:
+// - The cleaned message; empty if nothing but blank lines and comments remain.
+func Stripspace(message []byte, commentChar string) []byte {
+	var out bytes.Buffer
+	empties := 0
+	for len(message) > 0 {
+		line, rest, _ := bytes.Cut(message, []byte{'\n'})
+		message = rest
+
+		if commentChar != "" && bytes.HasPrefix(line, []byte(commentChar)) {
+			continue
+		}
+		line = bytes.TrimRight(line, " \t\r\v\f")
+		if len(line) == 0 {
+			empties++
+			continue
+		}
+		if empties > 0 && out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		empties = 0
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// CommentLines turns every line of text into a comment, as for the help
+// text of a message template. Non-empty lines get the comment character
+// and a space, except before a tab; empty lines get the character alone.
+func CommentLines(text []byte, commentChar string) []byte {
+	var out bytes.Buffer
+	for len(text) > 0 {
+		line, rest, _ := bytes.Cut(text, []byte{'\n'})
+		text = rest
+
+		out.WriteString(commentChar)
+		if len(line) > 0 && line[0] != '\t' {
+			out.WriteByte(' ')
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// WrapMessage wraps the lines of a message that are longer than width at
+// spaces. Indented lines, such as code or quoted output, are left alone,
+// and so are words longer than width.
+//
+// Parameters:
+// - message: The message, e.g. after Stripspace.
+// - width: The maximum number of characters per line; 0 or less disables
+// wrapping.
+//
+// Returns:
+// - The wrapped message.
+func WrapMessage(message []byte, width int) []byte {
+	if width <= 0 {
+		return message
+	}
+
+	var out bytes.Buffer
+	for len(message) > 0 {
+		line, rest, found := bytes.Cut(message, []byte{'\n'})
+		message = rest
+
+		if utf8.RuneCount(line) <= width || line[0] == ' ' || line[0] == '\t' {
+			out.Write(line)
+		} else {
+			column := 0
+			for i, word := range strings.Fields(string(line)) {
+				size := utf8.RuneCountInString(word)
+				if i > 0 && column+1+size > width {
+					out.WriteByte('\n')
+					column = 0
+				} else if i > 0 {
+					out.WriteByte(' ')
+					column++
+				}
+				out.WriteString(word)
+				column += size
+			}
+		}
+		if found {
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	// NamespaceEnv selects the namespace served by upload-pack and
+	// receive-pack, like git's GIT_NAMESPACE. Nested namespaces are
+	// separated by slashes.
+	NamespaceEnv     = "GIT_NAMESPACE"
+	NamespacesPrefix = "refs/namespaces/"
+)
+
+// NamespacePrefix returns the ref prefix of the namespace in GIT_NAMESPACE,
+// e.g. "refs/namespaces/a/refs/namespaces/b/" for "a/b", or "" when no
+// namespace is set. Refs under the prefix look to clients like the refs of
+// a repository of their own, so one object database can back several
+// logical repositories.
+func NamespacePrefix() string {
+	var prefix strings.Builder
+	for _, component := range strings.Split(os.Getenv(NamespaceEnv), "/") {
+		if component != "" {
+			prefix.WriteString(NamespacesPrefix + component + "/")
+		}
+	}
+	return prefix.String()
+}
+
+// namespacedRefs lists the refs of the current namespace under the names
+// clients see, with the namespace prefix removed. Without a namespace it
+// lists every ref.
+func namespacedRefs(repo *GitRepository) ([]Ref, error) {
+	prefix := NamespacePrefix()
+	if prefix == "" {
+		return ListRefs(repo)
+	}
+	refs, err := Refs(repo, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Ref
+	for _, ref := range refs {
+		if name := strings.TrimPrefix(ref.Name, prefix); strings.HasPrefix(name, "refs/") {
+			ref.Name = name
+			result = append(result, ref)
+		}
+	}
+	return result, nil
+}
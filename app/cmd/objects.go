@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+const ObjectsDir = "objects"
+
+// GitObjectType is the type of object stored in the object database.
+type GitObjectType string
+
+const (
+	CommitType GitObjectType = "commit"
+	TreeType   GitObjectType = "tree"
+	BlobType   GitObjectType = "blob"
+	TagType    GitObjectType = "tag"
+)
+
+// ObjectManager reads and writes objects in the object database of a
+// repository, looking at loose objects first and then at pack files.
+//
+// Once set up, an ObjectManager is safe for concurrent use: objects are
+// written atomically under their final names, pack indexes are immutable
+// once loaded, and the lazily loaded state is guarded by mu. The setup
+// methods UseReplaceRefs, UseGrafts and AllowUnknownType are not, and must
+// be called before the manager is shared.
+type ObjectManager struct {
+	repo       *GitRepository
+	quarantine string // When set, new objects go to this directory, which is also searched first.
+
+	mu          sync.Mutex // Guards packs, packsLoaded, abbrev and promised.
+	packs       []*packFile
+	packsLoaded bool
+	abbrev      int             // Resolved core.abbrev, 0 until AbbrevLength is called.
+	promised    map[string]bool // Objects already asked of the promisor remote, see fetchPromised.
+
+	replacements map[string]string   // Replace refs honored by reads, see UseReplaceRefs.
+	grafts       map[string][]string // Effective parents of grafted commits, see UseGrafts.
+	unknownTypes bool                // Loose objects may have any type, see AllowUnknownType.
+}
+
+// NewObjectManager creates an ObjectManager for the given repository.
+func NewObjectManager(repo *GitRepository) *ObjectManager {
+	return &ObjectManager{repo: repo}
+}
+
+// parseObjectType converts the type name found in an object header into a
+// GitObjectType, which must be registered; see RegisterObjectType.
+func parseObjectType(name string) (GitObjectType, error) {
+	objType := GitObjectType(name)
+	if _, ok := LookupObjectType(objType); !ok {
+		return "", fmt.Errorf("unknown object type '%s'", name)
+	}
+	return objType, nil
+}
+
+// parseLooseObjectType parses the type in the header of a loose object.
+// With allowUnknown any type name is accepted, for tools that inspect
+// objects written with hash-object --literally.
+func parseLooseObjectType(name string, allowUnknown bool) (GitObjectType, error) {
+	if allowUnknown && name != "" {
+		return GitObjectType(name), nil
+	}
+	return parseObjectType(name)
+}
+
+// isValidSHA reports whether sha is a full 40 character hexadecimal object name.
+func isValidSHA(sha string) bool {
+	if len(sha) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(sha)
+	return err == nil
+}
+
+// encodeObject builds the canonical "<type> <size>\x00<data>" representation
+// of an object and returns it together with its SHA-1.
+//
+// Parameters:
+// - objType: The type of the object.
+// - data: The object content.
+//
+// Returns:
+// - The hex encoded SHA-1 of the object.
+// - The encoded object, ready to be compressed into a loose object.
+func encodeObject(objType GitObjectType, data []byte) (string, []byte) {
+	header := fmt.Sprintf("%s %d\x00", objType, len(data))
+	encoded := append([]byte(header), data...)
+	sum := sha1.Sum(encoded)
+	return hex.EncodeToString(sum[:]), encoded
+}
+
+// loosePath returns the path of the loose object file for sha in the
+// object database.
+func (m *ObjectManager) loosePath(sha string) string {
+	return createRepoPath(m.repo, ObjectsDir, sha[:2], sha[2:])
+}
+
+// objectDir returns the directory new objects are written to: the
+// quarantine if there is one, otherwise the object database.
+func (m *ObjectManager) objectDir() string {
+	if m.quarantine != "" {
+		return m.quarantine
+	}
+	return createRepoPath(m.repo, ObjectsDir)
+}
+
+// findLoose returns the path of the loose object file for sha, looking in
+// the quarantine before the object database.
+func (m *ObjectManager) findLoose(sha string) (string, bool) {
+	if m.quarantine != "" {
+		if path := filepath.Join(m.quarantine, sha[:2], sha[2:]); pathExists(m.repo.fs, path) {
+			return path, true
+		}
+	}
+	path := m.loosePath(sha)
+	return path, pathExists(m.repo.fs, path)
+}
+
+// WriteObject computes the SHA-1 of an object and, if changeRepo is set,
+// stores it as a loose object.
+//
+// Parameters:
+// - objType: The type of the object.
+// - data: The object content.
+// - changeRepo: Whether the object should actually be written to the repository.
+//
+// Returns:
+// - The hex encoded SHA-1 of the object.
+// - An error if the object could not be written.
+func (m *ObjectManager) WriteObject(objType GitObjectType, data []byte, changeRepo bool) (string, error) {
+	sha, encoded := encodeObject(objType, data)
+	if !changeRepo {
+		return sha, nil
+	}
+
+	if m.Has(sha) {
+		return sha, nil
+	}
+
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	if _, err := writer.Write(encoded); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(m.objectDir(), sha[:2], sha[2:])
+	if err := writeRepoFile(m.repo, fsyncLooseObject, path, compressed.Bytes(), 0444); err != nil {
+		return "", err
+	}
+	if err := adjustSharedPerm(m.repo, filepath.Dir(path), path); err != nil {
+		return "", err
+	}
+	m.repo.looseWritten.Add(1)
+
+	trace.Log(trace.Object, "write", "sha", sha, "type", objType, "size", len(data))
+	return sha, nil
+}
+
+// ReadObject reads the object with the given SHA-1 from loose storage or
+// from one of the pack files. If the manager honors replace refs, the
+// content of the replacement object is returned instead.
+//
+// Parameters:
+// - sha: The hex encoded SHA-1 of the object.
+//
+// Returns:
+// - The type of the object.
+// - The object content.
+// - An error if the object does not exist or cannot be decoded.
+func (m *ObjectManager) ReadObject(sha string) (GitObjectType, []byte, error) {
+	return m.readObject(m.replaced(sha))
+}
+
+// readObject reads the object stored under sha, ignoring replace refs.
+func (m *ObjectManager) readObject(sha string) (GitObjectType, []byte, error) {
+	if !isValidSHA(sha) {
+		return "", nil, fmt.Errorf("invalid object name '%s'", sha)
+	}
+
+	if path, ok := m.findLoose(sha); ok {
+		objType, data, err := readLooseObject(m.repo.fs, path, m.unknownTypes)
+		if err != nil {
+			return "", nil, fmt.Errorf("object %s: %w", sha, err)
+		}
+		trace.Log(trace.Object, "read", "sha", sha, "type", objType, "size", len(data), "source", "loose")
+		return objType, data, nil
+	}
+
+	packs, err := m.packFiles()
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, pack := range packs {
+		offset, ok := pack.index.find(sha)
+		if !ok {
+			continue
+		}
+
+		objType, data, err := pack.readAt(m, offset)
+		if err != nil {
+			return "", nil, fmt.Errorf("object %s: %w", sha, err)
+		}
+		trace.Log(trace.Object, "read", "sha", sha, "type", objType, "size", len(data), "source", filepath.Base(pack.path))
+		return objType, data, nil
+	}
+
+	if err := m.fetchPromised(sha); err != nil {
+		return "", nil, err
+	}
+	return m.readObject(sha)
+}
+
+// Has reports whether the object with the given SHA-1 exists in the repository.
+func (m *ObjectManager) Has(sha string) bool {
+	if _, ok := m.findLoose(sha); ok {
+		return true
+	}
+	return m.inPack(sha)
+}
+
+// inPack reports whether the object with the given SHA-1 is stored in a pack file.
+func (m *ObjectManager) inPack(sha string) bool {
+	packs, err := m.packFiles()
+	if err != nil {
+		return false
+	}
+
+	for _, pack := range packs {
+		if _, ok := pack.index.find(sha); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LooseObjects lists the SHA-1 of every loose object, sorted.
+func (m *ObjectManager) LooseObjects() ([]string, error) {
+	objectsPath := createRepoPath(m.repo, ObjectsDir)
+	dirs, err := listDir(m.repo.fs, objectsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var shas []string
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+
+		files, err := listDir(m.repo.fs, filepath.Join(objectsPath, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			sha := dir.Name() + file.Name()
+			if isValidSHA(sha) {
+				shas = append(shas, sha)
+			}
+		}
+	}
+
+	sort.Strings(shas)
+	return shas, nil
+}
+
+// removeLooseObject deletes the loose copy of an object, removing its fan-out
+// directory when it becomes empty.
+func (m *ObjectManager) removeLooseObject(sha string) error {
+	path := m.loosePath(sha)
+	if err := m.repo.fs.Remove(path); err != nil {
+		return err
+	}
+
+	// Ignore the error, the directory is simply not empty yet.
+	_ = m.repo.fs.Remove(filepath.Dir(path))
+	return nil
+}
+
+// AllowUnknownType lets reads of loose objects accept any type name
+// instead of failing, as cat-file --allow-unknown-type does for recovering
+// from corrupt or experimental objects. Pack entries cannot have other
+// types.
+//
+// Returns:
+// - m, for chaining onto NewObjectManager.
+func (m *ObjectManager) AllowUnknownType() *ObjectManager {
+	m.unknownTypes = true
+	return m
+}
+
+// readLooseObject inflates a loose object file and splits it into its type and content.
+func readLooseObject(fsys FileSystem, path string, allowUnknown bool) (GitObjectType, []byte, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	// An interrupted write can leave an empty file behind; zlib would only
+	// report an unexpected end.
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		return "", nil, fmt.Errorf("object file '%s' is empty", path)
+	}
+	reader, err := zlib.NewReader(file)
+	if err != nil {
+		return "", nil, fmt.Errorf("corrupt loose object '%s': %w", path, err)
+	}
+	defer reader.Close()
+
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("corrupt loose object '%s': %w", path, err)
+	}
+
+	return parseObjectHeader(raw, allowUnknown)
+}
+
+// parseObjectHeader splits a raw "<type> <size>\x00<data>" buffer and validates the size.
+func parseObjectHeader(raw []byte, allowUnknown bool) (GitObjectType, []byte, error) {
+	space := bytes.IndexByte(raw, ' ')
+	nul := bytes.IndexByte(raw, 0)
+	if space < 0 || nul < space {
+		return "", nil, fmt.Errorf("malformed object header")
+	}
+
+	objType, err := parseLooseObjectType(string(raw[:space]), allowUnknown)
+	if err != nil {
+		return "", nil, err
+	}
+
+	size, err := strconv.Atoi(string(raw[space+1 : nul]))
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed object size '%s'", raw[space+1:nul])
+	}
+
+	data := raw[nul+1:]
+	if size != len(data) {
+		return "", nil, fmt.Errorf("object size mismatch: header says %d, found %d", size, len(data))
+	}
+	return objType, data, nil
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ObjectKind describes a type of object: how its content is checked,
+// decoded and pretty-printed. The four git types are registered from the
+// start; forks that extend the object model register their own kinds with
+// RegisterObjectType instead of patching the code that handles objects.
+//
+// Objects of registered kinds are read, written, hashed and checked like
+// the git types, but stay loose: the pack format only has room for the
+// four git types.
+type ObjectKind struct {
+	Type GitObjectType
+
+	// Check validates the content of an object, for hash-object and
+	// fsck. nil accepts any content.
+	Check func(data []byte) error
+
+	// Decode builds the Go value of an object, as returned by
+	// DecodeObject. nil decodes to the raw content.
+	Decode func(objects *ObjectManager, sha string, data []byte) (any, error)
+
+	// Pretty prints an object for a human, as cat-file -p does. nil
+	// prints the raw content.
+	Pretty func(w io.Writer, objects *ObjectManager, data []byte) error
+}
+
+var (
+	objectKindsMu sync.RWMutex
+	objectKinds   = make(map[GitObjectType]ObjectKind)
+)
+
+// The git types are registered in init, as their checks look types up.
+func init() {
+	for _, kind := range []ObjectKind{
+		{
+			Type:  CommitType,
+			Check: checkCommit,
+			Decode: func(objects *ObjectManager, sha string, data []byte) (any, error) {
+				commit, err := parseCommit(sha, data)
+				if err != nil {
+					return nil, err
+				}
+				objects.applyGrafts(commit)
+				return commit, nil
+			},
+		},
+		{
+			Type:  TreeType,
+			Check: checkTree,
+			Decode: func(objects *ObjectManager, sha string, data []byte) (any, error) {
+				return parseTree(data)
+			},
+			Pretty: prettyPrintTree,
+		},
+		{Type: BlobType},
+		{
+			Type:  TagType,
+			Check: checkTag,
+			Decode: func(objects *ObjectManager, sha string, data []byte) (any, error) {
+				return ParseKvlm(data)
+			},
+		},
+	} {
+		objectKinds[kind.Type] = kind
+	}
+}
+
+// RegisterObjectType adds a kind of object. It is meant to be called from
+// an init function, before any object of the kind is read or written.
+//
+// Parameters:
+// - kind: The kind, whose Type names it in object headers.
+//
+// Returns:
+// - An error if the name is empty, cannot appear in an object header or
+// is already registered.
+func RegisterObjectType(kind ObjectKind) error {
+	name := string(kind.Type)
+	if name == "" || strings.ContainsAny(name, " \x00") {
+		return fmt.Errorf("invalid object type name '%s'", name)
+	}
+
+	objectKindsMu.Lock()
+	defer objectKindsMu.Unlock()
+	if _, ok := objectKinds[kind.Type]; ok {
+		return fmt.Errorf("object type '%s' is already registered", name)
+	}
+	objectKinds[kind.Type] = kind
+	return nil
+}
+
+// LookupObjectType returns the registered kind of objType.
+func LookupObjectType(objType GitObjectType) (ObjectKind, bool) {
+	objectKindsMu.RLock()
+	defer objectKindsMu.RUnlock()
+	kind, ok := objectKinds[objType]
+	return kind, ok
+}
+
+// DecodeObject reads an object and decodes it with the Decode function of
+// its kind: a *Commit for commits, the entries of trees, the *Kvlm of
+// tags and the content of blobs.
+//
+// Parameters:
+// - sha: The hex encoded SHA-1 of the object.
+//
+// Returns:
+// - The type of the object and its decoded value.
+// - An error if the object cannot be read, is of no registered kind or
+// cannot be decoded.
+func (m *ObjectManager) DecodeObject(sha string) (GitObjectType, any, error) {
+	objType, data, err := m.ReadObject(sha)
+	if err != nil {
+		return "", nil, err
+	}
+	kind, ok := LookupObjectType(objType)
+	if !ok {
+		return "", nil, fmt.Errorf("unknown object type '%s'", objType)
+	}
+	if kind.Decode == nil {
+		return objType, data, nil
+	}
+	value, err := kind.Decode(m, sha, data)
+	return objType, value, err
+}
+
+// prettyPrintTree prints a tree as a listing like ls-tree.
+func prettyPrintTree(w io.Writer, objects *ObjectManager, data []byte) error {
+	entries, err := parseTree(data)
+	if err != nil {
+		return err
+	}
+	quotePath := QuotePathEnabled(objects.repo)
+	for _, entry := range entries {
+		entryType := BlobType
+		switch {
+		case entry.IsTree():
+			entryType = TreeType
+		case entry.IsGitlink():
+			entryType = CommitType
+		}
+		fmt.Fprintf(w, "%06s %s %s\t%s\n", entry.Mode, entryType, entry.SHA, QuotePath(entry.Name, quotePath))
+	}
+	return nil
+}
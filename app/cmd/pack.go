@@ -0,0 +1,619 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+const PackDir = "pack"
+
+// Object type codes used in pack entry headers.
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+const (
+	packSignature  = "PACK"
+	packVersion    = 2
+	indexSignature = "\377tOc"
+	indexVersion   = 2
+)
+
+var packTypeCodes = map[GitObjectType]byte{
+	CommitType: packCommit,
+	TreeType:   packTree,
+	BlobType:   packBlob,
+	TagType:    packTag,
+}
+
+var packTypeNames = map[byte]GitObjectType{
+	packCommit: CommitType,
+	packTree:   TreeType,
+	packBlob:   BlobType,
+	packTag:    TagType,
+}
+
+// packIndex is an in-memory copy of a version 2 pack index (.idx) file.
+type packIndex struct {
+	fanout  [256]uint32
+	shas    []byte // count * 20 bytes of sorted object names
+	offsets []uint64
+}
+
+// packFile is a pack (.pack) together with its index.
+type packFile struct {
+	fs    FileSystem
+	path  string
+	index *packIndex
+}
+
+// packFiles returns the pack files of the repository, and of the quarantine
+// if there is one, loading their indexes on first use.
+func (m *ObjectManager) packFiles() ([]*packFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.packsLoaded {
+		return m.packs, nil
+	}
+
+	packPaths := []string{createRepoPath(m.repo, ObjectsDir, PackDir)}
+	if m.quarantine != "" {
+		packPaths = append([]string{filepath.Join(m.quarantine, PackDir)}, packPaths...)
+	}
+
+	for _, packPath := range packPaths {
+		entries, err := m.repo.fs.ReadDir(packPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, "pack-") || !strings.HasSuffix(name, ".idx") {
+				continue
+			}
+
+			pack, err := openPackFile(m.repo.fs, filepath.Join(packPath, strings.TrimSuffix(name, ".idx")+".pack"))
+			if err != nil {
+				return nil, err
+			}
+			m.packs = append(m.packs, pack)
+		}
+	}
+
+	m.packsLoaded = true
+	return m.packs, nil
+}
+
+// openPackFile loads the index belonging to the pack at path.
+func openPackFile(fsys FileSystem, path string) (*packFile, error) {
+	idxPath := strings.TrimSuffix(path, ".pack") + ".idx"
+	data, err := readFile(fsys, idxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := parsePackIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("pack index '%s': %w", idxPath, err)
+	}
+
+	trace.Log(trace.Pack, "open", "path", path, "objects", index.count())
+	return &packFile{fs: fsys, path: path, index: index}, nil
+}
+
+// parsePackIndex parses the content of a version 2 pack index file.
+func parsePackIndex(data []byte) (*packIndex, error) {
+	if len(data) < 8+256*4+40 || string(data[:4]) != indexSignature {
+		return nil, fmt.Errorf("unsupported pack index format")
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != indexVersion {
+		return nil, fmt.Errorf("unsupported pack index version %d", version)
+	}
+
+	index := &packIndex{}
+	pos := 8
+	for i := range index.fanout {
+		index.fanout[i] = binary.BigEndian.Uint32(data[pos:])
+		pos += 4
+	}
+
+	count := int(index.fanout[255])
+	if len(data) < pos+count*(20+4+4)+40 {
+		return nil, fmt.Errorf("truncated pack index")
+	}
+
+	index.shas = data[pos : pos+count*20]
+	pos += count * 20
+	pos += count * 4 // CRC32 values are only needed for verification.
+
+	smallOffsets := data[pos : pos+count*4]
+	largeOffsets := data[pos+count*4:]
+	index.offsets = make([]uint64, count)
+	for i := 0; i < count; i++ {
+		offset := uint64(binary.BigEndian.Uint32(smallOffsets[i*4:]))
+		if offset&0x80000000 != 0 {
+			large := int(offset&0x7fffffff) * 8
+			if large+8 > len(largeOffsets)-40 {
+				return nil, fmt.Errorf("invalid large offset in pack index")
+			}
+			offset = binary.BigEndian.Uint64(largeOffsets[large:])
+		}
+		index.offsets[i] = offset
+	}
+
+	return index, nil
+}
+
+// count returns the number of objects in the pack.
+func (idx *packIndex) count() int {
+	return len(idx.offsets)
+}
+
+// sha returns the hex encoded name of the i-th object in the index.
+func (idx *packIndex) sha(i int) string {
+	return hex.EncodeToString(idx.shas[i*20 : i*20+20])
+}
+
+// find looks up sha in the index and returns its offset in the pack.
+func (idx *packIndex) find(sha string) (uint64, bool) {
+	raw, err := hex.DecodeString(sha)
+	if err != nil || len(raw) != 20 {
+		return 0, false
+	}
+
+	lo := 0
+	if raw[0] > 0 {
+		lo = int(idx.fanout[raw[0]-1])
+	}
+	hi := int(idx.fanout[raw[0]])
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(idx.shas[(lo+i)*20:(lo+i)*20+20], raw) >= 0
+	})
+	if i < hi && bytes.Equal(idx.shas[i*20:i*20+20], raw) {
+		return idx.offsets[i], true
+	}
+	return 0, false
+}
+
+// readAt decodes the object stored at offset in the pack, resolving deltas.
+// Ref deltas may point at objects outside this pack, so the ObjectManager is
+// used to resolve their bases.
+func (p *packFile) readAt(m *ObjectManager, offset uint64) (GitObjectType, []byte, error) {
+	file, err := p.fs.Open(p.path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	return readPackEntry(file, offset, func(sha string) (GitObjectType, []byte, error) {
+		return m.readObject(sha)
+	})
+}
+
+// readPackEntry decodes the pack entry at offset, following delta chains.
+//
+// Parameters:
+// - r: The pack data.
+// - offset: The offset of the entry header.
+// - resolveRef: Called to read the base of a ref delta.
+//
+// Returns:
+// - The type and content of the fully resolved object.
+// - An error if the entry is corrupt or a delta base is missing.
+func readPackEntry(r io.ReaderAt, offset uint64, resolveRef func(sha string) (GitObjectType, []byte, error)) (GitObjectType, []byte, error) {
+	typeCode, size, headerLen, err := readPackEntryHeader(r, offset)
+	if err != nil {
+		return "", nil, err
+	}
+	pos := offset + uint64(headerLen)
+
+	switch typeCode {
+	case packCommit, packTree, packBlob, packTag:
+		data, err := inflateAt(r, pos, size)
+		if err != nil {
+			return "", nil, err
+		}
+		return packTypeNames[typeCode], data, nil
+
+	case packOfsDelta:
+		distance, n, err := readOffsetDelta(r, pos)
+		if err != nil {
+			return "", nil, err
+		}
+		if distance > offset {
+			return "", nil, fmt.Errorf("delta base offset out of range at %d", offset)
+		}
+
+		baseType, base, err := readPackEntry(r, offset-distance, resolveRef)
+		if err != nil {
+			return "", nil, err
+		}
+		delta, err := inflateAt(r, pos+uint64(n), size)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+
+	case packRefDelta:
+		baseSHA := make([]byte, 20)
+		if _, err := r.ReadAt(baseSHA, int64(pos)); err != nil {
+			return "", nil, err
+		}
+
+		baseType, base, err := resolveRef(hex.EncodeToString(baseSHA))
+		if err != nil {
+			return "", nil, err
+		}
+		delta, err := inflateAt(r, pos+20, size)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+	}
+
+	return "", nil, fmt.Errorf("unknown pack entry type %d at offset %d", typeCode, offset)
+}
+
+// readPackEntryHeader decodes the type and inflated size of a pack entry.
+func readPackEntryHeader(r io.ReaderAt, offset uint64) (byte, int, int, error) {
+	buf := make([]byte, 16)
+	n, err := r.ReadAt(buf, int64(offset))
+	if n == 0 {
+		return 0, 0, 0, fmt.Errorf("reading pack entry at %d: %w", offset, err)
+	}
+	buf = buf[:n]
+
+	typeCode := (buf[0] >> 4) & 7
+	size := int(buf[0] & 0x0f)
+	shift := 4
+	i := 0
+	for buf[i]&0x80 != 0 {
+		i++
+		if i >= len(buf) {
+			return 0, 0, 0, fmt.Errorf("corrupt pack entry header at %d", offset)
+		}
+		size |= int(buf[i]&0x7f) << shift
+		shift += 7
+	}
+	return typeCode, size, i + 1, nil
+}
+
+// readOffsetDelta decodes the negative base offset of an ofs-delta entry.
+func readOffsetDelta(r io.ReaderAt, pos uint64) (uint64, int, error) {
+	buf := make([]byte, 10)
+	n, err := r.ReadAt(buf, int64(pos))
+	if n == 0 {
+		return 0, 0, err
+	}
+
+	distance := uint64(buf[0] & 0x7f)
+	i := 0
+	for buf[i]&0x80 != 0 {
+		i++
+		if i >= n {
+			return 0, 0, fmt.Errorf("corrupt delta offset at %d", pos)
+		}
+		distance = ((distance + 1) << 7) | uint64(buf[i]&0x7f)
+	}
+	return distance, i + 1, nil
+}
+
+// inflateAt decompresses exactly size bytes from the zlib stream starting at pos.
+func inflateAt(r io.ReaderAt, pos uint64, size int) ([]byte, error) {
+	reader, err := zlib.NewReader(io.NewSectionReader(r, int64(pos), 1<<62))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt zlib stream at %d: %w", pos, err)
+	}
+	defer reader.Close()
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("corrupt zlib stream at %d: %w", pos, err)
+	}
+	return data, nil
+}
+
+// applyDelta reconstructs an object from its base and a git delta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, n := readDeltaSize(delta)
+	delta = delta[n:]
+	if srcSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch: expected %d, got %d", srcSize, len(base))
+	}
+
+	dstSize, n := readDeltaSize(delta)
+	delta = delta[n:]
+	result := make([]byte, 0, dstSize)
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			if op == 0 || int(op) > len(delta) {
+				return nil, fmt.Errorf("invalid delta insert instruction")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+			continue
+		}
+
+		var offset, size int
+		for i := 0; i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+			if len(delta) == 0 {
+				return nil, fmt.Errorf("truncated delta copy instruction")
+			}
+			if i < 4 {
+				offset |= int(delta[0]) << (8 * i)
+			} else {
+				size |= int(delta[0]) << (8 * (i - 4))
+			}
+			delta = delta[1:]
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) {
+			return nil, fmt.Errorf("delta copy out of range")
+		}
+		result = append(result, base[offset:offset+size]...)
+	}
+
+	if len(result) != dstSize {
+		return nil, fmt.Errorf("delta result size mismatch: expected %d, got %d", dstSize, len(result))
+	}
+	return result, nil
+}
+
+// readDeltaSize decodes a little-endian base-128 size from the start of a delta.
+func readDeltaSize(delta []byte) (int, int) {
+	size, shift, i := 0, 0, 0
+	for i < len(delta) {
+		b := delta[i]
+		size |= int(b&0x7f) << shift
+		shift += 7
+		i++
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return size, i
+}
+
+// encodePackEntryHeader encodes the type and size header of a pack entry.
+func encodePackEntryHeader(typeCode byte, size int) []byte {
+	header := []byte{typeCode<<4 | byte(size&0x0f)}
+	size >>= 4
+	for size > 0 {
+		header[len(header)-1] |= 0x80
+		header = append(header, byte(size&0x7f))
+		size >>= 7
+	}
+	return header
+}
+
+// packIndexEntry describes one object while a pack is being written.
+type packIndexEntry struct {
+	sha    []byte
+	offset uint64
+	crc    uint32
+}
+
+// encodePack builds a pack stream holding the given objects. Objects are
+// stored as ofs-deltas where the delta search of opts finds a good base;
+// a zero PackOptions writes every object whole.
+//
+// Parameters:
+// - shas: The objects to put in the pack.
+// - opts: The delta search settings.
+//
+// Returns:
+// - The pack data, including its trailing checksum.
+// - The index entries of the objects, in pack order.
+// - Statistics about the pack.
+// - An error if an object cannot be read.
+func (m *ObjectManager) encodePack(shas []string, opts PackOptions) ([]byte, []packIndexEntry, PackStats, error) {
+	stats := PackStats{Objects: len(shas)}
+	objects := make([]*packObject, len(shas))
+	for i, sha := range shas {
+		objType, data, err := m.ReadObject(sha)
+		if err != nil {
+			return nil, nil, stats, err
+		}
+		if _, ok := packTypeCodes[objType]; !ok {
+			return nil, nil, stats, fmt.Errorf("object %s of type '%s' cannot be packed", sha, objType)
+		}
+		objects[i] = &packObject{sha: sha, objType: objType, data: data}
+		stats.RawSize += len(data)
+	}
+	findDeltas(objects, opts)
+
+	var pack bytes.Buffer
+	pack.WriteString(packSignature)
+	_ = binary.Write(&pack, binary.BigEndian, uint32(packVersion))
+	_ = binary.Write(&pack, binary.BigEndian, uint32(len(shas)))
+
+	entries := make([]packIndexEntry, 0, len(shas))
+	offsets := make(map[int]uint64, len(shas))
+
+	// write stores an object after its delta base, which ofs-deltas require.
+	var write func(i int) error
+	write = func(i int) error {
+		if _, ok := offsets[i]; ok {
+			return nil
+		}
+		object := objects[i]
+		if object.base >= 0 {
+			if err := write(object.base); err != nil {
+				return err
+			}
+		}
+
+		start := pack.Len()
+		offsets[i] = uint64(start)
+		content := object.data
+		if object.base >= 0 {
+			content = object.delta
+			pack.Write(encodePackEntryHeader(packOfsDelta, len(content)))
+			pack.Write(encodeOffsetDelta(uint64(start) - offsets[object.base]))
+			stats.Deltas++
+		} else {
+			pack.Write(encodePackEntryHeader(packTypeCodes[object.objType], len(content)))
+		}
+		writer := zlib.NewWriter(&pack)
+		if _, err := writer.Write(content); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+
+		raw, _ := hex.DecodeString(object.sha)
+		entries = append(entries, packIndexEntry{
+			sha:    raw,
+			offset: uint64(start),
+			crc:    crc32.ChecksumIEEE(pack.Bytes()[start:]),
+		})
+		return nil
+	}
+	for i := range objects {
+		if err := write(i); err != nil {
+			return nil, nil, stats, err
+		}
+	}
+
+	checksum := sha1.Sum(pack.Bytes())
+	pack.Write(checksum[:])
+	stats.PackedSize = pack.Len()
+	return pack.Bytes(), entries, stats, nil
+}
+
+// WritePack stores the given objects in a new pack file, deltified with
+// the repository's pack.window, pack.depth and pack.threads, and writes its
+// index.
+//
+// Parameters:
+// - shas: The objects to put in the pack.
+//
+// Returns:
+// - The name (pack checksum) of the new pack.
+// - Statistics about the pack.
+// - An error if an object cannot be read or the pack cannot be written.
+func (m *ObjectManager) WritePack(shas []string) (string, PackStats, error) {
+	defer trace.Start(trace.Pack, "write pack", "objects", len(shas))()
+
+	pack, entries, stats, err := m.encodePack(shas, LoadPackOptions(m.repo))
+	if err != nil {
+		return "", stats, err
+	}
+	name, err := m.writePackFiles(pack, entries)
+	if err != nil {
+		return "", stats, err
+	}
+	trace.Log(trace.Pack, "wrote pack", "name", name, "stats", stats.String())
+	return name, stats, nil
+}
+
+// writePackFiles writes a pack, which ends with its checksum, and the index
+// of its entries to the pack directory, and makes the pack readable
+// through the manager.
+//
+// Returns:
+// - The name (pack checksum) of the pack.
+// - An error if a file cannot be written.
+func (m *ObjectManager) writePackFiles(pack []byte, entries []packIndexEntry) (string, error) {
+	checksum := pack[len(pack)-20:]
+	name := hex.EncodeToString(checksum)
+
+	basePath := filepath.Join(m.objectDir(), PackDir, "pack-"+name)
+	if err := writeRepoFile(m.repo, fsyncPack, basePath+".pack", pack, 0444); err != nil {
+		return "", err
+	}
+	if err := writeRepoFile(m.repo, fsyncPackMetadata, basePath+".idx", encodePackIndex(entries, checksum), 0444); err != nil {
+		return "", err
+	}
+	if err := adjustSharedPerm(m.repo, filepath.Dir(basePath), basePath+".pack", basePath+".idx"); err != nil {
+		return "", err
+	}
+
+	written, err := openPackFile(m.repo.fs, basePath+".pack")
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	if m.packsLoaded {
+		m.packs = append(m.packs, written)
+	}
+	m.mu.Unlock()
+	return name, nil
+}
+
+// encodePackIndex serializes a version 2 pack index for the given entries.
+func encodePackIndex(entries []packIndexEntry, packChecksum []byte) []byte {
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].sha, entries[j].sha) < 0
+	})
+
+	var idx bytes.Buffer
+	idx.WriteString(indexSignature)
+	_ = binary.Write(&idx, binary.BigEndian, uint32(indexVersion))
+
+	var fanout [256]uint32
+	for _, entry := range entries {
+		fanout[entry.sha[0]]++
+	}
+	var total uint32
+	for i := range fanout {
+		total += fanout[i]
+		_ = binary.Write(&idx, binary.BigEndian, total)
+	}
+
+	for _, entry := range entries {
+		idx.Write(entry.sha)
+	}
+	for _, entry := range entries {
+		_ = binary.Write(&idx, binary.BigEndian, entry.crc)
+	}
+
+	var large []uint64
+	for _, entry := range entries {
+		if entry.offset < 0x80000000 {
+			_ = binary.Write(&idx, binary.BigEndian, uint32(entry.offset))
+			continue
+		}
+		_ = binary.Write(&idx, binary.BigEndian, uint32(0x80000000|len(large)))
+		large = append(large, entry.offset)
+	}
+	for _, offset := range large {
+		_ = binary.Write(&idx, binary.BigEndian, offset)
+	}
+
+	idx.Write(packChecksum)
+	sum := sha1.Sum(idx.Bytes())
+	idx.Write(sum[:])
+	return idx.Bytes()
+}
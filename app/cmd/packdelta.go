@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Defaults of the delta search, as in git.
+const (
+	defaultPackWindow = 10
+	defaultPackDepth  = 50
+	maxPackDepth      = 4095
+)
+
+const (
+	deltaBlockSize   = 16      // Bytes hashed per block of the base when indexing it.
+	maxDeltaInsert   = 0x7f    // Longest insert instruction.
+	maxDeltaCopy     = 0x10000 // Longest copy instruction we emit.
+	deltaSizeDivisor = 32      // Bases smaller than 1/32 of the target are not tried.
+)
+
+// PackOptions controls the delta compression of written packs.
+type PackOptions struct {
+	Window           int   // Objects each object is compared against; 0 disables deltas.
+	Depth            int   // Longest allowed delta chain.
+	Threads          int   // Workers searching for deltas.
+	BigFileThreshold int64 // Larger objects are stored whole; 0 means no limit.
+}
+
+// PackStats describes a written pack.
+type PackStats struct {
+	Objects    int
+	Deltas     int
+	RawSize    int // Total size of the objects before compression.
+	PackedSize int // Size of the pack file.
+}
+
+// String summarizes the statistics, e.g. "120 objects, 45 deltas, 52311 -> 9012 bytes".
+func (s PackStats) String() string {
+	return fmt.Sprintf("%d objects, %d deltas, %d -> %d bytes", s.Objects, s.Deltas, s.RawSize, s.PackedSize)
+}
+
+// LoadPackOptions reads pack.window, pack.depth, pack.threads and
+// core.bigFileThreshold. A pack.threads of 0, the default, uses one worker
+// per CPU.
+func LoadPackOptions(repo *GitRepository) PackOptions {
+	opts := PackOptions{Window: defaultPackWindow, Depth: defaultPackDepth, BigFileThreshold: BigFileThreshold(repo)}
+	if repo.Config().IsSet("pack.window") {
+		opts.Window = max(repo.Config().GetInt("pack.window"), 0)
+	}
+	if repo.Config().IsSet("pack.depth") {
+		opts.Depth = min(max(repo.Config().GetInt("pack.depth"), 0), maxPackDepth)
+	}
+	opts.Threads = repo.Config().GetInt("pack.threads")
+	if opts.Threads <= 0 {
+		opts.Threads = runtime.NumCPU()
+	}
+	return opts
+}
+
+// packObject is an object queued for a pack, with the delta chosen for it.
+type packObject struct {
+	sha     string
+	objType GitObjectType
+	data    []byte
+	base    int    // Index of the delta base in the pack list, or -1.
+	delta   []byte // The delta against base, if any.
+	depth   int    // Length of the delta chain ending here.
+}
+
+// findDeltas picks a delta base for each object. Objects are sorted by type
+// and decreasing size so that similar objects end up close together, then
+// split into one contiguous segment per worker. Each worker compares every
+// object with the Window objects before it in its segment and keeps the
+// smallest delta whose chain stays within Depth. Objects above
+// BigFileThreshold take no part in the search.
+func findDeltas(objects []*packObject, opts PackOptions) {
+	for _, object := range objects {
+		object.base = -1
+	}
+	if opts.Window <= 0 || opts.Depth <= 0 || len(objects) < 2 {
+		return
+	}
+
+	order := make([]int, 0, len(objects))
+	for i, object := range objects {
+		if opts.BigFileThreshold <= 0 || int64(len(object.data)) <= opts.BigFileThreshold {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := objects[order[i]], objects[order[j]]
+		if a.objType != b.objType {
+			return a.objType < b.objType
+		}
+		return len(a.data) > len(b.data)
+	})
+
+	// Small segments lose too many delta candidates to be worth a worker.
+	threads := max(min(opts.Threads, len(order)/(4*opts.Window)), 1)
+	segment := (len(order) + threads - 1) / threads
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(order); start += segment {
+		end := min(start+segment, len(order))
+		wg.Add(1)
+		go func(part []int) {
+			defer wg.Done()
+			searchDeltas(objects, part, opts)
+		}(order[start:end])
+	}
+	wg.Wait()
+}
+
+// searchDeltas runs the window search over one segment of the sorted list.
+func searchDeltas(objects []*packObject, part []int, opts PackOptions) {
+	indexes := make(map[int]map[string]int)
+	for n, target := range part {
+		object := objects[target]
+		best := len(object.data)/2 - 20
+
+		for _, candidate := range part[max(n-opts.Window, 0):n] {
+			base := objects[candidate]
+			if base.objType != object.objType || base.depth >= opts.Depth {
+				continue
+			}
+			if len(base.data) < len(object.data)/deltaSizeDivisor {
+				continue
+			}
+
+			index, ok := indexes[candidate]
+			if !ok {
+				index = deltaIndex(base.data)
+				indexes[candidate] = index
+			}
+			delta := createDelta(base.data, index, object.data)
+			if len(delta) < best {
+				best = len(delta)
+				object.base, object.delta, object.depth = candidate, delta, base.depth+1
+			}
+		}
+
+		// Only the window behind the next object needs an index.
+		if n >= opts.Window {
+			delete(indexes, part[n-opts.Window])
+		}
+	}
+}
+
+// deltaIndex maps each aligned block of base to its first offset.
+func deltaIndex(base []byte) map[string]int {
+	index := make(map[string]int, len(base)/deltaBlockSize)
+	for offset := 0; offset+deltaBlockSize <= len(base); offset += deltaBlockSize {
+		block := string(base[offset : offset+deltaBlockSize])
+		if _, ok := index[block]; !ok {
+			index[block] = offset
+		}
+	}
+	return index
+}
+
+// createDelta encodes target as a git delta against base: copy instructions
+// for runs found in base and insert instructions for everything else.
+//
+// Parameters:
+// - base: The delta base.
+// - index: The block index of base, from deltaIndex.
+// - target: The object to encode.
+//
+// Returns:
+// - The delta, which applyDelta turns back into target.
+func createDelta(base []byte, index map[string]int, target []byte) []byte {
+	delta := appendDeltaSize(nil, len(base))
+	delta = appendDeltaSize(delta, len(target))
+
+	insertStart := 0
+	for i := 0; i+deltaBlockSize <= len(target); {
+		offset, ok := index[string(target[i:i+deltaBlockSize])]
+		if !ok {
+			i++
+			continue
+		}
+
+		length := deltaBlockSize
+		for offset+length < len(base) && i+length < len(target) && base[offset+length] == target[i+length] {
+			length++
+		}
+		// Grow the match backwards over bytes that would otherwise be inserted.
+		for i > insertStart && offset > 0 && base[offset-1] == target[i-1] {
+			i--
+			offset--
+			length++
+		}
+		delta = appendDeltaInsert(delta, target[insertStart:i])
+		delta = appendDeltaCopy(delta, offset, length)
+		i += length
+		insertStart = i
+	}
+	return appendDeltaInsert(delta, target[insertStart:])
+}
+
+// appendDeltaSize appends a size in the little-endian base-128 form read by readDeltaSize.
+func appendDeltaSize(delta []byte, size int) []byte {
+	for size >= 0x80 {
+		delta = append(delta, byte(size)|0x80)
+		size >>= 7
+	}
+	return append(delta, byte(size))
+}
+
+// appendDeltaInsert appends insert instructions for data.
+func appendDeltaInsert(delta, data []byte) []byte {
+	for len(data) > 0 {
+		n := min(len(data), maxDeltaInsert)
+		delta = append(delta, byte(n))
+		delta = append(delta, data[:n]...)
+		data = data[n:]
+	}
+	return delta
+}
+
+// appendDeltaCopy appends copy instructions for length bytes of the base
+// starting at offset. Only the non-zero bytes of offset and size are stored.
+func appendDeltaCopy(delta []byte, offset, length int) []byte {
+	for length > 0 {
+		size := min(length, maxDeltaCopy)
+		op := byte(0x80)
+		var args []byte
+		for i := 0; i < 4; i++ {
+			if b := byte(offset >> (8 * i)); b != 0 {
+				op |= 1 << i
+				args = append(args, b)
+			}
+		}
+		if size != maxDeltaCopy {
+			for i := 0; i < 3; i++ {
+				if b := byte(size >> (8 * i)); b != 0 {
+					op |= 1 << (4 + i)
+					args = append(args, b)
+				}
+			}
+		}
+		delta = append(append(delta, op), args...)
+		offset += size
+		length -= size
+	}
+	return delta
+}
+
+// encodeOffsetDelta encodes the distance back to an ofs-delta base, the
+// inverse of readOffsetDelta.
+func encodeOffsetDelta(distance uint64) []byte {
+	buf := []byte{byte(distance & 0x7f)}
+	for distance >>= 7; distance > 0; distance >>= 7 {
+		distance--
+		buf = append([]byte{byte(distance&0x7f) | 0x80}, buf...)
+	}
+	return buf
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// PickaxeOptions selects the changes a Pickaxe finds, like the -S, -G and
+// --pickaxe-regex options of git log.
+type PickaxeOptions struct {
+	Search string // -S: the number of occurrences of this string changes.
+	Regex  bool   // --pickaxe-regex: Search is a regular expression.
+	Grep   string // -G: an added or removed line matches this regular expression.
+}
+
+// Pickaxe finds the changes that add or remove some text. A blob that
+// only appears on one side of a change counts as empty on the other.
+type Pickaxe struct {
+	search []byte
+	regex  *regexp.Regexp // For -S with --pickaxe-regex.
+	grep   *regexp.Regexp
+}
+
+// NewPickaxe compiles the patterns of a pickaxe.
+//
+// Returns:
+// - The pickaxe, or nil when opts select nothing.
+// - An error if a regular expression is invalid or both -S and -G are set.
+func NewPickaxe(opts PickaxeOptions) (*Pickaxe, error) {
+	switch {
+	case opts.Search != "" && opts.Grep != "":
+		return nil, fmt.Errorf("options '-S' and '-G' cannot be used together")
+	case opts.Grep != "":
+		grep, err := regexp.Compile(opts.Grep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex given to -G: '%s'", opts.Grep)
+		}
+		return &Pickaxe{grep: grep}, nil
+	case opts.Search == "":
+		return nil, nil
+	case opts.Regex:
+		regex, err := regexp.Compile(opts.Search)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex given to -S: '%s'", opts.Search)
+		}
+		return &Pickaxe{regex: regex}, nil
+	}
+	return &Pickaxe{search: []byte(opts.Search)}, nil
+}
+
+// Filter returns the changes the pickaxe finds among changes: with -S
+// those where the number of occurrences differs between the old and the
+// new blob, with -G those whose diff adds or removes a matching line.
+// Binary files are left out of -G, and submodules of both.
+//
+// Parameters:
+// - objects: The object store holding the blobs.
+// - changes: The changes of a commit, e.g. from DiffTrees.
+//
+// Returns:
+// - The changes found, in order.
+// - An error if a blob cannot be read.
+func (p *Pickaxe) Filter(objects *ObjectManager, changes []TreeChange) ([]TreeChange, error) {
+	var found []TreeChange
+	for _, change := range changes {
+		if change.Old.IsGitlink() || change.New.IsGitlink() || change.Old.SHA == change.New.SHA {
+			continue
+		}
+		var contents [2][]byte
+		for i, entry := range []TreeEntry{change.Old, change.New} {
+			if entry.SHA == "" {
+				continue
+			}
+			_, data, err := objects.ReadObject(entry.SHA)
+			if err != nil {
+				return nil, err
+			}
+			contents[i] = data
+		}
+		if p.matches(contents[0], contents[1]) {
+			found = append(found, change)
+		}
+	}
+	return found, nil
+}
+
+// matches applies the pickaxe to the two sides of one change.
+func (p *Pickaxe) matches(before, after []byte) bool {
+	if p.grep == nil {
+		return p.count(before) != p.count(after)
+	}
+	if IsBinary(before) || IsBinary(after) {
+		return false
+	}
+	beforeLines, afterLines := splitLines(before), splitLines(after)
+	for _, op := range diffLines(beforeLines, afterLines) {
+		switch {
+		case op.kind == '-' && p.grep.MatchString(beforeLines[op.old]):
+			return true
+		case op.kind == '+' && p.grep.MatchString(afterLines[op.new]):
+			return true
+		}
+	}
+	return false
+}
+
+// count returns how many times the -S string or regex occurs in data,
+// without overlaps.
+func (p *Pickaxe) count(data []byte) int {
+	if p.regex != nil {
+		return len(p.regex.FindAllIndex(data, -1))
+	}
+	return bytes.Count(data, p.search)
+}
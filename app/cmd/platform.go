@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Windows file systems have neither an executable bit nor, for most users,
+// symlinks. git probes for both when a repository is created and records the
+// result in core.filemode and core.symlinks; these are the fallbacks when
+// the config says nothing.
+var (
+	defaultFileMode = runtime.GOOS != "windows"
+	defaultSymlinks = runtime.GOOS != "windows"
+)
+
+// FileModeEnabled reports whether the executable bit of worktree files is
+// trusted (core.filemode). When it is not, the mode recorded in the index
+// is kept.
+func FileModeEnabled(repo *GitRepository) bool {
+	if repo.Config().IsSet("core.filemode") {
+		return repo.Config().GetBool("core.filemode")
+	}
+	return defaultFileMode
+}
+
+// SymlinksEnabled reports whether symlinks can be created in the worktree
+// (core.symlinks). When they cannot, a symlink is checked out as a plain
+// file holding its target.
+func SymlinksEnabled(repo *GitRepository) bool {
+	if repo.Config().IsSet("core.symlinks") {
+		return repo.Config().GetBool("core.symlinks")
+	}
+	return defaultSymlinks
+}
+
+// worktreePath converts a slash separated path from a tree or the index into
+// a path in the worktree of repo.
+func worktreePath(repo *GitRepository, name string) string {
+	return filepath.Join(repo.WorkTree, filepath.FromSlash(name))
+}
+
+// probeFileMode checks whether files in dir keep an executable bit once it
+// is set, as git does before writing core.filemode.
+func probeFileMode(dir string) bool {
+	file, err := os.CreateTemp(dir, "tmp_filemode_")
+	if err != nil {
+		return defaultFileMode
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+
+	if err := os.Chmod(path, 0755); err != nil {
+		return false
+	}
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&0100 != 0
+}
+
+// probeSymlinks checks whether symlinks can be created in dir.
+func probeSymlinks(dir string) bool {
+	path := filepath.Join(dir, "tmp_symlink_probe")
+	os.Remove(path)
+	if err := os.Symlink("target", path); err != nil {
+		return false
+	}
+	os.Remove(path)
+	return true
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package cmd
+
+import "os"
+
+// processAlive reports whether a process with the given pid exists. On
+// Windows, finding a process opens a handle to it, which fails once it has
+// exited.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressDelay is how long an operation runs before its progress meter
+// appears, so that quick ones stay silent, as in git.
+const progressDelay = 2 * time.Second
+
+// progress draws a git style progress meter such as
+// "Updating files:  42% (420/1000)", redrawn in place as work is done and
+// ending in ", done.". Nothing is drawn until progressDelay has passed. A
+// nil progress draws nothing.
+type progress struct {
+	out     io.Writer
+	title   string
+	total   int
+	done    int
+	percent int
+	start   time.Time
+	shown   bool
+}
+
+// newProgress starts a meter for total units of work, or returns nil when
+// out is nil.
+func newProgress(out io.Writer, title string, total int) *progress {
+	if out == nil {
+		return nil
+	}
+	return &progress{out: out, title: title, total: total, percent: -1, start: time.Now()}
+}
+
+// add records n more units of work as done.
+func (p *progress) add(n int) {
+	if p == nil {
+		return
+	}
+	p.done += n
+	if !p.shown && time.Since(p.start) < progressDelay {
+		return
+	}
+	if percent := p.done * 100 / max(p.total, 1); percent != p.percent {
+		p.percent, p.shown = percent, true
+		fmt.Fprintf(p.out, "%s: %3d%% (%d/%d)\r", p.title, percent, p.done, p.total)
+	}
+}
+
+// finish completes the meter if it was shown.
+func (p *progress) finish() {
+	if p == nil || !p.shown {
+		return
+	}
+	fmt.Fprintf(p.out, "%s: %3d%% (%d/%d), done.\n", p.title, p.done*100/max(p.total, 1), p.done, p.total)
+}
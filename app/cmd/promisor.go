@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// PromisorRemote returns the remote a partial clone fetches missing
+// objects from, extensions.partialClone, or an empty string if the
+// repository is not a partial clone.
+func PromisorRemote(repo *GitRepository) string {
+	if repo.Config().GetInt("core.repositoryformatversion") != 1 {
+		return ""
+	}
+	return repo.Config().GetString("extensions.partialclone")
+}
+
+// promisorFilter returns remote.<name>.partialCloneFilter, the filter
+// that fetches from the promisor remote keep using.
+func promisorFilter(repo *GitRepository, remote string) (*ObjectFilter, error) {
+	spec := repo.Config().GetString(configKey("remote", remote, "partialclonefilter"))
+	if spec == "" {
+		return nil, nil
+	}
+	return ParseObjectFilter(spec)
+}
+
+// recordPromisorRemote turns the repository into a partial clone of
+// remote, as the first fetch with a filter does: the remote is marked as
+// a promisor, its filter is remembered for later fetches, and
+// extensions.partialClone, which needs repository format version 1, tells
+// readers that missing objects are expected.
+func recordPromisorRemote(repo *GitRepository, remote string, filter *ObjectFilter) error {
+	settings := []struct{ section, subsection, name, value string }{
+		{"remote", remote, "promisor", "true"},
+		{"remote", remote, "partialclonefilter", filter.String()},
+		{"core", "", "repositoryformatversion", "1"},
+		{"extensions", "", "partialclone", remote},
+	}
+	for _, s := range settings {
+		if err := SetConfig(repo, s.section, s.subsection, s.name, s.value); err != nil {
+			return err
+		}
+	}
+	trace.Log(trace.Config, "partial clone", "remote", remote, "filter", filter.String())
+	return nil
+}
+
+// storePromisorPack stores a pack received from the promisor remote. The
+// objects are written as a pack next to an empty .promisor file, which
+// tells git that objects they refer to but that are missing can be
+// fetched again. The pack is assembled in a quarantine so that a failure
+// leaves nothing behind.
+//
+// Returns:
+// - The number of objects received.
+// - An error if the pack is corrupt or cannot be written.
+func storePromisorPack(repo *GitRepository, pack []byte) (int, error) {
+	quarantine, err := NewQuarantine(repo)
+	if err != nil {
+		return 0, err
+	}
+	defer quarantine.Discard()
+
+	unpacked, err := UnpackObjects(repo, bytes.NewReader(pack), UnpackOptions{Strict: FsckObjects(repo, "fetch"), Into: quarantine.Objects})
+	if err != nil {
+		return 0, err
+	}
+
+	name, _, err := quarantine.Objects.WritePack(unpacked.Objects)
+	if err != nil {
+		return 0, err
+	}
+	promisor := filepath.Join(quarantine.Dir(), PackDir, "pack-"+name+".promisor")
+	if err := writeRepoFile(repo, fsyncPackMetadata, promisor, nil, 0444); err != nil {
+		return 0, err
+	}
+	if err := quarantine.removeLoose(); err != nil {
+		return 0, err
+	}
+	if err := quarantine.Migrate(); err != nil {
+		return 0, err
+	}
+	trace.Log(trace.Pack, "promisor pack", "name", name, "objects", len(unpacked.Objects))
+	return unpacked.Unpacked, nil
+}
+
+// fetchPromisorObjects fetches objects by name from the promisor remote,
+// with its filter, so that fetching a tree does not bring in its blobs.
+// No haves are sent: the objects are missing, so nothing in the
+// repository is known to lead to them.
+func fetchPromisorObjects(repo *GitRepository, shas []string) error {
+	remote := PromisorRemote(repo)
+	defer trace.Start(trace.Perf, "promisor fetch", "remote", remote, "objects", len(shas))()
+
+	filter, err := promisorFilter(repo, remote)
+	if err != nil {
+		return err
+	}
+	session, err := dialRemote(repo, ResolveRemoteURL(repo, remote), "git-upload-pack", remoteUploadPack(repo, remote))
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if !session.Advertisement.Capabilities.Has(transport.CapFilter) {
+		filter = nil
+	}
+	pack, err := fetchPack(session, shas, nil, filter)
+	if err != nil {
+		return err
+	}
+	_, err = storePromisorPack(repo, pack)
+	return err
+}
+
+// fetchPromised is called when sha cannot be found. In a partial clone the
+// object is fetched from the promisor remote, once per manager, so that a
+// missing object that the remote does not have either fails quickly.
+//
+// Returns:
+// - nil if the object was fetched and can be read again.
+// - The error to report for the missing object otherwise.
+func (m *ObjectManager) fetchPromised(sha string) error {
+	notFound := fmt.Errorf("object %s not found", sha)
+	remote := PromisorRemote(m.repo)
+	if remote == "" {
+		return notFound
+	}
+
+	m.mu.Lock()
+	tried := m.promised[sha]
+	if m.promised == nil {
+		m.promised = make(map[string]bool)
+	}
+	m.promised[sha] = true
+	m.mu.Unlock()
+	if tried {
+		return notFound
+	}
+
+	if err := fetchPromisorObjects(m.repo, []string{sha}); err != nil {
+		return fmt.Errorf("object %s not found, and could not be fetched from promisor remote '%s': %w", sha, remote, err)
+	}
+	m.reloadPacks()
+	return nil
+}
+
+// Prefetch fetches the objects of shas that are missing from the promisor
+// remote in a single request, so that a command about to read many blobs
+// of a partial clone, such as diff, does not fetch them one at a time. In
+// other repositories it does nothing.
+//
+// Returns:
+// - An error if the missing objects cannot be fetched.
+func (m *ObjectManager) Prefetch(shas []string) error {
+	remote := PromisorRemote(m.repo)
+	if remote == "" {
+		return nil
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+	for _, sha := range shas {
+		sha = m.replaced(sha)
+		if !isValidSHA(sha) || sha == zeroSHA || seen[sha] || m.Has(sha) {
+			continue
+		}
+		seen[sha] = true
+		missing = append(missing, sha)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	if m.promised == nil {
+		m.promised = make(map[string]bool)
+	}
+	for _, sha := range missing {
+		m.promised[sha] = true
+	}
+	m.mu.Unlock()
+
+	if err := fetchPromisorObjects(m.repo, missing); err != nil {
+		return fmt.Errorf("could not fetch %d missing objects from promisor remote '%s': %w", len(missing), remote, err)
+	}
+	m.reloadPacks()
+	return nil
+}
+
+// reloadPacks makes the next read list the pack directory again, to pick
+// up packs written by another manager.
+func (m *ObjectManager) reloadPacks() {
+	m.mu.Lock()
+	m.packs, m.packsLoaded = nil, false
+	m.mu.Unlock()
+}
+
+// remoteUploadPack returns remote.<name>.uploadpack, the program to run
+// on the remote instead of git-upload-pack.
+func remoteUploadPack(repo *GitRepository, remote string) string {
+	return repo.Config().GetString(configKey("remote", remote, "uploadpack"))
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// PruneOptions controls which unreachable objects Prune removes.
+type PruneOptions struct {
+	Expire time.Time // Only objects last modified before this time are removed.
+	DryRun bool      // Report what would be removed without removing anything.
+}
+
+// PrunedObject is a loose object removed, or that would be removed, by Prune.
+type PrunedObject struct {
+	SHA  string
+	Type GitObjectType
+}
+
+// Prune removes loose objects that are not reachable from HEAD, any ref, any
+// reflog entry or the index and that are older than the expiry. Loose objects
+// that also exist in a pack are removed as well, since they are redundant.
+//
+// Parameters:
+// - repo: The repository to prune.
+// - opts: The expiry and dry-run settings.
+//
+// Returns:
+// - The unreachable objects that were (or, in a dry run, would be) removed.
+// - An error if reachability cannot be computed or an object cannot be removed.
+func Prune(repo *GitRepository, opts PruneOptions) ([]PrunedObject, error) {
+	defer trace.Start(trace.Perf, "prune", "dry-run", opts.DryRun)()
+
+	if PreciousObjects(repo) {
+		return nil, fmt.Errorf("cannot prune in a precious-objects repo")
+	}
+
+	objects := NewObjectManager(repo)
+	reachable, err := ReachableObjects(repo, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	loose, err := objects.LooseObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []PrunedObject
+	for _, sha := range loose {
+		if reachable[sha] {
+			continue
+		}
+
+		info, err := repo.fs.Stat(objects.loosePath(sha))
+		if err != nil {
+			return pruned, err
+		}
+		if !info.ModTime().Before(opts.Expire) {
+			continue
+		}
+
+		objType, _, err := objects.StatObject(sha)
+		if err != nil {
+			objType = "unknown"
+		}
+		pruned = append(pruned, PrunedObject{SHA: sha, Type: objType})
+
+		if opts.DryRun {
+			continue
+		}
+		if err := objects.removeLooseObject(sha); err != nil {
+			return pruned, err
+		}
+	}
+
+	if !opts.DryRun {
+		if _, _, err := prunePacked(objects); err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
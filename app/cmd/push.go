@@ -0,0 +1,726 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// Ref update outcomes reported by Push, matching git's push output.
+const (
+	PushUpToDate       = "up to date"
+	PushNewBranch      = "new branch"
+	PushNewTag         = "new tag"
+	PushNewRef         = "new reference"
+	PushDeleted        = "deleted"
+	PushFastForward    = "fast-forward"
+	PushForced         = "forced update"
+	PushNonFF          = "non-fast-forward"
+	PushFetchFirst     = "fetch first"
+	PushAlreadyExists  = "already exists"
+	PushStaleInfo      = "stale info"
+	PushNoRemoteRef    = "remote ref does not exist"
+	PushNoDelete       = "remote does not support deleting refs"
+	PushRemoteRejected = "remote rejected"
+	PushRemoteFailure  = "remote failure"
+	PushAtomicFailed   = "atomic push failed"
+)
+
+// PushOptions controls Push.
+type PushOptions struct {
+	Refspecs    []string  // Refspecs to push instead of remote.<name>.push or the current branch.
+	Force       bool      // Allow non fast-forward updates for every refspec, ignoring leases.
+	Leases      []string  // --force-with-lease values, "<ref>[:<expect>]", or "" for every ref pushed.
+	Delete      bool      // Delete the refs named by Refspecs on the remote.
+	Tags        bool      // Push every tag in addition to the refspecs.
+	Mirror      bool      // Make every ref of the remote match the local one, deleting the others; implied by remote.<name>.mirror.
+	SetUpstream bool      // Make each pushed branch track the ref it was pushed to.
+	Atomic      bool      // Update either every ref or none; the remote must support it.
+	Options     []string  // Push options handed to the hooks of the remote.
+	ReceivePack string    // Program to run on the remote instead of git-receive-pack.
+	Messages    io.Writer // Receives messages of the remote, such as hook output; discarded when nil.
+	DryRun      bool      // Report how the remote refs would be updated without sending anything.
+}
+
+// PushedRef describes what happened to one remote ref during a push.
+type PushedRef struct {
+	Local    string    // The local ref or revision pushed, empty for a deletion.
+	Remote   string    // The ref on the remote.
+	Old      string    // The remote value before the push, zeroSHA if it did not exist.
+	New      string    // The value pushed, zeroSHA for a deletion.
+	Status   string    // One of the Push* outcomes.
+	Reason   string    // Why the remote rejected the update, for PushRemoteRejected.
+	Upstream *Upstream // Set when --set-upstream made the local branch track this ref.
+
+	force bool
+}
+
+// Rejected reports whether the remote ref was left untouched because the
+// update was refused, here or by the remote.
+func (p PushedRef) Rejected() bool {
+	switch p.Status {
+	case PushNonFF, PushFetchFirst, PushAlreadyExists, PushStaleInfo, PushNoRemoteRef, PushNoDelete, PushRemoteRejected, PushRemoteFailure, PushAtomicFailed:
+		return true
+	}
+	return false
+}
+
+// pending reports whether the update still has to be sent to the remote.
+func (p PushedRef) pending() bool {
+	return p.Status != PushUpToDate && !p.Rejected()
+}
+
+// PushResult summarizes a call to Push.
+type PushResult struct {
+	URL  string      // Where the refs were pushed.
+	Refs []PushedRef // Every ref considered, in refspec order.
+}
+
+// pushLease is the value a remote ref is expected to have for a
+// --force-with-lease update to go ahead.
+type pushLease struct {
+	ref    string // The ref as given, empty for every ref.
+	expect string // The expected value, empty to use the remote-tracking ref.
+	given  bool   // Whether an expected value was given; an empty one means the ref must not exist.
+}
+
+// Push updates refs of a remote repository and sends the objects they
+// need. Like git, an update is refused unless it fast-forwards the remote
+// ref, the refspec is forced with "+" or --force, or a --force-with-lease
+// expectation for the ref holds: the remote ref still has the value the
+// remote-tracking ref, or the given value, says it has. The remote can
+// refuse updates too, and reports each refusal. Remote-tracking refs of a
+// configured remote follow the refs that were updated. An atomic push
+// sends nothing when any update is refused here, and the remote applies
+// all of the updates or none. A mirror push force-updates every local ref
+// on the remote and deletes the remote refs that do not exist locally. A
+// dry run reports the same outcomes but sends nothing.
+//
+// Parameters:
+// - repo: The repository to push from.
+// - remote: A configured remote name, or a URL or path.
+// - opts: Refspecs and update policy.
+//
+// Returns:
+// - How each ref was updated or why it was not.
+// - An error if the remote cannot be reached, a refspec is invalid or the
+// remote does not accept push options or atomic pushes.
+func Push(repo *GitRepository, remote string, opts PushOptions) (*PushResult, error) {
+	defer trace.Start(trace.Perf, "push", "remote", remote)()
+
+	url := ResolvePushURL(repo, remote)
+	configured := RemoteConfigured(repo, remote)
+	if configured && repo.Config().GetBool(configKey("remote", remote, "mirror")) {
+		opts.Mirror = true
+	}
+
+	refspecs, err := pushRefspecs(repo, remote, configured, opts)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := parsePushLeases(opts.Leases)
+	if err != nil {
+		return nil, err
+	}
+
+	receivePack := opts.ReceivePack
+	if receivePack == "" && configured {
+		receivePack = repo.Config().GetString(configKey("remote", remote, "receivepack"))
+	}
+	session, err := dialRemote(repo, url, "git-receive-pack", receivePack)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	offered := session.Advertisement.Capabilities
+	if len(opts.Options) > 0 && !offered.Has(transport.CapPushOptions) {
+		return nil, fmt.Errorf("the receiving end does not support push options")
+	}
+	if opts.Atomic && !offered.Has(transport.CapAtomic) {
+		return nil, fmt.Errorf("the receiving end does not support --atomic push")
+	}
+
+	advertised := make(map[string]string)
+	for _, ref := range session.Advertisement.Refs {
+		advertised[ref.Name] = ref.SHA
+	}
+
+	objects := NewObjectManager(repo)
+	updates, err := pushUpdates(repo, refspecs, advertised, opts.Force)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Mirror {
+		updates = append(updates, mirrorDeletions(updates, session.Advertisement.Refs)...)
+	}
+	for i := range updates {
+		update := &updates[i]
+		if lease := matchPushLease(leases, update.Remote); lease != nil && !update.force {
+			expect, err := leaseExpectation(repo, remote, configured, lease, update.Remote)
+			if err != nil {
+				return nil, err
+			}
+			if update.Old != expect {
+				update.Status = PushStaleInfo
+				continue
+			}
+			update.force = true
+		}
+		update.Status = pushStatus(objects, update, offered)
+	}
+
+	result := &PushResult{URL: AnonymizeURL(url), Refs: updates}
+	var pending []*PushedRef
+	refused := false
+	for i := range updates {
+		if updates[i].pending() {
+			pending = append(pending, &updates[i])
+		}
+		refused = refused || updates[i].Rejected()
+	}
+	if opts.Atomic && refused {
+		for _, update := range pending {
+			update.Status = PushAtomicFailed
+		}
+		pending = nil
+	}
+	if len(pending) == 0 || opts.DryRun {
+		if !session.Stateless {
+			_ = transport.NewEncoder(session).Flush()
+		}
+		return result, nil
+	}
+
+	if err := sendPushCommands(repo, objects, session, pending, opts); err != nil {
+		return nil, err
+	}
+
+	if configured {
+		if err := updateTrackingRefs(repo, remote, pending); err != nil {
+			return nil, err
+		}
+	}
+	if opts.SetUpstream {
+		for i := range updates {
+			update := &updates[i]
+			if update.Rejected() || update.New == zeroSHA || !strings.HasPrefix(update.Local, BranchesPrefix) {
+				continue
+			}
+			if err := setPushUpstream(repo, remote, update); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// pushRefspecs returns the refspecs a push applies: those given on the
+// command line, else remote.<name>.push of a configured remote, else the
+// current branch to the branch of the same name. --delete turns each name
+// into a deletion and --tags adds every tag. A mirror push takes every
+// ref, and cannot be combined with any of these.
+func pushRefspecs(repo *GitRepository, remote string, configured bool, opts PushOptions) ([]*Refspec, error) {
+	if opts.Mirror {
+		switch {
+		case opts.Delete:
+			return nil, fmt.Errorf("--delete is incompatible with --mirror")
+		case len(opts.Refspecs) > 0 || opts.Tags:
+			return nil, fmt.Errorf("--mirror can't be combined with refspecs")
+		}
+		refspec, err := ParseRefspec(MirrorRefspec)
+		if err != nil {
+			return nil, err
+		}
+		return []*Refspec{refspec}, nil
+	}
+
+	specs := opts.Refspecs
+	if opts.Delete {
+		if len(specs) == 0 {
+			return nil, fmt.Errorf("--delete doesn't make sense without any refs")
+		}
+		specs = make([]string, len(opts.Refspecs))
+		for i, name := range opts.Refspecs {
+			if strings.ContainsAny(name, ":+") {
+				return nil, fmt.Errorf("--delete only accepts plain target ref names")
+			}
+			specs[i] = ":" + name
+		}
+	}
+
+	if len(specs) == 0 && !opts.Tags {
+		if configured {
+			specs = repo.Config().GetStringSlice(configKey("remote", remote, "push"))
+		}
+		if len(specs) == 0 {
+			branch, ok := CurrentBranch(repo)
+			if !ok {
+				return nil, fmt.Errorf("you are not currently on a branch")
+			}
+			specs = []string{BranchesPrefix + branch}
+		}
+	}
+	if opts.Tags {
+		specs = append(specs, "refs/tags/*:refs/tags/*")
+	}
+
+	refspecs := make([]*Refspec, 0, len(specs))
+	for _, spec := range specs {
+		refspec, err := parsePushRefspec(spec)
+		if err != nil {
+			return nil, err
+		}
+		refspecs = append(refspecs, refspec)
+	}
+	return refspecs, nil
+}
+
+// parsePushRefspec parses a push refspec. Unlike in a fetch, the source of
+// a push may be any revision, such as HEAD~1 or an object name.
+func parsePushRefspec(spec string) (*Refspec, error) {
+	refspec, err := ParseRefspec(spec)
+	if err == nil {
+		return refspec, nil
+	}
+
+	src, dst, _ := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+	if src == "" || strings.HasPrefix(spec, "^") || strings.Contains(src, "*") || strings.Contains(dst, "*") || (dst != "" && !validRefName(dst)) {
+		return nil, err
+	}
+	return &Refspec{Src: src, Dst: dst, Force: strings.HasPrefix(spec, "+")}, nil
+}
+
+// pushUpdates turns refspecs into the remote refs to update. Sources are
+// resolved locally, as refs or revisions; a destination that is not a full
+// ref name is looked up among the advertised refs, or placed next to its
+// source, so "git push origin main:topic" creates refs/heads/topic.
+func pushUpdates(repo *GitRepository, refspecs []*Refspec, advertised map[string]string, force bool) ([]PushedRef, error) {
+	localRefs, err := ListRefs(repo)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(localRefs))
+	for _, ref := range localRefs {
+		names = append(names, ref.Name)
+	}
+
+	var updates []PushedRef
+	seen := make(map[string]bool)
+	add := func(update PushedRef) {
+		if seen[update.Remote] {
+			return
+		}
+		seen[update.Remote] = true
+		update.Old = zeroSHA
+		if sha, ok := advertised[update.Remote]; ok {
+			update.Old = sha
+		}
+		updates = append(updates, update)
+	}
+
+	for _, refspec := range refspecs {
+		if refspec.Negative {
+			continue
+		}
+		if refspec.IsPattern() {
+			for _, mapping := range MapRefs([]*Refspec{refspec}, names) {
+				sha, err := ResolveRef(repo, mapping.Src)
+				if err != nil {
+					return nil, err
+				}
+				add(PushedRef{Local: mapping.Src, Remote: mapping.Dst, New: sha, force: force || mapping.Force})
+			}
+			continue
+		}
+
+		if refspec.Src == "" {
+			dst, ok := expandAdvertisedRef(refspec.Dst, advertised)
+			if !ok {
+				dst = refspec.Dst
+			}
+			add(PushedRef{Remote: dst, New: zeroSHA, force: force || refspec.Force})
+			continue
+		}
+
+		local, sha, err := resolvePushSource(repo, refspec.Src)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := pushDestination(refspec, local, advertised)
+		if err != nil {
+			return nil, err
+		}
+		add(PushedRef{Local: local, Remote: dst, New: sha, force: force || refspec.Force})
+	}
+	return updates, nil
+}
+
+// mirrorDeletions returns forced deletions of the advertised refs that a
+// mirror push does not update, because they no longer exist locally.
+func mirrorDeletions(updates []PushedRef, advertised []transport.AdvertisedRef) []PushedRef {
+	kept := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		kept[update.Remote] = true
+	}
+	var deletions []PushedRef
+	for _, ref := range advertised {
+		if !strings.HasPrefix(ref.Name, "refs/") || strings.HasSuffix(ref.Name, "^{}") || kept[ref.Name] {
+			continue
+		}
+		kept[ref.Name] = true
+		deletions = append(deletions, PushedRef{Remote: ref.Name, Old: ref.SHA, New: zeroSHA, force: true})
+	}
+	return deletions
+}
+
+// resolvePushSource resolves the source side of a push refspec.
+//
+// Returns:
+// - The full name of the ref, or src itself for another revision.
+// - The object to push.
+// - An error if src names nothing.
+func resolvePushSource(repo *GitRepository, src string) (string, string, error) {
+	if name, err := ExpandRefName(repo, src); err == nil {
+		if sha, err := ResolveRef(repo, name); err == nil {
+			return name, sha, nil
+		}
+	}
+	sha, err := ResolveRevision(repo, src)
+	if err != nil {
+		return "", "", fmt.Errorf("src refspec '%s' does not match any", src)
+	}
+	return src, sha, nil
+}
+
+// pushDestination returns the remote ref a refspec with source local
+// updates.
+func pushDestination(refspec *Refspec, local string, advertised map[string]string) (string, error) {
+	dst := refspec.Dst
+	if dst == "" {
+		if !strings.HasPrefix(local, RefsDir+"/") {
+			return "", fmt.Errorf("the destination of '%s' must be given, as it is not a ref", refspec.Src)
+		}
+		return local, nil
+	}
+	if strings.HasPrefix(dst, RefsDir+"/") {
+		return dst, nil
+	}
+	if full, ok := expandAdvertisedRef(dst, advertised); ok {
+		return full, nil
+	}
+	for _, prefix := range []string{BranchesPrefix, "refs/tags/"} {
+		if strings.HasPrefix(local, prefix) {
+			return prefix + dst, nil
+		}
+	}
+	return "", fmt.Errorf("the destination '%s' is not a full ref name", dst)
+}
+
+// parsePushLeases parses --force-with-lease values.
+func parsePushLeases(specs []string) ([]*pushLease, error) {
+	leases := make([]*pushLease, 0, len(specs))
+	for _, spec := range specs {
+		lease := &pushLease{}
+		lease.ref, lease.expect, lease.given = strings.Cut(spec, ":")
+		if lease.ref == "" && spec != "" {
+			return nil, fmt.Errorf("invalid --force-with-lease value '%s'", spec)
+		}
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+// matchPushLease returns the lease that applies to a remote ref: one naming
+// it, by full or short name, before one covering every ref.
+func matchPushLease(leases []*pushLease, remoteRef string) *pushLease {
+	var all *pushLease
+	for _, lease := range leases {
+		switch lease.ref {
+		case "":
+			all = lease
+		case remoteRef, strings.TrimPrefix(remoteRef, BranchesPrefix), strings.TrimPrefix(remoteRef, "refs/tags/"):
+			return lease
+		}
+	}
+	return all
+}
+
+// leaseExpectation returns the value a leased remote ref must still have:
+// the given value, or else the remote-tracking ref of a configured remote.
+// When neither exists the remote ref is expected not to exist.
+func leaseExpectation(repo *GitRepository, remote string, configured bool, lease *pushLease, remoteRef string) (string, error) {
+	if lease.given {
+		if lease.expect == "" {
+			return zeroSHA, nil
+		}
+		sha, err := ResolveRevision(repo, lease.expect)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse expected object name '%s'", lease.expect)
+		}
+		return sha, nil
+	}
+
+	if !configured {
+		return zeroSHA, nil
+	}
+	if tracking := trackingRef(repo, remote, remoteRef); tracking != "" {
+		if sha, err := ResolveRef(repo, tracking); err == nil {
+			return sha, nil
+		}
+	}
+	return zeroSHA, nil
+}
+
+// trackingRef returns the remote-tracking ref that remote.<name>.fetch maps
+// a remote ref to, or an empty string if none.
+func trackingRef(repo *GitRepository, remote, remoteRef string) string {
+	refspecs, err := RemoteFetchRefspecs(repo, remote)
+	if err != nil {
+		return ""
+	}
+	for _, refspec := range refspecs {
+		if refspec.Negative {
+			continue
+		}
+		if dst, ok := refspec.MatchSource(remoteRef); ok && dst != "" {
+			return dst
+		}
+	}
+	return ""
+}
+
+// pushStatus decides locally how an update would change the remote ref,
+// or why it is refused before anything is sent.
+func pushStatus(objects *ObjectManager, update *PushedRef, offered transport.Capabilities) string {
+	switch {
+	case update.New == zeroSHA && update.Old == zeroSHA:
+		return PushNoRemoteRef
+	case update.New == zeroSHA && !offered.Has(transport.CapDeleteRefs):
+		return PushNoDelete
+	case update.New == zeroSHA:
+		return PushDeleted
+	case update.Old == update.New:
+		return PushUpToDate
+	case update.Old == zeroSHA && strings.HasPrefix(update.Remote, "refs/tags/"):
+		return PushNewTag
+	case update.Old == zeroSHA && strings.HasPrefix(update.Remote, BranchesPrefix):
+		return PushNewBranch
+	case update.Old == zeroSHA:
+		return PushNewRef
+	case update.force:
+		if fastForward, err := objects.IsAncestor(update.Old, update.New); err == nil && fastForward && !strings.HasPrefix(update.Remote, "refs/tags/") {
+			return PushFastForward
+		}
+		return PushForced
+	case strings.HasPrefix(update.Remote, "refs/tags/"):
+		return PushAlreadyExists
+	case !objects.Has(update.Old):
+		return PushFetchFirst
+	}
+	if fastForward, err := objects.IsAncestor(update.Old, update.New); err == nil && fastForward {
+		return PushFastForward
+	}
+	return PushNonFF
+}
+
+// sendPushCommands sends the ref updates, the push options and the pack of
+// objects the remote lacks, then records the remote's verdict on each
+// update in its status.
+func sendPushCommands(repo *GitRepository, objects *ObjectManager, session *transport.Session, pending []*PushedRef, opts PushOptions) error {
+	offered := session.Advertisement.Capabilities
+	request := transport.NewCapabilities(transport.CapReportStatus).
+		With(offered.Preferred(transport.CapSideBand64k, transport.CapSideBand)).
+		WithValue(transport.CapAgent, Agent)
+	if len(opts.Options) > 0 {
+		request = request.With(transport.CapPushOptions)
+	}
+	if opts.Atomic {
+		request = request.With(transport.CapAtomic)
+	}
+	capabilities := offered.Negotiate(request)
+
+	enc := transport.NewEncoder(session)
+	var tips []string
+	for i, update := range pending {
+		line := fmt.Sprintf("%s %s %s", update.Old, update.New, update.Remote)
+		if i == 0 {
+			line += "\x00" + capabilities.String()
+		}
+		if err := enc.Encode([]byte(line + "\n")); err != nil {
+			return err
+		}
+		if update.New != zeroSHA {
+			tips = append(tips, update.New)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+
+	if capabilities.Has(transport.CapPushOptions) {
+		for _, option := range opts.Options {
+			if err := enc.Encodef("%s\n", option); err != nil {
+				return err
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(tips) > 0 {
+		pack, err := pushPack(repo, objects, tips, session.Advertisement, capabilities.Has(transport.CapOfsDelta))
+		if err != nil {
+			return err
+		}
+		if _, err := session.Write(pack); err != nil {
+			return err
+		}
+	}
+
+	if !capabilities.Has(transport.CapReportStatus) {
+		return nil
+	}
+	messages := opts.Messages
+	if messages == nil {
+		messages = io.Discard
+	}
+	dec := transport.NewDecoder(session)
+	if capabilities.SidebandPacketSize() > 0 {
+		dec = transport.NewDecoder(transport.NewSidebandReader(dec, messages))
+	}
+	return readPushReport(dec, pending)
+}
+
+// pushPack builds the pack of the objects reachable from tips that the
+// remote does not have, judging by the refs it advertised. Advertised
+// objects missing here cannot be used to trim the pack.
+func pushPack(repo *GitRepository, objects *ObjectManager, tips []string, advertisement *transport.Advertisement, ofsDelta bool) ([]byte, error) {
+	var common []string
+	for _, ref := range advertisement.Refs {
+		if objects.Has(ref.SHA) {
+			common = append(common, ref.SHA)
+		}
+	}
+
+	exclude, err := collectObjects(objects, common, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	found, err := collectObjects(objects, tips, exclude, false)
+	if err != nil {
+		return nil, err
+	}
+	shas, err := sortObjectsForPack(objects, found)
+	if err != nil {
+		return nil, err
+	}
+	trace.Log(trace.Pack, "push objects", "tips", len(tips), "common", len(common), "objects", len(shas))
+
+	// Deltas are only sent to servers that can read them.
+	var packOpts PackOptions
+	if ofsDelta {
+		packOpts = LoadPackOptions(repo)
+	}
+	pack, _, _, err := objects.encodePack(shas, packOpts)
+	return pack, err
+}
+
+// readPushReport reads the report-status answer: "unpack <status>", then
+// "ok <ref>" or "ng <ref> <reason>" for each update. An update the report
+// does not mention is marked as a remote failure.
+func readPushReport(dec *transport.Decoder, pending []*PushedRef) error {
+	_, payload, err := dec.Read()
+	if err != nil {
+		return fmt.Errorf("reading push report: %w", err)
+	}
+	unpack, ok := strings.CutPrefix(strings.TrimSuffix(string(payload), "\n"), "unpack ")
+	if !ok {
+		return fmt.Errorf("protocol error: expected unpack status, got '%s'", strings.TrimSpace(string(payload)))
+	}
+	if unpack != "ok" {
+		trace.Log(trace.Pack, "remote unpack failed", "status", unpack)
+	}
+
+	byName := make(map[string]*PushedRef, len(pending))
+	for _, update := range pending {
+		byName[update.Remote] = update
+	}
+	reported := make(map[string]bool, len(pending))
+	for {
+		packetType, payload, err := dec.Read()
+		if err != nil {
+			return fmt.Errorf("reading push report: %w", err)
+		}
+		if packetType == transport.FlushPacket {
+			break
+		}
+
+		line := strings.TrimSuffix(string(payload), "\n")
+		if name, ok := strings.CutPrefix(line, "ok "); ok {
+			reported[name] = true
+			continue
+		}
+		rest, ok := strings.CutPrefix(line, "ng ")
+		if !ok {
+			return fmt.Errorf("protocol error: invalid push report line '%s'", line)
+		}
+		name, reason, _ := strings.Cut(rest, " ")
+		reported[name] = true
+		if update := byName[name]; update != nil {
+			update.Status, update.Reason = PushRemoteRejected, reason
+		}
+	}
+
+	for _, update := range pending {
+		if !reported[update.Remote] {
+			update.Status = PushRemoteFailure
+		}
+	}
+	return nil
+}
+
+// updateTrackingRefs moves the remote-tracking refs of the updates the
+// remote accepted, as the next fetch would.
+func updateTrackingRefs(repo *GitRepository, remote string, updates []*PushedRef) error {
+	for _, update := range updates {
+		tracking := trackingRef(repo, remote, update.Remote)
+		if update.Rejected() || tracking == "" {
+			continue
+		}
+		var err error
+		if update.New == zeroSHA {
+			err = DeleteRef(repo, tracking)
+		} else {
+			err = UpdateRef(repo, tracking, update.New, "update by push")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPushUpstream records the remote ref a branch was pushed to as its
+// upstream. Like git, a remote given as a URL is recorded as is.
+func setPushUpstream(repo *GitRepository, remote string, update *PushedRef) error {
+	branch := strings.TrimPrefix(update.Local, BranchesPrefix)
+	if err := SetConfig(repo, "branch", branch, "remote", remote); err != nil {
+		return err
+	}
+	if err := SetConfig(repo, "branch", branch, "merge", update.Remote); err != nil {
+		return err
+	}
+
+	upstream, ok := BranchUpstream(repo, branch)
+	if !ok {
+		upstream = &Upstream{Remote: remote, Merge: update.Remote, Ref: update.Remote}
+	}
+	update.Upstream = upstream
+	trace.Log(trace.Ref, "set upstream", "branch", branch, "remote", remote, "merge", update.Remote)
+	return nil
+}
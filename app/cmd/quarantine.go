@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// quarantinePrefix names the temporary object directories of incoming pushes,
+// as git's tmp_objdir does.
+const quarantinePrefix = "tmp_objdir-incoming-"
+
+// Quarantine is a temporary object directory for objects received from a
+// push. Objects written through its ObjectManager land in the quarantine,
+// while reads see both the quarantine and the object database. Nothing
+// reaches the object database until Migrate is called, so a rejected push
+// leaves no trace.
+type Quarantine struct {
+	Objects *ObjectManager
+	dir     string
+	repo    *GitRepository
+}
+
+// NewQuarantine creates an empty quarantine inside the objects directory, so
+// that migrating it is a rename on the same file system.
+//
+// Returns:
+// - The quarantine.
+// - An error if the directory cannot be created.
+func NewQuarantine(repo *GitRepository) (*Quarantine, error) {
+	dir, err := repo.fs.MkdirTemp(createRepoPath(repo, ObjectsDir), quarantinePrefix)
+	if err != nil {
+		return nil, err
+	}
+	objects := NewObjectManager(repo)
+	objects.quarantine = dir
+	trace.Log(trace.Object, "quarantine created", "dir", dir)
+	return &Quarantine{Objects: objects, dir: dir, repo: repo}, nil
+}
+
+// Dir returns the path of the quarantine directory.
+func (q *Quarantine) Dir() string {
+	return q.dir
+}
+
+// CheckConnected verifies that everything reachable from tip is available.
+// Objects already in the object database are assumed to be complete, as git
+// does, so only the objects received into the quarantine are walked.
+//
+// Returns:
+// - An error naming the first missing object.
+func (q *Quarantine) CheckConnected(tip string) error {
+	main := NewObjectManager(q.repo)
+	pending := []string{tip}
+	seen := make(map[string]bool)
+
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[sha] || main.Has(sha) {
+			continue
+		}
+		seen[sha] = true
+
+		objType, data, err := q.Objects.ReadObject(sha)
+		if err != nil {
+			return fmt.Errorf("missing object %s", sha)
+		}
+
+		switch objType {
+		case CommitType:
+			commit, err := parseCommit(sha, data)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, commit.Tree)
+			pending = append(pending, commit.Parents...)
+
+		case TreeType:
+			entries, err := parseTree(data)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				if !entry.IsGitlink() {
+					pending = append(pending, entry.SHA)
+				}
+			}
+
+		case TagType:
+			kvlm, err := ParseKvlm(data)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, string(kvlm.Get("object")))
+		}
+	}
+	return nil
+}
+
+// Migrate moves the quarantined objects into the object database and
+// removes the quarantine. Pack indexes are moved after their packs so a
+// reader never sees an index without its pack. Objects that already exist
+// are left alone.
+//
+// Returns:
+// - An error if a file cannot be moved; objects moved so far stay.
+func (q *Quarantine) Migrate() error {
+	defer trace.Start(trace.Object, "quarantine migrate", "dir", q.dir)()
+	if err := q.repo.syncPending(); err != nil {
+		return err
+	}
+
+	var files []string
+	fsys := q.repo.fs
+	err := walkDir(fsys, q.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), "tmp_") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return !strings.HasSuffix(files[i], ".idx") && strings.HasSuffix(files[j], ".idx")
+	})
+
+	objectsDir := createRepoPath(q.repo, ObjectsDir)
+	for _, file := range files {
+		rel, err := filepath.Rel(q.dir, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(objectsDir, rel)
+		if pathExists(fsys, target) {
+			continue
+		}
+		if err := fsys.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := fsys.Rename(file, target); err != nil {
+			return err
+		}
+	}
+	return q.Discard()
+}
+
+// removeLoose deletes the loose objects of the quarantine, once they have
+// been written to a pack inside it.
+func (q *Quarantine) removeLoose() error {
+	entries, err := listDir(q.repo.fs, q.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && len(entry.Name()) == 2 {
+			if err := q.repo.fs.RemoveAll(filepath.Join(q.dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Discard deletes the quarantine and everything in it. It is safe to call
+// after Migrate.
+func (q *Quarantine) Discard() error {
+	return q.repo.fs.RemoveAll(q.dir)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuotePathEnabled reports core.quotePath: whether bytes above 0x7f in
+// paths are escaped in output. Control characters, quotes and backslashes
+// are escaped either way.
+func QuotePathEnabled(repo *GitRepository) bool {
+	if repo.Config().IsSet("core.quotePath") {
+		return repo.Config().GetBool("core.quotePath")
+	}
+	return true
+}
+
+// cQuoteEscapes are the characters with a short escape in C-quoted paths.
+var cQuoteEscapes = map[byte]string{
+	'\a': `\a`, '\b': `\b`, '\t': `\t`, '\n': `\n`, '\v': `\v`, '\f': `\f`, '\r': `\r`,
+	'"': `\"`, '\\': `\\`,
+}
+
+// QuotePath C-quotes a path for output the way git does: a path containing
+// control characters, quotes, backslashes or (with quoteHighBytes) non-ASCII
+// bytes is wrapped in double quotes, with those bytes escaped as \t, \" or
+// octal \303. Other paths are returned unchanged. Output terminated by NUL
+// (-z) is never quoted.
+//
+// Parameters:
+// - name: The path.
+// - quoteHighBytes: Whether to escape bytes above 0x7f, i.e. core.quotePath.
+//
+// Returns:
+// - The path as it should be printed.
+func QuotePath(name string, quoteHighBytes bool) string {
+	if !needsQuoting(name, quoteHighBytes) {
+		return name
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case cQuoteEscapes[c] != "":
+			b.WriteString(cQuoteEscapes[c])
+		case c < 0x20 || c == 0x7f || (c >= 0x80 && quoteHighBytes):
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// needsQuoting reports whether QuotePath would change name.
+func needsQuoting(name string, quoteHighBytes bool) bool {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x20 || c == '"' || c == '\\' || c == 0x7f || (c >= 0x80 && quoteHighBytes) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// refRoots returns the objects pointed to by HEAD and every ref.
+func refRoots(repo *GitRepository) ([]string, error) {
+	refs, err := ListRefs(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []string
+	if head, err := ResolveRef(repo, HeadFile); err == nil {
+		roots = append(roots, head)
+	}
+	for _, ref := range refs {
+		roots = append(roots, ref.SHA)
+	}
+	return roots, nil
+}
+
+// reachabilityRoots returns every object that keeps history alive: HEAD, all
+// refs, every old and new value recorded in the reflogs and the blobs staged
+// in the index.
+func reachabilityRoots(repo *GitRepository) ([]string, error) {
+	roots, err := refRoots(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := reflogNames(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range logs {
+		entries, err := ReadReflog(repo, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			roots = append(roots, entry.Old, entry.New)
+		}
+	}
+
+	index, err := ReadIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range index.Entries {
+		if entry.Mode != 0160000 {
+			roots = append(roots, entry.SHA)
+		}
+	}
+
+	return roots, nil
+}
+
+// ReachableObjects computes the set of objects reachable from HEAD, the refs,
+// the reflogs and the index. Objects that are missing from the repository are
+// skipped rather than treated as errors, so a damaged repository can still be
+// inspected.
+func ReachableObjects(repo *GitRepository, objects *ObjectManager) (map[string]bool, error) {
+	defer trace.Start(trace.Perf, "reachability")()
+
+	roots, err := reachabilityRoots(repo)
+	if err != nil {
+		return nil, err
+	}
+	return collectObjects(objects, roots, nil, true)
+}
+
+// collectObjects walks the object graph from roots and returns every object
+// found. Objects in exclude, and everything only reachable through them, are
+// left out.
+//
+// Parameters:
+// - objects: The object store to read from.
+// - roots: The objects to start from.
+// - exclude: Objects the walk must not enter. May be nil.
+// - allowMissing: Whether missing objects are skipped instead of reported.
+//
+// Returns:
+// - The set of objects found.
+// - An error if an object is malformed, or missing when allowMissing is false.
+func collectObjects(objects *ObjectManager, roots []string, exclude map[string]bool, allowMissing bool) (map[string]bool, error) {
+	pending := append([]string(nil), roots...)
+	found := make(map[string]bool)
+
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if sha == zeroSHA || found[sha] || exclude[sha] {
+			continue
+		}
+
+		// Checking first keeps a partial clone from fetching every
+		// object it left out.
+		if allowMissing && isValidSHA(sha) && !objects.Has(objects.replaced(sha)) {
+			trace.Log(trace.Object, "missing during reachability walk", "sha", sha)
+			found[sha] = true
+			continue
+		}
+		objType, data, err := objects.ReadObject(sha)
+		if err != nil {
+			if allowMissing {
+				trace.Log(trace.Object, "missing during reachability walk", "sha", sha)
+				found[sha] = true
+				continue
+			}
+			return nil, err
+		}
+		found[sha] = true
+
+		switch objType {
+		case CommitType:
+			commit, err := parseCommit(sha, data)
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, commit.Tree)
+			pending = append(pending, commit.Parents...)
+
+		case TreeType:
+			entries, err := parseTree(data)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if !entry.IsGitlink() {
+					pending = append(pending, entry.SHA)
+				}
+			}
+
+		case TagType:
+			kvlm, err := ParseKvlm(data)
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, string(kvlm.Get("object")))
+		}
+	}
+
+	return found, nil
+}
+
+// PeelObject follows annotated tags until it reaches a non-tag object.
+//
+// Returns:
+// - The SHA-1 and type of the peeled object.
+// - An error if an object in the chain cannot be read.
+func (m *ObjectManager) PeelObject(sha string) (string, GitObjectType, error) {
+	for {
+		objType, data, err := m.ReadObject(sha)
+		if err != nil {
+			return "", "", err
+		}
+		if objType != TagType {
+			return sha, objType, nil
+		}
+
+		kvlm, err := ParseKvlm(data)
+		if err != nil {
+			return "", "", err
+		}
+		sha = string(kvlm.Get("object"))
+	}
+}
+
+// PeelToType follows tags, and from a commit to its tree, until an object
+// of the wanted type is reached, as git cat-file <type> <object> does.
+//
+// Parameters:
+// - sha: The object to start from.
+// - want: The type of object wanted.
+//
+// Returns:
+// - The SHA-1 of the object of type want.
+// - An error if the object cannot be peeled to that type.
+func (m *ObjectManager) PeelToType(sha string, want GitObjectType) (string, error) {
+	start := sha
+	for {
+		objType, data, err := m.ReadObject(sha)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case objType == want:
+			return sha, nil
+		case objType == TagType:
+			kvlm, err := ParseKvlm(data)
+			if err != nil {
+				return "", err
+			}
+			sha = string(kvlm.Get("object"))
+		case objType == CommitType && want == TreeType:
+			kvlm, err := ParseKvlm(data)
+			if err != nil {
+				return "", err
+			}
+			sha = string(kvlm.Get("tree"))
+		default:
+			return "", fmt.Errorf("object %s cannot be peeled to a %s", start, want)
+		}
+	}
+}
+
+// ContainsChecker tells which commits contain a given commit, that is have
+// it as an ancestor, as the --contains options of git tag and git branch
+// do. It remembers the answer for every commit it walks through, so that
+// checking many tips that share history stays cheap.
+type ContainsChecker struct {
+	objects *ObjectManager
+	target  string
+	known   map[string]bool
+}
+
+// NewContainsChecker returns a ContainsChecker for the commit target.
+func (m *ObjectManager) NewContainsChecker(target string) *ContainsChecker {
+	return &ContainsChecker{objects: m, target: target, known: map[string]bool{target: true}}
+}
+
+// Contains reports whether the commit sha contains the target. A commit
+// contains itself.
+//
+// Returns:
+// - Whether target is reachable from sha through parent links.
+// - An error if a commit cannot be read.
+func (c *ContainsChecker) Contains(sha string) (bool, error) {
+	type pending struct {
+		sha     string
+		parents []string // Those not known yet not to contain the target.
+	}
+	var stack []pending
+	visit := func(sha string) error {
+		commit, err := c.objects.ReadCommit(sha)
+		if err != nil {
+			return err
+		}
+		stack = append(stack, pending{sha: sha, parents: commit.Parents})
+		return nil
+	}
+
+	if _, ok := c.known[sha]; !ok {
+		if err := visit(sha); err != nil {
+			return false, err
+		}
+	}
+	// Walk depth first, settling a commit once one of its parents contains
+	// the target or none of them does.
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if len(top.parents) == 0 {
+			c.known[top.sha] = false
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		contains, ok := c.known[top.parents[0]]
+		switch {
+		case ok && contains:
+			c.known[top.sha] = true
+			stack = stack[:len(stack)-1]
+		case ok:
+			top.parents = top.parents[1:]
+		default:
+			if err := visit(top.parents[0]); err != nil {
+				return false, err
+			}
+		}
+	}
+	return c.known[sha], nil
+}
+
+// CommitFilter selects commits by their ancestry, as the --contains,
+// --merged and --no-merged options of git branch and git tag do: a commit
+// passes when it contains at least one of the Contains commits, is
+// reachable from at least one of the Merged commits and from none of the
+// NoMerged ones. A filter without commits lets everything pass.
+type CommitFilter struct {
+	contains []*ContainsChecker
+	merged   map[string]bool // Nil without Merged commits.
+	noMerged map[string]bool
+}
+
+// NewCommitFilter resolves the revisions of a CommitFilter.
+//
+// Parameters:
+// - repo: The repository.
+// - contains: Revisions that passing commits must contain, one at least.
+// - merged: Revisions that passing commits must be merged into, one at least.
+// - noMerged: Revisions that passing commits must not be merged into.
+//
+// Returns:
+// - The filter.
+// - An error if a revision does not name a commit or history cannot be read.
+func NewCommitFilter(repo *GitRepository, contains, merged, noMerged []string) (*CommitFilter, error) {
+	objects := NewObjectManager(repo)
+	resolve := func(revs []string) ([]string, error) {
+		shas := make([]string, 0, len(revs))
+		for _, rev := range revs {
+			sha, err := resolveCommit(repo, objects, rev)
+			if err != nil {
+				return nil, fmt.Errorf("malformed object name %s", rev)
+			}
+			shas = append(shas, sha)
+		}
+		return shas, nil
+	}
+
+	filter := &CommitFilter{}
+	shas, err := resolve(contains)
+	if err != nil {
+		return nil, err
+	}
+	for _, sha := range shas {
+		filter.contains = append(filter.contains, objects.NewContainsChecker(sha))
+	}
+	if len(merged) > 0 {
+		if shas, err = resolve(merged); err != nil {
+			return nil, err
+		}
+		if filter.merged, err = objects.ancestors(shas); err != nil {
+			return nil, err
+		}
+	}
+	if shas, err = resolve(noMerged); err != nil {
+		return nil, err
+	}
+	if filter.noMerged, err = objects.ancestors(shas); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// Empty reports whether the filter lets every commit pass.
+func (f *CommitFilter) Empty() bool {
+	return len(f.contains) == 0 && f.merged == nil && len(f.noMerged) == 0
+}
+
+// Match reports whether a commit passes the filter.
+func (f *CommitFilter) Match(sha string) (bool, error) {
+	if (f.merged != nil && !f.merged[sha]) || f.noMerged[sha] {
+		return false, nil
+	}
+	if len(f.contains) == 0 {
+		return true, nil
+	}
+	for _, checker := range f.contains {
+		if contains, err := checker.Contains(sha); err != nil || contains {
+			return contains, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,650 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// RebaseAction is what an interactive rebase does with one commit.
+type RebaseAction string
+
+// The actions of a rebase todo list.
+const (
+	RebasePick   RebaseAction = "pick"   // Use the commit.
+	RebaseReword RebaseAction = "reword" // Use the commit, but edit its message.
+	RebaseSquash RebaseAction = "squash" // Meld the commit into the previous one and edit the combined message.
+	RebaseFixup  RebaseAction = "fixup"  // Meld the commit into the previous one and drop its message.
+	RebaseDrop   RebaseAction = "drop"   // Leave the commit out.
+)
+
+// rebaseActions maps the words of a todo list, long and short, to actions.
+var rebaseActions = map[string]RebaseAction{
+	"pick": RebasePick, "p": RebasePick,
+	"reword": RebaseReword, "r": RebaseReword,
+	"squash": RebaseSquash, "s": RebaseSquash,
+	"fixup": RebaseFixup, "f": RebaseFixup,
+	"drop": RebaseDrop, "d": RebaseDrop,
+}
+
+// RebaseStep is one line of a rebase todo list.
+type RebaseStep struct {
+	Action  RebaseAction
+	Commit  string
+	Subject string
+}
+
+// RebaseTodoFile holds the todo list while the user edits it, as in git.
+const RebaseTodoFile = "rebase-merge/git-rebase-todo"
+
+// rebaseTodoHelp is shown below the todo list in the editor.
+const rebaseTodoHelp = `
+Commands:
+p, pick <commit> = use commit
+r, reword <commit> = use commit, but edit the commit message
+s, squash <commit> = use commit, but meld into previous commit
+f, fixup <commit> = like "squash", but discard this commit's log message
+d, drop <commit> = remove commit
+
+These lines can be re-ordered; they are executed from top to bottom.
+
+If you remove a line here THAT COMMIT WILL BE LOST.
+
+However, if you remove everything, the rebase will be aborted.
+`
+
+// FormatRebaseTodo writes steps as the lines of a todo list, such as
+// "pick 1a2b3c4 Add the parser".
+func FormatRebaseTodo(objects *ObjectManager, steps []RebaseStep) []byte {
+	var out bytes.Buffer
+	for _, step := range steps {
+		fmt.Fprintf(&out, "%s %s %s\n", step.Action, objects.ShortSHA(step.Commit, 0), step.Subject)
+	}
+	return out.Bytes()
+}
+
+// ParseRebaseTodo reads a todo list as edited by the user. Blank lines and
+// comments are skipped, as is the "noop" of an empty list, actions may be abbreviated to their first letter
+// and commits may be given by any revision.
+//
+// Parameters:
+// - repo: The repository to resolve commits in.
+// - todo: The todo list.
+// - commentChar: The character comment lines start with.
+//
+// Returns:
+// - The steps, in order.
+// - An error naming the first line with an unknown action or commit.
+func ParseRebaseTodo(repo *GitRepository, todo []byte, commentChar string) ([]RebaseStep, error) {
+	var steps []RebaseStep
+	for number, line := range strings.Split(string(todo), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "noop" || strings.HasPrefix(line, commentChar) {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		action, ok := rebaseActions[fields[0]]
+		if !ok || len(fields) < 2 {
+			return nil, fmt.Errorf("invalid line %d: %s", number+1, line)
+		}
+		sha, err := ResolveRevision(repo, fields[1]+"^{commit}")
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %d: %s: no such commit '%s'", number+1, line, fields[1])
+		}
+		step := RebaseStep{Action: action, Commit: sha}
+		if len(fields) == 3 {
+			step.Subject = fields[2]
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// AutosquashEnabled reports whether rebase.autoSquash asks interactive
+// rebases to rearrange fixup! and squash! commits by default.
+func AutosquashEnabled(repo *GitRepository) bool {
+	return repo.Config().GetBool("rebase.autosquash")
+}
+
+// skipAutosquashPrefix strips a "fixup! " or "squash! " prefix from a
+// subject.
+func skipAutosquashPrefix(subject string) (string, bool) {
+	for _, prefix := range []string{FixupPrefix, SquashPrefix} {
+		if rest, ok := strings.CutPrefix(subject, prefix); ok {
+			return rest, true
+		}
+	}
+	return subject, false
+}
+
+// Autosquash rearranges a todo list the way git rebase --autosquash does:
+// every picked commit whose subject starts with "fixup! " or "squash! " is
+// moved right after the commit it names, behind earlier fixups of the same
+// commit, and turned into a fixup or squash step. The target is the
+// earliest step whose subject is the rest of the subject, after stripping
+// any further prefixes; failing that, the commit the rest names if it is
+// a single word; failing that, the earliest step whose subject starts with
+// the rest. Commits without a target are left where they are.
+//
+// Parameters:
+// - repo: The repository, for commits named by revision.
+// - steps: The todo list, in order.
+//
+// Returns:
+// - The rearranged todo list.
+func Autosquash(repo *GitRepository, steps []RebaseStep) []RebaseStep {
+	// next links each step to the fixup that follows it, tail to the last
+	// fixup of its chain; -1 marks the end.
+	next := make([]int, len(steps))
+	tail := make([]int, len(steps))
+	attached := make([]bool, len(steps))
+	bySubject := make(map[string]int)
+	byCommit := make(map[string]int)
+	for i := range steps {
+		next[i], tail[i] = -1, -1
+		byCommit[steps[i].Commit] = i
+	}
+
+	for i := range steps {
+		step := &steps[i]
+		if step.Action == RebaseDrop {
+			continue
+		}
+		target := -1
+		if rest, ok := skipAutosquashPrefix(step.Subject); ok {
+			for ok {
+				rest, ok = skipAutosquashPrefix(strings.TrimLeft(rest, " \t"))
+			}
+			target = autosquashTarget(repo, steps[:i], rest, bySubject, byCommit)
+		}
+
+		if target < 0 {
+			if _, seen := bySubject[step.Subject]; !seen {
+				bySubject[step.Subject] = i
+			}
+			continue
+		}
+		if strings.HasPrefix(step.Subject, strings.TrimSpace(FixupPrefix)) {
+			step.Action = RebaseFixup
+		} else {
+			step.Action = RebaseSquash
+		}
+		last := target
+		if tail[target] >= 0 {
+			last = tail[target]
+		}
+		next[i], next[last] = next[last], i
+		tail[target] = i
+		attached[i] = true
+	}
+
+	rearranged := make([]RebaseStep, 0, len(steps))
+	for i := range steps {
+		if attached[i] {
+			continue
+		}
+		for j := i; j >= 0; j = next[j] {
+			rearranged = append(rearranged, steps[j])
+		}
+	}
+	return rearranged
+}
+
+// autosquashTarget finds the step among earlier that a fixup! or squash!
+// subject, without its prefixes, names, or returns -1.
+func autosquashTarget(repo *GitRepository, earlier []RebaseStep, name string, bySubject, byCommit map[string]int) int {
+	if i, ok := bySubject[name]; ok {
+		return i
+	}
+	if !strings.Contains(name, " ") {
+		if sha, err := ResolveRevision(repo, name+"^{commit}"); err == nil {
+			if i, ok := byCommit[sha]; ok && i < len(earlier) {
+				return i
+			}
+		}
+	}
+	for i, step := range earlier {
+		if strings.HasPrefix(step.Subject, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// RebaseOptions controls Rebase.
+type RebaseOptions struct {
+	Upstream    string // The revision whose history is left out of the commits to replay.
+	Onto        string // Where to replay the commits; the upstream when empty.
+	Interactive bool   // Let the user edit the todo list first.
+	Autosquash  bool   // With Interactive, rearrange fixup! and squash! commits.
+}
+
+// RebaseResult describes a finished rebase.
+type RebaseResult struct {
+	Branch   string   // The rebased branch, empty when HEAD is detached.
+	Head     string   // The new tip.
+	UpToDate bool     // The branch already was on top of the upstream; nothing was done.
+	Resolved []string // Conflicted paths resolved with a resolution recorded by rerere, once per conflict.
+}
+
+// Rebase replays the commits of the current branch that are not in the
+// upstream on top of another commit and moves the branch to the result,
+// as git rebase does. Merge commits are left out. An interactive rebase
+// first lets the user edit the list of commits to replay, optionally
+// after Autosquash has rearranged it.
+//
+// The commits are replayed in memory: a commit whose parent is already
+// the new base is kept as it is, any other is merged onto it with
+// MergeTrees, and a commit that becomes empty is dropped. Conflicts that
+// rerere has a recorded resolution for are resolved with it. If a commit
+// still does not apply cleanly, the rebase stops before anything in the
+// repository is changed. Otherwise the worktree, the index and the branch
+// are updated at the end, and ORIG_HEAD records the old tip. Until then
+// the commits made are kept reachable by a ref under refs/tmp.
+//
+// Parameters:
+// - repo: A repository with a worktree and no local changes.
+// - opts: The upstream, the new base and whether to edit the todo list.
+//
+// Returns:
+// - The new tip of the branch.
+// - An error if there are local changes, a revision is unknown, the todo
+// list is invalid or empty, a commit does not apply or a message is empty.
+func Rebase(repo *GitRepository, opts RebaseOptions) (*RebaseResult, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	defer trace.Start(trace.Perf, "rebase", "upstream", opts.Upstream, "onto", opts.Onto)()
+
+	status, err := Status(repo)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(status.Unmerged) > 0:
+		return nil, fmt.Errorf("cannot rebase: you need to resolve your current index first")
+	case len(status.Unstaged) > 0:
+		return nil, fmt.Errorf("cannot rebase: you have unstaged changes")
+	case len(status.Staged) > 0:
+		return nil, fmt.Errorf("cannot rebase: your index contains uncommitted changes")
+	}
+
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return nil, err
+	}
+	if head.SHA == "" {
+		return nil, fmt.Errorf("cannot rebase: branch '%s' has no commits yet", head.Branch())
+	}
+	upstream, err := ResolveRevision(repo, opts.Upstream+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream '%s'", opts.Upstream)
+	}
+	onto := upstream
+	if opts.Onto != "" {
+		if onto, err = ResolveRevision(repo, opts.Onto+"^{commit}"); err != nil {
+			return nil, fmt.Errorf("does not point to a valid commit '%s'", opts.Onto)
+		}
+	}
+
+	objects := NewObjectManager(repo)
+	result := &RebaseResult{Branch: head.Branch(), Head: head.SHA}
+	if !opts.Interactive {
+		bases, err := objects.MergeBases(onto, head.SHA)
+		if err != nil {
+			return nil, err
+		}
+		if len(bases) == 1 && bases[0] == onto && upstream == onto {
+			result.UpToDate = true
+			return result, nil
+		}
+	}
+
+	commits, err := objects.RevList([]string{head.SHA}, []string{upstream}, RevListOptions{Reverse: true, NoMerges: true})
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]RebaseStep, 0, len(commits))
+	for _, sha := range commits {
+		commit, err := objects.ReadCommit(sha)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, RebaseStep{Action: RebasePick, Commit: sha, Subject: commitSubjectLine(commit)})
+	}
+	if opts.Interactive {
+		if opts.Autosquash {
+			steps = Autosquash(repo, steps)
+		}
+		if steps, err = editRebaseTodo(repo, objects, steps, upstream, head.SHA, onto); err != nil {
+			return nil, err
+		}
+	}
+
+	replay := &rebaseReplay{repo: repo, objects: objects, tip: onto, rerere: RerereEnabled(repo), keep: NewTempRef(repo, "rebase")}
+	defer replay.keep.Release()
+	if replay.committer, err = commitIdent(repo, CommitterRole); err != nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		endsChain := i+1 == len(steps) || (steps[i+1].Action != RebaseSquash && steps[i+1].Action != RebaseFixup)
+		if err := replay.apply(step, endsChain); err != nil {
+			return nil, err
+		}
+	}
+	result.Head, result.Resolved = replay.tip, replay.resolved
+
+	if result.Head != head.SHA {
+		checkout, err := Checkout(repo, result.Head, CheckoutOptions{KeepHead: true})
+		if err != nil {
+			return nil, err
+		}
+		if checkout.Refused() {
+			return nil, fmt.Errorf("untracked working tree files would be overwritten: %s", strings.Join(checkout.Untracked, ", "))
+		}
+	}
+	if err := UpdateRef(repo, OrigHeadFile, head.SHA, ""); err != nil {
+		return nil, err
+	}
+	// Like git, HEAD is detached while the commits are replayed, and the
+	// branch moved at the end.
+	base := opts.Upstream
+	if opts.Onto != "" {
+		base = opts.Onto
+	}
+	if err := UpdateRef(repo, HeadFile, result.Head, "rebase (start): checkout "+base); err != nil {
+		return nil, err
+	}
+	if head.Ref == "" {
+		return result, nil
+	}
+	if err := UpdateRef(repo, head.Ref, result.Head, fmt.Sprintf("rebase (finish): %s onto %s", head.Ref, onto)); err != nil {
+		return nil, err
+	}
+	trace.Log(trace.Ref, "rebase", "ref", head.Ref, "from", head.SHA, "to", result.Head)
+	return result, UpdateSymbolicRef(repo, HeadFile, head.Ref, "rebase (finish): returning to "+head.Ref)
+}
+
+// OrigHeadFile records the tip of a branch before a command that rewrites
+// it, such as rebase, so that it can be restored.
+const OrigHeadFile = "ORIG_HEAD"
+
+// commitSubjectLine returns the first line of the message of a commit.
+func commitSubjectLine(commit *Commit) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(string(commit.Message)), "\n")
+	return subject
+}
+
+// commitIdent returns the identity line of the given role.
+func commitIdent(repo *GitRepository, role string) (string, error) {
+	sig, err := Ident(repo, role)
+	if err != nil {
+		return "", err
+	}
+	return sig.String(), nil
+}
+
+// editRebaseTodo lets the user edit the todo list in their editor, as
+// git rebase -i does, and reads it back.
+func editRebaseTodo(repo *GitRepository, objects *ObjectManager, steps []RebaseStep, upstream, head, onto string) ([]RebaseStep, error) {
+	commentChar, err := CommentChar(repo)
+	if err != nil {
+		return nil, err
+	}
+	file := createRepoPath(repo, RebaseTodoFile)
+	if err := repo.fs.MkdirAll(createRepoPath(repo, "rebase-merge"), 0755); err != nil {
+		return nil, err
+	}
+	defer repo.fs.RemoveAll(createRepoPath(repo, "rebase-merge"))
+
+	plural := "s"
+	if len(steps) == 1 {
+		plural = ""
+	}
+	help := fmt.Sprintf("Rebase %s..%s onto %s (%d command%s)\n", objects.ShortSHA(upstream, 0), objects.ShortSHA(head, 0), objects.ShortSHA(onto, 0), len(steps), plural)
+	todo := FormatRebaseTodo(objects, steps)
+	if len(steps) == 0 {
+		todo = []byte("noop\n")
+	}
+	todo = append(append(todo, '\n'), CommentLines([]byte(help+rebaseTodoHelp), commentChar)...)
+	if err := writeFileAtomic(repo.fs, file, todo, 0644); err != nil {
+		return nil, err
+	}
+	if err := runEditor(repo, file); err != nil {
+		return nil, err
+	}
+
+	edited, err := readFile(repo.fs, file)
+	if err != nil {
+		return nil, err
+	}
+	if steps, err = ParseRebaseTodo(repo, edited, commentChar); err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("nothing to do")
+	}
+	return steps, nil
+}
+
+// rebaseReplay replays the steps of a rebase one by one on top of tip.
+type rebaseReplay struct {
+	repo      *GitRepository
+	objects   *ObjectManager
+	committer string
+	tip       string   // The last commit made, or the base when there is none yet.
+	picked    bool     // A step has been applied, so a squash has a commit to meld into.
+	messages  [][]byte // The messages of the commits melded into tip, in order.
+	skipped   []bool   // Which of those are fixups, whose messages are dropped.
+	squashed  bool     // Some commit was melded into tip by a squash.
+	rerere    bool     // Conflicts may be resolved with resolutions rerere recorded.
+	resolved  []string // The paths resolved that way.
+	keep      *TempRef // Keeps the commits made so far from a concurrent prune.
+}
+
+// apply replays one step. endsChain tells whether no squash or fixup
+// follows, so that a combined message is final.
+func (r *rebaseReplay) apply(step RebaseStep, endsChain bool) error {
+	if step.Action == RebaseDrop {
+		return nil
+	}
+	commit, err := r.objects.ReadCommit(step.Commit)
+	if err != nil {
+		return err
+	}
+	meld := step.Action == RebaseSquash || step.Action == RebaseFixup
+	if meld && !r.picked {
+		return fmt.Errorf("cannot '%s' without a previous commit", step.Action)
+	}
+
+	base := ""
+	parentTree := ""
+	if len(commit.Parents) > 0 {
+		base = commit.Parents[0]
+		parent, err := r.objects.ReadCommit(base)
+		if err != nil {
+			return err
+		}
+		parentTree = parent.Tree
+	}
+	tip, err := r.objects.ReadCommit(r.tip)
+	if err != nil {
+		return err
+	}
+
+	if !meld {
+		r.picked, r.squashed = true, false
+		r.messages, r.skipped = [][]byte{commit.Message}, []bool{false}
+		if base == r.tip {
+			// The commit already sits on the new base.
+			r.tip = commit.SHA
+		} else {
+			tree, err := r.mergeCommit(commit, parentTree, tip.Tree)
+			if err != nil {
+				return err
+			}
+			if tree == tip.Tree && commit.Tree != parentTree {
+				trace.Log(trace.Ref, "rebase drop empty", "commit", commit.SHA)
+				return nil
+			}
+			if r.tip, err = r.writeCommit(tree, []string{r.tip}, commit.Author, commit.Message); err != nil {
+				return err
+			}
+		}
+		if step.Action != RebaseReword {
+			return nil
+		}
+		tip, err := r.objects.ReadCommit(r.tip)
+		if err != nil {
+			return err
+		}
+		message, err := r.editMessage(tip.Message)
+		if err != nil {
+			return err
+		}
+		r.messages[0] = message
+		r.tip, err = r.writeCommit(tip.Tree, tip.Parents, tip.Author, message)
+		return err
+	}
+
+	// Meld the commit into the tip, keeping the author and the message of
+	// the tip until a squash asks for the messages to be combined.
+	tree, err := r.mergeCommit(commit, parentTree, tip.Tree)
+	if err != nil {
+		return err
+	}
+	r.messages = append(r.messages, commit.Message)
+	r.skipped = append(r.skipped, step.Action == RebaseFixup)
+	r.squashed = r.squashed || step.Action == RebaseSquash
+
+	message := r.messages[0]
+	if endsChain && r.squashed {
+		if message, err = r.combinedMessage(); err != nil {
+			return err
+		}
+		if message, err = r.editMessage(message); err != nil {
+			return err
+		}
+	}
+	r.tip, err = r.writeCommit(tree, tip.Parents, tip.Author, message)
+	return err
+}
+
+// editMessage lets the user edit the message of a rewritten commit.
+func (r *rebaseReplay) editMessage(message []byte) ([]byte, error) {
+	message, err := EditMessage(r.repo, message, commitHelp)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) == 0 {
+		return nil, fmt.Errorf("aborting commit due to empty commit message")
+	}
+	return message, nil
+}
+
+// mergeCommit applies the changes a commit made to its parent onto the
+// tree of the tip, and writes the result.
+func (r *rebaseReplay) mergeCommit(commit *Commit, parentTree, tipTree string) (string, error) {
+	labels := MergeLabels{Ours: "HEAD", Theirs: fmt.Sprintf("%s (%s)", r.objects.ShortSHA(commit.SHA, 0), commitSubjectLine(commit))}
+	merged, err := r.objects.MergeTrees(parentTree, tipTree, commit.Tree, labels)
+	if err != nil {
+		return "", err
+	}
+	if len(merged.Conflicts) > 0 && r.rerere {
+		if err := r.reuseResolutions(merged); err != nil {
+			return "", err
+		}
+	}
+	if len(merged.Conflicts) > 0 {
+		paths := make([]string, 0, len(merged.Conflicts))
+		for _, conflict := range merged.Conflicts {
+			paths = append(paths, conflict.Path)
+		}
+		return "", fmt.Errorf("could not apply %s... %s: conflict in %s", r.objects.ShortSHA(commit.SHA, 0), commitSubjectLine(commit), strings.Join(paths, ", "))
+	}
+	return r.objects.WriteTreeFromFiles(merged.Files)
+}
+
+// reuseResolutions resolves the content conflicts of a merge for which
+// rerere recorded a resolution, and drops them from its conflicts.
+func (r *rebaseReplay) reuseResolutions(merged *TreeMergeResult) error {
+	var remaining []MergeConflict
+	for _, conflict := range merged.Conflicts {
+		entry, ok := merged.Files[conflict.Path]
+		if !ok || conflict.Ours == nil || conflict.Theirs == nil || modeKind(entry.Mode) != "file" {
+			remaining = append(remaining, conflict)
+			continue
+		}
+		_, content, err := r.objects.ReadObject(entry.SHA)
+		if err != nil {
+			return err
+		}
+		resolved, ok, err := RerereResolveContent(r.repo, content)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			remaining = append(remaining, conflict)
+			continue
+		}
+		if entry.SHA, err = r.objects.WriteObject(BlobType, resolved, true); err != nil {
+			return err
+		}
+		merged.Files[conflict.Path] = entry
+		r.resolved = append(r.resolved, conflict.Path)
+	}
+	merged.Conflicts = remaining
+	return nil
+}
+
+// combinedMessage builds the message of commits melded by squashes the way
+// git presents it for editing: every message under a comment numbering it,
+// with the messages of fixups and the subjects of squash! commits
+// commented out.
+func (r *rebaseReplay) combinedMessage() ([]byte, error) {
+	commentChar, err := CommentChar(r.repo)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s This is a combination of %d commits.\n", commentChar, len(r.messages))
+	for i, message := range r.messages {
+		switch {
+		case i == 0:
+			fmt.Fprintf(&out, "%s This is the 1st commit message:\n\n", commentChar)
+		case r.skipped[i]:
+			fmt.Fprintf(&out, "\n%s The commit message #%d will be skipped:\n\n", commentChar, i+1)
+			out.Write(CommentLines(bytes.TrimRight(message, "\n"), commentChar))
+			continue
+		default:
+			fmt.Fprintf(&out, "\n%s This is the commit message #%d:\n\n", commentChar, i+1)
+			// The subject of a squash! commit only named its target.
+			if _, ok := skipAutosquashPrefix(string(message)); ok {
+				subject, body, _ := bytes.Cut(message, []byte("\n\n"))
+				out.Write(CommentLines(subject, commentChar))
+				out.WriteByte('\n')
+				message = body
+			}
+		}
+		out.Write(message)
+	}
+	return Stripspace(out.Bytes(), ""), nil
+}
+
+// writeCommit writes a commit by the current committer.
+func (r *rebaseReplay) writeCommit(tree string, parents []string, author string, message []byte) (string, error) {
+	kvlm := &Kvlm{Message: message}
+	kvlm.Add("tree", []byte(tree))
+	for _, parent := range parents {
+		kvlm.Add("parent", []byte(parent))
+	}
+	kvlm.Add("author", []byte(author))
+	kvlm.Add("committer", []byte(r.committer))
+	sha, err := r.objects.WriteObject(CommitType, kvlm.Serialize(), true)
+	if err != nil {
+		return "", err
+	}
+	return sha, r.keep.Keep(sha)
+}
@@ -0,0 +1,431 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// receivePackCapabilities are the protocol capabilities offered by ReceivePack.
+var receivePackCapabilities = transport.NewCapabilities(transport.CapReportStatus, transport.CapDeleteRefs, transport.CapOfsDelta, transport.CapAtomic)
+
+// atomicFailure is reported for the updates of an atomic push that were
+// not applied because another update of the push failed.
+const atomicFailure = "atomic push failure"
+
+// ReceivePackOptions controls a single receive-pack session.
+type ReceivePackOptions struct {
+	AdvertiseRefsOnly bool      // Only send the ref advertisement.
+	StatelessRPC      bool      // Serve one request of a smart HTTP exchange; no advertisement is sent.
+	HookOutput        io.Writer // Receives the output of hooks and policy warnings; discarded when nil.
+}
+
+// refUpdateCommand is one "<old> <new> <ref>" line sent by a pushing client.
+type refUpdateCommand struct {
+	old    string
+	new    string
+	name   string
+	status string   // Empty on success, otherwise the reason reported to the client.
+	lock   *refLock // Held between lockRefUpdate and applyRefUpdate.
+}
+
+// ReceivePack serves the server side of a push over a bidirectional stream:
+// it advertises refs, reads the requested ref updates and the pack carrying
+// the new objects, then updates the refs and reports the outcome. Updates
+// must pass the pre-receive hook, the receive.deny* policies of
+// checkRefPolicy and the update hook; the post-receive hook is told about
+// the refs that were updated.
+//
+// Parameters:
+// - repo: The repository being pushed to.
+// - r: The stream of client requests.
+// - w: The stream of server responses.
+// - opts: Session settings.
+//
+// Returns:
+// - An error if the conversation fails. Rejected ref updates are reported to
+// the client and are not errors. When the client asks for an atomic push,
+// either every update is applied or none is.
+func ReceivePack(repo *GitRepository, r io.Reader, w io.Writer, opts ReceivePackOptions) error {
+	defer trace.Start(trace.Pack, "receive-pack", "repo", repo.GitDir)()
+
+	out := bufio.NewWriter(w)
+	enc := transport.NewEncoder(out)
+	dec := transport.NewDecoder(r)
+
+	if !opts.StatelessRPC {
+		refs, err := namespacedRefs(repo)
+		if err != nil {
+			return err
+		}
+
+		advertised := make([]advertisedRef, 0, len(refs))
+		for _, ref := range refs {
+			advertised = append(advertised, advertisedRef{name: ref.Name, sha: ref.SHA})
+		}
+		capabilities := receivePackCapabilities.WithValue(transport.CapAgent, Agent)
+		if err := writeRefAdvertisement(enc, advertised, capabilities); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	if opts.AdvertiseRefsOnly {
+		return nil
+	}
+
+	commands, clientCapabilities, err := readRefUpdateCommands(dec)
+	if err != nil || len(commands) == 0 {
+		return err
+	}
+
+	// Incoming objects wait in a quarantine until every check has passed,
+	// so a failed or rejected push never touches the object database.
+	unpackStatus := "ok"
+	var quarantine *Quarantine
+	if needsPack(commands) {
+		if quarantine, err = NewQuarantine(repo); err != nil {
+			return err
+		}
+		defer quarantine.Discard()
+
+		pack, err := ReadPackStream(r)
+		if err == nil {
+			_, err = StorePack(repo, pack, UnpackOptions{Strict: FsckObjects(repo, "receive"), Into: quarantine.Objects})
+		}
+		if err != nil {
+			unpackStatus = err.Error()
+		}
+	}
+
+	accepted := false
+	for _, command := range commands {
+		switch {
+		case unpackStatus != "ok":
+			command.status = "unpacker error"
+		case command.new != zeroSHA:
+			if err := quarantine.CheckConnected(command.new); err != nil {
+				trace.Log(trace.Ref, "receive-pack connectivity", "ref", command.name, "error", err)
+				command.status = "missing necessary objects"
+				continue
+			}
+			accepted = true
+		default:
+			accepted = true
+		}
+	}
+
+	atomic := clientCapabilities.Has(transport.CapAtomic)
+	if atomic && failAtomicPush(commands) {
+		accepted = false
+	}
+	if accepted {
+		accepted = runPreReceiveHook(repo, quarantine, commands, opts.HookOutput)
+	}
+
+	if quarantine != nil && accepted {
+		if err := quarantine.Migrate(); err != nil {
+			unpackStatus = err.Error()
+		}
+	}
+
+	// Every update is checked before any is applied, so that an atomic
+	// push can be refused as a whole.
+	objects := NewObjectManager(repo)
+	for _, command := range commands {
+		if command.status != "" {
+			continue
+		}
+		if unpackStatus != "ok" {
+			command.status = "unpacker error"
+			continue
+		}
+		command.status = checkRefPolicy(repo, objects, command, opts.HookOutput)
+	}
+	// Every ref is locked and checked against the value the client expects
+	// before any is updated, so that an atomic push fails as a whole
+	// without having to undo anything.
+	for _, command := range commands {
+		if command.status == "" {
+			command.status = lockRefUpdate(repo, objects, command)
+		}
+	}
+	if atomic && failAtomicPush(commands) {
+		for _, command := range commands {
+			if command.lock != nil {
+				command.lock.release()
+			}
+		}
+	} else {
+		for _, command := range commands {
+			if command.lock != nil {
+				command.status = applyRefUpdate(command)
+			}
+		}
+	}
+	for _, command := range commands {
+		trace.Log(trace.Ref, "receive-pack update", "ref", command.name, "old", command.old, "new", command.new, "status", command.status)
+	}
+	if input := hookInput(commands); input.Len() > 0 {
+		RunHook(repo, "post-receive", HookRun{Stdin: input, Output: opts.HookOutput})
+	}
+
+	if !clientCapabilities.Has(transport.CapReportStatus) {
+		return nil
+	}
+
+	if err := enc.Encodef("unpack %s\n", unpackStatus); err != nil {
+		return err
+	}
+	for _, command := range commands {
+		var err error
+		if command.status == "" {
+			err = enc.Encodef("ok %s\n", command.name)
+		} else {
+			err = enc.Encodef("ng %s %s\n", command.name, command.status)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// readRefUpdateCommands reads the ref update commands up to the first flush
+// packet.
+//
+// Returns:
+// - The requested updates.
+// - The capabilities requested on the first command.
+// - An error if a line is malformed.
+func readRefUpdateCommands(dec *transport.Decoder) ([]*refUpdateCommand, transport.Capabilities, error) {
+	var commands []*refUpdateCommand
+	var capabilities transport.Capabilities
+
+	for {
+		packetType, payload, err := dec.Read()
+		if err == io.EOF && len(commands) == 0 {
+			return nil, capabilities, nil
+		}
+		if err != nil {
+			return nil, transport.Capabilities{}, err
+		}
+		if packetType == transport.FlushPacket {
+			return commands, capabilities, nil
+		}
+
+		line, capabilityList, hasCapabilities := strings.Cut(strings.TrimSuffix(string(payload), "\n"), "\x00")
+		if hasCapabilities && len(commands) == 0 {
+			capabilities = transport.ParseCapabilities(capabilityList)
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || !isValidSHA(fields[0]) || !isValidSHA(fields[1]) {
+			return nil, transport.Capabilities{}, fmt.Errorf("receive-pack: protocol error, invalid command '%s'", line)
+		}
+		commands = append(commands, &refUpdateCommand{old: fields[0], new: fields[1], name: fields[2]})
+	}
+}
+
+// hookInput renders the "<old> <new> <ref>" lines the pre-receive and
+// post-receive hooks read, for the commands that have not failed.
+func hookInput(commands []*refUpdateCommand) *bytes.Buffer {
+	var input bytes.Buffer
+	for _, command := range commands {
+		if command.status == "" {
+			fmt.Fprintf(&input, "%s %s %s\n", command.old, command.new, command.name)
+		}
+	}
+	return &input
+}
+
+// runPreReceiveHook runs the pre-receive hook before any ref is updated.
+// The hook sees the quarantined objects through the environment, as git
+// sets it up. When the hook fails every pending command is declined.
+//
+// Returns:
+// - Whether the push may go on.
+func runPreReceiveHook(repo *GitRepository, quarantine *Quarantine, commands []*refUpdateCommand, output io.Writer) bool {
+	input := hookInput(commands)
+	if input.Len() == 0 {
+		return true
+	}
+	run := HookRun{Stdin: input, Output: output}
+	if quarantine != nil {
+		objectsDir, _ := filepath.Abs(createRepoPath(repo, ObjectsDir))
+		run.Env = []string{
+			"GIT_QUARANTINE_PATH=" + quarantine.Dir(),
+			"GIT_OBJECT_DIRECTORY=" + quarantine.Dir(),
+			"GIT_ALTERNATE_OBJECT_DIRECTORIES=" + objectsDir,
+		}
+	}
+
+	if _, err := RunHook(repo, "pre-receive", run); err != nil {
+		trace.Log(trace.Ref, "pre-receive declined", "error", err)
+		for _, command := range commands {
+			if command.status == "" {
+				command.status = "pre-receive hook declined"
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// checkRefPolicy applies the server side rules to one update before it is
+// made, in git's order:
+//   - receive.denyCurrentBranch refuses updates of the branch checked out
+//     in a non-bare repository, unless set to warn or ignore;
+//   - receive.denyDeletes refuses all deletions, and
+//     receive.denyDeleteCurrent, on by default, that of the current branch;
+//   - receive.denyNonFastForwards refuses updates that lose commits;
+//   - the update hook, given the ref and its old and new values, may
+//     decline any update.
+//
+// Returns:
+// - An empty string when the update may go ahead, otherwise the reason
+// reported to the client.
+func checkRefPolicy(repo *GitRepository, objects *ObjectManager, command *refUpdateCommand, output io.Writer) string {
+	name := NamespacePrefix() + command.name
+	current, _ := ReadSymbolicRef(repo, HeadFile)
+	deleting := command.new == zeroSHA
+
+	if name == current && !deleting && !repo.IsBare() {
+		switch policy := strings.ToLower(repo.Config().GetString("receive.denycurrentbranch")); policy {
+		case "ignore", "false", "no", "off", "0":
+		case "warn":
+			if output != nil {
+				fmt.Fprintf(output, "warning: updating the current branch\n")
+			}
+		default:
+			return "branch is currently checked out"
+		}
+	}
+
+	if deleting && strings.HasPrefix(command.name, "refs/") {
+		if repo.Config().GetBool("receive.denydeletes") {
+			return "deletion prohibited"
+		}
+		if name == current && (!repo.Config().IsSet("receive.denydeletecurrent") || repo.Config().GetBool("receive.denydeletecurrent")) {
+			return "deletion of the current branch prohibited"
+		}
+	}
+
+	if repo.Config().GetBool("receive.denynonfastforwards") && command.old != zeroSHA && !deleting {
+		oldType, _, errOld := objects.StatObject(command.old)
+		newType, _, errNew := objects.StatObject(command.new)
+		if errOld == nil && errNew == nil && oldType == CommitType && newType == CommitType {
+			if ok, err := objects.IsAncestor(command.old, command.new); err != nil || !ok {
+				return "non-fast-forward"
+			}
+		}
+	}
+
+	if _, err := RunHook(repo, "update", HookRun{Args: []string{command.name, command.old, command.new}, Output: output}); err != nil {
+		return "hook declined"
+	}
+	return ""
+}
+
+// failAtomicPush fails every update of an atomic push once one of them has
+// failed.
+//
+// Returns:
+// - Whether any update failed.
+func failAtomicPush(commands []*refUpdateCommand) bool {
+	failed := false
+	for _, command := range commands {
+		failed = failed || command.status != ""
+	}
+	if failed {
+		for _, command := range commands {
+			if command.status == "" {
+				command.status = atomicFailure
+			}
+		}
+	}
+	return failed
+}
+
+// needsPack reports whether any command creates or updates a ref, in which
+// case the client follows the commands with a pack.
+func needsPack(commands []*refUpdateCommand) bool {
+	for _, command := range commands {
+		if command.new != zeroSHA {
+			return true
+		}
+	}
+	return false
+}
+
+// lockRefUpdate takes the lock of the ref a command updates, then checks
+// that the ref still has the value the client expects and that the new
+// object exists. The ref is stored in the current namespace. On success the
+// lock is left in command.lock for applyRefUpdate.
+//
+// Returns:
+// - An empty string on success, otherwise the reason the update was refused.
+func lockRefUpdate(repo *GitRepository, objects *ObjectManager, command *refUpdateCommand) string {
+	if !strings.HasPrefix(command.name, "refs/") || !validRefName(command.name) {
+		return "funny refname"
+	}
+	name := NamespacePrefix() + command.name
+
+	lock, err := lockRef(repo, name)
+	if err != nil {
+		trace.Log(trace.Ref, "receive-pack lock failed", "ref", command.name, "error", err)
+		return "failed to lock"
+	}
+	current := zeroSHA
+	if refExists(repo, name) {
+		if current, err = ResolveRef(repo, name); err != nil {
+			lock.release()
+			return "failed to lock"
+		}
+	}
+	if current != command.old {
+		lock.release()
+		return "stale info"
+	}
+	if command.new != zeroSHA && !objects.Has(command.new) {
+		lock.release()
+		return "missing necessary objects"
+	}
+	command.lock = lock
+	return ""
+}
+
+// applyRefUpdate creates, updates or deletes the ref of a command through
+// the lock lockRefUpdate took, releasing it. Deleting a ref that is already
+// gone succeeds.
+//
+// Returns:
+// - An empty string on success, otherwise the reason the update failed.
+func applyRefUpdate(command *refUpdateCommand) string {
+	lock := command.lock
+	command.lock = nil
+	if command.new != zeroSHA {
+		if err := lock.update(command.new, "push"); err != nil {
+			return "failed to write"
+		}
+		return ""
+	}
+	if command.old == zeroSHA {
+		lock.release()
+		return ""
+	}
+	if err := lock.delete(); err != nil {
+		return "failed to delete"
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const LogsDir = "logs"
+
+// ReflogEntry is a single line of a ref's reflog.
+type ReflogEntry struct {
+	Old       string // The previous value of the ref.
+	New       string // The new value of the ref.
+	Committer string // Who made the change and when.
+	Message   string // Why the ref changed.
+}
+
+// ReadReflog reads the reflog of a ref, oldest entry first. A ref without a
+// reflog has no entries.
+func ReadReflog(repo *GitRepository, name string) ([]ReflogEntry, error) {
+	data, err := readFile(repo.fs, createRepoPath(repo, LogsDir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ReflogEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		header, message, _ := strings.Cut(line, "\t")
+		fields := strings.SplitN(header, " ", 3)
+		if len(fields) < 3 || !isValidSHA(fields[0]) || !isValidSHA(fields[1]) {
+			return nil, fmt.Errorf("malformed reflog line in '%s': %s", name, line)
+		}
+
+		entries = append(entries, ReflogEntry{
+			Old:       fields[0],
+			New:       fields[1],
+			Committer: fields[2],
+			Message:   message,
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// reflogNames lists the refs that have a reflog, such as HEAD and refs/heads/master.
+func reflogNames(repo *GitRepository) ([]string, error) {
+	root := createRepoPath(repo, LogsDir)
+	var names []string
+
+	err := walkDir(repo.fs, root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+
+	return names, err
+}
+
+// keepsReflog reports whether the updates of a ref are logged, following
+// core.logAllRefUpdates: by default, in a repository with a worktree, those
+// of HEAD, branches, remote-tracking branches and notes are, and with
+// "always" those of every ref. A ref that has a reflog keeps it up to date
+// regardless.
+func keepsReflog(repo *GitRepository, name string) bool {
+	if pathExists(repo.fs, createRepoPath(repo, LogsDir, filepath.FromSlash(name))) {
+		return true
+	}
+	const key = "core.logallrefupdates"
+	switch {
+	case strings.EqualFold(repo.Config().GetString(key), "always"):
+		return true
+	case repo.Config().IsSet(key) && !repo.Config().GetBool(key):
+		return false
+	case !repo.Config().IsSet(key) && repo.IsBare():
+		return false
+	}
+	if name == HeadFile {
+		return true
+	}
+	for _, prefix := range []string{BranchesPrefix, "refs/remotes/", "refs/notes/"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// logRefUpdate records the move of a ref from old to new in its reflog
+// with message, unless message is empty or the ref keeps no reflog.
+func logRefUpdate(repo *GitRepository, name, old, new, message string) error {
+	if message == "" || !keepsReflog(repo, name) {
+		return nil
+	}
+	return appendReflog(repo, name, old, new, message)
+}
+
+// appendReflog records a change of a ref at the end of its reflog, in the
+// name of the current committer. Like git, it does without a configured
+// name, which only commits and tags need.
+func appendReflog(repo *GitRepository, name, old, new, message string) error {
+	entries, err := ReadReflog(repo, name)
+	if err != nil {
+		return err
+	}
+	committer, err := Ident(repo, CommitterRole)
+	if err != nil {
+		committer = Signature{Name: loginName(), Email: defaultEmail(), When: time.Now()}
+	}
+	if old == "" {
+		old = zeroSHA
+	}
+	entries = append(entries, ReflogEntry{Old: old, New: new, Committer: committer.String(), Message: message})
+	return writeReflog(repo, name, entries)
+}
+
+// writeReflog replaces the reflog of a ref with entries, oldest first.
+func writeReflog(repo *GitRepository, name string, entries []ReflogEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		fmt.Fprintf(&buf, "%s %s %s\t%s\n", entry.Old, entry.New, entry.Committer, entry.Message)
+	}
+	return writeFileAtomic(repo.fs, createRepoPath(repo, LogsDir, filepath.FromSlash(name)), buf.Bytes(), 0644)
+}
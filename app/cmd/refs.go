@@ -0,0 +1,641 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+const (
+	RefsDir        = "refs"
+	PackedRefsFile = "packed-refs"
+	symbolicPrefix = "ref: "
+	maxSymrefDepth = 5
+)
+
+// Ref is a named reference to an object.
+type Ref struct {
+	Name     string // The full name of the ref, e.g. refs/heads/master.
+	SHA      string // The object the ref points to.
+	Symbolic bool   // The ref points at another ref, like refs/remotes/origin/HEAD.
+}
+
+// readPackedRefs parses the packed-refs file of the repository.
+//
+// Returns:
+// - A map from ref name to object SHA-1. Peeled lines are ignored.
+// - An error if the file exists but cannot be read.
+func readPackedRefs(repo *GitRepository) (map[string]string, error) {
+	refs := make(map[string]string)
+	data, err := readFile(repo.fs, createRepoPath(repo, PackedRefsFile))
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+
+		sha, name, ok := strings.Cut(line, " ")
+		if !ok || !isValidSHA(sha) {
+			return nil, fmt.Errorf("malformed packed-refs line '%s'", line)
+		}
+		refs[name] = sha
+	}
+	return refs, scanner.Err()
+}
+
+// lockPackedRefs takes packed-refs.lock, which keeps other writers of the
+// packed-refs file out while it is read, changed and written back through
+// writePackedRefs.
+func lockPackedRefs(repo *GitRepository) (*refLock, error) {
+	return lockRef(repo, PackedRefsFile)
+}
+
+// writePackedRefs replaces the packed-refs file with the given refs by
+// renaming its held lock over it.
+func writePackedRefs(lock *refLock, refs map[string]string) error {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("# pack-refs with: sorted \n")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s %s\n", refs[name], name)
+	}
+
+	return lock.commit(buf.Bytes())
+}
+
+// readRefFile reads a loose ref and returns its raw content without the
+// trailing newline. ok is false when the loose ref does not exist.
+func readRefFile(repo *GitRepository, name string) (content string, ok bool, err error) {
+	data, err := readFile(repo.fs, createRepoPath(repo, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSpace(string(data)), true, nil
+}
+
+// ResolveRef follows a ref, including symbolic refs such as HEAD, to the
+// object it points to.
+//
+// Parameters:
+// - repo: The repository containing the ref.
+// - name: The full name of the ref, e.g. HEAD or refs/heads/master.
+//
+// Returns:
+// - The SHA-1 the ref points to.
+// - An error if the ref does not exist or the chain of symbolic refs is too deep.
+func ResolveRef(repo *GitRepository, name string) (string, error) {
+	current := name
+	for depth := 0; depth <= maxSymrefDepth; depth++ {
+		content, ok, err := readRefFile(repo, current)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			packed, err := readPackedRefs(repo)
+			if err != nil {
+				return "", err
+			}
+			sha, found := packed[current]
+			if !found {
+				return "", fmt.Errorf("ref '%s' not found", current)
+			}
+			trace.Log(trace.Ref, "resolve", "ref", name, "sha", sha, "source", "packed")
+			return sha, nil
+		}
+
+		if target, isSymbolic := strings.CutPrefix(content, symbolicPrefix); isSymbolic {
+			current = target
+			continue
+		}
+
+		if !isValidSHA(content) {
+			return "", fmt.Errorf("ref '%s' is corrupt", current)
+		}
+		trace.Log(trace.Ref, "resolve", "ref", name, "sha", content, "source", "loose")
+		return content, nil
+	}
+
+	return "", fmt.Errorf("symbolic ref '%s' nested too deeply", name)
+}
+
+// UpdateRef points a ref at the given object, creating it if needed. The
+// change is recorded in the reflog of the ref, and in that of HEAD as well
+// when HEAD is attached to the ref, as git does; see logRefUpdate.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The full name of the ref, e.g. refs/heads/master, or HEAD to detach it.
+// - sha: The object the ref is to point at.
+// - message: Why the ref moved, e.g. "commit: Fix typo", for the reflogs;
+// "" leaves them alone, as for ORIG_HEAD or when the caller writes them.
+//
+// Returns:
+// - An error if sha is not an object name or a file cannot be written.
+func UpdateRef(repo *GitRepository, name, sha, message string) error {
+	if !isValidSHA(sha) {
+		return fmt.Errorf("invalid object name '%s'", sha)
+	}
+
+	lock, err := lockRef(repo, name)
+	if err != nil {
+		return err
+	}
+	return lock.update(sha, message)
+}
+
+// refLock is the "<ref>.lock" file held while a ref is updated. As in git,
+// creating it exclusively keeps concurrent writers of the same ref out: the
+// value of the ref read after taking the lock cannot change before the lock
+// is renamed over the ref or released.
+type refLock struct {
+	repo *GitRepository
+	name string
+	path string // The path of the ref; the lock is path+lockSuffix.
+	file File   // Nil once the lock is committed or released.
+}
+
+// lockRef takes the lock of a ref, creating the directories it needs.
+//
+// Returns:
+// - The held lock, which must be updated or released.
+// - An error if another process holds the lock or it cannot be created.
+func lockRef(repo *GitRepository, name string) (*refLock, error) {
+	path := createRepoPath(repo, filepath.FromSlash(name))
+	if err := repo.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := repo.fs.OpenFile(path+lockSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("unable to create '%s': another process seems to be updating the ref; remove the file if it crashed", path+lockSuffix)
+		}
+		return nil, err
+	}
+	trace.Log(trace.Ref, "lock", "ref", name)
+	return &refLock{repo: repo, name: name, path: path, file: file}, nil
+}
+
+// update writes sha into the lock and renames it over the ref, releasing
+// the lock, then logs the change; see UpdateRef.
+func (l *refLock) update(sha, message string) error {
+	repo := l.repo
+	old, _ := ResolveRef(repo, l.name)
+	_, symbolic := ReadSymbolicRef(repo, l.name)
+	trace.Log(trace.Ref, "update", "ref", l.name, "sha", sha)
+
+	if err := l.commit([]byte(sha + "\n")); err != nil {
+		return err
+	}
+
+	// Like git, an update that leaves the ref as it was is not logged,
+	// except in the reflog of HEAD.
+	if old != sha || symbolic {
+		if err := logRefUpdate(repo, l.name, old, sha, message); err != nil {
+			return err
+		}
+	}
+	if target, ok := ReadSymbolicRef(repo, HeadFile); ok && target == l.name {
+		return logRefUpdate(repo, HeadFile, old, sha, message)
+	}
+	return nil
+}
+
+// commit writes data into the lock and renames it over the locked file,
+// releasing the lock.
+func (l *refLock) commit(data []byte) error {
+	repo := l.repo
+	err := repo.syncPending()
+	policy := repo.fsyncPolicyOf(fsyncReference)
+	if err == nil {
+		_, err = l.file.Write(data)
+	}
+	if err == nil && policy == fsyncNow {
+		err = syncFile(l.file)
+	}
+	if err == nil {
+		err = l.file.Close()
+		l.file = nil
+	}
+	if err == nil {
+		err = repo.fs.Rename(l.path+lockSuffix, l.path)
+	}
+	if err != nil {
+		l.release()
+		repo.fs.Remove(l.path + lockSuffix)
+		return err
+	}
+	return repo.syncWritten(policy, l.path)
+}
+
+// delete deletes the ref, both loose and packed, with its reflog, then
+// releases the lock.
+func (l *refLock) delete() error {
+	defer l.release()
+	return DeleteRef(l.repo, l.name)
+}
+
+// release gives up the lock without touching the ref. Releasing a lock
+// that was already committed or released does nothing.
+func (l *refLock) release() {
+	if l.file == nil {
+		return
+	}
+	l.file.Close()
+	l.file = nil
+	l.repo.fs.Remove(l.path + lockSuffix)
+}
+
+// UpdateSymbolicRef points a symbolic ref such as HEAD at another ref. As
+// in git, the reflog of the symbolic ref records the move from the commit
+// it resolved to before to the one it resolves to now, when there is one.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The symbolic ref, e.g. HEAD.
+// - target: The full name of the ref it is to point at.
+// - message: Why it moved, e.g. "checkout: moving from master to topic";
+// "" leaves the reflog alone.
+//
+// Returns:
+// - An error if a file cannot be written.
+func UpdateSymbolicRef(repo *GitRepository, name, target, message string) error {
+	old, _ := ResolveRef(repo, name)
+	trace.Log(trace.Ref, "update", "ref", name, "target", target)
+	if err := writeRepoFile(repo, fsyncReference, createRepoPath(repo, filepath.FromSlash(name)), []byte(symbolicPrefix+target+"\n"), 0644); err != nil {
+		return err
+	}
+	sha, err := ResolveRef(repo, target)
+	if err != nil {
+		return nil
+	}
+	return logRefUpdate(repo, name, old, sha, message)
+}
+
+// DeleteRef removes a ref, both its loose file and its packed-refs entry,
+// together with its reflog.
+func DeleteRef(repo *GitRepository, name string) error {
+	trace.Log(trace.Ref, "delete", "ref", name)
+
+	// The packed entry goes first, as in git, so that a failure cannot
+	// leave it behind to take the place of the deleted loose ref.
+	if err := deletePackedRef(repo, name); err != nil {
+		return err
+	}
+	if err := repo.fs.Remove(createRepoPath(repo, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := repo.fs.Remove(createRepoPath(repo, LogsDir, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// deletePackedRef removes a ref from the packed-refs file under its lock.
+// The lock is only taken when the ref is packed, so deleting loose refs
+// does not contend for it.
+func deletePackedRef(repo *GitRepository, name string) error {
+	packed, err := readPackedRefs(repo)
+	if err != nil {
+		return err
+	}
+	if _, ok := packed[name]; !ok {
+		return nil
+	}
+
+	lock, err := lockPackedRefs(repo)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	// Read again now that no one else can change it.
+	if packed, err = readPackedRefs(repo); err != nil {
+		return err
+	}
+	if _, ok := packed[name]; !ok {
+		return nil
+	}
+	delete(packed, name)
+	return writePackedRefs(lock, packed)
+}
+
+// looseRefNames lists the names of the loose refs in dir, a directory below
+// refs/ such as "refs/tags", including symbolic ones.
+func looseRefNames(repo *GitRepository, dir string) ([]string, error) {
+	root := createRepoPath(repo, filepath.FromSlash(dir))
+	var names []string
+
+	err := walkDir(repo.fs, root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".lock") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(repo.GitDir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+
+	return names, err
+}
+
+// ListRefs returns every ref under refs/, loose and packed, resolved to the
+// object they point to and sorted by name.
+func ListRefs(repo *GitRepository) ([]Ref, error) {
+	return Refs(repo, RefsDir+"/")
+}
+
+// Refs returns the refs whose full name starts with prefix, such as
+// "refs/tags/", loose and packed, resolved to the object they point to and
+// sorted by name. Only the loose refs below the directory part of the
+// prefix are read.
+//
+// Parameters:
+// - repo: The repository.
+// - prefix: The start of the ref names, e.g. "refs/heads/"; "refs/" for all.
+//
+// Returns:
+// - The refs. Symbolic refs are resolved, and dangling ones left out.
+// - An error if the refs cannot be read.
+func Refs(repo *GitRepository, prefix string) ([]Ref, error) {
+	packed, err := readPackedRefs(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := RefsDir
+	if i := strings.LastIndexByte(prefix, '/'); i > len(RefsDir) {
+		dir = prefix[:i]
+	}
+	names, err := looseRefNames(repo, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]Ref)
+	for name, sha := range packed {
+		if strings.HasPrefix(name, prefix) {
+			refs[name] = Ref{Name: name, SHA: sha}
+		}
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		sha, err := ResolveRef(repo, name)
+		if err != nil {
+			// Dangling symbolic refs are not an error when listing.
+			continue
+		}
+		_, symbolic := ReadSymbolicRef(repo, name)
+		refs[name] = Ref{Name: name, SHA: sha, Symbolic: symbolic}
+	}
+
+	result := make([]Ref, 0, len(refs))
+	for _, ref := range refs {
+		result = append(result, ref)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// ForEachRef calls fn for every ref whose name starts with prefix, in name
+// order, and stops at the first error fn returns.
+func ForEachRef(repo *GitRepository, prefix string, fn func(Ref) error) error {
+	refs, err := Refs(repo, prefix)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := fn(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PackRefs moves all loose, non-symbolic refs into the packed-refs file and
+// deletes the loose copies.
+//
+// Returns:
+// - The number of refs that were packed.
+// - An error if the refs cannot be read or the packed-refs file cannot be written.
+func PackRefs(repo *GitRepository) (int, error) {
+	lock, err := lockPackedRefs(repo)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.release()
+
+	packed, err := readPackedRefs(repo)
+	if err != nil {
+		return 0, err
+	}
+
+	names, err := looseRefNames(repo, RefsDir)
+	if err != nil {
+		return 0, err
+	}
+
+	var loose []string
+	for _, name := range names {
+		content, ok, err := readRefFile(repo, name)
+		if err != nil {
+			return 0, err
+		}
+		if !ok || !isValidSHA(content) {
+			continue
+		}
+
+		packed[name] = content
+		loose = append(loose, name)
+	}
+
+	if len(loose) == 0 {
+		return 0, nil
+	}
+
+	if err := writePackedRefs(lock, packed); err != nil {
+		return 0, err
+	}
+
+	for _, name := range loose {
+		if err := repo.fs.Remove(createRepoPath(repo, filepath.FromSlash(name))); err != nil {
+			return 0, err
+		}
+	}
+
+	trace.Log(trace.Ref, "pack-refs", "packed", len(loose))
+	return len(loose), nil
+}
+
+// refExists reports whether a loose or packed ref with this exact name exists.
+func refExists(repo *GitRepository, name string) bool {
+	if _, ok, _ := readRefFile(repo, name); ok {
+		return true
+	}
+	packed, err := readPackedRefs(repo)
+	if err != nil {
+		return false
+	}
+	_, ok := packed[name]
+	return ok
+}
+
+// ReadSymbolicRef returns the ref a symbolic ref points at, e.g.
+// refs/heads/master for HEAD. ok is false for regular or missing refs.
+func ReadSymbolicRef(repo *GitRepository, name string) (string, bool) {
+	content, exists, err := readRefFile(repo, name)
+	if err != nil || !exists {
+		return "", false
+	}
+	return strings.CutPrefix(content, symbolicPrefix)
+}
+
+// Head describes the state of HEAD: attached to a branch, which may not
+// have any commits yet, or detached at a commit.
+type Head struct {
+	Ref string // The ref HEAD points at, e.g. refs/heads/master; "" when detached.
+	SHA string // The commit HEAD resolves to; "" on an unborn branch.
+}
+
+// Detached reports whether HEAD points directly at a commit.
+func (h Head) Detached() bool {
+	return h.Ref == ""
+}
+
+// Unborn reports whether HEAD is attached to a branch that does not exist
+// yet, as in a freshly initialized repository.
+func (h Head) Unborn() bool {
+	return h.SHA == ""
+}
+
+// Branch returns the short name of the branch HEAD is attached to, or ""
+// when HEAD is detached or points outside refs/heads/.
+func (h Head) Branch() string {
+	branch, _ := strings.CutPrefix(h.Ref, BranchesPrefix)
+	if branch == h.Ref {
+		return ""
+	}
+	return branch
+}
+
+// ResolveHEAD reads HEAD without treating an unborn branch as an error.
+//
+// Returns:
+// - The ref HEAD is attached to, if any, and the commit it resolves to.
+// - An error if HEAD is missing or corrupt, or points at a broken ref.
+func ResolveHEAD(repo *GitRepository) (Head, error) {
+	content, ok, err := readRefFile(repo, HeadFile)
+	if err != nil {
+		return Head{}, err
+	}
+	if !ok {
+		return Head{}, fmt.Errorf("ref '%s' not found", HeadFile)
+	}
+
+	target, symbolic := strings.CutPrefix(content, symbolicPrefix)
+	if !symbolic {
+		if !isValidSHA(content) {
+			return Head{}, fmt.Errorf("ref '%s' is corrupt", HeadFile)
+		}
+		return Head{SHA: content}, nil
+	}
+
+	sha, err := ResolveRef(repo, target)
+	if err != nil {
+		if refExists(repo, target) {
+			return Head{}, err
+		}
+		return Head{Ref: target}, nil
+	}
+	return Head{Ref: target, SHA: sha}, nil
+}
+
+// HeadCommit returns the commit HEAD resolves to. Unlike resolving HEAD as
+// a ref, it explains an unborn branch the way git does.
+func HeadCommit(repo *GitRepository) (string, error) {
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return "", err
+	}
+	if head.Unborn() {
+		return "", fmt.Errorf("your current branch '%s' does not have any commits yet", strings.TrimPrefix(head.Ref, BranchesPrefix))
+	}
+	return head.SHA, nil
+}
+
+// CurrentBranch returns the short name of the branch HEAD is attached to,
+// whether or not it has commits yet. ok is false when HEAD is detached or
+// unreadable.
+func CurrentBranch(repo *GitRepository) (string, bool) {
+	head, err := ResolveHEAD(repo)
+	if err != nil || head.Branch() == "" {
+		return "", false
+	}
+	return head.Branch(), true
+}
+
+// ExpandRefName turns a short ref name into the full name of an existing
+// ref, trying the same locations as git: the name itself, refs/<name>,
+// refs/tags/<name>, refs/heads/<name> and refs/remotes/<name>. A symbolic
+// HEAD expands to the branch it points at.
+func ExpandRefName(repo *GitRepository, name string) (string, error) {
+	if name == HeadFile {
+		content, ok, err := readRefFile(repo, HeadFile)
+		if err != nil {
+			return "", err
+		}
+		if target, isSymbolic := strings.CutPrefix(content, symbolicPrefix); ok && isSymbolic {
+			return target, nil
+		}
+		return HeadFile, nil
+	}
+
+	candidates := []string{
+		name,
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, "refs/") && refExists(repo, candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("ref '%s' not found", name)
+}
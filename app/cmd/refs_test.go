@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+// packTestRefs creates count tags and packs them.
+func packTestRefs(t *testing.T, repo *GitRepository, count int) []string {
+	t.Helper()
+	sha, err := NewObjectManager(repo).WriteObject(BlobType, []byte("tagged\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for i := range count {
+		name := fmt.Sprintf("refs/tags/tag%02d", i)
+		if err := UpdateRef(repo, name, sha, ""); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if _, err := PackRefs(repo); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// TestDeleteRefParallelPacked deletes a different packed ref from each of
+// many goroutines. Without packed-refs.lock taken before the file is read,
+// deleters starting from the same content would write back each other's
+// refs.
+func TestDeleteRefParallelPacked(t *testing.T) {
+	repo := newTestRepo(t)
+	names := packTestRefs(t, repo, 64)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- retryLocked(func() error {
+				return DeleteRef(repo, name)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	packed, err := readPackedRefs(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packed) != 0 {
+		t.Errorf("packed-refs still has %v", packed)
+	}
+	if _, err := os.Stat(createRepoPath(repo, PackedRefsFile+lockSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("packed-refs.lock left behind: %v", err)
+	}
+}
+
+func TestDeleteRefFailsWhilePackedRefsLocked(t *testing.T) {
+	repo := newTestRepo(t)
+	name := packTestRefs(t, repo, 1)[0]
+	lock := createRepoPath(repo, PackedRefsFile+lockSuffix)
+	if err := os.WriteFile(lock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteRef(repo, name); err == nil {
+		t.Fatal("DeleteRef succeeded while packed-refs.lock was held")
+	}
+	if !refExists(repo, name) {
+		t.Errorf("%s was deleted while packed-refs.lock was held", name)
+	}
+	if _, err := os.Stat(lock); err != nil {
+		t.Fatalf("packed-refs.lock was removed: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sort keys understood by SortRefs.
+const (
+	SortRefname        = "refname"
+	SortVersionRefname = "version:refname"
+)
+
+// SortRefs sorts refs by keys, as the --sort options of git tag and git
+// branch do: the last key decides first and the earlier ones break ties.
+// A key is "refname", or "version:refname" ("v:refname" for short), which
+// compares the numbers in names by value so that v1.10 comes after v1.9;
+// a leading "-" reverses it. Version sorting honours versionsort.suffix, a
+// whitespace separated list of suffixes, such as "-rc", that sort before
+// the release they belong to, in the order they are listed.
+//
+// Parameters:
+// - repo: The repository, for versionsort.suffix.
+// - refs: The refs to sort in place.
+// - keys: The sort keys.
+//
+// Returns:
+// - An error if a key is not supported.
+func SortRefs(repo *GitRepository, refs []Ref, keys []string) error {
+	var suffixes []string
+	for _, key := range []string{"versionsort.suffix", "versionsort.prereleasesuffix"} {
+		if suffixes = strings.Fields(lookupConfig(repo, key)); len(suffixes) > 0 {
+			break
+		}
+	}
+
+	for _, spec := range keys {
+		key, reverse := strings.CutPrefix(spec, "-")
+		var compare func(a, b string) int
+		switch key {
+		case SortRefname:
+			compare = strings.Compare
+		case SortVersionRefname, "v:refname":
+			compare = func(a, b string) int { return VersionCompare(a, b, suffixes) }
+		default:
+			return fmt.Errorf("unsupported sort specification '%s'", spec)
+		}
+		sort.SliceStable(refs, func(i, j int) bool {
+			if reverse {
+				return compare(refs[j].Name, refs[i].Name) < 0
+			}
+			return compare(refs[i].Name, refs[j].Name) < 0
+		})
+	}
+	return nil
+}
+
+// States of VersionCompare while it scans the common prefix of two names:
+// in text, in an integral number, in a fractional number (one that started
+// with "0") and in a run of leading zeros.
+const (
+	versionNormal   = 0
+	versionInteger  = 3
+	versionFraction = 6
+	versionZeros    = 9
+)
+
+// Outcomes of VersionCompare at the first difference, besides -1 and +1:
+// compare the differing bytes, or compare the numbers by length first.
+const (
+	versionByByte   = 2
+	versionByLength = 3
+)
+
+var versionNextState = [...]int{
+	/*                    other           digit            zero */
+	/* normal   */ versionNormal, versionInteger, versionZeros,
+	/* integer  */ versionNormal, versionInteger, versionInteger,
+	/* fraction */ versionNormal, versionFraction, versionFraction,
+	/* zeros    */ versionNormal, versionFraction, versionZeros,
+}
+
+var versionResult = [...]int{
+	/*                  x/x             x/d             x/0             d/x             d/d              d/0              0/x             0/d              0/0 */
+	/* normal   */ versionByByte, versionByByte, versionByByte, versionByByte, versionByLength, versionByByte, versionByByte, versionByByte, versionByByte,
+	/* integer  */ versionByByte, -1, -1, +1, versionByLength, versionByLength, +1, versionByLength, versionByLength,
+	/* fraction */ versionByByte, versionByByte, versionByByte, versionByByte, versionByByte, versionByByte, versionByByte, versionByByte, versionByByte,
+	/* zeros    */ versionByByte, +1, +1, -1, versionByByte, versionByByte, -1, versionByByte, versionByByte,
+}
+
+// versionClass is 0 for a byte that is not a digit, 1 for a digit other
+// than "0" and 2 for "0".
+func versionClass(c byte) int {
+	switch {
+	case c == '0':
+		return 2
+	case c >= '1' && c <= '9':
+		return 1
+	}
+	return 0
+}
+
+// VersionCompare compares two names the way git's version sort does,
+// which is strverscmp with pre-release suffixes: runs of digits compare
+// as numbers, except that a run starting with "0" is compared as a
+// fraction, and a name where one of suffixes starts at the first
+// difference sorts before one without it.
+//
+// Returns:
+// - A negative number, zero or a positive number, as a sorts before, with
+// or after b.
+func VersionCompare(a, b string, suffixes []string) int {
+	at := func(s string, i int) byte {
+		if i < len(s) {
+			return s[i]
+		}
+		return 0
+	}
+	if a == b {
+		return 0
+	}
+
+	i := 0
+	c1, c2 := at(a, 0), at(b, 0)
+	state := versionNormal + versionClass(c1)
+	for c1 == c2 {
+		state = versionNextState[state]
+		i++
+		c1, c2 = at(a, i), at(b, i)
+		state += versionClass(c1)
+	}
+	diff := int(c1) - int(c2)
+
+	if diff, ok := comparePrereleases(a, b, i, suffixes); ok {
+		return diff
+	}
+
+	switch result := versionResult[state*3+versionClass(c2)]; result {
+	case versionByByte:
+		return diff
+	case versionByLength:
+		// The longer number is the bigger one.
+		for j := i + 1; ; j++ {
+			if versionClass(at(a, j)) == 0 {
+				if versionClass(at(b, j)) != 0 {
+					return -1
+				}
+				return diff
+			}
+			if versionClass(at(b, j)) == 0 {
+				return 1
+			}
+		}
+	default:
+		return result
+	}
+}
+
+// comparePrereleases orders two names by the pre-release suffixes found
+// around off, their first difference: a suffix listed earlier sorts first,
+// and any suffix sorts before none. ok is false when neither name or both
+// have the same suffix there.
+func comparePrereleases(a, b string, off int, suffixes []string) (int, bool) {
+	find := func(s string) int {
+		for n, suffix := range suffixes {
+			for j := max(off-len(suffix), 0); j <= off && j <= len(s); j++ {
+				if strings.HasPrefix(s[j:], suffix) {
+					return n
+				}
+			}
+		}
+		return -1
+	}
+	i1, i2 := find(a), find(b)
+	switch {
+	case i1 == i2:
+		return 0, false
+	case i1 >= 0 && i2 >= 0:
+		return i1 - i2, true
+	case i1 >= 0:
+		return -1, true
+	}
+	return 1, true
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Refspec describes how refs on one side of a fetch or push map onto refs on
+// the other, e.g. "+refs/heads/*:refs/remotes/origin/*".
+type Refspec struct {
+	Src      string // The source ref or pattern. Empty for a push that deletes Dst.
+	Dst      string // The destination ref or pattern. Empty when nothing is stored.
+	Force    bool   // Allow non fast-forward updates ("+" prefix).
+	Negative bool   // Exclude matching refs ("^" prefix).
+}
+
+// RefMapping is a concrete source ref paired with the destination a refspec
+// maps it to.
+type RefMapping struct {
+	Src   string
+	Dst   string
+	Force bool
+}
+
+// ParseRefspec parses a refspec of the form [+]<src>[:<dst>] or ^<src>. A
+// pattern contains exactly one '*' on each side that has a ref.
+//
+// Parameters:
+// - spec: The refspec as written in the config or on the command line.
+//
+// Returns:
+// - The parsed refspec.
+// - An error if the refspec is malformed.
+func ParseRefspec(spec string) (*Refspec, error) {
+	refspec := &Refspec{}
+	rest := spec
+
+	if strings.HasPrefix(rest, "^") {
+		refspec.Negative = true
+		rest = rest[1:]
+		if strings.Contains(rest, ":") || rest == "" {
+			return nil, fmt.Errorf("invalid negative refspec '%s'", spec)
+		}
+	} else if strings.HasPrefix(rest, "+") {
+		refspec.Force = true
+		rest = rest[1:]
+	}
+
+	refspec.Src, refspec.Dst, _ = strings.Cut(rest, ":")
+
+	srcWildcards := strings.Count(refspec.Src, "*")
+	dstWildcards := strings.Count(refspec.Dst, "*")
+	if srcWildcards > 1 || dstWildcards > 1 {
+		return nil, fmt.Errorf("invalid refspec '%s': more than one '*'", spec)
+	}
+	if refspec.Dst != "" && srcWildcards != dstWildcards {
+		return nil, fmt.Errorf("invalid refspec '%s': wildcard on only one side", spec)
+	}
+	if refspec.Src == "" && refspec.Dst == "" {
+		return nil, fmt.Errorf("invalid refspec '%s'", spec)
+	}
+
+	for _, name := range []string{refspec.Src, refspec.Dst} {
+		if name != "" && !validRefName(strings.Replace(name, "*", "x", 1)) {
+			return nil, fmt.Errorf("invalid refspec '%s': bad ref name '%s'", spec, name)
+		}
+	}
+	return refspec, nil
+}
+
+// ParseRefspecs parses a list of refspecs, stopping at the first bad one.
+func ParseRefspecs(specs []string) ([]*Refspec, error) {
+	refspecs := make([]*Refspec, 0, len(specs))
+	for _, spec := range specs {
+		refspec, err := ParseRefspec(spec)
+		if err != nil {
+			return nil, err
+		}
+		refspecs = append(refspecs, refspec)
+	}
+	return refspecs, nil
+}
+
+// String formats the refspec the way it is written in the config.
+func (r *Refspec) String() string {
+	if r.Negative {
+		return "^" + r.Src
+	}
+
+	spec := r.Src
+	if r.Dst != "" || r.Src == "" {
+		spec += ":" + r.Dst
+	}
+	if r.Force {
+		spec = "+" + spec
+	}
+	return spec
+}
+
+// IsPattern reports whether the refspec contains a wildcard.
+func (r *Refspec) IsPattern() bool {
+	return strings.Contains(r.Src, "*")
+}
+
+// MatchSource reports whether name matches the source side and returns the
+// destination it maps to. The destination is empty when the refspec does
+// not store the ref anywhere.
+func (r *Refspec) MatchSource(name string) (string, bool) {
+	return mapRefPattern(r.Src, r.Dst, name)
+}
+
+// MatchDestination reports whether name matches the destination side and
+// returns the source it maps back to, e.g. which remote branch a
+// remote-tracking ref follows.
+func (r *Refspec) MatchDestination(name string) (string, bool) {
+	if r.Dst == "" {
+		return "", false
+	}
+	return mapRefPattern(r.Dst, r.Src, name)
+}
+
+// mapRefPattern matches name against from and, on success, substitutes the
+// part matched by the wildcard into to.
+func mapRefPattern(from, to, name string) (string, bool) {
+	prefix, suffix, isPattern := strings.Cut(from, "*")
+	if !isPattern {
+		if name != from {
+			return "", false
+		}
+		return to, true
+	}
+
+	if len(name) < len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	matched := name[len(prefix) : len(name)-len(suffix)]
+	return strings.Replace(to, "*", matched, 1), true
+}
+
+// MapRefs applies refspecs to a list of ref names. Every name is mapped by
+// each positive refspec it matches, unless a negative refspec excludes it.
+// Non-pattern sources must be full ref names.
+//
+// Parameters:
+// - refspecs: The refspecs to apply, in order.
+// - names: The candidate source refs.
+//
+// Returns:
+// - The resulting mappings, in refspec order and then name order.
+func MapRefs(refspecs []*Refspec, names []string) []RefMapping {
+	excluded := make(map[string]bool)
+	for _, refspec := range refspecs {
+		if !refspec.Negative {
+			continue
+		}
+		for _, name := range names {
+			if _, ok := refspec.MatchSource(name); ok {
+				excluded[name] = true
+			}
+		}
+	}
+
+	var mappings []RefMapping
+	for _, refspec := range refspecs {
+		if refspec.Negative {
+			continue
+		}
+		for _, name := range names {
+			if excluded[name] {
+				continue
+			}
+			if dst, ok := refspec.MatchSource(name); ok {
+				mappings = append(mappings, RefMapping{Src: name, Dst: dst, Force: refspec.Force})
+			}
+		}
+	}
+	return mappings
+}
+
+// DefaultFetchRefspec is the refspec git writes for a newly added remote.
+func DefaultFetchRefspec(remote string) string {
+	return fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", remote)
+}
+
+// RemoteFetchRefspecs reads remote.<name>.fetch, falling back to the default
+// refspec when the remote has none configured.
+func RemoteFetchRefspecs(repo *GitRepository, remote string) ([]*Refspec, error) {
+	specs := repo.Config().GetStringSlice(configKey("remote", remote, "fetch"))
+	if len(specs) == 0 {
+		specs = []string{DefaultFetchRefspec(remote)}
+	}
+	return ParseRefspecs(specs)
+}
+
+// validRefName checks the rules of git check-ref-format that apply to
+// refspec sides: no "..", "@{", control characters, spaces or any of
+// "~^:?*[\", and no component starting with "." or ending in ".lock".
+func validRefName(name string) bool {
+	if name == "" || name == "@" || strings.Contains(name, "..") || strings.Contains(name, "@{") ||
+		strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") || strings.Contains(name, "//") {
+		return false
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return false
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRefspec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Refspec
+		wantErr bool
+	}{
+		{spec: "refs/heads/master:refs/remotes/origin/master", want: Refspec{Src: "refs/heads/master", Dst: "refs/remotes/origin/master"}},
+		{spec: "+refs/heads/*:refs/remotes/origin/*", want: Refspec{Src: "refs/heads/*", Dst: "refs/remotes/origin/*", Force: true}},
+		{spec: "refs/heads/*", want: Refspec{Src: "refs/heads/*"}},
+		{spec: "refs/tags/v1.0", want: Refspec{Src: "refs/tags/v1.0"}},
+		{spec: ":refs/heads/gone", want: Refspec{Dst: "refs/heads/gone"}},
+		{spec: "+:refs/heads/gone", want: Refspec{Dst: "refs/heads/gone", Force: true}},
+		{spec: "refs/heads/feature*:refs/remotes/origin/feature*", want: Refspec{Src: "refs/heads/feature*", Dst: "refs/remotes/origin/feature*"}},
+		{spec: "refs/heads/*/head:refs/remotes/origin/*/head", want: Refspec{Src: "refs/heads/*/head", Dst: "refs/remotes/origin/*/head"}},
+		{spec: "^refs/heads/secret", want: Refspec{Src: "refs/heads/secret", Negative: true}},
+		{spec: "^refs/heads/wip/*", want: Refspec{Src: "refs/heads/wip/*", Negative: true}},
+
+		{spec: "", wantErr: true},
+		{spec: ":", wantErr: true},
+		{spec: "+", wantErr: true},
+		{spec: "^", wantErr: true},
+		{spec: "^refs/heads/a:refs/heads/b", wantErr: true},
+		{spec: "+^refs/heads/a", wantErr: true},
+		{spec: "refs/heads/*/*:refs/remotes/origin/*", wantErr: true},
+		{spec: "refs/heads/*:refs/remotes/origin/master", wantErr: true},
+		{spec: "refs/heads/master:refs/remotes/origin/*", wantErr: true},
+		{spec: "refs/heads/a..b", wantErr: true},
+		{spec: "refs/heads/a b:refs/heads/c", wantErr: true},
+		{spec: "refs/heads/a:refs/heads/b.lock", wantErr: true},
+		{spec: "refs/heads/a@{1}", wantErr: true},
+		{spec: "refs/heads/.hidden", wantErr: true},
+		{spec: "refs/heads/a/", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRefspec(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseRefspec(%q) = %+v, want an error", tt.spec, *got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRefspec(%q): %v", tt.spec, err)
+			}
+			if *got != tt.want {
+				t.Errorf("ParseRefspec(%q) = %+v, want %+v", tt.spec, *got, tt.want)
+			}
+			if got.String() != tt.spec {
+				t.Errorf("String() = %q, want %q", got.String(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestRefspecMatchSource(t *testing.T) {
+	tests := []struct {
+		spec   string
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"refs/heads/master:refs/remotes/origin/master", "refs/heads/master", "refs/remotes/origin/master", true},
+		{"refs/heads/master:refs/remotes/origin/master", "refs/heads/master2", "", false},
+		{"refs/heads/master", "refs/heads/master", "", true},
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/heads/master", "refs/remotes/origin/master", true},
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/heads/feature/deep/name", "refs/remotes/origin/feature/deep/name", true},
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/tags/v1", "", false},
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/heads", "", false},
+		{"refs/heads/*", "refs/heads/master", "", true},
+		{"refs/heads/feature*:refs/remotes/origin/f*", "refs/heads/feature-x", "refs/remotes/origin/f-x", true},
+		{"refs/heads/feature*:refs/remotes/origin/f*", "refs/heads/feature", "refs/remotes/origin/f", true},
+		{"refs/heads/feature*:refs/remotes/origin/f*", "refs/heads/featur", "", false},
+		{"refs/heads/*/head:refs/remotes/origin/*", "refs/heads/team/head", "refs/remotes/origin/team", true},
+		{"refs/heads/*/head:refs/remotes/origin/*", "refs/heads/team/tail", "", false},
+		// The prefix and suffix of a pattern may not overlap in the name.
+		{"refs/heads/a*a:refs/x/*", "refs/heads/a", "", false},
+		{"refs/heads/a*a:refs/x/*", "refs/heads/aa", "refs/x/", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" "+tt.name, func(t *testing.T) {
+			refspec, err := ParseRefspec(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := refspec.MatchSource(tt.name)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("MatchSource(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRefspecMatchDestination(t *testing.T) {
+	tests := []struct {
+		spec   string
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/remotes/origin/topic", "refs/heads/topic", true},
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/remotes/upstream/topic", "", false},
+		{"refs/heads/master:refs/remotes/origin/main", "refs/remotes/origin/main", "refs/heads/master", true},
+		{"refs/heads/*", "refs/heads/master", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" "+tt.name, func(t *testing.T) {
+			refspec, err := ParseRefspec(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := refspec.MatchDestination(tt.name)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("MatchDestination(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMapRefs(t *testing.T) {
+	names := []string{"refs/heads/master", "refs/heads/wip/a", "refs/heads/wip/b", "refs/heads/secret", "refs/tags/v1"}
+	tests := []struct {
+		name  string
+		specs []string
+		want  []RefMapping
+	}{
+		{
+			name:  "glob with force",
+			specs: []string{"+refs/heads/*:refs/remotes/origin/*"},
+			want: []RefMapping{
+				{Src: "refs/heads/master", Dst: "refs/remotes/origin/master", Force: true},
+				{Src: "refs/heads/wip/a", Dst: "refs/remotes/origin/wip/a", Force: true},
+				{Src: "refs/heads/wip/b", Dst: "refs/remotes/origin/wip/b", Force: true},
+				{Src: "refs/heads/secret", Dst: "refs/remotes/origin/secret", Force: true},
+			},
+		},
+		{
+			name:  "negative refspecs exclude whatever their position",
+			specs: []string{"^refs/heads/wip/*", "refs/heads/*:refs/remotes/origin/*", "^refs/heads/secret"},
+			want: []RefMapping{
+				{Src: "refs/heads/master", Dst: "refs/remotes/origin/master"},
+			},
+		},
+		{
+			name:  "exact refspecs keep their own force flag",
+			specs: []string{"refs/heads/master:refs/heads/master", "+refs/tags/v1:refs/tags/v1"},
+			want: []RefMapping{
+				{Src: "refs/heads/master", Dst: "refs/heads/master"},
+				{Src: "refs/tags/v1", Dst: "refs/tags/v1", Force: true},
+			},
+		},
+		{
+			name:  "refspec order comes first, then name order",
+			specs: []string{"refs/tags/*:refs/tags/*", "refs/heads/master:refs/heads/master"},
+			want: []RefMapping{
+				{Src: "refs/tags/v1", Dst: "refs/tags/v1"},
+				{Src: "refs/heads/master", Dst: "refs/heads/master"},
+			},
+		},
+		{
+			name:  "only negative refspecs map nothing",
+			specs: []string{"^refs/heads/*"},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refspecs, err := ParseRefspecs(tt.specs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := MapRefs(refspecs, names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MapRefs(%q) =\n%+v\nwant\n%+v", tt.specs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRefspecsStopsAtFirstBadOne(t *testing.T) {
+	if _, err := ParseRefspecs([]string{"refs/heads/*:refs/remotes/origin/*", "refs/heads/*:x", "refs/tags/*"}); err == nil {
+		t.Fatal("ParseRefspecs accepted a bad refspec")
+	}
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// States of a remote branch in RemoteInfo, as git remote show words them.
+const (
+	RemoteBranchTracked = "tracked"
+	RemoteBranchNew     = "new"
+	RemoteBranchStale   = "stale"
+)
+
+// States of a push target in RemoteInfo.
+const (
+	PushTargetUpToDate    = "up to date"
+	PushTargetCreate      = "create"
+	PushTargetFastForward = "fast-forwardable"
+	PushTargetOutOfDate   = "local out of date"
+)
+
+// RemoteBranch is a branch of a remote, or a remote-tracking ref left over
+// from one that is gone.
+type RemoteBranch struct {
+	Name     string // The branch on the remote, e.g. refs/heads/main.
+	Tracking string // The local ref the fetch refspecs map it to, if any.
+	State    string // One of the RemoteBranch* states.
+}
+
+// PushTarget is a local branch and the remote branch a push would update.
+type PushTarget struct {
+	Local  string // The local branch, e.g. refs/heads/main.
+	Remote string // The branch on the remote.
+	State  string // One of the PushTarget* states.
+}
+
+// RemoteInfo describes a configured remote and how the repository relates
+// to it, for git remote show.
+type RemoteInfo struct {
+	Name       string
+	FetchURL   string
+	PushURL    string
+	HeadBranch string         // The branch the remote HEAD points at, empty if unknown.
+	Branches   []RemoteBranch // Remote branches by name, then stale tracking refs.
+	Merges     []Upstream     // Local branches that track a branch of the remote, by their Ref.
+	Pushes     []PushTarget   // What a push to the remote would update.
+}
+
+// ResolvePushURL returns remote.<name>.pushurl when set, and otherwise the
+// URL fetches use. As in git, a pushurl is rewritten by
+// url.<base>.insteadOf, while the URL fetches use is first rewritten by
+// url.<base>.pushInsteadOf, so that pushes can go elsewhere, e.g. over
+// ssh instead of https.
+func ResolvePushURL(repo *GitRepository, name string) string {
+	if url := repo.Config().GetString(configKey("remote", name, "pushurl")); url != "" {
+		return rewriteURL(repo, url)
+	}
+	url := name
+	if RemoteConfigured(repo, name) {
+		url = repo.Config().GetString(configKey("remote", name, "url"))
+	}
+	if rewritten, ok := transport.RewriteURL(url, urlRewrites(repo, urlPushInsteadOf)); ok {
+		return rewritten
+	}
+	return rewriteURL(repo, url)
+}
+
+// ShowRemote queries a configured remote and compares its branches with the
+// local refs: which branches are tracked, which are new and which tracking
+// refs are stale, which local branches merge from it, and what a push
+// would do. Without remote.<name>.push, a push is shown as git's
+// "matching" push, of the local branches the remote also has.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The name of a configured remote.
+//
+// Returns:
+// - What is known about the remote.
+// - An error if the remote is not configured or cannot be reached.
+func ShowRemote(repo *GitRepository, name string) (*RemoteInfo, error) {
+	info := &RemoteInfo{Name: name, FetchURL: ResolveRemoteURL(repo, name), PushURL: ResolvePushURL(repo, name)}
+	if !RemoteConfigured(repo, name) {
+		return nil, fmt.Errorf("no such remote '%s'", name)
+	}
+
+	advertisement, err := queryRemote(repo, name)
+	if err != nil {
+		return nil, err
+	}
+	info.HeadBranch = strings.TrimPrefix(advertisedHead(advertisement), BranchesPrefix)
+
+	advertised := make(map[string]string)
+	for _, ref := range advertisement.Refs {
+		advertised[ref.Name] = ref.SHA
+	}
+
+	refspecs, err := RemoteFetchRefspecs(repo, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range advertisement.Refs {
+		if !strings.HasPrefix(ref.Name, BranchesPrefix) {
+			continue
+		}
+		branch := RemoteBranch{Name: ref.Name, Tracking: trackingRef(repo, name, ref.Name), State: RemoteBranchNew}
+		if branch.Tracking != "" && refExists(repo, branch.Tracking) {
+			branch.State = RemoteBranchTracked
+		}
+		info.Branches = append(info.Branches, branch)
+	}
+	stale, err := staleTrackingRefs(repo, refspecs, advertised)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(info.Branches, func(i, j int) bool { return info.Branches[i].Name < info.Branches[j].Name })
+	info.Branches = append(info.Branches, stale...)
+
+	branches, err := Refs(repo, BranchesPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, branch := range branches {
+		if upstream, ok := BranchUpstream(repo, strings.TrimPrefix(branch.Name, BranchesPrefix)); ok && upstream.Remote == name {
+			upstream.Ref = branch.Name
+			info.Merges = append(info.Merges, *upstream)
+		}
+	}
+
+	info.Pushes, err = pushTargets(repo, name, branches, advertised)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// queryRemote reads the ref advertisement of a configured remote.
+func queryRemote(repo *GitRepository, name string) (*transport.Advertisement, error) {
+	session, err := dialRemote(repo, ResolveRemoteURL(repo, name), "git-upload-pack", remoteUploadPack(repo, name))
+	if err != nil {
+		return nil, err
+	}
+	if !session.Stateless {
+		_ = transport.NewEncoder(session).Flush()
+	}
+	if err := session.Close(); err != nil {
+		return nil, err
+	}
+	return session.Advertisement, nil
+}
+
+// staleTrackingRefs finds the remote-tracking refs that a fetch refspec
+// maps from a remote branch that is no longer advertised.
+func staleTrackingRefs(repo *GitRepository, refspecs []*Refspec, advertised map[string]string) ([]RemoteBranch, error) {
+	var stale []RemoteBranch
+	seen := make(map[string]bool)
+	for _, refspec := range refspecs {
+		if refspec.Negative || refspec.Dst == "" {
+			continue
+		}
+		prefix, _, _ := strings.Cut(refspec.Dst, "*")
+		refs, err := Refs(repo, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			src, ok := refspec.MatchDestination(ref.Name)
+			if !ok || seen[ref.Name] || ref.Symbolic {
+				continue
+			}
+			if _, exists := advertised[src]; !exists {
+				seen[ref.Name] = true
+				stale = append(stale, RemoteBranch{Name: src, Tracking: ref.Name, State: RemoteBranchStale})
+			}
+		}
+	}
+	return stale, nil
+}
+
+// pushTargets lists what a push to the remote would update, with
+// remote.<name>.push or else the matching branches.
+func pushTargets(repo *GitRepository, name string, branches []Ref, advertised map[string]string) ([]PushTarget, error) {
+	var mappings []RefMapping
+	names := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		names = append(names, branch.Name)
+	}
+
+	if specs := repo.Config().GetStringSlice(configKey("remote", name, "push")); len(specs) > 0 {
+		for _, spec := range specs {
+			refspec, err := parsePushRefspec(spec)
+			if err != nil {
+				return nil, err
+			}
+			if refspec.IsPattern() {
+				mappings = append(mappings, MapRefs([]*Refspec{refspec}, names)...)
+			} else if local, err := ExpandRefName(repo, refspec.Src); err == nil && strings.HasPrefix(local, BranchesPrefix) {
+				dst, err := pushDestination(refspec, local, advertised)
+				if err != nil {
+					return nil, err
+				}
+				mappings = append(mappings, RefMapping{Src: local, Dst: dst})
+			}
+		}
+	} else {
+		for _, branch := range names {
+			if _, ok := advertised[branch]; ok {
+				mappings = append(mappings, RefMapping{Src: branch, Dst: branch})
+			}
+		}
+	}
+
+	objects := NewObjectManager(repo)
+	targets := make([]PushTarget, 0, len(mappings))
+	for _, mapping := range mappings {
+		local, err := ResolveRef(repo, mapping.Src)
+		if err != nil {
+			return nil, err
+		}
+		target := PushTarget{Local: mapping.Src, Remote: mapping.Dst}
+		remote, exists := advertised[mapping.Dst]
+		switch {
+		case !exists:
+			target.State = PushTargetCreate
+		case remote == local:
+			target.State = PushTargetUpToDate
+		case objects.Has(remote):
+			if ok, err := objects.IsAncestor(remote, local); err == nil && ok {
+				target.State = PushTargetFastForward
+			} else {
+				target.State = PushTargetOutOfDate
+			}
+		default:
+			target.State = PushTargetOutOfDate
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// PruneRemote deletes the remote-tracking refs of a remote whose branches
+// are gone from it.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The name of a configured remote.
+// - dryRun: Only report the refs that would be deleted.
+//
+// Returns:
+// - The stale remote-tracking refs, deleted unless dryRun is set.
+// - An error if the remote cannot be reached or a ref cannot be deleted.
+func PruneRemote(repo *GitRepository, name string, dryRun bool) ([]string, error) {
+	if !RemoteConfigured(repo, name) {
+		return nil, fmt.Errorf("no such remote '%s'", name)
+	}
+	advertisement, err := queryRemote(repo, name)
+	if err != nil {
+		return nil, err
+	}
+	advertised := make(map[string]string)
+	for _, ref := range advertisement.Refs {
+		advertised[ref.Name] = ref.SHA
+	}
+
+	refspecs, err := RemoteFetchRefspecs(repo, name)
+	if err != nil {
+		return nil, err
+	}
+	stale, err := staleTrackingRefs(repo, refspecs, advertised)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := make([]string, 0, len(stale))
+	for _, branch := range stale {
+		if !dryRun {
+			if err := DeleteRef(repo, branch.Tracking); err != nil {
+				return nil, err
+			}
+		}
+		trace.Log(trace.Ref, "prune", "remote", name, "ref", branch.Tracking, "dry-run", dryRun)
+		pruned = append(pruned, branch.Tracking)
+	}
+	sort.Strings(pruned)
+	return pruned, nil
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultRenameScore is the similarity, in percent, from which a deleted
+// and an added file are taken for a rename, as git's default -M50%.
+const DefaultRenameScore = 50
+
+// renameLimit caps the number of deleted and added files compared by
+// content, like diff.renameLimit; files that are identical are always
+// paired.
+const renameLimit = 1000
+
+// emptyBlobSHA is the name of the empty blob.
+const emptyBlobSHA = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+// DetectRenames pairs deleted files with added ones that have the same or
+// similar content, and replaces each pair with one StatusRenamed change.
+// Identical content is paired first, preferring files with the same base
+// name; the other pairs are made from the most similar files down to
+// minScore. Empty files and submodules are never paired.
+//
+// Parameters:
+// - changes: The changes, e.g. from DiffTrees.
+// - minScore: The least similarity of a rename, in percent.
+//
+// Returns:
+// - The changes with renames, sorted by path.
+// - An error if a blob cannot be read.
+func (m *ObjectManager) DetectRenames(changes []TreeChange, minScore int) ([]TreeChange, error) {
+	var deleted, added []int
+	for i, change := range changes {
+		switch {
+		case change.Status == StatusDeleted && !change.Old.IsGitlink() && change.Old.SHA != emptyBlobSHA:
+			deleted = append(deleted, i)
+		case change.Status == StatusAdded && !change.New.IsGitlink() && change.New.SHA != emptyBlobSHA:
+			added = append(added, i)
+		}
+	}
+	if len(deleted) == 0 || len(added) == 0 {
+		return changes, nil
+	}
+
+	sources := make(map[int]int) // Added change -> the deleted one it came from.
+	scores := make(map[int]int)
+	used := make(map[int]bool)
+	pair := func(dst, src, score int) {
+		sources[dst], scores[dst], used[src] = src, score, true
+	}
+
+	// Identical content, same base names first.
+	for _, sameName := range []bool{true, false} {
+		for _, dst := range added {
+			if _, ok := sources[dst]; ok {
+				continue
+			}
+			for _, src := range deleted {
+				if used[src] || changes[src].Old.SHA != changes[dst].New.SHA {
+					continue
+				}
+				if sameName && pathBase(changes[src].Path) != pathBase(changes[dst].Path) {
+					continue
+				}
+				pair(dst, src, 100)
+				break
+			}
+		}
+	}
+
+	// Similar content, most similar first.
+	type candidate struct{ dst, src, score int }
+	var candidates []candidate
+	if len(deleted)*len(added) <= renameLimit*renameLimit {
+		contents := make(map[string][]byte)
+		read := func(sha string) ([]byte, error) {
+			if data, ok := contents[sha]; ok {
+				return data, nil
+			}
+			_, data, err := m.ReadObject(sha)
+			contents[sha] = data
+			return data, err
+		}
+		for _, dst := range added {
+			if _, ok := sources[dst]; ok {
+				continue
+			}
+			after, err := read(changes[dst].New.SHA)
+			if err != nil {
+				return nil, err
+			}
+			for _, src := range deleted {
+				if used[src] {
+					continue
+				}
+				before, err := read(changes[src].Old.SHA)
+				if err != nil {
+					return nil, err
+				}
+				if score := similarity(before, after); score >= minScore {
+					candidates = append(candidates, candidate{dst, src, score})
+				}
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	for _, c := range candidates {
+		if _, ok := sources[c.dst]; !ok && !used[c.src] {
+			pair(c.dst, c.src, c.score)
+		}
+	}
+
+	var result []TreeChange
+	for i, change := range changes {
+		if used[i] {
+			continue
+		}
+		if src, ok := sources[i]; ok {
+			change = TreeChange{
+				Path:    change.Path,
+				OldPath: changes[src].Path,
+				Status:  StatusRenamed,
+				Old:     changes[src].Old,
+				New:     change.New,
+				Score:   scores[i],
+			}
+		}
+		result = append(result, change)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result, nil
+}
+
+// pathBase returns the last component of a slash separated path.
+func pathBase(path string) string {
+	return path[strings.LastIndexByte(path, '/')+1:]
+}
+
+// similarity estimates how much of two contents is the same, in percent:
+// the bytes of the lines they share, over the size of the larger one.
+func similarity(a, b []byte) int {
+	size := max(len(a), len(b))
+	if size == 0 {
+		return 100
+	}
+	if min(len(a), len(b))*100 < size*DefaultRenameScore/2 {
+		// Too different in size to be worth comparing.
+		return 0
+	}
+
+	lines := make(map[string]int)
+	for _, line := range splitLines(a) {
+		lines[line]++
+	}
+	common := 0
+	for _, line := range splitLines(b) {
+		if lines[line] > 0 {
+			lines[line]--
+			common += len(line)
+		}
+	}
+	return common * 100 / size
+}
+
+// RenameDisplayName names a rename the way git's --stat does, with the
+// parts of the paths the two names share outside of braces, e.g.
+// "src/{old.go => new.go}" or "a.txt => b.txt".
+func RenameDisplayName(oldPath, newPath string) string {
+	at := func(s string, i int) byte {
+		if i >= 0 && i < len(s) {
+			return s[i]
+		}
+		return 0
+	}
+
+	prefix := 0
+	for i := 0; i < len(oldPath) && i < len(newPath) && oldPath[i] == newPath[i]; i++ {
+		if oldPath[i] == '/' {
+			prefix = i + 1
+		}
+	}
+
+	// The suffix may reach back into the slash that ends the prefix.
+	suffix := 0
+	adjust := 0
+	if prefix > 0 {
+		adjust = 1
+	}
+	for i, j := len(oldPath), len(newPath); prefix-adjust <= i && prefix-adjust <= j && at(oldPath, i) == at(newPath, j); i, j = i-1, j-1 {
+		if at(oldPath, i) == '/' {
+			suffix = len(oldPath) - i
+		}
+	}
+
+	oldMid := max(len(oldPath)-prefix-suffix, 0)
+	newMid := max(len(newPath)-prefix-suffix, 0)
+	if prefix+suffix == 0 {
+		return oldPath + " => " + newPath
+	}
+	return oldPath[:prefix] + "{" + oldPath[prefix:prefix+oldMid] + " => " + newPath[prefix:prefix+newMid] + "}" + oldPath[len(oldPath)-suffix:]
+}
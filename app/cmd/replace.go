@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// ReplacePrefix is where replace refs live: refs/replace/<object> points at
+// the object that takes its place.
+const ReplacePrefix = "refs/replace/"
+
+// NoReplaceObjectsEnv disables replace refs, like git's variable of the same
+// name. The --no-replace-objects flag sets it.
+const NoReplaceObjectsEnv = "GIT_NO_REPLACE_OBJECTS"
+
+// maxReplaceDepth bounds chains of replacements, as in git.
+const maxReplaceDepth = 5
+
+// ReplaceRefsEnabled reports whether replace refs should be honored: not when
+// GIT_NO_REPLACE_OBJECTS is set or core.useReplaceRefs is false.
+func ReplaceRefsEnabled(repo *GitRepository) bool {
+	if _, ok := os.LookupEnv(NoReplaceObjectsEnv); ok {
+		return false
+	}
+	if repo.Config().IsSet("core.useReplaceRefs") {
+		return repo.Config().GetBool("core.useReplaceRefs")
+	}
+	return true
+}
+
+// ListReplacements returns the replace refs of the repository whose object
+// name matches pattern, a glob; an empty pattern matches all.
+//
+// Returns:
+// - A map from replaced object to its replacement. Refs whose name is not an
+// object name are ignored.
+// - An error if the refs cannot be read.
+func ListReplacements(repo *GitRepository, pattern string) (map[string]string, error) {
+	replacements := make(map[string]string)
+	err := ForEachRef(repo, ReplacePrefix, func(ref Ref) error {
+		object := strings.TrimPrefix(ref.Name, ReplacePrefix)
+		if isValidSHA(object) && (pattern == "" || wildmatch(pattern, object, false)) {
+			replacements[object] = ref.SHA
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replacements, nil
+}
+
+// UseReplaceRefs makes reads through m return replacement objects in place
+// of the objects they replace, unless replace refs are disabled. Only
+// commands that show or walk history should do this: anything that copies
+// objects, such as packing, pruning or serving fetches, must see the real
+// objects.
+//
+// Returns:
+// - m, for chaining onto NewObjectManager.
+func (m *ObjectManager) UseReplaceRefs() *ObjectManager {
+	if !ReplaceRefsEnabled(m.repo) {
+		return m
+	}
+	replacements, err := ListReplacements(m.repo, "")
+	if err != nil {
+		trace.Log(trace.Ref, "replace refs unreadable", "error", err)
+		return m
+	}
+	if len(replacements) > 0 {
+		m.replacements = replacements
+	}
+	return m
+}
+
+// replaced returns the object that stands in for sha, following chains of
+// replacements.
+func (m *ObjectManager) replaced(sha string) string {
+	for depth := 0; depth < maxReplaceDepth; depth++ {
+		replacement, ok := m.replacements[sha]
+		if !ok {
+			return sha
+		}
+		trace.Log(trace.Object, "replace", "sha", sha, "replacement", replacement)
+		sha = replacement
+	}
+	return sha
+}
+
+// ReplaceObject creates the replace ref that substitutes replacement for
+// object.
+//
+// Parameters:
+// - repo: The repository.
+// - object: The object to replace.
+// - replacement: The object to use in its place.
+// - force: Overwrite an existing replacement and allow a different type.
+//
+// Returns:
+// - An error if either object is missing, the types differ or the object is
+// already replaced.
+func ReplaceObject(repo *GitRepository, object, replacement string, force bool) error {
+	if object == replacement {
+		return fmt.Errorf("new object is the same as the old one: '%s'", object)
+	}
+
+	objects := NewObjectManager(repo)
+	objType, _, err := objects.StatObject(object)
+	if err != nil {
+		return fmt.Errorf("failed to resolve '%s' as a valid ref", object)
+	}
+	replacementType, _, err := objects.StatObject(replacement)
+	if err != nil {
+		return fmt.Errorf("failed to resolve '%s' as a valid ref", replacement)
+	}
+	if !force && objType != replacementType {
+		return fmt.Errorf("objects must be of the same type: '%s' is a %s while '%s' is a %s",
+			object, objType, replacement, replacementType)
+	}
+
+	ref := ReplacePrefix + object
+	if _, err := ResolveRef(repo, ref); err == nil && !force {
+		return fmt.Errorf("replace ref '%s' already exists", ref)
+	}
+	return UpdateRef(repo, ref, replacement, "")
+}
+
+// DeleteReplacement removes the replace ref of object.
+//
+// Returns:
+// - An error if object is not replaced.
+func DeleteReplacement(repo *GitRepository, object string) error {
+	ref := ReplacePrefix + object
+	if _, err := ResolveRef(repo, ref); err != nil {
+		return fmt.Errorf("replace ref '%s' not found", object)
+	}
+	return DeleteRef(repo, ref)
+}
@@ -3,8 +3,13 @@ package cmd
 import (
 	"fmt"
 	"github.com/spf13/viper"
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -14,21 +19,44 @@ const (
 	ConfigFile   = "config"
 )
 
+// GitRepository is an opened repository. It is safe for concurrent use:
+// WorkTree and GitDir never change once it is opened, and the config is an
+// immutable snapshot that writes replace as a whole, so readers never see
+// a half-loaded config.
 type GitRepository struct {
-	WorkTree string       // The path to the repository.
-	GitDir   string       // The path to the .git directory.
-	Config   *viper.Viper // The configuration file.
+	WorkTree string // The path to the repository.
+	GitDir   string // The path to the .git directory.
+
+	fs     FileSystem                  // The file system holding GitDir, see FS.
+	config atomic.Pointer[viper.Viper] // The current config snapshot, see Config.
+
+	syncMu       sync.Mutex // Guards pendingSyncs.
+	pendingSyncs []string   // Written files whose sync core.fsyncMethod=batch deferred.
+
+	looseWritten atomic.Int64 // Loose objects written since the repository was opened, see AutoMaintenance.
+}
+
+// FS returns the file system the git directory is read and written through.
+func (repo *GitRepository) FS() FileSystem {
+	return repo.fs
+}
+
+// Config returns the current snapshot of the repository config. The
+// snapshot must only be read; config writes store a new one rather than
+// changing it, so callers holding it keep a consistent view.
+func (repo *GitRepository) Config() *viper.Viper {
+	return repo.config.Load()
 }
 
-func initializeGitRepo(path string, force bool) (*GitRepository, error) {
+func initializeGitRepo(fsys FileSystem, path string, force bool) (*GitRepository, error) {
 	repo := GitRepository{
 		WorkTree: path,
 		GitDir:   filepath.Join(path, GitExtension),
-		Config:   viper.New(),
+		fs:       fsys,
 	}
 
 	if !force {
-		isDir, err := isDir(repo.GitDir)
+		isDir, err := isDir(fsys, repo.GitDir)
 		if err != nil {
 			return nil, err
 		}
@@ -38,72 +66,301 @@ func initializeGitRepo(path string, force bool) (*GitRepository, error) {
 		}
 	}
 
-	repo.Config.SetConfigName("config")
-	repo.Config.AddConfigPath(repo.GitDir)
-	repo.Config.SetConfigType("ini")
+	if err := loadRepoConfig(&repo, force); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
 
-	if err := readConfig(&repo, force); err != nil {
+// loadRepoConfig reads the repository's config file into a new viper
+// instance and makes it the current snapshot.
+func loadRepoConfig(repo *GitRepository, force bool) error {
+	config := viper.New()
+	config.SetConfigType("ini")
+	config.SetConfigFile(createRepoPath(repo, ConfigFile))
+	if err := readConfig(repo, config, force); err != nil {
+		return err
+	}
+	repo.config.Store(config)
+	return nil
+}
+
+// isBareRepository reports whether path is the git directory of a bare
+// repository, i.e. it holds HEAD, objects and refs directly.
+func isBareRepository(fsys FileSystem, path string) bool {
+	for _, name := range []string{HeadFile, ObjectsDir, RefsDir} {
+		if !pathExists(fsys, filepath.Join(path, name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// openBareRepository opens the bare repository whose git directory is path.
+func openBareRepository(fsys FileSystem, path string) (*GitRepository, error) {
+	repo := GitRepository{GitDir: path, fs: fsys}
+
+	if err := loadRepoConfig(&repo, false); err != nil {
 		return nil, err
 	}
 	return &repo, nil
 }
 
-func readConfig(repo *GitRepository, force bool) error {
-	if err := repo.Config.ReadInConfig(); err != nil {
+// OpenRepository opens the repository at path, which is either a working tree
+// containing a .git directory or a bare repository.
+//
+// Parameters:
+// - path: The working tree or bare git directory.
+//
+// Returns:
+// - A pointer to the opened GitRepository.
+// - An error if path is not a repository or its config cannot be read.
+func OpenRepository(path string) (*GitRepository, error) {
+	return OpenRepositoryFS(path, OSFileSystem{})
+}
+
+// OpenRepositoryFS opens the repository at path like OpenRepository, reading
+// and writing its git directory through fsys, such as a read-only or jailed
+// file system for serving it.
+//
+// Parameters:
+// - path: The working tree or bare git directory, as fsys names it.
+// - fsys: The file system the repository lives on.
+//
+// Returns:
+// - A pointer to the opened GitRepository.
+// - An error if path is not a repository or its config cannot be read.
+func OpenRepositoryFS(path string, fsys FileSystem) (*GitRepository, error) {
+	if isDir, _ := isDir(fsys, filepath.Join(path, GitExtension)); isDir {
+		return initializeGitRepo(fsys, path, false)
+	}
+	if isBareRepository(fsys, path) {
+		return openBareRepository(fsys, path)
+	}
+	return nil, fmt.Errorf("'%s' is not a git repository", path)
+}
+
+// EnterRepository opens the repository a transport request names, trying
+// path and then path.git as git does.
+func EnterRepository(path string) (*GitRepository, error) {
+	for _, candidate := range []string{path, path + GitExtension} {
+		if repo, err := OpenRepository(candidate); err == nil {
+			return repo, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' does not appear to be a git repository", path)
+}
+
+// IsBare reports whether the repository has no working tree.
+func (repo *GitRepository) IsBare() bool {
+	return repo.WorkTree == ""
+}
+
+func readConfig(repo *GitRepository, config *viper.Viper, force bool) error {
+	if err := readConfigFile(repo.fs, config); err != nil {
+		trace.Log(trace.Config, "config not read", "path", repo.GitDir, "error", err)
 		if !force {
 			return fmt.Errorf("failed to read config file: %s", err)
 		}
 	} else {
 		if !force {
-			version := repo.Config.GetInt("core.repositoryformatversion")
-			if version != 0 {
-				return fmt.Errorf("unsupported repositoryformatversion %d", version)
+			version := config.GetInt("core.repositoryformatversion")
+			trace.Log(trace.Config, "config read", "path", config.ConfigFileUsed(), "repositoryformatversion", version)
+			if err := checkRepositoryFormat(config); err != nil {
+				return err
 			}
 		}
+		if err := readWorktreeConfig(repo, config); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func CreateGitRepository(path string) (*GitRepository, error) {
-	repo, err := initializeGitRepo(path, true)
+// FindRepository locates the Git repository containing the given path by
+// walking up the directory tree until a .git directory or a bare repository
+// is found.
+//
+// Parameters:
+// - path: The path to start searching from.
+//
+// Returns:
+// - A pointer to the GitRepository that contains the path.
+// - An error if no repository is found or it cannot be opened.
+func FindRepository(path string) (*GitRepository, error) {
+	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := ensureValidRepoExists(repo); err != nil {
-		return nil, err
+	fsys := OSFileSystem{}
+	for {
+		if isDir, _ := isDir(fsys, filepath.Join(absPath, GitExtension)); isDir {
+			return initializeGitRepo(fsys, absPath, false)
+		}
+		if isBareRepository(fsys, absPath) {
+			return openBareRepository(fsys, absPath)
+		}
+
+		parent := filepath.Dir(absPath)
+		if parent == absPath {
+			return nil, fmt.Errorf("not a git repository (or any of the parent directories): %s", GitExtension)
+		}
+		absPath = parent
+	}
+}
+
+// DefaultBranch is the initial branch of new repositories when neither
+// init -b nor init.defaultBranch names another.
+const DefaultBranch = "master"
+
+// TemplateDirEnv names the init template directory, unless init --template
+// names another.
+const TemplateDirEnv = "GIT_TEMPLATE_DIR"
+
+// InitOptions controls how CreateGitRepository sets up a repository.
+type InitOptions struct {
+	InitialBranch string // The branch HEAD starts on; init.defaultBranch or master when empty.
+	Template      string // Directory copied into the git directory; GIT_TEMPLATE_DIR or init.templateDir when empty.
+	Shared        string // core.sharedRepository: umask, group, all or an octal mode like 0640.
+	Bare          bool   // Make path the git directory of a repository without a worktree.
+}
+
+// CreateGitRepository creates an empty repository at path, or reinitializes
+// the repository already there. Reinitializing keeps the objects, refs,
+// HEAD and config and only adds what is missing: directories, template
+// files and, with opts.Shared, the sharing settings.
+//
+// Parameters:
+// - path: The worktree of the repository, or its git directory when bare.
+// - opts: How to set up the repository. InitialBranch is ignored when
+// reinitializing.
+//
+// Returns:
+// - The repository, with absolute paths.
+// - Whether an existing repository was reinitialized.
+// - An error if the branch name is invalid, path holds something other
+// than a repository or the files cannot be written.
+func CreateGitRepository(path string, opts InitOptions) (*GitRepository, bool, error) {
+	defer trace.Start(trace.Perf, "create repository", "path", path)()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	global := GlobalConfig()
+	branch := opts.InitialBranch
+	if branch == "" {
+		branch = global.GetString("init.defaultbranch")
+	}
+	if branch == "" {
+		branch = DefaultBranch
+	}
+	if branch == HeadFile || !validRefName(BranchesPrefix+branch) {
+		return nil, false, fmt.Errorf("invalid initial branch name: '%s'", branch)
+	}
+	var shared sharedPerm
+	if opts.Shared != "" {
+		if shared, err = parseSharedPerm(opts.Shared); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var repo *GitRepository
+	if opts.Bare {
+		repo = &GitRepository{GitDir: path, fs: OSFileSystem{}}
+		err = loadRepoConfig(repo, true)
+	} else {
+		repo, err = initializeGitRepo(OSFileSystem{}, path, true)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	reinit := pathExists(repo.fs, createRepoPath(repo, HeadFile))
+	if !reinit {
+		if err := ensureValidRepoExists(repo); err != nil {
+			return nil, false, err
+		}
 	}
 
 	if err := createInitialDirectories(repo); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	if err := createGitFiles(repo); err != nil {
-		return nil, err
+	template := opts.Template
+	if template == "" {
+		template = os.Getenv(TemplateDirEnv)
+	}
+	if template == "" {
+		template = global.GetString("init.templatedir")
+	}
+	if template != "" {
+		if isDir, _ := isDir(OSFileSystem{}, template); isDir {
+			if err := copyTemplate(template, repo.GitDir); err != nil {
+				return nil, false, err
+			}
+		} else {
+			trace.Log(trace.Config, "templates not found", "path", template)
+		}
 	}
 
-	config := repoDefaultConfig()
-	config.SetConfigFile(repoFile(repo, false, ConfigFile))
+	if reinit {
+		if value := shared.configValue(); value != "" {
+			if err := SetConfig(repo, "core", "", "sharedrepository", value); err != nil {
+				return nil, false, err
+			}
+		}
+	} else {
+		if err := createGitFiles(repo, branch); err != nil {
+			return nil, false, err
+		}
 
-	if err := config.WriteConfig(); err != nil {
-		return nil, err
+		config := repoDefaultConfig(repo.GitDir, opts.Bare)
+		if value := shared.configValue(); value != "" {
+			// Like git, a shared repository refuses rewinds by default.
+			config.Set("core.sharedrepository", value)
+			config.Set("receive.denynonfastforwards", "true")
+		}
+		config.SetConfigFile(repoFile(repo, false, ConfigFile))
+
+		if err := config.WriteConfig(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	err = walkDir(repo.fs, repo.GitDir, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return shared.apply(repo.fs, path)
+	})
+	if err != nil {
+		return nil, false, err
 	}
-	return repo, nil
+	return repo, reinit, nil
 }
 
 func ensureValidRepoExists(repo *GitRepository) error {
-	if pathExists(repo.GitDir) {
-		isDir, err := isDir(repo.WorkTree)
-		if err != nil || !isDir {
-			return fmt.Errorf("'%s' is not a directory", repo.WorkTree)
+	if pathExists(repo.fs, repo.GitDir) {
+		if !repo.IsBare() {
+			isDir, err := isDir(repo.fs, repo.WorkTree)
+			if err != nil || !isDir {
+				return fmt.Errorf("'%s' is not a directory", repo.WorkTree)
+			}
 		}
 
-		dirs, err := listDir(repo.GitDir)
+		dirs, err := listDir(repo.fs, repo.GitDir)
 		if err != nil || len(dirs) > 0 {
 			return fmt.Errorf("'%s' is not an empty directory", repo.GitDir)
 		}
 	} else {
-		if err := os.MkdirAll(repo.WorkTree, 0755); err != nil {
+		top := repo.WorkTree
+		if repo.IsBare() {
+			top = repo.GitDir
+		}
+		if err := os.MkdirAll(top, 0755); err != nil {
 			return err
 		}
 	}
@@ -142,20 +399,23 @@ func createInitialDirectories(repo *GitRepository) error {
 //
 // Parameters:
 // - repo: A pointer to a GitRepository struct containing the repository paths.
+// - branch: The branch HEAD points at.
 //
 // Returns:
 // - An error if any of the file creation operations fail.
-func createGitFiles(repo *GitRepository) error {
-	// .git/description
+func createGitFiles(repo *GitRepository, branch string) error {
+	// .git/description, unless the template provided one
 	descriptionPath := repoFile(repo, false, DescFile)
 	descriptionContent := "Unnamed repository; edit this file 'description' to name the repository.\n"
-	if err := os.WriteFile(descriptionPath, []byte(descriptionContent), 0644); err != nil {
-		return err
+	if !pathExists(repo.fs, descriptionPath) {
+		if err := os.WriteFile(descriptionPath, []byte(descriptionContent), 0644); err != nil {
+			return err
+		}
 	}
 
 	// .git/HEAD
 	headPath := repoFile(repo, false, HeadFile)
-	headContent := "ref: refs/heads/master\n"
+	headContent := symbolicPrefix + BranchesPrefix + branch + "\n"
 	if err := os.WriteFile(headPath, []byte(headContent), 0644); err != nil {
 		return err
 	}
@@ -163,16 +423,43 @@ func createGitFiles(repo *GitRepository) error {
 	return nil
 }
 
+// configKey builds the viper key for a git configuration variable. Git writes
+// subsections as [section "subsection"], which viper keeps as the section name.
+//
+// Parameters:
+// - section: The configuration section, e.g. "maintenance".
+// - subsection: The optional subsection, e.g. "gc". May be empty.
+// - name: The variable name, e.g. "enabled".
+//
+// Returns:
+// - The key to pass to viper.
+func configKey(section, subsection, name string) string {
+	if subsection == "" {
+		return section + "." + name
+	}
+	return fmt.Sprintf("%s \"%s\".%s", section, subsection, name)
+}
+
 // repoDefaultConfig creates and returns a default configuration for a Git repository.
+// Like git, it probes the file system holding gitDir for executable bits and
+// symlinks; core.symlinks is only written when they are missing, and
+// core.logAllRefUpdates only with a worktree.
 //
 // Returns:
 // - A pointer to a viper.Viper instance containing the default configuration.
-func repoDefaultConfig() *viper.Viper {
+func repoDefaultConfig(gitDir string, bare bool) *viper.Viper {
 	config := viper.New()
 
 	config.Set("core.repositoryformatversion", "0")
-	config.Set("core.filemode", "false")
-	config.Set("core.bare", "false")
+	config.Set("core.filemode", strconv.FormatBool(probeFileMode(gitDir)))
+	if !probeSymlinks(gitDir) {
+		config.Set("core.symlinks", "false")
+	}
+	config.Set("core.bare", strconv.FormatBool(bare))
+	if !bare {
+		config.Set("core.logallrefupdates", "true")
+	}
+	config.SetConfigType("ini")
 
 	return config
 }
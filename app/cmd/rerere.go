@@ -0,0 +1,496 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// Files of the rerere machinery, relative to the git directory. Every
+// conflict seen has a directory in rr-cache named after its conflict ID,
+// holding the conflict as a "preimage" and, once resolved, the resolution
+// as a "postimage". MERGE_RR lists the conflicted paths awaiting a
+// resolution, as "<id>\t<path>\0" records.
+const (
+	RerereCacheDir = "rr-cache"
+	MergeRRFile    = "MERGE_RR"
+)
+
+// How long rerere gc keeps resolved and unresolved conflicts by default.
+const (
+	defaultRerereResolved   = "60.days.ago"
+	defaultRerereUnresolved = "15.days.ago"
+)
+
+// conflictMarkerSize is the length of the conflict markers rerere looks for.
+const conflictMarkerSize = 7
+
+// RerereEnabled reports whether conflict resolutions are recorded and
+// reused: rerere.enabled, or when that is not set, whether the rr-cache
+// directory exists, as in git.
+func RerereEnabled(repo *GitRepository) bool {
+	if value := lookupConfig(repo, "rerere.enabled"); value != "" {
+		enabled, _ := strconv.ParseBool(value)
+		return enabled
+	}
+	return pathExists(repo.fs, createRepoPath(repo, RerereCacheDir))
+}
+
+// RerereAutoUpdate reports whether rerere.autoUpdate asks for files
+// resolved with a previous resolution to be staged.
+func RerereAutoUpdate(repo *GitRepository) bool {
+	enabled, _ := strconv.ParseBool(lookupConfig(repo, "rerere.autoupdate"))
+	return enabled
+}
+
+// isConflictMarker reports whether line is a conflict marker made of the
+// given character. The markers opening and closing a conflict must be
+// followed by a label.
+func isConflictMarker(line string, marker byte) bool {
+	if len(line) <= conflictMarkerSize || line[:conflictMarkerSize] != strings.Repeat(string(marker), conflictMarkerSize) {
+		return false
+	}
+	next := line[conflictMarkerSize]
+	if marker == '<' || marker == '>' {
+		return next == ' '
+	}
+	return next == ' ' || next == '\t' || next == '\n' || next == '\r'
+}
+
+// normalizeConflicts finds the conflicts in a file and computes its
+// conflict ID the way git does, so that the same conflict is recognized
+// whichever side is ours and whatever the labels are: of every conflict,
+// the base section is dropped and the two sides are sorted, and the ID
+// hashes the sides of all conflicts.
+//
+// Returns:
+// - The conflict ID, empty when the file has no complete conflict.
+// - The file with its conflicts normalized, with bare markers.
+func normalizeConflicts(content []byte) (string, []byte) {
+	const (
+		outside = iota
+		ours
+		base
+		theirs
+	)
+	hash := sha1.New()
+	var out, one, two strings.Builder
+	state, conflicts := outside, 0
+	for _, line := range splitLines(content) {
+		switch {
+		case state == outside && isConflictMarker(line, '<'):
+			state = ours
+			one.Reset()
+			two.Reset()
+		case state == ours && isConflictMarker(line, '|'):
+			state = base
+		case (state == ours || state == base) && isConflictMarker(line, '='):
+			state = theirs
+		case state == theirs && isConflictMarker(line, '>'):
+			state = outside
+			conflicts++
+			first, second := one.String(), two.String()
+			if first > second {
+				first, second = second, first
+			}
+			fmt.Fprintf(&out, "%s\n%s%s\n%s%s\n", conflictOursMarker, first, conflictSepMarker, second, conflictTheirsMarker)
+			hash.Write([]byte(first + "\x00" + second + "\x00"))
+		case state == ours:
+			one.WriteString(line)
+		case state == theirs:
+			two.WriteString(line)
+		case state == outside:
+			out.WriteString(line)
+		}
+	}
+	if conflicts == 0 || state != outside {
+		return "", nil
+	}
+	return hex.EncodeToString(hash.Sum(nil)), []byte(out.String())
+}
+
+// rerereImage returns the path of the preimage or postimage of a conflict.
+func rerereImage(repo *GitRepository, id, image string) string {
+	return createRepoPath(repo, RerereCacheDir, id, image)
+}
+
+// readMergeRR reads the conflicted paths rerere waits to see resolved,
+// mapped to their conflict IDs.
+func readMergeRR(repo *GitRepository) (map[string]string, error) {
+	data, err := readFile(repo.fs, createRepoPath(repo, MergeRRFile))
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]string)
+	for _, record := range strings.Split(string(data), "\x00") {
+		if id, path, ok := strings.Cut(record, "\t"); ok {
+			pending[path] = id
+		}
+	}
+	return pending, nil
+}
+
+// writeMergeRR writes the conflicted paths rerere waits to see resolved.
+func writeMergeRR(repo *GitRepository, pending map[string]string) error {
+	paths := make([]string, 0, len(pending))
+	for path := range pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var out bytes.Buffer
+	for _, path := range paths {
+		fmt.Fprintf(&out, "%s\t%s\x00", pending[path], path)
+	}
+	return writeFileAtomic(repo.fs, createRepoPath(repo, MergeRRFile), out.Bytes(), 0644)
+}
+
+// RerereResult lists what a call to Rerere did, by path.
+type RerereResult struct {
+	Recorded    []string // Conflicts seen for the first time, whose preimage was recorded.
+	Resolved    []string // Conflicts resolved in the worktree with a previous resolution.
+	Staged      []string // Of those, the files staged because of rerere.autoUpdate.
+	Resolutions []string // Conflicts the user resolved, whose resolution was recorded.
+}
+
+// Rerere records conflicts and their resolutions, and reuses recorded
+// resolutions, as git rerere does. Every unmerged path whose worktree file
+// holds conflict markers is looked up by its conflict ID: when a
+// resolution of the same conflict was recorded before, it is applied to
+// the file, otherwise the conflict is recorded and the path remembered in
+// MERGE_RR. A remembered path whose file no longer has conflict markers
+// was resolved by the user, and the file is recorded as the resolution.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+//
+// Returns:
+// - The paths recorded, resolved and staged.
+// - An error if a file or the index cannot be read or written.
+func Rerere(repo *GitRepository) (*RerereResult, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	defer trace.Start(trace.Perf, "rerere")()
+
+	pending, err := readMergeRR(repo)
+	if err != nil {
+		return nil, err
+	}
+	lock, index, err := LockIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	result := &RerereResult{}
+
+	// Paths already waiting for a resolution first, so that a conflict
+	// resolved in this call is not taken for a new one.
+	paths := make([]string, 0, len(pending))
+	for path := range pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		content, err := os.ReadFile(worktreePath(repo, path))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if id, _ := normalizeConflicts(content); id != "" {
+			continue
+		}
+		if err == nil {
+			if err := writeFileAtomic(repo.fs, rerereImage(repo, pending[path], "postimage"), content, 0644); err != nil {
+				return nil, err
+			}
+			result.Resolutions = append(result.Resolutions, path)
+		}
+		delete(pending, path)
+	}
+
+	staged := false
+	for _, path := range rerereConflicts(index) {
+		if _, ok := pending[path]; ok {
+			continue
+		}
+		file := worktreePath(repo, path)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		id, normalized := normalizeConflicts(content)
+		if id == "" {
+			continue
+		}
+
+		resolved, ok, err := replayResolution(repo, id, normalized)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if err := writeFileAtomic(repo.fs, rerereImage(repo, id, "preimage"), normalized, 0644); err != nil {
+				return nil, err
+			}
+			if err := repo.fs.Remove(rerereImage(repo, id, "postimage")); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			pending[path] = id
+			result.Recorded = append(result.Recorded, path)
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(file, resolved, info.Mode().Perm()); err != nil {
+			return nil, err
+		}
+		result.Resolved = append(result.Resolved, path)
+		if RerereAutoUpdate(repo) {
+			if err := StagePath(repo, index, path, nil); err != nil {
+				return nil, err
+			}
+			result.Staged = append(result.Staged, path)
+			staged = true
+		}
+	}
+
+	if staged {
+		if err := lock.Write(index); err != nil {
+			return nil, err
+		}
+	}
+	return result, writeMergeRR(repo, pending)
+}
+
+// rerereConflicts lists the unmerged paths of the index that rerere can
+// handle: those that are regular files on both sides.
+func rerereConflicts(index *Index) []string {
+	var paths []string
+	for _, entry := range index.Entries {
+		if entry.Stage() != 2 {
+			continue
+		}
+		stages := index.Stages(entry.Name)
+		if stages[3] != nil && indexModeString(stages[2].Mode) != ModeSymlink && modeKind(indexModeString(stages[2].Mode)) == "file" &&
+			indexModeString(stages[3].Mode) != ModeSymlink && modeKind(indexModeString(stages[3].Mode)) == "file" {
+			paths = append(paths, entry.Name)
+		}
+	}
+	return paths
+}
+
+// replayResolution applies the recorded resolution of a conflict to a file
+// holding it, normalized: the changes from the preimage to the postimage
+// are merged into the file.
+//
+// Returns:
+// - The resolved file.
+// - Whether a resolution was recorded and applied cleanly.
+// - An error if the recorded images cannot be read.
+func replayResolution(repo *GitRepository, id string, normalized []byte) ([]byte, bool, error) {
+	preimage, err := readFile(repo.fs, rerereImage(repo, id, "preimage"))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	postimage, err := readFile(repo.fs, rerereImage(repo, id, "postimage"))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	resolved, conflicted := MergeContent(preimage, normalized, postimage, MergeLabels{})
+	if conflicted {
+		return nil, false, nil
+	}
+	// Using a resolution keeps it from expiring.
+	now := time.Now()
+	_ = os.Chtimes(rerereImage(repo, id, "postimage"), now, now)
+	return resolved, true, nil
+}
+
+// RerereResolveContent resolves the conflicts in a merged file with a
+// recorded resolution, for commands that merge files in memory, such as
+// rebase.
+//
+// Parameters:
+// - repo: The repository whose rr-cache to use.
+// - content: A file with conflict markers.
+//
+// Returns:
+// - The resolved file.
+// - Whether a resolution was found and applied cleanly.
+// - An error if the recorded images cannot be read.
+func RerereResolveContent(repo *GitRepository, content []byte) ([]byte, bool, error) {
+	id, normalized := normalizeConflicts(content)
+	if id == "" {
+		return nil, false, nil
+	}
+	return replayResolution(repo, id, normalized)
+}
+
+// RerereStatus lists the paths whose conflicts rerere recorded and waits
+// to see resolved.
+func RerereStatus(repo *GitRepository) ([]string, error) {
+	pending, err := readMergeRR(repo)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(pending))
+	for path := range pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// RerereForget drops the recorded resolution of the conflict a path is
+// in, as git rerere forget does, so that a wrong resolution is not reused.
+// The conflict is rebuilt from the stages of the index, recorded anew and
+// the path remembered in MERGE_RR, so that the next resolution is
+// recorded instead.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - path: An unmerged path.
+//
+// Returns:
+// - An error if the path is not unmerged or has no recorded resolution.
+func RerereForget(repo *GitRepository, path string) error {
+	index, err := ReadIndex(repo)
+	if err != nil {
+		return err
+	}
+	stages := index.Stages(path)
+	if stages[2] == nil || stages[3] == nil {
+		return fmt.Errorf("'%s' is not unmerged", path)
+	}
+
+	objects := NewObjectManager(repo)
+	var contents [3][]byte
+	for i, entry := range stages[1:] {
+		if entry == nil {
+			continue
+		}
+		if _, contents[i], err = objects.ReadObject(entry.SHA); err != nil {
+			return err
+		}
+	}
+	merged, _ := MergeContent(contents[0], contents[1], contents[2], MergeLabels{Ours: "ours", Theirs: "theirs"})
+	id, normalized := normalizeConflicts(merged)
+	if id == "" {
+		return fmt.Errorf("could not parse conflict hunks in '%s'", path)
+	}
+	if err := repo.fs.Remove(rerereImage(repo, id, "postimage")); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no remembered resolution for '%s'", path)
+		}
+		return err
+	}
+	if err := writeFileAtomic(repo.fs, rerereImage(repo, id, "preimage"), normalized, 0644); err != nil {
+		return err
+	}
+
+	pending, err := readMergeRR(repo)
+	if err != nil {
+		return err
+	}
+	pending[path] = id
+	return writeMergeRR(repo, pending)
+}
+
+// RerereClear forgets the conflicts awaiting a resolution, as after an
+// aborted merge: their rr-cache entries, which have no resolution, and
+// MERGE_RR are removed.
+func RerereClear(repo *GitRepository) error {
+	pending, err := readMergeRR(repo)
+	if err != nil {
+		return err
+	}
+	for _, id := range pending {
+		if !pathExists(repo.fs, rerereImage(repo, id, "postimage")) {
+			if err := repo.fs.RemoveAll(createRepoPath(repo, RerereCacheDir, id)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := repo.fs.Remove(createRepoPath(repo, MergeRRFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// RerereGC removes old entries from the rr-cache, as git rerere gc does:
+// resolved conflicts whose resolution was last used before
+// gc.rerereResolved (60 days ago by default), and unresolved ones
+// recorded before gc.rerereUnresolved (15 days ago by default).
+//
+// Parameters:
+// - repo: The repository.
+// - now: The time the expiry dates count back from.
+//
+// Returns:
+// - The number of entries removed.
+// - An error if an expiry is invalid or the rr-cache cannot be read.
+func RerereGC(repo *GitRepository, now time.Time) (int, error) {
+	cutoffs := make([]time.Time, 2)
+	for i, key := range []string{"gc.rerereresolved", "gc.rerereunresolved"} {
+		value := lookupConfig(repo, key)
+		if value == "" {
+			value = []string{defaultRerereResolved, defaultRerereUnresolved}[i]
+		} else if days, err := strconv.Atoi(value); err == nil {
+			value = fmt.Sprintf("%d.days.ago", days)
+		}
+		cutoff, err := ParseExpiry(value, now)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s '%s': %w", key, value, err)
+		}
+		cutoffs[i] = cutoff
+	}
+
+	dir := createRepoPath(repo, RerereCacheDir)
+	entries, err := listDir(repo.fs, dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		image, cutoff := "postimage", cutoffs[0]
+		info, err := repo.fs.Stat(filepath.Join(dir, entry.Name(), image))
+		if err != nil {
+			image, cutoff = "preimage", cutoffs[1]
+			info, err = repo.fs.Stat(filepath.Join(dir, entry.Name(), image))
+		}
+		if err == nil && !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := repo.fs.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
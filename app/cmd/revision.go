@@ -0,0 +1,620 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// minAbbrevLength is the shortest object name prefix accepted as a revision.
+const minAbbrevLength = 4
+
+// DefaultAbbrevLength is the length of abbreviated object names in small
+// repositories, where core.abbrev is "auto".
+const DefaultAbbrevLength = 7
+
+// ResolveRevision turns a revision into the SHA-1 of the object it names.
+// Supported forms are full and abbreviated object names, ref names (HEAD,
+// master, origin/master, refs/tags/v1, ...), "@" for HEAD, the reflog and
+// upstream forms of resolveAtSuffix such as master@{1} or @{upstream}, and
+// any chain of <rev>~<n> (n-th first-parent ancestor), <rev>^<n> (n-th
+// parent), <rev>^{<type>} (peeled to an object of that type) and <rev>^{}
+// (tags peeled to the object they finally point at) suffixes. Any of those
+// followed by ":<path>" names the blob or tree at that path in the tree of
+// the revision, and ":<path>" or ":<stage>:<path>" alone names a blob in
+// the index. Paths starting with "./" or "../" are relative to the current
+// directory.
+//
+// Parameters:
+// - repo: The repository to resolve in.
+// - rev: The revision, e.g. "HEAD~2", "v1.0^{tree}", "@{1}^2" or
+// "HEAD~1:app/main.go".
+//
+// Returns:
+// - The SHA-1 of the named object.
+// - An error if the revision is unknown or ambiguous.
+func ResolveRevision(repo *GitRepository, rev string) (string, error) {
+	if treeish, path, ok := splitRevisionPath(rev); ok {
+		return resolveRevisionPath(repo, treeish, path)
+	}
+	base, suffix := splitRevision(rev)
+
+	objects := NewObjectManager(repo).UseReplaceRefs().UseGrafts()
+	sha, err := resolveRevisionBase(repo, objects, base)
+	if err != nil {
+		return "", err
+	}
+
+	for suffix != "" {
+		if rest, ok := strings.CutPrefix(suffix, "^{"); ok {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return "", fmt.Errorf("revision '%s': missing '}'", rev)
+			}
+			if sha, err = objects.peelRevision(sha, rest[:end]); err != nil {
+				return "", fmt.Errorf("revision '%s': %w", rev, err)
+			}
+			suffix = rest[end+1:]
+			continue
+		}
+
+		op := suffix[0]
+		digits := 0
+		for digits+1 < len(suffix) && suffix[digits+1] >= '0' && suffix[digits+1] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 0 {
+			n, _ = strconv.Atoi(suffix[1 : digits+1])
+		}
+		suffix = suffix[digits+1:]
+
+		commitSHA, objType, err := objects.PeelObject(sha)
+		if err != nil {
+			return "", err
+		}
+		if objType != CommitType {
+			return "", fmt.Errorf("revision '%s': %s is a %s, not a commit", rev, sha, objType)
+		}
+		sha = commitSHA
+
+		if op == '^' {
+			if n == 0 {
+				continue
+			}
+			commit, err := objects.ReadCommit(sha)
+			if err != nil {
+				return "", err
+			}
+			if n > len(commit.Parents) {
+				return "", fmt.Errorf("revision '%s': commit %s has no parent %d", rev, sha, n)
+			}
+			sha = commit.Parents[n-1]
+			continue
+		}
+
+		for ; n > 0; n-- {
+			commit, err := objects.ReadCommit(sha)
+			if err != nil {
+				return "", err
+			}
+			if len(commit.Parents) == 0 {
+				return "", fmt.Errorf("revision '%s': commit %s has no parent", rev, sha)
+			}
+			sha = commit.Parents[0]
+		}
+	}
+	return sha, nil
+}
+
+// splitRevision splits a revision before its first "~" or "^" suffix. The
+// braces of an @{...} part may contain anything.
+func splitRevision(rev string) (base, suffix string) {
+	depth := 0
+	for i, c := range rev {
+		switch {
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case (c == '~' || c == '^') && depth == 0:
+			return rev[:i], rev[i:]
+		}
+	}
+	return rev, ""
+}
+
+// splitRevisionPath splits "<rev>:<path>" at its first colon outside of
+// braces, so that the colons of "@{...}" stay in the revision.
+func splitRevisionPath(rev string) (treeish, path string, ok bool) {
+	depth := 0
+	for i, c := range rev {
+		switch {
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == ':' && depth == 0:
+			return rev[:i], rev[i+1:], true
+		}
+	}
+	return rev, "", false
+}
+
+// ResolveRevisionFollowingSymlinks resolves a revision like
+// ResolveRevision, except that the path of a "<rev>:<path>" revision is
+// looked up with FollowTreePath, following the symlinks of the tree of
+// rev, as cat-file --follow-symlinks does.
+//
+// Parameters:
+// - repo: The repository to resolve in.
+// - rev: The revision, e.g. "HEAD:docs/current/index.md".
+//
+// Returns:
+// - The entry found or why there is none. Other forms of revisions give
+// an entry holding only the SHA-1 they name.
+// - An error if the revision cannot be resolved, except for a path that
+// is not in the tree.
+func ResolveRevisionFollowingSymlinks(repo *GitRepository, rev string) (FollowedPath, error) {
+	treeish, path, ok := splitRevisionPath(rev)
+	if !ok || treeish == "" {
+		sha, err := ResolveRevision(repo, rev)
+		return FollowedPath{Entry: TreeEntry{SHA: sha}}, err
+	}
+
+	spec := treeish + ":" + path
+	path, err := revisionPath(repo, spec, path)
+	if err != nil {
+		return FollowedPath{}, err
+	}
+	objects, tree, err := revisionTree(repo, treeish, spec)
+	if err != nil {
+		return FollowedPath{}, err
+	}
+	return objects.FollowTreePath(tree, path)
+}
+
+// resolveRevisionPath resolves the path of a "<rev>:<path>" revision in
+// the tree of rev, or in the index when rev is empty.
+func resolveRevisionPath(repo *GitRepository, treeish, path string) (string, error) {
+	spec := treeish + ":" + path
+	path, err := revisionPath(repo, spec, path)
+	if err != nil {
+		return "", err
+	}
+
+	if treeish == "" {
+		stage := 0
+		if len(path) > 2 && path[0] >= '0' && path[0] <= '3' && path[1] == ':' {
+			stage, path = int(path[0]-'0'), path[2:]
+		}
+		index, err := ReadIndex(repo)
+		if err != nil {
+			return "", err
+		}
+		if entry := index.Stages(path)[stage]; entry != nil {
+			return entry.SHA, nil
+		}
+		return "", fmt.Errorf("path '%s' does not exist (neither on disk nor in the index) at stage %d", path, stage)
+	}
+
+	objects, tree, err := revisionTree(repo, treeish, spec)
+	if err != nil {
+		return "", err
+	}
+	entry, err := objects.TreeEntryAt(tree, path)
+	if err != nil {
+		return "", fmt.Errorf("path '%s' does not exist in '%s'", path, treeish)
+	}
+	return entry.SHA, nil
+}
+
+// revisionPath makes the path of a "<rev>:<path>" revision relative to the
+// top of the worktree when it starts with "./" or "../".
+func revisionPath(repo *GitRepository, spec, path string) (string, error) {
+	if strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		top, err := filepath.Abs(repo.WorkTree)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(top, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("revision '%s': '%s' is outside repository", spec, path)
+		}
+		if path = filepath.ToSlash(rel); path == "." {
+			path = ""
+		}
+	}
+	return path, nil
+}
+
+// revisionTree resolves the revision of a "<rev>:<path>" revision to its
+// tree.
+func revisionTree(repo *GitRepository, treeish, spec string) (*ObjectManager, string, error) {
+	sha, err := ResolveRevision(repo, treeish)
+	if err != nil {
+		return nil, "", err
+	}
+	objects := NewObjectManager(repo).UseReplaceRefs()
+	tree, err := objects.PeelToType(sha, TreeType)
+	if err != nil {
+		return nil, "", fmt.Errorf("revision '%s': %w", spec, err)
+	}
+	return objects, tree, nil
+}
+
+// peelRevision applies a ^{<spec>} suffix: an empty spec peels tags,
+// "object" only requires the object to exist, and a type name peels to an
+// object of that type.
+func (m *ObjectManager) peelRevision(sha, spec string) (string, error) {
+	switch spec {
+	case "":
+		peeled, _, err := m.PeelObject(sha)
+		return peeled, err
+	case "object":
+		if !m.Has(sha) {
+			return "", fmt.Errorf("object %s not found", sha)
+		}
+		return sha, nil
+	}
+
+	objType, err := parseObjectType(spec)
+	if err != nil {
+		return "", err
+	}
+	return m.PeelToType(sha, objType)
+}
+
+// resolveRevisionBase resolves the part of a revision before any suffix: a
+// full object name, then "@" and the @{...} forms, then a ref, then an
+// abbreviated object name.
+func resolveRevisionBase(repo *GitRepository, objects *ObjectManager, name string) (string, error) {
+	if isValidSHA(name) {
+		return name, nil
+	}
+	if name == "@" {
+		name = HeadFile
+	}
+	if at := strings.Index(name, "@{"); at >= 0 && strings.HasSuffix(name, "}") {
+		return resolveAtSuffix(repo, objects, name[:at], name[at+2:len(name)-1])
+	}
+
+	if refName, err := ExpandRefName(repo, name); err == nil {
+		if sha, err := ResolveRef(repo, refName); err == nil {
+			return sha, nil
+		}
+	}
+
+	if len(name) >= minAbbrevLength && isHex(name) {
+		matches, err := objects.objectsWithPrefix(strings.ToLower(name))
+		if err != nil {
+			return "", err
+		}
+		switch len(matches) {
+		case 1:
+			return matches[0], nil
+		case 0:
+		default:
+			return "", fmt.Errorf("short object ID %s is ambiguous", name)
+		}
+	}
+	return "", fmt.Errorf("unknown revision '%s'", name)
+}
+
+// resolveAtSuffix resolves <ref>@{<spec>}. An empty ref means the current
+// branch, or HEAD when it is detached. The spec is one of:
+//   - n: the n-th prior value of the ref from its reflog, @{0} being the
+//     current one;
+//   - a date such as "yesterday" or "2.hours.ago": the value the ref had
+//     then, according to its reflog;
+//   - upstream or u: the branch the ref tracks;
+//   - -n, without a ref: the n-th branch checked out before the current one.
+func resolveAtSuffix(repo *GitRepository, objects *ObjectManager, ref, spec string) (string, error) {
+	if n, err := strconv.Atoi(strings.TrimPrefix(spec, "-")); err == nil && strings.HasPrefix(spec, "-") && ref == "" {
+		previous, err := previousCheckout(repo, n)
+		if err != nil {
+			return "", err
+		}
+		return resolveRevisionBase(repo, objects, previous)
+	}
+
+	switch strings.ToLower(spec) {
+	case "upstream", "u":
+		branch := strings.TrimPrefix(ref, BranchesPrefix)
+		if branch == "" || branch == HeadFile {
+			current, ok := CurrentBranch(repo)
+			if !ok {
+				return "", fmt.Errorf("HEAD does not point to a branch")
+			}
+			branch = current
+		}
+		upstream, ok := BranchUpstream(repo, branch)
+		if !ok {
+			return "", fmt.Errorf("no upstream configured for branch '%s'", branch)
+		}
+		return ResolveRef(repo, upstream.Ref)
+	}
+
+	refName := HeadFile
+	switch {
+	case ref == "":
+		if branch, ok := CurrentBranch(repo); ok {
+			refName = BranchesPrefix + branch
+		}
+	case ref != HeadFile:
+		expanded, err := ExpandRefName(repo, ref)
+		if err != nil {
+			return "", err
+		}
+		refName = expanded
+	}
+
+	entries, err := ReadReflog(repo, refName)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("log for '%s' is empty", refName)
+	}
+
+	n, err := strconv.Atoi(spec)
+	if err != nil {
+		when, err := ParseExpiry(spec, time.Now())
+		if err != nil {
+			return "", fmt.Errorf("unknown reflog selector '@{%s}'", spec)
+		}
+		// The value at a date is set by the newest entry made before it. For
+		// dates before the reflog starts git settles for its oldest value.
+		for i := len(entries) - 1; i >= 0; i-- {
+			if !ParseSignature(entries[i].Committer).When.After(when) {
+				return entries[i].New, nil
+			}
+		}
+		if entries[0].Old != zeroSHA {
+			return entries[0].Old, nil
+		}
+		return entries[0].New, nil
+	}
+
+	switch {
+	case n < 0:
+		return "", fmt.Errorf("unknown reflog selector '@{%s}'", spec)
+	case n < len(entries):
+		return entries[len(entries)-1-n].New, nil
+	case n == len(entries) && entries[0].Old != zeroSHA:
+		return entries[0].Old, nil
+	}
+	return "", fmt.Errorf("log for '%s' only has %d entries", refName, len(entries))
+}
+
+// previousCheckout returns the branch, or commit, that was checked out
+// before the n-th most recent checkout, from the messages of the HEAD reflog.
+func previousCheckout(repo *GitRepository, n int) (string, error) {
+	entries, err := ReadReflog(repo, HeadFile)
+	if err != nil {
+		return "", err
+	}
+
+	found := 0
+	for i := len(entries) - 1; i >= 0 && n > 0; i-- {
+		rest, ok := strings.CutPrefix(entries[i].Message, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		if found++; found == n {
+			from, _, _ := strings.Cut(rest, " to ")
+			return from, nil
+		}
+	}
+	return "", fmt.Errorf("only %d branches were checked out before", found)
+}
+
+// isHex reports whether s consists only of hexadecimal digits.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// objectsWithPrefix lists the distinct objects, loose or packed, whose name
+// starts with prefix. The prefix must be at least two characters long.
+func (m *ObjectManager) objectsWithPrefix(prefix string) ([]string, error) {
+	found := make(map[string]bool)
+
+	files, err := m.repo.fs.ReadDir(createRepoPath(m.repo, ObjectsDir, prefix[:2]))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		sha := prefix[:2] + file.Name()
+		if isValidSHA(sha) && strings.HasPrefix(sha, prefix) {
+			found[sha] = true
+		}
+	}
+
+	packs, err := m.packFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		first, _ := strconv.ParseUint(prefix[:2], 16, 8)
+		start := uint32(0)
+		if first > 0 {
+			start = pack.index.fanout[first-1]
+		}
+		for i := int(start); i < int(pack.index.fanout[first]); i++ {
+			if sha := pack.index.sha(i); strings.HasPrefix(sha, prefix) {
+				found[sha] = true
+			}
+		}
+	}
+
+	matches := make([]string, 0, len(found))
+	for sha := range found {
+		matches = append(matches, sha)
+	}
+	return matches, nil
+}
+
+// AbbrevLength returns the minimum length of abbreviated object names set
+// by core.abbrev: a number of hex digits, "no" for full names, or "auto",
+// the default. Like git, auto grows with the number of packed objects so
+// that abbreviations rarely need extending, but is never below
+// DefaultAbbrevLength.
+func (m *ObjectManager) AbbrevLength() int {
+	m.mu.Lock()
+	abbrev := m.abbrev
+	m.mu.Unlock()
+	if abbrev > 0 {
+		return abbrev
+	}
+
+	// Resolved without holding mu, as counting objects loads the packs.
+	abbrev = m.resolveAbbrevLength()
+	m.mu.Lock()
+	m.abbrev = abbrev
+	m.mu.Unlock()
+	return abbrev
+}
+
+// resolveAbbrevLength works out the length AbbrevLength returns.
+func (m *ObjectManager) resolveAbbrevLength() int {
+	value := strings.ToLower(lookupConfig(m.repo, "core.abbrev"))
+	switch value {
+	case "", "auto":
+	case "no", "false", "off":
+		return 40
+	default:
+		if n, err := strconv.Atoi(value); err == nil && n >= minAbbrevLength && n <= 40 {
+			return n
+		}
+		trace.Log(trace.Config, "core.abbrev ignored", "value", value)
+	}
+
+	// git counts packed objects only, which is cheap and close enough.
+	count := 0
+	if packs, err := m.packFiles(); err == nil {
+		for _, pack := range packs {
+			count += pack.index.count()
+		}
+	}
+	// The same estimate as git's: half the bit length of the object count,
+	// taken as hex digits, which leaves a good margin over the bits/2 at
+	// which names start to collide.
+	bits := max(bitLength(count), 1)
+	return max((bits+1)/2, DefaultAbbrevLength)
+}
+
+// bitLength returns the number of bits needed to represent n.
+func bitLength(n int) int {
+	bits := 0
+	for ; n > 0; n >>= 1 {
+		bits++
+	}
+	return bits
+}
+
+// ShortSHA abbreviates an object name to at least minLen hex digits,
+// extended until no other loose or packed object shares the prefix.
+//
+// Parameters:
+// - sha: The full object name.
+// - minLen: The minimum length; 0 or less uses AbbrevLength.
+//
+// Returns:
+// - The abbreviated name, or sha itself if it is not a full object name.
+func (m *ObjectManager) ShortSHA(sha string, minLen int) string {
+	if !isValidSHA(sha) {
+		return sha
+	}
+	if minLen <= 0 {
+		minLen = m.AbbrevLength()
+	}
+	if minLen >= len(sha) {
+		return sha
+	}
+	return sha[:min(max(minLen, m.sharedPrefixLength(sha)+1), len(sha))]
+}
+
+// sharedPrefixLength returns the length of the longest prefix sha shares
+// with another object. Only objects in the same fan-out bucket can share
+// two or more digits, and in a sorted pack index only the neighbors of
+// sha's position need comparing.
+func (m *ObjectManager) sharedPrefixLength(sha string) int {
+	longest := 0
+	files, _ := m.repo.fs.ReadDir(createRepoPath(m.repo, ObjectsDir, sha[:2]))
+	for _, file := range files {
+		if other := sha[:2] + file.Name(); other != sha && isValidSHA(other) {
+			longest = max(longest, commonPrefixLength(sha, other))
+		}
+	}
+
+	packs, err := m.packFiles()
+	if err != nil {
+		return longest
+	}
+	for _, pack := range packs {
+		first, _ := strconv.ParseUint(sha[:2], 16, 8)
+		start, end := 0, int(pack.index.fanout[first])
+		if first > 0 {
+			start = int(pack.index.fanout[first-1])
+		}
+		i := start + sort.Search(end-start, func(i int) bool { return pack.index.sha(start+i) >= sha })
+		for _, j := range []int{i - 1, i, i + 1} {
+			if j < start || j >= end {
+				continue
+			}
+			if other := pack.index.sha(j); other != sha {
+				longest = max(longest, commonPrefixLength(sha, other))
+			}
+		}
+	}
+	return longest
+}
+
+// commonPrefixLength returns the length of the common prefix of a and b.
+func commonPrefixLength(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// ResolveTree resolves a revision to a tree, peeling tags and commits.
+func ResolveTree(repo *GitRepository, rev string) (string, error) {
+	sha, err := ResolveRevision(repo, rev)
+	if err != nil {
+		return "", err
+	}
+
+	objects := NewObjectManager(repo).UseReplaceRefs().UseGrafts()
+	sha, objType, err := objects.PeelObject(sha)
+	if err != nil {
+		return "", err
+	}
+	switch objType {
+	case TreeType:
+		return sha, nil
+	case CommitType:
+		commit, err := objects.ReadCommit(sha)
+		if err != nil {
+			return "", err
+		}
+		return commit.Tree, nil
+	}
+	return "", fmt.Errorf("revision '%s' is a %s, not a tree", rev, objType)
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"container/heap"
+	"slices"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// RevListOrder is the order in which RevList returns commits.
+type RevListOrder int
+
+const (
+	// OrderDefault lists commits by committer date as the walk reaches them.
+	OrderDefault RevListOrder = iota
+	// OrderDate shows no parent before all of its children, otherwise
+	// ordering by committer date.
+	OrderDate
+	// OrderTopo shows no parent before all of its children and avoids
+	// interleaving commits from different lines of history.
+	OrderTopo
+)
+
+// RevListOptions controls how RevList walks and orders history.
+type RevListOptions struct {
+	Order       RevListOrder
+	Reverse     bool // Oldest first; applied after ordering.
+	FirstParent bool // Follow only the first parent of merge commits.
+	Merges      bool // List only commits with more than one parent.
+	NoMerges    bool // List only commits with at most one parent.
+}
+
+// parents returns the parents the walk follows from commit.
+func (o RevListOptions) parents(commit *Commit) []string {
+	if o.FirstParent && len(commit.Parents) > 1 {
+		return commit.Parents[:1]
+	}
+	return commit.Parents
+}
+
+// shows reports whether commit passes the merge filters.
+func (o RevListOptions) shows(commit *Commit) bool {
+	merge := len(commit.Parents) > 1
+	return !(o.Merges && !merge) && !(o.NoMerges && merge)
+}
+
+// RevList returns the commits reachable from any of include but from none of
+// exclude, newest first by committer date, like git rev-list A ^B.
+//
+// Parameters:
+// - include: Commits whose history is listed.
+// - exclude: Commits whose history is left out.
+// - opts: The ordering to apply.
+//
+// Returns:
+// - The SHA-1 of every selected commit.
+// - An error if a commit cannot be read.
+func (m *ObjectManager) RevList(include, exclude []string, opts RevListOptions) ([]string, error) {
+	defer trace.Start(trace.Perf, "rev-list", "include", len(include), "exclude", len(exclude))()
+
+	excluded, err := m.ancestors(exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := &commitQueue{}
+	queued := make(map[string]bool)
+	push := func(sha string) error {
+		if queued[sha] || excluded[sha] {
+			return nil
+		}
+		queued[sha] = true
+		commit, err := m.ReadCommit(sha)
+		if err != nil {
+			return err
+		}
+		queue.Put(commit)
+		return nil
+	}
+
+	for _, sha := range include {
+		if err := push(sha); err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []*Commit
+	for queue.Len() > 0 {
+		commit := queue.Get()
+		commits = append(commits, commit)
+		for _, parent := range opts.parents(commit) {
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.Order != OrderDefault {
+		commits = sortTopological(commits, opts)
+	}
+
+	result := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		if opts.shows(commit) {
+			result = append(result, commit.SHA)
+		}
+	}
+	if opts.Reverse {
+		slices.Reverse(result)
+	}
+	return result, nil
+}
+
+// sortTopological reorders commits so that every commit comes before its
+// parents. It counts, for each commit, the children that are still to be
+// shown (its in-degree) and releases a commit once that reaches zero. Tips
+// are taken in their current order. OrderDate releases the newest ready
+// commit first; OrderTopo follows the most recently released one so each
+// line of history is shown in one piece.
+func sortTopological(commits []*Commit, opts RevListOptions) []*Commit {
+	indegree := make(map[string]int, len(commits))
+	for _, commit := range commits {
+		indegree[commit.SHA] = 0
+	}
+	for _, commit := range commits {
+		for _, parent := range opts.parents(commit) {
+			if _, ok := indegree[parent]; ok {
+				indegree[parent]++
+			}
+		}
+	}
+
+	bySHA := make(map[string]*Commit, len(commits))
+	var ready []*Commit
+	for _, commit := range commits {
+		bySHA[commit.SHA] = commit
+		if indegree[commit.SHA] == 0 {
+			ready = append(ready, commit)
+		}
+	}
+
+	queue := &commitQueue{lifo: opts.Order == OrderTopo}
+	// A stack hands out the last tip first, so push them in reverse.
+	if queue.lifo {
+		slices.Reverse(ready)
+	}
+	for _, commit := range ready {
+		queue.Put(commit)
+	}
+
+	sorted := make([]*Commit, 0, len(commits))
+	for queue.Len() > 0 {
+		commit := queue.Get()
+		for _, parent := range opts.parents(commit) {
+			count, ok := indegree[parent]
+			if !ok {
+				continue
+			}
+			indegree[parent] = count - 1
+			if count == 1 {
+				queue.Put(bySHA[parent])
+			}
+		}
+		sorted = append(sorted, commit)
+	}
+	return sorted
+}
+
+// commitQueue is a priority queue of commits, newest committer date first
+// and first in, first out among equal dates. With lifo set it is a plain
+// stack instead.
+type commitQueue struct {
+	items []queuedCommit
+	next  int
+	lifo  bool
+}
+
+// queuedCommit is a commit with its insertion number, which breaks date ties.
+type queuedCommit struct {
+	commit *Commit
+	time   int64
+	seq    int
+}
+
+func (q *commitQueue) Len() int { return len(q.items) }
+
+func (q *commitQueue) Less(i, j int) bool {
+	if q.items[i].time != q.items[j].time {
+		return q.items[i].time > q.items[j].time
+	}
+	return q.items[i].seq < q.items[j].seq
+}
+
+func (q *commitQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *commitQueue) Push(x any) { q.items = append(q.items, x.(queuedCommit)) }
+
+func (q *commitQueue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
+
+// Put adds a commit to the queue.
+func (q *commitQueue) Put(commit *Commit) {
+	item := queuedCommit{commit: commit, time: commit.CommitTime(), seq: q.next}
+	q.next++
+	if q.lifo {
+		q.items = append(q.items, item)
+		return
+	}
+	heap.Push(q, item)
+}
+
+// Get removes and returns the next commit.
+func (q *commitQueue) Get() *Commit {
+	if q.lifo {
+		return q.Pop().(queuedCommit).commit
+	}
+	return heap.Pop(q).(queuedCommit).commit
+}
+
+// ancestors returns every commit reachable from the given commits, themselves included.
+func (m *ObjectManager) ancestors(shas []string) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	pending := append([]string(nil), shas...)
+
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[sha] {
+			continue
+		}
+		seen[sha] = true
+
+		commit, err := m.ReadCommit(sha)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, commit.Parents...)
+	}
+	return seen, nil
+}
+
+// AheadBehind counts how many commits local has that upstream lacks (ahead)
+// and how many upstream has that local lacks (behind).
+func (m *ObjectManager) AheadBehind(local, upstream string) (ahead, behind int, err error) {
+	aheadCommits, err := m.RevList([]string{local}, []string{upstream}, RevListOptions{})
+	if err != nil {
+		return 0, 0, err
+	}
+	behindCommits, err := m.RevList([]string{upstream}, []string{local}, RevListOptions{})
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(aheadCommits), len(behindCommits), nil
+}
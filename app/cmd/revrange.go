@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RevisionRange is a set of commits described by revision arguments: the
+// history of every Include commit minus the history of every Exclude commit.
+type RevisionRange struct {
+	Include []string
+	Exclude []string
+}
+
+// Empty reports whether no revision was given at all, in which case
+// commands fall back to HEAD.
+func (r *RevisionRange) Empty() bool {
+	return len(r.Include) == 0 && len(r.Exclude) == 0
+}
+
+// ParseRevisionRange resolves revision arguments to commits. Supported
+// forms are "<rev>", "^<rev>" (exclude), "A..B" (B without A), "A...B"
+// (either side but not both, i.e. without their merge bases). An empty side
+// of ".." or "..." means HEAD.
+//
+// Parameters:
+// - repo: The repository to resolve in.
+// - args: The revision arguments.
+//
+// Returns:
+// - The commits to include and exclude.
+// - An error if a revision is unknown or does not name a commit.
+func ParseRevisionRange(repo *GitRepository, args []string) (*RevisionRange, error) {
+	objects := NewObjectManager(repo).UseReplaceRefs().UseGrafts()
+	r := &RevisionRange{}
+
+	for _, arg := range args {
+		if left, right, ok := strings.Cut(arg, "..."); ok {
+			a, err := resolveRangeSide(repo, objects, left)
+			if err != nil {
+				return nil, err
+			}
+			b, err := resolveRangeSide(repo, objects, right)
+			if err != nil {
+				return nil, err
+			}
+			bases, err := objects.MergeBases(a, b)
+			if err != nil {
+				return nil, err
+			}
+			r.Include = append(r.Include, a, b)
+			r.Exclude = append(r.Exclude, bases...)
+			continue
+		}
+
+		if left, right, ok := strings.Cut(arg, ".."); ok {
+			a, err := resolveRangeSide(repo, objects, left)
+			if err != nil {
+				return nil, err
+			}
+			b, err := resolveRangeSide(repo, objects, right)
+			if err != nil {
+				return nil, err
+			}
+			r.Exclude = append(r.Exclude, a)
+			r.Include = append(r.Include, b)
+			continue
+		}
+
+		if rev, ok := strings.CutPrefix(arg, "^"); ok {
+			sha, err := resolveCommit(repo, objects, rev)
+			if err != nil {
+				return nil, err
+			}
+			r.Exclude = append(r.Exclude, sha)
+			continue
+		}
+
+		sha, err := resolveCommit(repo, objects, arg)
+		if err != nil {
+			return nil, err
+		}
+		r.Include = append(r.Include, sha)
+	}
+	return r, nil
+}
+
+// resolveRangeSide resolves one end of a range, defaulting to HEAD.
+func resolveRangeSide(repo *GitRepository, objects *ObjectManager, rev string) (string, error) {
+	if rev == "" {
+		rev = HeadFile
+	}
+	return resolveCommit(repo, objects, rev)
+}
+
+// resolveCommit resolves a revision and peels it to a commit.
+func resolveCommit(repo *GitRepository, objects *ObjectManager, rev string) (string, error) {
+	sha, err := ResolveRevision(repo, rev)
+	if err != nil {
+		return "", err
+	}
+	commit, objType, err := objects.PeelObject(sha)
+	if err != nil {
+		return "", err
+	}
+	if objType != CommitType {
+		return "", fmt.Errorf("revision '%s' is a %s, not a commit", rev, objType)
+	}
+	return commit, nil
+}
+
+// AddRefs includes the commits of every ref under prefix that matches
+// pattern. This implements --all (prefix "refs/", which also adds HEAD),
+// --branches, --tags and --remotes. Like git, a pattern without glob
+// characters matches everything below it, and an empty pattern matches all.
+// Refs that do not point at commits are skipped.
+func (r *RevisionRange) AddRefs(repo *GitRepository, prefix, pattern string) error {
+	refs, err := Refs(repo, prefix)
+	if err != nil {
+		return err
+	}
+
+	if pattern != "" {
+		pattern = prefix + pattern
+		if !strings.ContainsAny(pattern, "*?[") {
+			pattern = strings.TrimSuffix(pattern, "/") + "/*"
+		}
+	}
+
+	objects := NewObjectManager(repo).UseReplaceRefs().UseGrafts()
+	for _, ref := range refs {
+		if pattern != "" && !wildmatch(pattern, ref.Name, false) {
+			continue
+		}
+		commit, objType, err := objects.PeelObject(ref.SHA)
+		if err != nil || objType != CommitType {
+			continue
+		}
+		r.Include = append(r.Include, commit)
+	}
+
+	// git adds HEAD after the refs, which decides the order of date ties.
+	if prefix == RefsDir+"/" {
+		if sha, err := ResolveRef(repo, HeadFile); err == nil {
+			r.Include = append(r.Include, sha)
+		}
+	}
+	return nil
+}
+
+// MergeBases returns the best common ancestors of two commits: the common
+// ancestors that are not themselves ancestors of another common ancestor.
+//
+// Parameters:
+// - a: The first commit.
+// - b: The second commit.
+//
+// Returns:
+// - The merge bases; usually one, none for unrelated histories.
+// - An error if a commit cannot be read.
+func (m *ObjectManager) MergeBases(a, b string) ([]string, error) {
+	fromA, err := m.ancestors([]string{a})
+	if err != nil {
+		return nil, err
+	}
+	fromB, err := m.ancestors([]string{b})
+	if err != nil {
+		return nil, err
+	}
+
+	var common []string
+	var parents []string
+	for sha := range fromA {
+		if !fromB[sha] {
+			continue
+		}
+		common = append(common, sha)
+		commit, err := m.ReadCommit(sha)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, commit.Parents...)
+	}
+
+	// Anything reachable from the parent of a common ancestor is redundant.
+	redundant, err := m.ancestors(parents)
+	if err != nil {
+		return nil, err
+	}
+	var bases []string
+	for _, sha := range common {
+		if !redundant[sha] {
+			bases = append(bases, sha)
+		}
+	}
+	return bases, nil
+}
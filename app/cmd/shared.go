@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// sharedPerm is the permission policy of core.sharedRepository. The zero
+// value leaves permissions to the umask.
+type sharedPerm struct {
+	mode  os.FileMode // Bits added to, or with exact, replacing a file's permissions.
+	exact bool        // Set for an octal value such as 0640.
+}
+
+var (
+	sharedGroup    = sharedPerm{mode: 0660}
+	sharedEveryone = sharedPerm{mode: 0664}
+)
+
+// parseSharedPerm parses a core.sharedRepository or init --shared value
+// the way git does: "umask", "group", "all" (or "world", "everybody"), a
+// boolean, or an octal mode. 0, 1 and 2 are the old spellings of umask,
+// group and all.
+func parseSharedPerm(value string) (sharedPerm, error) {
+	switch strings.ToLower(value) {
+	case "umask":
+		return sharedPerm{}, nil
+	case "group":
+		return sharedGroup, nil
+	case "all", "world", "everybody":
+		return sharedEveryone, nil
+	}
+
+	octal, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		switch strings.ToLower(value) {
+		case "true", "yes", "on", "":
+			return sharedGroup, nil
+		case "false", "no", "off":
+			return sharedPerm{}, nil
+		}
+		return sharedPerm{}, fmt.Errorf("unable to parse value '%s' for option core.sharedRepository", value)
+	}
+
+	switch octal {
+	case 0:
+		return sharedPerm{}, nil
+	case 1:
+		return sharedGroup, nil
+	case 2:
+		return sharedEveryone, nil
+	}
+	if octal&0600 != 0600 {
+		return sharedPerm{}, fmt.Errorf("problem with core.sharedRepository filemode value (0%03o): the owner of files must always have read and write permissions", octal)
+	}
+	return sharedPerm{mode: os.FileMode(octal) & 0666, exact: true}, nil
+}
+
+// configValue returns what init writes to core.sharedRepository, or "" when
+// the umask decides and nothing is written.
+func (p sharedPerm) configValue() string {
+	switch {
+	case p.mode == 0:
+		return ""
+	case p.exact:
+		return fmt.Sprintf("0%03o", p.mode)
+	case p == sharedGroup:
+		return "1"
+	default:
+		return "2"
+	}
+}
+
+// apply widens, or for an exact mode sets, the permissions of path like
+// git's adjust_shared_perm. Files that are read-only stay read-only,
+// directories gain the execute bit wherever they are readable, and the
+// setgid bit so that new files inherit the group.
+func (p sharedPerm) apply(fsys FileSystem, path string) error {
+	if p.mode == 0 {
+		return nil
+	}
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	mode := info.Mode().Perm()
+	tweak := p.mode
+	if mode&0200 == 0 {
+		tweak &^= 0222
+	}
+	if info.IsDir() || mode&0100 != 0 {
+		tweak |= (tweak & 0444) >> 2
+	}
+	if p.exact {
+		mode = tweak
+	} else {
+		mode |= tweak
+	}
+	if info.IsDir() {
+		return fsys.Chmod(path, mode|os.ModeSetgid)
+	}
+	return fsys.Chmod(path, mode)
+}
+
+// adjustSharedPerm applies the repository's core.sharedRepository policy to
+// files and directories it has just created. An unreadable setting is
+// treated as umask.
+func adjustSharedPerm(repo *GitRepository, paths ...string) error {
+	if !repo.Config().IsSet("core.sharedrepository") {
+		return nil
+	}
+	perm, err := parseSharedPerm(repo.Config().GetString("core.sharedrepository"))
+	if err != nil {
+		trace.Log(trace.Config, "core.sharedrepository ignored", "error", err)
+		return nil
+	}
+	for _, path := range paths {
+		if err := perm.apply(repo.fs, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTemplate copies the files of an init template, such as hooks,
+// info/exclude and description, into a new git directory. Files that
+// already exist, and the template's config, are left alone.
+func copyTemplate(dir, gitDir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if rel == ConfigFile {
+			return nil
+		}
+
+		target := filepath.Join(gitDir, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case pathExists(OSFileSystem{}, target):
+			return nil
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShowRefOptions selects the refs ShowRefs lists.
+type ShowRefOptions struct {
+	// Patterns keep the refs whose name ends with one of them, in whole
+	// path components, so "main" matches refs/heads/main and
+	// refs/remotes/origin/main but not refs/heads/domain. Patterns may
+	// hold globs.
+	Patterns    []string
+	Heads       bool // Keep the refs under refs/heads.
+	Tags        bool // Keep the refs under refs/tags, as well as branches with Heads.
+	Head        bool // List HEAD first, whatever the other filters say.
+	Dereference bool // Follow every annotated tag with what it peels to, as "<name>^{}".
+}
+
+// ShowRefs lists the local refs, as git show-ref does.
+//
+// Parameters:
+// - repo: The repository.
+// - opts: Which refs to list.
+//
+// Returns:
+// - The refs, sorted by name after HEAD.
+// - An error if the refs or the tags cannot be read.
+func ShowRefs(repo *GitRepository, opts ShowRefOptions) ([]Ref, error) {
+	objects := NewObjectManager(repo)
+	var shown []Ref
+	if opts.Head {
+		if sha, err := ResolveRef(repo, HeadFile); err == nil {
+			shown = append(shown, Ref{Name: HeadFile, SHA: sha})
+		}
+	}
+
+	refs, err := ListRefs(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if !showRefMatches(ref.Name, opts) {
+			continue
+		}
+		shown = append(shown, ref)
+		if opts.Dereference {
+			peeled, err := peeledRef(objects, ref)
+			if err != nil {
+				return nil, err
+			}
+			if peeled != nil {
+				shown = append(shown, *peeled)
+			}
+		}
+	}
+	return shown, nil
+}
+
+// showRefMatches applies the --heads, --tags and pattern filters of
+// show-ref to a ref name.
+func showRefMatches(name string, opts ShowRefOptions) bool {
+	if opts.Heads || opts.Tags {
+		isHead := opts.Heads && strings.HasPrefix(name, BranchesPrefix)
+		isTag := opts.Tags && strings.HasPrefix(name, TagsPrefix)
+		if !isHead && !isTag {
+			return false
+		}
+	}
+	if len(opts.Patterns) == 0 {
+		return true
+	}
+	for _, pattern := range opts.Patterns {
+		if refTailMatches(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// refTailMatches reports whether pattern matches the last path components
+// of a ref name, or all of them.
+func refTailMatches(name, pattern string) bool {
+	return wildmatch("*/"+pattern, "/"+name, false)
+}
+
+// peeledRef returns the "<name>^{}" entry of a ref that points to an
+// annotated tag, or nil for other refs.
+func peeledRef(objects *ObjectManager, ref Ref) (*Ref, error) {
+	peeled, objType, err := objects.PeelObject(ref.SHA)
+	if err != nil {
+		return nil, err
+	}
+	if peeled == ref.SHA && objType != TagType {
+		return nil, nil
+	}
+	return &Ref{Name: ref.Name + "^{}", SHA: peeled}, nil
+}
+
+// VerifyRef looks up a ref by its exact full name, as git show-ref
+// --verify does: "refs/heads/main" or "HEAD", but not "main".
+//
+// Parameters:
+// - repo: The repository.
+// - name: The full name of the ref.
+// - dereference: Also return what an annotated tag peels to.
+//
+// Returns:
+// - The ref, followed by its "<name>^{}" entry with dereference.
+// - An error if there is no ref of that name.
+func VerifyRef(repo *GitRepository, name string, dereference bool) ([]Ref, error) {
+	if name != HeadFile && !strings.HasPrefix(name, RefsDir+"/") {
+		return nil, fmt.Errorf("'%s' - not a valid ref", name)
+	}
+	sha, err := ResolveRef(repo, name)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' - not a valid ref", name)
+	}
+
+	refs := []Ref{{Name: name, SHA: sha}}
+	if dereference {
+		peeled, err := peeledRef(NewObjectManager(repo), refs[0])
+		if err != nil {
+			return nil, err
+		}
+		if peeled != nil {
+			refs = append(refs, *peeled)
+		}
+	}
+	return refs, nil
+}
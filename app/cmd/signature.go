@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Headers of a commit that carry its signature. The signature covers the
+// commit object without them.
+const (
+	SignatureHeader       = "gpgsig"
+	SignatureHeaderSHA256 = "gpgsig-sha256"
+)
+
+// SignatureFormat is the kind of a signature, named as gpg.format names it.
+type SignatureFormat string
+
+const (
+	FormatOpenPGP SignatureFormat = "openpgp"
+	FormatX509    SignatureFormat = "x509"
+	FormatSSH     SignatureFormat = "ssh"
+)
+
+// signatureArmors are the first lines of the signatures of each format.
+var signatureArmors = []struct {
+	prefix string
+	format SignatureFormat
+}{
+	{"-----BEGIN PGP SIGNATURE-----", FormatOpenPGP},
+	{"-----BEGIN PGP MESSAGE-----", FormatOpenPGP},
+	{"-----BEGIN SIGNED MESSAGE-----", FormatX509},
+	{"-----BEGIN SSH SIGNATURE-----", FormatSSH},
+}
+
+// signatureFormatOf tells the format of a signature from its armor.
+func signatureFormatOf(signature []byte) (SignatureFormat, bool) {
+	for _, armor := range signatureArmors {
+		if bytes.HasPrefix(signature, []byte(armor.prefix)) {
+			return armor.format, true
+		}
+	}
+	return "", false
+}
+
+// Signature returns the signature of a commit and the content it signs,
+// which is the commit object without its signature headers.
+//
+// Returns:
+// - The signed content.
+// - The signature, or nil if the commit is not signed.
+func (c *Commit) Signature() ([]byte, []byte) {
+	signature := c.Kvlm.Get(SignatureHeader)
+	if signature == nil {
+		return nil, nil
+	}
+
+	payload := c.Kvlm.Clone()
+	payload.Delete(SignatureHeader)
+	payload.Delete(SignatureHeaderSHA256)
+	// Signatures end in a newline that the header cannot keep.
+	return payload.Serialize(), append(append([]byte(nil), signature...), '\n')
+}
+
+// TagSignature splits the content of a tag object into the part that is
+// signed and the signature appended to its message.
+//
+// Returns:
+// - The signed content.
+// - The signature, or nil if the tag is not signed.
+func TagSignature(data []byte) ([]byte, []byte) {
+	// Like git, the last line that starts a signature wins.
+	match := -1
+	for start := 0; start < len(data); {
+		if _, ok := signatureFormatOf(data[start:]); ok {
+			match = start
+		}
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			break
+		}
+		start += end + 1
+	}
+	if match < 0 {
+		return data, nil
+	}
+	return data[:match], data[match:]
+}
+
+// SignatureStatus is the result of checking a signature.
+type SignatureStatus struct {
+	Good   bool   // The signature is valid and its key is known.
+	Signer string // Who made the signature, as the backend names them.
+	Key    string // The key ID or fingerprint.
+	Output string // The backend's report for humans, as --show-signature prints it.
+}
+
+// SignatureVerifier checks a detached signature over a payload, such as
+// the halves returned by Commit.Signature or TagSignature. GPGVerifier and
+// SSHVerifier run the usual programs; other backends only need Verify.
+type SignatureVerifier interface {
+	Verify(payload, signature []byte) (*SignatureStatus, error)
+}
+
+// NewSignatureVerifier returns the backend that checks signatures of a
+// format, configured like git: gpg.program or gpg.openpgp.program,
+// gpg.x509.program, and gpg.ssh.program with gpg.ssh.allowedSignersFile.
+func NewSignatureVerifier(repo *GitRepository, format SignatureFormat) SignatureVerifier {
+	switch format {
+	case FormatX509:
+		return GPGVerifier{Program: configOr(repo, configKey("gpg", "x509", "program"), "gpgsm")}
+	case FormatSSH:
+		return SSHVerifier{
+			Program:        configOr(repo, configKey("gpg", "ssh", "program"), "ssh-keygen"),
+			AllowedSigners: expandHome(lookupConfig(repo, configKey("gpg", "ssh", "allowedsignersfile"))),
+		}
+	}
+	program := lookupConfig(repo, configKey("gpg", "openpgp", "program"))
+	if program == "" {
+		program = configOr(repo, "gpg.program", "gpg")
+	}
+	return GPGVerifier{Program: program}
+}
+
+// VerifySignature checks a signature with the configured backend for its
+// format.
+//
+// Returns:
+// - The status of the signature.
+// - An error if the format is unknown or the backend cannot be run or is
+// not configured.
+func VerifySignature(repo *GitRepository, payload, signature []byte) (*SignatureStatus, error) {
+	format, ok := signatureFormatOf(signature)
+	if !ok {
+		return nil, fmt.Errorf("unknown signature format")
+	}
+	return NewSignatureVerifier(repo, format).Verify(payload, signature)
+}
+
+// configOr returns a config variable, or fallback when it is not set.
+func configOr(repo *GitRepository, key, fallback string) string {
+	if value := lookupConfig(repo, key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// expandHome expands a leading "~/" in a configured path.
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// writeSignatureFile writes a signature to a temporary file for programs
+// that read it from a path. The caller removes the file.
+func writeSignatureFile(signature []byte) (string, error) {
+	file, err := os.CreateTemp("", "justdoit-signature-")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.Write(signature); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// runVerifier runs a verification program. A non-zero exit status is how
+// these programs report bad signatures, so it is returned as ok being
+// false rather than as an error.
+func runVerifier(program string, args []string, payload []byte) (stdout, stderr []byte, ok bool, err error) {
+	var out, errOut bytes.Buffer
+	verify := exec.Command(program, args...)
+	verify.Stdin = bytes.NewReader(payload)
+	verify.Stdout, verify.Stderr = &out, &errOut
+
+	err = verify.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return out.Bytes(), errOut.Bytes(), false, nil
+	}
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("cannot run %s: %w", program, err)
+	}
+	return out.Bytes(), errOut.Bytes(), true, nil
+}
+
+// GPGVerifier checks OpenPGP signatures with gpg, or X.509 ones with gpgsm,
+// reading the result from the machine-readable status lines.
+type GPGVerifier struct {
+	Program string
+}
+
+// Verify implements SignatureVerifier.
+func (v GPGVerifier) Verify(payload, signature []byte) (*SignatureStatus, error) {
+	file, err := writeSignatureFile(signature)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file)
+
+	stdout, stderr, _, err := runVerifier(v.Program, []string{"--status-fd=1", "--keyid-format=long", "--verify", file, "-"}, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &SignatureStatus{Output: string(stderr)}
+	bad := false
+	lines := bufio.NewScanner(bytes.NewReader(stdout))
+	for lines.Scan() {
+		line, ok := strings.CutPrefix(lines.Text(), "[GNUPG:] ")
+		if !ok {
+			continue
+		}
+		keyword, rest, _ := strings.Cut(line, " ")
+		switch keyword {
+		case "GOODSIG":
+			status.Good = true
+			status.Key, status.Signer, _ = strings.Cut(rest, " ")
+		case "BADSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG":
+			bad = true
+			status.Key, status.Signer, _ = strings.Cut(rest, " ")
+		case "ERRSIG":
+			bad = true
+			status.Key, _, _ = strings.Cut(rest, " ")
+		}
+	}
+	status.Good = status.Good && !bad
+	return status, nil
+}
+
+// SSHVerifier checks SSH signatures with ssh-keygen against an allowed
+// signers file, which maps principals such as email addresses to keys.
+type SSHVerifier struct {
+	Program        string
+	AllowedSigners string
+}
+
+// Verify implements SignatureVerifier.
+func (v SSHVerifier) Verify(payload, signature []byte) (*SignatureStatus, error) {
+	if v.AllowedSigners == "" || !pathExists(OSFileSystem{}, v.AllowedSigners) {
+		return nil, fmt.Errorf("gpg.ssh.allowedSignersFile needs to be configured and exist for ssh signature verification")
+	}
+	file, err := writeSignatureFile(signature)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file)
+
+	principals, _, _, err := runVerifier(v.Program, []string{"-Y", "find-principals", "-f", v.AllowedSigners, "-s", file}, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, principal := range strings.Split(strings.TrimSpace(string(principals)), "\n") {
+		if principal == "" {
+			continue
+		}
+		stdout, stderr, ok, err := runVerifier(v.Program, []string{"-Y", "verify", "-n", "git", "-f", v.AllowedSigners, "-I", principal, "-s", file}, payload)
+		if err != nil {
+			return nil, err
+		}
+		output := string(stdout) + string(stderr)
+		if ok && strings.HasPrefix(output, `Good "git" signature for `) {
+			status := &SignatureStatus{Good: true, Signer: principal, Output: output}
+			if _, key, found := strings.Cut(strings.TrimSpace(string(stdout)), " key "); found {
+				status.Key = key
+			}
+			return status, nil
+		}
+	}
+
+	// Without a matching principal the signature can still be checked on
+	// its own, which tells a valid signature by an unknown key from a bad one.
+	stdout, stderr, _, err := runVerifier(v.Program, []string{"-Y", "check-novalidate", "-n", "git", "-s", file}, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &SignatureStatus{Output: string(stdout) + string(stderr) + "No principal matched.\n"}, nil
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// StagePath records the current worktree state of a file in the index, as
+// git add does for one path: the file is hashed, with line endings
+// converted, and written as a blob. A file that is gone from the worktree
+// is removed from the index. The index is changed in memory only; write it
+// with WriteIndex. Like git add, StagePath refuses to touch an entry marked
+// skip-worktree, whose worktree file is not to be trusted.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - index: The index to update.
+// - path: The slash separated path of the file, relative to the worktree.
+// - warnings: Receives the core.safecrlf warnings about line endings that
+// would not survive a checkout; nil skips that check.
+//
+// Returns:
+// - An error if the path is a directory, is marked skip-worktree, its line
+// endings would not round-trip with core.safecrlf=true or the file cannot
+// be hashed.
+func StagePath(repo *GitRepository, index *Index, path string, warnings io.Writer) error {
+	if entry := index.Entry(path); entry != nil && entry.SkipWorktree() {
+		return skipWorktreeError(path)
+	}
+	file := worktreePath(repo, path)
+	info, err := os.Lstat(file)
+	if os.IsNotExist(err) {
+		index.Remove(path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("'%s' is a directory", path)
+	}
+
+	// The previous entry supplies the mode where the file system cannot,
+	// as without core.filemode or core.symlinks.
+	previous := index.Entry(path)
+	if previous == nil {
+		previous = &IndexEntry{Name: path, Mode: 0100644}
+		if info.Mode()&os.ModeSymlink != 0 {
+			previous.Mode = 0120000
+		}
+	}
+	scan := worktreeScan{
+		objects:          NewObjectManager(repo),
+		fileMode:         FileModeEnabled(repo),
+		symlinks:         SymlinksEnabled(repo),
+		bigFileThreshold: BigFileThreshold(repo),
+		eol:              NewEOLConverter(repo),
+	}
+	scan.eol.Strict, scan.eol.Warnings = true, warnings
+	current, ok, err := scan.entry(file, &IndexEntry{Name: path, Mode: previous.Mode})
+	if err != nil || !ok {
+		return err
+	}
+	// The line endings were checked while hashing; do not warn twice.
+	scan.eol.Warnings = nil
+
+	// scan.entry only hashes; store the content now that it is staged.
+	if !scan.objects.Has(current.SHA) {
+		if current.Mode == ModeSymlink || info.Size() <= scan.bigFileThreshold {
+			data, err := readWorktreeFile(file, current.Mode)
+			if err != nil {
+				return err
+			}
+			if current.Mode != ModeSymlink {
+				if data, err = scan.eol.ToGit(path, data); err != nil {
+					return err
+				}
+			}
+			_, err = scan.objects.WriteObject(BlobType, data, true)
+			if err != nil {
+				return err
+			}
+		} else {
+			reader, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			_, err = scan.objects.WriteBlobStream(reader, info.Size(), true)
+			reader.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	index.Add(NewIndexEntry(path, current.Mode, current.SHA, info))
+	return nil
+}
+
+// skipWorktreeError refuses to stage a file marked skip-worktree.
+func skipWorktreeError(path string) error {
+	return fmt.Errorf("'%s' is marked skip-worktree and will not be updated in the index", path)
+}
+
+// UnstagePath resets the index entry of a file to its state in HEAD, as git
+// reset does for one path, leaving the worktree alone. A file HEAD does not
+// have is removed from the index. The index is changed in memory only.
+//
+// Parameters:
+// - repo: The repository.
+// - index: The index to update.
+// - path: The slash separated path of the file.
+//
+// Returns:
+// - An error if HEAD cannot be read.
+func UnstagePath(repo *GitRepository, index *Index, path string) error {
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return err
+	}
+	if head.Unborn() {
+		index.Remove(path)
+		return nil
+	}
+
+	objects := NewObjectManager(repo)
+	commit, err := objects.ReadCommit(head.SHA)
+	if err != nil {
+		return err
+	}
+	entry, err := objects.TreeEntryAt(commit.Tree, path)
+	if err != nil || entry.IsTree() {
+		index.Remove(path)
+		return nil
+	}
+
+	if previous := index.Entry(path); previous != nil && previous.SHA == entry.SHA && indexModeString(previous.Mode) == entry.Mode {
+		return nil
+	}
+	// Without stat data the entry never looks clean, so the worktree file is
+	// compared by content until it is staged again.
+	reset := &IndexEntry{Name: path, SHA: entry.SHA, Flags: uint16(min(len(path), IndexFlagNameMask))}
+	fmt.Sscanf(entry.Mode, "%o", &reset.Mode)
+	index.Add(reset)
+	return nil
+}
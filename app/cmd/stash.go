@@ -0,0 +1,539 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// StashRef is the ref of the newest stash entry; its reflog holds the
+// whole stack, newest last, so that stash@{n} names the n-th entry.
+const StashRef = "refs/stash"
+
+// StashOptions controls SaveStash.
+type StashOptions struct {
+	Message          string // Describes the entry instead of the HEAD commit.
+	IncludeUntracked bool   // Also save the untracked files, and remove them.
+}
+
+// Stash is a stash entry. Like git's, it is a commit of the worktree whose
+// first parent is the HEAD commit it was made on, whose second parent is
+// a commit of the index and, for entries made with untracked files, whose
+// third parent is a parentless commit of those files.
+type Stash struct {
+	Name      string // The name the entry was looked up by, such as "stash@{0}".
+	SHA       string // The worktree commit.
+	Message   string
+	Base      string // The HEAD commit the entry was made on.
+	BaseTree  string
+	Index     string // The tree of the index.
+	Worktree  string // The tree of the tracked files in the worktree.
+	Untracked string // The tree of the untracked files, or "".
+}
+
+// SaveStash records the local changes as a new stash entry on top of
+// refs/stash and then resets the index and the worktree to HEAD, as git
+// stash push does. With IncludeUntracked, the untracked files that are not
+// ignored are saved too and then removed.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - opts: The message and whether to save untracked files.
+//
+// Returns:
+// - The new entry, or nil when there were no local changes to save.
+// - An error if HEAD is unborn, the index has unmerged entries or the
+// worktree cannot be read or reset.
+func SaveStash(repo *GitRepository, opts StashOptions) (*Stash, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	defer trace.Start(trace.Perf, "stash save", "untracked", opts.IncludeUntracked)()
+
+	objects := NewObjectManager(repo)
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return nil, err
+	}
+	if head.SHA == "" {
+		return nil, fmt.Errorf("you do not have the initial commit yet")
+	}
+	headCommit, err := objects.ReadCommit(head.SHA)
+	if err != nil {
+		return nil, err
+	}
+	index, err := ReadIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 {
+			return nil, fmt.Errorf("cannot save the current index state: '%s' needs merge", entry.Name)
+		}
+	}
+
+	stash := &Stash{Base: head.SHA, BaseTree: headCommit.Tree}
+	if stash.Index, err = objects.WriteTreeFromFiles(IndexFiles(index)); err != nil {
+		return nil, err
+	}
+	if stash.Worktree, err = worktreeTree(repo, objects, index); err != nil {
+		return nil, err
+	}
+	var untracked []string
+	if opts.IncludeUntracked {
+		if untracked, err = listUntracked(repo, index, UntrackedAll); err != nil {
+			return nil, err
+		}
+		if len(untracked) > 0 {
+			if stash.Untracked, err = filesTree(repo, objects, untracked); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if stash.Index == stash.BaseTree && stash.Worktree == stash.BaseTree && stash.Untracked == "" {
+		return nil, nil
+	}
+
+	branch := head.Branch()
+	if branch == "" {
+		branch = "(no branch)"
+	}
+	description := fmt.Sprintf("%s: %s %s", branch, objects.ShortSHA(head.SHA, 0), commitSubjectLine(headCommit))
+	stash.Message = "WIP on " + description
+	if opts.Message != "" {
+		stash.Message = fmt.Sprintf("On %s: %s", branch, opts.Message)
+	}
+
+	indexCommit, err := writeStashCommit(repo, objects, stash.Index, []string{head.SHA}, "index on "+description+"\n")
+	if err != nil {
+		return nil, err
+	}
+	parents := []string{head.SHA, indexCommit}
+	if stash.Untracked != "" {
+		untrackedCommit, err := writeStashCommit(repo, objects, stash.Untracked, nil, "untracked files on "+description+"\n")
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, untrackedCommit)
+	}
+	// Like git, the message of the entry itself has no final newline.
+	if stash.SHA, err = writeStashCommit(repo, objects, stash.Worktree, parents, stash.Message); err != nil {
+		return nil, err
+	}
+
+	previous, _ := ResolveRef(repo, StashRef)
+	if err := UpdateRef(repo, StashRef, stash.SHA, ""); err != nil {
+		return nil, err
+	}
+	if err := appendReflog(repo, StashRef, previous, stash.SHA, stash.Message); err != nil {
+		return nil, err
+	}
+	stash.Name = "stash@{0}"
+
+	target := head.Branch()
+	if target == "" {
+		target = head.SHA
+	}
+	if _, err := Checkout(repo, target, CheckoutOptions{Force: true, KeepHead: true}); err != nil {
+		return nil, err
+	}
+	// HEAD stays, but like git reset --hard, the reset is logged.
+	ref := head.Ref
+	if ref == "" {
+		ref = HeadFile
+	}
+	if err := UpdateRef(repo, ref, head.SHA, "reset: moving to HEAD"); err != nil {
+		return nil, err
+	}
+	for _, name := range untracked {
+		if err := removeWorktreeFile(repo, name); err != nil {
+			return nil, err
+		}
+	}
+	trace.Log(trace.Ref, "stash", "sha", stash.SHA)
+	return stash, nil
+}
+
+// worktreeTree writes the tree of the tracked files as they are in the
+// worktree, storing the blobs of changed files.
+func worktreeTree(repo *GitRepository, objects *ObjectManager, index *Index) (string, error) {
+	worktree, err := WorktreeFiles(repo, index, NewEOLConverter(repo))
+	if err != nil {
+		return "", err
+	}
+	snapshot, err := ReadIndex(repo)
+	if err != nil {
+		return "", err
+	}
+	for name, staged := range IndexFiles(index) {
+		if current, ok := worktree[name]; !ok || !sameTreeEntry(current, staged) {
+			if err := StagePath(repo, snapshot, name, nil); err != nil {
+				return "", err
+			}
+		}
+	}
+	return objects.WriteTreeFromFiles(IndexFiles(snapshot))
+}
+
+// filesTree writes a tree of worktree files, storing their blobs.
+// Directories, which stand for nested repositories, are left out.
+func filesTree(repo *GitRepository, objects *ObjectManager, names []string) (string, error) {
+	index := &Index{Version: 2}
+	for _, name := range names {
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		if err := StagePath(repo, index, name, nil); err != nil {
+			return "", err
+		}
+	}
+	return objects.WriteTreeFromFiles(IndexFiles(index))
+}
+
+// writeStashCommit writes one of the commits of a stash entry.
+func writeStashCommit(repo *GitRepository, objects *ObjectManager, tree string, parents []string, message string) (string, error) {
+	author, err := commitIdent(repo, AuthorRole)
+	if err != nil {
+		return "", err
+	}
+	committer, err := commitIdent(repo, CommitterRole)
+	if err != nil {
+		return "", err
+	}
+	kvlm := &Kvlm{Message: []byte(message)}
+	kvlm.Add("tree", []byte(tree))
+	for _, parent := range parents {
+		kvlm.Add("parent", []byte(parent))
+	}
+	kvlm.Add("author", []byte(author))
+	kvlm.Add("committer", []byte(committer))
+	return objects.WriteObject(CommitType, kvlm.Serialize(), true)
+}
+
+// ListStashes lists the stash entries, newest first.
+//
+// Returns:
+// - The entries, named stash@{0}, stash@{1} and so on.
+// - An error if the reflog of refs/stash cannot be read.
+func ListStashes(repo *GitRepository) ([]Stash, error) {
+	entries, err := ReadReflog(repo, StashRef)
+	if err != nil {
+		return nil, err
+	}
+	stashes := make([]Stash, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		stashes = append(stashes, Stash{
+			Name:    fmt.Sprintf("stash@{%d}", len(stashes)),
+			SHA:     entries[i].New,
+			Message: entries[i].Message,
+		})
+	}
+	return stashes, nil
+}
+
+// stashName expands the ways of naming a stash entry: nothing for the
+// newest one, a bare number n for refs/stash@{n}, or any revision.
+func stashName(name string) string {
+	if name == "" {
+		name = "0"
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return StashRef + "@{" + name + "}"
+	}
+	return name
+}
+
+// ReadStash looks up a stash entry and the trees it is made of.
+//
+// Parameters:
+// - repo: The repository.
+// - name: "stash@{n}", a bare number n, any revision naming a stash
+// commit, or "" for the newest entry.
+//
+// Returns:
+// - The entry.
+// - An error if there is no stash, the name does not resolve or it does
+// not name a stash-like commit.
+func ReadStash(repo *GitRepository, name string) (*Stash, error) {
+	if !refExists(repo, StashRef) {
+		return nil, fmt.Errorf("no stash entries found")
+	}
+	name = stashName(name)
+	sha, err := ResolveRevision(repo, name)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid reference", name)
+	}
+
+	objects := NewObjectManager(repo)
+	commit, err := objects.ReadCommit(sha)
+	if err != nil || len(commit.Parents) < 2 || len(commit.Parents) > 3 {
+		return nil, fmt.Errorf("'%s' is not a stash-like commit", name)
+	}
+	stash := &Stash{Name: name, SHA: sha, Message: commitSubjectLine(commit), Base: commit.Parents[0], Worktree: commit.Tree}
+
+	trees := make([]string, len(commit.Parents))
+	for i, parent := range commit.Parents {
+		parentCommit, err := objects.ReadCommit(parent)
+		if err != nil {
+			return nil, err
+		}
+		trees[i] = parentCommit.Tree
+	}
+	stash.BaseTree, stash.Index = trees[0], trees[1]
+	if len(trees) == 3 {
+		stash.Untracked = trees[2]
+	}
+	return stash, nil
+}
+
+// Changes lists what a stash entry changed relative to the commit it was
+// made on, as git stash show does: the tracked files of the worktree and,
+// with untracked, the untracked files it saved as added files.
+//
+// Returns:
+// - The changes, sorted by path.
+// - An error if a tree cannot be read.
+func (s *Stash) Changes(objects *ObjectManager, untracked bool) ([]TreeChange, error) {
+	baseFiles, err := objects.FlattenTree(s.BaseTree)
+	if err != nil {
+		return nil, err
+	}
+	files, err := objects.FlattenTree(s.Worktree)
+	if err != nil {
+		return nil, err
+	}
+	if untracked && s.Untracked != "" {
+		untrackedFiles, err := objects.FlattenTree(s.Untracked)
+		if err != nil {
+			return nil, err
+		}
+		for name, entry := range untrackedFiles {
+			files[name] = entry
+		}
+	}
+	return DiffFileSets(baseFiles, files), nil
+}
+
+// ApplyStashOptions controls ApplyStash.
+type ApplyStashOptions struct {
+	// Index restores the staged changes of the entry in the index too;
+	// otherwise only the files the entry added are staged.
+	Index bool
+}
+
+// ApplyStash applies the changes of a stash entry to the worktree, as git
+// stash apply does: the changes the entry made to the commit it was made
+// on are merged into the current index, and its untracked files are
+// restored. Nothing is changed if the merge has conflicts, if a file the
+// stash changes has local changes or if an untracked file of the stash is
+// in the way.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - name: The entry, as for ReadStash.
+// - opts: Whether to restore the index.
+//
+// Returns:
+// - The entry applied.
+// - An error if the entry cannot be applied.
+func ApplyStash(repo *GitRepository, name string, opts ApplyStashOptions) (*Stash, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	stash, err := ReadStash(repo, name)
+	if err != nil {
+		return nil, err
+	}
+	defer trace.Start(trace.Perf, "stash apply", "stash", stash.SHA)()
+
+	objects := NewObjectManager(repo)
+	lock, index, err := LockIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 {
+			return nil, fmt.Errorf("cannot apply a stash in the middle of a merge")
+		}
+	}
+	staged := IndexFiles(index)
+	ours, err := objects.WriteTreeFromFiles(staged)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := MergeLabels{Ours: "Updated upstream", Theirs: "Stashed changes"}
+	merged, err := objects.MergeTrees(stash.BaseTree, ours, stash.Worktree, labels)
+	if err != nil {
+		return nil, err
+	}
+	if err := stashConflicts(stash, merged); err != nil {
+		return nil, err
+	}
+
+	// Without Index, the index keeps what it had, plus the new files.
+	indexFiles := make(map[string]TreeEntry)
+	for name, entry := range staged {
+		indexFiles[name] = entry
+	}
+	for name, entry := range merged.Files {
+		if _, ok := staged[name]; !ok {
+			indexFiles[name] = entry
+		}
+	}
+	if opts.Index && stash.Index != stash.BaseTree {
+		restored, err := objects.MergeTrees(stash.BaseTree, ours, stash.Index, labels)
+		if err != nil {
+			return nil, err
+		}
+		if len(restored.Conflicts) > 0 {
+			return nil, fmt.Errorf("conflicts in index; try without --index")
+		}
+		indexFiles = restored.Files
+	}
+
+	var untracked map[string]TreeEntry
+	if stash.Untracked != "" {
+		if untracked, err = objects.FlattenTree(stash.Untracked); err != nil {
+			return nil, err
+		}
+	}
+
+	// Find the files to write, and refuse if any has local changes.
+	eol := NewEOLConverter(repo)
+	worktree, err := WorktreeFiles(repo, index, eol)
+	if err != nil {
+		return nil, err
+	}
+	var updates, blocked []string
+	for _, changes := range []map[string]TreeEntry{merged.Files, staged} {
+		for name := range changes {
+			entry, inMerged := merged.Files[name]
+			current, inStaged := staged[name]
+			if inMerged == inStaged && (!inMerged || sameTreeEntry(entry, current)) {
+				continue
+			}
+			if slices.Contains(updates, name) {
+				continue
+			}
+			updates = append(updates, name)
+			file, inWorktree := worktree[name]
+			switch {
+			case inStaged && (!inWorktree || !sameTreeEntry(file, current)):
+				blocked = append(blocked, name)
+			case !inStaged && pathExists(OSFileSystem{}, worktreePath(repo, name)):
+				blocked = append(blocked, name)
+			}
+		}
+	}
+	if len(blocked) > 0 {
+		sort.Strings(blocked)
+		return nil, fmt.Errorf("your local changes to the following files would be overwritten by merge:\n\t%s",
+			strings.Join(blocked, "\n\t"))
+	}
+	for name := range untracked {
+		if pathExists(OSFileSystem{}, worktreePath(repo, name)) {
+			return nil, fmt.Errorf("%s already exists, no checkout", name)
+		}
+	}
+
+	written := make(map[string]os.FileInfo)
+	sort.Strings(updates)
+	for _, name := range updates {
+		entry, ok := merged.Files[name]
+		if !ok {
+			if err := removeWorktreeFile(repo, name); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info, err := checkoutFile(repo, objects, eol, name, entry)
+		if err != nil {
+			return nil, err
+		}
+		written[name] = info
+	}
+	for name, entry := range untracked {
+		if _, err := checkoutFile(repo, objects, eol, name, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	for name := range staged {
+		if _, ok := indexFiles[name]; !ok {
+			index.Remove(name)
+		}
+	}
+	for name, entry := range indexFiles {
+		info, isWritten := written[name]
+		current, inStaged := staged[name]
+		switch {
+		case isWritten && sameTreeEntry(entry, merged.Files[name]):
+			index.Add(NewIndexEntry(name, entry.Mode, entry.SHA, info))
+		case !inStaged || !sameTreeEntry(entry, current):
+			placeholder := &IndexEntry{Name: name, SHA: entry.SHA, Flags: uint16(min(len(name), IndexFlagNameMask))}
+			fmt.Sscanf(entry.Mode, "%o", &placeholder.Mode)
+			index.Add(placeholder)
+		}
+	}
+	return stash, lock.Write(index)
+}
+
+// stashConflicts turns the conflicts of applying a stash entry into an
+// error.
+func stashConflicts(stash *Stash, merged *TreeMergeResult) error {
+	if len(merged.Conflicts) == 0 {
+		return nil
+	}
+	paths := make([]string, len(merged.Conflicts))
+	for i, conflict := range merged.Conflicts {
+		paths[i] = conflict.Path
+	}
+	return fmt.Errorf("could not apply %s: conflict in %s", stash.Name, strings.Join(paths, ", "))
+}
+
+// DropStash removes an entry from the stash.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The entry, as stash@{n} or refs/stash@{n}, a bare number n or
+// "" for the newest.
+//
+// Returns:
+// - The entry dropped.
+// - An error if name does not name an entry of the stash.
+func DropStash(repo *GitRepository, name string) (*Stash, error) {
+	name = stashName(name)
+	spec, ok := strings.CutPrefix(strings.TrimPrefix(name, "refs/"), "stash@{")
+	n, err := strconv.Atoi(strings.TrimSuffix(spec, "}"))
+	if !ok || !strings.HasSuffix(spec, "}") || err != nil || n < 0 {
+		return nil, fmt.Errorf("'%s' is not a stash reference", name)
+	}
+	entries, err := ReadReflog(repo, StashRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no stash entries found")
+	}
+	if n >= len(entries) {
+		return nil, fmt.Errorf("%s is not a valid reference", name)
+	}
+
+	i := len(entries) - 1 - n
+	dropped := &Stash{Name: name, SHA: entries[i].New, Message: entries[i].Message}
+	entries = slices.Delete(entries, i, i+1)
+	if len(entries) == 0 {
+		return dropped, DeleteRef(repo, StashRef)
+	}
+	if err := writeReflog(repo, StashRef, entries); err != nil {
+		return nil, err
+	}
+	return dropped, UpdateRef(repo, StashRef, entries[len(entries)-1].New, "")
+}
@@ -0,0 +1,14 @@
+//go:build unix && !(darwin || freebsd || netbsd)
+
+package cmd
+
+import (
+	"syscall"
+	"time"
+)
+
+// statCtime returns the inode change time of a file, which Linux and the
+// other unix systems keep in Ctim.
+func statCtime(stat *syscall.Stat_t) time.Time {
+	return time.Unix(int64(stat.Ctim.Sec), int64(stat.Ctim.Nsec))
+}
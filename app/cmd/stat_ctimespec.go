@@ -0,0 +1,14 @@
+//go:build darwin || freebsd || netbsd
+
+package cmd
+
+import (
+	"syscall"
+	"time"
+)
+
+// statCtime returns the inode change time of a file, which these systems
+// keep in Ctimespec.
+func statCtime(stat *syscall.Stat_t) time.Time {
+	return time.Unix(int64(stat.Ctimespec.Sec), int64(stat.Ctimespec.Nsec))
+}
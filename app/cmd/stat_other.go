@@ -0,0 +1,16 @@
+//go:build !unix
+
+package cmd
+
+import "os"
+
+// fillStatData leaves the ctime, device, inode and owner of an index entry
+// alone where they are not portably available; git only compares them
+// when they are recorded.
+func fillStatData(entry *IndexEntry, info os.FileInfo) {}
+
+// diskUsage returns the space a file takes on disk. Where the allocated
+// blocks are not portably available, that is its size.
+func diskUsage(info os.FileInfo) int64 {
+	return info.Size()
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// fillStatData copies the stat fields git records besides size and mtime
+// into an index entry.
+func fillStatData(entry *IndexEntry, info os.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	entry.CTime = statCtime(stat)
+	entry.Dev = uint32(stat.Dev)
+	entry.Ino = uint32(stat.Ino)
+	entry.UID = stat.Uid
+	entry.GID = stat.Gid
+}
+
+// diskUsage returns the space a file takes on disk, as git count-objects
+// reports it: its allocated blocks rather than its size.
+func diskUsage(info os.FileInfo) int64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(stat.Blocks) * 512
+	}
+	return info.Size()
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// ChangeKind describes how a path differs between two states.
+type ChangeKind string
+
+const (
+	ChangeAdded      ChangeKind = "new file"
+	ChangeModified   ChangeKind = "modified"
+	ChangeDeleted    ChangeKind = "deleted"
+	ChangeTypeChange ChangeKind = "typechange"
+)
+
+// UntrackedMode says which untracked files Status lists, as the -u option
+// and status.showUntrackedFiles of git do.
+type UntrackedMode string
+
+const (
+	UntrackedNo     UntrackedMode = "no"     // Do not look for untracked files.
+	UntrackedNormal UntrackedMode = "normal" // Show a directory without tracked files as "dir/".
+	UntrackedAll    UntrackedMode = "all"    // Show every untracked file.
+)
+
+// ParseUntrackedMode parses the value of -u or status.showUntrackedFiles.
+func ParseUntrackedMode(value string) (UntrackedMode, error) {
+	switch mode := UntrackedMode(value); mode {
+	case UntrackedNo, UntrackedNormal, UntrackedAll:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid untracked files mode '%s'", value)
+}
+
+// StatusOptions changes what Status looks at.
+type StatusOptions struct {
+	// Untracked selects the untracked files to list; empty means
+	// status.showUntrackedFiles, which defaults to UntrackedNormal.
+	Untracked UntrackedMode
+}
+
+// FileChange is a single changed path.
+type FileChange struct {
+	Path string
+	Kind ChangeKind
+}
+
+// StatusReport is the state of the worktree, index and current branch.
+type StatusReport struct {
+	Branch       string    // The current branch, empty when HEAD is detached.
+	Head         string    // The commit HEAD points at, empty on an unborn branch.
+	Upstream     *Upstream // The upstream of the branch, if any.
+	UpstreamGone bool      // The upstream is configured but its ref is missing.
+	Ahead        int       // Commits on the branch that are not on the upstream.
+	Behind       int       // Commits on the upstream that are not on the branch.
+	Staged       []FileChange
+	Unstaged     []FileChange
+	Unmerged     []string
+	Conflicts    map[string]string // The short status of each unmerged path, such as "UU" or "AA".
+	Merging      bool              // A merge is in progress, waiting to be committed.
+	Untracked    []string          // Untracked files; untracked directories end in "/".
+	NoUntracked  bool              // Untracked files were not looked for.
+	StaleLocks   []LockFile        // Locks that look left behind by a crashed process.
+}
+
+// Status compares HEAD, the index and the worktree, listing untracked
+// files as status.showUntrackedFiles says.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+//
+// Returns:
+// - The staged, unstaged, unmerged and untracked paths, the tracking
+// state of the current branch and any stale locks.
+// - An error if the index or an object cannot be read.
+func Status(repo *GitRepository) (*StatusReport, error) {
+	return StatusWithOptions(repo, StatusOptions{})
+}
+
+// StatusWithOptions is Status with the untracked files chosen by opts.
+//
+// Returns:
+// - The status report.
+// - An error if the index or an object cannot be read, or if
+// status.showUntrackedFiles is invalid.
+func StatusWithOptions(repo *GitRepository, opts StatusOptions) (*StatusReport, error) {
+	if opts.Untracked == "" {
+		opts.Untracked = UntrackedNormal
+		if value := lookupConfig(repo, "status.showuntrackedfiles"); value != "" {
+			mode, err := ParseUntrackedMode(value)
+			if err != nil {
+				return nil, err
+			}
+			opts.Untracked = mode
+		}
+	}
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	defer trace.Start(trace.Perf, "status")()
+
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return nil, err
+	}
+	report := &StatusReport{Head: head.SHA, Merging: MergeInProgress(repo)}
+	if branch := head.Branch(); branch != "" {
+		report.Branch = branch
+		upstream, ahead, behind, gone, err := TrackingStatus(repo, branch)
+		if err != nil {
+			return nil, err
+		}
+		report.Upstream, report.Ahead, report.Behind, report.UpstreamGone = upstream, ahead, behind, gone
+	}
+
+	index, err := ReadIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := NewObjectManager(repo)
+	headFiles := make(map[string]TreeEntry)
+	if report.Head != "" {
+		commit, err := objects.ReadCommit(report.Head)
+		if err != nil {
+			return nil, err
+		}
+		if headFiles, err = objects.FlattenTree(commit.Tree); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Conflicts = make(map[string]string)
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 && !slices.Contains(report.Unmerged, entry.Name) {
+			report.Unmerged = append(report.Unmerged, entry.Name)
+			report.Conflicts[entry.Name] = conflictStatus(index.Stages(entry.Name))
+			delete(headFiles, entry.Name)
+		}
+	}
+	sort.Strings(report.Unmerged)
+
+	staged := IndexFiles(index)
+	worktree, err := WorktreeFiles(repo, index, NewEOLConverter(repo))
+	if err != nil {
+		return nil, err
+	}
+	report.Staged = fileChanges(DiffFileSets(headFiles, staged))
+	report.Unstaged = fileChanges(DiffFileSets(staged, worktree))
+
+	if report.Untracked, err = listUntracked(repo, index, opts.Untracked); err != nil {
+		return nil, err
+	}
+	report.NoUntracked = opts.Untracked == UntrackedNo
+
+	cutoff, _ := ParseExpiry(DefaultStaleLockExpiry, time.Now())
+	if report.StaleLocks, err = StaleLocks(repo, cutoff); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Clean reports whether nothing is staged, modified, unmerged or untracked.
+func (r *StatusReport) Clean() bool {
+	return len(r.Staged) == 0 && len(r.Unstaged) == 0 && len(r.Unmerged) == 0 && len(r.Untracked) == 0
+}
+
+// conflictStatus returns the two letters git status --short shows for an
+// unmerged path, from the stages it has: "UU" when both sides changed it,
+// "AA" when both added it, "DU" when we deleted it and so on.
+func conflictStatus(stages [4]*IndexEntry) string {
+	mask := 0
+	for stage := 1; stage <= 3; stage++ {
+		if stages[stage] != nil {
+			mask |= 1 << (stage - 1)
+		}
+	}
+	return [...]string{"", "DD", "AU", "UD", "UA", "DU", "AA", "UU"}[mask]
+}
+
+// listUntracked lists the untracked files of the worktree in the given
+// mode, sorted by path.
+func listUntracked(repo *GitRepository, index *Index, mode UntrackedMode) ([]string, error) {
+	if mode == UntrackedNo {
+		return nil, nil
+	}
+
+	tracked := make(map[string]bool)
+	for _, entry := range index.Entries {
+		for dir := path.Dir(entry.Name); dir != "."; dir = path.Dir(dir) {
+			tracked[dir+"/"] = true
+		}
+		tracked[entry.Name] = true
+	}
+	ignore := NewIgnoreMatcher(repo)
+	untracked, err := untrackedFiles(repo, ignore, tracked, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == UntrackedAll {
+		var files []string
+		for _, name := range untracked {
+			// Nested repositories stay whole, as git shows them.
+			if !strings.HasSuffix(name, "/") || pathExists(OSFileSystem{}, worktreePath(repo, name+GitExtension)) {
+				files = append(files, name)
+				continue
+			}
+			inner, err := filesBelow(repo, ignore, name)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, inner...)
+		}
+		untracked = files
+	}
+	sort.Strings(untracked)
+	return untracked, nil
+}
+
+// fileChanges converts tree changes to the kinds status reports.
+func fileChanges(changes []TreeChange) []FileChange {
+	kinds := map[ChangeStatus]ChangeKind{
+		StatusAdded:      ChangeAdded,
+		StatusDeleted:    ChangeDeleted,
+		StatusModified:   ChangeModified,
+		StatusTypeChange: ChangeTypeChange,
+	}
+	result := make([]FileChange, len(changes))
+	for i, change := range changes {
+		result[i] = FileChange{Path: change.Path, Kind: kinds[change.Status]}
+	}
+	return result
+}
+
+// indexModeString formats an index entry mode the way trees store it.
+func indexModeString(mode uint32) string {
+	return fmt.Sprintf("%o", mode)
+}
+
+// modeKind groups file modes into regular files, symlinks and submodules,
+// ignoring the executable bit.
+func modeKind(mode string) string {
+	switch mode {
+	case ModeBlob, ModeExecutable:
+		return "file"
+	}
+	return mode
+}
+
+// untrackedFiles lists the untracked, non-ignored paths below dir. A
+// directory that contains no tracked files is reported as a whole, as
+// "dir/", as long as something in it is not ignored.
+func untrackedFiles(repo *GitRepository, ignore *IgnoreMatcher, tracked map[string]bool, dir string) ([]string, error) {
+	entries, err := os.ReadDir(worktreePath(repo, dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var untracked []string
+	for _, entry := range entries {
+		name := dir + entry.Name()
+		if dir == "" && entry.Name() == GitExtension {
+			continue
+		}
+
+		if !entry.IsDir() {
+			if !tracked[name] && !ignore.Ignored(name, false) {
+				untracked = append(untracked, name)
+			}
+			continue
+		}
+
+		if tracked[name] || ignore.Ignored(name, true) {
+			continue
+		}
+		inner, err := untrackedFiles(repo, ignore, tracked, name+"/")
+		if err != nil {
+			return nil, err
+		}
+		if tracked[name+"/"] {
+			untracked = append(untracked, inner...)
+		} else if len(inner) > 0 {
+			untracked = append(untracked, name+"/")
+		}
+	}
+	return untracked, nil
+}
+
+// UntrackedFilesIn lists every non-ignored file below an untracked
+// directory, such as a "dir/" entry of StatusReport.Untracked, so that it
+// can be staged file by file. Nested repositories are skipped.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - dir: The directory, relative to the worktree and ending in "/".
+//
+// Returns:
+// - The slash separated paths of the files, sorted.
+// - An error if the directory cannot be read.
+func UntrackedFilesIn(repo *GitRepository, dir string) ([]string, error) {
+	return filesBelow(repo, NewIgnoreMatcher(repo), dir)
+}
+
+// filesBelow lists the non-ignored files below dir for UntrackedFilesIn.
+func filesBelow(repo *GitRepository, ignore *IgnoreMatcher, dir string) ([]string, error) {
+	entries, err := os.ReadDir(worktreePath(repo, dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := dir + entry.Name()
+		if dir == "" && entry.Name() == GitExtension {
+			continue
+		}
+		if !entry.IsDir() {
+			if !ignore.Ignored(name, false) {
+				files = append(files, name)
+			}
+			continue
+		}
+		if ignore.Ignored(name, true) || pathExists(OSFileSystem{}, worktreePath(repo, name+"/"+GitExtension)) {
+			continue
+		}
+		inner, err := filesBelow(repo, ignore, name+"/")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, inner...)
+	}
+	return files, nil
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TagsPrefix is the namespace of tags.
+const TagsPrefix = "refs/tags/"
+
+// CreateTagOptions controls CreateTag.
+type CreateTagOptions struct {
+	// Annotate makes an annotated tag: a tag object with the tagger and
+	// Message, which the ref then points to. Otherwise the ref points to
+	// the target itself.
+	Annotate bool
+	Message  []byte
+	Force    bool // Replace a tag of the same name.
+}
+
+// CreateTag creates a tag, as git tag does.
+//
+// Parameters:
+// - repo: The repository.
+// - name: The short name of the tag, e.g. "v1.0".
+// - target: The revision to tag.
+// - opts: Whether to make a tag object, and its message.
+//
+// Returns:
+// - The object refs/tags/<name> now points to.
+// - An error if the name is invalid, the tag exists, the target cannot be
+// found or the tag object cannot be written.
+func CreateTag(repo *GitRepository, name, target string, opts CreateTagOptions) (string, error) {
+	ref := TagsPrefix + name
+	if name == HeadFile || strings.HasPrefix(name, "-") || !validRefName(ref) {
+		return "", fmt.Errorf("'%s' is not a valid tag name", name)
+	}
+	if refExists(repo, ref) && !opts.Force {
+		return "", fmt.Errorf("tag '%s' already exists", name)
+	}
+
+	sha, err := ResolveRevision(repo, target)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve '%s' as a valid ref", target)
+	}
+	objects := NewObjectManager(repo)
+	// A revision may name an object that is not there, e.g. a full SHA.
+	objType, _, err := objects.StatObject(sha)
+	if err != nil {
+		return "", fmt.Errorf("cannot tag missing object %s", sha)
+	}
+
+	if opts.Annotate {
+		tagger, err := Ident(repo, CommitterRole)
+		if err != nil {
+			return "", err
+		}
+		kvlm := &Kvlm{Message: opts.Message}
+		kvlm.Add("object", []byte(sha))
+		kvlm.Add("type", []byte(objType))
+		kvlm.Add("tag", []byte(name))
+		kvlm.Add("tagger", []byte(tagger.String()))
+		if sha, err = objects.WriteObject(TagType, kvlm.Serialize(), true); err != nil {
+			return "", err
+		}
+	}
+
+	if err := UpdateRef(repo, ref, sha, ""); err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+// DeleteTag deletes a tag.
+//
+// Returns:
+// - What the tag pointed to.
+// - An error if there is no such tag.
+func DeleteTag(repo *GitRepository, name string) (string, error) {
+	sha, err := ResolveRef(repo, TagsPrefix+name)
+	if err != nil {
+		return "", fmt.Errorf("tag '%s' not found", name)
+	}
+	return sha, DeleteRef(repo, TagsPrefix+name)
+}
+
+// TagVerification is the result of VerifyTag.
+type TagVerification struct {
+	SHA     string           // The tag object.
+	Payload []byte           // The tag object without its signature.
+	Status  *SignatureStatus // Nil when the tag is not signed.
+}
+
+// VerifyTag checks the signature of an annotated tag, as git verify-tag
+// does. The object the tag points to must exist.
+//
+// Parameters:
+// - repo: The repository.
+// - name: A tag name or any revision naming a tag object.
+//
+// Returns:
+// - The tag, what it signs and the status of its signature.
+// - An error if name is not a tag object, its target is missing or the
+// signature cannot be checked.
+func VerifyTag(repo *GitRepository, name string) (*TagVerification, error) {
+	sha, err := ResolveRef(repo, TagsPrefix+name)
+	if err != nil {
+		if sha, err = ResolveRevision(repo, name); err != nil {
+			return nil, fmt.Errorf("tag '%s' not found", name)
+		}
+	}
+	objects := NewObjectManager(repo)
+	objType, data, err := objects.ReadObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != TagType {
+		return nil, fmt.Errorf("%s: cannot verify a non-tag object of type %s", name, objType)
+	}
+	tag, err := ParseKvlm(data)
+	if err != nil {
+		return nil, err
+	}
+	if target := string(tag.Get("object")); !objects.Has(target) {
+		return nil, fmt.Errorf("tag '%s' points to missing object %s", name, target)
+	}
+
+	payload, signature := TagSignature(data)
+	result := &TagVerification{SHA: sha, Payload: payload}
+	if signature == nil {
+		return result, nil
+	}
+	if result.Status, err = VerifySignature(repo, payload, signature); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TagListOptions selects and orders the tags ListTags returns.
+type TagListOptions struct {
+	// Patterns are wildcards for the short names of the tags, in which
+	// "*" also matches "/"; without them every tag is listed.
+	Patterns []string
+	// Sort holds the keys of SortRefs; tag.sort is used without them.
+	Sort []string
+	// Contains keeps the tags of commits that contain one of these
+	// revisions.
+	Contains []string
+	// PointsAt keeps the tags that point at one of these revisions,
+	// directly or through tag objects.
+	PointsAt []string
+}
+
+// ListTags lists tags, as git tag --list does.
+//
+// Parameters:
+// - repo: The repository.
+// - opts: Which tags to list, and in what order.
+//
+// Returns:
+// - The tags.
+// - An error if a revision cannot be resolved, a sort key is not
+// supported or an object cannot be read.
+func ListTags(repo *GitRepository, opts TagListOptions) ([]Ref, error) {
+	objects := NewObjectManager(repo)
+	filter, err := NewCommitFilter(repo, opts.Contains, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	pointsAt := make(map[string]bool)
+	for _, rev := range opts.PointsAt {
+		sha, err := ResolveRevision(repo, rev)
+		if err != nil {
+			return nil, fmt.Errorf("malformed object name '%s'", rev)
+		}
+		pointsAt[sha] = true
+	}
+
+	refs, err := Refs(repo, TagsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var matched []Ref
+	for _, ref := range refs {
+		ok, err := tagMatches(objects, ref, opts.Patterns, filter, pointsAt)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, ref)
+		}
+	}
+
+	keys := opts.Sort
+	if len(keys) == 0 {
+		keys = strings.Fields(lookupConfig(repo, "tag.sort"))
+	}
+	if err := SortRefs(repo, matched, keys); err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+// tagMatches checks a tag against the filters of ListTags.
+func tagMatches(objects *ObjectManager, ref Ref, patterns []string, filter *CommitFilter, pointsAt map[string]bool) (bool, error) {
+	name := strings.TrimPrefix(ref.Name, TagsPrefix)
+	if len(patterns) > 0 && !slices.ContainsFunc(patterns, func(pattern string) bool { return wildmatch(pattern, name, false) }) {
+		return false, nil
+	}
+
+	if len(pointsAt) > 0 {
+		found := false
+		for sha := ref.SHA; ; {
+			if found = pointsAt[sha]; found {
+				break
+			}
+			objType, data, err := objects.ReadObject(sha)
+			if err != nil {
+				return false, err
+			}
+			if objType != TagType {
+				break
+			}
+			tag, err := ParseKvlm(data)
+			if err != nil {
+				return false, err
+			}
+			sha = string(tag.Get("object"))
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	if filter.Empty() {
+		return true, nil
+	}
+	commit, objType, err := objects.PeelObject(ref.SHA)
+	if err != nil || objType != CommitType {
+		return false, err
+	}
+	return filter.Match(commit)
+}
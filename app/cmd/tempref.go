@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// TempRefsDir holds the refs of operations in progress. Being refs, they
+// keep what they point at reachable for prune and gc.
+const TempRefsDir = "refs/tmp"
+
+// TempRef keeps the commits an operation such as rebase has made so far
+// reachable until it is done, so that a prune or gc running meanwhile,
+// e.g. while an editor is open, cannot delete them. Only the newest
+// commit of a chain needs keeping, as it reaches the ones before.
+//
+// The ref is named after the operation and the process running it, so
+// that gc can remove the refs of processes that died before releasing
+// them.
+type TempRef struct {
+	repo *GitRepository
+	Name string // e.g. refs/tmp/rebase-1234.
+	kept bool
+}
+
+// NewTempRef returns the temporary ref of an operation of this process.
+// Nothing is written until Keep is called.
+//
+// Parameters:
+// - repo: The repository.
+// - operation: The name of the operation, e.g. "rebase".
+func NewTempRef(repo *GitRepository, operation string) *TempRef {
+	return &TempRef{repo: repo, Name: fmt.Sprintf("%s/%s-%d", TempRefsDir, operation, os.Getpid())}
+}
+
+// Keep points the ref at sha, which stays reachable until the next Keep
+// or Release.
+func (t *TempRef) Keep(sha string) error {
+	if err := UpdateRef(t.repo, t.Name, sha, ""); err != nil {
+		return err
+	}
+	t.kept = true
+	return nil
+}
+
+// Release deletes the ref, once the operation has pointed a branch at
+// what it made or given up on it. It does nothing if Keep was never
+// called.
+func (t *TempRef) Release() error {
+	if !t.kept {
+		return nil
+	}
+	t.kept = false
+	return DeleteRef(t.repo, t.Name)
+}
+
+// RemoveStaleTempRefs deletes the temporary refs left behind by processes
+// that are no longer running and that were last updated before cutoff.
+// Refs of running processes are kept whatever their age, and so are refs
+// whose name does not end in a process id.
+//
+// Returns:
+// - The names of the refs removed.
+// - An error if the refs cannot be read or one cannot be deleted.
+func RemoveStaleTempRefs(repo *GitRepository, cutoff time.Time) ([]string, error) {
+	refs, err := Refs(repo, TempRefsDir+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, ref := range refs {
+		pid, ok := tempRefOwner(ref.Name)
+		if !ok || processAlive(pid) {
+			continue
+		}
+		// A packed ref has no time of its own; it was last updated before
+		// the refs were packed.
+		info, err := repo.fs.Stat(createRepoPath(repo, filepath.FromSlash(ref.Name)))
+		if err == nil && !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := DeleteRef(repo, ref.Name); err != nil {
+			return removed, err
+		}
+		trace.Log(trace.Ref, "remove stale temporary ref", "ref", ref.Name, "pid", pid)
+		removed = append(removed, ref.Name)
+	}
+	return removed, nil
+}
+
+// tempRefOwner returns the process id at the end of a temporary ref name.
+func tempRefOwner(name string) (int, bool) {
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(name[i+1:])
+	return pid, err == nil && pid > 0
+}
@@ -0,0 +1,126 @@
+// Package trace provides structured debug logging for justdoit, similar to
+// git's GIT_TRACE. Tracing is disabled by default and can be turned on with
+// the JUSTDOIT_TRACE environment variable or the --trace flag.
+//
+// JUSTDOIT_TRACE accepts the same values as GIT_TRACE:
+//   - "", "0" or "false" disables tracing.
+//   - "1", "2" or "true" writes trace records to stderr.
+//   - An absolute path appends trace records to that file.
+//
+// Records are written in logfmt so they can be grepped or fed to log tooling.
+package trace
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvVar is the environment variable that enables tracing.
+const EnvVar = "JUSTDOIT_TRACE"
+
+// Category groups trace records by the subsystem that emitted them.
+type Category string
+
+const (
+	Object Category = "object" // Object reads and writes.
+	Ref    Category = "ref"    // Ref resolution and updates.
+	Pack   Category = "pack"   // Pack reading, writing and negotiation rounds.
+	Config Category = "config" // Configuration loading.
+	Perf   Category = "perf"   // Timings of whole operations.
+)
+
+var (
+	mu     sync.Mutex
+	once   sync.Once
+	logger *slog.Logger
+)
+
+// Enable turns tracing on and directs all records to w.
+func Enable(w io.Writer) {
+	once.Do(func() {})
+	mu.Lock()
+	defer mu.Unlock()
+	logger = newLogger(w)
+}
+
+// Disable turns tracing off.
+func Disable() {
+	once.Do(func() {})
+	mu.Lock()
+	defer mu.Unlock()
+	logger = nil
+}
+
+// Enabled reports whether trace records are being written. It is cheap to call
+// and should guard any expensive argument construction.
+func Enabled() bool {
+	return current() != nil
+}
+
+// Log writes a single trace record for the given category.
+//
+// Parameters:
+// - cat: The subsystem emitting the record.
+// - msg: A short description of the event.
+// - args: Alternating key/value pairs attached to the record.
+func Log(cat Category, msg string, args ...any) {
+	l := current()
+	if l == nil {
+		return
+	}
+	l.Info(msg, append([]any{"cat", string(cat)}, args...)...)
+}
+
+// Start marks the beginning of a timed operation and returns a function that
+// records its elapsed time when called. It is intended to be deferred:
+//
+//	defer trace.Start(trace.Perf, "init", "path", path)()
+func Start(cat Category, msg string, args ...any) func() {
+	if current() == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		Log(cat, msg, append(args, "elapsed", time.Since(start))...)
+	}
+}
+
+func current() *slog.Logger {
+	once.Do(initFromEnv)
+	mu.Lock()
+	defer mu.Unlock()
+	return logger
+}
+
+// initFromEnv configures the logger from JUSTDOIT_TRACE. Unusable values,
+// such as a file that cannot be opened, silently leave tracing disabled.
+func initFromEnv() {
+	value := strings.TrimSpace(os.Getenv(EnvVar))
+	switch strings.ToLower(value) {
+	case "", "0", "false":
+		return
+	case "1", "2", "true":
+		logger = newLogger(os.Stderr)
+		return
+	}
+
+	if !filepath.IsAbs(value) {
+		return
+	}
+
+	file, err := os.OpenFile(value, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	logger = newLogger(file)
+}
+
+func newLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
@@ -0,0 +1,762 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// SignoffToken is the token of the trailer commit --signoff adds.
+const SignoffToken = "Signed-off-by"
+
+// scissorsLine marks where an edited commit message is cut off, after the
+// comment character and a space.
+const scissorsLine = "------------------------ >8 ------------------------\n"
+
+// gitGeneratedPrefixes start trailers that git itself adds. One of them is
+// enough to recognize a block that is only partly made of trailers.
+var gitGeneratedPrefixes = []string{SignoffToken + ": ", "(cherry picked from commit "}
+
+// TrailerWhere is where a new trailer is put, as trailer.where sets it.
+type TrailerWhere string
+
+const (
+	TrailerEnd    TrailerWhere = "end"    // After the last trailer.
+	TrailerStart  TrailerWhere = "start"  // Before the first trailer.
+	TrailerAfter  TrailerWhere = "after"  // After the last trailer with the same token.
+	TrailerBefore TrailerWhere = "before" // Before the first trailer with the same token.
+)
+
+// TrailerIfExists is what happens to a new trailer whose token the message
+// already has, as trailer.ifExists sets it.
+type TrailerIfExists string
+
+const (
+	TrailerAddIfDifferentNeighbor TrailerIfExists = "addIfDifferentNeighbor"
+	TrailerAddIfDifferent         TrailerIfExists = "addIfDifferent"
+	TrailerAdd                    TrailerIfExists = "add"
+	TrailerReplace                TrailerIfExists = "replace"
+	TrailerDoNothing              TrailerIfExists = "doNothing"
+)
+
+// TrailerIfMissing is what happens to a new trailer whose token the
+// message does not have, as trailer.ifMissing sets it: "add" or
+// "doNothing".
+type TrailerIfMissing string
+
+// TrailerRule places a new trailer. Empty fields fall back to the trailer's
+// config, then to trailer.where, trailer.ifExists and trailer.ifMissing,
+// then to end, addIfDifferentNeighbor and add.
+type TrailerRule struct {
+	Where     TrailerWhere
+	IfExists  TrailerIfExists
+	IfMissing TrailerIfMissing
+}
+
+// Trailer is a "Token: value" line at the end of a message. Continuation
+// lines stay in Value, joined by newlines, unless the trailers were
+// unfolded.
+type Trailer struct {
+	Token string
+	Value string
+}
+
+// NewTrailer is a trailer to add, as given to interpret-trailers
+// --trailer: "token: value" or "token=value", where the token may be an
+// alias set with trailer.<alias>.key.
+type NewTrailer struct {
+	Text string
+	Rule TrailerRule
+}
+
+// TrailerOptions controls InterpretTrailers, like the flags of git
+// interpret-trailers.
+type TrailerOptions struct {
+	TrimEmpty    bool // Leave out trailers with an empty value.
+	OnlyTrailers bool // Output the trailers alone, without the message.
+	OnlyInput    bool // Do not add any trailer.
+	Unfold       bool // Join continuation lines into one line.
+	NoDivider    bool // Do not treat a "---" line as the start of a patch.
+
+	// Warnings receives an error for each new trailer that is skipped
+	// because its token is empty, or nil to skip them silently.
+	Warnings io.Writer
+}
+
+// ParseTrailerWhere parses a trailer.where value, ignoring case.
+func ParseTrailerWhere(value string) (TrailerWhere, error) {
+	for _, where := range []TrailerWhere{TrailerEnd, TrailerStart, TrailerAfter, TrailerBefore} {
+		if strings.EqualFold(value, string(where)) {
+			return where, nil
+		}
+	}
+	return "", fmt.Errorf("unknown value '%s' for key 'where'", value)
+}
+
+// ParseTrailerIfExists parses a trailer.ifExists value, ignoring case.
+func ParseTrailerIfExists(value string) (TrailerIfExists, error) {
+	for _, action := range []TrailerIfExists{TrailerAddIfDifferentNeighbor, TrailerAddIfDifferent, TrailerAdd, TrailerReplace, TrailerDoNothing} {
+		if strings.EqualFold(value, string(action)) {
+			return action, nil
+		}
+	}
+	return "", fmt.Errorf("unknown value '%s' for key 'ifexists'", value)
+}
+
+// ParseTrailerIfMissing parses a trailer.ifMissing value, ignoring case.
+func ParseTrailerIfMissing(value string) (TrailerIfMissing, error) {
+	for _, action := range []TrailerIfMissing{TrailerIfMissing(TrailerAdd), TrailerIfMissing(TrailerDoNothing)} {
+		if strings.EqualFold(value, string(action)) {
+			return action, nil
+		}
+	}
+	return "", fmt.Errorf("unknown value '%s' for key 'ifmissing'", value)
+}
+
+// trailerConf is one configured trailer, trailer.<name>.*.
+type trailerConf struct {
+	name string
+	key  string // The token written for it, "" for name.
+	rule TrailerRule
+}
+
+// trailerSettings is the trailer config of a repository.
+type trailerSettings struct {
+	separators  string
+	commentChar string
+	defaults    TrailerRule
+	items       []trailerConf
+}
+
+// loadTrailerSettings reads trailer.* from the repository and global
+// config. Invalid values are ignored, as git only warns about them.
+func loadTrailerSettings(repo *GitRepository) *trailerSettings {
+	settings := &trailerSettings{
+		separators: ":",
+		defaults:   TrailerRule{Where: TrailerEnd, IfExists: TrailerAddIfDifferentNeighbor, IfMissing: TrailerIfMissing(TrailerAdd)},
+	}
+	if separators := lookupConfig(repo, "trailer.separators"); separators != "" {
+		settings.separators = separators
+	}
+	settings.commentChar, _ = CommentChar(repo)
+	if settings.commentChar == "" {
+		settings.commentChar = DefaultCommentChar
+	}
+	settings.defaults = readTrailerRule(repo, "trailer", "", settings.defaults)
+
+	keys := GlobalConfig().AllKeys()
+	if repo != nil {
+		keys = append(keys, repo.Config().AllKeys()...)
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, `trailer "`)
+		if !ok {
+			continue
+		}
+		if name, _, ok := strings.Cut(rest, `"`); ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		settings.items = append(settings.items, trailerConf{
+			name: name,
+			key:  lookupConfig(repo, configKey("trailer", name, "key")),
+			rule: readTrailerRule(repo, "trailer", name, TrailerRule{}),
+		})
+	}
+	return settings
+}
+
+// readTrailerRule reads where, ifExists and ifMissing of a trailer config
+// section over fallback.
+func readTrailerRule(repo *GitRepository, section, subsection string, fallback TrailerRule) TrailerRule {
+	rule := fallback
+	if value := lookupConfig(repo, configKey(section, subsection, "where")); value != "" {
+		if where, err := ParseTrailerWhere(value); err == nil {
+			rule.Where = where
+		} else {
+			trace.Log(trace.Config, "trailer setting ignored", "error", err)
+		}
+	}
+	if value := lookupConfig(repo, configKey(section, subsection, "ifexists")); value != "" {
+		if action, err := ParseTrailerIfExists(value); err == nil {
+			rule.IfExists = action
+		} else {
+			trace.Log(trace.Config, "trailer setting ignored", "error", err)
+		}
+	}
+	if value := lookupConfig(repo, configKey(section, subsection, "ifmissing")); value != "" {
+		if action, err := ParseTrailerIfMissing(value); err == nil {
+			rule.IfMissing = action
+		} else {
+			trace.Log(trace.Config, "trailer setting ignored", "error", err)
+		}
+	}
+	return rule
+}
+
+// merge fills the empty fields of r from fallback.
+func (r TrailerRule) merge(fallback TrailerRule) TrailerRule {
+	if r.Where == "" {
+		r.Where = fallback.Where
+	}
+	if r.IfExists == "" {
+		r.IfExists = fallback.IfExists
+	}
+	if r.IfMissing == "" {
+		r.IfMissing = fallback.IfMissing
+	}
+	return r
+}
+
+// afterOrEnd reports whether a trailer placed with where goes after its
+// anchor, so matching trailers are searched from the end.
+func (w TrailerWhere) afterOrEnd() bool {
+	return w == TrailerAfter || w == TrailerEnd
+}
+
+// trailerItem is one entry of a trailer block: a trailer, or a line of the
+// block that is not one, kept with an empty token.
+type trailerItem struct {
+	token string
+	value string
+}
+
+// trailerArg is a trailer to add, with its rule resolved.
+type trailerArg struct {
+	token string
+	value string
+	rule  TrailerRule
+}
+
+// isTrailerSpace is git's isspace, which unlike C's leaves out \v and \f.
+func isTrailerSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isTrailerAlnum reports whether c is an ASCII letter or digit.
+func isTrailerAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// trimTrailerSpace trims git's whitespace from both ends of s.
+func trimTrailerSpace(s string) string {
+	start, end := 0, len(s)
+	for start < end && isTrailerSpace(s[start]) {
+		start++
+	}
+	for end > start && isTrailerSpace(s[end-1]) {
+		end--
+	}
+	return s[start:end]
+}
+
+// nextLine returns the offset of the line after the one at i.
+func nextLine(buf string, i int) int {
+	if end := strings.IndexByte(buf[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+	return len(buf)
+}
+
+// lastLine returns the offset of the last line of buf[:n], which may end
+// with a newline, or -1 when it is empty.
+func lastLine(buf string, n int) int {
+	if n == 0 {
+		return -1
+	}
+	for i := n - 2; i >= 0; i-- {
+		if buf[i] == '\n' {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// isBlankLine reports whether the line starting s holds only whitespace.
+func isBlankLine(s string) bool {
+	for i := 0; i < len(s) && s[i] != '\n'; i++ {
+		if !isTrailerSpace(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// findSeparator returns the offset of the separator of a trailer line, or
+// -1 when the line does not start with a token, made of letters, digits
+// and dashes and optionally followed by whitespace, and a separator.
+func findSeparator(line, separators string) int {
+	whitespace := false
+	for i := 0; i < len(line) && line[i] != '\n'; i++ {
+		c := line[i]
+		switch {
+		case strings.IndexByte(separators, c) >= 0:
+			return i
+		case !whitespace && (isTrailerAlnum(c) || c == '-'):
+		case i > 0 && (c == ' ' || c == '\t'):
+			whitespace = true
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// tokenLen returns the length of a token without a trailing separator or
+// other punctuation, such as the ": " of "Signed-off-by: ".
+func tokenLen(token string) int {
+	n := len(token)
+	for n > 0 && !isTrailerAlnum(token[n-1]) {
+		n--
+	}
+	return n
+}
+
+// hasTokenPrefix compares the first n bytes of token and name, ignoring
+// case, like strncasecmp: a shorter name only matches if token ends too.
+func hasTokenPrefix(token, name string, n int) bool {
+	a, b := token[:min(n, len(token))], name[:min(n, len(name))]
+	return strings.EqualFold(a, b)
+}
+
+// matches reports whether a token names a configured trailer, by its name
+// or its key.
+func (c *trailerConf) matches(token string, n int) bool {
+	return hasTokenPrefix(token, c.name, n) || (c.key != "" && hasTokenPrefix(token, c.key, n))
+}
+
+// parseTrailer splits a trailer at its separator, or takes the whole text
+// as the token when separator is -1. A token naming a configured trailer
+// is replaced by its key.
+func (s *trailerSettings) parseTrailer(text string, separator int) (string, string, *trailerConf) {
+	token, value := trimTrailerSpace(text), ""
+	if separator >= 0 {
+		token, value = trimTrailerSpace(text[:separator]), trimTrailerSpace(text[separator+1:])
+	}
+
+	n := tokenLen(token)
+	for i := range s.items {
+		if s.items[i].matches(token, n) {
+			if s.items[i].key != "" {
+				token = s.items[i].key
+			}
+			return token, value, &s.items[i]
+		}
+	}
+	return token, value, nil
+}
+
+// formatTrailer formats a trailer line, adding the first separator and a
+// space unless the token, like a configured key, already ends in one.
+func (s *trailerSettings) formatTrailer(token, value string) string {
+	trimmed := strings.TrimRight(token, " \t\n\r")
+	if trimmed == "" {
+		return ""
+	}
+	if strings.IndexByte(s.separators, trimmed[len(trimmed)-1]) >= 0 {
+		return token + value + "\n"
+	}
+	return fmt.Sprintf("%s%c %s\n", token, s.separators[0], value)
+}
+
+// unfoldValue joins the continuation lines of a value with single spaces.
+func unfoldValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		c := value[i]
+		i++
+		if c != '\n' {
+			b.WriteByte(c)
+			continue
+		}
+		for i < len(value) && isTrailerSpace(value[i]) {
+			i++
+		}
+		b.WriteByte(' ')
+	}
+	return trimTrailerSpace(b.String())
+}
+
+// findPatchStart returns the offset of the "---" line that starts a patch
+// below a message, or the length of the message.
+func findPatchStart(buf string) int {
+	for i := 0; i < len(buf); i = nextLine(buf, i) {
+		if strings.HasPrefix(buf[i:], "---") && i+3 < len(buf) && isTrailerSpace(buf[i+3]) {
+			return i
+		}
+	}
+	return len(buf)
+}
+
+// ignoredMessageBytes returns how many bytes at the end of buf[:n] are
+// not part of the message proper: the trailing comments and blank lines, an
+// old "Conflicts:" list and anything below the scissors line.
+func ignoredMessageBytes(buf string, n int, commentChar string) int {
+	cutoff := n
+	scissors := commentChar + " " + scissorsLine
+	if strings.HasPrefix(buf[:n], scissors) {
+		cutoff = 0
+	} else if i := strings.Index(buf[:n], "\n"+scissors); i >= 0 {
+		cutoff = i + 1
+	}
+
+	comments := -1
+	conflicts := false
+	for bol := 0; bol < cutoff; bol = min(nextLine(buf, bol), cutoff) {
+		line := buf[bol:cutoff]
+		switch {
+		case strings.HasPrefix(line, commentChar) || line[0] == '\n':
+			if comments < 0 {
+				comments = bol
+			}
+		case strings.HasPrefix(line, "Conflicts:\n"):
+			conflicts = true
+			if comments < 0 {
+				comments = bol
+			}
+		case conflicts && line[0] == '\t':
+		case comments >= 0:
+			comments = -1
+			conflicts = false
+		}
+	}
+	if comments >= 0 {
+		return n - comments
+	}
+	return n - cutoff
+}
+
+// findTrailerStart returns the offset of the trailer block in buf[:n], or
+// n when there is none. The block is the last paragraph, but never the
+// title, and must consist only of trailers and continuation lines, or of
+// at least a quarter of trailers including one git adds or that is
+// configured.
+func (s *trailerSettings) findTrailerStart(buf string, n int) int {
+	endOfTitle := 0
+	for endOfTitle < n {
+		if !strings.HasPrefix(buf[endOfTitle:], s.commentChar) && isBlankLine(buf[endOfTitle:]) {
+			break
+		}
+		endOfTitle = nextLine(buf, endOfTitle)
+	}
+
+	onlySpaces := true
+	recognized := false
+	trailerLines, nonTrailerLines, continuationLines := 0, 0, 0
+	for l := lastLine(buf, n); l >= endOfTitle; l = lastLine(buf, l) {
+		line := buf[l:]
+		if strings.HasPrefix(line, s.commentChar) {
+			nonTrailerLines += continuationLines
+			continuationLines = 0
+			continue
+		}
+		if isBlankLine(line) {
+			if onlySpaces {
+				continue
+			}
+			nonTrailerLines += continuationLines
+			if recognized && trailerLines*3 >= nonTrailerLines {
+				return nextLine(buf, l)
+			}
+			if trailerLines > 0 && nonTrailerLines == 0 {
+				return nextLine(buf, l)
+			}
+			return n
+		}
+		onlySpaces = false
+
+		generated := false
+		for _, prefix := range gitGeneratedPrefixes {
+			if strings.HasPrefix(line, prefix) {
+				generated = true
+				break
+			}
+		}
+		if generated {
+			trailerLines++
+			continuationLines = 0
+			recognized = true
+			continue
+		}
+
+		separator := findSeparator(line, s.separators)
+		switch {
+		case separator >= 1 && !isTrailerSpace(line[0]):
+			trailerLines++
+			continuationLines = 0
+			if recognized {
+				continue
+			}
+			for i := range s.items {
+				if s.items[i].matches(line, separator) {
+					recognized = true
+					break
+				}
+			}
+		case isTrailerSpace(line[0]):
+			continuationLines++
+		default:
+			nonTrailerLines += 1 + continuationLines
+			continuationLines = 0
+		}
+	}
+	return n
+}
+
+// trailerBlock is a message split around its trailer block.
+type trailerBlock struct {
+	before      string // The message up to the trailer block.
+	after       string // Comments, a scissors line or a patch below the message.
+	blankBefore bool   // Whether before ends with a blank line.
+	items       []*trailerItem
+}
+
+// parseTrailerBlock finds the trailer block of a message and parses its
+// lines.
+func (s *trailerSettings) parseTrailerBlock(message string, opts TrailerOptions) *trailerBlock {
+	patchStart := len(message)
+	if !opts.NoDivider {
+		patchStart = findPatchStart(message)
+	}
+	end := patchStart - ignoredMessageBytes(message, patchStart, s.commentChar)
+	start := s.findTrailerStart(message, end)
+
+	block := &trailerBlock{before: message[:start], after: message[end:]}
+	if last := lastLine(message, start); last >= 0 {
+		block.blankBefore = isBlankLine(message[last:])
+	}
+
+	// Continuation lines are joined to the trailer above them.
+	var lines []string
+	continues := false
+	for i := start; i < end; i = nextLine(message, i) {
+		line := message[i:min(nextLine(message, i), end)]
+		if continues && isTrailerSpace(line[0]) {
+			lines[len(lines)-1] += line
+			continue
+		}
+		lines = append(lines, line)
+		continues = findSeparator(line, s.separators) >= 1
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, s.commentChar) {
+			continue
+		}
+		if separator := findSeparator(line, s.separators); separator >= 1 {
+			token, value, _ := s.parseTrailer(line, separator)
+			if opts.Unfold {
+				value = unfoldValue(value)
+			}
+			block.items = append(block.items, &trailerItem{token: token, value: value})
+		} else if !opts.OnlyTrailers {
+			block.items = append(block.items, &trailerItem{value: strings.TrimSuffix(line, "\n")})
+		}
+	}
+	return block
+}
+
+// sameToken compares the tokens of a trailer and a new one, ignoring case
+// and up to the length of the shorter.
+func sameToken(item *trailerItem, arg *trailerArg) bool {
+	if item.token == "" {
+		return false
+	}
+	n := min(tokenLen(item.token), tokenLen(arg.token))
+	return hasTokenPrefix(item.token, arg.token, n)
+}
+
+// sameTrailer reports whether a trailer equals a new one, ignoring case.
+func sameTrailer(item *trailerItem, arg *trailerArg) bool {
+	return sameToken(item, arg) && strings.EqualFold(item.value, arg.value)
+}
+
+// indexOf returns the position of item in the block.
+func (b *trailerBlock) indexOf(item *trailerItem) int {
+	for i, candidate := range b.items {
+		if candidate == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// insert adds a new trailer next to anchor: after it when the rule places
+// trailers after or at the end, before it otherwise.
+func (b *trailerBlock) insert(anchor *trailerItem, arg *trailerArg) {
+	i := b.indexOf(anchor)
+	if arg.rule.Where.afterOrEnd() {
+		i++
+	}
+	b.items = append(b.items[:i], append([]*trailerItem{{token: arg.token, value: arg.value}}, b.items[i:]...)...)
+}
+
+// differs reports whether arg differs from item and, with all, from every
+// trailer before it (or after it, for rules placing trailers first).
+func (b *trailerBlock) differs(item *trailerItem, arg *trailerArg, all bool) bool {
+	for i := b.indexOf(item); i >= 0 && i < len(b.items); {
+		if sameTrailer(b.items[i], arg) {
+			return false
+		}
+		if !all {
+			break
+		}
+		if arg.rule.Where.afterOrEnd() {
+			i--
+		} else {
+			i++
+		}
+	}
+	return true
+}
+
+// apply adds one new trailer to the block following its rule.
+func (b *trailerBlock) apply(arg *trailerArg) {
+	backwards := arg.rule.Where.afterOrEnd()
+	middle := arg.rule.Where == TrailerAfter || arg.rule.Where == TrailerBefore
+
+	if len(b.items) > 0 {
+		anchor := b.items[0]
+		if backwards {
+			anchor = b.items[len(b.items)-1]
+		}
+		for n := range b.items {
+			i := n
+			if backwards {
+				i = len(b.items) - 1 - n
+			}
+			existing := b.items[i]
+			if !sameToken(existing, arg) {
+				continue
+			}
+			if middle {
+				anchor = existing
+			}
+
+			switch arg.rule.IfExists {
+			case TrailerDoNothing:
+			case TrailerReplace:
+				b.insert(anchor, arg)
+				b.items = append(b.items[:b.indexOf(existing)], b.items[b.indexOf(existing)+1:]...)
+			case TrailerAdd:
+				b.insert(anchor, arg)
+			case TrailerAddIfDifferent:
+				if b.differs(existing, arg, true) {
+					b.insert(anchor, arg)
+				}
+			default:
+				if b.differs(anchor, arg, false) {
+					b.insert(anchor, arg)
+				}
+			}
+			return
+		}
+	}
+
+	if arg.rule.IfMissing == TrailerIfMissing(TrailerDoNothing) {
+		return
+	}
+	item := &trailerItem{token: arg.token, value: arg.value}
+	if backwards {
+		b.items = append(b.items, item)
+	} else {
+		b.items = append([]*trailerItem{item}, b.items...)
+	}
+}
+
+// InterpretTrailers adds trailers to a message and reformats its trailer
+// block the way git interpret-trailers does. The trailer block is the last
+// paragraph of the message when it looks like trailers; comments, a
+// scissors line and a patch after a "---" line are kept below it.
+//
+// Parameters:
+// - repo: The repository, or nil to read only the global config.
+// - message: The message.
+// - trailers: The trailers to add, in order.
+// - opts: The output options.
+//
+// Returns:
+// - The new message.
+func InterpretTrailers(repo *GitRepository, message []byte, trailers []NewTrailer, opts TrailerOptions) []byte {
+	settings := loadTrailerSettings(repo)
+	block := settings.parseTrailerBlock(string(message), opts)
+
+	if !opts.OnlyInput {
+		// On the command line '=' separates too.
+		separators := "=" + settings.separators
+		for _, trailer := range trailers {
+			separator := findSeparator(trailer.Text, separators)
+			if separator == 0 {
+				if opts.Warnings != nil {
+					fmt.Fprintf(opts.Warnings, "error: empty trailer token in trailer '%s'\n", trimTrailerSpace(trailer.Text))
+				}
+				continue
+			}
+			token, value, conf := settings.parseTrailer(trailer.Text, separator)
+			rule := settings.defaults
+			if conf != nil {
+				rule = conf.rule.merge(rule)
+			}
+			block.apply(&trailerArg{token: token, value: value, rule: trailer.Rule.merge(rule)})
+		}
+	}
+
+	var out strings.Builder
+	if !opts.OnlyTrailers {
+		out.WriteString(block.before)
+		if !block.blankBefore {
+			out.WriteByte('\n')
+		}
+	}
+	for _, item := range block.items {
+		if opts.TrimEmpty && item.value == "" {
+			continue
+		}
+		switch {
+		case item.token != "":
+			out.WriteString(settings.formatTrailer(item.token, item.value))
+		case !opts.OnlyTrailers:
+			out.WriteString(item.value + "\n")
+		}
+	}
+	if !opts.OnlyTrailers {
+		out.WriteString(block.after)
+	}
+	return []byte(out.String())
+}
+
+// ParseTrailers returns the trailers at the end of a message, with their
+// continuation lines unfolded, as interpret-trailers --parse lists them.
+func ParseTrailers(repo *GitRepository, message []byte) []Trailer {
+	settings := loadTrailerSettings(repo)
+	block := settings.parseTrailerBlock(string(message), TrailerOptions{OnlyTrailers: true, Unfold: true})
+	trailers := make([]Trailer, 0, len(block.items))
+	for _, item := range block.items {
+		trailers = append(trailers, Trailer{Token: item.token, Value: item.value})
+	}
+	return trailers
+}
+
+// AppendSignoff adds a "Signed-off-by: <ident>" trailer to a message, as
+// commit --signoff does, unless the last trailer already is that one.
+//
+// Parameters:
+// - repo: The repository, or nil.
+// - message: The message, e.g. after Stripspace.
+// - ident: The signer, "Name <email>", e.g. from Ident without the date.
+//
+// Returns:
+// - The signed-off message.
+func AppendSignoff(repo *GitRepository, message []byte, ident string) []byte {
+	rule := TrailerRule{Where: TrailerEnd, IfExists: TrailerAddIfDifferentNeighbor, IfMissing: TrailerIfMissing(TrailerAdd)}
+	return InterpretTrailers(repo, message, []NewTrailer{{Text: SignoffToken + ": " + ident, Rule: rule}}, TrailerOptions{})
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// zeroID is the object name advertised by a repository without any refs.
+const zeroID = "0000000000000000000000000000000000000000"
+
+// AdvertisedRef is one line of a ref advertisement. Peeled tags appear as
+// separate entries named "<tag>^{}".
+type AdvertisedRef struct {
+	SHA  string
+	Name string
+}
+
+// Advertisement is the list of refs and capabilities a server announces at
+// the start of a protocol v0 conversation.
+type Advertisement struct {
+	Refs         []AdvertisedRef
+	Capabilities Capabilities
+}
+
+// ReadAdvertisement reads a ref advertisement up to its flush packet. The
+// "capabilities^{}" placeholder sent by empty repositories is not returned
+// as a ref.
+//
+// Parameters:
+// - dec: The decoder positioned at the start of the advertisement.
+//
+// Returns:
+// - The advertised refs and capabilities.
+// - An error if the server reported an error or sent a malformed line.
+func ReadAdvertisement(dec *Decoder) (*Advertisement, error) {
+	advertisement := &Advertisement{}
+
+	for first := true; ; first = false {
+		packetType, payload, err := dec.Read()
+		if err == io.EOF && first {
+			return nil, fmt.Errorf("remote end hung up before advertising refs")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if packetType == FlushPacket {
+			return advertisement, nil
+		}
+
+		line := strings.TrimSuffix(string(payload), "\n")
+		if message, isError := strings.CutPrefix(line, "ERR "); isError {
+			return nil, fmt.Errorf("remote error: %s", message)
+		}
+
+		if first {
+			var capabilities string
+			line, capabilities, _ = strings.Cut(line, "\x00")
+			advertisement.Capabilities = ParseCapabilities(capabilities)
+		}
+
+		sha, name, ok := strings.Cut(line, " ")
+		if !ok || len(sha) != len(zeroID) {
+			return nil, fmt.Errorf("protocol error: unexpected advertisement line '%s'", line)
+		}
+		if sha == zeroID && name == "capabilities^{}" {
+			continue
+		}
+		advertisement.Refs = append(advertisement.Refs, AdvertisedRef{SHA: sha, Name: name})
+	}
+}
@@ -0,0 +1,149 @@
+package transport
+
+import "strings"
+
+// Names of the protocol v0 capabilities.
+const (
+	CapMultiAck         = "multi_ack"
+	CapMultiAckDetailed = "multi_ack_detailed"
+	CapNoDone           = "no-done"
+	CapThinPack         = "thin-pack"
+	CapSideBand         = "side-band"
+	CapSideBand64k      = "side-band-64k"
+	CapOfsDelta         = "ofs-delta"
+	CapAgent            = "agent"
+	CapShallow          = "shallow"
+	CapFilter           = "filter"
+	CapAllowTipSHA1     = "allow-tip-sha1-in-want"
+	CapAllowReachSHA1   = "allow-reachable-sha1-in-want"
+	CapIncludeTag       = "include-tag"
+	CapNoProgress       = "no-progress"
+	CapSymref           = "symref"
+	CapReportStatus     = "report-status"
+	CapDeleteRefs       = "delete-refs"
+	CapAtomic           = "atomic"
+	CapPushOptions      = "push-options"
+)
+
+// Capabilities is a list of capabilities in the order they are sent, each
+// either a bare name such as "ofs-delta" or a "name=value" pair such as
+// "agent=git/2.45.0". A name may occur more than once, as symref does.
+//
+// Capabilities values are not changed in place: With and WithValue return
+// a new list, so a shared list such as a server's offer can be extended
+// per connection.
+type Capabilities struct {
+	entries []string
+}
+
+// NewCapabilities creates a list of the given capabilities.
+func NewCapabilities(entries ...string) Capabilities {
+	return Capabilities{entries: append([]string(nil), entries...)}
+}
+
+// ParseCapabilities parses the space separated capabilities sent after the
+// NUL byte of the first advertised ref, or after the first want or ref
+// update command.
+func ParseCapabilities(list string) Capabilities {
+	return Capabilities{entries: strings.Fields(list)}
+}
+
+// With returns the list with entry, a name or "name=value", appended.
+// Empty entries are ignored.
+func (c Capabilities) With(entry string) Capabilities {
+	if entry == "" {
+		return c
+	}
+	entries := make([]string, len(c.entries), len(c.entries)+1)
+	copy(entries, c.entries)
+	return Capabilities{entries: append(entries, entry)}
+}
+
+// WithValue returns the list with "name=value" appended.
+func (c Capabilities) WithValue(name, value string) Capabilities {
+	return c.With(name + "=" + value)
+}
+
+// Has reports whether the list contains name, with or without a value.
+func (c Capabilities) Has(name string) bool {
+	_, ok := c.Value(name)
+	return ok
+}
+
+// Value returns the value of the first capability called name, empty for
+// a bare name, and whether the list contains it.
+func (c Capabilities) Value(name string) (string, bool) {
+	for _, entry := range c.entries {
+		if key, value, _ := strings.Cut(entry, "="); key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Values returns the values of every capability called name.
+func (c Capabilities) Values(name string) []string {
+	var values []string
+	for _, entry := range c.entries {
+		if key, value, _ := strings.Cut(entry, "="); key == name {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Preferred returns the first of names that the list contains, for
+// choosing between alternatives such as side-band-64k and side-band, or an
+// empty string if it contains none of them.
+func (c Capabilities) Preferred(names ...string) string {
+	for _, name := range names {
+		if c.Has(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// Negotiate returns the capabilities of request whose names the list, as
+// offered by the peer, contains. Requests keep their own values, so a
+// client answers "agent=git/2.45.0" with its own agent.
+//
+// Parameters:
+// - request: The capabilities the caller would like to use.
+//
+// Returns:
+// - The requested capabilities the peer supports, in the order requested.
+func (c Capabilities) Negotiate(request Capabilities) Capabilities {
+	var agreed Capabilities
+	for _, entry := range request.entries {
+		name, _, _ := strings.Cut(entry, "=")
+		if c.Has(name) {
+			agreed.entries = append(agreed.entries, entry)
+		}
+	}
+	return agreed
+}
+
+// SidebandPacketSize returns the largest side-band packet the list allows:
+// MaxPacketSize with side-band-64k, 1000 with side-band, and 0 when the
+// pack is not multiplexed.
+func (c Capabilities) SidebandPacketSize() int {
+	switch {
+	case c.Has(CapSideBand64k):
+		return MaxPacketSize
+	case c.Has(CapSideBand):
+		return 1000
+	default:
+		return 0
+	}
+}
+
+// Len returns the number of capabilities in the list.
+func (c Capabilities) Len() int {
+	return len(c.entries)
+}
+
+// String returns the list as sent on the wire, separated by spaces.
+func (c Capabilities) String() string {
+	return strings.Join(c.entries, " ")
+}
@@ -0,0 +1,176 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// DefaultGitPort is the port of the git:// protocol.
+	DefaultGitPort = "9418"
+	// httpUserAgent starts with "git/" because some hosts only speak the
+	// smart protocol to user agents that look like git.
+	httpUserAgent = "git/justdoit"
+)
+
+// DialOptions configures Dial.
+type DialOptions struct {
+	// Command overrides the program started for file and ssh endpoints, e.g.
+	// "git-upload-pack". It may contain arguments separated by spaces.
+	Command string
+	// HTTP configures the client of http and https endpoints.
+	HTTP HTTPOptions
+}
+
+// Session is an open conversation with a remote upload-pack or receive-pack.
+// Reads return the server's responses and writes send requests.
+type Session struct {
+	// Advertisement holds the refs and capabilities announced by the server.
+	Advertisement *Advertisement
+	// Stateless is set for smart HTTP, where every request is answered
+	// independently and the client must resend its state each round.
+	Stateless bool
+
+	r     io.Reader
+	w     io.Writer
+	close func() error
+}
+
+func (s *Session) Read(p []byte) (int, error)  { return s.r.Read(p) }
+func (s *Session) Write(p []byte) (int, error) { return s.w.Write(p) }
+
+// Close ends the conversation and releases the connection or process.
+func (s *Session) Close() error {
+	return s.close()
+}
+
+// Dial connects to a service ("git-upload-pack" or "git-receive-pack") of the
+// repository at endpoint and reads its ref advertisement.
+//
+// Parameters:
+// - endpoint: Where the repository lives.
+// - service: The service to start.
+// - opts: Overrides for how the service is started.
+//
+// Returns:
+// - The open session, positioned after the advertisement.
+// - An error if the connection fails or the server refuses the request.
+func Dial(endpoint *Endpoint, service string, opts DialOptions) (*Session, error) {
+	var session *Session
+	var err error
+
+	switch endpoint.Scheme {
+	case "git":
+		session, err = dialGit(endpoint, service)
+	case "http", "https":
+		return dialHTTP(endpoint, service, opts.HTTP)
+	case "ssh":
+		session, err = dialSSH(endpoint, service, opts)
+	case "file":
+		session, err = dialLocal(endpoint, service, opts)
+	default:
+		return nil, fmt.Errorf("unsupported protocol '%s'", endpoint.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session.Advertisement, err = ReadAdvertisement(NewDecoder(session))
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// dialGit opens a git:// connection and sends the service request.
+func dialGit(endpoint *Endpoint, service string) (*Session, error) {
+	port := endpoint.Port
+	if port == "" {
+		port = DefaultGitPort
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(endpoint.Host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	request := fmt.Sprintf("%s %s\x00host=%s\x00", service, endpoint.Path, endpoint.Host)
+	if err := NewEncoder(conn).Encode([]byte(request)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Session{r: conn, w: conn, close: conn.Close}, nil
+}
+
+// dialSSH runs the service on the remote host through ssh. GIT_SSH_COMMAND
+// replaces the ssh program, as it does for git.
+func dialSSH(endpoint *Endpoint, service string, opts DialOptions) (*Session, error) {
+	args := []string{"ssh"}
+	if custom := os.Getenv("GIT_SSH_COMMAND"); custom != "" {
+		args = strings.Fields(custom)
+	}
+	if endpoint.Port != "" {
+		args = append(args, "-p", endpoint.Port)
+	}
+
+	host := endpoint.Host
+	if endpoint.User != nil {
+		host = endpoint.User.Username() + "@" + host
+	}
+
+	command := opts.Command
+	if command == "" {
+		command = service
+	}
+	remote := fmt.Sprintf("%s '%s'", command, strings.ReplaceAll(endpoint.Path, "'", `'\''`))
+	return startProcess(append(args, host, remote))
+}
+
+// dialLocal runs the service against a repository on this machine. Unless
+// overridden, the running executable serves it.
+func dialLocal(endpoint *Endpoint, service string, opts DialOptions) (*Session, error) {
+	var args []string
+	if opts.Command != "" {
+		args = strings.Fields(opts.Command)
+	} else {
+		self, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		args = []string{self, strings.TrimPrefix(service, "git-")}
+	}
+	return startProcess(append(args, endpoint.Path))
+}
+
+// startProcess starts a program speaking the protocol on its stdin and
+// stdout. Its stderr is passed through so remote messages reach the user.
+func startProcess(args []string) (*Session, error) {
+	process := exec.Command(args[0], args[1:]...)
+	process.Stderr = os.Stderr
+
+	stdin, err := process.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := process.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := process.Start(); err != nil {
+		return nil, err
+	}
+
+	// Closing stdout first stops a process that is still writing, such as a
+	// server sending a pack the client gave up on, from blocking Wait.
+	closeProcess := func() error {
+		stdin.Close()
+		stdout.Close()
+		return process.Wait()
+	}
+	return &Session{r: stdout, w: stdin, close: closeProcess}, nil
+}
@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Endpoint is the parsed location of a remote repository.
+type Endpoint struct {
+	Scheme string        // One of "file", "git", "ssh", "http" or "https".
+	User   *url.Userinfo // Credentials embedded in the URL, if any.
+	Host   string        // Host name, without the port.
+	Port   string        // Port, empty for the scheme's default.
+	Path   string        // Path of the repository on the remote host.
+}
+
+// ParseEndpoint parses the repository locations git accepts: URLs with the
+// file, git, ssh, http and https schemes, the scp-like "user@host:path"
+// syntax and plain local paths.
+//
+// Parameters:
+// - raw: The location as given by the user.
+//
+// Returns:
+// - The parsed endpoint.
+// - An error if the scheme is not supported.
+func ParseEndpoint(raw string) (*Endpoint, error) {
+	if scheme, _, ok := strings.Cut(raw, "://"); ok {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		switch scheme {
+		case "file", "git", "ssh", "http", "https":
+		case "git+ssh", "ssh+git":
+			scheme = "ssh"
+		default:
+			return nil, fmt.Errorf("unsupported protocol '%s'", scheme)
+		}
+
+		path := parsed.Path
+		if scheme == "http" || scheme == "https" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		return &Endpoint{
+			Scheme: scheme,
+			User:   parsed.User,
+			Host:   parsed.Hostname(),
+			Port:   parsed.Port(),
+			Path:   path,
+		}, nil
+	}
+
+	// scp-like syntax: [user@]host:path, where the host part has no slash.
+	if colon := strings.Index(raw, ":"); colon > 0 && !strings.Contains(raw[:colon], "/") {
+		host, path := raw[:colon], raw[colon+1:]
+		endpoint := &Endpoint{Scheme: "ssh", Host: host, Path: path}
+		if user, hostname, ok := strings.Cut(host, "@"); ok {
+			endpoint.User, endpoint.Host = url.User(user), hostname
+		}
+		return endpoint, nil
+	}
+
+	return &Endpoint{Scheme: "file", Path: raw}, nil
+}
+
+// String renders the endpoint as a URL without its password.
+func (e *Endpoint) String() string {
+	if e.Scheme == "file" {
+		return e.Path
+	}
+
+	host := e.Host
+	if e.Port != "" {
+		host += ":" + e.Port
+	}
+	if e.User != nil {
+		host = e.User.Username() + "@" + host
+	}
+	return fmt.Sprintf("%s://%s%s", e.Scheme, host, e.Path)
+}
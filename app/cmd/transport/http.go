@@ -0,0 +1,283 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// gzipThreshold is the request size above which upload-pack requests
+	// are compressed, as git does; smaller ones gain nothing.
+	gzipThreshold = 1024
+	// retryBaseDelay is the wait before the first retry, doubled each time.
+	retryBaseDelay = 500 * time.Millisecond
+	// maxRetryDelay caps the wait between retries, including one asked for
+	// by a Retry-After header.
+	maxRetryDelay = 30 * time.Second
+)
+
+// HTTPOptions configures the smart HTTP client.
+type HTTPOptions struct {
+	Proxy      string        // Proxy URL; empty uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+	CAInfo     string        // File of PEM certificates to trust instead of the system roots.
+	NoVerify   bool          // Skip verification of the server certificate.
+	Timeout    time.Duration // Limit on connecting and on waiting for a response; 0 for none.
+	MaxRetries int           // Retries of idempotent requests after a transient failure.
+	Header     http.Header   // Sent with every request, e.g. Authorization.
+}
+
+// newHTTPClient builds the client for one session from opts.
+func newHTTPClient(opts HTTPOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.Proxy != "" {
+		proxy := opts.Proxy
+		// Like git, a proxy without a scheme is an HTTP proxy.
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy '%s': %w", opts.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if opts.CAInfo != "" || opts.NoVerify {
+		config := &tls.Config{InsecureSkipVerify: opts.NoVerify}
+		if opts.CAInfo != "" {
+			pem, err := os.ReadFile(opts.CAInfo)
+			if err != nil {
+				return nil, fmt.Errorf("could not read CA file: %w", err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA file '%s'", opts.CAInfo)
+			}
+		}
+		transport.TLSClientConfig = config
+	}
+
+	if opts.Timeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: opts.Timeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = opts.Timeout
+		transport.ResponseHeaderTimeout = opts.Timeout
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// httpRequest describes a request that may be sent more than once, so
+// its body is kept in memory.
+type httpRequest struct {
+	method string
+	url    string
+	header http.Header
+	body   []byte
+	retry  bool // Whether the request is idempotent and may be retried.
+}
+
+// doHTTP sends a request with the headers of opts added. An idempotent
+// request is retried up to opts.MaxRetries times after network errors and
+// after the statuses that announce a temporary problem, waiting longer
+// each time or as long as Retry-After asks.
+//
+// Returns:
+// - The response of the last attempt; the caller closes its body.
+// - An error if the last attempt could not be sent.
+func doHTTP(client *http.Client, request httpRequest, opts HTTPOptions) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(request.method, request.url, bytes.NewReader(request.body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header = request.header.Clone()
+		for name, values := range opts.Header {
+			req.Header[name] = values
+		}
+
+		resp, err := client.Do(req)
+		if !request.retry || attempt >= opts.MaxRetries || !transientFailure(resp, err) {
+			return resp, err
+		}
+
+		delay := min(retryBaseDelay<<attempt, maxRetryDelay)
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				delay = min(time.Duration(seconds)*time.Second, maxRetryDelay)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		time.Sleep(delay)
+	}
+}
+
+// transientFailure reports whether a failed attempt may succeed when sent
+// again: the connection failed, or the server is overloaded or restarting.
+// An unknown host or an untrusted certificate will not go away.
+func transientFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		var dnsErr *net.DNSError
+		var certErr *tls.CertificateVerificationError
+		return !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) && !errors.As(err, &certErr)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// gzipBody compresses a request body.
+func gzipBody(body []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// httpSession turns the request/response exchanges of smart HTTP into a
+// stream: writes are buffered and sent as one POST when the caller starts
+// reading the answer.
+type httpSession struct {
+	client   *http.Client
+	opts     HTTPOptions
+	url      string
+	service  string
+	request  bytes.Buffer
+	response io.ReadCloser
+}
+
+// dialHTTP fetches the advertisement from <url>/info/refs.
+func dialHTTP(endpoint *Endpoint, service string, opts HTTPOptions) (*Session, error) {
+	host := endpoint.Host
+	if endpoint.Port != "" {
+		host = net.JoinHostPort(endpoint.Host, endpoint.Port)
+	}
+	base := (&url.URL{Scheme: endpoint.Scheme, User: endpoint.User, Host: host, Path: endpoint.Path}).String()
+
+	client, err := newHTTPClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doHTTP(client, httpRequest{
+		method: http.MethodGet,
+		url:    base + "/info/refs?service=" + service,
+		header: http.Header{"User-Agent": {httpUserAgent}},
+		retry:  true,
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to access '%s': %s", endpoint, resp.Status)
+	}
+	if resp.Header.Get("Content-Type") != fmt.Sprintf("application/x-%s-advertisement", service) {
+		return nil, fmt.Errorf("'%s' does not speak the smart http protocol", endpoint)
+	}
+
+	dec := NewDecoder(resp.Body)
+	if _, payload, err := dec.Read(); err != nil || strings.TrimSuffix(string(payload), "\n") != "# service="+service {
+		return nil, fmt.Errorf("protocol error: missing service announcement from '%s'", endpoint)
+	}
+	if packetType, _, err := dec.Read(); err != nil || packetType != FlushPacket {
+		return nil, fmt.Errorf("protocol error: malformed service announcement from '%s'", endpoint)
+	}
+
+	advertisement, err := ReadAdvertisement(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &httpSession{client: client, opts: opts, url: base + "/" + service, service: service}
+	return &Session{
+		Advertisement: advertisement,
+		Stateless:     true,
+		r:             stream,
+		w:             &stream.request,
+		close:         stream.Close,
+	}, nil
+}
+
+// Read posts any buffered request and reads from its response.
+func (h *httpSession) Read(p []byte) (int, error) {
+	if h.request.Len() > 0 {
+		if err := h.post(); err != nil {
+			return 0, err
+		}
+	}
+	if h.response == nil {
+		return 0, io.EOF
+	}
+	return h.response.Read(p)
+}
+
+// post sends the buffered request and replaces the current response.
+// Fetch requests are compressed when large and retried on transient
+// failures, since upload-pack changes nothing; pushes are sent once.
+func (h *httpSession) post() error {
+	if h.response != nil {
+		h.response.Close()
+		h.response = nil
+	}
+
+	request := httpRequest{
+		method: http.MethodPost,
+		url:    h.url,
+		header: http.Header{
+			"User-Agent":   {httpUserAgent},
+			"Content-Type": {fmt.Sprintf("application/x-%s-request", h.service)},
+			"Accept":       {fmt.Sprintf("application/x-%s-result", h.service)},
+		},
+		body:  bytes.Clone(h.request.Bytes()),
+		retry: h.service == "git-upload-pack",
+	}
+	h.request.Reset()
+
+	if request.retry && len(request.body) > gzipThreshold {
+		compressed, err := gzipBody(request.body)
+		if err != nil {
+			return err
+		}
+		request.body = compressed
+		request.header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := doHTTP(h.client, request, h.opts)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return fmt.Errorf("rpc to '%s' failed: %s", h.url, resp.Status)
+	}
+	h.response = resp.Body
+	return nil
+}
+
+// Close releases the last response.
+func (h *httpSession) Close() error {
+	if h.response != nil {
+		return h.response.Close()
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+// Package transport implements the wire formats shared by the git protocol
+// transports, pkt-line framing and side-band multiplexing, and the client
+// side of the local, ssh, git:// and smart HTTP transports.
+package transport
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxPacketSize is the largest pkt-line, including its four byte header.
+	MaxPacketSize = 65520
+	// MaxPayloadSize is the largest payload a single pkt-line can carry.
+	MaxPayloadSize = MaxPacketSize - 4
+)
+
+// PacketType distinguishes data packets from the special zero-length packets.
+type PacketType int
+
+const (
+	DataPacket        PacketType = iota // A packet carrying a payload.
+	FlushPacket                         // "0000", ends a section of the conversation.
+	DelimPacket                         // "0001", separates sections of a protocol v2 message.
+	ResponseEndPacket                   // "0002", ends a stateless protocol v2 response.
+)
+
+// String returns the name of the packet type, for error messages.
+func (t PacketType) String() string {
+	switch t {
+	case DataPacket:
+		return "data"
+	case FlushPacket:
+		return "flush"
+	case DelimPacket:
+		return "delim"
+	case ResponseEndPacket:
+		return "response-end"
+	default:
+		return fmt.Sprintf("packet type %d", int(t))
+	}
+}
+
+// Encoder writes pkt-lines to an underlying writer.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder creates an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes data as a single pkt-line.
+func (e *Encoder) Encode(data []byte) error {
+	if len(data) > MaxPayloadSize {
+		return fmt.Errorf("pkt-line payload of %d bytes exceeds %d", len(data), MaxPayloadSize)
+	}
+
+	if _, err := fmt.Fprintf(e.w, "%04x", len(data)+4); err != nil {
+		return err
+	}
+	_, err := e.w.Write(data)
+	return err
+}
+
+// Encodef formats a pkt-line payload and writes it.
+func (e *Encoder) Encodef(format string, args ...any) error {
+	return e.Encode([]byte(fmt.Sprintf(format, args...)))
+}
+
+// Flush writes a flush packet.
+func (e *Encoder) Flush() error {
+	_, err := io.WriteString(e.w, "0000")
+	return err
+}
+
+// Delim writes a delimiter packet.
+func (e *Encoder) Delim() error {
+	_, err := io.WriteString(e.w, "0001")
+	return err
+}
+
+// ResponseEnd writes a response-end packet.
+func (e *Encoder) ResponseEnd() error {
+	_, err := io.WriteString(e.w, "0002")
+	return err
+}
+
+// Decoder reads pkt-lines from an underlying reader.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder creates a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Read reads the next packet and returns its type and payload. io.EOF is
+// returned when the stream ends cleanly between packets.
+func (d *Decoder) Read() (PacketType, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated pkt-line header")
+		}
+		return 0, nil, err
+	}
+
+	length, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid pkt-line length '%s'", header)
+	}
+
+	switch length {
+	case 0:
+		return FlushPacket, nil, nil
+	case 1:
+		return DelimPacket, nil, nil
+	case 2:
+		return ResponseEndPacket, nil, nil
+	case 3:
+		return 0, nil, fmt.Errorf("unsupported special pkt-line '%s'", header)
+	}
+	if length > MaxPacketSize {
+		return 0, nil, fmt.Errorf("pkt-line length %d exceeds %d", length, MaxPacketSize)
+	}
+
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		return 0, nil, fmt.Errorf("truncated pkt-line: %w", err)
+	}
+	return DataPacket, payload, nil
+}
+
+// Side-band channels used to multiplex a pack with progress and errors.
+const (
+	SidebandData     = 1
+	SidebandProgress = 2
+	SidebandError    = 3
+)
+
+// SidebandWriter writes everything it is given to one side-band channel,
+// splitting it into pkt-lines no larger than the negotiated maximum.
+type SidebandWriter struct {
+	enc     *Encoder
+	channel byte
+	max     int
+}
+
+// NewSidebandWriter creates a writer for the given channel. maxPacket is the
+// packet size agreed with the peer: 1000 for side-band, 65520 for side-band-64k.
+func NewSidebandWriter(enc *Encoder, channel byte, maxPacket int) *SidebandWriter {
+	return &SidebandWriter{enc: enc, channel: channel, max: maxPacket - 5}
+}
+
+// Write sends p on the side-band channel.
+func (s *SidebandWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > s.max {
+			chunk = chunk[:s.max]
+		}
+
+		if err := s.enc.Encode(append([]byte{s.channel}, chunk...)); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// SidebandReader demultiplexes a side-band stream: Read returns what the
+// peer sends on the data channel, progress messages are copied to a writer
+// and a message on the error channel ends the stream with an error. The
+// stream ends with io.EOF at the flush packet after the data.
+type SidebandReader struct {
+	dec      *Decoder
+	progress io.Writer
+	pending  []byte
+	err      error
+}
+
+// NewSidebandReader creates a reader for the side-band stream dec is
+// positioned at. progress receives the progress channel; io.Discard drops
+// it.
+func NewSidebandReader(dec *Decoder, progress io.Writer) *SidebandReader {
+	return &SidebandReader{dec: dec, progress: progress}
+}
+
+// Read reads data from the data channel.
+func (s *SidebandReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.pending, s.err = s.next()
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// next reads packets until one carries data, and returns that data.
+func (s *SidebandReader) next() ([]byte, error) {
+	for {
+		packetType, payload, err := s.dec.Read()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if packetType == FlushPacket {
+			return nil, io.EOF
+		}
+		if packetType != DataPacket || len(payload) == 0 {
+			return nil, fmt.Errorf("unexpected %s packet in side-band stream", packetType)
+		}
+
+		switch channel, data := payload[0], payload[1:]; channel {
+		case SidebandData:
+			if len(data) > 0 {
+				return data, nil
+			}
+		case SidebandProgress:
+			if _, err := s.progress.Write(data); err != nil {
+				return nil, err
+			}
+		case SidebandError:
+			return nil, fmt.Errorf("remote error: %s", strings.TrimSpace(string(data)))
+		default:
+			return nil, fmt.Errorf("unknown side-band channel %d", channel)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	payloads := [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("want 0123456789012345678901234567890123456789\n"),
+		[]byte("binary\x00\x01\xff"),
+		bytes.Repeat([]byte("x"), MaxPayloadSize),
+	}
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream)
+	for _, payload := range payloads {
+		if err := enc.Encode(payload); err != nil {
+			t.Fatalf("Encode(%d bytes): %v", len(payload), err)
+		}
+	}
+
+	dec := NewDecoder(&stream)
+	for _, want := range payloads {
+		packetType, got, err := dec.Read()
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if packetType != DataPacket || !bytes.Equal(got, want) {
+			t.Fatalf("Read() = %s %q, want data %q", packetType, truncate(got), truncate(want))
+		}
+	}
+	if _, _, err := dec.Read(); err != io.EOF {
+		t.Fatalf("Read at the end of the stream = %v, want io.EOF", err)
+	}
+}
+
+func TestEncodeWireFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(*Encoder) error
+		want  string
+	}{
+		{"data", func(e *Encoder) error { return e.Encode([]byte("hello\n")) }, "000ahello\n"},
+		{"empty data", func(e *Encoder) error { return e.Encode(nil) }, "0004"},
+		{"formatted", func(e *Encoder) error { return e.Encodef("unpack %s\n", "ok") }, "000eunpack ok\n"},
+		{"flush", (*Encoder).Flush, "0000"},
+		{"delim", (*Encoder).Delim, "0001"},
+		{"response end", (*Encoder).ResponseEnd, "0002"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tt.write(NewEncoder(&out)); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("wrote %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeRejectsOversizedPayload(t *testing.T) {
+	var out bytes.Buffer
+	if err := NewEncoder(&out).Encode(make([]byte, MaxPayloadSize+1)); err == nil {
+		t.Fatal("Encode accepted a payload larger than MaxPayloadSize")
+	}
+	if out.Len() != 0 {
+		t.Fatalf("Encode wrote %d bytes for a rejected payload", out.Len())
+	}
+}
+
+func TestDecodeSpecialPackets(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("0008abcd000000010002"))
+	want := []struct {
+		packetType PacketType
+		payload    string
+	}{
+		{DataPacket, "abcd"},
+		{FlushPacket, ""},
+		{DelimPacket, ""},
+		{ResponseEndPacket, ""},
+	}
+	for _, w := range want {
+		packetType, payload, err := dec.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if packetType != w.packetType || string(payload) != w.payload {
+			t.Fatalf("Read() = %s %q, want %s %q", packetType, payload, w.packetType, w.payload)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"truncated header", "00"},
+		{"truncated payload", "000ahel"},
+		{"header without payload", "0005"},
+		{"non-hex length", "00zz"},
+		{"signed length", "+00a"},
+		{"reserved special packet", "0003"},
+		{"length above the maximum", "fff1" + strings.Repeat("x", 0xfff1-4)},
+		{"largest length", "ffff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packetType, payload, err := NewDecoder(strings.NewReader(tt.input)).Read()
+			if err == nil || err == io.EOF {
+				t.Fatalf("Read() = %s %q, %v, want an error", packetType, truncate(payload), err)
+			}
+		})
+	}
+}
+
+func TestDecodeLargestPacket(t *testing.T) {
+	input := "fff0" + strings.Repeat("x", MaxPayloadSize)
+	_, payload, err := NewDecoder(strings.NewReader(input)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != MaxPayloadSize {
+		t.Fatalf("payload of %d bytes, want %d", len(payload), MaxPayloadSize)
+	}
+}
+
+func TestSidebandWriterSplitsPackets(t *testing.T) {
+	var stream bytes.Buffer
+	data := bytes.Repeat([]byte("0123456789"), 250)
+	n, err := NewSidebandWriter(NewEncoder(&stream), SidebandData, 1000).Write(data)
+	if err != nil || n != len(data) {
+		t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(data))
+	}
+
+	dec := NewDecoder(&stream)
+	var sizes []int
+	var got []byte
+	for {
+		_, payload, err := dec.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload[0] != SidebandData {
+			t.Fatalf("packet on channel %d, want %d", payload[0], SidebandData)
+		}
+		sizes = append(sizes, len(payload)+4)
+		got = append(got, payload[1:]...)
+	}
+	for _, size := range sizes {
+		if size > 1000 {
+			t.Errorf("packet of %d bytes exceeds the side-band limit of 1000", size)
+		}
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data read back differs from the data written")
+	}
+}
+
+// sidebandStream encodes packets on the given channels followed by a flush.
+func sidebandStream(t *testing.T, packets ...string) *Decoder {
+	t.Helper()
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream)
+	for _, packet := range packets {
+		if err := enc.Encode([]byte(packet)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return NewDecoder(&stream)
+}
+
+func TestSidebandReaderDemultiplexes(t *testing.T) {
+	dec := sidebandStream(t,
+		"\x02Counting objects: 1\r",
+		"\x01PACK",
+		"\x01",
+		"\x02done.\n",
+		"\x01rest of the pack",
+	)
+	var progress bytes.Buffer
+	data, err := io.ReadAll(NewSidebandReader(dec, &progress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "PACKrest of the pack" {
+		t.Errorf("data = %q", data)
+	}
+	if progress.String() != "Counting objects: 1\rdone.\n" {
+		t.Errorf("progress = %q", progress.String())
+	}
+}
+
+func TestSidebandReaderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dec     func(t *testing.T) *Decoder
+		wantErr string
+	}{
+		{
+			name:    "error channel",
+			dec:     func(t *testing.T) *Decoder { return sidebandStream(t, "\x01PA", "\x03access denied\n") },
+			wantErr: "remote error: access denied",
+		},
+		{
+			name:    "unknown channel",
+			dec:     func(t *testing.T) *Decoder { return sidebandStream(t, "\x04what") },
+			wantErr: "unknown side-band channel 4",
+		},
+		{
+			name:    "empty packet",
+			dec:     func(t *testing.T) *Decoder { return sidebandStream(t, "") },
+			wantErr: "unexpected data packet",
+		},
+		{
+			name:    "delim packet",
+			dec:     func(*testing.T) *Decoder { return NewDecoder(strings.NewReader("0006\x01a0001")) },
+			wantErr: "unexpected delim packet",
+		},
+		{
+			name:    "stream ends before the flush",
+			dec:     func(*testing.T) *Decoder { return NewDecoder(strings.NewReader("0006\x01a")) },
+			wantErr: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:    "truncated packet",
+			dec:     func(*testing.T) *Decoder { return NewDecoder(strings.NewReader("0009\x01a")) },
+			wantErr: "truncated pkt-line",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(NewSidebandReader(tt.dec(t), io.Discard))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ReadAll error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSidebandReaderKeepsReturningTheError(t *testing.T) {
+	reader := NewSidebandReader(sidebandStream(t, "\x03boom"), io.Discard)
+	for range 2 {
+		if _, err := reader.Read(make([]byte, 8)); err == nil || errors.Is(err, io.EOF) {
+			t.Fatalf("Read = %v, want the remote error", err)
+		}
+	}
+}
+
+// truncate shortens a payload for error messages.
+func truncate(payload []byte) []byte {
+	if len(payload) > 32 {
+		return payload[:32]
+	}
+	return payload
+}
@@ -0,0 +1,38 @@
+package transport
+
+import "strings"
+
+// URLRewrite replaces a prefix of remote URLs, as git's
+// url.<base>.insteadOf and url.<base>.pushInsteadOf do: a URL starting
+// with InsteadOf has that prefix replaced by Base.
+type URLRewrite struct {
+	Base      string
+	InsteadOf string
+}
+
+// RewriteURL applies the rewrite whose InsteadOf is the longest prefix of
+// raw. Of rewrites with equally long prefixes, the first one wins. The
+// result is not rewritten again.
+//
+// Parameters:
+// - raw: The URL as configured or given by the user.
+// - rewrites: The rewrites, in the order they were configured.
+//
+// Returns:
+// - The rewritten URL, or raw when no rewrite matches.
+// - Whether a rewrite matched.
+func RewriteURL(raw string, rewrites []URLRewrite) (string, bool) {
+	best := -1
+	for i, rewrite := range rewrites {
+		if !strings.HasPrefix(raw, rewrite.InsteadOf) {
+			continue
+		}
+		if best < 0 || len(rewrite.InsteadOf) > len(rewrites[best].InsteadOf) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return raw, false
+	}
+	return rewrites[best].Base + raw[len(rewrites[best].InsteadOf):], true
+}
@@ -0,0 +1,51 @@
+package transport
+
+import "testing"
+
+func TestRewriteURL(t *testing.T) {
+	insteadOf := []URLRewrite{
+		{Base: "https://github.com/", InsteadOf: "gh:"},
+		{Base: "git@github.com:", InsteadOf: "gh:private/"},
+		{Base: "https://mirror.example.com/", InsteadOf: "https://example.com/"},
+		{Base: "https://mirror.example.com/big/", InsteadOf: "https://example.com/big/"},
+		{Base: "https://first.example.org/", InsteadOf: "org:"},
+		{Base: "https://second.example.org/", InsteadOf: "org:"},
+		{Base: "ssh://git@example.net/", InsteadOf: "https://example.net/"},
+		{Base: "https://example.net/", InsteadOf: "ssh://git@example.net/"},
+	}
+	pushInsteadOf := []URLRewrite{
+		{Base: "ssh://git@example.com/", InsteadOf: "https://example.com/"},
+		{Base: "ssh://git@example.com/team/", InsteadOf: "https://example.com/team/"},
+	}
+
+	tests := []struct {
+		name     string
+		raw      string
+		rewrites []URLRewrite
+		want     string
+		wantOK   bool
+	}{
+		{"short alias", "gh:user/repo", insteadOf, "https://github.com/user/repo", true},
+		{"longest prefix wins", "gh:private/repo", insteadOf, "git@github.com:repo", true},
+		{"longest prefix wins whatever the order", "https://example.com/big/repo", insteadOf, "https://mirror.example.com/big/repo", true},
+		{"shorter prefix when the longer does not match", "https://example.com/small/repo", insteadOf, "https://mirror.example.com/small/repo", true},
+		{"first of equally long prefixes", "org:repo", insteadOf, "https://first.example.org/repo", true},
+		{"whole URL as prefix", "https://example.com/", insteadOf, "https://mirror.example.com/", true},
+		{"rewritten once only", "https://example.net/repo", insteadOf, "ssh://git@example.net/repo", true},
+		{"prefix must start the URL", "see gh:user/repo", insteadOf, "see gh:user/repo", false},
+		{"prefix is case sensitive", "GH:user/repo", insteadOf, "GH:user/repo", false},
+		{"no match", "https://gitlab.com/user/repo", insteadOf, "https://gitlab.com/user/repo", false},
+		{"no rewrites", "gh:user/repo", nil, "gh:user/repo", false},
+		{"push rewrite", "https://example.com/repo", pushInsteadOf, "ssh://git@example.com/repo", true},
+		{"longest push rewrite", "https://example.com/team/repo", pushInsteadOf, "ssh://git@example.com/team/repo", true},
+		{"push rewrites ignore other URLs", "gh:user/repo", pushInsteadOf, "gh:user/repo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RewriteURL(tt.raw, tt.rewrites)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("RewriteURL(%q) = %q, %v, want %q, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Tree entry modes as written by git.
+const (
+	ModeTree       = "40000"
+	ModeBlob       = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+	ModeGitlink    = "160000"
+)
+
+// TreeEntry is a single entry of a tree object.
+type TreeEntry struct {
+	Mode string // The file mode, e.g. 100644 or 40000.
+	Name string // The entry name, without any directory component.
+	SHA  string // The object the entry points to.
+}
+
+// IsTree reports whether the entry is a subdirectory.
+func (e TreeEntry) IsTree() bool {
+	return e.Mode == ModeTree || e.Mode == "040000"
+}
+
+// IsGitlink reports whether the entry is a submodule commit, which lives in
+// another repository.
+func (e TreeEntry) IsGitlink() bool {
+	return e.Mode == ModeGitlink
+}
+
+// ReadTree reads and parses the tree with the given SHA-1.
+func (m *ObjectManager) ReadTree(sha string) ([]TreeEntry, error) {
+	objType, data, err := m.ReadObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != TreeType {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", sha, objType)
+	}
+	return parseTree(data)
+}
+
+// TreeEntryAt looks up a slash separated path below a tree. The empty path
+// names the tree itself.
+//
+// Parameters:
+// - tree: The SHA-1 of the root tree.
+// - path: The path of the entry, e.g. "app/main.go".
+//
+// Returns:
+// - The entry, named after the last path component.
+// - An error if a tree cannot be read or the path does not exist.
+func (m *ObjectManager) TreeEntryAt(tree, path string) (TreeEntry, error) {
+	entry := TreeEntry{Mode: ModeTree, SHA: tree}
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if !entry.IsTree() {
+			return TreeEntry{}, fmt.Errorf("path '%s' does not exist in '%s'", path, tree)
+		}
+		entries, err := m.ReadTree(entry.SHA)
+		if err != nil {
+			return TreeEntry{}, err
+		}
+		i := slices.IndexFunc(entries, func(e TreeEntry) bool { return e.Name == name })
+		if i < 0 {
+			return TreeEntry{}, fmt.Errorf("path '%s' does not exist in '%s'", path, tree)
+		}
+		entry = entries[i]
+	}
+	return entry, nil
+}
+
+// maxSymlinkFollows is how many symlinks FollowTreePath follows before it
+// takes the path for a loop, as git does.
+const maxSymlinkFollows = 40
+
+// PathStatus says why FollowTreePath found no entry, in the words of
+// cat-file --follow-symlinks.
+type PathStatus string
+
+const (
+	PathFound    PathStatus = ""
+	PathMissing  PathStatus = "missing"  // The path does not exist.
+	PathDangling PathStatus = "dangling" // The path does not exist once a symlink was followed.
+	PathLoop     PathStatus = "loop"     // Too many symlinks were followed.
+	PathNotDir   PathStatus = "notdir"   // A component before the last is not a directory.
+	PathOutside  PathStatus = "symlink"  // A symlink leads out of the tree.
+)
+
+// FollowedPath is the outcome of FollowTreePath.
+type FollowedPath struct {
+	Entry  TreeEntry  // The entry found, when Status is PathFound.
+	Status PathStatus // Why no entry was found.
+	Target string     // For PathOutside: where the symlink leads, an absolute path or one starting with "..".
+}
+
+// FollowTreePath looks up a slash separated path below a tree like
+// TreeEntryAt, but follows the symlinks of the tree on the way, the last
+// component included, and resolves ".." against the directories walked.
+// A symlink with an absolute target, or a ".." above the root, leads out
+// of the tree and ends the walk.
+//
+// Parameters:
+// - tree: The SHA-1 of the root tree.
+// - path: The path of the entry, e.g. "docs/current/index.md".
+//
+// Returns:
+// - The entry found, or why there is none.
+// - An error if a tree or a symlink cannot be read.
+func (m *ObjectManager) FollowTreePath(tree, path string) (FollowedPath, error) {
+	dirs := []TreeEntry{{Mode: ModeTree, SHA: tree}} // From the root to the current directory.
+	names := strings.Split(path, "/")
+	links := 0
+	for len(names) > 0 {
+		name := names[0]
+		names = names[1:]
+		switch name {
+		case "":
+			continue
+		case "..":
+			if len(dirs) == 1 {
+				return FollowedPath{Status: PathOutside, Target: strings.Join(append([]string{".."}, names...), "/")}, nil
+			}
+			dirs = dirs[:len(dirs)-1]
+			continue
+		}
+
+		entries, err := m.ReadTree(dirs[len(dirs)-1].SHA)
+		if err != nil {
+			return FollowedPath{}, err
+		}
+		i := slices.IndexFunc(entries, func(e TreeEntry) bool { return e.Name == name })
+		if i < 0 {
+			if links > 0 {
+				return FollowedPath{Status: PathDangling}, nil
+			}
+			return FollowedPath{Status: PathMissing}, nil
+		}
+
+		entry := entries[i]
+		switch {
+		case entry.IsTree():
+			dirs = append(dirs, entry)
+		case entry.Mode == ModeSymlink:
+			if links++; links > maxSymlinkFollows {
+				return FollowedPath{Status: PathLoop}, nil
+			}
+			_, target, err := m.ReadObject(entry.SHA)
+			if err != nil {
+				return FollowedPath{}, err
+			}
+			if bytes.HasPrefix(target, []byte("/")) {
+				return FollowedPath{Status: PathOutside, Target: string(target)}, nil
+			}
+			names = append(strings.Split(string(target), "/"), names...)
+		case len(names) > 0:
+			return FollowedPath{Status: PathNotDir}, nil
+		default:
+			return FollowedPath{Entry: entry}, nil
+		}
+	}
+	return FollowedPath{Entry: dirs[len(dirs)-1]}, nil
+}
+
+// parseTree decodes the binary "<mode> <name>\x00<20 byte sha>" entries of a tree.
+func parseTree(data []byte) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		if space < 0 {
+			return nil, fmt.Errorf("malformed tree entry mode")
+		}
+
+		nul := bytes.IndexByte(data[space:], 0)
+		if nul < 0 || space+nul+21 > len(data) {
+			return nil, fmt.Errorf("malformed tree entry")
+		}
+		nul += space
+
+		entries = append(entries, TreeEntry{
+			Mode: string(data[:space]),
+			Name: string(data[space+1 : nul]),
+			SHA:  hex.EncodeToString(data[nul+1 : nul+21]),
+		})
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// CompareTreeEntries orders tree entries the way git does: names are
+// compared byte by byte, and a directory compares as if its name ended with
+// "/". Only trees count as directories; symlinks and submodules sort like
+// files. Trees whose entries are in another order have a different SHA-1
+// than git would give them.
+//
+// Returns:
+// - A negative number if a sorts before b, a positive one if after, and 0
+// if they have the same name and kind.
+func CompareTreeEntries(a, b TreeEntry) int {
+	n := min(len(a.Name), len(b.Name))
+	if c := strings.Compare(a.Name[:n], b.Name[:n]); c != 0 {
+		return c
+	}
+	return cmp.Compare(treeNameByte(a, n), treeNameByte(b, n))
+}
+
+// treeNameByte returns the byte at position i of the name of an entry as
+// it is compared: past the end, a directory continues with "/".
+func treeNameByte(entry TreeEntry, i int) byte {
+	switch {
+	case i < len(entry.Name):
+		return entry.Name[i]
+	case entry.IsTree():
+		return '/'
+	}
+	return 0
+}
+
+// serializeTree encodes entries as tree object content in git's canonical order.
+func serializeTree(entries []TreeEntry) []byte {
+	sorted := slices.Clone(entries)
+	slices.SortFunc(sorted, CompareTreeEntries)
+
+	var buf bytes.Buffer
+	for _, entry := range sorted {
+		raw, _ := hex.DecodeString(entry.SHA)
+		buf.WriteString(strings.TrimPrefix(entry.Mode, "0"))
+		buf.WriteByte(' ')
+		buf.WriteString(entry.Name)
+		buf.WriteByte(0)
+		buf.Write(raw)
+	}
+	return buf.Bytes()
+}
+
+// WriteTree stores a tree object built from the given entries.
+func (m *ObjectManager) WriteTree(entries []TreeEntry) (string, error) {
+	return m.WriteObject(TreeType, serializeTree(entries), true)
+}
+
+// FlattenTree lists every non-tree entry reachable from a tree, keyed by its
+// slash separated path relative to the tree root.
+func (m *ObjectManager) FlattenTree(sha string) (map[string]TreeEntry, error) {
+	files := make(map[string]TreeEntry)
+	err := m.flattenTreeInto(sha, "", files)
+	return files, err
+}
+
+func (m *ObjectManager) flattenTreeInto(sha, prefix string, files map[string]TreeEntry) error {
+	entries, err := m.ReadTree(sha)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := prefix + entry.Name
+		if entry.IsTree() {
+			if err := m.flattenTreeInto(entry.SHA, path+"/", files); err != nil {
+				return err
+			}
+			continue
+		}
+		files[path] = entry
+	}
+	return nil
+}
+
+// WriteTreeFromFiles builds and stores the nested tree objects for a flat set
+// of files keyed by slash separated path, as returned by FlattenTree.
+//
+// Parameters:
+// - files: The files of the tree. The Name of each entry is ignored.
+//
+// Returns:
+// - The SHA-1 of the root tree.
+// - An error if a path is invalid or a tree cannot be written.
+func (m *ObjectManager) WriteTreeFromFiles(files map[string]TreeEntry) (string, error) {
+	type dir struct {
+		entries []TreeEntry
+		subdirs map[string]*dir
+	}
+	newDir := func() *dir { return &dir{subdirs: make(map[string]*dir)} }
+	root := newDir()
+
+	for path, entry := range files {
+		parts := strings.Split(path, "/")
+		current := root
+		for _, part := range parts[:len(parts)-1] {
+			if part == "" {
+				return "", fmt.Errorf("invalid path '%s'", path)
+			}
+			next, ok := current.subdirs[part]
+			if !ok {
+				next = newDir()
+				current.subdirs[part] = next
+			}
+			current = next
+		}
+
+		name := parts[len(parts)-1]
+		if name == "" {
+			return "", fmt.Errorf("invalid path '%s'", path)
+		}
+		current.entries = append(current.entries, TreeEntry{Mode: entry.Mode, Name: name, SHA: entry.SHA})
+	}
+
+	var write func(d *dir) (string, error)
+	write = func(d *dir) (string, error) {
+		entries := d.entries
+		for name, sub := range d.subdirs {
+			sha, err := write(sub)
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, TreeEntry{Mode: ModeTree, Name: name, SHA: sha})
+		}
+		return m.WriteTree(entries)
+	}
+	return write(root)
+}
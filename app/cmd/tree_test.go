@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// requireGit skips a test that cross-checks against git when git is not
+// installed.
+func requireGit(tb testing.TB) {
+	tb.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		tb.Skip("git is not installed")
+	}
+}
+
+// runGit runs git in dir and returns its output without the final newline.
+func runGit(tb testing.TB, dir, stdin string, args ...string) string {
+	tb.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		tb.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+func TestCompareTreeEntriesOrder(t *testing.T) {
+	blob := func(name string) TreeEntry { return TreeEntry{Mode: ModeBlob, Name: name} }
+	tree := func(name string) TreeEntry { return TreeEntry{Mode: ModeTree, Name: name} }
+	tests := []struct {
+		name string
+		a, b TreeEntry
+		want int
+	}{
+		{"file before longer names", blob("a"), blob("a-"), -1},
+		{"directory sorts as a/", tree("a"), blob("a-"), 1},
+		{"directory after a.b", tree("a"), blob("a.b"), 1},
+		{"directory before a0", tree("a"), blob("a0"), -1},
+		{"file a before directory a", blob("a"), tree("a"), -1},
+		{"same file", blob("a.b"), blob("a.b"), 0},
+		{"same directory", tree("a"), tree("a"), 0},
+		{"bytes, not runes", blob("Z"), blob("a"), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareTreeEntries(tt.a, tt.b); sign(got) != tt.want {
+				t.Errorf("CompareTreeEntries(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := CompareTreeEntries(tt.b, tt.a); sign(got) != -tt.want {
+				t.Errorf("CompareTreeEntries(%v, %v) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// TestWriteTreeMatchesGitMktree writes trees whose entries only sort right
+// when a directory is compared as if its name ended in "/", and checks that
+// git mktree, which sorts the entries itself, names them the same.
+func TestWriteTreeMatchesGitMktree(t *testing.T) {
+	requireGit(t)
+	repo := newTestRepo(t)
+	objects := NewObjectManager(repo)
+
+	blob, err := objects.WriteObject(BlobType, []byte("content\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtree, err := objects.WriteTree([]TreeEntry{{Mode: ModeBlob, Name: "x", SHA: blob}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		entries []TreeEntry
+	}{
+		{"file a", []TreeEntry{
+			{Mode: ModeBlob, Name: "a.b", SHA: blob},
+			{Mode: ModeBlob, Name: "a", SHA: blob},
+			{Mode: ModeBlob, Name: "a-", SHA: blob},
+		}},
+		{"directory a", []TreeEntry{
+			{Mode: ModeTree, Name: "a", SHA: subtree},
+			{Mode: ModeBlob, Name: "a.b", SHA: blob},
+			{Mode: ModeBlob, Name: "a-", SHA: blob},
+			{Mode: ModeBlob, Name: "a0", SHA: blob},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := objects.WriteTree(tt.entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var input strings.Builder
+			for _, entry := range tt.entries {
+				kind := BlobType
+				if entry.IsTree() {
+					kind = TreeType
+				}
+				fmt.Fprintf(&input, "%06s %s %s\t%s\n", entry.Mode, kind, entry.SHA, entry.Name)
+			}
+			if want := runGit(t, repo.WorkTree, input.String(), "mktree"); got != want {
+				t.Errorf("WriteTree = %s, git mktree = %s", got, want)
+			}
+		})
+	}
+}
+
+// TestWriteTreeFromFilesMatchesGitWriteTree stages a-, a.b and a/x with git
+// and checks that the tree git write-tree writes for them is the one
+// WriteTreeFromFiles writes.
+func TestWriteTreeFromFilesMatchesGitWriteTree(t *testing.T) {
+	requireGit(t)
+	repo := newTestRepo(t)
+	objects := NewObjectManager(repo)
+
+	files := make(map[string]TreeEntry)
+	for _, name := range []string{"a-", "a.b", "a/x", "a0", "a/b/c"} {
+		content := "content of " + name + "\n"
+		writeWorktreeFile(t, repo, name, content)
+		sha, err := objects.WriteObject(BlobType, []byte(content), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = TreeEntry{Mode: ModeBlob, SHA: sha}
+	}
+	got, err := objects.WriteTreeFromFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, repo.WorkTree, "", "add", ".")
+	if want := runGit(t, repo.WorkTree, "", "write-tree"); got != want {
+		t.Errorf("WriteTreeFromFiles = %s, git write-tree = %s", got, want)
+	}
+}
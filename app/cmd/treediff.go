@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ChangeStatus is the one-letter status git uses for a changed path.
+type ChangeStatus byte
+
+const (
+	StatusAdded      ChangeStatus = 'A'
+	StatusDeleted    ChangeStatus = 'D'
+	StatusModified   ChangeStatus = 'M'
+	StatusTypeChange ChangeStatus = 'T'
+	StatusRenamed    ChangeStatus = 'R'
+)
+
+// TreeChange is a path that differs between two sets of files. Old is the
+// zero TreeEntry for added paths and New for deleted ones. Renames, from
+// DetectRenames, keep the path the file had before in OldPath.
+type TreeChange struct {
+	Path    string
+	OldPath string
+	Status  ChangeStatus
+	Old     TreeEntry
+	New     TreeEntry
+	Score   int // How similar a renamed file stayed, in percent.
+}
+
+// SourcePath returns the path of the old side of a change, which differs
+// from Path for renames.
+func (c TreeChange) SourcePath() string {
+	if c.OldPath != "" {
+		return c.OldPath
+	}
+	return c.Path
+}
+
+// DiffFileSets compares two flat sets of files keyed by path, such as the
+// result of FlattenTree, and returns the changes sorted by path.
+func DiffFileSets(oldFiles, newFiles map[string]TreeEntry) []TreeChange {
+	var changes []TreeChange
+	for path, old := range oldFiles {
+		entry, ok := newFiles[path]
+		switch {
+		case !ok:
+			changes = append(changes, TreeChange{Path: path, Status: StatusDeleted, Old: old})
+		case modeKind(old.Mode) != modeKind(entry.Mode):
+			changes = append(changes, TreeChange{Path: path, Status: StatusTypeChange, Old: old, New: entry})
+		case old.SHA != entry.SHA || old.Mode != entry.Mode:
+			changes = append(changes, TreeChange{Path: path, Status: StatusModified, Old: old, New: entry})
+		}
+	}
+	for path, entry := range newFiles {
+		if _, ok := oldFiles[path]; !ok {
+			changes = append(changes, TreeChange{Path: path, Status: StatusAdded, New: entry})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// DiffTrees compares two trees recursively, skipping subtrees that did not
+// change. An empty SHA stands for the empty tree.
+//
+// Parameters:
+// - oldTree: The SHA-1 of the old tree, or "".
+// - newTree: The SHA-1 of the new tree, or "".
+//
+// Returns:
+// - The changed files, sorted by path.
+// - An error if a tree cannot be read.
+func (m *ObjectManager) DiffTrees(oldTree, newTree string) ([]TreeChange, error) {
+	oldFiles := make(map[string]TreeEntry)
+	newFiles := make(map[string]TreeEntry)
+	if err := m.collectTreeDiff(oldTree, newTree, "", oldFiles, newFiles); err != nil {
+		return nil, err
+	}
+	return DiffFileSets(oldFiles, newFiles), nil
+}
+
+// collectTreeDiff gathers the files of the two trees that may differ, so
+// DiffFileSets only has to look at changed subtrees.
+func (m *ObjectManager) collectTreeDiff(oldTree, newTree, prefix string, oldFiles, newFiles map[string]TreeEntry) error {
+	if oldTree == newTree {
+		return nil
+	}
+
+	readEntries := func(sha string) (map[string]TreeEntry, error) {
+		entries := make(map[string]TreeEntry)
+		if sha == "" {
+			return entries, nil
+		}
+		list, err := m.ReadTree(sha)
+		for _, entry := range list {
+			entries[entry.Name] = entry
+		}
+		return entries, err
+	}
+	oldEntries, err := readEntries(oldTree)
+	if err != nil {
+		return err
+	}
+	newEntries, err := readEntries(newTree)
+	if err != nil {
+		return err
+	}
+
+	subtree := func(entry TreeEntry, ok bool) string {
+		if ok && entry.IsTree() {
+			return entry.SHA
+		}
+		return ""
+	}
+	names := make(map[string]bool)
+	for name := range oldEntries {
+		names[name] = true
+	}
+	for name := range newEntries {
+		names[name] = true
+	}
+
+	for name := range names {
+		old, inOld := oldEntries[name]
+		entry, inNew := newEntries[name]
+		if inOld && inNew && old == entry {
+			continue
+		}
+
+		path := prefix + name
+		if inOld && !old.IsTree() {
+			oldFiles[path] = old
+		}
+		if inNew && !entry.IsTree() {
+			newFiles[path] = entry
+		}
+		oldSub, newSub := subtree(old, inOld), subtree(entry, inNew)
+		if oldSub != "" || newSub != "" {
+			if err := m.collectTreeDiff(oldSub, newSub, path+"/", oldFiles, newFiles); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IndexFiles returns the stage 0 entries of the index as a flat file set.
+func IndexFiles(index *Index) map[string]TreeEntry {
+	files := make(map[string]TreeEntry)
+	for _, entry := range index.Entries {
+		if entry.Stage() == 0 {
+			files[entry.Name] = entry.TreeEntry()
+		}
+	}
+	return files
+}
+
+// WorktreeFiles returns the current state of every file tracked by the
+// index. Files whose stat data matches the index keep the indexed SHA-1,
+// unless they are racily clean; the others are hashed without being written. Deleted files are left out.
+// Entries marked assume-unchanged or skip-worktree are not looked at and
+// keep their indexed state, as in git.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - index: The index listing the tracked files.
+// - eol: Converts the line endings of files before they are hashed.
+//
+// Returns:
+// - The tracked files present in the worktree.
+// - An error if a file cannot be read, or from the line ending conversion.
+func WorktreeFiles(repo *GitRepository, index *Index, eol *EOLConverter) (map[string]TreeEntry, error) {
+	scan := worktreeScan{
+		objects:          NewObjectManager(repo),
+		fileMode:         FileModeEnabled(repo),
+		symlinks:         SymlinksEnabled(repo),
+		bigFileThreshold: BigFileThreshold(repo),
+		eol:              eol,
+		racyAfter:        index.MTime,
+	}
+
+	files := make(map[string]TreeEntry)
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 {
+			continue
+		}
+		if entry.AssumeUnchanged() || entry.SkipWorktree() {
+			files[entry.Name] = entry.TreeEntry()
+			continue
+		}
+		current, ok, err := scan.entry(worktreePath(repo, entry.Name), entry)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			files[entry.Name] = current
+		}
+	}
+	return files, nil
+}
+
+// worktreeScan holds the settings for hashing worktree files.
+type worktreeScan struct {
+	objects          *ObjectManager
+	fileMode         bool // core.filemode
+	symlinks         bool // core.symlinks
+	bigFileThreshold int64
+	eol              *EOLConverter
+	// racyAfter is when the index was written. Files modified since are
+	// hashed even when their stat data matches.
+	racyAfter time.Time
+}
+
+// entry describes the worktree file of an index entry. The file is only
+// hashed when its size or modification time differ from the stat data in
+// the index or the entry is racily clean, and streamed without line ending conversion when it is larger
+// than bigFileThreshold. The executable bit is ignored without fileMode,
+// and a plain file stands in for a symlink without symlinks. ok is false
+// when the file is gone or replaced by a directory.
+func (s *worktreeScan) entry(file string, entry *IndexEntry) (TreeEntry, bool, error) {
+	indexMode := indexModeString(entry.Mode)
+	info, err := os.Lstat(file)
+	if os.IsNotExist(err) {
+		return TreeEntry{}, false, nil
+	}
+	if err != nil {
+		return TreeEntry{}, false, err
+	}
+
+	var mode string
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		mode = ModeSymlink
+	case !s.symlinks && indexMode == ModeSymlink && info.Mode().IsRegular():
+		// Without core.symlinks a symlink is checked out as a file holding its target.
+		mode = ModeSymlink
+	case info.IsDir():
+		// Submodule contents are not inspected.
+		if indexMode == ModeGitlink {
+			return TreeEntry{Mode: indexMode, SHA: entry.SHA}, true, nil
+		}
+		return TreeEntry{}, false, nil
+	case !s.fileMode && modeKind(indexMode) == "file":
+		mode = indexMode
+	case info.Mode()&0111 != 0:
+		mode = ModeExecutable
+	default:
+		mode = ModeBlob
+	}
+
+	if mode == indexMode && statMatches(entry, info) && !isRacyTimestamp(entry, s.racyAfter) {
+		return TreeEntry{Mode: mode, SHA: entry.SHA}, true, nil
+	}
+
+	if mode != ModeSymlink && info.Size() > s.bigFileThreshold {
+		reader, err := os.Open(file)
+		if err != nil {
+			return TreeEntry{}, false, err
+		}
+		defer reader.Close()
+
+		sha, err := s.objects.WriteBlobStream(reader, info.Size(), false)
+		if err != nil {
+			return TreeEntry{}, false, fmt.Errorf("hashing '%s': %w", file, err)
+		}
+		return TreeEntry{Mode: mode, SHA: sha}, true, nil
+	}
+
+	data, err := readWorktreeFile(file, mode)
+	if err != nil {
+		return TreeEntry{}, false, err
+	}
+	if mode != ModeSymlink {
+		if data, err = s.eol.ToGit(entry.Name, data); err != nil {
+			return TreeEntry{}, false, err
+		}
+	}
+	sha, err := s.objects.WriteObject(BlobType, data, false)
+	if err != nil {
+		return TreeEntry{}, false, err
+	}
+	return TreeEntry{Mode: mode, SHA: sha}, true, nil
+}
+
+// statMatches reports whether the size and modification time of a file
+// are those recorded in its index entry.
+func statMatches(entry *IndexEntry, info os.FileInfo) bool {
+	return uint32(info.Size()) == entry.Size && info.ModTime().Equal(entry.MTime)
+}
+
+// SmudgeRacyEntries prepares the entries of an index that is about to be
+// written at the given time. An entry modified at or after that time whose
+// stat data matches its file while the content does not would look clean
+// once the new index is newer than the file, so its size is set to 0. The
+// size never matches a non-empty file, forcing the content to be compared.
+// Entries whose content does match stay racily clean and are checked again
+// by later reads.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - index: The index to be written.
+// - written: When the index is written, usually now.
+//
+// Returns:
+// - An error if a file cannot be read.
+func SmudgeRacyEntries(repo *GitRepository, index *Index, written time.Time) error {
+	scan := worktreeScan{
+		objects:          NewObjectManager(repo),
+		fileMode:         FileModeEnabled(repo),
+		symlinks:         SymlinksEnabled(repo),
+		bigFileThreshold: BigFileThreshold(repo),
+		eol:              NewEOLConverter(repo),
+		racyAfter:        written,
+	}
+
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 || entry.Size == 0 || !isRacyTimestamp(entry, written) {
+			continue
+		}
+		file := worktreePath(repo, entry.Name)
+		info, err := os.Lstat(file)
+		if err != nil || !statMatches(entry, info) {
+			continue
+		}
+		current, ok, err := scan.entry(file, entry)
+		if err != nil {
+			return err
+		}
+		if ok && current.SHA != entry.SHA {
+			entry.Size = 0
+		}
+	}
+	return nil
+}
+
+// readWorktreeFile reads a file as git would store it: the target of a
+// symlink, or the file content. A symlink that was checked out as a plain
+// file, on systems without symlinks, already holds its target.
+func readWorktreeFile(file, mode string) ([]byte, error) {
+	if mode == ModeSymlink {
+		if info, err := os.Lstat(file); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(file)
+			return []byte(target), err
+		}
+	}
+	return os.ReadFile(file)
+}
+
+// Patches computes the patch of every change. Type changes are split into
+// a deletion and an addition, as git prints them. Blobs above
+// core.bigFileThreshold are treated as binary. Worktree content has its
+// line endings converted as it would be when added.
+//
+// Parameters:
+// - changes: The changes, e.g. from DiffTrees.
+// - worktree: When not empty, content that is not in the object database
+// is read from this directory.
+// - context: The number of context lines around each change.
+//
+// Returns:
+// - One patch per change, two for type changes.
+// - An error if some content cannot be read.
+func (m *ObjectManager) Patches(changes []TreeChange, worktree string, context int) ([]*FilePatch, error) {
+	threshold := BigFileThreshold(m.repo)
+	var eol *EOLConverter
+	if worktree != "" {
+		eol = NewEOLConverter(m.repo)
+	}
+
+	// A partial clone fetches the blobs it left out in one request. Content
+	// of the worktree side is not in the object database, nor on the remote.
+	var blobs []string
+	for _, change := range changes {
+		if !change.Old.IsGitlink() {
+			blobs = append(blobs, change.Old.SHA)
+		}
+		if worktree == "" && !change.New.IsGitlink() {
+			blobs = append(blobs, change.New.SHA)
+		}
+	}
+	if err := m.Prefetch(blobs); err != nil {
+		return nil, err
+	}
+
+	var patches []*FilePatch
+	for _, change := range changes {
+		if change.Status == StatusTypeChange {
+			removed := TreeChange{Path: change.Path, Status: StatusDeleted, Old: change.Old}
+			added := TreeChange{Path: change.Path, Status: StatusAdded, New: change.New}
+			split, err := m.Patches([]TreeChange{removed, added}, worktree, context)
+			if err != nil {
+				return nil, err
+			}
+			patches = append(patches, split...)
+			continue
+		}
+
+		// Big files are reported as binary without loading them.
+		if m.isBigBlob(change.Path, change.Old, worktree, threshold) || m.isBigBlob(change.Path, change.New, worktree, threshold) {
+			patches = append(patches, &FilePatch{
+				TreeChange: change,
+				Binary:     change.Old.SHA != change.New.SHA,
+				OldSize:    m.bigBlobSize(change.Path, change.Old, worktree),
+				NewSize:    m.bigBlobSize(change.Path, change.New, worktree),
+			})
+			continue
+		}
+
+		oldContent, err := m.diffContent(change.Path, change.Old, worktree, eol)
+		if err != nil {
+			return nil, err
+		}
+		newContent, err := m.diffContent(change.Path, change.New, worktree, eol)
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, NewFilePatch(change, oldContent, newContent, context))
+	}
+	return patches, nil
+}
+
+// diffContent loads the content of one side of a change.
+func (m *ObjectManager) diffContent(path string, entry TreeEntry, worktree string, eol *EOLConverter) ([]byte, error) {
+	switch {
+	case entry.SHA == "":
+		return nil, nil
+	case entry.IsGitlink():
+		return []byte(fmt.Sprintf("Subproject commit %s\n", entry.SHA)), nil
+	case worktree != "" && !m.Has(entry.SHA):
+		data, err := readWorktreeFile(filepath.Join(worktree, filepath.FromSlash(path)), entry.Mode)
+		if err != nil || entry.Mode == ModeSymlink {
+			return data, err
+		}
+		return eol.ToGit(path, data)
+	}
+
+	objType, data, err := m.ReadObject(entry.SHA)
+	if err != nil {
+		return nil, err
+	}
+	if objType != BlobType {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", entry.SHA, objType)
+	}
+	return data, nil
+}
@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	UndoDir          = "undo"    // The directory of the undo journal, in the git directory.
+	undoJournalFile  = "journal" // One line per recorded operation, oldest first.
+	defaultUndoLimit = 20        // Operations kept when undo.limit is not configured.
+)
+
+// UndoEntry is an operation recorded in the undo journal: where HEAD was
+// before and after it. The index of before is kept next to the journal.
+type UndoEntry struct {
+	ID       int       // Increasing number of the operation.
+	Command  string    // The command that made it, e.g. "commit".
+	Time     time.Time // When it finished.
+	OldRef   string    // The branch HEAD was on before, "" when detached.
+	OldHead  string    // The commit HEAD was at before, "" on an unborn branch.
+	NewRef   string    // The branch HEAD is on after.
+	NewHead  string    // The commit HEAD is at after.
+	Worktree bool      // The operation checked files out, as checkout and rebase do.
+}
+
+// UndoSnapshot is the state of HEAD and the index before a command that
+// changes them, taken by SnapshotForUndo.
+type UndoSnapshot struct {
+	repo     *GitRepository
+	command  string
+	worktree bool
+	head     Head
+	index    []byte // The index file; nil when there was none.
+}
+
+// SnapshotForUndo saves where HEAD is and the index before a command that
+// may move HEAD, such as commit, rebase or checkout. Once the command
+// succeeded, Record adds it to the undo journal so that Undo can take it
+// back.
+//
+// Parameters:
+// - repo: The repository.
+// - command: The name of the command, for the journal.
+// - worktree: Whether the command checks files out, so that undoing it
+// must check the files of before out again. Commands like commit, which
+// leave the worktree alone, only have HEAD and the index restored.
+//
+// Returns:
+// - The snapshot.
+// - An error if HEAD or the index cannot be read.
+func SnapshotForUndo(repo *GitRepository, command string, worktree bool) (*UndoSnapshot, error) {
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return nil, err
+	}
+	index, err := readFile(repo.fs, createRepoPath(repo, IndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &UndoSnapshot{repo: repo, command: command, worktree: worktree, head: head, index: index}, nil
+}
+
+// Record adds the operation to the undo journal, unless it changed
+// neither HEAD nor the index. Only the newest undo.limit operations, 20 by
+// default, are kept.
+//
+// Returns:
+// - An error if the journal or the index copy cannot be written.
+func (s *UndoSnapshot) Record() error {
+	repo := s.repo
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return err
+	}
+	index, err := readFile(repo.fs, createRepoPath(repo, IndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if head == s.head && bytes.Equal(index, s.index) {
+		return nil
+	}
+
+	entries, err := ReadUndoJournal(repo)
+	if err != nil {
+		return err
+	}
+	entry := UndoEntry{
+		ID:       1,
+		Command:  s.command,
+		Time:     time.Now(),
+		OldRef:   s.head.Ref,
+		OldHead:  s.head.SHA,
+		NewRef:   head.Ref,
+		NewHead:  head.SHA,
+		Worktree: s.worktree,
+	}
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+	if s.index != nil {
+		if err := writeFileAtomic(repo.fs, undoIndexPath(repo, entry.ID), s.index, 0644); err != nil {
+			return err
+		}
+	}
+
+	entries = append(entries, entry)
+	limit := defaultUndoLimit
+	if value, err := strconv.Atoi(lookupConfig(repo, "undo.limit")); err == nil && value > 0 {
+		limit = value
+	}
+	for len(entries) > limit {
+		repo.fs.Remove(undoIndexPath(repo, entries[0].ID))
+		entries = entries[1:]
+	}
+	return writeUndoJournal(repo, entries)
+}
+
+// ReadUndoJournal reads the operations recorded for undo, oldest first.
+func ReadUndoJournal(repo *GitRepository) ([]UndoEntry, error) {
+	path := createRepoPath(repo, UndoDir, undoJournalFile)
+	data, err := readFile(repo.fs, path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []UndoEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.SplitN(line, " ", 8)
+		if len(fields) != 8 {
+			return nil, fmt.Errorf("malformed undo journal line: %s", line)
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed undo journal line: %s", line)
+		}
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed undo journal line: %s", line)
+		}
+		entries = append(entries, UndoEntry{
+			ID:       id,
+			Time:     time.Unix(seconds, 0),
+			OldHead:  undoField(fields[2]),
+			NewHead:  undoField(fields[3]),
+			OldRef:   undoField(fields[4]),
+			NewRef:   undoField(fields[5]),
+			Worktree: fields[6] == "worktree",
+			Command:  fields[7],
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// writeUndoJournal replaces the undo journal with entries.
+func writeUndoJournal(repo *GitRepository, entries []UndoEntry) error {
+	field := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return value
+	}
+	var buf bytes.Buffer
+	for _, e := range entries {
+		scope := "index"
+		if e.Worktree {
+			scope = "worktree"
+		}
+		fmt.Fprintf(&buf, "%d %d %s %s %s %s %s %s\n", e.ID, e.Time.Unix(),
+			field(e.OldHead), field(e.NewHead), field(e.OldRef), field(e.NewRef), scope, e.Command)
+	}
+	return writeFileAtomic(repo.fs, createRepoPath(repo, UndoDir, undoJournalFile), buf.Bytes(), 0644)
+}
+
+// undoField reads an optional field of the journal, written as "-" when empty.
+func undoField(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// undoIndexPath returns where the index of before operation id is kept.
+func undoIndexPath(repo *GitRepository, id int) string {
+	return createRepoPath(repo, UndoDir, fmt.Sprintf("index-%d", id))
+}
+
+// UndoOptions controls Undo.
+type UndoOptions struct {
+	Force bool // Undo even if HEAD moved since, and overwrite local changes.
+}
+
+// UndoResult describes an undone operation. When Modified or Untracked
+// lists any path, the undo was refused and nothing was changed.
+type UndoResult struct {
+	Entry     UndoEntry
+	Updated   int      // Worktree files written or removed.
+	Modified  []string // Paths whose local changes the undo would overwrite.
+	Untracked []string // Untracked files the undo would overwrite.
+}
+
+// Undo takes back the last operation of the undo journal: HEAD goes back
+// to the branch and commit it was on and the index to what it was. If the
+// operation checked files out, the worktree files that differ between the
+// two indexes are checked out again, unless they have local changes. The
+// commits HEAD leaves stay in the reflog, where an "undo" entry is added.
+//
+// Parameters:
+// - repo: A repository with a worktree.
+// - opts: Whether to undo over later changes.
+//
+// Returns:
+// - The undone operation, or the paths that stopped it.
+// - An error if there is nothing to undo, HEAD moved since the operation
+// and opts.Force is not set, or a file cannot be read or written.
+func Undo(repo *GitRepository, opts UndoOptions) (*UndoResult, error) {
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	entries, err := ReadUndoJournal(repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	entry := entries[len(entries)-1]
+	result := &UndoResult{Entry: entry}
+
+	head, err := ResolveHEAD(repo)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Force && (head.Ref != entry.NewRef || head.SHA != entry.NewHead) {
+		return nil, fmt.Errorf("HEAD has moved since '%s'; use --force to undo it anyway", entry.Command)
+	}
+	var oldRefValue string
+	if entry.OldRef != "" && entry.OldRef != entry.NewRef {
+		oldRefValue, _ = ResolveRef(repo, entry.OldRef)
+		if !opts.Force && oldRefValue != entry.OldHead {
+			return nil, fmt.Errorf("'%s' has moved since '%s'; use --force to undo it anyway", entry.OldRef, entry.Command)
+		}
+	}
+
+	lock, current, err := LockIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	previous := &Index{Version: 2}
+	if data, err := readFile(repo.fs, undoIndexPath(repo, entry.ID)); err == nil {
+		if previous, err = parseIndex(data); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// The paths whose staged content differs, and whether their worktree
+	// files can be replaced.
+	eol := NewEOLConverter(repo)
+	var changed []string
+	if entry.Worktree {
+		worktree, err := WorktreeFiles(repo, current, eol)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, index := range []*Index{current, previous} {
+			for _, e := range index.Entries {
+				if seen[e.Name] {
+					continue
+				}
+				seen[e.Name] = true
+				before, after := previous.Entry(e.Name), current.Entry(e.Name)
+				if before != nil && after != nil && sameTreeEntry(before.TreeEntry(), after.TreeEntry()) {
+					continue
+				}
+				changed = append(changed, e.Name)
+				file, exists := worktree[e.Name]
+				switch {
+				case after != nil && (!exists || !sameTreeEntry(file, after.TreeEntry())):
+					result.Modified = append(result.Modified, e.Name)
+				case after == nil && pathExists(OSFileSystem{}, worktreePath(repo, e.Name)):
+					result.Untracked = append(result.Untracked, e.Name)
+				}
+			}
+		}
+	}
+	if !opts.Force && (len(result.Modified) > 0 || len(result.Untracked) > 0) {
+		return result, nil
+	}
+	result.Modified, result.Untracked = nil, nil
+
+	objects := NewObjectManager(repo)
+	for _, name := range changed {
+		before := previous.Entry(name)
+		if before == nil {
+			if err := removeWorktreeFile(repo, name); err != nil {
+				return nil, err
+			}
+			result.Updated++
+			continue
+		}
+		info, err := checkoutFile(repo, objects, eol, name, before.TreeEntry())
+		if err != nil {
+			return nil, err
+		}
+		refreshed := NewIndexEntry(name, before.TreeEntry().Mode, before.SHA, info)
+		refreshed.Flags, refreshed.ExtendedFlags = before.Flags, before.ExtendedFlags
+		previous.Add(refreshed)
+		result.Updated++
+	}
+	if err := lock.Write(previous); err != nil {
+		return nil, err
+	}
+
+	message := "undo: " + entry.Command
+	switch {
+	case entry.OldRef == "":
+		err = UpdateRef(repo, HeadFile, entry.OldHead, "")
+	case entry.OldHead == "":
+		if err = DeleteRef(repo, entry.OldRef); err == nil {
+			err = UpdateSymbolicRef(repo, HeadFile, entry.OldRef, "")
+		}
+	default:
+		if err = UpdateRef(repo, entry.OldRef, entry.OldHead, ""); err == nil {
+			err = appendReflog(repo, entry.OldRef, orZeroSHA(head.SHA), entry.OldHead, message)
+		}
+		if err == nil {
+			err = UpdateSymbolicRef(repo, HeadFile, entry.OldRef, "")
+		}
+	}
+	if err == nil && entry.OldHead != "" {
+		err = appendReflog(repo, HeadFile, orZeroSHA(head.SHA), entry.OldHead, message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	repo.fs.Remove(undoIndexPath(repo, entry.ID))
+	return result, writeUndoJournal(repo, entries[:len(entries)-1])
+}
+
+// orZeroSHA returns sha, or the null object name for an unborn branch.
+func orZeroSHA(sha string) string {
+	if sha == "" {
+		return zeroSHA
+	}
+	return sha
+}
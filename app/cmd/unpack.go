@@ -0,0 +1,540 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"slices"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// defaultUnpackLimit is git's default for transfer.unpackLimit.
+const defaultUnpackLimit = 100
+
+// UnpackOptions controls how UnpackObjects handles a pack stream.
+type UnpackOptions struct {
+	DryRun  bool           // Decode and verify the pack without writing any object.
+	Recover bool           // Keep going past corrupt entries and salvage what is left.
+	Strict  bool           // Check every object with CheckObject and write nothing unless all pass.
+	Into    *ObjectManager // Where objects are written, e.g. a quarantine; the repository when nil.
+}
+
+// UnpackResult summarizes a call to UnpackObjects.
+type UnpackResult struct {
+	Total    int      // Number of objects announced by the pack header.
+	Unpacked int      // Number of objects written (or verified in a dry run).
+	Objects  []string // Names of the objects unpacked, including those already present.
+	Errors   []error  // Problems that were skipped in recovery mode.
+
+	offsets   map[string]uint64 // Where each object starts in the pack.
+	thinBases []string          // Delta bases taken from the repository rather than the pack.
+}
+
+// packStreamEntry is an entry of a pack being exploded whose delta may not
+// have been resolved yet.
+type packStreamEntry struct {
+	offset  uint64
+	objType GitObjectType
+	data    []byte           // Object content, or the delta while unresolved.
+	baseSHA string           // Base of an unresolved ref delta.
+	base    *packStreamEntry // Unresolved base of an ofs delta.
+}
+
+// resolved reports whether the entry's content is known.
+func (e *packStreamEntry) resolved() bool {
+	return e.baseSHA == "" && e.base == nil
+}
+
+// UnpackLimit returns transfer.unpackLimit: packs with fewer objects than this
+// are exploded into loose objects instead of being stored as a pack.
+func UnpackLimit(repo *GitRepository) int {
+	if repo.Config().IsSet("transfer.unpackLimit") {
+		return repo.Config().GetInt("transfer.unpackLimit")
+	}
+	return defaultUnpackLimit
+}
+
+// StorePack stores a received pack as transfer.unpackLimit says: a pack
+// with fewer objects than UnpackLimit is exploded into loose objects by
+// UnpackObjects, a larger one is kept as it is and indexed by IndexPack.
+//
+// Parameters:
+// - repo: The repository receiving the objects.
+// - pack: The raw pack.
+// - opts: Strict and Into as for UnpackObjects.
+//
+// Returns:
+// - A summary of the objects stored.
+// - An error if the pack is corrupt or cannot be written.
+func StorePack(repo *GitRepository, pack []byte, opts UnpackOptions) (*UnpackResult, error) {
+	if len(pack) >= 12 && int(binary.BigEndian.Uint32(pack[8:12])) >= UnpackLimit(repo) {
+		_, result, err := IndexPack(repo, pack, opts)
+		return result, err
+	}
+	return UnpackObjects(repo, bytes.NewReader(pack), opts)
+}
+
+// IndexPack keeps a received pack as it is and writes its index next to it,
+// as git index-pack does. The objects are found and checked by a dry run of
+// UnpackObjects. A thin pack, whose deltas refer to objects of the
+// repository that it does not carry, is completed first by appending those
+// objects, so that the pack stands on its own.
+//
+// Parameters:
+// - repo: The repository receiving the pack.
+// - pack: The raw pack.
+// - opts: Strict and Into as for UnpackObjects.
+//
+// Returns:
+// - The name (pack checksum) of the stored pack.
+// - A summary of the objects in the pack.
+// - An error if the pack is corrupt or cannot be written.
+func IndexPack(repo *GitRepository, pack []byte, opts UnpackOptions) (string, *UnpackResult, error) {
+	defer trace.Start(trace.Pack, "index pack")()
+
+	result, err := UnpackObjects(repo, bytes.NewReader(pack), UnpackOptions{DryRun: true, Strict: opts.Strict, Into: opts.Into})
+	if err != nil {
+		return "", nil, err
+	}
+	objects := opts.Into
+	if objects == nil {
+		objects = NewObjectManager(repo)
+	}
+	if len(result.thinBases) > 0 {
+		if pack, err = completeThinPack(objects, pack, result); err != nil {
+			return "", nil, err
+		}
+	}
+
+	starts := make([]uint64, 0, len(result.offsets))
+	for _, offset := range result.offsets {
+		starts = append(starts, offset)
+	}
+	slices.Sort(starts)
+	ends := make(map[uint64]uint64, len(starts))
+	for i, start := range starts {
+		ends[start] = uint64(len(pack) - 20)
+		if i+1 < len(starts) {
+			ends[start] = starts[i+1]
+		}
+	}
+
+	entries := make([]packIndexEntry, 0, len(result.offsets))
+	for sha, offset := range result.offsets {
+		raw, _ := hex.DecodeString(sha)
+		entries = append(entries, packIndexEntry{sha: raw, offset: offset, crc: crc32.ChecksumIEEE(pack[offset:ends[offset]])})
+	}
+	name, err := objects.writePackFiles(pack, entries)
+	if err != nil {
+		return "", nil, err
+	}
+	trace.Log(trace.Pack, "indexed pack", "name", name, "objects", len(entries), "thin bases", len(result.thinBases))
+	return name, result, nil
+}
+
+// completeThinPack appends the delta bases a thin pack takes from the
+// repository to it as whole objects, fixing its object count and
+// checksum, and records where they start in result.
+//
+// Returns:
+// - The completed pack.
+// - An error if a base cannot be read.
+func completeThinPack(objects *ObjectManager, pack []byte, result *UnpackResult) ([]byte, error) {
+	var fixed bytes.Buffer
+	fixed.Write(pack[:len(pack)-20])
+	for _, sha := range result.thinBases {
+		objType, data, err := objects.ReadObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		result.offsets[sha] = uint64(fixed.Len())
+		fixed.Write(encodePackEntryHeader(packTypeCodes[objType], len(data)))
+		writer := zlib.NewWriter(&fixed)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	completed := fixed.Bytes()
+	binary.BigEndian.PutUint32(completed[8:12], uint32(result.Total+len(result.thinBases)))
+	checksum := sha1.Sum(completed)
+	return append(completed, checksum[:]...), nil
+}
+
+// UnpackObjects reads a pack from r and writes every object it contains as a
+// loose object. Ref deltas may refer to objects already in the repository, so
+// thin packs are supported.
+//
+// Parameters:
+// - repo: The repository receiving the objects.
+// - r: The pack stream.
+// - opts: Dry-run and recovery settings.
+//
+// Returns:
+// - A summary of the objects unpacked.
+// - An error if the pack is corrupt and recovery was not requested.
+func UnpackObjects(repo *GitRepository, r io.Reader, opts UnpackOptions) (*UnpackResult, error) {
+	defer trace.Start(trace.Pack, "unpack objects")()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UnpackResult{offsets: make(map[string]uint64)}
+	fail := func(err error) error {
+		if !opts.Recover {
+			return err
+		}
+		result.Errors = append(result.Errors, err)
+		return nil
+	}
+
+	if len(data) < 12+20 || string(data[:4]) != packSignature {
+		return nil, fmt.Errorf("bad pack header")
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+	result.Total = int(binary.BigEndian.Uint32(data[8:12]))
+
+	body, trailer := data[:len(data)-20], data[len(data)-20:]
+	if sum := sha1.Sum(body); !bytes.Equal(sum[:], trailer) {
+		if err := fail(fmt.Errorf("pack trailer checksum mismatch")); err != nil {
+			return nil, err
+		}
+	}
+
+	objects := opts.Into
+	if objects == nil {
+		objects = NewObjectManager(repo)
+	}
+	byOffset := make(map[uint64]*packStreamEntry)
+	bySHA := make(map[string]*packStreamEntry)
+	var pending []*packStreamEntry
+	var verified []*packStreamEntry
+
+	// In strict mode objects are only written once the whole pack has been
+	// checked, so a corrupt pack leaves nothing behind.
+	store := func(entry *packStreamEntry) error {
+		if opts.Strict {
+			if err := CheckObject(entry.objType, entry.data); err != nil {
+				return fail(fmt.Errorf("%s at offset %d: %w", entry.objType, entry.offset, err))
+			}
+		}
+		sha, err := objects.WriteObject(entry.objType, entry.data, !opts.DryRun && !opts.Strict)
+		if err != nil {
+			return err
+		}
+		bySHA[sha] = entry
+		result.offsets[sha] = entry.offset
+		result.Objects = append(result.Objects, sha)
+		if opts.Strict {
+			verified = append(verified, entry)
+		}
+		result.Unpacked++
+		return nil
+	}
+
+	reader := bytes.NewReader(body)
+	offset := uint64(12)
+	for i := 0; i < result.Total; i++ {
+		entry, next, err := readPackStreamEntry(reader, offset, byOffset)
+		if err != nil {
+			if err := fail(err); err != nil {
+				return nil, err
+			}
+			// The entry boundary is lost, nothing after it can be located.
+			break
+		}
+		offset = next
+		byOffset[entry.offset] = entry
+
+		if !entry.resolved() {
+			pending = append(pending, entry)
+			continue
+		}
+		if err := store(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ref deltas are resolved once their base is known, either from this pack
+	// or from the repository. Repeat until no more progress can be made.
+	for progress := true; progress && len(pending) > 0; {
+		progress = false
+		var unresolved []*packStreamEntry
+
+		for _, entry := range pending {
+			base, ok := bySHA[entry.baseSHA]
+			if entry.base != nil {
+				base, ok = entry.base, entry.base.resolved()
+			}
+
+			var baseType GitObjectType
+			var baseData []byte
+			if ok {
+				baseType, baseData = base.objType, base.data
+			} else if entry.base == nil && objects.Has(entry.baseSHA) {
+				baseType, baseData, err = objects.ReadObject(entry.baseSHA)
+				if err != nil {
+					return nil, err
+				}
+				if !slices.Contains(result.thinBases, entry.baseSHA) {
+					result.thinBases = append(result.thinBases, entry.baseSHA)
+				}
+			} else {
+				unresolved = append(unresolved, entry)
+				continue
+			}
+
+			resolved, err := applyDelta(baseData, entry.data)
+			if err != nil {
+				if err := fail(fmt.Errorf("delta at offset %d: %w", entry.offset, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			entry.objType, entry.data, entry.baseSHA, entry.base = baseType, resolved, "", nil
+			if err := store(entry); err != nil {
+				return nil, err
+			}
+			progress = true
+		}
+		pending = unresolved
+	}
+
+	for _, entry := range pending {
+		if err := fail(fmt.Errorf("missing delta base for entry at offset %d", entry.offset)); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Strict && !opts.DryRun {
+		for _, entry := range verified {
+			if _, err := objects.WriteObject(entry.objType, entry.data, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	trace.Log(trace.Pack, "unpacked", "objects", result.Unpacked, "total", result.Total, "errors", len(result.Errors))
+	return result, nil
+}
+
+// ReadPackStream reads one pack from r and returns as soon as its trailer
+// has arrived, without waiting for the stream to end. This is what a server
+// needs when the client keeps the connection open after sending a pack.
+// The pack is checked while it streams in: every entry must have a known
+// type and inflate to its declared size, and the SHA-1 of everything read
+// must match the trailer.
+//
+// Returns:
+// - The raw pack.
+// - An error if the stream ends early or the pack is corrupt.
+func ReadPackStream(r io.Reader) ([]byte, error) {
+	reader := &recordingReader{r: bufio.NewReader(r), sum: sha1.New()}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("reading pack header: %w", err)
+	}
+	if string(header[:4]) != packSignature {
+		return nil, fmt.Errorf("bad pack header")
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+
+	count := binary.BigEndian.Uint32(header[8:12])
+	for i := uint32(0); i < count; i++ {
+		first, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading pack entry %d: %w", i, err)
+		}
+		size := int64(first & 0x0f)
+		for b, shift := first, 4; b&0x80 != 0; shift += 7 {
+			if b, err = reader.ReadByte(); err != nil {
+				return nil, err
+			}
+			if shift > 56 {
+				return nil, fmt.Errorf("pack entry %d: size header too long", i)
+			}
+			size |= int64(b&0x7f) << shift
+		}
+
+		switch typeCode := (first >> 4) & 0x7; typeCode {
+		case packCommit, packTree, packBlob, packTag:
+		case packOfsDelta:
+			for {
+				b, err := reader.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				if b&0x80 == 0 {
+					break
+				}
+			}
+		case packRefDelta:
+			if _, err := io.ReadFull(reader, make([]byte, 20)); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("pack entry %d has unknown type %d", i, typeCode)
+		}
+
+		// The inflater reads byte by byte from an io.ByteReader, so it stops
+		// exactly at the end of the compressed data.
+		zr, err := zlib.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("pack entry %d: %w", i, err)
+		}
+		inflated, err := io.Copy(io.Discard, zr)
+		if err != nil {
+			return nil, fmt.Errorf("pack entry %d: %w", i, err)
+		}
+		zr.Close()
+		if inflated != size {
+			return nil, fmt.Errorf("pack entry %d: inflated size mismatch: expected %d, got %d", i, size, inflated)
+		}
+	}
+
+	checksum := reader.sum.Sum(nil)
+	trailer := make([]byte, 20)
+	if _, err := io.ReadFull(reader.r, trailer); err != nil {
+		return nil, fmt.Errorf("reading pack trailer: %w", err)
+	}
+	if !bytes.Equal(checksum, trailer) {
+		return nil, fmt.Errorf("pack trailer checksum mismatch")
+	}
+	reader.buf.Write(trailer)
+	return reader.buf.Bytes(), nil
+}
+
+// recordingReader is a byte reader that keeps a copy of everything read
+// and its running SHA-1.
+type recordingReader struct {
+	r   *bufio.Reader
+	buf bytes.Buffer
+	sum hash.Hash
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf.Write(p[:n])
+	rr.sum.Write(p[:n])
+	return n, err
+}
+
+func (rr *recordingReader) ReadByte() (byte, error) {
+	b, err := rr.r.ReadByte()
+	if err == nil {
+		rr.buf.WriteByte(b)
+		rr.sum.Write([]byte{b})
+	}
+	return b, err
+}
+
+// readPackStreamEntry decodes the entry at offset. Ofs deltas are resolved
+// immediately when their base, which always precedes them, is known; other
+// deltas are returned unresolved with baseSHA or base set.
+//
+// Returns:
+// - The decoded entry.
+// - The offset of the next entry.
+// - An error if the entry is corrupt.
+func readPackStreamEntry(reader *bytes.Reader, offset uint64, byOffset map[uint64]*packStreamEntry) (*packStreamEntry, uint64, error) {
+	typeCode, size, headerLen, err := readPackEntryHeader(reader, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	pos := offset + uint64(headerLen)
+	entry := &packStreamEntry{offset: offset}
+
+	var base *packStreamEntry
+	switch typeCode {
+	case packCommit, packTree, packBlob, packTag:
+		entry.objType = packTypeNames[typeCode]
+
+	case packOfsDelta:
+		distance, n, err := readOffsetDelta(reader, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += uint64(n)
+
+		var ok bool
+		base, ok = byOffset[offset-distance]
+		if distance > offset || !ok {
+			return nil, 0, fmt.Errorf("bad delta base offset for entry at offset %d", offset)
+		}
+		if !base.resolved() {
+			entry.base, base = base, nil
+		}
+
+	case packRefDelta:
+		if pos+20 > uint64(reader.Size()) {
+			return nil, 0, fmt.Errorf("truncated ref delta at offset %d", offset)
+		}
+		raw := make([]byte, 20)
+		_, _ = reader.ReadAt(raw, int64(pos))
+		entry.baseSHA = hex.EncodeToString(raw)
+		pos += 20
+
+	default:
+		return nil, 0, fmt.Errorf("unknown pack entry type %d at offset %d", typeCode, offset)
+	}
+
+	data, next, err := inflateStream(reader, pos, size)
+	if err != nil {
+		return nil, 0, fmt.Errorf("entry at offset %d: %w", offset, err)
+	}
+	entry.data = data
+
+	if base != nil {
+		resolved, err := applyDelta(base.data, data)
+		if err != nil {
+			return nil, 0, fmt.Errorf("entry at offset %d: %w", offset, err)
+		}
+		entry.objType, entry.data = base.objType, resolved
+	}
+	return entry, next, nil
+}
+
+// inflateStream decompresses the zlib stream at pos, checks that it holds
+// exactly size bytes and returns the offset just past the stream.
+func inflateStream(reader *bytes.Reader, pos uint64, size int) ([]byte, uint64, error) {
+	if _, err := reader.Seek(int64(pos), io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	// bytes.Reader is an io.ByteReader, so the decompressor consumes exactly
+	// the compressed bytes and the reader is left at the next entry.
+	inflater, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("corrupt zlib stream: %w", err)
+	}
+
+	data, err := io.ReadAll(inflater)
+	if err != nil {
+		return nil, 0, fmt.Errorf("corrupt zlib stream: %w", err)
+	}
+	if len(data) != size {
+		return nil, 0, fmt.Errorf("inflated size mismatch: expected %d, got %d", size, len(data))
+	}
+
+	next, _ := reader.Seek(0, io.SeekCurrent)
+	return data, uint64(next), nil
+}
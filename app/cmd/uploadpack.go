@@ -0,0 +1,428 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// Agent identifies justdoit in capability advertisements.
+const Agent = "justdoit/0.1"
+
+// uploadPackCapabilities are the protocol capabilities offered by UploadPack.
+var uploadPackCapabilities = transport.NewCapabilities(
+	transport.CapSideBand, transport.CapSideBand64k, transport.CapOfsDelta, transport.CapIncludeTag, transport.CapNoProgress,
+)
+
+// advertisedRef is a ref announced to a client, with its peeled value for tags.
+type advertisedRef struct {
+	name   string
+	sha    string
+	peeled string
+}
+
+// advertisableRefs returns HEAD followed by every ref of the current
+// namespace, with tags peeled.
+func advertisableRefs(repo *GitRepository, objects *ObjectManager) ([]advertisedRef, error) {
+	refs, err := namespacedRefs(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []advertisedRef
+	if head, err := ResolveRef(repo, NamespacePrefix()+HeadFile); err == nil {
+		result = append(result, advertisedRef{name: HeadFile, sha: head})
+	}
+	for _, ref := range refs {
+		advertised := advertisedRef{name: ref.Name, sha: ref.SHA}
+		if strings.HasPrefix(ref.Name, "refs/tags/") {
+			if peeled, _, err := objects.PeelObject(ref.SHA); err == nil && peeled != ref.SHA {
+				advertised.peeled = peeled
+			}
+		}
+		result = append(result, advertised)
+	}
+	return result, nil
+}
+
+// headSymref returns the "symref=HEAD:<branch>" capability when HEAD, of
+// the current namespace, is symbolic and points inside the namespace.
+func headSymref(repo *GitRepository) string {
+	prefix := NamespacePrefix()
+	content, ok, err := readRefFile(repo, prefix+HeadFile)
+	if err != nil || !ok {
+		return ""
+	}
+	if target, isSymbolic := strings.CutPrefix(content, symbolicPrefix); isSymbolic {
+		if target, ok := strings.CutPrefix(target, prefix); ok {
+			return "symref=HEAD:" + target
+		}
+	}
+	return ""
+}
+
+// writeRefAdvertisement sends the protocol v0 ref advertisement. The first line
+// carries the capabilities after a NUL byte; an empty repository advertises a
+// placeholder so the capabilities can still be sent.
+func writeRefAdvertisement(enc *transport.Encoder, refs []advertisedRef, capabilities transport.Capabilities) error {
+	capabilityList := capabilities.String()
+	if len(refs) == 0 {
+		if err := enc.Encodef("%s capabilities^{}\x00%s\n", zeroSHA, capabilityList); err != nil {
+			return err
+		}
+		return enc.Flush()
+	}
+
+	for i, ref := range refs {
+		var err error
+		if i == 0 {
+			err = enc.Encodef("%s %s\x00%s\n", ref.sha, ref.name, capabilityList)
+		} else {
+			err = enc.Encodef("%s %s\n", ref.sha, ref.name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if ref.peeled != "" {
+			if err := enc.Encodef("%s %s^{}\n", ref.peeled, ref.name); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.Flush()
+}
+
+// UploadPackOptions controls a single upload-pack session.
+type UploadPackOptions struct {
+	AdvertiseRefsOnly bool // Only send the ref advertisement, as for ls-remote.
+	StatelessRPC      bool // Serve one request of a smart HTTP exchange; no advertisement is sent.
+}
+
+// UploadPack serves the server side of a fetch over a bidirectional stream:
+// it advertises refs, negotiates common history with the client and sends a
+// pack of the missing objects.
+//
+// Parameters:
+// - repo: The repository being served.
+// - r: The stream of client requests.
+// - w: The stream of server responses.
+// - opts: Session settings.
+//
+// Returns:
+// - An error if the conversation fails or the client asks for something invalid.
+func UploadPack(repo *GitRepository, r io.Reader, w io.Writer, opts UploadPackOptions) error {
+	defer trace.Start(trace.Pack, "upload-pack", "repo", repo.GitDir)()
+
+	objects := NewObjectManager(repo)
+	out := bufio.NewWriter(w)
+	enc := transport.NewEncoder(out)
+	dec := transport.NewDecoder(r)
+
+	refs, err := advertisableRefs(repo, objects)
+	if err != nil {
+		return err
+	}
+
+	allowFilter := repo.Config().GetBool("uploadpack.allowfilter")
+	allowAnyWant := repo.Config().GetBool("uploadpack.allowanysha1inwant")
+	capabilities := uploadPackCapabilities
+	if allowFilter {
+		capabilities = capabilities.With(transport.CapFilter)
+	}
+	if allowAnyWant {
+		capabilities = capabilities.With(transport.CapAllowTipSHA1).With(transport.CapAllowReachSHA1)
+	}
+	capabilities = capabilities.With(headSymref(repo)).WithValue(transport.CapAgent, Agent)
+
+	if !opts.StatelessRPC {
+		if err := writeRefAdvertisement(enc, refs, capabilities); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	if opts.AdvertiseRefsOnly {
+		return nil
+	}
+
+	request, err := readWants(dec)
+	if err != nil {
+		return err
+	}
+	wants, clientCapabilities := request.wants, request.capabilities
+	if len(wants) == 0 {
+		return nil
+	}
+
+	advertised := make(map[string]bool)
+	for _, ref := range refs {
+		advertised[ref.sha] = true
+	}
+	for _, want := range wants {
+		// uploadpack.allowAnySHA1InWant lets partial clones fetch the
+		// blobs they left out by name.
+		if !advertised[want] && !(allowAnyWant && objects.Has(want)) {
+			enc.Encodef("ERR upload-pack: not our ref %s", want)
+			out.Flush()
+			return fmt.Errorf("upload-pack: not our ref %s", want)
+		}
+	}
+
+	var filter *ObjectFilter
+	if request.filter != "" {
+		if !allowFilter || !clientCapabilities.Has(transport.CapFilter) {
+			return fmt.Errorf("upload-pack: filtering capability not negotiated")
+		}
+		if filter, err = ParseObjectFilter(request.filter); err != nil {
+			enc.Encodef("ERR upload-pack: %s", err)
+			out.Flush()
+			return fmt.Errorf("upload-pack: %w", err)
+		}
+	}
+
+	common, done, err := negotiateHaves(dec, enc, out, objects, opts.StatelessRPC)
+	if err != nil || !done {
+		return err
+	}
+
+	shas, err := objectsForPack(repo, objects, wants, common, clientCapabilities.Has(transport.CapIncludeTag), filter)
+	if err != nil {
+		return err
+	}
+	trace.Log(trace.Pack, "upload-pack objects", "wants", len(wants), "common", len(common), "objects", len(shas), "filter", request.filter)
+
+	// Deltas are only sent to clients that can read them.
+	var packOpts PackOptions
+	if clientCapabilities.Has(transport.CapOfsDelta) {
+		packOpts = LoadPackOptions(repo)
+	}
+	pack, _, _, err := objects.encodePack(shas, packOpts)
+	if err != nil {
+		return err
+	}
+
+	maxPacket := clientCapabilities.SidebandPacketSize()
+	if maxPacket == 0 {
+		if _, err := out.Write(pack); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+
+	if !clientCapabilities.Has(transport.CapNoProgress) {
+		progress := transport.NewSidebandWriter(enc, transport.SidebandProgress, maxPacket)
+		fmt.Fprintf(progress, "Enumerating objects: %d, done.\n", len(shas))
+	}
+	if _, err := transport.NewSidebandWriter(enc, transport.SidebandData, maxPacket).Write(pack); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// wantRequest is the first section of a fetch request.
+type wantRequest struct {
+	wants        []string               // The wanted object names.
+	capabilities transport.Capabilities // Requested on the first want line.
+	filter       string                 // Filter spec of a partial clone, if any.
+}
+
+// readWants reads the "want" lines, and the "filter" line that may follow
+// them, up to the first flush packet.
+//
+// Returns:
+// - The request; without wants if the client hung up without asking for
+// anything.
+// - An error if a line is malformed.
+func readWants(dec *transport.Decoder) (wantRequest, error) {
+	var request wantRequest
+
+	for {
+		packetType, payload, err := dec.Read()
+		if err == io.EOF && len(request.wants) == 0 {
+			return request, nil
+		}
+		if err != nil {
+			return wantRequest{}, err
+		}
+		if packetType == transport.FlushPacket {
+			return request, nil
+		}
+
+		line := strings.TrimSuffix(string(payload), "\n")
+		if spec, ok := strings.CutPrefix(line, "filter "); ok && len(request.wants) > 0 {
+			request.filter = spec
+			continue
+		}
+		value, ok := strings.CutPrefix(line, "want ")
+		if !ok {
+			return wantRequest{}, fmt.Errorf("upload-pack: protocol error, expected want, got '%s'", line)
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) == 0 || !isValidSHA(fields[0]) {
+			return wantRequest{}, fmt.Errorf("upload-pack: protocol error, invalid want '%s'", line)
+		}
+		request.wants = append(request.wants, fields[0])
+		if len(request.wants) == 1 {
+			request.capabilities = transport.NewCapabilities(fields[1:]...)
+		}
+	}
+}
+
+// negotiateHaves reads "have" lines until "done" and reports common commits
+// using the basic (non multi_ack) protocol: the first common commit is
+// acknowledged once, and a NAK is sent at every flush and after "done" as
+// long as nothing is common. In stateless mode a flush ends the request.
+//
+// Returns:
+// - The commits both sides have.
+// - Whether the client sent "done" and expects a pack.
+// - An error if the conversation fails.
+func negotiateHaves(dec *transport.Decoder, enc *transport.Encoder, out *bufio.Writer, objects *ObjectManager, stateless bool) ([]string, bool, error) {
+	var common []string
+	round := 0
+
+	for {
+		packetType, payload, err := dec.Read()
+		if err == io.EOF {
+			return nil, false, fmt.Errorf("upload-pack: client hung up during negotiation")
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		if packetType == transport.FlushPacket {
+			round++
+			trace.Log(trace.Pack, "negotiation round", "round", round, "common", len(common))
+			if len(common) == 0 {
+				if err := enc.Encode([]byte("NAK\n")); err != nil {
+					return nil, false, err
+				}
+			}
+			if err := out.Flush(); err != nil {
+				return nil, false, err
+			}
+			if stateless {
+				return common, false, nil
+			}
+			continue
+		}
+
+		line := strings.TrimSuffix(string(payload), "\n")
+		if line == "done" {
+			if len(common) == 0 {
+				if err := enc.Encode([]byte("NAK\n")); err != nil {
+					return nil, false, err
+				}
+			}
+			return common, true, out.Flush()
+		}
+
+		sha, ok := strings.CutPrefix(line, "have ")
+		if !ok || !isValidSHA(sha) {
+			return nil, false, fmt.Errorf("upload-pack: protocol error, expected have, got '%s'", line)
+		}
+
+		if objType, _, err := objects.StatObject(sha); err == nil && objType == CommitType {
+			if len(common) == 0 {
+				if err := enc.Encodef("ACK %s\n", sha); err != nil {
+					return nil, false, err
+				}
+			}
+			common = append(common, sha)
+		}
+	}
+}
+
+// objectsForPack lists the objects reachable from wants but not from the
+// common commits, commits first. With includeTags, annotated tags pointing
+// into the pack are added as well. Blobs the filter rejects are left out
+// unless they are wanted by name.
+func objectsForPack(repo *GitRepository, objects *ObjectManager, wants, common []string, includeTags bool, filter *ObjectFilter) ([]string, error) {
+	exclude, err := collectObjects(objects, common, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := collectObjects(objects, wants, exclude, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if includeTags {
+		refs, err := namespacedRefs(repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			if !strings.HasPrefix(ref.Name, "refs/tags/") || found[ref.SHA] || exclude[ref.SHA] {
+				continue
+			}
+			if peeled, _, err := objects.PeelObject(ref.SHA); err == nil && peeled != ref.SHA && found[peeled] {
+				tags, err := collectObjects(objects, []string{ref.SHA}, found, false)
+				if err != nil {
+					return nil, err
+				}
+				for sha := range tags {
+					found[sha] = true
+				}
+			}
+		}
+	}
+
+	if filter != nil {
+		wanted := make(map[string]bool, len(wants))
+		for _, want := range wants {
+			wanted[want] = true
+		}
+		for sha := range found {
+			if wanted[sha] {
+				continue
+			}
+			objType, size, err := objects.StatObject(sha)
+			if err != nil {
+				return nil, err
+			}
+			if !filter.Includes(objType, size) {
+				delete(found, sha)
+			}
+		}
+	}
+
+	return sortObjectsForPack(objects, found)
+}
+
+// sortObjectsForPack orders objects by type (commits, tags, trees, blobs) and
+// then by name, the layout git readers find most efficient.
+func sortObjectsForPack(objects *ObjectManager, found map[string]bool) ([]string, error) {
+	rank := map[GitObjectType]int{CommitType: 0, TagType: 1, TreeType: 2, BlobType: 3}
+	types := make(map[string]GitObjectType, len(found))
+	shas := make([]string, 0, len(found))
+
+	for sha := range found {
+		objType, _, err := objects.StatObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		types[sha] = objType
+		shas = append(shas, sha)
+	}
+
+	sort.Slice(shas, func(i, j int) bool {
+		if rank[types[shas[i]]] != rank[types[shas[j]]] {
+			return rank[types[shas[i]]] < rank[types[shas[j]]]
+		}
+		return shas[i] < shas[j]
+	})
+	return shas, nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/transport"
+)
+
+// Config variables of url.<base> sections that rewrite remote URLs.
+const (
+	urlInsteadOf     = "insteadof"     // For every remote URL.
+	urlPushInsteadOf = "pushinsteadof" // For the URLs pushes go to, when no pushurl is set.
+)
+
+// urlRewrites reads the url.<base>.<key> variables of the global config
+// and of repo, which may be nil, in the order git reads them. The config
+// files are read line by line rather than through viper, which would
+// lower the case of the bases and keep one value of a variable set more
+// than once.
+func urlRewrites(repo *GitRepository, key string) []transport.URLRewrite {
+	var rewrites []transport.URLRewrite
+	for _, path := range globalConfigFiles() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		rewrites = appendURLRewrites(rewrites, strings.Split(string(data), "\n"), key)
+	}
+	if repo != nil {
+		lines, err := readConfigLines(repo)
+		if err != nil {
+			return rewrites
+		}
+		rewrites = appendURLRewrites(rewrites, lines, key)
+	}
+	return rewrites
+}
+
+// appendURLRewrites appends the url.<base>.<key> variables set by lines.
+func appendURLRewrites(rewrites []transport.URLRewrite, lines []string, key string) []transport.URLRewrite {
+	section, base := "", ""
+	for _, line := range lines {
+		if name, subsection, ok := parseConfigSectionHeader(line); ok {
+			section, base = name, subsection
+			continue
+		}
+		if section != "url" || configLineKey(line) != key {
+			continue
+		}
+		_, value, _ := strings.Cut(line, "=")
+		rewrites = append(rewrites, transport.URLRewrite{Base: base, InsteadOf: unquoteConfigValue(strings.TrimSpace(value))})
+	}
+	return rewrites
+}
+
+// rewriteURL applies url.<base>.insteadOf to a remote URL.
+func rewriteURL(repo *GitRepository, url string) string {
+	rewritten, _ := transport.RewriteURL(url, urlRewrites(repo, urlInsteadOf))
+	return rewritten
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import "testing"
+
+func TestResolvePushURL(t *testing.T) {
+	const rewrites = `[url "https://mirror.example.com/"]
+	insteadOf = https://example.com/
+[url "ssh://git@example.com/"]
+	pushInsteadOf = https://example.com/
+[url "ssh://git@example.com/special/"]
+	pushInsteadOf = https://example.com/team/special/
+	pushInsteadOf = special:
+[url "https://github.com/"]
+	insteadOf = gh:
+`
+	tests := []struct {
+		name      string
+		config    string
+		remote    string
+		wantFetch string
+		wantPush  string
+	}{
+		{
+			name:      "pushInsteadOf takes precedence over insteadOf",
+			config:    "[remote \"origin\"]\n\turl = https://example.com/team/repo\n",
+			remote:    "origin",
+			wantFetch: "https://mirror.example.com/team/repo",
+			wantPush:  "ssh://git@example.com/team/repo",
+		},
+		{
+			name:      "longest pushInsteadOf",
+			config:    "[remote \"origin\"]\n\turl = https://example.com/team/special/repo\n",
+			remote:    "origin",
+			wantFetch: "https://mirror.example.com/team/special/repo",
+			wantPush:  "ssh://git@example.com/special/repo",
+		},
+		{
+			name:      "second value of a pushInsteadOf",
+			config:    "[remote \"origin\"]\n\turl = special:repo\n",
+			remote:    "origin",
+			wantFetch: "special:repo",
+			wantPush:  "ssh://git@example.com/special/repo",
+		},
+		{
+			name:      "insteadOf when no pushInsteadOf matches",
+			config:    "[remote \"origin\"]\n\turl = gh:user/repo\n",
+			remote:    "origin",
+			wantFetch: "https://github.com/user/repo",
+			wantPush:  "https://github.com/user/repo",
+		},
+		{
+			name:      "pushurl is rewritten by insteadOf only",
+			config:    "[remote \"origin\"]\n\turl = gh:user/repo\n\tpushurl = https://example.com/push\n",
+			remote:    "origin",
+			wantFetch: "https://github.com/user/repo",
+			wantPush:  "https://mirror.example.com/push",
+		},
+		{
+			name:      "URL given instead of a remote",
+			remote:    "https://example.com/repo",
+			wantFetch: "https://mirror.example.com/repo",
+			wantPush:  "ssh://git@example.com/repo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			appendRepoConfig(t, repo, rewrites+tt.config)
+
+			url := tt.remote
+			if RemoteConfigured(repo, tt.remote) {
+				url = repo.Config().GetString(configKey("remote", tt.remote, "url"))
+			}
+			if got := rewriteURL(repo, url); got != tt.wantFetch {
+				t.Errorf("fetch URL = %q, want %q", got, tt.wantFetch)
+			}
+			if got := ResolvePushURL(repo, tt.remote); got != tt.wantPush {
+				t.Errorf("ResolvePushURL = %q, want %q", got, tt.wantPush)
+			}
+		})
+	}
+}
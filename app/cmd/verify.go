@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+)
+
+// VerifyOptions controls how deeply VerifyObjects looks at objects.
+type VerifyOptions struct {
+	// Blobs re-inflates and re-hashes every blob. Without it only the header
+	// of a blob is read, since blobs have no structure to check and can be
+	// large; commits, trees and tags are always checked in full.
+	Blobs bool
+}
+
+// ObjectCorruption is a problem found with a stored object.
+type ObjectCorruption struct {
+	SHA     string `json:"sha,omitempty"`    // The object, empty for problems with a whole pack.
+	Source  string `json:"source"`           // The loose object or pack file holding it.
+	Offset  uint64 `json:"offset,omitempty"` // The offset of the entry, for packed objects.
+	Problem string `json:"problem"`
+}
+
+// String describes the corruption on one line.
+func (c ObjectCorruption) String() string {
+	switch {
+	case c.SHA == "":
+		return fmt.Sprintf("%s: %s", c.Source, c.Problem)
+	case c.Offset > 0:
+		return fmt.Sprintf("%s (%s at %d): %s", c.SHA, c.Source, c.Offset, c.Problem)
+	}
+	return fmt.Sprintf("%s (%s): %s", c.SHA, c.Source, c.Problem)
+}
+
+// VerifyReport is the result of VerifyObjects. It is meant to be
+// serialized as the corruption manifest of a repository.
+type VerifyReport struct {
+	Checked int                `json:"checked"` // Objects looked at, loose and packed.
+	Corrupt []ObjectCorruption `json:"corrupt"`
+}
+
+// VerifyObjects reads back every loose object and every object of every
+// pack and checks that its zlib stream is intact, that its size matches its
+// header and that its content hashes to its name. Commits, trees and tags
+// are also checked with CheckObject. Pack trailers are verified as well.
+// Problems with single objects are collected rather than returned, so that
+// one corrupt object does not hide the others.
+//
+// Parameters:
+// - repo: The repository to verify.
+// - opts: Whether blobs are verified in full.
+//
+// Returns:
+// - The report listing every corrupt object found.
+// - An error if the object database cannot be listed.
+func VerifyObjects(repo *GitRepository, opts VerifyOptions) (*VerifyReport, error) {
+	defer trace.Start(trace.Perf, "verify objects", "blobs", opts.Blobs)()
+
+	objects := NewObjectManager(repo)
+	report := &VerifyReport{Corrupt: []ObjectCorruption{}}
+
+	loose, err := objects.LooseObjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, sha := range loose {
+		report.Checked++
+		path := objects.loosePath(sha)
+		if problem := verifyLooseObject(repo.fs, path, sha, opts); problem != nil {
+			report.Corrupt = append(report.Corrupt, ObjectCorruption{SHA: sha, Source: path, Problem: problem.Error()})
+		}
+	}
+
+	packs, err := objects.packFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		if err := verifyPackChecksum(repo.fs, pack.path); err != nil {
+			report.Corrupt = append(report.Corrupt, ObjectCorruption{Source: pack.path, Problem: err.Error()})
+		}
+		for i := 0; i < pack.index.count(); i++ {
+			report.Checked++
+			sha, offset := pack.index.sha(i), pack.index.offsets[i]
+			if problem := pack.verifyEntry(objects, sha, offset, opts); problem != nil {
+				report.Corrupt = append(report.Corrupt, ObjectCorruption{
+					SHA: sha, Source: pack.path, Offset: offset, Problem: problem.Error(),
+				})
+			}
+		}
+	}
+	return report, nil
+}
+
+// verifyLooseObject checks one loose object file.
+func verifyLooseObject(fsys FileSystem, path, sha string, opts VerifyOptions) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	objType, size, reader, err := openLooseObject(file, false)
+	if err != nil {
+		return err
+	}
+	if objType == BlobType && !opts.Blobs {
+		return nil
+	}
+	return verifyContent(reader, objType, size, sha)
+}
+
+// verifyEntry checks one pack entry. Whole entries are inflated as a
+// stream; deltas are resolved and their result is checked.
+func (p *packFile) verifyEntry(m *ObjectManager, sha string, offset uint64, opts VerifyOptions) error {
+	file, err := p.fs.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	typeCode, size, headerLen, err := readPackEntryHeader(file, offset)
+	if err != nil {
+		return err
+	}
+
+	if objType, whole := packTypeNames[typeCode]; whole {
+		if objType == BlobType && !opts.Blobs {
+			return nil
+		}
+		reader, err := zlib.NewReader(io.NewSectionReader(file, int64(offset)+int64(headerLen), 1<<62))
+		if err != nil {
+			return fmt.Errorf("corrupt zlib stream: %w", err)
+		}
+		defer reader.Close()
+		return verifyContent(reader, objType, int64(size), sha)
+	}
+
+	objType, _, err := p.statAt(m, offset)
+	if err != nil {
+		return err
+	}
+	if objType == BlobType && !opts.Blobs {
+		return nil
+	}
+	objType, data, err := p.readAt(m, offset)
+	if err != nil {
+		return err
+	}
+	return verifyContent(bytes.NewReader(data), objType, int64(len(data)), sha)
+}
+
+// verifyContent reads exactly size bytes of content from r, which must then
+// be at its end, and checks that they hash to sha. Commits, trees and tags
+// are also checked with CheckObject.
+func verifyContent(r io.Reader, objType GitObjectType, size int64, sha string) error {
+	hasher := sha1.New()
+	fmt.Fprintf(hasher, "%s %d\x00", objType, size)
+
+	var content bytes.Buffer
+	writer := io.Writer(hasher)
+	if objType != BlobType {
+		writer = io.MultiWriter(hasher, &content)
+	}
+
+	n, err := io.Copy(writer, io.LimitReader(r, size))
+	if err != nil {
+		return fmt.Errorf("corrupt zlib stream: %w", err)
+	}
+	if n != size {
+		return fmt.Errorf("size mismatch: header says %d bytes, content has %d", size, n)
+	}
+	// Reading past the end makes zlib verify its checksum.
+	if extra, err := io.Copy(io.Discard, r); err != nil {
+		return fmt.Errorf("corrupt zlib stream: %w", err)
+	} else if extra > 0 {
+		return fmt.Errorf("size mismatch: header says %d bytes, content has %d", size, size+extra)
+	}
+
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != sha {
+		return fmt.Errorf("hash mismatch: content hashes to %s", actual)
+	}
+	if objType != BlobType {
+		return CheckObject(objType, content.Bytes())
+	}
+	return nil
+}
+
+// verifyPackChecksum checks the SHA-1 trailer of a pack file.
+func verifyPackChecksum(fsys FileSystem, path string) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < 12+20 {
+		return fmt.Errorf("pack '%s' is truncated", filepath.Base(path))
+	}
+
+	hasher := sha1.New()
+	if _, err := io.CopyN(hasher, file, info.Size()-20); err != nil {
+		return err
+	}
+	trailer := make([]byte, 20)
+	if _, err := io.ReadFull(file, trailer); err != nil {
+		return err
+	}
+	if !bytes.Equal(hasher.Sum(nil), trailer) {
+		return fmt.Errorf("pack trailer checksum mismatch")
+	}
+	return nil
+}
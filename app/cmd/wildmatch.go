@@ -0,0 +1,120 @@
+package cmd
+
+import "strings"
+
+// wildmatch reports whether text matches the shell glob pattern. '?' matches
+// one character, "[...]" matches a character class (negated with '!' or
+// '^', with ranges such as a-z) and a backslash escapes the next character.
+// '*' matches any run of characters; with pathname set it stops at '/', and
+// "**" between slashes matches any number of directories instead, as in
+// .gitignore files.
+func wildmatch(pattern, text string, pathname bool) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			if pathname && strings.HasPrefix(pattern, "**") {
+				rest := strings.TrimLeft(pattern, "*")
+				if rest == "" {
+					return true
+				}
+				if rest[0] == '/' {
+					// "**/" matches zero or more leading directories.
+					rest = rest[1:]
+					for i := 0; i <= len(text); i++ {
+						if (i == 0 || text[i-1] == '/') && wildmatch(rest, text[i:], pathname) {
+							return true
+						}
+					}
+					return false
+				}
+			}
+
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return !pathname || !strings.Contains(text, "/")
+			}
+			for i := 0; i <= len(text); i++ {
+				if wildmatch(pattern, text[i:], pathname) {
+					return true
+				}
+				if pathname && i < len(text) && text[i] == '/' {
+					return false
+				}
+			}
+			return false
+
+		case '?':
+			if text == "" || (pathname && text[0] == '/') {
+				return false
+			}
+
+		case '[':
+			if text == "" {
+				return false
+			}
+			matched, rest, ok := matchCharClass(pattern[1:], text[0])
+			if !ok {
+				// An unterminated class matches a literal '['.
+				if text[0] != '[' {
+					return false
+				}
+				break
+			}
+			if !matched || (pathname && text[0] == '/') {
+				return false
+			}
+			pattern, text = rest, text[1:]
+			continue
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if text == "" || pattern[0] != text[0] {
+				return false
+			}
+		}
+		pattern, text = pattern[1:], text[1:]
+	}
+	return text == ""
+}
+
+// matchCharClass matches c against the class starting just after '['.
+//
+// Returns:
+// - Whether c is in the class.
+// - The pattern following the closing ']'.
+// - Whether the class was terminated.
+func matchCharClass(class string, c byte) (bool, string, bool) {
+	negated := false
+	if class != "" && (class[0] == '!' || class[0] == '^') {
+		negated, class = true, class[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if class[i] == ']' && i > 0 {
+			return matched != negated, class[i+1:], true
+		}
+
+		lo := class[i]
+		if lo == '\\' && i+1 < len(class) {
+			i++
+			lo = class[i]
+		}
+		hi := lo
+		if i+2 < len(class) && class[i+1] == '-' && class[i+2] != ']' {
+			hi = class[i+2]
+			i += 2
+		}
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+	return false, "", false
+}
@@ -0,0 +1,58 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadCommandsMatchGit(t *testing.T) {
+	dir := newHistory(t)
+	// Leave changes of every kind: staged, unstaged, removed from the index
+	// only, and untracked.
+	writeFile(t, dir, "staged.txt", "staged\n", 0644)
+	git(t, dir, "add", "staged.txt")
+	writeFile(t, dir, "README", "hello\nagain\nand again\n", 0644)
+	git(t, dir, "rm", "-q", "--cached", "run.sh")
+	writeFile(t, dir, "untracked.txt", "untracked\n", 0644)
+
+	commands := [][]string{
+		{"ls-files"},
+		{"ls-files", "--stage"},
+		{"cat-file", "-t", "v1"},
+		{"cat-file", "-s", "HEAD"},
+		{"cat-file", "-p", "HEAD"},
+		{"cat-file", "-p", "v1"},
+		{"cat-file", "-p", "HEAD^{tree}"},
+		{"cat-file", "-p", "HEAD:many"},
+		{"hash-object", "README"},
+		{"rev-list", "HEAD"},
+		{"rev-list", "--all"},
+		{"rev-list", "--count", "HEAD"},
+		{"rev-list", "--topo-order", "HEAD"},
+		{"rev-list", "--first-parent", "HEAD"},
+		{"show-ref"},
+		{"show-ref", "--heads"},
+		{"show-ref", "--dereference", "--tags"},
+		{"log"},
+		{"log", "--oneline"},
+		{"log", "--name-status"},
+		{"log", "--stat", "-n", "2"},
+		{"status"},
+		{"status", "--short"},
+		{"status", "--short", "--branch"},
+		{"diff"},
+		{"diff", "--cached"},
+		{"diff", "--stat"},
+		{"diff", "--raw"},
+		{"diff", "--raw", "HEAD~1"},
+		{"diff", "--name-status", "HEAD~1"},
+		{"diff", "HEAD~1", "HEAD"},
+	}
+	for _, args := range commands {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			sameOutput(t, dir, args...)
+		})
+	}
+}
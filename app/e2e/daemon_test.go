@@ -0,0 +1,131 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startDaemon serves the repositories below base with git daemon on a free
+// local port until the test ends, and returns the URL of base.
+func startDaemon(t *testing.T, base string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	daemon := exec.Command("git", "daemon", "--reuseaddr", "--export-all", "--listen=127.0.0.1",
+		fmt.Sprintf("--port=%d", port), "--base-path="+base, base)
+	if err := daemon.Start(); err != nil {
+		t.Fatalf("starting git daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = daemon.Process.Kill()
+		_ = daemon.Wait()
+	})
+
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+			return "git://" + address
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("git daemon does not listen on %s: %v", address, err)
+		}
+	}
+}
+
+// serveHistory publishes a bare copy of a newHistory repository through git
+// daemon.
+//
+// Returns:
+// - The repository the history was made in, to push more from.
+// - The bare repository being served.
+// - Its git:// URL.
+func serveHistory(t *testing.T) (string, string, string) {
+	t.Helper()
+	work := newHistory(t)
+	base := t.TempDir()
+	bare := filepath.Join(base, "src.git")
+	git(t, base, "clone", "-q", "--bare", work, bare)
+	return work, bare, startDaemon(t, base) + "/src.git"
+}
+
+// cloneBoth clones url with both tools, each into a directory called
+// "clone" so that their output can be compared, and returns the clones
+// made by justdoit and by git.
+func cloneBoth(t *testing.T, url string) (string, string) {
+	t.Helper()
+	gotBase, wantBase := t.TempDir(), t.TempDir()
+	want := runCombined(t, wantBase, "git", "clone", url, "clone")
+	if got := runCombined(t, gotBase, jdiPath, "clone", url, "clone"); got != want {
+		t.Errorf("clone output differs\njustdoit:\n%s\ngit:\n%s", got, want)
+	}
+	return filepath.Join(gotBase, "clone"), filepath.Join(wantBase, "clone")
+}
+
+// fetchBoth fetches into both clones and compares what they report.
+func fetchBoth(t *testing.T, got, want string) {
+	t.Helper()
+	wantOutput := runCombined(t, want, "git", "fetch")
+	if gotOutput := runCombined(t, got, jdiPath, "fetch"); gotOutput != wantOutput {
+		t.Errorf("fetch output differs\njustdoit:\n%s\ngit:\n%s", gotOutput, wantOutput)
+	}
+}
+
+func TestCloneFromGitDaemon(t *testing.T) {
+	_, _, url := serveHistory(t)
+	got, want := cloneBoth(t, url)
+	sameState(t, got, want)
+	if g, w := git(t, got, "symbolic-ref", "refs/remotes/origin/HEAD"), git(t, want, "symbolic-ref", "refs/remotes/origin/HEAD"); g != w {
+		t.Errorf("origin/HEAD points to %s, git's to %s", g, w)
+	}
+	sameOutput(t, got, "log", "--all")
+}
+
+func TestFetchFromGitDaemon(t *testing.T) {
+	work, bare, url := serveHistory(t)
+	got, want := cloneBoth(t, url)
+
+	steps := []struct {
+		name  string
+		files int // Files added: enough or not for the fetch to keep a pack.
+		tag   bool
+	}{
+		{name: "kept as a pack", files: 150, tag: true},
+		{name: "unpacked", files: 1},
+	}
+	for i, step := range steps {
+		for n := range step.files {
+			writeFile(t, work, fmt.Sprintf("step%d/file%03d.txt", i, n), fmt.Sprintf("step %d file %d\n", i, n), 0644)
+		}
+		tick(t, 10+i)
+		git(t, work, "add", ".")
+		git(t, work, "commit", "-q", "-m", step.name)
+		push := []string{"push", "-q", bare, "master"}
+		if step.tag {
+			tag := fmt.Sprintf("step%d", i)
+			git(t, work, "tag", "-a", "-m", step.name, tag)
+			push = append(push, "refs/tags/"+tag)
+		}
+		run(t, work, "git", push...)
+
+		fetchBoth(t, got, want)
+		for _, query := range [][]string{{"for-each-ref"}, {"rev-list", "--all", "--objects"}} {
+			if g, w := git(t, got, query...), git(t, want, query...); g != w {
+				t.Errorf("after fetching %s, git %v differs\njustdoit:\n%s\ngit:\n%s", step.name, query, g, w)
+			}
+		}
+		git(t, got, "fsck", "--full", "--strict", "--no-dangling")
+	}
+}
@@ -0,0 +1,10 @@
+// Package e2e holds the end-to-end compatibility suite: it builds the
+// justdoit binary, runs it next to the installed git on the same input and
+// compares what they produce, from object names and index bytes to command
+// output, and clones and fetches from a local git daemon. The tests are
+// behind the e2e build tag since they need git and a git daemon. The binary
+// is built by the tests themselves, out of sight of the test cache, hence
+// -count=1:
+//
+//	go test -count=1 -tags e2e ./app/e2e
+package e2e
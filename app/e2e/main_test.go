@@ -0,0 +1,273 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// jdiPath is the justdoit binary built by TestMain.
+var jdiPath string
+
+// baseTime is the clock of both tools, in seconds since the epoch; see tick.
+const baseTime = 1700000000
+
+// globalConfig is the only config both tools see. Hints are turned off
+// because justdoit does not print them.
+const globalConfig = `[init]
+	defaultBranch = master
+[advice]
+	statusHints = false
+	detachedHead = false
+`
+
+func TestMain(m *testing.M) {
+	os.Exit(runSuite(m))
+}
+
+// runSuite builds justdoit, isolates both tools from the user's setup and
+// runs the tests.
+func runSuite(m *testing.M) int {
+	if _, err := exec.LookPath("git"); err != nil {
+		fmt.Fprintln(os.Stderr, "e2e: git is not installed, skipping")
+		return 0
+	}
+
+	dir, err := os.MkdirTemp("", "justdoit-e2e-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	jdiPath = filepath.Join(dir, "justdoit")
+	build := exec.Command("go", "build", "-o", jdiPath, "github.com/utkarsh5026/justdoit/app")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "e2e: building justdoit:", err)
+		return 1
+	}
+
+	if err := isolateEnv(filepath.Join(dir, "home")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return m.Run()
+}
+
+// isolateEnv points both tools at an empty home with globalConfig, without
+// system config, and fixes the identity and clock so that they write the
+// same objects.
+func isolateEnv(home string) error {
+	if err := os.MkdirAll(home, 0755); err != nil {
+		return err
+	}
+	config := filepath.Join(home, ".gitconfig")
+	if err := os.WriteFile(config, []byte(globalConfig), 0644); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"GIT_DIR", "GIT_WORK_TREE", "GIT_INDEX_FILE", "GIT_OBJECT_DIRECTORY", "GIT_NAMESPACE", "XDG_CONFIG_HOME"} {
+		os.Unsetenv(name)
+	}
+	date := fmt.Sprintf("%d +0000", baseTime)
+	env := map[string]string{
+		"HOME":                home,
+		"GIT_CONFIG_GLOBAL":   config,
+		"GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_TERMINAL_PROMPT": "0",
+		"GIT_AUTHOR_NAME":     "A U Thor",
+		"GIT_AUTHOR_EMAIL":    "author@example.com",
+		"GIT_AUTHOR_DATE":     date,
+		"GIT_COMMITTER_NAME":  "C O Mitter",
+		"GIT_COMMITTER_EMAIL": "committer@example.com",
+		"GIT_COMMITTER_DATE":  date,
+		"TZ":                  "UTC",
+		"LC_ALL":              "C",
+	}
+	for name, value := range env {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tick moves the clock of both tools n seconds past baseTime, so that
+// commits made one after the other are ordered the same by both.
+func tick(t *testing.T, n int) {
+	t.Helper()
+	date := fmt.Sprintf("%d +0000", baseTime+n)
+	t.Setenv("GIT_AUTHOR_DATE", date)
+	t.Setenv("GIT_COMMITTER_DATE", date)
+}
+
+// run runs a program in dir and returns its standard output. The test
+// fails if the program does.
+func run(t *testing.T, dir, name string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s %s: %v\n%s", filepath.Base(name), strings.Join(args, " "), err, stderr.String())
+	}
+	return string(out)
+}
+
+// runCombined is run for commands that report on standard error, such as
+// clone and fetch, and returns both outputs together.
+func runCombined(t *testing.T, dir, name string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %s: %v\n%s", filepath.Base(name), strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+// git runs git in dir and returns its standard output.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	return run(t, dir, "git", args...)
+}
+
+// jdi runs justdoit in dir and returns its standard output.
+func jdi(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	return run(t, dir, jdiPath, args...)
+}
+
+// sameOutput runs a command with both tools in dir and fails the test if
+// their standard outputs differ.
+func sameOutput(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	want := git(t, dir, args...)
+	if got := jdi(t, dir, args...); got != want {
+		t.Errorf("%s differs from git\njustdoit:\n%s\ngit:\n%s", strings.Join(args, " "), got, want)
+	}
+}
+
+// sameState fails the test if git sees the two repositories differently:
+// their refs, index, worktree status and config. got must also pass a
+// strict fsck.
+func sameState(t *testing.T, got, want string) {
+	t.Helper()
+	queries := [][]string{
+		{"for-each-ref"},
+		{"symbolic-ref", "HEAD"},
+		{"rev-parse", "HEAD"},
+		{"ls-files", "--stage"},
+		{"status", "--porcelain"},
+	}
+	for _, query := range queries {
+		if g, w := git(t, got, query...), git(t, want, query...); g != w {
+			t.Errorf("git %s differs\njustdoit:\n%s\ngit:\n%s", strings.Join(query, " "), g, w)
+		}
+	}
+	if g, w := sortedLines(git(t, got, "config", "--local", "--list")), sortedLines(git(t, want, "config", "--local", "--list")); g != w {
+		t.Errorf("config differs\njustdoit:\n%s\ngit:\n%s", g, w)
+	}
+	git(t, got, "fsck", "--full", "--strict", "--no-dangling")
+}
+
+// sortedLines sorts the lines of output, for output whose order is not
+// specified.
+func sortedLines(output string) string {
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// sampleFiles is a small tree covering what the formats must get right:
+// nested directories, names that sort differently as files and as
+// directories, and CRLF, binary and empty content.
+var sampleFiles = map[string]string{
+	"README":              "hello\n",
+	"a-b":                 "dash\n",
+	"a.b":                 "dot\n",
+	"a/b":                 "slash\n",
+	"dir/nested/deep.txt": "deep\n",
+	"crlf.txt":            "one\r\ntwo\r\n",
+	"binary.bin":          "\x00\x01\x02\xff",
+	"empty":               "",
+}
+
+// writeFile writes a file below dir, creating its directories. Its time
+// is set in the past, so that no index entry is racily clean.
+func writeFile(t *testing.T, dir, name, content string, mode os.FileMode) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Unix(baseTime, 0)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeSample writes sampleFiles to dir, with an executable script and a
+// symlink.
+func writeSample(t *testing.T, dir string) {
+	t.Helper()
+	for name, content := range sampleFiles {
+		writeFile(t, dir, name, content, 0644)
+	}
+	writeFile(t, dir, "run.sh", "#!/bin/sh\necho run\n", 0755)
+	if err := os.Symlink("README", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newHistory creates a repository with git holding a short history: the
+// sample files and many more, a side branch merged back, an annotated and
+// a lightweight tag. There are enough objects for a fetch of it to be kept
+// as a pack.
+func newHistory(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	writeSample(t, dir)
+	for i := range 120 {
+		writeFile(t, dir, fmt.Sprintf("many/file%03d.txt", i), fmt.Sprintf("file %d\n", i), 0644)
+	}
+	tick(t, 1)
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "initial")
+	git(t, dir, "tag", "light")
+
+	git(t, dir, "checkout", "-q", "-b", "side")
+	writeFile(t, dir, "side.txt", "side\n", 0644)
+	tick(t, 2)
+	git(t, dir, "add", "side.txt")
+	git(t, dir, "commit", "-q", "-m", "side")
+
+	git(t, dir, "checkout", "-q", "master")
+	writeFile(t, dir, "README", "hello\nagain\n", 0644)
+	tick(t, 3)
+	git(t, dir, "commit", "-q", "-a", "-m", "second\n\nWith a body.")
+	git(t, dir, "tag", "-a", "-m", "release", "v1")
+
+	tick(t, 4)
+	git(t, dir, "merge", "-q", "--no-edit", "side")
+	return dir
+}
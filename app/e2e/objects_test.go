@@ -0,0 +1,147 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitMatchesGit(t *testing.T) {
+	base := t.TempDir()
+	git(t, base, "init", "-q", "from-git")
+	jdi(t, base, "init", "-q", "from-jdi")
+	want, got := filepath.Join(base, "from-git"), filepath.Join(base, "from-jdi")
+
+	for _, name := range []string{"HEAD", "description"} {
+		wantData, err := os.ReadFile(filepath.Join(want, ".git", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotData, err := os.ReadFile(filepath.Join(got, ".git", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(gotData, wantData) {
+			t.Errorf(".git/%s = %q, git writes %q", name, gotData, wantData)
+		}
+	}
+	if g, w := sortedLines(git(t, got, "config", "--local", "--list")), sortedLines(git(t, want, "config", "--local", "--list")); g != w {
+		t.Errorf("config differs\njustdoit:\n%s\ngit:\n%s", g, w)
+	}
+	if status := git(t, got, "status", "--porcelain"); status != "" {
+		t.Errorf("git status in a new repository = %q", status)
+	}
+	git(t, got, "fsck", "--strict")
+}
+
+func TestHashObjectMatchesGit(t *testing.T) {
+	contents := map[string]string{
+		"empty":      "",
+		"text":       "hello\n",
+		"no-newline": "hello",
+		"crlf":       "one\r\ntwo\r\n",
+		"binary":     "\x00\x01\x02\xff",
+		"large":      strings.Repeat("0123456789abcdef\n", 1<<16),
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+
+	for name, content := range contents {
+		t.Run(name, func(t *testing.T) {
+			writeFile(t, dir, name, content, 0644)
+			want := git(t, dir, "hash-object", name)
+			if got := jdi(t, dir, "hash-object", "-w", name); got != want {
+				t.Fatalf("hash-object = %s, git hashes it as %s", got, want)
+			}
+			// git must be able to read back the loose object justdoit wrote.
+			if data := git(t, dir, "cat-file", "blob", strings.TrimSpace(want)); data != content {
+				t.Errorf("git cat-file of the written object returns %d bytes, want %d", len(data), len(content))
+			}
+		})
+	}
+	git(t, dir, "fsck", "--full", "--strict")
+}
+
+func TestIndexMatchesGit(t *testing.T) {
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	writeSample(t, dir)
+	index := filepath.Join(dir, ".git", "index")
+
+	// Both tools stage the same files, so even the stat data must agree.
+	git(t, dir, "add", ".")
+	want, err := os.ReadFile(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(index); err != nil {
+		t.Fatal(err)
+	}
+	jdi(t, dir, "add", ".")
+	got, err := os.ReadFile(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("index differs from git's\njustdoit:\n%s\ngit:\n%s", debugIndex(t, dir, got), debugIndex(t, dir, want))
+	}
+}
+
+// debugIndex describes an index the way git ls-files --debug does, to show
+// where two indexes differ.
+func debugIndex(t *testing.T, dir string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "index")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_INDEX_FILE", path)
+	return git(t, dir, "ls-files", "--stage", "--debug")
+}
+
+func TestCommitMatchesGit(t *testing.T) {
+	want, got := t.TempDir(), t.TempDir()
+	git(t, want, "init", "-q")
+	jdi(t, got, "init", "-q")
+
+	steps := []struct {
+		name   string
+		change func(t *testing.T, dir string)
+	}{
+		{"initial", writeSample},
+		{"modify, delete and add", func(t *testing.T, dir string) {
+			writeFile(t, dir, "README", "hello\nagain\n", 0644)
+			writeFile(t, dir, "new/dir/file.txt", "new\n", 0644)
+			if err := os.Remove(filepath.Join(dir, "a.b")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(filepath.Join(dir, "run.sh"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for i, step := range steps {
+		step.change(t, want)
+		step.change(t, got)
+		tick(t, i+1)
+		git(t, want, "add", "-A")
+		git(t, want, "commit", "-q", "-m", step.name)
+		jdi(t, got, "add", "-A")
+		jdi(t, got, "commit", "-q", "-m", step.name)
+
+		if g, w := git(t, got, "rev-parse", "HEAD", "HEAD^{tree}"), git(t, want, "rev-parse", "HEAD", "HEAD^{tree}"); g != w {
+			t.Fatalf("after %q, HEAD and its tree are\n%s, git made\n%s", step.name, g, w)
+		}
+	}
+
+	git(t, want, "tag", "-a", "-m", "release", "v1")
+	jdi(t, got, "tag", "-a", "-m", "release", "v1")
+	if g, w := git(t, got, "rev-parse", "v1"), git(t, want, "rev-parse", "v1"); g != w {
+		t.Errorf("annotated tag is %s, git made %s", g, w)
+	}
+	sameState(t, got, want)
+}
@@ -1,42 +1,158 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/utkarsh5026/justdoit/app/cmd"
+	"github.com/utkarsh5026/justdoit/app/cmd/commands"
+	"github.com/utkarsh5026/justdoit/app/cmd/trace"
+	"os"
+	"path/filepath"
 )
 
 func initCommand() *cobra.Command {
 	var repoPath string
+	var opts cmd.InitOptions
+	var quiet bool
 	initCmd := &cobra.Command{
-		Use:   "init",
+		Use:   "init [directory]",
 		Short: "Create an empty Git repository or reinitialize an existing one",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(command *cobra.Command, args []string) error {
-			_, err := cmd.CreateGitRepository(repoPath)
+			if len(args) > 0 {
+				repoPath = args[0]
+			}
+			repo, reinit, err := cmd.CreateGitRepository(repoPath, opts)
 			if err != nil {
 				return err
 			}
 
-			fmt.Println("Initialized empty Git repository in", repoPath)
+			if reinit && opts.InitialBranch != "" {
+				fmt.Fprintf(os.Stderr, "warning: re-init: ignored --initial-branch=%s\n", opts.InitialBranch)
+			}
+			switch {
+			case quiet:
+			case reinit:
+				fmt.Printf("Reinitialized existing Git repository in %s%c\n", repo.GitDir, filepath.Separator)
+			default:
+				fmt.Printf("Initialized empty Git repository in %s%c\n", repo.GitDir, filepath.Separator)
+			}
 			return nil
 		},
 	}
 
 	initCmd.Flags().StringVarP(&repoPath, "path",
 		"p", ".", "The path to the repository")
+	initCmd.Flags().StringVarP(&opts.InitialBranch, "initial-branch",
+		"b", "", "The name of the initial branch (default: init.defaultBranch or "+cmd.DefaultBranch+")")
+	initCmd.Flags().StringVar(&opts.Template, "template", "",
+		"Copy hooks, info and description from this directory")
+	initCmd.Flags().StringVar(&opts.Shared, "shared", "",
+		"Share the repository with a group or all users: umask, group, all or an octal mode")
+	initCmd.Flags().Lookup("shared").NoOptDefVal = "group"
+	initCmd.Flags().BoolVar(&opts.Bare, "bare", false, "Create a bare repository, without a working tree")
+	initCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only print errors")
 	return initCmd
 }
 
 func main() {
+	var traceOutput, noReplaceObjects bool
+	var namespace string
 	rootCmd := &cobra.Command{
 		Use:   "justdoit",
 		Short: "It is a simple CLI application to manage your tasks.",
+		// Errors are reported once by main, like git's "fatal:" messages,
+		// without the usage text.
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRun: func(command *cobra.Command, args []string) {
+			if traceOutput {
+				trace.Enable(os.Stderr)
+			}
+			if noReplaceObjects {
+				os.Setenv(cmd.NoReplaceObjectsEnv, "1")
+			}
+			if namespace != "" {
+				os.Setenv(cmd.NamespaceEnv, namespace)
+			}
+		},
 	}
 
+	// Not --verbose, which commands define for themselves as git's do.
+	rootCmd.PersistentFlags().BoolVar(&traceOutput, "trace",
+		false, "Write trace output to stderr (same as "+trace.EnvVar+"=1)")
+	rootCmd.PersistentFlags().BoolVar(&noReplaceObjects, "no-replace-objects",
+		false, "Ignore replace refs (same as "+cmd.NoReplaceObjectsEnv+"=1)")
+	rootCmd.PersistentFlags().StringVar(&namespace, "namespace", "",
+		"Serve the refs of this namespace (same as "+cmd.NamespaceEnv+"=<namespace>)")
+
 	initCmd := initCommand()
 	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(commands.MaintenanceCommand())
+	rootCmd.AddCommand(commands.PruneCommand())
+	rootCmd.AddCommand(commands.CountObjectsCommand())
+	rootCmd.AddCommand(commands.UnpackObjectsCommand())
+	rootCmd.AddCommand(commands.FastExportCommand())
+	rootCmd.AddCommand(commands.FastImportCommand())
+	rootCmd.AddCommand(commands.DaemonCommand())
+	rootCmd.AddCommand(commands.ServeCommand())
+	rootCmd.AddCommand(commands.UploadPackCommand())
+	rootCmd.AddCommand(commands.ReceivePackCommand())
+	rootCmd.AddCommand(commands.ShowRefCommand())
+	rootCmd.AddCommand(commands.LsRemoteCommand())
+	rootCmd.AddCommand(commands.CloneCommand())
+	rootCmd.AddCommand(commands.FetchCommand())
+	rootCmd.AddCommand(commands.PushCommand())
+	rootCmd.AddCommand(commands.RemoteCommand())
+	rootCmd.AddCommand(commands.BranchCommand())
+	rootCmd.AddCommand(commands.TagCommand())
+	rootCmd.AddCommand(commands.VerifyTagCommand())
+	rootCmd.AddCommand(commands.CheckoutCommand())
+	rootCmd.AddCommand(commands.CommitCommand())
+	rootCmd.AddCommand(commands.RebaseCommand())
+	rootCmd.AddCommand(commands.MergeCommand())
+	rootCmd.AddCommand(commands.UndoCommand())
+	rootCmd.AddCommand(commands.AddCommand())
+	rootCmd.AddCommand(commands.StatusCommand())
+	rootCmd.AddCommand(commands.StashCommand())
+	rootCmd.AddCommand(commands.LsFilesCommand())
+	rootCmd.AddCommand(commands.UpdateIndexCommand())
+	rootCmd.AddCommand(commands.LogCommand())
+	rootCmd.AddCommand(commands.WhatchangedCommand())
+	rootCmd.AddCommand(commands.DiffCommand())
+	rootCmd.AddCommand(commands.ShowCommand())
+	rootCmd.AddCommand(commands.RevListCommand())
+	rootCmd.AddCommand(commands.ReplaceCommand())
+	rootCmd.AddCommand(commands.FsckCommand())
+	rootCmd.AddCommand(commands.HashObjectCommand())
+	rootCmd.AddCommand(commands.CatFileCommand())
+	rootCmd.AddCommand(commands.VarCommand())
+	rootCmd.AddCommand(commands.VersionCommand())
+	rootCmd.AddCommand(commands.BrowseCommand())
+	rootCmd.AddCommand(commands.UICommand())
+	rootCmd.AddCommand(commands.DiffToolCommand())
+	rootCmd.AddCommand(commands.MergeToolCommand())
+	rootCmd.AddCommand(commands.RerereCommand())
+	rootCmd.AddCommand(commands.StripspaceCommand())
+	rootCmd.AddCommand(commands.InterpretTrailersCommand())
+	rootCmd.AddCommand(commands.CheckMessageCommand())
+	rootCmd.AddCommand(commands.CheckAttrCommand())
+	rootCmd.AddCommand(commands.CheckMailmapCommand())
+	rootCmd.AddCommand(commands.BenchCommand())
 	if err := rootCmd.Execute(); err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode returns the exit status for a failed command: the one carried by
+// the error, such as that of a program it ran, or 128 as git uses for fatal
+// errors.
+func exitCode(err error) int {
+	var coded interface{ ExitCode() int }
+	if errors.As(err, &coded) && coded.ExitCode() > 0 {
+		return coded.ExitCode()
 	}
+	return 128
 }